@@ -0,0 +1,66 @@
+package main
+
+import "time"
+
+// Adaptive completion timeout: instead of one idle threshold for all
+// batches, each batch waits a multiple of the longest recent gap between
+// its arrivals, so a fast LAN copy completes quickly and a slow WAN upload
+// that pauses between files is not cut short.
+const (
+	adaptiveGaps   = 20 // recent gaps kept per batch
+	adaptiveFactor = 3  // threshold = factor × longest recent gap
+)
+
+// noteArrivalGap records the time since b last grew. Caller must hold
+// batchesMu.
+func noteArrivalGap(b *Batch, now time.Time) {
+	if b.GrowTime.IsZero() {
+		return
+	}
+	b.Gaps = append(b.Gaps, now.Sub(b.GrowTime))
+	if len(b.Gaps) > adaptiveGaps {
+		b.Gaps = b.Gaps[len(b.Gaps)-adaptiveGaps:]
+	}
+}
+
+// adaptiveBounds returns the configured range of the adaptive timeout;
+// the lower bound is never under the 10s minimum of the fixed timeout
+func adaptiveBounds() (min, max time.Duration) {
+	min = time.Duration(config.AdaptiveMin) * time.Second
+	if min < 10*time.Second {
+		min = 10 * time.Second
+	}
+	max = time.Duration(config.AdaptiveMax) * time.Second
+	if max < min {
+		max = min
+	}
+	return min, max
+}
+
+// batchTimeout is the idle time after which b completes: the completion
+// timeout, or in adaptive mode adaptiveFactor times the longest recent gap
+// between arrivals, within the configured bounds. A batch without a gap
+// yet starts from the completion timeout. Caller must hold batchesMu.
+func batchTimeout(b *Batch) time.Duration {
+	timeout := completionTimeout()
+	if !config.AdaptiveTimeout {
+		return timeout
+	}
+	if len(b.Gaps) > 0 {
+		var longest time.Duration
+		for _, g := range b.Gaps {
+			if g > longest {
+				longest = g
+			}
+		}
+		timeout = adaptiveFactor * longest
+	}
+	min, max := adaptiveBounds()
+	if timeout < min {
+		timeout = min
+	}
+	if timeout > max {
+		timeout = max
+	}
+	return timeout
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBatchTimeout(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.CompletionTimeout = 30
+	config.AdaptiveMin, config.AdaptiveMax = 10, 120
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b := &Batch{Status: "uploading"}
+	noteGrowth(b, start)
+	if got := batchTimeout(b); got != 30*time.Second {
+		t.Errorf("fixed timeout = %v, want 30s", got)
+	}
+
+	config.AdaptiveTimeout = true
+	if got := batchTimeout(b); got != 30*time.Second {
+		t.Errorf("timeout without gaps = %v, want the completion timeout", got)
+	}
+	// A fast copy: gaps of a second give the lower bound
+	for i := 1; i <= 5; i++ {
+		noteGrowth(b, start.Add(time.Duration(i)*time.Second))
+	}
+	if got := batchTimeout(b); got != 10*time.Second {
+		t.Errorf("fast batch timeout = %v, want 10s", got)
+	}
+	// One pause of 20s between files triples to 60s
+	noteGrowth(b, start.Add(25*time.Second))
+	if got := batchTimeout(b); got != 60*time.Second {
+		t.Errorf("timeout after a 20s gap = %v, want 60s", got)
+	}
+	// Long pauses stop at the upper bound
+	noteGrowth(b, start.Add(5*time.Minute))
+	if got := batchTimeout(b); got != 120*time.Second {
+		t.Errorf("timeout after a long gap = %v, want 120s", got)
+	}
+	// Only recent gaps count
+	now := start.Add(5 * time.Minute)
+	for i := 0; i < adaptiveGaps; i++ {
+		now = now.Add(2 * time.Second)
+		noteGrowth(b, now)
+	}
+	if got := batchTimeout(b); got != 10*time.Second {
+		t.Errorf("timeout after the long gap aged out = %v, want 10s", got)
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// apiServer is the running API server, nil when the API is disabled
+var apiServer *http.Server
+
+// apiEvent is a "file received" event posted by an external system, e.g. an
+// FTP server upload hook, for files the filesystem watcher can't observe
+type apiEvent struct {
+	Path string `json:"path"`
+	Size *int64 `json:"size,omitempty"` // optional, stat'ed locally when absent
+}
+
+// apiBatch is the JSON form of a batch
+type apiBatch struct {
+	ID        string    `json:"id"`
+	Folder    string    `json:"folder"`
+	Status    string    `json:"status"`
+	Files     int       `json:"files"`
+	TotalSize int64     `json:"total_size"`
+	StartTime time.Time `json:"start_time"`
+	LastTime  time.Time `json:"last_time"`
+	SessionID string    `json:"session_id,omitempty"`
+	CheckCode string    `json:"check_code,omitempty"`
+	SignOffs  []SignOff `json:"sign_offs,omitempty"`
+}
+
+// apiSignRequest is the body of a sign request; By defaults to the
+// configured operator
+type apiSignRequest struct {
+	By      string `json:"by"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// apiResponse wraps every API reply with the current monitoring session
+type apiResponse struct {
+	Session string      `json:"session,omitempty"`
+	Error   string      `json:"error,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func writeAPIResponse(w http.ResponseWriter, status int, data interface{}, errMsg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(apiResponse{Session: sessionID, Error: errMsg, Data: data})
+}
+
+// API roles. Viewers read batches and post events, signers may also sign.
+// Without a sign token every authorized caller is a signer.
+const (
+	apiRoleViewer = "viewer"
+	apiRoleSigner = "signer"
+)
+
+// apiRole returns the role the request's bearer token grants, or "" when
+// it is not authorized
+func apiRole(r *http.Request) string {
+	return apiRoleFor(r.Header.Get("Authorization"))
+}
+
+// apiRoleFor returns the role an Authorization value grants, or "" when it
+// is not authorized. A sign token without an API token is needed for every
+// call, so setting only the sign token never leaves the API open.
+func apiRoleFor(auth string) string {
+	if config.SignToken != "" && bearerMatches(auth, config.SignToken) {
+		return apiRoleSigner
+	}
+	switch {
+	case config.APIToken != "":
+		if !bearerMatches(auth, config.APIToken) {
+			return ""
+		}
+	case config.SignToken != "":
+		return ""
+	}
+	if config.SignToken != "" {
+		return apiRoleViewer
+	}
+	return apiRoleSigner
+}
+
+// bearerMatches compares an Authorization value with a token in constant
+// time, so response times don't give the token away
+func bearerMatches(auth, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+token)) == 1
+}
+
+// apiPrincipal identifies who signs through the API, D-Bus or gRPC: the
+// token that grants signing, as holding it is all that was checked. The
+// token itself is never recorded.
+func apiPrincipal() string {
+	switch {
+	case config.SignToken != "":
+		return "token:sign"
+	case config.APIToken != "":
+		return "token:api"
+	}
+	return "token:none"
+}
+
+// apiAuthorized checks the bearer token when one is configured
+func apiAuthorized(r *http.Request) bool {
+	return apiRole(r) != ""
+}
+
+// newAPIHandler builds the HTTP API. Accepted events go through the same
+// batch pipeline as fsnotify events.
+func newAPIHandler(updateUI func(), app fyne.App) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/events", func(w http.ResponseWriter, r *http.Request) {
+		if !apiAuthorized(r) {
+			writeAPIResponse(w, http.StatusUnauthorized, nil, "unauthorized")
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeAPIResponse(w, http.StatusMethodNotAllowed, nil, "POST required")
+			return
+		}
+		if !isMonitoring {
+			writeAPIResponse(w, http.StatusConflict, nil, "not monitoring")
+			return
+		}
+		var ev apiEvent
+		if err := json.NewDecoder(r.Body).Decode(&ev); err != nil {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "invalid JSON: "+err.Error())
+			return
+		}
+		if ev.Path == "" || !filepath.IsAbs(ev.Path) {
+			writeAPIResponse(w, http.StatusBadRequest, nil, "path must be absolute")
+			return
+		}
+		if !isMonitoredFile(ev.Path) {
+			writeAPIResponse(w, http.StatusOK, map[string]bool{"accepted": false}, "")
+			return
+		}
+
+		var isNewBatch bool
+		if ev.Size != nil {
+			isNewBatch = addObservedFile(ev.Path, *ev.Size)
+		} else {
+			isNewBatch = addFileToBatch(ev.Path)
+		}
+		logEvent("API 事件: %s", ev.Path)
+		announceIngest(isNewBatch, ev.Path, updateUI, app)
+		writeAPIResponse(w, http.StatusOK, map[string]bool{"accepted": true, "new_batch": isNewBatch}, "")
+	})
+
+	mux.HandleFunc("/api/batches", func(w http.ResponseWriter, r *http.Request) {
+		if !apiAuthorized(r) {
+			writeAPIResponse(w, http.StatusUnauthorized, nil, "unauthorized")
+			return
+		}
+		batchesMu.RLock()
+		list := make([]*Batch, 0, len(batches))
+		for _, b := range batches {
+			list = append(list, b)
+		}
+		sortBatches(list, sortByStartTime)
+		out := make([]apiBatch, 0, len(list))
+		for _, b := range list {
+			out = append(out, toAPIBatch(b))
+		}
+		batchesMu.RUnlock()
+		writeAPIResponse(w, http.StatusOK, out, "")
+	})
+
+	mux.HandleFunc("/api/batches/{id}/sign", func(w http.ResponseWriter, r *http.Request) {
+		if !apiAuthorized(r) {
+			writeAPIResponse(w, http.StatusUnauthorized, nil, "unauthorized")
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeAPIResponse(w, http.StatusMethodNotAllowed, nil, "POST required")
+			return
+		}
+		if apiRole(r) != apiRoleSigner {
+			writeAPIResponse(w, http.StatusForbidden, nil, "signing requires the sign token")
+			return
+		}
+		var req apiSignRequest
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				writeAPIResponse(w, http.StatusBadRequest, nil, "invalid JSON: "+err.Error())
+				return
+			}
+		}
+		if req.By == "" {
+			req.By = config.OperatorName
+		}
+
+		batchesMu.Lock()
+		b, ok := batches[r.PathValue("id")]
+		if !ok {
+			batchesMu.Unlock()
+			writeAPIResponse(w, http.StatusNotFound, nil, "batch not found")
+			return
+		}
+		err := signBatchAs(b, req.By, apiPrincipal(), req.Comment, time.Now())
+		out := toAPIBatch(b)
+		batchesMu.Unlock()
+		if err != nil {
+			writeAPIResponse(w, http.StatusConflict, nil, err.Error())
+			return
+		}
+		updateUI()
+		writeAPIResponse(w, http.StatusOK, out, "")
+	})
+
+	// The caller's role, so clients can offer signing only to signers
+	mux.HandleFunc("/api/whoami", func(w http.ResponseWriter, r *http.Request) {
+		role := apiRole(r)
+		if role == "" {
+			writeAPIResponse(w, http.StatusUnauthorized, nil, "unauthorized")
+			return
+		}
+		writeAPIResponse(w, http.StatusOK, map[string]string{"role": role}, "")
+	})
+
+	// Server-streaming batch lifecycle events as JSON Lines, one event per
+	// line, until the client disconnects
+	mux.HandleFunc("/api/watch", func(w http.ResponseWriter, r *http.Request) {
+		if !apiAuthorized(r) {
+			writeAPIResponse(w, http.StatusUnauthorized, nil, "unauthorized")
+			return
+		}
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeAPIResponse(w, http.StatusInternalServerError, nil, "streaming unsupported")
+			return
+		}
+		events, unsubscribe := batchEvents.Subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case ev := <-events:
+				if err := enc.Encode(ev); err != nil {
+					return
+				}
+				flusher.Flush()
+			}
+		}
+	})
+
+	mux.HandleFunc("/healthz", handleHealthz)
+
+	return mux
+}
+
+// toAPIBatch converts a batch for the API. Caller must hold batchesMu.
+func toAPIBatch(b *Batch) apiBatch {
+	return apiBatch{
+		ID:        b.ID,
+		Folder:    b.Folder,
+		Status:    b.Status,
+		Files:     len(b.Files),
+		TotalSize: b.TotalSize,
+		StartTime: b.StartTime,
+		LastTime:  b.LastTime,
+		SessionID: b.SessionID,
+		CheckCode: b.CheckCode,
+		SignOffs:  b.SignOffs,
+	}
+}
+
+// startAPIServer serves the API on config.APIListen in the background
+func startAPIServer(updateUI func(), app fyne.App) *http.Server {
+	addr := strings.TrimSpace(config.APIListen)
+	if addr == "" {
+		addr = "127.0.0.1:8765"
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           newAPIHandler(updateUI, app),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		logEvent("API 服务监听 %s", addr)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logEvent("API 服务启动失败: %v", err)
+		}
+	}()
+	return srv
+}
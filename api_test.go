@@ -0,0 +1,192 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAPIEvents(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	origConfig := config
+	origBatches := batches
+	origMonitoring := isMonitoring
+	defer func() {
+		config = origConfig
+		batches = origBatches
+		isMonitoring = origMonitoring
+	}()
+
+	config = Config{VideoEnabled: true, APIToken: "secret"}
+	batches = make(map[string]*Batch)
+	isMonitoring = true
+
+	handler := newAPIHandler(func() {}, nil)
+	post := func(body, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/events", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	path := filepath.Join(tmpDir, "ftp_upload.mp4")
+	body := `{"path": ` + jsonString(path) + `, "size": 2048}`
+
+	if rec := post(body, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Missing token: status %d, want 401", rec.Code)
+	}
+	if rec := post("{bad", "secret"); rec.Code != http.StatusBadRequest {
+		t.Errorf("Bad JSON: status %d, want 400", rec.Code)
+	}
+
+	rec := post(body, "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Valid event: status %d, body %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		Data map[string]bool `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if !resp.Data["accepted"] || !resp.Data["new_batch"] {
+		t.Errorf("Unexpected response data: %v", resp.Data)
+	}
+	for _, b := range batches {
+		if b.TotalSize != 2048 {
+			t.Errorf("TotalSize = %d, want reported size 2048", b.TotalSize)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/batches", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	list := httptest.NewRecorder()
+	handler.ServeHTTP(list, req)
+	var batchesResp struct {
+		Data []apiBatch `json:"data"`
+	}
+	json.NewDecoder(list.Body).Decode(&batchesResp)
+	if len(batchesResp.Data) != 1 || batchesResp.Data[0].Files != 1 {
+		t.Errorf("Unexpected batch list: %+v", batchesResp.Data)
+	}
+}
+
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+func TestAPISignAndWatch(t *testing.T) {
+	origConfig := config
+	origBatches := batches
+	defer func() {
+		config = origConfig
+		batches = origBatches
+	}()
+	config = Config{OperatorName: "api"}
+	batches = map[string]*Batch{"b1": {ID: "b1", Status: "completed", FileSizes: map[string]int64{}}}
+
+	srv := httptest.NewServer(newAPIHandler(func() {}, nil))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	signResp, err := http.Post(srv.URL+"/api/batches/b1/sign", "application/json", strings.NewReader(`{"by": "Bob", "comment": "ok"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signResp.Body.Close()
+	if signResp.StatusCode != http.StatusOK {
+		t.Fatalf("sign status = %d", signResp.StatusCode)
+	}
+	if batches["b1"].Status != "signed" || batches["b1"].SignOffs[0].By != "Bob" {
+		t.Errorf("batch not signed by Bob: %+v", batches["b1"])
+	}
+
+	var ev batchEvent
+	if err := json.NewDecoder(resp.Body).Decode(&ev); err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != eventSigned || ev.Batch.ID != "b1" {
+		t.Errorf("event = %+v, want signed b1", ev)
+	}
+
+	// Signing again conflicts; unknown batches are 404
+	again, _ := http.Post(srv.URL+"/api/batches/b1/sign", "application/json", nil)
+	again.Body.Close()
+	if again.StatusCode != http.StatusConflict {
+		t.Errorf("second sign status = %d, want 409", again.StatusCode)
+	}
+	missing, _ := http.Post(srv.URL+"/api/batches/nope/sign", "application/json", nil)
+	missing.Body.Close()
+	if missing.StatusCode != http.StatusNotFound {
+		t.Errorf("unknown batch status = %d, want 404", missing.StatusCode)
+	}
+}
+
+func TestAPIRoles(t *testing.T) {
+	origConfig, origBatches := config, batches
+	defer func() { config, batches = origConfig, origBatches }()
+	batches = map[string]*Batch{"b1": {ID: "b1", Status: "completed"}}
+	handler := newAPIHandler(func() {}, nil)
+	call := func(method, path, token string) int {
+		req := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	tests := []struct {
+		apiToken, signToken, token string
+		role                       string
+	}{
+		{"", "", "", apiRoleSigner},
+		{"view", "", "view", apiRoleSigner},
+		{"view", "", "", ""},
+		{"view", "sign", "view", apiRoleViewer},
+		{"view", "sign", "sign", apiRoleSigner},
+		{"view", "sign", "other", ""},
+		{"", "sign", "", ""},
+		{"", "sign", "other", ""},
+		{"", "sign", "sign", apiRoleSigner},
+	}
+	for _, tt := range tests {
+		config = Config{APIToken: tt.apiToken, SignToken: tt.signToken}
+		req := httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		if got := apiRole(req); got != tt.role {
+			t.Errorf("tokens %q/%q, bearer %q: role %q, want %q", tt.apiToken, tt.signToken, tt.token, got, tt.role)
+		}
+	}
+
+	config = Config{APIToken: "view", SignToken: "sign", OperatorName: "Lin"}
+	if code := call(http.MethodGet, "/api/batches", "view"); code != http.StatusOK {
+		t.Errorf("viewer list status = %d, want 200", code)
+	}
+	if code := call(http.MethodPost, "/api/batches/b1/sign", "view"); code != http.StatusForbidden {
+		t.Errorf("viewer sign status = %d, want 403", code)
+	}
+	if batches["b1"].Status != "completed" {
+		t.Errorf("viewer signed the batch: %s", batches["b1"].Status)
+	}
+	if code := call(http.MethodPost, "/api/batches/b1/sign", "sign"); code != http.StatusOK {
+		t.Errorf("signer sign status = %d, want 200", code)
+	}
+}
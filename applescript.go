@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// appleScriptLibraryPath returns ~/Library/Script Libraries/FidruaWatch.scpt,
+// where `script "FidruaWatch"` finds it
+func appleScriptLibraryPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, "Library", "Script Libraries", "FidruaWatch.scpt"), nil
+}
+
+// appleScriptString renders s as an AppleScript string literal
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// appleScriptLibrary is the source of the script library. Its handlers run
+// `fidruawatch ctl` against the instance using cfgPath, so AppleScript and
+// the Run AppleScript action of Shortcuts can drive the running app.
+func appleScriptLibrary(exePath, cfgPath string) string {
+	return fmt.Sprintf(`-- FidruaWatch script library, installed from Settings > Other.
+-- tell script "FidruaWatch" to addFolder("/Volumes/CARD/DCIM")
+use AppleScript version "2.4"
+use framework "Foundation"
+use scripting additions
+
+property appPath : %s
+property configPath : %s
+
+on ctl(args)
+	return do shell script quoted form of appPath & " ctl --config " & quoted form of configPath & " " & args
+end ctl
+
+on startMonitoring()
+	ctl("start")
+end startMonitoring
+
+on stopMonitoring()
+	ctl("stop")
+end stopMonitoring
+
+on showWindow()
+	ctl("show")
+end showWindow
+
+-- f is a POSIX path, an alias or a file
+on addFolder(f)
+	if class of f is text then
+		set p to f
+	else
+		set p to POSIX path of f
+	end if
+	ctl("add-path " & quoted form of p)
+end addFolder
+
+on parseJSON(json)
+	set jsonData to (current application's NSString's stringWithString:json)'s dataUsingEncoding:(current application's NSUTF8StringEncoding)
+	return (current application's NSJSONSerialization's JSONObjectWithData:jsonData options:0 |error|:(missing value))
+end parseJSON
+
+-- {monitoring:, path:, uploading:} of the running app
+on monitoringStatus()
+	set s to parseJSON(ctl("status --json"))
+	set p to ""
+	if (s's objectForKey:"path") is not missing value then set p to (s's objectForKey:"path") as text
+	return {monitoring:(s's objectForKey:"monitoring") as boolean, path:p, uploading:(s's objectForKey:"uploading") as integer}
+end monitoringStatus
+
+-- A record per batch, newest first: id, folder, status, files, total_size,
+-- start_time and last_time (ISO 8601 text)
+on listBatches()
+	return ((parseJSON(ctl("status --json")))'s objectForKey:"batches") as list
+end listBatches
+`, appleScriptString(exePath), appleScriptString(cfgPath))
+}
+
+// installAppleScriptLibrary compiles the library with osacompile
+func installAppleScriptLibrary() error {
+	exePath := getExecutablePath()
+	if exePath == "" {
+		return fmt.Errorf("无法获取程序路径")
+	}
+	path, err := appleScriptLibraryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	cmd := exec.Command("osacompile", "-o", path)
+	cmd.Stdin = strings.NewReader(appleScriptLibrary(exePath, configPath))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("osacompile 失败: %v %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// newAppleScriptRow is the macOS settings row installing the script library
+func newAppleScriptRow(w fyne.Window) fyne.CanvasObject {
+	status := widget.NewLabel("")
+	refresh := func() {
+		path, err := appleScriptLibraryPath()
+		if _, serr := os.Stat(path); err == nil && serr == nil {
+			status.SetText("状态: 已安装, 在脚本中使用 tell script \"FidruaWatch\"")
+		} else {
+			status.SetText("状态: 未安装")
+		}
+	}
+	install := widget.NewButton("安装/更新", func() {
+		if err := installAppleScriptLibrary(); err != nil {
+			dialog.ShowError(err, w)
+		} else {
+			logEvent("已安装 AppleScript 脚本库")
+		}
+		refresh()
+	})
+	remove := widget.NewButton("移除", func() {
+		if path, err := appleScriptLibraryPath(); err == nil {
+			os.Remove(path)
+			logEvent("已移除 AppleScript 脚本库")
+		}
+		refresh()
+	})
+	refresh()
+	return container.NewVBox(
+		widget.NewLabel("🍎 AppleScript / 快捷指令: startMonitoring, stopMonitoring, addFolder, listBatches"),
+		container.NewHBox(install, remove),
+		status,
+	)
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAppleScriptLibrary(t *testing.T) {
+	if got := appleScriptString(`C:\x "y"`); got != `"C:\\x \"y\""` {
+		t.Errorf("appleScriptString = %s", got)
+	}
+	lib := appleScriptLibrary(`/Applications/Fidrua "Watch".app/Contents/MacOS/fidruawatch`, "/Users/me/config.json")
+	for _, want := range []string{
+		`property appPath : "/Applications/Fidrua \"Watch\".app/Contents/MacOS/fidruawatch"`,
+		`property configPath : "/Users/me/config.json"`,
+		"on addFolder(f)",
+		"on listBatches()",
+	} {
+		if !strings.Contains(lib, want) {
+			t.Errorf("Library lacks %s", want)
+		}
+	}
+}
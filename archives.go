@@ -0,0 +1,160 @@
+package main
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Archive check results of a batch
+const (
+	archiveUnchecked = ""
+	archiveOK        = "ok"
+	archiveBad       = "bad"
+)
+
+// errNoArchiveTool is returned for formats that need a missing external tool
+var errNoArchiveTool = errors.New("no tool to test this archive format")
+
+// errArchiveTimeout is returned when an external tool ran out of time or was
+// cancelled; the archive is left unchecked rather than marked corrupt
+var errArchiveTimeout = errors.New("archive test timed out")
+
+// archiveToolTimeout bounds one run of 7z or unrar, which can hang on a
+// damaged archive or take very long on a huge one
+const archiveToolTimeout = 10 * time.Minute
+
+// testArchive checks an archive for truncation and corruption: zip and gzip
+// natively (every entry's CRC is verified), 7z and rar with 7z or unrar
+func testArchive(ctx context.Context, path string) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".zip":
+		return testZip(path)
+	case ".gz":
+		return testGzip(path)
+	case ".7z", ".rar":
+		return testWithTool(ctx, path)
+	}
+	return errNoArchiveTool
+}
+
+// testZip reads every entry so archive/zip verifies its CRC-32
+func testZip(path string) error {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+		_, err = io.Copy(io.Discard, rc)
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Name, err)
+		}
+	}
+	return nil
+}
+
+// testGzip decompresses the whole stream, which checks its CRC and length
+func testGzip(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	_, err = io.Copy(io.Discard, zr)
+	return err
+}
+
+// testWithTool runs "7z t" (or "unrar t" for rar) when installed
+func testWithTool(ctx context.Context, path string) error {
+	ctx, cancel := context.WithTimeout(ctx, archiveToolTimeout)
+	defer cancel()
+	for _, tool := range []string{"7z", "7za", "7zz"} {
+		if bin, err := exec.LookPath(tool); err == nil {
+			return runArchiveTool(ctx, exec.CommandContext(ctx, bin, "t", "-bd", path))
+		}
+	}
+	if strings.EqualFold(filepath.Ext(path), ".rar") {
+		if bin, err := exec.LookPath("unrar"); err == nil {
+			return runArchiveTool(ctx, exec.CommandContext(ctx, bin, "t", "-idq", path))
+		}
+	}
+	return errNoArchiveTool
+}
+
+// runArchiveTool runs a test command, reporting errArchiveTimeout when ctx
+// ended it
+func runArchiveTool(ctx context.Context, cmd *exec.Cmd) error {
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return errArchiveTimeout
+	}
+	return err
+}
+
+// verifyBatchArchives tests the archives of a completed batch and marks the
+// batch ok or bad. Formats without an available tool and tests that time out
+// are skipped.
+func verifyBatchArchives(ctx context.Context, b *Batch, updateUI func()) {
+	batchesMu.RLock()
+	folder := b.Folder
+	var archives []string
+	for _, f := range b.Files {
+		if hasExt(f, archiveExts) {
+			archives = append(archives, f)
+		}
+	}
+	batchesMu.RUnlock()
+
+	checked := 0
+	var bad []string
+	for _, f := range archives {
+		err := testArchive(ctx, filepath.Join(folder, f))
+		if err == errNoArchiveTool {
+			continue
+		}
+		if err == errArchiveTimeout {
+			logEvent("压缩包校验超时, 未检查: %s", f)
+			continue
+		}
+		checked++
+		if err != nil {
+			logEvent("压缩包校验失败 %s: %v", f, err)
+			bad = append(bad, f)
+		}
+	}
+	if checked == 0 {
+		return
+	}
+
+	batchesMu.Lock()
+	b.BadFiles = bad
+	b.Archive = archiveOK
+	if len(bad) > 0 {
+		b.Archive = archiveBad
+		sysLogBatch(sysLogError, b, "压缩包损坏: %s", strings.Join(bad, ", "))
+	}
+	if b.Status != "uploading" {
+		recordHistory(b)
+	}
+	batchesMu.Unlock()
+	updateUI()
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func writeTestZip(t *testing.T, path string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "clip.txt", Method: zip.Store})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(bytes.Repeat([]byte("footage "), 1000))
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestTestArchive(t *testing.T) {
+	dir := t.TempDir()
+
+	good := filepath.Join(dir, "good.zip")
+	data := writeTestZip(t, good)
+	if err := testArchive(context.Background(), good); err != nil {
+		t.Errorf("good zip: %v", err)
+	}
+
+	// Flip a byte of the stored entry: the CRC no longer matches
+	corrupt := filepath.Join(dir, "corrupt.zip")
+	bad := append([]byte(nil), data...)
+	bad[100] ^= 0xFF
+	os.WriteFile(corrupt, bad, 0644)
+	if err := testArchive(context.Background(), corrupt); err == nil {
+		t.Error("corrupt zip passed")
+	}
+
+	// A truncated upload has no central directory
+	truncated := filepath.Join(dir, "truncated.zip")
+	os.WriteFile(truncated, data[:len(data)/2], 0644)
+	if err := testArchive(context.Background(), truncated); err == nil {
+		t.Error("truncated zip passed")
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(bytes.Repeat([]byte("log line\n"), 1000))
+	zw.Close()
+	gzPath := filepath.Join(dir, "logs.gz")
+	os.WriteFile(gzPath, gz.Bytes(), 0644)
+	if err := testArchive(context.Background(), gzPath); err != nil {
+		t.Errorf("good gzip: %v", err)
+	}
+	os.WriteFile(gzPath, gz.Bytes()[:gz.Len()-6], 0644)
+	if err := testArchive(context.Background(), gzPath); err == nil {
+		t.Error("truncated gzip passed")
+	}
+
+	if err := testArchive(context.Background(), filepath.Join(dir, "data.xz")); err != errNoArchiveTool {
+		t.Errorf("xz: err = %v, want errNoArchiveTool", err)
+	}
+}
+
+func TestArchiveToolTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as 7z")
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "7z"), []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := testArchive(ctx, filepath.Join(dir, "footage.7z")); err != errArchiveTimeout {
+		t.Errorf("err = %v, want errArchiveTimeout", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("hung tool was not stopped, took %v", d)
+	}
+}
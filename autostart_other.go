@@ -0,0 +1,13 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+func setAutoStartWindows(exePath string, args []string, enable bool) error {
+	return errors.New("仅支持 Windows")
+}
+
+func isAutoStartEnabledWindows() bool {
+	return false
+}
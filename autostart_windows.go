@@ -0,0 +1,57 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// runKeyPath is the per-user Run key read by Explorer at sign-in
+const runKeyPath = `Software\Microsoft\Windows\CurrentVersion\Run`
+
+// runValueName is the name of our entry under the Run key
+const runValueName = "FidruaWatch"
+
+// runCommand is the command line stored in the Run entry, quoted the way
+// Windows splits it back into arguments
+func runCommand(exePath string, args []string) string {
+	parts := []string{syscall.EscapeArg(exePath)}
+	for _, a := range args {
+		parts = append(parts, syscall.EscapeArg(a))
+	}
+	return strings.Join(parts, " ")
+}
+
+func setAutoStartWindows(exePath string, args []string, enable bool) error {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.SET_VALUE)
+	if err != nil {
+		return fmt.Errorf("无法打开注册表项 HKCU\\%s: %v", runKeyPath, err)
+	}
+	defer key.Close()
+
+	if !enable {
+		if err := key.DeleteValue(runValueName); err != nil && !errors.Is(err, registry.ErrNotExist) {
+			return fmt.Errorf("无法删除启动项 %s: %v", runValueName, err)
+		}
+		return nil
+	}
+	if err := key.SetStringValue(runValueName, runCommand(exePath, args)); err != nil {
+		return fmt.Errorf("无法写入启动项 %s: %v", runValueName, err)
+	}
+	return nil
+}
+
+func isAutoStartEnabledWindows() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, runKeyPath, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	defer key.Close()
+	_, _, err = key.GetStringValue(runValueName)
+	return err == nil
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package main
+
+import "testing"
+
+func TestRunCommand(t *testing.T) {
+	tests := []struct {
+		exe      string
+		args     []string
+		expected string
+	}{
+		{`C:\Tools\fidruawatch.exe`, nil, `C:\Tools\fidruawatch.exe`},
+		{`C:\Program Files\FidruaWatch\fidruawatch.exe`, []string{"--minimized"}, `"C:\Program Files\FidruaWatch\fidruawatch.exe" --minimized`},
+	}
+	for _, tt := range tests {
+		if got := runCommand(tt.exe, tt.args); got != tt.expected {
+			t.Errorf("runCommand(%q, %q) = %s, want %s", tt.exe, tt.args, got, tt.expected)
+		}
+	}
+}
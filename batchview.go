@@ -0,0 +1,176 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+// batchStatusOptions lists the batch states the list can be filtered by
+var batchStatusOptions = []struct {
+	Status string
+	Label  string
+}{
+	{"uploading", "上传中"},
+	{"completed", "已完成"},
+	{statusReview, "待复核"},
+	{"signed", "已签收"},
+}
+
+// statusLabel returns the display name of a batch status
+func statusLabel(status string) string {
+	for _, opt := range batchStatusOptions {
+		if opt.Status == status {
+			return opt.Label
+		}
+	}
+	return status
+}
+
+// batchFilter holds the search text and status filter of the batch list.
+// It is updated from widget callbacks and read while the list is rebuilt.
+type batchFilter struct {
+	mu       sync.Mutex
+	query    string
+	statuses map[string]bool
+}
+
+// newBatchFilter returns a filter that shows every batch
+func newBatchFilter() *batchFilter {
+	f := &batchFilter{statuses: make(map[string]bool)}
+	for _, opt := range batchStatusOptions {
+		f.statuses[opt.Status] = true
+	}
+	return f
+}
+
+// SetQuery sets the text batches are searched for
+func (f *batchFilter) SetQuery(q string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.query = strings.ToLower(strings.TrimSpace(q))
+}
+
+// SetStatusLabels shows only batches whose status label is selected
+func (f *batchFilter) SetStatusLabels(labels []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.statuses = make(map[string]bool)
+	for _, opt := range batchStatusOptions {
+		for _, l := range labels {
+			if l == opt.Label {
+				f.statuses[opt.Status] = true
+			}
+		}
+	}
+}
+
+// Match reports whether a batch passes the filter: its status is selected
+// and the query occurs in its folder or one of its file names.
+// Caller must hold batchesMu.
+func (f *batchFilter) Match(b *Batch) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.statuses[b.Status] {
+		return false
+	}
+	if f.query == "" {
+		return true
+	}
+	if strings.Contains(strings.ToLower(pathKey(displayFolder(b.Folder))), f.query) {
+		return true
+	}
+	for _, name := range b.Files {
+		if strings.Contains(strings.ToLower(pathKey(name)), f.query) {
+			return true
+		}
+	}
+	return false
+}
+
+// Batch list sort orders
+const (
+	sortByStartTime = "start_time"
+	sortByLastTime  = "last_time"
+	sortBySize      = "size"
+	sortByFileCount = "file_count"
+	sortByStatus    = "status"
+)
+
+var batchSortOptions = []struct {
+	Mode  string
+	Label string
+}{
+	{sortByStartTime, "开始时间"},
+	{sortByLastTime, "最近活动"},
+	{sortBySize, "总大小"},
+	{sortByFileCount, "文件数"},
+	{sortByStatus, "状态"},
+}
+
+// newSortSelect builds the sort order picker showing config.BatchSort, or
+// the first order when it names none. Picking an order saves it to
+// config.BatchSort and calls changed.
+func newSortSelect(changed func()) *widget.Select {
+	labels := make([]string, len(batchSortOptions))
+	selected := 0
+	for i, opt := range batchSortOptions {
+		labels[i] = opt.Label
+		if opt.Mode == config.BatchSort {
+			selected = i
+		}
+	}
+	// OnChanged is set afterwards, so showing the saved order doesn't
+	// overwrite it
+	s := widget.NewSelect(labels, nil)
+	s.SetSelectedIndex(selected)
+	s.OnChanged = func(label string) {
+		for _, opt := range batchSortOptions {
+			if opt.Label == label {
+				config.BatchSort = opt.Mode
+				break
+			}
+		}
+		changed()
+	}
+	return s
+}
+
+// statusRank orders statuses by how much attention they need
+func statusRank(status string) int {
+	for i, opt := range batchStatusOptions {
+		if opt.Status == status {
+			return i
+		}
+	}
+	return len(batchStatusOptions)
+}
+
+// sortBatches orders batches for display, largest/newest first, falling back
+// to newest start time for ties. Caller must hold batchesMu.
+func sortBatches(list []*Batch, mode string) {
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		switch mode {
+		case sortByLastTime:
+			if !a.LastTime.Equal(b.LastTime) {
+				return a.LastTime.After(b.LastTime)
+			}
+		case sortBySize:
+			if a.TotalSize != b.TotalSize {
+				return a.TotalSize > b.TotalSize
+			}
+		case sortByFileCount:
+			if len(a.Files) != len(b.Files) {
+				return len(a.Files) > len(b.Files)
+			}
+		case sortByStatus:
+			if ra, rb := statusRank(a.Status), statusRank(b.Status); ra != rb {
+				return ra < rb
+			}
+		}
+		return a.StartTime.After(b.StartTime)
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestBatchFilter(t *testing.T) {
+	origMonitorPath := monitorPath
+	defer func() { monitorPath = origMonitorPath }()
+	monitorPath = "/watch"
+
+	uploading := &Batch{Folder: "/watch/ClientA/Day1", Status: "uploading", Files: []string{"clip.mp4"}}
+	signed := &Batch{Folder: "/watch/ClientB", Status: "signed", Files: []string{"photo.jpg"}}
+
+	f := newBatchFilter()
+	if !f.Match(uploading) || !f.Match(signed) {
+		t.Error("Default filter should show every batch")
+	}
+
+	f.SetQuery("clienta")
+	if !f.Match(uploading) || f.Match(signed) {
+		t.Error("Query should match folder names case-insensitively")
+	}
+
+	f.SetQuery("PHOTO")
+	if f.Match(uploading) || !f.Match(signed) {
+		t.Error("Query should match file names")
+	}
+
+	f.SetQuery("")
+	f.SetStatusLabels([]string{"上传中"})
+	if !f.Match(uploading) || f.Match(signed) {
+		t.Error("Status filter should hide signed batches")
+	}
+}
+
+func TestSortBatches(t *testing.T) {
+	now := time.Now()
+	a := &Batch{ID: "a", Status: "signed", TotalSize: 300, Files: []string{"1"}, StartTime: now, LastTime: now}
+	b := &Batch{ID: "b", Status: "uploading", TotalSize: 100, Files: []string{"1", "2", "3"}, StartTime: now.Add(-time.Hour), LastTime: now.Add(time.Minute)}
+	c := &Batch{ID: "c", Status: "completed", TotalSize: 200, Files: []string{"1", "2"}, StartTime: now.Add(-2 * time.Hour), LastTime: now.Add(-time.Minute)}
+
+	tests := []struct {
+		mode     string
+		expected string
+	}{
+		{sortByStartTime, "abc"},
+		{sortByLastTime, "bac"},
+		{sortBySize, "acb"},
+		{sortByFileCount, "bca"},
+		{sortByStatus, "bca"},
+		{"", "abc"},
+	}
+	for _, tt := range tests {
+		list := []*Batch{c, a, b}
+		sortBatches(list, tt.mode)
+		got := list[0].ID + list[1].ID + list[2].ID
+		if got != tt.expected {
+			t.Errorf("sortBatches(%q) = %s, want %s", tt.mode, got, tt.expected)
+		}
+	}
+}
+
+func TestSortSelectKeepsSavedOrder(t *testing.T) {
+	a := test.NewApp()
+	defer a.Quit()
+	saved := config.BatchSort
+	defer func() { config.BatchSort = saved }()
+
+	config.BatchSort = sortBySize
+	changed := 0
+	s := newSortSelect(func() { changed++ })
+	if config.BatchSort != sortBySize || s.Selected != "总大小" {
+		t.Errorf("after building: BatchSort = %q, selected %q, want %q", config.BatchSort, s.Selected, sortBySize)
+	}
+	if changed != 0 {
+		t.Errorf("building the select triggered %d updates", changed)
+	}
+
+	s.SetSelected("状态")
+	if config.BatchSort != sortByStatus || changed != 1 {
+		t.Errorf("after picking: BatchSort = %q, %d updates", config.BatchSort, changed)
+	}
+
+	config.BatchSort = "bogus"
+	if s := newSortSelect(func() {}); s.Selected != "开始时间" {
+		t.Errorf("unknown order selected %q, want the first", s.Selected)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+)
+
+// fileCategories are the monitored file types, in display order
+var fileCategories = []struct {
+	Name  string
+	Icon  string
+	Exts  []string
+	Color color.NRGBA
+}{
+	{"视频", "🎬", videoExts, color.NRGBA{R: 255, G: 82, B: 82, A: 255}},
+	{"图片", "🖼️", imageExts, color.NRGBA{R: 255, G: 193, B: 7, A: 255}},
+	{"音频", "🎵", audioExts, color.NRGBA{R: 0, G: 200, B: 83, A: 255}},
+	{"文档", "📄", docExts, color.NRGBA{R: 41, G: 121, B: 255, A: 255}},
+	{"压缩包", "📦", archiveExts, color.NRGBA{R: 170, G: 120, B: 80, A: 255}},
+}
+
+// otherCategory covers custom extensions
+const otherCategory = "其他"
+
+// fileCategory names the monitored file type of a file
+func fileCategory(name string) string {
+	for _, c := range fileCategories {
+		if hasExt(name, c.Exts) {
+			return c.Name
+		}
+	}
+	return otherCategory
+}
+
+// categoryCount is the number of files of one category in a batch
+type categoryCount struct {
+	Name  string
+	Files int
+}
+
+// categoryBreakdown counts a batch's files per category in display order,
+// omitting empty categories. Caller must hold batchesMu.
+func categoryBreakdown(b *Batch) []categoryCount {
+	counts := make(map[string]int)
+	for _, f := range b.Files {
+		counts[fileCategory(f)]++
+	}
+	var out []categoryCount
+	for _, c := range fileCategories {
+		if n := counts[c.Name]; n > 0 {
+			out = append(out, categoryCount{c.Name, n})
+		}
+	}
+	if n := counts[otherCategory]; n > 0 {
+		out = append(out, categoryCount{otherCategory, n})
+	}
+	return out
+}
+
+// dominantCategory returns the category with the most files; ties go to
+// the earlier category in display order
+func dominantCategory(breakdown []categoryCount) string {
+	best := categoryCount{Name: otherCategory}
+	for _, c := range breakdown {
+		if c.Files > best.Files {
+			best = c
+		}
+	}
+	return best.Name
+}
+
+// categoryStyle returns the icon and color of a category
+func categoryStyle(name string) (string, color.NRGBA) {
+	for _, c := range fileCategories {
+		if c.Name == name {
+			return c.Icon, c.Color
+		}
+	}
+	return "📁", colorGray
+}
+
+// breakdownText formats a breakdown like "🎬12 🖼️3"
+func breakdownText(breakdown []categoryCount) string {
+	parts := make([]string, len(breakdown))
+	for i, c := range breakdown {
+		icon, _ := categoryStyle(c.Name)
+		parts[i] = fmt.Sprintf("%s%d", icon, c.Files)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,32 @@
+package main
+
+import "testing"
+
+func TestCategoryBreakdown(t *testing.T) {
+	b := &Batch{Files: []string{"a.mp4", "b.MOV", "c.jpg", "d.xyz", "e.mkv"}}
+	breakdown := categoryBreakdown(b)
+	want := []categoryCount{{"视频", 3}, {"图片", 1}, {otherCategory, 1}}
+	if len(breakdown) != len(want) {
+		t.Fatalf("breakdown = %v, want %v", breakdown, want)
+	}
+	for i := range want {
+		if breakdown[i] != want[i] {
+			t.Errorf("breakdown[%d] = %v, want %v", i, breakdown[i], want[i])
+		}
+	}
+	if got := dominantCategory(breakdown); got != "视频" {
+		t.Errorf("dominantCategory = %q", got)
+	}
+	if got := breakdownText(breakdown); got != "🎬3 🖼️1 📁1" {
+		t.Errorf("breakdownText = %q", got)
+	}
+
+	// Ties go to the earlier category
+	tie := []categoryCount{{"图片", 2}, {"音频", 2}}
+	if got := dominantCategory(tie); got != "图片" {
+		t.Errorf("tie dominantCategory = %q", got)
+	}
+	if got := dominantCategory(nil); got != otherCategory {
+		t.Errorf("empty dominantCategory = %q", got)
+	}
+}
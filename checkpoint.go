@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// checkpointInterval is how often in-flight batches are written to disk
+const checkpointInterval = 10 * time.Second
+
+var (
+	// checkpointPath holds the batches still uploading, so they survive a
+	// crash or reboot. It is removed when none are left.
+	checkpointPath string
+	lastCheckpoint []byte
+	// lastEngine is the storage engine lastCheckpoint was written to
+	lastEngine string
+)
+
+// batchCheckpoint is the content of the checkpoint file. Batches use the
+// history record format.
+type batchCheckpoint struct {
+	SavedAt time.Time       `json:"saved_at"`
+	Batches []HistoryRecord `json:"batches"`
+}
+
+// writeCheckpoint saves the batches still uploading, or removes the
+// checkpoint when there are none. Unchanged state is not rewritten.
+func writeCheckpoint() error {
+	var recs []HistoryRecord
+	batchesMu.Lock()
+	for _, b := range batches {
+		if b.Status == "uploading" {
+			recs = append(recs, historyRecordFor(b))
+		}
+	}
+	batchesMu.Unlock()
+
+	if len(recs) == 0 {
+		lastCheckpoint = nil
+		if useDatabase() {
+			return dbWriteCheckpoint(nil)
+		}
+		if err := os.Remove(checkpointPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].ID < recs[j].ID })
+	// Compare without the timestamp, which changes every time
+	data, err := json.Marshal(recs)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(data, lastCheckpoint) && lastEngine == config.StorageEngine {
+		return nil
+	}
+	out, err := json.Marshal(batchCheckpoint{SavedAt: time.Now(), Batches: recs})
+	if err != nil {
+		return err
+	}
+	if useDatabase() {
+		err = dbWriteCheckpoint(out)
+	} else if err = os.MkdirAll(filepath.Dir(checkpointPath), 0755); err == nil {
+		err = writeFileAtomic(checkpointPath, out, 0644)
+	}
+	if err != nil {
+		return err
+	}
+	lastCheckpoint, lastEngine = data, config.StorageEngine
+	return nil
+}
+
+// runCheckpoints writes checkpoints until ctx is cancelled. Callers write a
+// last one themselves when quitting.
+func runCheckpoints(ctx context.Context) {
+	ticker := time.NewTicker(checkpointInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			saveCheckpoint()
+		}
+	}
+}
+
+// saveCheckpoint writes a checkpoint, logging failures
+func saveCheckpoint() {
+	if err := writeCheckpoint(); err != nil {
+		logEvent("保存批次检查点失败: %v", err)
+	}
+}
+
+// restoreCheckpoint brings back the batches that were uploading when the
+// app last stopped. Local files are checked again: missing ones are
+// dropped and sizes updated to what is on disk now. The completion timeout
+// restarts, giving interrupted uploads time to resume.
+func restoreCheckpoint() (int, error) {
+	var data []byte
+	var err error
+	if useDatabase() {
+		data, err = dbReadCheckpoint()
+	} else {
+		data, err = os.ReadFile(checkpointPath)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var cp batchCheckpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	restored := 0
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	for _, rec := range cp.Batches {
+		if _, exists := batches[rec.ID]; exists || rec.ID == "" {
+			continue
+		}
+		b := &Batch{
+			ID:        rec.ID,
+			Folder:    rec.Folder,
+			FileSizes: make(map[string]int64),
+			Status:    "uploading",
+			StartTime: rec.StartTime,
+			LastTime:  now,
+			Uploader:  rec.Uploader,
+			Samples:   newBatchSampleRing(),
+			SessionID: rec.SessionID,
+			Manifest:  rec.Manifest,
+			Timeline:  append([]TimelineEntry(nil), rec.Timeline...),
+		}
+		remote := isRemoteWatchPath(rec.Folder)
+		for _, f := range rec.Files {
+			size := rec.FileSizes[f]
+			if !remote {
+				info, err := os.Stat(filepath.Join(rec.Folder, f))
+				if err != nil || info.IsDir() {
+					continue
+				}
+				size = info.Size()
+			}
+			b.Files = append(b.Files, f)
+			b.FileSizes[f] = size
+			b.TotalSize += size
+		}
+		if len(b.Files) == 0 {
+			logEvent("批次 %s 的文件已不存在, 不再恢复: %s", rec.ID, rec.Folder)
+			continue
+		}
+		if b.TotalSize != rec.TotalSize || len(b.Files) != len(rec.Files) {
+			logEvent("恢复批次 %s: 文件 %d → %d 个, 大小 %s → %s", rec.ID, len(rec.Files), len(b.Files), formatSize(rec.TotalSize), formatSize(b.TotalSize))
+		}
+		noteGrowth(b, now)
+		noteTimeline(b, timelineRestored, "", now)
+		b.Samples.Add(Sample{Time: now, Size: b.TotalSize})
+		batches[b.ID] = b
+		restored++
+	}
+	return restored, nil
+}
+
+// restoreInterruptedBatches restores the checkpoint at startup and logs
+// the outcome
+func restoreInterruptedBatches() int {
+	n, err := restoreCheckpoint()
+	if err != nil {
+		logEvent("读取批次检查点失败: %v", err)
+	} else if n > 0 {
+		logEvent("已恢复 %d 个未完成的批次", n)
+	}
+	return n
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckpointRestore(t *testing.T) {
+	tmpDir := t.TempDir()
+	origBatches, origPath, origLast := batches, checkpointPath, lastCheckpoint
+	defer func() { batches, checkpointPath, lastCheckpoint = origBatches, origPath, origLast }()
+	checkpointPath = filepath.Join(tmpDir, "state", "batches.json")
+
+	folder := filepath.Join(tmpDir, "in")
+	os.MkdirAll(folder, 0755)
+	os.WriteFile(filepath.Join(folder, "a.mp4"), make([]byte, 100), 0644)
+	os.WriteFile(filepath.Join(folder, "b.mp4"), make([]byte, 50), 0644)
+	gone := filepath.Join(tmpDir, "gone")
+
+	start := time.Now().Add(-time.Hour)
+	batches = map[string]*Batch{
+		"1": {ID: "1", Folder: folder, Files: []string{"a.mp4", "b.mp4"},
+			FileSizes: map[string]int64{"a.mp4": 100, "b.mp4": 50}, TotalSize: 150,
+			Status: "uploading", StartTime: start, SessionID: "s1", Samples: newBatchSampleRing()},
+		"2": {ID: "2", Folder: folder, Files: []string{"a.mp4"}, FileSizes: map[string]int64{"a.mp4": 100},
+			TotalSize: 100, Status: "completed", Samples: newBatchSampleRing()},
+		"3": {ID: "3", Folder: gone, Files: []string{"x.mp4"}, FileSizes: map[string]int64{"x.mp4": 10},
+			TotalSize: 10, Status: "uploading", Samples: newBatchSampleRing()},
+	}
+	if err := writeCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The upload went on before the restart, and one file was removed
+	os.WriteFile(filepath.Join(folder, "a.mp4"), make([]byte, 300), 0644)
+	os.Remove(filepath.Join(folder, "b.mp4"))
+	batches = make(map[string]*Batch)
+	n, err := restoreCheckpoint()
+	if err != nil || n != 1 {
+		t.Fatalf("Restored %d batches, err %v; want 1", n, err)
+	}
+	b := batches["1"]
+	if b == nil || b.Status != "uploading" || !b.StartTime.Equal(start) || b.SessionID != "s1" {
+		t.Fatalf("Restored batch %+v", b)
+	}
+	if len(b.Files) != 1 || b.FileSizes["a.mp4"] != 300 || b.TotalSize != 300 {
+		t.Errorf("Sizes not re-verified: files %v, total %d", b.Files, b.TotalSize)
+	}
+	if time.Since(b.LastTime) > time.Minute {
+		t.Error("Completion timeout should restart on restore")
+	}
+	if _, err := restoreCheckpoint(); err != nil || len(batches) != 1 {
+		t.Errorf("Restoring twice duplicated batches: %d", len(batches))
+	}
+
+	// Nothing in flight: the checkpoint goes away
+	b.Status = "completed"
+	if err := writeCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(checkpointPath); !os.IsNotExist(err) {
+		t.Error("Checkpoint kept without uploading batches")
+	}
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Checksum verification results of a batch
+const (
+	checksumUnchecked = ""
+	checksumOK        = "ok"
+	checksumBad       = "bad"
+)
+
+// Per-file results of the checksum verification
+const (
+	sumOK       = "ok"
+	sumMismatch = "mismatch"
+	sumMissing  = "missing" // listed in the sums file but not received
+)
+
+// sumsFileExts and sumsFileNames identify checksum files shipped with an
+// upload, e.g. "delivery.md5", "IMG_0001.CR2.sha256" or "SHA256SUMS"
+var (
+	sumsFileExts  = []string{".md5", ".md5sum", ".sha256", ".sha256sum"}
+	sumsFileNames = []string{"md5sums", "sha256sums", "checksums"}
+)
+
+// isSumsFile reports whether name is a checksum file
+func isSumsFile(name string) bool {
+	if hasExt(name, sumsFileExts) {
+		return true
+	}
+	base := strings.ToLower(filepath.Base(name))
+	base = strings.TrimSuffix(base, ".txt")
+	for _, n := range sumsFileNames {
+		if base == n {
+			return true
+		}
+	}
+	return false
+}
+
+// checksumEntry is one expected hash, Name relative to the batch folder
+type checksumEntry struct {
+	Name string
+	Algo string // "md5" or "sha256"
+	Sum  string // lower case hex
+}
+
+var (
+	// GNU coreutils: "<hex>  name", or "<hex> *name" for binary mode
+	gnuSumLine = regexp.MustCompile(`^([0-9a-fA-F]{32}|[0-9a-fA-F]{64}) [ *]?(.+)$`)
+	// BSD and "--tag" style: "SHA256 (name) = <hex>"
+	bsdSumLine = regexp.MustCompile(`^(MD5|SHA256) \((.+)\) = ([0-9a-fA-F]+)$`)
+	bareSum    = regexp.MustCompile(`^([0-9a-fA-F]{32}|[0-9a-fA-F]{64})$`)
+)
+
+// parseSumsFile reads a checksum file of the batch. Names in it are
+// relative to the file's own folder; a bare hash applies to the file the
+// sums file is named after (IMG_0001.CR2.md5 -> IMG_0001.CR2).
+func parseSumsFile(r io.Reader, sumsName string) ([]checksumEntry, error) {
+	dir := path.Dir(filepath.ToSlash(sumsName))
+	var entries []checksumEntry
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		var name, sum, algo string
+		if m := gnuSumLine.FindStringSubmatch(line); m != nil {
+			sum, name = m[1], m[2]
+		} else if m := bsdSumLine.FindStringSubmatch(line); m != nil {
+			algo, name, sum = strings.ToLower(m[1]), m[2], m[3]
+		} else if m := bareSum.FindStringSubmatch(line); m != nil {
+			sum = m[1]
+			name = path.Base(strings.TrimSuffix(filepath.ToSlash(sumsName), path.Ext(sumsName)))
+		} else {
+			continue
+		}
+		if algo == "" {
+			algo = "md5"
+			if len(sum) == 64 {
+				algo = "sha256"
+			}
+		}
+		entries = append(entries, checksumEntry{
+			Name: path.Clean(path.Join(dir, filepath.ToSlash(name))),
+			Algo: algo,
+			Sum:  strings.ToLower(sum),
+		})
+	}
+	return entries, sc.Err()
+}
+
+// fileSum hashes a file with the given algorithm
+func fileSum(ctx context.Context, p, algo string) (string, error) {
+	var h hash.Hash
+	switch algo {
+	case "md5":
+		h = md5.New()
+	case "sha256":
+		h = sha256.New()
+	default:
+		return "", fmt.Errorf("unknown checksum algorithm %q", algo)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	buf := make([]byte, 1<<20)
+	for {
+		if err := ctx.Err(); err != nil {
+			return "", err
+		}
+		n, err := f.Read(buf)
+		h.Write(buf[:n])
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyBatchChecksums hashes the files of a completed batch listed in the
+// checksum files it brought along and marks the batch ok or bad. Batches
+// without a checksum file are left unchecked.
+func verifyBatchChecksums(ctx context.Context, b *Batch, updateUI func()) {
+	batchesMu.RLock()
+	folder := b.Folder
+	have := make(map[string]string, len(b.Files))
+	var sumsFiles []string
+	for _, f := range b.Files {
+		have[pathKey(filepath.ToSlash(f))] = f
+		if isSumsFile(f) {
+			sumsFiles = append(sumsFiles, f)
+		}
+	}
+	batchesMu.RUnlock()
+	if len(sumsFiles) == 0 {
+		return
+	}
+
+	results := make(map[string]string)
+	for _, s := range sumsFiles {
+		f, err := os.Open(filepath.Join(folder, s))
+		if err != nil {
+			logEvent("读取校验文件失败 %s: %v", s, err)
+			continue
+		}
+		entries, err := parseSumsFile(f, s)
+		f.Close()
+		if err != nil {
+			logEvent("读取校验文件失败 %s: %v", s, err)
+		}
+		for _, e := range entries {
+			name, ok := have[pathKey(e.Name)]
+			if !ok {
+				results[filepath.FromSlash(e.Name)] = sumMissing
+				continue
+			}
+			sum, err := fileSum(ctx, filepath.Join(folder, name), e.Algo)
+			switch {
+			case ctx.Err() != nil:
+				return
+			case err != nil:
+				logEvent("计算校验值失败 %s: %v", name, err)
+				results[name] = sumMissing
+			case sum != e.Sum:
+				logEvent("校验失败 %s: %s 应为 %s, 实际 %s", name, strings.ToUpper(e.Algo), e.Sum, sum)
+				results[name] = sumMismatch
+			default:
+				results[name] = sumOK
+			}
+		}
+	}
+	if len(results) == 0 {
+		return
+	}
+
+	batchesMu.Lock()
+	// A batch reopened meanwhile is verified again when it completes
+	if b.Status != "uploading" {
+		b.Sums = results
+		b.Checksum = checksumOK
+		if failed := checksumFailures(b); len(failed) > 0 {
+			b.Checksum = checksumBad
+			logEvent("批次 %s 校验失败: %d 个文件", b.ID, len(failed))
+			sysLogBatch(sysLogError, b, "文件校验失败: %s", strings.Join(failed, ", "))
+		}
+		recordHistory(b)
+	}
+	batchesMu.Unlock()
+	updateUI()
+}
+
+// checksumFailures lists the files that did not verify, in order.
+// Caller must hold batchesMu.
+func checksumFailures(b *Batch) []string {
+	var failed []string
+	for f, r := range b.Sums {
+		if r != sumOK {
+			failed = append(failed, f)
+		}
+	}
+	sort.Strings(failed)
+	return failed
+}
+
+// checksumDetails is the per-file result list of the detail dialog.
+// Caller must hold batchesMu.
+func checksumDetails(b *Batch) string {
+	files := make([]string, 0, len(b.Sums))
+	for f := range b.Sums {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	var sb strings.Builder
+	for _, f := range files {
+		switch b.Sums[f] {
+		case sumOK:
+			sb.WriteString("\n  ✅ " + f)
+		case sumMismatch:
+			sb.WriteString("\n  ❌ " + f + " (不一致)")
+		case sumMissing:
+			sb.WriteString("\n  ❓ " + f + " (未收到)")
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSumsFile(t *testing.T) {
+	input := "# generated\n" +
+		"d41d8cd98f00b204e9800998ecf8427e  a.mp4\n" +
+		"e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855 *raw/b.jpg\n" +
+		"MD5 (c d.mov) = D41D8CD98F00B204E9800998ECF8427E\n" +
+		"not a checksum line\n"
+	entries, err := parseSumsFile(strings.NewReader(input), "day1/SHA256SUMS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []checksumEntry{
+		{"day1/a.mp4", "md5", "d41d8cd98f00b204e9800998ecf8427e"},
+		{"day1/raw/b.jpg", "sha256", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"day1/c d.mov", "md5", "d41d8cd98f00b204e9800998ecf8427e"},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("entries = %+v", entries)
+	}
+	for i := range want {
+		if entries[i] != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, entries[i], want[i])
+		}
+	}
+
+	entries, _ = parseSumsFile(strings.NewReader("d41d8cd98f00b204e9800998ecf8427e\n"), "IMG_0001.CR2.md5")
+	if len(entries) != 1 || entries[0].Name != "IMG_0001.CR2" {
+		t.Errorf("Bare hash entries = %+v", entries)
+	}
+}
+
+func TestVerifyBatchChecksums(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "a.mp4"), []byte(""), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "b.mp4"), []byte("corrupt"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "files.md5"), []byte(
+		"d41d8cd98f00b204e9800998ecf8427e  a.mp4\n"+
+			"d41d8cd98f00b204e9800998ecf8427e  b.mp4\n"+
+			"d41d8cd98f00b204e9800998ecf8427e  c.mp4\n"), 0644)
+
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{}
+
+	b := &Batch{ID: "test", Folder: tmpDir, Status: "completed", Files: []string{"a.mp4", "b.mp4", "files.md5"}}
+	verifyBatchChecksums(context.Background(), b, func() {})
+	if b.Checksum != checksumBad {
+		t.Errorf("Checksum = %q, want %q", b.Checksum, checksumBad)
+	}
+	if b.Sums["a.mp4"] != sumOK || b.Sums["b.mp4"] != sumMismatch || b.Sums["c.mp4"] != sumMissing {
+		t.Errorf("Sums = %v", b.Sums)
+	}
+	if failed := checksumFailures(b); strings.Join(failed, ",") != "b.mp4,c.mp4" {
+		t.Errorf("checksumFailures = %v", failed)
+	}
+
+	unchecked := &Batch{ID: "plain", Folder: tmpDir, Status: "completed", Files: []string{"a.mp4"}}
+	verifyBatchChecksums(context.Background(), unchecked, func() {})
+	if unchecked.Checksum != checksumUnchecked {
+		t.Errorf("Batch without a sums file got Checksum %q", unchecked.Checksum)
+	}
+}
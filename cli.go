@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// envPrefix prefixes environment variables that override settings, e.g.
+// FIDRUAWATCH_COMPLETION_TIMEOUT=60 for completion_timeout
+const envPrefix = "FIDRUAWATCH_"
+
+// flagAliases are short flag names for common settings
+var flagAliases = map[string]string{
+	"watch":   "watch_path",
+	"timeout": "completion_timeout",
+}
+
+// configOverride sets one setting from outside the config file
+type configOverride struct {
+	Key    string
+	Value  string
+	Source string // flag or variable name, for error messages
+}
+
+// cliOptions is the parsed command line
+type cliOptions struct {
+	ConfigPath string
+	Headless   bool
+	Minimized  bool
+	Folder     string // folder given as the argument, watched right away
+	Overrides  []configOverride
+}
+
+// headless is set while running without a window, see runHeadless
+var headless bool
+
+// Settings as read from the file and as overridden, by key, so saving from
+// the UI doesn't write overrides into the file. Set by applyConfigOverrides.
+var (
+	fileValues     map[string]interface{}
+	overrideValues map[string]interface{}
+)
+
+// settingFlag returns the flag name of a setting: completion_timeout becomes
+// completion-timeout
+func settingFlag(key string) string {
+	return strings.ReplaceAll(key, "_", "-")
+}
+
+// settingEnv returns the environment variable of a setting
+func settingEnv(key string) string {
+	return envPrefix + strings.ToUpper(key)
+}
+
+// configFieldValue returns the settable field of c with the given key
+func configFieldValue(c *Config, key string) (reflect.Value, bool) {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if strings.Split(t.Field(i).Tag.Get("json"), ",")[0] == key {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setConfigValue parses value for the setting key and stores it in c.
+// Lists of numbers are comma-separated.
+func setConfigValue(c *Config, key, value string) error {
+	field, ok := configFieldValue(c, key)
+	if !ok || key == "version" {
+		return fmt.Errorf("未知的设置项: %s", key)
+	}
+	value = strings.TrimSpace(value)
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s 应为 true 或 false: %q", key, value)
+		}
+		field.SetBool(b)
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s 应为整数: %q", key, value)
+		}
+		field.SetInt(int64(n))
+	case reflect.Slice:
+		list := []int{}
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.TrimSpace(part); part == "" {
+				continue
+			}
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("%s 应为逗号分隔的整数: %q", key, value)
+			}
+			list = append(list, n)
+		}
+		field.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("设置项 %s 不支持覆盖", key)
+	}
+	return nil
+}
+
+// overrideFlag is the flag of one setting
+type overrideFlag struct {
+	key    string
+	isBool bool
+	opts   *cliOptions
+}
+
+func (f *overrideFlag) String() string { return "" }
+
+func (f *overrideFlag) Set(value string) error {
+	if err := setConfigValue(&Config{}, f.key, value); err != nil {
+		return err
+	}
+	f.opts.Overrides = append(f.opts.Overrides, configOverride{Key: f.key, Value: value, Source: "--" + settingFlag(f.key)})
+	return nil
+}
+
+// IsBoolFlag lets "--sound-enabled" stand for "--sound-enabled=true"
+func (f *overrideFlag) IsBoolFlag() bool { return f.isBool }
+
+// parseCommandLine parses the command line. Every setting has a flag named
+// after it (--completion-timeout), plus the aliases in flagAliases.
+func parseCommandLine(args []string) (cliOptions, *flag.FlagSet, error) {
+	var opts cliOptions
+	fs := flag.NewFlagSet("fidruawatch", flag.ContinueOnError)
+	fs.StringVar(&opts.ConfigPath, "config", "", "配置文件路径 (.json / .yaml / .toml)")
+	fs.BoolVar(&opts.Headless, "headless", false, "无界面运行: 监控 watch_path, 事件输出到日志, Ctrl+C 退出")
+	fs.BoolVar(&opts.Minimized, "minimized", false, "启动时不显示窗口, 只在系统托盘中运行")
+	for _, f := range configFields(defaultConfig()) {
+		if f.Key == "version" {
+			continue
+		}
+		_, isBool := f.Value.(bool)
+		fs.Var(&overrideFlag{key: f.Key, isBool: isBool, opts: &opts}, settingFlag(f.Key),
+			fmt.Sprintf("设置 %s (环境变量 %s)", f.Key, settingEnv(f.Key)))
+	}
+	def := defaultConfig()
+	for alias, key := range flagAliases {
+		field, _ := configFieldValue(&def, key)
+		isBool := field.Kind() == reflect.Bool
+		fs.Var(&overrideFlag{key: key, isBool: isBool, opts: &opts}, alias, "同 --"+settingFlag(key))
+	}
+	err := fs.Parse(args)
+	return opts, fs, err
+}
+
+// envOverrides returns the overrides set through FIDRUAWATCH_* variables in
+// environ, which is in os.Environ form
+func envOverrides(environ []string) []configOverride {
+	keys := make(map[string]string)
+	for _, f := range configFields(defaultConfig()) {
+		if f.Key != "version" {
+			keys[settingEnv(f.Key)] = f.Key
+		}
+	}
+	var out []configOverride
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if key, known := keys[name]; ok && known {
+			out = append(out, configOverride{Key: key, Value: value, Source: name})
+		}
+	}
+	return out
+}
+
+// applyConfigOverrides applies overrides to the current settings in order,
+// so later ones win, and remembers the file values they replaced
+func applyConfigOverrides(overrides []configOverride) error {
+	fileValues = make(map[string]interface{})
+	overrideValues = make(map[string]interface{})
+	for _, o := range overrides {
+		field, _ := configFieldValue(&config, o.Key)
+		if _, seen := fileValues[o.Key]; !seen && field.IsValid() {
+			fileValues[o.Key] = field.Interface()
+		}
+		if err := setConfigValue(&config, o.Key, o.Value); err != nil {
+			return fmt.Errorf("%s: %v", o.Source, err)
+		}
+		field, _ = configFieldValue(&config, o.Key)
+		overrideValues[o.Key] = field.Interface()
+	}
+	return nil
+}
+
+// withoutOverrides returns c with overridden settings put back to their file
+// values, unless they were changed since (e.g. in the settings page)
+func withoutOverrides(c Config) Config {
+	for key, fileValue := range fileValues {
+		field, ok := configFieldValue(&c, key)
+		if ok && reflect.DeepEqual(field.Interface(), overrideValues[key]) {
+			field.Set(reflect.ValueOf(fileValue))
+		}
+	}
+	return c
+}
+
+// setupFromCommandLine loads the config named by --config and applies
+// environment and flag overrides, flags winning over variables. It exits on
+// invalid input so deployments fail loudly instead of running misconfigured.
+func setupFromCommandLine(args []string) cliOptions {
+	opts, fs, err := parseCommandLine(args)
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2) // the flag package already printed the error and usage
+	}
+	if fs.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "未知的参数: %s\n", strings.Join(fs.Args()[1:], " "))
+		os.Exit(2)
+	}
+	if fs.NArg() == 1 {
+		folder, err := launchFolder(fs.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts.Folder = folder
+		opts.Overrides = append(opts.Overrides, configOverride{Key: "watch_path", Value: folder, Source: fs.Arg(0)})
+	}
+	if opts.ConfigPath != "" {
+		configPath = opts.ConfigPath
+		config = defaultConfig()
+		configLoadErr = loadConfig()
+	}
+	overrides := append(envOverrides(os.Environ()), opts.Overrides...)
+	if err := applyConfigOverrides(overrides); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if len(overrides) > 0 {
+		configProblems = append(configProblems, validateConfig(&config)...)
+	}
+	activeOverrides = overrides
+	configChanged()
+	return opts
+}
+
+// launchFolder resolves the folder given as the argument, as in
+// `fidruawatch /srv/in` from a file manager's context menu. A file stands
+// for its folder; remote paths are taken as they are.
+func launchFolder(arg string) (string, error) {
+	if isRemoteWatchPath(arg) {
+		return arg, nil
+	}
+	// Explorer quotes a drive root as "D:", which splits into D:"
+	if runtime.GOOS == "windows" {
+		arg = strings.TrimSuffix(arg, `"`)
+	}
+	path, err := filepath.Abs(arg)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("无法监控 %s: %v", arg, err)
+	}
+	if !info.IsDir() {
+		path = filepath.Dir(path)
+	}
+	return path, nil
+}
+
+// runHeadless monitors the watch path without a window until interrupted,
+// logging batches as they start and complete. Notifications and sounds only
+// go to the log. It returns the process exit code.
+func runHeadless() int {
+	if configLoadErr != nil {
+		logEvent("配置文件读取失败: %v", configLoadErr)
+		return 1
+	}
+	for _, p := range configProblems {
+		logEvent("配置: %s", p)
+	}
+	if config.WatchPath == "" {
+		logEvent("未设置监控路径, 请使用 --watch 或 %s", settingEnv("watch_path"))
+		return 2
+	}
+	if len(getEnabledExts()) == 0 {
+		logEvent("没有启用任何文件类型")
+		return 2
+	}
+	headless = true
+	monitorPath = config.WatchPath
+	if !isRemoteWatchPath(monitorPath) {
+		monitorPath = normalizeWatchPath(monitorPath)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	sessionID = newSessionID()
+	updateUI := func() {}
+	pruneHistoryOnStartup()
+	restoreInterruptedBatches()
+	go runCheckpoints(ctx)
+
+	run, err := startWatchRun(ctx, updateUI, nil, func(free, total uint64) {}, nil)
+	if err != nil {
+		logEvent("启动监控失败: %v", err)
+		return 1
+	}
+	// Folders the watcher refused are polled once the scan has found them all
+	pollRefused := func(run *watchRun) {
+		<-run.scanned
+		if !run.remote && run.ctx.Err() == nil && failedWatchCount() > 0 {
+			startPolling(run.ctx, updateUI, nil)
+		}
+	}
+	go pollRefused(run)
+	logEvent("开始监控 (无界面): %s", monitorPath)
+	// `fidruawatch ctl` and D-Bus can query and stop the headless run
+	actions := ctlActions{
+		Status: func() ctlStatus {
+			s := currentCtlStatus()
+			s.Monitoring, s.Path = true, monitorPath
+			return s
+		},
+		Stop: func() error {
+			cancel()
+			return nil
+		},
+	}
+	if l, err := listenCtl(); err == nil {
+		go serveCtl(ctx, l, actions)
+	} else {
+		logEvent("控制通道不可用: %v", err)
+	}
+	go runDBus(ctx, actions, updateUI)
+	if config.APIEnabled {
+		apiServer = startAPIServer(updateUI, nil)
+	}
+	if config.GRPCEnabled {
+		grpcServer = startGRPCServer(updateUI)
+	}
+	go checkCompletions(ctx, updateUI, nil)
+	go remindUnsignedBatches(ctx, nil)
+	go runSummaryScheduler(ctx, nil)
+
+	// Edits to the config file apply without a restart, as in the window.
+	// Everything runs on this goroutine so reloads don't race the watchers.
+	reloads := make(chan struct{}, 1)
+	if err := watchConfigFile(ctx, func() {
+		select {
+		case reloads <- struct{}{}:
+		default:
+		}
+	}); err != nil {
+		logEvent("无法监视配置文件: %v", err)
+	}
+	onConfigChange(func(old, cur Config) {
+		if apiSettingsChanged(old, cur) {
+			restartAPIServer(updateUI, nil)
+		}
+		if grpcSettingsChanged(old, cur) {
+			restartGRPCServer(updateUI)
+		}
+		if !watchSettingsChanged(old, cur) {
+			return
+		}
+		run.Stop()
+		monitorPath = cur.WatchPath
+		if !isRemoteWatchPath(monitorPath) {
+			monitorPath = normalizeWatchPath(monitorPath)
+		}
+		if run, err = startWatchRun(ctx, updateUI, nil, func(free, total uint64) {}, nil); err != nil {
+			logEvent("切换监控失败: %v", err)
+			return
+		}
+		go pollRefused(run)
+		logEvent("监控已切换: %s", monitorPath)
+	})
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-reloads:
+			reloadChangedConfig()
+		}
+	}
+	run.Stop()
+	logEvent("停止监控")
+	finishShutdown()
+	return 0
+}
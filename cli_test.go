@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseCommandLine(t *testing.T) {
+	opts, fs, err := parseCommandLine([]string{
+		"--config", "/etc/fidruawatch.yaml", "--headless",
+		"--watch", "/srv/in", "--timeout=90", "--image-enabled", "--schedule-days", "1,3,5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.ConfigPath != "/etc/fidruawatch.yaml" || !opts.Headless || fs.NArg() != 0 {
+		t.Errorf("Unexpected options %+v", opts)
+	}
+	want := []string{"watch_path", "completion_timeout", "image_enabled", "schedule_days"}
+	if len(opts.Overrides) != len(want) {
+		t.Fatalf("Expected %d overrides, got %+v", len(want), opts.Overrides)
+	}
+	for i, key := range want {
+		if opts.Overrides[i].Key != key {
+			t.Errorf("Override %d = %s, want %s", i, opts.Overrides[i].Key, key)
+		}
+	}
+
+	if opts, _, _ := parseCommandLine([]string{"--minimized"}); !opts.Minimized || opts.Headless {
+		t.Errorf("--minimized parsed as %+v", opts)
+	}
+
+	if _, _, err := parseCommandLine([]string{"--timeout", "soon"}); err == nil {
+		t.Error("Expected an error for a non-numeric timeout")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	got := envOverrides([]string{
+		"HOME=/root",
+		"FIDRUAWATCH_COMPLETION_TIMEOUT=45",
+		"FIDRUAWATCH_NOT_A_SETTING=1",
+		"FIDRUAWATCH_VERSION=9",
+		"FIDRUAWATCH_API_TOKEN=a=b",
+	})
+	want := []configOverride{
+		{Key: "completion_timeout", Value: "45", Source: "FIDRUAWATCH_COMPLETION_TIMEOUT"},
+		{Key: "api_token", Value: "a=b", Source: "FIDRUAWATCH_API_TOKEN"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("envOverrides = %+v", got)
+	}
+}
+
+func TestOverridesAreNotSaved(t *testing.T) {
+	tmpDir := t.TempDir()
+	origConfig, origConfigPath := config, configPath
+	origFile, origOverride := fileValues, overrideValues
+	defer func() {
+		config, configPath = origConfig, origConfigPath
+		fileValues, overrideValues = origFile, origOverride
+	}()
+	configPath = filepath.Join(tmpDir, "config.json")
+	config = defaultConfig()
+	config.CompletionTimeout = 40
+	config.SoundEnabled = true
+	saveConfig()
+
+	err := applyConfigOverrides([]configOverride{
+		{Key: "completion_timeout", Value: "60", Source: "FIDRUAWATCH_COMPLETION_TIMEOUT"},
+		{Key: "completion_timeout", Value: "90", Source: "--timeout"},
+		{Key: "sound_enabled", Value: "false", Source: "--sound-enabled"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.CompletionTimeout != 90 || config.SoundEnabled {
+		t.Fatalf("Overrides not applied: timeout %d, sound %v", config.CompletionTimeout, config.SoundEnabled)
+	}
+
+	// A setting changed after the override is saved; untouched overrides are not
+	config.SoundEnabled = true
+	config.ImageEnabled = true
+	saveConfig()
+	config = defaultConfig()
+	loadConfig()
+	if config.CompletionTimeout != 40 || !config.SoundEnabled || !config.ImageEnabled {
+		t.Errorf("Saved timeout %d, sound %v, image %v", config.CompletionTimeout, config.SoundEnabled, config.ImageEnabled)
+	}
+
+	if err := applyConfigOverrides([]configOverride{{Key: "stall_minutes", Value: "x", Source: "--stall-minutes"}}); err == nil {
+		t.Error("Expected an error for an invalid override")
+	}
+}
+
+func TestLaunchFolder(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "clip.mp4")
+	os.WriteFile(file, nil, 0644)
+
+	if got, err := launchFolder(dir); err != nil || got != dir {
+		t.Errorf("launchFolder(dir) = %q, %v", got, err)
+	}
+	if got, err := launchFolder(file); err != nil || got != dir {
+		t.Errorf("A file should stand for its folder, got %q, %v", got, err)
+	}
+	if got, err := launchFolder("sim:/demo"); err != nil || got != "sim:/demo" {
+		t.Errorf("Remote path changed to %q, %v", got, err)
+	}
+	if _, err := launchFolder(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expected an error for a missing folder")
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import "time"
+
+// Go compares times by the monotonic clock, so idle and stall timers are
+// immune to DST shifts and manual clock changes as long as batch times come
+// from time.Now() and keep their monotonic reading: don't Round, Truncate,
+// In or UTC them, and restart timers from now for batches read from disk.
+// What the monotonic clock doesn't settle is suspend. Linux and macOS stop
+// it while the system sleeps, but Windows keeps it running, so a laptop
+// waking up would find every uploading batch idle for the whole night.
+
+// clockSlack is how much later than expected a tick may arrive, or how far
+// the wall clock may drift from the monotonic one, before it counts
+const clockSlack = 10 * time.Second
+
+// clockWatch notices suspends and clock changes between the ticks of a
+// periodic check
+type clockWatch struct {
+	last time.Time
+}
+
+// Observe takes the time of a tick expected interval after the previous
+// one. paused is how long the process didn't run beyond that, e.g. while
+// the system slept; jumped is how far the wall clock moved beyond the
+// monotonic one, from a clock change or a suspend the monotonic clock
+// skipped.
+func (c *clockWatch) Observe(now time.Time, interval time.Duration) (paused, jumped time.Duration) {
+	last := c.last
+	c.last = now
+	if last.IsZero() {
+		return 0, 0
+	}
+	mono := now.Sub(last)
+	wall := now.Round(0).Sub(last.Round(0))
+	if late := mono - interval; late > clockSlack {
+		paused = late
+	}
+	if skew := wall - mono; skew > clockSlack || skew < -clockSlack {
+		jumped = skew
+	}
+	return paused, jumped
+}
+
+// pauseBatchTimers moves the idle and stall timers of uploading batches
+// forward by d, so time the process didn't run for doesn't count as idle.
+// Caller must hold batchesMu.
+func pauseBatchTimers(d time.Duration) {
+	for _, b := range batches {
+		if b.Status == "uploading" {
+			shiftBatchTimers(b, d)
+		}
+	}
+}
+
+// shiftBatchTimers moves the idle and stall timers of b forward by d.
+// Caller must hold batchesMu.
+func shiftBatchTimers(b *Batch, d time.Duration) {
+	b.LastTime = b.LastTime.Add(d)
+	if !b.GrowTime.IsZero() {
+		b.GrowTime = b.GrowTime.Add(d)
+	}
+}
+
+// reconcileClock checks the clock at a tick of checkCompletions and
+// reconciles the batch timers after a suspend. Caller must hold batchesMu.
+func reconcileClock(c *clockWatch, now time.Time, interval time.Duration) {
+	paused, jumped := c.Observe(now, interval)
+	if paused > 0 {
+		logEvent("程序暂停了 %s (系统睡眠?), 上传中批次的完成计时顺延", paused.Round(time.Second))
+		pauseBatchTimers(paused)
+	}
+	if jumped != 0 {
+		logEvent("系统时钟变化了 %s (睡眠恢复或手动调整), 完成判定不受影响", jumped.Round(time.Second))
+	}
+}
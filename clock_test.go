@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClockWatch(t *testing.T) {
+	var c clockWatch
+	start := time.Now()
+	if p, j := c.Observe(start, 3*time.Second); p != 0 || j != 0 {
+		t.Errorf("first tick: paused %v, jumped %v", p, j)
+	}
+	if p, j := c.Observe(start.Add(4*time.Second), 3*time.Second); p != 0 || j != 0 {
+		t.Errorf("slightly late tick: paused %v, jumped %v", p, j)
+	}
+	// A tick an hour late, as after a suspend on Windows
+	if p, j := c.Observe(start.Add(time.Hour+4*time.Second), 3*time.Second); p != time.Hour-3*time.Second || j != 0 {
+		t.Errorf("tick after a suspend: paused %v, jumped %v", p, j)
+	}
+}
+
+func TestPauseBatchTimers(t *testing.T) {
+	origBatches := batches
+	defer func() { batches = origBatches }()
+	now := time.Now()
+	up := &Batch{Status: "uploading", LastTime: now, GrowTime: now}
+	done := &Batch{Status: "completed", LastTime: now}
+	batches = map[string]*Batch{"up": up, "done": done}
+
+	pauseBatchTimers(time.Hour)
+	if !up.LastTime.Equal(now.Add(time.Hour)) || !up.GrowTime.Equal(now.Add(time.Hour)) {
+		t.Errorf("uploading batch timers = %v, %v; want an hour later", up.LastTime, up.GrowTime)
+	}
+	if !done.LastTime.Equal(now) {
+		t.Error("completed batch timer moved")
+	}
+	// Idle time still runs on the monotonic clock
+	if idle := now.Add(90 * time.Minute).Sub(up.LastTime); idle != 30*time.Minute {
+		t.Errorf("idle after the pause = %v, want 30m", idle)
+	}
+}
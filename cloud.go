@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cloudClient is used for cloud API calls. Its timeout leaves room for the
+// Dropbox longpoll.
+var cloudClient = &http.Client{Timeout: 2 * time.Minute}
+
+// API base URLs, variables so tests can point them at a local server
+var (
+	dropboxAPI    = "https://api.dropboxapi.com"
+	dropboxNotify = "https://notify.dropboxapi.com"
+	driveAPI      = "https://www.googleapis.com"
+	graphAPI      = "https://graph.microsoft.com"
+)
+
+// dropboxLongpoll is how long one Dropbox longpoll waits for changes, in seconds
+const dropboxLongpoll = 90
+
+// driveFolderType is the MIME type of Google Drive folders
+const driveFolderType = "application/vnd.google-apps.folder"
+
+// cloudProvider is a cloud storage service usable as a watch source
+type cloudProvider struct {
+	Name     string
+	Label    string
+	Folder   string // what the folder setting means for this provider
+	Endpoint oauthEndpoint
+	newFeed  func(api *cloudAPI, folder string) cloudFeed
+}
+
+var cloudProviders = []cloudProvider{
+	{
+		Name:   "dropbox",
+		Label:  "Dropbox",
+		Folder: "文件夹路径, 如 /Uploads",
+		Endpoint: oauthEndpoint{
+			AuthURL:  "https://www.dropbox.com/oauth2/authorize",
+			TokenURL: "https://api.dropboxapi.com/oauth2/token",
+			Scopes:   []string{"files.metadata.read"},
+			Extra:    url.Values{"token_access_type": {"offline"}},
+		},
+		newFeed: func(api *cloudAPI, folder string) cloudFeed { return &dropboxFeed{api: api, folder: folder} },
+	},
+	{
+		Name:   "gdrive",
+		Label:  "Google Drive",
+		Folder: "文件夹 ID (文件夹网址最后一段)",
+		Endpoint: oauthEndpoint{
+			AuthURL:  "https://accounts.google.com/o/oauth2/v2/auth",
+			TokenURL: "https://oauth2.googleapis.com/token",
+			Scopes:   []string{"https://www.googleapis.com/auth/drive.metadata.readonly"},
+			Extra:    url.Values{"access_type": {"offline"}, "prompt": {"consent"}},
+		},
+		newFeed: func(api *cloudAPI, folder string) cloudFeed { return &driveFeed{api: api, folder: folder} },
+	},
+	{
+		Name:   "onedrive",
+		Label:  "OneDrive",
+		Folder: "文件夹路径, 如 /Uploads",
+		Endpoint: oauthEndpoint{
+			AuthURL:  "https://login.microsoftonline.com/common/oauth2/v2.0/authorize",
+			TokenURL: "https://login.microsoftonline.com/common/oauth2/v2.0/token",
+			Scopes:   []string{"Files.Read.All", "offline_access"},
+		},
+		newFeed: func(api *cloudAPI, folder string) cloudFeed { return &graphFeed{api: api, folder: folder} },
+	},
+}
+
+// findCloudProvider returns the provider with the given name
+func findCloudProvider(name string) (cloudProvider, bool) {
+	for _, p := range cloudProviders {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return cloudProvider{}, false
+}
+
+// cloudWatchPath names a cloud folder as a watch path, e.g. "cloud:dropbox/Uploads"
+func cloudWatchPath(provider, folder string) string {
+	return cloudScheme + path.Join(provider, strings.Trim(folder, "/"))
+}
+
+// cloudChange is a file that appeared or changed in a cloud folder
+type cloudChange struct {
+	Path string // slash-separated, relative to the watched folder
+	Size int64
+}
+
+// cloudFeed follows a provider's change API for one folder
+type cloudFeed interface {
+	// Start returns a cursor for the current state, so only later changes are reported
+	Start(ctx context.Context) (string, error)
+	// Changes returns the changes after cursor and the cursor to continue
+	// from. It may block for a while waiting for changes.
+	Changes(ctx context.Context, cursor string) ([]cloudChange, string, error)
+}
+
+// cloudAPI makes JSON requests authorized by an OAuth session
+type cloudAPI struct {
+	auth *oauthSession
+}
+
+// call sends body as JSON (or nothing when nil) and decodes the response
+// into out. Without auth the request is sent without a bearer token.
+func (c *cloudAPI) call(ctx context.Context, method, rawURL string, body, out interface{}, auth bool) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if auth {
+		token, err := c.auth.AccessToken(ctx)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := cloudClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s %s: %s %s", method, req.URL.Path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// dropboxFeed follows a folder with list_folder cursors and longpoll
+type dropboxFeed struct {
+	api    *cloudAPI
+	folder string
+}
+
+// cloudFolderPath returns a folder path in API form: "" for the root, else "/a/b"
+func cloudFolderPath(folder string) string {
+	p := path.Clean("/" + folder)
+	if p == "/" {
+		return ""
+	}
+	return p
+}
+
+func (f *dropboxFeed) Start(ctx context.Context) (string, error) {
+	var out struct {
+		Cursor string `json:"cursor"`
+	}
+	body := map[string]interface{}{"path": cloudFolderPath(f.folder), "recursive": config.MonitorSubdirs}
+	err := f.api.call(ctx, http.MethodPost, dropboxAPI+"/2/files/list_folder/get_latest_cursor", body, &out, true)
+	return out.Cursor, err
+}
+
+func (f *dropboxFeed) Changes(ctx context.Context, cursor string) ([]cloudChange, string, error) {
+	var poll struct {
+		Changes bool `json:"changes"`
+		Backoff int  `json:"backoff"`
+	}
+	body := map[string]interface{}{"cursor": cursor, "timeout": dropboxLongpoll}
+	if err := f.api.call(ctx, http.MethodPost, dropboxNotify+"/2/files/list_folder/longpoll", body, &poll, false); err != nil {
+		return nil, cursor, err
+	}
+	if poll.Backoff > 0 {
+		select {
+		case <-ctx.Done():
+			return nil, cursor, ctx.Err()
+		case <-time.After(time.Duration(poll.Backoff) * time.Second):
+		}
+	}
+	if !poll.Changes {
+		return nil, cursor, nil
+	}
+
+	prefix := strings.ToLower(cloudFolderPath(f.folder)) + "/"
+	var changes []cloudChange
+	for {
+		var page struct {
+			Entries []struct {
+				Tag         string `json:".tag"`
+				PathLower   string `json:"path_lower"`
+				PathDisplay string `json:"path_display"`
+				Size        int64  `json:"size"`
+			} `json:"entries"`
+			Cursor  string `json:"cursor"`
+			HasMore bool   `json:"has_more"`
+		}
+		err := f.api.call(ctx, http.MethodPost, dropboxAPI+"/2/files/list_folder/continue", map[string]string{"cursor": cursor}, &page, true)
+		if err != nil {
+			return changes, cursor, err
+		}
+		for _, e := range page.Entries {
+			if e.Tag != "file" || !strings.HasPrefix(e.PathLower, prefix) || len(e.PathDisplay) != len(e.PathLower) {
+				continue
+			}
+			changes = append(changes, cloudChange{Path: e.PathDisplay[len(prefix):], Size: e.Size})
+		}
+		cursor = page.Cursor
+		if !page.HasMore {
+			return changes, cursor, nil
+		}
+	}
+}
+
+// driveFeed follows the Drive changes list, keeping the files whose parent
+// is the watched folder or a subfolder created in it while watching
+type driveFeed struct {
+	api    *cloudAPI
+	folder string
+	dirs   map[string]string // folder ID -> path relative to the watched folder
+}
+
+func (f *driveFeed) Start(ctx context.Context) (string, error) {
+	f.dirs = map[string]string{f.folder: ""}
+	var out struct {
+		Token string `json:"startPageToken"`
+	}
+	err := f.api.call(ctx, http.MethodGet, driveAPI+"/drive/v3/changes/startPageToken?supportsAllDrives=true", nil, &out, true)
+	return out.Token, err
+}
+
+func (f *driveFeed) Changes(ctx context.Context, cursor string) ([]cloudChange, string, error) {
+	var changes []cloudChange
+	token := cursor
+	for {
+		q := url.Values{
+			"pageToken":                 {token},
+			"supportsAllDrives":         {"true"},
+			"includeItemsFromAllDrives": {"true"},
+			"fields":                    {"nextPageToken,newStartPageToken,changes(removed,file(id,name,size,mimeType,parents,trashed))"},
+		}
+		var page struct {
+			Changes []struct {
+				Removed bool `json:"removed"`
+				File    *struct {
+					ID       string   `json:"id"`
+					Name     string   `json:"name"`
+					Size     string   `json:"size"`
+					MimeType string   `json:"mimeType"`
+					Parents  []string `json:"parents"`
+					Trashed  bool     `json:"trashed"`
+				} `json:"file"`
+			} `json:"changes"`
+			NextPageToken     string `json:"nextPageToken"`
+			NewStartPageToken string `json:"newStartPageToken"`
+		}
+		if err := f.api.call(ctx, http.MethodGet, driveAPI+"/drive/v3/changes?"+q.Encode(), nil, &page, true); err != nil {
+			return changes, cursor, err
+		}
+		for _, c := range page.Changes {
+			if c.Removed || c.File == nil || c.File.Trashed {
+				continue
+			}
+			for _, parent := range c.File.Parents {
+				dir, ok := f.dirs[parent]
+				if !ok {
+					continue
+				}
+				rel := path.Join(dir, c.File.Name)
+				if c.File.MimeType == driveFolderType {
+					if config.MonitorSubdirs {
+						f.dirs[c.File.ID] = rel
+					}
+				} else {
+					size, _ := strconv.ParseInt(c.File.Size, 10, 64)
+					changes = append(changes, cloudChange{Path: rel, Size: size})
+				}
+				break
+			}
+		}
+		if page.NewStartPageToken != "" {
+			return changes, page.NewStartPageToken, nil
+		}
+		if page.NextPageToken == "" {
+			return changes, cursor, errors.New("Drive changes: 响应缺少分页令牌")
+		}
+		token = page.NextPageToken
+	}
+}
+
+// graphFeed follows a OneDrive folder with Microsoft Graph delta queries;
+// the cursor is the delta link
+type graphFeed struct {
+	api    *cloudAPI
+	folder string
+}
+
+// graphItem is the part of a driveItem the feed reads
+type graphItem struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	File      *struct{} `json:"file"`
+	Deleted   *struct{} `json:"deleted"`
+	ParentRef struct {
+		Path string `json:"path"`
+	} `json:"parentReference"`
+}
+
+// graphPage is one page of a delta response
+type graphPage struct {
+	Value     []graphItem `json:"value"`
+	NextLink  string      `json:"@odata.nextLink"`
+	DeltaLink string      `json:"@odata.deltaLink"`
+}
+
+func (f *graphFeed) Start(ctx context.Context) (string, error) {
+	u := graphAPI + "/v1.0/me/drive/root/delta?token=latest"
+	if folder := cloudFolderPath(f.folder); folder != "" {
+		u = graphAPI + "/v1.0/me/drive/root:" + (&url.URL{Path: folder}).EscapedPath() + ":/delta?token=latest"
+	}
+	for {
+		var page graphPage
+		if err := f.api.call(ctx, http.MethodGet, u, nil, &page, true); err != nil {
+			return "", err
+		}
+		if page.DeltaLink != "" {
+			return page.DeltaLink, nil
+		}
+		if page.NextLink == "" {
+			return "", errors.New("Graph delta: 响应缺少 deltaLink")
+		}
+		u = page.NextLink
+	}
+}
+
+func (f *graphFeed) Changes(ctx context.Context, cursor string) ([]cloudChange, string, error) {
+	root := "/drive/root:" + cloudFolderPath(f.folder)
+	var changes []cloudChange
+	u := cursor
+	for {
+		var page graphPage
+		if err := f.api.call(ctx, http.MethodGet, u, nil, &page, true); err != nil {
+			return changes, cursor, err
+		}
+		for _, item := range page.Value {
+			if item.File == nil || item.Deleted != nil {
+				continue
+			}
+			parent := item.ParentRef.Path
+			if p, err := url.PathUnescape(parent); err == nil {
+				parent = p
+			}
+			if parent != root && !strings.HasPrefix(parent, root+"/") {
+				continue
+			}
+			dir := strings.TrimPrefix(strings.TrimPrefix(parent, root), "/")
+			if dir != "" && !config.MonitorSubdirs {
+				continue
+			}
+			changes = append(changes, cloudChange{Path: path.Join(dir, item.Name), Size: item.Size})
+		}
+		if page.DeltaLink != "" {
+			return changes, page.DeltaLink, nil
+		}
+		if page.NextLink == "" {
+			return changes, cursor, errors.New("Graph delta: 响应缺少 deltaLink")
+		}
+		u = page.NextLink
+	}
+}
+
+// runCloudFeed follows feed until ctx is cancelled. After changes it asks
+// again right away, since an upload in progress tends to bring more; when
+// nothing changed it waits out the rest of interval. Failures are logged
+// when they start and when they clear.
+func runCloudFeed(ctx context.Context, feed cloudFeed, root string, interval time.Duration, ingest func(remoteChange)) {
+	failing := false
+	report := func(err error) {
+		switch {
+		case err != nil && ctx.Err() == nil && !failing:
+			logEvent("云盘变更获取失败 %s: %v", root, err)
+			failing = true
+		case err == nil && failing:
+			logEvent("云盘变更获取恢复: %s", root)
+			failing = false
+		}
+	}
+	wait := func(d time.Duration) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(d):
+			return true
+		}
+	}
+
+	var cursor string
+	for {
+		var err error
+		cursor, err = feed.Start(ctx)
+		report(err)
+		if err == nil {
+			break
+		}
+		if !wait(interval) {
+			return
+		}
+	}
+	for {
+		start := time.Now()
+		changes, next, err := feed.Changes(ctx, cursor)
+		report(err)
+		cursor = next
+		for _, c := range changes {
+			ingest(remoteChange{Path: filepath.Join(root, filepath.FromSlash(c.Path)), Size: c.Size})
+		}
+		if len(changes) > 0 && err == nil {
+			if ctx.Err() != nil {
+				return
+			}
+			continue
+		}
+		if !wait(ecoInterval(interval) - time.Since(start)) {
+			return
+		}
+	}
+}
+
+// startCloudWatch follows the configured cloud folder
+func startCloudWatch(ctx context.Context, ingest func(remoteChange)) error {
+	p, ok := findCloudProvider(config.CloudProvider)
+	if !ok {
+		return fmt.Errorf("未知的云盘: %s", config.CloudProvider)
+	}
+	auth := &oauthSession{
+		endpoint:     p.Endpoint,
+		clientID:     config.CloudClientID,
+		clientSecret: config.CloudSecret,
+		token:        oauthToken{RefreshToken: config.CloudRefresh},
+		onRefresh: func(refreshToken string) {
+			config.CloudRefresh = refreshToken
+			saveConfig()
+		},
+	}
+	feed := p.newFeed(&cloudAPI{auth: auth}, config.CloudFolder)
+	go runCloudFeed(ctx, feed, monitorPath, remoteInterval(), ingest)
+	return nil
+}
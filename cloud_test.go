@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestPKCEChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	if c := pkceChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"); c != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("pkceChallenge = %s", c)
+	}
+}
+
+func TestCloudWatchPath(t *testing.T) {
+	if p := cloudWatchPath("dropbox", "/Uploads/"); p != "cloud:dropbox/Uploads" || !isRemoteWatchPath(p) {
+		t.Errorf("cloudWatchPath = %q", p)
+	}
+	if p := cloudWatchPath("onedrive", ""); p != "cloud:onedrive" {
+		t.Errorf("cloudWatchPath root = %q", p)
+	}
+}
+
+func TestOAuthAuthorize(t *testing.T) {
+	origAddr := oauthRedirectAddr
+	defer func() { oauthRedirectAddr = origAddr }()
+	oauthRedirectAddr = "127.0.0.1:0"
+
+	var challenge string
+	tokenSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("grant_type") {
+		case "authorization_code":
+			if r.Form.Get("code") != "the-code" || pkceChallenge(r.Form.Get("code_verifier")) != challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant"}`)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"a1","refresh_token":"r1","expires_in":3600}`)
+		case "refresh_token":
+			fmt.Fprint(w, `{"access_token":"a2","expires_in":3600}`)
+		}
+	}))
+	defer tokenSrv.Close()
+
+	ep := oauthEndpoint{AuthURL: "https://auth.example/authorize", TokenURL: tokenSrv.URL, Scopes: []string{"read"}}
+	openURL := func(u *url.URL) error {
+		q := u.Query()
+		challenge = q.Get("code_challenge")
+		if q.Get("client_id") != "cid" || q.Get("code_challenge_method") != "S256" || q.Get("scope") != "read" {
+			return fmt.Errorf("unexpected authorization URL %s", u)
+		}
+		// Play the browser coming back to the redirect
+		cb := q.Get("redirect_uri") + "?code=the-code&state=" + url.QueryEscape(q.Get("state"))
+		resp, err := http.Get(cb)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	tok, err := oauthAuthorize(context.Background(), ep, "cid", "", openURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "a1" || tok.RefreshToken != "r1" || tok.Expiry.IsZero() {
+		t.Errorf("Unexpected token %+v", tok)
+	}
+
+	// An expired session refreshes and keeps the refresh token it was given
+	s := &oauthSession{endpoint: ep, clientID: "cid", token: oauthToken{RefreshToken: "r1"}}
+	if access, err := s.AccessToken(context.Background()); err != nil || access != "a2" || s.token.RefreshToken != "r1" {
+		t.Errorf("AccessToken = %q, %v, refresh %q", access, err, s.token.RefreshToken)
+	}
+}
+
+// cloudTestAPI returns an API whose session already holds a valid token
+func cloudTestAPI() *cloudAPI {
+	return &cloudAPI{auth: &oauthSession{token: oauthToken{AccessToken: "tok"}}}
+}
+
+func TestDropboxFeed(t *testing.T) {
+	origAPI, origNotify := dropboxAPI, dropboxNotify
+	defer func() { dropboxAPI, dropboxNotify = origAPI, origNotify }()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/2/files/list_folder/get_latest_cursor":
+			if body["path"] != "/Uploads" {
+				http.Error(w, "bad path", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"cursor":"c0"}`)
+		case "/2/files/list_folder/longpoll":
+			if r.Header.Get("Authorization") != "" {
+				http.Error(w, "longpoll takes no auth", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"changes":true}`)
+		case "/2/files/list_folder/continue":
+			if r.Header.Get("Authorization") != "Bearer tok" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			if body["cursor"] == "c0" {
+				fmt.Fprint(w, `{"entries":[{".tag":"file","path_lower":"/uploads/day1/a.mp4","path_display":"/Uploads/Day1/A.mp4","size":42},{".tag":"folder","path_lower":"/uploads/day1","path_display":"/Uploads/Day1"}],"cursor":"c1","has_more":true}`)
+			} else {
+				fmt.Fprint(w, `{"entries":[{".tag":"deleted","path_lower":"/uploads/old.mp4","path_display":"/Uploads/old.mp4"}],"cursor":"c2","has_more":false}`)
+			}
+		}
+	}))
+	defer srv.Close()
+	dropboxAPI, dropboxNotify = srv.URL, srv.URL
+
+	f := &dropboxFeed{api: cloudTestAPI(), folder: "Uploads"}
+	cursor, err := f.Start(context.Background())
+	if err != nil || cursor != "c0" {
+		t.Fatalf("Start = %q, %v", cursor, err)
+	}
+	changes, cursor, err := f.Changes(context.Background(), cursor)
+	if err != nil || cursor != "c2" {
+		t.Fatalf("Changes cursor = %q, %v", cursor, err)
+	}
+	if len(changes) != 1 || changes[0].Path != "Day1/A.mp4" || changes[0].Size != 42 {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+}
+
+func TestDriveFeed(t *testing.T) {
+	origConfig, origAPI := config, driveAPI
+	defer func() { config, driveAPI = origConfig, origAPI }()
+	config = Config{MonitorSubdirs: true}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/drive/v3/changes/startPageToken":
+			fmt.Fprint(w, `{"startPageToken":"p1"}`)
+		case "/drive/v3/changes":
+			if r.URL.Query().Get("pageToken") == "p1" {
+				fmt.Fprint(w, `{"nextPageToken":"p2","changes":[
+					{"file":{"id":"sub","name":"CamA","mimeType":"application/vnd.google-apps.folder","parents":["root-id"]}},
+					{"file":{"id":"f1","name":"x.mp4","size":"10","parents":["elsewhere"]}}]}`)
+			} else {
+				fmt.Fprint(w, `{"newStartPageToken":"p3","changes":[
+					{"file":{"id":"f2","name":"clip.mp4","size":"99","parents":["sub"]}},
+					{"file":{"id":"f3","name":"gone.mp4","size":"5","parents":["root-id"],"trashed":true}},
+					{"removed":true}]}`)
+			}
+		}
+	}))
+	defer srv.Close()
+	driveAPI = srv.URL
+
+	f := &driveFeed{api: cloudTestAPI(), folder: "root-id"}
+	cursor, err := f.Start(context.Background())
+	if err != nil || cursor != "p1" {
+		t.Fatalf("Start = %q, %v", cursor, err)
+	}
+	changes, cursor, err := f.Changes(context.Background(), cursor)
+	if err != nil || cursor != "p3" {
+		t.Fatalf("Changes cursor = %q, %v", cursor, err)
+	}
+	if len(changes) != 1 || changes[0].Path != "CamA/clip.mp4" || changes[0].Size != 99 {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+}
+
+func TestGraphFeed(t *testing.T) {
+	origConfig, origAPI := config, graphAPI
+	defer func() { config, graphAPI = origConfig, origAPI }()
+	config = Config{MonitorSubdirs: false}
+
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v1.0/me/drive/root:/My Uploads:/delta":
+			fmt.Fprintf(w, `{"value":[],"@odata.deltaLink":"%s/delta/1"}`, srv.URL)
+		case r.URL.Path == "/delta/1":
+			fmt.Fprintf(w, `{"value":[
+				{"name":"a.mp4","size":7,"file":{},"parentReference":{"path":"/drive/root:/My%%20Uploads"}},
+				{"name":"b.mp4","size":8,"file":{},"parentReference":{"path":"/drive/root:/My Uploads/sub"}},
+				{"name":"c.mp4","size":9,"file":{},"deleted":{},"parentReference":{"path":"/drive/root:/My Uploads"}},
+				{"name":"d.mp4","size":1,"file":{},"parentReference":{"path":"/drive/root:/Other"}}],
+				"@odata.deltaLink":"%s/delta/2"}`, srv.URL)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	graphAPI = srv.URL
+
+	f := &graphFeed{api: cloudTestAPI(), folder: "/My Uploads"}
+	cursor, err := f.Start(context.Background())
+	if err != nil || cursor != srv.URL+"/delta/1" {
+		t.Fatalf("Start = %q, %v", cursor, err)
+	}
+	changes, cursor, err := f.Changes(context.Background(), cursor)
+	if err != nil || cursor != srv.URL+"/delta/2" {
+		t.Fatalf("Changes cursor = %q, %v", cursor, err)
+	}
+	if len(changes) != 1 || changes[0].Path != "a.mp4" || changes[0].Size != 7 {
+		t.Errorf("Unexpected changes %+v", changes)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// oauthRedirectAddr is where the browser is sent back after authorizing.
+// It is fixed because some providers only accept registered redirect URIs,
+// so register http://127.0.0.1:53682/ with the OAuth app.
+var oauthRedirectAddr = "127.0.0.1:53682"
+
+// oauthTimeout is how long the user has to finish authorizing in the browser
+const oauthTimeout = 5 * time.Minute
+
+// oauthEndpoint describes a provider's authorization code flow
+type oauthEndpoint struct {
+	AuthURL  string
+	TokenURL string
+	Scopes   []string
+	Extra    url.Values // additional authorization parameters, e.g. to get a refresh token
+}
+
+// oauthToken is the result of a token request
+type oauthToken struct {
+	AccessToken  string
+	RefreshToken string
+	Expiry       time.Time
+}
+
+// pkceVerifier returns a random PKCE code verifier
+func pkceVerifier() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// pkceChallenge returns the S256 code challenge for verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// oauthAuthorize runs the authorization code flow with PKCE: it opens the
+// consent page through openURL, waits for the browser to come back to the
+// loopback redirect and exchanges the code for tokens. The client secret is
+// only sent when set; desktop clients of some providers still need one.
+func oauthAuthorize(ctx context.Context, ep oauthEndpoint, clientID, clientSecret string, openURL func(*url.URL) error) (oauthToken, error) {
+	verifier, err := pkceVerifier()
+	if err != nil {
+		return oauthToken{}, err
+	}
+	state, err := pkceVerifier()
+	if err != nil {
+		return oauthToken{}, err
+	}
+	ln, err := net.Listen("tcp", oauthRedirectAddr)
+	if err != nil {
+		return oauthToken{}, fmt.Errorf("无法监听授权回调地址 %s: %w", oauthRedirectAddr, err)
+	}
+	redirectURI := "http://" + ln.Addr().String() + "/"
+
+	type result struct {
+		code string
+		err  error
+	}
+	done := make(chan result, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		var res result
+		switch {
+		case q.Get("state") != state:
+			res.err = errors.New("授权回调的 state 不匹配")
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("授权被拒绝: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("code") == "":
+			http.NotFound(w, r)
+			return
+		default:
+			res.code = q.Get("code")
+		}
+		if res.err != nil {
+			fmt.Fprintln(w, "FidruaWatch: 授权失败, 可以关闭此页面。")
+		} else {
+			fmt.Fprintln(w, "FidruaWatch: 授权成功, 可以关闭此页面。")
+		}
+		select {
+		case done <- res:
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {clientID},
+		"redirect_uri":          {redirectURI},
+		"state":                 {state},
+		"code_challenge":        {pkceChallenge(verifier)},
+		"code_challenge_method": {"S256"},
+	}
+	if len(ep.Scopes) > 0 {
+		q.Set("scope", strings.Join(ep.Scopes, " "))
+	}
+	for k, v := range ep.Extra {
+		q[k] = v
+	}
+	authURL, err := url.Parse(ep.AuthURL)
+	if err != nil {
+		return oauthToken{}, err
+	}
+	authURL.RawQuery = q.Encode()
+	if err := openURL(authURL); err != nil {
+		return oauthToken{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, oauthTimeout)
+	defer cancel()
+	var res result
+	select {
+	case <-ctx.Done():
+		return oauthToken{}, errors.New("等待浏览器授权超时")
+	case res = <-done:
+	}
+	if res.err != nil {
+		return oauthToken{}, res.err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {res.code},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {clientID},
+		"code_verifier": {verifier},
+	}
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+	return oauthTokenRequest(ctx, ep.TokenURL, form)
+}
+
+// oauthRefresh gets a new access token with a refresh token. Providers that
+// rotate refresh tokens return a new one; otherwise the old one is kept.
+func oauthRefresh(ctx context.Context, ep oauthEndpoint, clientID, clientSecret, refreshToken string) (oauthToken, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+		"client_id":     {clientID},
+	}
+	if clientSecret != "" {
+		form.Set("client_secret", clientSecret)
+	}
+	tok, err := oauthTokenRequest(ctx, ep.TokenURL, form)
+	if err == nil && tok.RefreshToken == "" {
+		tok.RefreshToken = refreshToken
+	}
+	return tok, err
+}
+
+// oauthTokenRequest posts form to a token endpoint and parses the response
+func oauthTokenRequest(ctx context.Context, tokenURL string, form url.Values) (oauthToken, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return oauthToken{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := cloudClient.Do(req)
+	if err != nil {
+		return oauthToken{}, err
+	}
+	defer resp.Body.Close()
+	var body struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+		Error        string `json:"error"`
+		Description  string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return oauthToken{}, fmt.Errorf("令牌响应无效 (%s): %w", resp.Status, err)
+	}
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return oauthToken{}, fmt.Errorf("获取令牌失败 (%s): %s %s", resp.Status, body.Error, body.Description)
+	}
+	tok := oauthToken{AccessToken: body.AccessToken, RefreshToken: body.RefreshToken}
+	if body.ExpiresIn > 0 {
+		tok.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return tok, nil
+}
+
+// oauthSession hands out access tokens, refreshing them shortly before they
+// expire. onRefresh is called with the refresh token whenever it changes so
+// it can be persisted.
+type oauthSession struct {
+	mu           sync.Mutex
+	endpoint     oauthEndpoint
+	clientID     string
+	clientSecret string
+	token        oauthToken
+	onRefresh    func(refreshToken string)
+}
+
+// AccessToken returns a valid access token
+func (s *oauthSession) AccessToken(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token.AccessToken != "" && (s.token.Expiry.IsZero() || time.Until(s.token.Expiry) > time.Minute) {
+		return s.token.AccessToken, nil
+	}
+	if s.token.RefreshToken == "" {
+		return "", errors.New("云盘未授权, 请重新登录")
+	}
+	old := s.token.RefreshToken
+	tok, err := oauthRefresh(ctx, s.endpoint, s.clientID, s.clientSecret, old)
+	if err != nil {
+		return "", err
+	}
+	s.token = tok
+	if tok.RefreshToken != old && s.onRefresh != nil {
+		s.onRefresh(tok.RefreshToken)
+	}
+	return tok.AccessToken, nil
+}
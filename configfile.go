@@ -0,0 +1,413 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// configVersion is the schema version of the config file. Files without a
+// version are from before the schema was versioned and count as version 1.
+const configVersion = 2
+
+// Config file formats, chosen by file extension
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+)
+
+var configFormats = []struct {
+	Format string
+	Label  string
+	File   string
+}{
+	{formatJSON, "JSON", "config.json"},
+	{formatYAML, "YAML", "config.yaml"},
+	{formatTOML, "TOML", "config.toml"},
+}
+
+// findConfigPath returns the config file in dir, preferring YAML and TOML
+// over JSON when several exist, and config.json when there is none yet
+func findConfigPath(dir string) string {
+	for _, name := range []string{"config.yaml", "config.yml", "config.toml", "config.json"} {
+		p := filepath.Join(dir, name)
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+	}
+	return filepath.Join(dir, "config.json")
+}
+
+// configFormat returns the format of a config file from its extension
+func configFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	}
+	return formatJSON
+}
+
+// configField is one setting keyed by its name in the config file
+type configField struct {
+	Key   string
+	Value interface{}
+}
+
+// configFields returns the settings in declaration order, keyed by their
+// json names, which are the setting names in every format
+func configFields(c Config) []configField {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	fields := make([]configField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		key := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if key == "" || key == "-" {
+			continue
+		}
+		fields = append(fields, configField{Key: key, Value: v.Field(i).Interface()})
+	}
+	return fields
+}
+
+// encodeConfig serializes c in format, keeping the declaration order
+func encodeConfig(c Config, format string) ([]byte, error) {
+	switch format {
+	case formatYAML:
+		doc := &yaml.Node{Kind: yaml.MappingNode}
+		for _, f := range configFields(c) {
+			if isNilSlice(f.Value) {
+				continue
+			}
+			var value yaml.Node
+			if err := value.Encode(f.Value); err != nil {
+				return nil, err
+			}
+			doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: f.Key}, &value)
+		}
+		return yaml.Marshal(doc)
+	case formatTOML:
+		// One key at a time, since maps would be written sorted. Keys after
+		// a [table] belong to it, so tables go last.
+		var buf bytes.Buffer
+		var tables []configField
+		for _, f := range configFields(c) {
+			if isNilSlice(f.Value) {
+				continue
+			}
+			if isTOMLTable(f.Value) {
+				tables = append(tables, f)
+				continue
+			}
+			if err := toml.NewEncoder(&buf).Encode(map[string]interface{}{f.Key: f.Value}); err != nil {
+				return nil, err
+			}
+		}
+		for _, f := range tables {
+			if err := toml.NewEncoder(&buf).Encode(map[string]interface{}{f.Key: f.Value}); err != nil {
+				return nil, err
+			}
+		}
+		return buf.Bytes(), nil
+	}
+	return json.MarshalIndent(c, "", "  ")
+}
+
+// isNilSlice reports whether v is a nil slice, left out of YAML and TOML
+// files so that it decodes back to nil
+func isNilSlice(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	return rv.Kind() == reflect.Slice && rv.IsNil()
+}
+
+// isTOMLTable reports whether v is written as a TOML table or array of tables
+func isTOMLTable(v interface{}) bool {
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct || t.Kind() == reflect.Map
+}
+
+// decodeConfig parses data in format over base, so settings missing from
+// the file keep their value in base. Syntax and type errors are described
+// with the line or setting they concern.
+func decodeConfig(data []byte, format string, base Config) (Config, error) {
+	switch format {
+	case formatYAML, formatTOML:
+		var m map[string]interface{}
+		if format == formatYAML {
+			if err := yaml.Unmarshal(data, &m); err != nil {
+				return base, fmt.Errorf("YAML 格式错误: %v", err)
+			}
+		} else if _, err := toml.Decode(string(data), &m); err != nil {
+			return base, fmt.Errorf("TOML 格式错误: %v", err)
+		}
+		var err error
+		if data, err = json.Marshal(m); err != nil {
+			return base, fmt.Errorf("无法转换配置: %v", err)
+		}
+	}
+	c := base
+	if err := json.Unmarshal(data, &c); err != nil {
+		return base, describeJSONError(data, err, format)
+	}
+	return c, nil
+}
+
+// describeJSONError turns json decoding errors into messages naming the
+// line or setting at fault
+func describeJSONError(data []byte, err error, format string) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr):
+		return fmt.Errorf("设置项 %s 的类型错误: 应为 %s, 实际为 %s", typeErr.Field, typeErr.Type, typeErr.Value)
+	case errors.As(err, &syntaxErr) && format == formatJSON:
+		line := 1 + bytes.Count(data[:min(int(syntaxErr.Offset), len(data))], []byte("\n"))
+		return fmt.Errorf("JSON 格式错误 (第 %d 行): %v", line, err)
+	}
+	return err
+}
+
+// configMigrations upgrade a config by one version: entry i takes a
+// version i+1 config to version i+2
+var configMigrations = []func(c *Config){
+	// 1 -> 2: completion timeouts under 10s were silently replaced by 30s at
+	// runtime; write the value actually used
+	func(c *Config) {
+		if c.CompletionTimeout < 10 {
+			c.CompletionTimeout = 30
+		}
+	},
+}
+
+// migrateConfig brings c up to configVersion and returns the version it was
+// at. Files newer than this build are left alone.
+func migrateConfig(c *Config) int {
+	from := c.Version
+	if from < 1 {
+		from = 1
+	}
+	for v := from; v < configVersion && v-1 < len(configMigrations); v++ {
+		configMigrations[v-1](c)
+	}
+	if from < configVersion {
+		c.Version = configVersion
+	}
+	return from
+}
+
+// validateConfig resets settings with invalid values to their defaults and
+// returns a description of each one
+func validateConfig(c *Config) []string {
+	def := defaultConfig()
+	var problems []string
+	reset := func(key string, value interface{}, field, fallback interface{}) {
+		reflect.ValueOf(field).Elem().Set(reflect.ValueOf(fallback))
+		problems = append(problems, fmt.Sprintf("%s = %v 无效, 已改用默认值 %v", key, value, fallback))
+	}
+	oneOf := func(v string, options ...string) bool {
+		for _, o := range options {
+			if v == o {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, f := range []struct {
+		key   string
+		field *int
+		def   int
+	}{
+		{"completion_timeout", &c.CompletionTimeout, def.CompletionTimeout},
+		{"adaptive_min", &c.AdaptiveMin, def.AdaptiveMin},
+		{"adaptive_max", &c.AdaptiveMax, def.AdaptiveMax},
+		{"remind_interval", &c.RemindInterval, def.RemindInterval},
+		{"notify_per_minute", &c.NotifyPerMinute, def.NotifyPerMinute},
+		{"mute_minutes", &c.MuteMinutes, def.MuteMinutes},
+		{"group_depth", &c.GroupDepth, def.GroupDepth},
+		{"rescan_interval", &c.RescanInterval, def.RescanInterval},
+		{"sample_buffer_size", &c.SampleBufferSize, def.SampleBufferSize},
+		{"sample_resolution", &c.SampleResolution, def.SampleResolution},
+		{"group_time_window", &c.GroupTimeWindow, def.GroupTimeWindow},
+		{"low_disk_warn_gb", &c.LowDiskWarnGB, def.LowDiskWarnGB},
+		{"stall_minutes", &c.StallMinutes, def.StallMinutes},
+		{"reopen_minutes", &c.ReopenMinutes, def.ReopenMinutes},
+		{"thumb_cache_mb", &c.ThumbCacheMB, def.ThumbCacheMB},
+		{"remote_interval", &c.RemoteInterval, def.RemoteInterval},
+		{"max_batches", &c.MaxBatches, def.MaxBatches},
+		{"keep_batch_hours", &c.KeepBatchHours, def.KeepBatchHours},
+		{"history_keep_days", &c.HistoryKeepDays, def.HistoryKeepDays},
+		{"history_max_batches", &c.HistoryMaxBatches, def.HistoryMaxBatches},
+		{"history_max_mb", &c.HistoryMaxMB, def.HistoryMaxMB},
+		{"event_log_max_mb", &c.EventLogMaxMB, def.EventLogMaxMB},
+		{"event_log_keep", &c.EventLogKeep, def.EventLogKeep},
+		{"transfer_limit_kb", &c.TransferLimitKB, def.TransferLimitKB},
+		{"transfer_jobs", &c.TransferJobs, def.TransferJobs},
+		{"sim_rate", &c.SimRate, def.SimRate},
+		{"sim_min_mb", &c.SimMinMB, def.SimMinMB},
+		{"sim_max_mb", &c.SimMaxMB, def.SimMaxMB},
+		{"sim_folders", &c.SimFolders, def.SimFolders},
+		{"sim_files_per_batch", &c.SimFilesPerBatch, def.SimFilesPerBatch},
+	} {
+		if *f.field < 0 {
+			reset(f.key, *f.field, f.field, f.def)
+		}
+	}
+	if c.UIScale < 80 || c.UIScale > 160 {
+		reset("ui_scale", c.UIScale, &c.UIScale, def.UIScale)
+	}
+	if c.SFTPPort < 0 || c.SFTPPort > 65535 {
+		reset("sftp_port", c.SFTPPort, &c.SFTPPort, def.SFTPPort)
+	}
+
+	var modes []string
+	for _, o := range zeroByteModes {
+		modes = append(modes, o.Mode)
+	}
+	if !oneOf(c.ZeroByteMode, modes...) {
+		reset("zero_byte_mode", c.ZeroByteMode, &c.ZeroByteMode, def.ZeroByteMode)
+	}
+	modes = nil
+	for _, o := range batchSortOptions {
+		modes = append(modes, o.Mode)
+	}
+	if !oneOf(c.BatchSort, modes...) {
+		reset("batch_sort", c.BatchSort, &c.BatchSort, def.BatchSort)
+	}
+	modes = nil
+	for _, o := range profileOptions {
+		modes = append(modes, o.Profile)
+	}
+	if !oneOf(c.Profile, modes...) {
+		reset("profile", c.Profile, &c.Profile, def.Profile)
+	}
+	modes = nil
+	for _, o := range storageEngineOptions {
+		modes = append(modes, o.Engine)
+	}
+	if !oneOf(c.StorageEngine, modes...) {
+		reset("storage_engine", c.StorageEngine, &c.StorageEngine, def.StorageEngine)
+	}
+	modes = nil
+	for _, o := range sysLogOptions {
+		modes = append(modes, o.Backend)
+	}
+	if !oneOf(c.SysLog, modes...) {
+		reset("sys_log", c.SysLog, &c.SysLog, def.SysLog)
+	}
+	modes = nil
+	for _, o := range secretStoreOptions {
+		modes = append(modes, o.Store)
+	}
+	if !oneOf(c.SecretStore, modes...) {
+		reset("secret_store", c.SecretStore, &c.SecretStore, def.SecretStore)
+	}
+	modes = nil
+	for _, o := range localeOptions {
+		modes = append(modes, o.Tag)
+	}
+	if !oneOf(c.Locale, modes...) {
+		reset("locale", c.Locale, &c.Locale, def.Locale)
+	}
+	modes = nil
+	for _, o := range themeOptions {
+		modes = append(modes, o.Mode)
+	}
+	if !oneOf(c.Theme, modes...) {
+		reset("theme", c.Theme, &c.Theme, def.Theme)
+	}
+	if !oneOf(c.SummaryPeriod, summaryDaily, summaryWeekly) {
+		reset("summary_period", c.SummaryPeriod, &c.SummaryPeriod, def.SummaryPeriod)
+	}
+	if _, ok := findCloudProvider(c.CloudProvider); c.CloudProvider != "" && !ok {
+		reset("cloud_provider", c.CloudProvider, &c.CloudProvider, def.CloudProvider)
+	}
+
+	for _, f := range []struct {
+		key   string
+		field *string
+		def   string
+	}{
+		{"summary_time", &c.SummaryTime, def.SummaryTime},
+		{"schedule_start", &c.ScheduleStart, def.ScheduleStart},
+		{"schedule_end", &c.ScheduleEnd, def.ScheduleEnd},
+	} {
+		if _, _, ok := parseClock(*f.field); !ok {
+			reset(f.key, *f.field, f.field, f.def)
+		}
+	}
+	if _, err := parseHexColor(c.AccentColor); c.AccentColor != "" && err != nil {
+		reset("accent_color", c.AccentColor, &c.AccentColor, def.AccentColor)
+	}
+	if c.RenameTemplate != "" && !validRenameTemplate(c.RenameTemplate) {
+		reset("rename_template", c.RenameTemplate, &c.RenameTemplate, def.RenameTemplate)
+	}
+	if _, _, err := net.SplitHostPort(c.APIListen); err != nil {
+		reset("api_listen", c.APIListen, &c.APIListen, def.APIListen)
+	}
+	if !validGRPCListen(c.GRPCListen) {
+		reset("grpc_listen", c.GRPCListen, &c.GRPCListen, def.GRPCListen)
+	}
+	for _, d := range c.ScheduleDays {
+		if d < 0 || d > 6 {
+			reset("schedule_days", c.ScheduleDays, &c.ScheduleDays, def.ScheduleDays)
+			break
+		}
+	}
+	for i := range c.Rules {
+		if problem := c.Rules[i].validate(); problem != "" && c.Rules[i].Enabled {
+			c.Rules[i].Enabled = false
+			problems = append(problems, fmt.Sprintf("rules[%d] %q: %s, 已停用", i, c.Rules[i].Name, problem))
+		}
+	}
+	for i := range c.Plugins {
+		if problem := c.Plugins[i].validate(); problem != "" && c.Plugins[i].Enabled {
+			c.Plugins[i].Enabled = false
+			problems = append(problems, fmt.Sprintf("plugins[%d] %q: %s, 已停用", i, c.Plugins[i].Name, problem))
+		}
+	}
+	return problems
+}
+
+// convertConfigFile writes the current settings in a new format next to
+// the old file, then removes the old one and switches to the new path
+func convertConfigFile(format string) error {
+	for _, f := range configFormats {
+		if f.Format != format {
+			continue
+		}
+		newPath := filepath.Join(filepath.Dir(configPath), f.File)
+		if newPath == configPath {
+			return nil
+		}
+		data, err := encodeConfig(sealSecrets(config), format)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(newPath, data, 0644); err != nil {
+			return err
+		}
+		os.Remove(configPath)
+		for _, p := range configBackupPaths() {
+			os.Remove(p)
+		}
+		configPath = newPath
+		lastSavedConfig = data
+		return nil
+	}
+	return fmt.Errorf("未知的配置格式: %s", format)
+}
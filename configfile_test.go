@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecodeConfigFormats(t *testing.T) {
+	c := defaultConfig()
+	c.CustomExts = `.r3d, "quoted"`
+	c.CompletionTimeout = 45
+	c.ScheduleDays = []int{0, 6}
+	c.ImageEnabled = true
+	c.Rules = []Rule{{Name: "大文件", Enabled: true, Scope: ruleScopeFile, MinMB: 100, Action: ruleTag, Target: "big"}}
+	c.Plugins = []PluginConfig{{Name: "备份", Enabled: true, Kind: pluginUploader, Command: "backup.sh"}}
+
+	for _, f := range configFormats {
+		data, err := encodeConfig(c, f.Format)
+		if err != nil {
+			t.Fatalf("%s: encode error %v", f.Label, err)
+		}
+		got, err := decodeConfig(data, f.Format, Config{})
+		if err != nil {
+			t.Fatalf("%s: decode error %v\n%s", f.Label, err, data)
+		}
+		if !reflect.DeepEqual(got, c) {
+			t.Errorf("%s: round trip changed the config\n%s", f.Label, data)
+		}
+	}
+
+	// Settings are written in declaration order, not sorted
+	data, _ := encodeConfig(c, formatYAML)
+	if strings.Index(string(data), "version:") > strings.Index(string(data), "video_enabled:") {
+		t.Errorf("YAML not in declaration order:\n%s", data)
+	}
+}
+
+func TestDecodeConfigErrors(t *testing.T) {
+	_, err := decodeConfig([]byte("{\n  \"completion_timeout\": \"soon\"\n}"), formatJSON, defaultConfig())
+	if err == nil || !strings.Contains(err.Error(), "completion_timeout") {
+		t.Errorf("Type error should name the setting, got %v", err)
+	}
+	_, err = decodeConfig([]byte("{\n  \"video_enabled\": true,\n  oops\n}"), formatJSON, defaultConfig())
+	if err == nil || !strings.Contains(err.Error(), "第 3 行") {
+		t.Errorf("Syntax error should name the line, got %v", err)
+	}
+	_, err = decodeConfig([]byte("theme: [dark"), formatYAML, defaultConfig())
+	if err == nil || !strings.Contains(err.Error(), "YAML") {
+		t.Errorf("Expected a YAML error, got %v", err)
+	}
+	_, err = decodeConfig([]byte("theme = "), formatTOML, defaultConfig())
+	if err == nil || !strings.Contains(err.Error(), "TOML") {
+		t.Errorf("Expected a TOML error, got %v", err)
+	}
+
+	// Missing settings keep the base value
+	c, err := decodeConfig([]byte("theme: light\n"), formatYAML, defaultConfig())
+	if err != nil || c.Theme != themeLight || c.CompletionTimeout != defaultConfig().CompletionTimeout {
+		t.Errorf("Partial YAML decoded to %+v, %v", c, err)
+	}
+}
+
+func TestFindConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	if p := findConfigPath(dir); filepath.Base(p) != "config.json" {
+		t.Errorf("Expected config.json without any file, got %s", p)
+	}
+	os.WriteFile(filepath.Join(dir, "config.json"), []byte("{}"), 0644)
+	os.WriteFile(filepath.Join(dir, "config.toml"), []byte(""), 0644)
+	if p := findConfigPath(dir); filepath.Base(p) != "config.toml" {
+		t.Errorf("Expected config.toml to win over config.json, got %s", p)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	c := defaultConfig()
+	c.Theme = "neon"
+	c.StallMinutes = -1
+	c.ScheduleStart = "25:00"
+	c.ScheduleDays = []int{1, 9}
+	c.AccentColor = "purple"
+
+	problems := validateConfig(&c)
+	if len(problems) != 5 {
+		t.Errorf("Expected 5 problems, got %v", problems)
+	}
+	def := defaultConfig()
+	if c.Theme != def.Theme || c.StallMinutes != def.StallMinutes || c.ScheduleStart != def.ScheduleStart ||
+		!reflect.DeepEqual(c.ScheduleDays, def.ScheduleDays) || c.AccentColor != def.AccentColor {
+		t.Errorf("Invalid values not reset: %+v", c)
+	}
+
+	c = defaultConfig()
+	if problems := validateConfig(&c); len(problems) != 0 {
+		t.Errorf("Defaults reported invalid: %v", problems)
+	}
+}
+
+func TestLoadConfigMigratesLegacyFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	origConfig, origConfigPath, origProblems := config, configPath, configProblems
+	defer func() {
+		config, configPath, configProblems = origConfig, origConfigPath, origProblems
+	}()
+	configPath = filepath.Join(tmpDir, "config.json")
+	legacy := []byte(`{"video_enabled": true, "completion_timeout": 5, "theme": "neon"}`)
+	os.WriteFile(configPath, legacy, 0644)
+
+	config = defaultConfig()
+	if err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.Version != configVersion || config.CompletionTimeout != 30 {
+		t.Errorf("Not migrated: version %d, timeout %d", config.Version, config.CompletionTimeout)
+	}
+	if len(configProblems) != 1 || !strings.Contains(configProblems[0], "theme") {
+		t.Errorf("Expected the invalid theme to be reported, got %v", configProblems)
+	}
+	if kept, err := os.ReadFile(configPath + ".v1"); err != nil || string(kept) != string(legacy) {
+		t.Errorf("Original not kept: %q, %v", kept, err)
+	}
+	data, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(data), `"version": 2`) {
+		t.Errorf("Migrated file not written:\n%s", data)
+	}
+}
+
+func TestConvertConfigFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	origConfig, origConfigPath := config, configPath
+	defer func() { config, configPath = origConfig, origConfigPath }()
+	configPath = filepath.Join(tmpDir, "config.json")
+
+	config = defaultConfig()
+	config.CompletionTimeout = 77
+	saveConfig()
+	if err := convertConfigFile(formatYAML); err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(configPath) != "config.yaml" {
+		t.Errorf("configPath = %s", configPath)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "config.json")); !os.IsNotExist(err) {
+		t.Error("Old config.json was not removed")
+	}
+	config = defaultConfig()
+	if err := loadConfig(); err != nil || config.CompletionTimeout != 77 {
+		t.Errorf("Converted config loaded as %d, %v", config.CompletionTimeout, err)
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// contextMenuLabel is the entry added to the file manager's menu. It runs
+// the app with the folder as its argument, see launchFolder.
+const contextMenuLabel = "用 FidruaWatch 监控此文件夹"
+
+// shellQuote quotes s for /bin/sh
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// nautilusScriptPath returns ~/.local/share/nautilus/scripts/<label>, shown
+// under Scripts in the Files context menu
+func nautilusScriptPath() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(homeDir, ".local", "share")
+	}
+	return filepath.Join(dir, "nautilus", "scripts", contextMenuLabel), nil
+}
+
+// nautilusScript runs exePath on the selected folder. Files starts scripts
+// in the open folder with the selection as arguments, so with nothing
+// selected the open folder is watched.
+func nautilusScript(exePath string) string {
+	return fmt.Sprintf(`#!/bin/sh
+# Installed by FidruaWatch
+exec %s "${1:-.}"
+`, shellQuote(exePath))
+}
+
+// finderWorkflowPath returns ~/Library/Services/<label>.workflow, the quick
+// action listed under Quick Actions and Services for folders in Finder
+func finderWorkflowPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, "Library", "Services", contextMenuLabel+".workflow"), nil
+}
+
+// finderWorkflowInfo is the Info.plist of the quick action, offering it for
+// folders selected in Finder
+func finderWorkflowInfo() string {
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>NSServices</key>
+    <array>
+        <dict>
+            <key>NSMenuItem</key>
+            <dict>
+                <key>default</key>
+                %s
+            </dict>
+            <key>NSMessage</key>
+            <string>runWorkflowAsService</string>
+            <key>NSRequiredContext</key>
+            <dict>
+                <key>NSApplicationIdentifier</key>
+                <string>com.apple.finder</string>
+            </dict>
+            <key>NSSendFileTypes</key>
+            <array>
+                <string>public.folder</string>
+            </array>
+        </dict>
+    </array>
+</dict>
+</plist>
+`, plistString(contextMenuLabel))
+}
+
+// finderWorkflowDocument is the document.wflow of the quick action: one Run
+// Shell Script action getting the folder as its argument. The app is
+// started in the background, as Finder waits for the script to end.
+func finderWorkflowDocument(exePath string) string {
+	script := fmt.Sprintf(`nohup %s "$1" >/dev/null 2>&1 &`, shellQuote(exePath))
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>AMApplicationBuild</key>
+    <string>523</string>
+    <key>AMApplicationVersion</key>
+    <string>2.10</string>
+    <key>AMDocumentVersion</key>
+    <string>2</string>
+    <key>actions</key>
+    <array>
+        <dict>
+            <key>action</key>
+            <dict>
+                <key>AMAccepts</key>
+                <dict>
+                    <key>Container</key>
+                    <string>List</string>
+                    <key>Optional</key>
+                    <true/>
+                    <key>Types</key>
+                    <array>
+                        <string>com.apple.cocoa.string</string>
+                    </array>
+                </dict>
+                <key>AMActionVersion</key>
+                <string>2.0.3</string>
+                <key>AMProvides</key>
+                <dict>
+                    <key>Container</key>
+                    <string>List</string>
+                    <key>Types</key>
+                    <array>
+                        <string>com.apple.cocoa.string</string>
+                    </array>
+                </dict>
+                <key>ActionBundlePath</key>
+                <string>/System/Library/Automator/Run Shell Script.action</string>
+                <key>ActionName</key>
+                <string>Run Shell Script</string>
+                <key>ActionParameters</key>
+                <dict>
+                    <key>COMMAND_STRING</key>
+                    %s
+                    <key>CheckedForUserDefaultShell</key>
+                    <true/>
+                    <key>inputMethod</key>
+                    <integer>1</integer>
+                    <key>shell</key>
+                    <string>/bin/sh</string>
+                    <key>source</key>
+                    <string></string>
+                </dict>
+                <key>BundleIdentifier</key>
+                <string>com.apple.RunShellScript</string>
+                <key>CFBundleVersion</key>
+                <string>2.0.3</string>
+                <key>Class Name</key>
+                <string>RunShellScriptAction</string>
+            </dict>
+        </dict>
+    </array>
+    <key>connectors</key>
+    <dict/>
+    <key>workflowMetaData</key>
+    <dict>
+        <key>serviceInputTypeIdentifier</key>
+        <string>com.apple.Automator.fileSystemObject.folder</string>
+        <key>serviceOutputTypeIdentifier</key>
+        <string>com.apple.Automator.nothing</string>
+        <key>serviceProcessesInput</key>
+        <integer>0</integer>
+        <key>workflowTypeIdentifier</key>
+        <string>com.apple.Automator.servicesMenu</string>
+    </dict>
+</dict>
+</plist>
+`, plistString(script))
+}
+
+// installContextMenu adds contextMenuLabel to the file manager: Explorer's
+// folder menu on Windows, a Finder quick action on macOS and a Files
+// (Nautilus) script elsewhere
+func installContextMenu() error {
+	exePath := getExecutablePath()
+	if exePath == "" {
+		return fmt.Errorf("无法获取程序路径")
+	}
+	switch runtime.GOOS {
+	case "windows":
+		return installContextMenuWindows(exePath)
+	case "darwin":
+		dir, err := finderWorkflowPath()
+		if err != nil {
+			return err
+		}
+		contents := filepath.Join(dir, "Contents")
+		if err := os.MkdirAll(contents, 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(contents, "Info.plist"), []byte(finderWorkflowInfo()), 0644); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(contents, "document.wflow"), []byte(finderWorkflowDocument(exePath)), 0644); err != nil {
+			return err
+		}
+		// Have the services menu pick it up now rather than at next login
+		exec.Command("/System/Library/CoreServices/pbs", "-update").Run()
+		return nil
+	default:
+		path, err := nautilusScriptPath()
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := writeFileAtomic(path, []byte(nautilusScript(exePath)), 0755); err != nil {
+			return fmt.Errorf("无法写入 %s: %v", path, err)
+		}
+		return nil
+	}
+}
+
+// removeContextMenu removes what installContextMenu added
+func removeContextMenu() error {
+	switch runtime.GOOS {
+	case "windows":
+		return removeContextMenuWindows()
+	case "darwin":
+		dir, err := finderWorkflowPath()
+		if err != nil {
+			return err
+		}
+		return os.RemoveAll(dir)
+	default:
+		path, err := nautilusScriptPath()
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+}
+
+// contextMenuInstalled reports whether the menu entry is installed
+func contextMenuInstalled() bool {
+	var path string
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		return contextMenuInstalledWindows()
+	case "darwin":
+		path, err = finderWorkflowPath()
+	default:
+		path, err = nautilusScriptPath()
+	}
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(path)
+	return err == nil
+}
+
+// newContextMenuRow is the settings row installing and removing the file
+// manager menu entry
+func newContextMenuRow(w fyne.Window) fyne.CanvasObject {
+	where := map[string]string{
+		"windows": "资源管理器文件夹右键菜单",
+		"darwin":  "访达的快速操作 (右键 → 快速操作/服务)",
+	}[runtime.GOOS]
+	if where == "" {
+		where = "文件 (Nautilus) 右键菜单的脚本"
+	}
+	status := widget.NewLabel("")
+	refresh := func() {
+		if contextMenuInstalled() {
+			status.SetText("状态: 已安装")
+		} else {
+			status.SetText("状态: 未安装")
+		}
+	}
+	run := func(action func() error, done string) {
+		if err := action(); err != nil {
+			dialog.ShowError(err, w)
+		} else {
+			logEvent("%s", done)
+		}
+		refresh()
+	}
+	install := widget.NewButton("安装", func() {
+		run(installContextMenu, "已安装右键菜单: "+contextMenuLabel)
+	})
+	remove := widget.NewButton("移除", func() {
+		run(removeContextMenu, "已移除右键菜单")
+	})
+	refresh()
+	return container.NewVBox(
+		widget.NewLabel("🖱️ 右键菜单「"+contextMenuLabel+"」: "+where),
+		container.NewHBox(install, remove),
+		status,
+	)
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+func installContextMenuWindows(exePath string) error {
+	return errors.New("仅支持 Windows")
+}
+
+func removeContextMenuWindows() error {
+	return errors.New("仅支持 Windows")
+}
+
+func contextMenuInstalledWindows() bool {
+	return false
+}
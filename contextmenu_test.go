@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNautilusScript(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs /bin/sh")
+	}
+	dir := t.TempDir()
+	// A stand-in for the app at a path that needs quoting
+	exe := filepath.Join(dir, "it's me", "fidruawatch")
+	os.MkdirAll(filepath.Dir(exe), 0755)
+	os.WriteFile(exe, []byte("#!/bin/sh\necho \"$1\"\n"), 0755)
+	script := filepath.Join(dir, "script")
+	os.WriteFile(script, []byte(nautilusScript(exe)), 0755)
+
+	for args, want := range map[string]string{"": ".", "Client A": "Client A"} {
+		cmd := exec.Command(script)
+		if args != "" {
+			cmd.Args = append(cmd.Args, args)
+		}
+		out, err := cmd.Output()
+		if err != nil || strings.TrimSpace(string(out)) != want {
+			t.Errorf("script %q got %q, %v; want %q", args, out, err, want)
+		}
+	}
+}
+
+func TestFinderWorkflow(t *testing.T) {
+	doc := finderWorkflowDocument("/Applications/A&B.app/Contents/MacOS/fidruawatch")
+	for _, plist := range []string{finderWorkflowInfo(), doc} {
+		if err := xml.Unmarshal([]byte(plist), new(struct{})); err != nil {
+			t.Fatalf("Plist is not valid XML: %v\n%s", err, plist)
+		}
+	}
+	if !strings.Contains(doc, `<string>nohup &#39;/Applications/A&amp;B.app/Contents/MacOS/fidruawatch&#39; &#34;$1&#34; &gt;/dev/null 2&gt;&amp;1 &amp;</string>`) {
+		t.Errorf("Unexpected shell script:\n%s", doc)
+	}
+	if !strings.Contains(finderWorkflowInfo(), "<string>"+contextMenuLabel+"</string>") {
+		t.Error("Quick action lacks its menu label")
+	}
+}
@@ -0,0 +1,68 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// contextMenuKeys are the per-user Explorer verbs: on a folder, where
+// Explorer passes it as %1, and on the background of an open folder, where
+// it is %V
+var contextMenuKeys = []struct {
+	Path string
+	Arg  string
+}{
+	{`Software\Classes\Directory\shell\FidruaWatch`, "%1"},
+	{`Software\Classes\Directory\Background\shell\FidruaWatch`, "%V"},
+}
+
+func installContextMenuWindows(exePath string) error {
+	for _, k := range contextMenuKeys {
+		key, _, err := registry.CreateKey(registry.CURRENT_USER, k.Path, registry.SET_VALUE)
+		if err != nil {
+			return fmt.Errorf("无法创建注册表项 HKCU\\%s: %v", k.Path, err)
+		}
+		err = key.SetStringValue("", contextMenuLabel)
+		if err == nil {
+			err = key.SetStringValue("Icon", exePath)
+		}
+		key.Close()
+		if err != nil {
+			return fmt.Errorf("无法写入注册表项 HKCU\\%s: %v", k.Path, err)
+		}
+		cmd, _, err := registry.CreateKey(registry.CURRENT_USER, k.Path+`\command`, registry.SET_VALUE)
+		if err != nil {
+			return fmt.Errorf("无法创建注册表项 HKCU\\%s\\command: %v", k.Path, err)
+		}
+		err = cmd.SetStringValue("", runCommand(exePath, nil)+` "`+k.Arg+`"`)
+		cmd.Close()
+		if err != nil {
+			return fmt.Errorf("无法写入注册表项 HKCU\\%s\\command: %v", k.Path, err)
+		}
+	}
+	return nil
+}
+
+func removeContextMenuWindows() error {
+	for _, k := range contextMenuKeys {
+		for _, path := range []string{k.Path + `\command`, k.Path} {
+			if err := registry.DeleteKey(registry.CURRENT_USER, path); err != nil && !errors.Is(err, registry.ErrNotExist) {
+				return fmt.Errorf("无法删除注册表项 HKCU\\%s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func contextMenuInstalledWindows() bool {
+	key, err := registry.OpenKey(registry.CURRENT_USER, contextMenuKeys[0].Path+`\command`, registry.QUERY_VALUE)
+	if err != nil {
+		return false
+	}
+	key.Close()
+	return true
+}
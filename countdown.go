@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// countdownText is the card line counting down to the completion check of
+// an uploading batch, "" for other batches. Every new file or size change
+// moves LastTime and so restarts the countdown. Caller must hold batchesMu.
+func countdownText(b *Batch, now time.Time) string {
+	if b.Status != "uploading" {
+		return ""
+	}
+	left := b.LastTime.Add(batchTimeout(b)).Sub(now)
+	if left <= 0 {
+		// Completion is checked every few seconds and may be held back by
+		// the conditions shown on the card
+		return "⏳ 即将判定完成"
+	}
+	return fmt.Sprintf("⏳ 预计 %d 秒后判定完成", int(math.Ceil(left.Seconds())))
+}
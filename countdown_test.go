@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCountdownText(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.CompletionTimeout = 30
+
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b := &Batch{Status: "uploading", LastTime: now.Add(-12500 * time.Millisecond)}
+	if got := countdownText(b, now); got != "⏳ 预计 18 秒后判定完成" {
+		t.Errorf("countdown = %q", got)
+	}
+	// A new file restarts it
+	b.LastTime = now
+	if got := countdownText(b, now); got != "⏳ 预计 30 秒后判定完成" {
+		t.Errorf("countdown after activity = %q", got)
+	}
+	if got := countdownText(b, now.Add(time.Minute)); got != "⏳ 即将判定完成" {
+		t.Errorf("countdown past the timeout = %q", got)
+	}
+	b.Status = "completed"
+	if got := countdownText(b, now); got != "" {
+		t.Errorf("completed batch countdown = %q", got)
+	}
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ctlTimeout bounds a control command, including the UI work it waits for
+const ctlTimeout = 10 * time.Second
+
+// ctlRequest is a command sent to the running instance over its control
+// socket, one JSON object per connection
+type ctlRequest struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// ctlResponse answers a ctlRequest
+type ctlResponse struct {
+	OK      bool            `json:"ok"`
+	Message string          `json:"message,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// ctlStatus is the reply to status
+type ctlStatus struct {
+	PID        int        `json:"pid"`
+	Headless   bool       `json:"headless"`
+	Monitoring bool       `json:"monitoring"`
+	Path       string     `json:"path,omitempty"`
+	Session    string     `json:"session,omitempty"`
+	Uploading  int        `json:"uploading"`
+	Batches    []apiBatch `json:"batches"`
+}
+
+// ctlActions carry out commands in the running instance. A nil action is a
+// command the instance doesn't support, e.g. show without a window.
+type ctlActions struct {
+	Status  func() ctlStatus
+	AddPath func(path string) error
+	Start   func() error
+	Stop    func() error
+	Show    func() error
+}
+
+// errCtlRunning is returned by listenCtl when another instance owns the
+// socket
+var errCtlRunning = errors.New("FidruaWatch 已在运行")
+
+// ctlSocketPath is the control socket next to the config file, so copies
+// run with another --config don't talk to each other
+func ctlSocketPath() string {
+	return filepath.Join(filepath.Dir(configPath), "fidruawatch.sock")
+}
+
+// currentCtlStatus describes the monitoring state and the batches, newest
+// first. Callers in the window run it on the UI goroutine, which owns
+// isMonitoring and monitorPath.
+func currentCtlStatus() ctlStatus {
+	s := ctlStatus{PID: os.Getpid(), Headless: headless, Monitoring: isMonitoring, Session: sessionID, Batches: []apiBatch{}}
+	if isMonitoring {
+		s.Path = monitorPath
+	}
+	batchesMu.RLock()
+	for _, b := range batches {
+		s.Batches = append(s.Batches, toAPIBatch(b))
+	}
+	s.Uploading = uploadingCount()
+	batchesMu.RUnlock()
+	sort.Slice(s.Batches, func(i, j int) bool { return s.Batches[i].StartTime.After(s.Batches[j].StartTime) })
+	return s
+}
+
+// ctlStatusText is the status for people
+func ctlStatusText(s ctlStatus) string {
+	var b strings.Builder
+	if s.Monitoring {
+		fmt.Fprintf(&b, "正在监控: %s\n", displayWindowsPath(s.Path))
+	} else {
+		b.WriteString("未在监控\n")
+	}
+	done := 0
+	for _, batch := range s.Batches {
+		if batch.Status != "uploading" {
+			done++
+		}
+	}
+	fmt.Fprintf(&b, "上传中 %d 个批次, 已完成 %d 个 (进程 %d)", s.Uploading, done, s.PID)
+	return b.String()
+}
+
+// listenCtl opens the control socket, taking over one left behind by an
+// instance that didn't exit cleanly
+func listenCtl() (net.Listener, error) {
+	path := ctlSocketPath()
+	if conn, err := net.DialTimeout("unix", path, time.Second); err == nil {
+		conn.Close()
+		return nil, errCtlRunning
+	}
+	os.Remove(path)
+	return listenPrivateSocket(path)
+}
+
+// serveCtl answers commands on l until ctx is done
+func serveCtl(ctx context.Context, l net.Listener, actions ctlActions) {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			conn.SetDeadline(time.Now().Add(ctlTimeout))
+			var req ctlRequest
+			if err := json.NewDecoder(bufio.NewReader(conn)).Decode(&req); err != nil {
+				return
+			}
+			json.NewEncoder(conn).Encode(handleCtl(req, actions))
+		}()
+	}
+}
+
+// handleCtl carries out one command
+func handleCtl(req ctlRequest, actions ctlActions) ctlResponse {
+	unsupported := ctlResponse{Message: "当前实例不支持 " + req.Command}
+	run := func(action func() error, done string) ctlResponse {
+		if action == nil {
+			return unsupported
+		}
+		if err := action(); err != nil {
+			return ctlResponse{Message: err.Error()}
+		}
+		return ctlResponse{OK: true, Message: done}
+	}
+	switch req.Command {
+	case "status":
+		if actions.Status == nil {
+			return unsupported
+		}
+		data, err := json.Marshal(actions.Status())
+		if err != nil {
+			return ctlResponse{Message: err.Error()}
+		}
+		return ctlResponse{OK: true, Data: data}
+	case "add-path":
+		if len(req.Args) != 1 || req.Args[0] == "" {
+			return ctlResponse{Message: "add-path 需要一个文件夹"}
+		}
+		if actions.AddPath == nil {
+			return unsupported
+		}
+		logEvent("控制命令: 监控 %s", req.Args[0])
+		return run(func() error { return actions.AddPath(req.Args[0]) }, "正在监控 "+req.Args[0])
+	case "start":
+		logEvent("控制命令: 开始监控")
+		return run(actions.Start, "已开始监控")
+	case "stop":
+		logEvent("控制命令: 停止监控")
+		return run(actions.Stop, "已停止监控")
+	case "show":
+		return run(actions.Show, "")
+	}
+	return ctlResponse{Message: "未知的命令: " + req.Command}
+}
+
+// sendCtl sends req to the running instance
+func sendCtl(req ctlRequest) (ctlResponse, error) {
+	var resp ctlResponse
+	conn, err := net.DialTimeout("unix", ctlSocketPath(), time.Second)
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(ctlTimeout + time.Second))
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, err
+	}
+	err = json.NewDecoder(conn).Decode(&resp)
+	return resp, err
+}
+
+// forwardToRunning hands a second launch to the instance already running
+// with the same config, e.g. from the context menu: it watches the folder
+// given or comes to the front. It reports whether the running instance
+// took over, so this one can exit.
+func forwardToRunning(opts cliOptions) bool {
+	req := ctlRequest{Command: "show"}
+	if opts.Folder != "" {
+		req = ctlRequest{Command: "add-path", Args: []string{opts.Folder}}
+	}
+	resp, err := sendCtl(req)
+	if err != nil {
+		return false
+	}
+	if !resp.OK {
+		fmt.Fprintln(os.Stderr, resp.Message)
+	}
+	return resp.OK
+}
+
+// parseInterspersed parses flags anywhere on the command line, as in
+// "status --json" or "add-path /srv/in --json", and returns the other
+// arguments in order. Everything after "--" is an argument.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// runCtl is `fidruawatch ctl`, driving the running instance from scripts.
+// It returns the process exit code: 1 when the command failed and 3 when
+// no instance is running.
+func runCtl(args []string) int {
+	fs := flag.NewFlagSet("fidruawatch ctl", flag.ContinueOnError)
+	cfg := fs.String("config", "", "配置文件路径, 用于找到以该配置运行的实例")
+	asJSON := fs.Bool("json", false, "以 JSON 输出")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "用法: fidruawatch ctl [--config 文件] <status | start | stop | show | add-path 文件夹> [--json]")
+		fs.PrintDefaults()
+	}
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(positional) == 0 {
+		fs.Usage()
+		return 2
+	}
+	command := positional[0]
+	req := ctlRequest{Command: command, Args: positional[1:]}
+	if *cfg != "" {
+		configPath = *cfg
+	}
+	if command == "add-path" && len(req.Args) == 1 {
+		// Relative to where ctl runs, not the running instance
+		folder, err := launchFolder(req.Args[0])
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		req.Args[0] = folder
+	}
+
+	resp, err := sendCtl(req)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "没有正在运行的 FidruaWatch (%s): %v\n", ctlSocketPath(), err)
+		return 3
+	}
+	switch {
+	case *asJSON && resp.Data != nil:
+		fmt.Println(string(resp.Data))
+	case *asJSON:
+		json.NewEncoder(os.Stdout).Encode(resp)
+	case !resp.OK:
+		fmt.Fprintln(os.Stderr, resp.Message)
+	case command == "status":
+		var s ctlStatus
+		if err := json.Unmarshal(resp.Data, &s); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fmt.Println(ctlStatusText(s))
+	case resp.Message != "":
+		fmt.Println(resp.Message)
+	}
+	if !resp.OK {
+		return 1
+	}
+	return 0
+}
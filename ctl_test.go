@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCtlCommands(t *testing.T) {
+	origConfigPath := configPath
+	defer func() { configPath = origConfigPath }()
+	configPath = filepath.Join(t.TempDir(), "config.json")
+
+	l, err := listenCtl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := listenCtl(); !errors.Is(err, errCtlRunning) {
+		t.Errorf("Second listener: %v, want errCtlRunning", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var added string
+	go serveCtl(ctx, l, ctlActions{
+		Status: func() ctlStatus {
+			return ctlStatus{PID: 42, Monitoring: true, Path: added, Uploading: 1,
+				Batches: []apiBatch{{ID: "a", Status: "uploading"}, {ID: "b", Status: "completed"}}}
+		},
+		AddPath: func(path string) error {
+			if path == "/bad" {
+				return errors.New("无法开始监控")
+			}
+			added = path
+			return nil
+		},
+	})
+
+	if resp, err := sendCtl(ctlRequest{Command: "add-path", Args: []string{"/srv/in"}}); err != nil || !resp.OK || added != "/srv/in" {
+		t.Errorf("add-path: %+v, %v, added %q", resp, err, added)
+	}
+	resp, err := sendCtl(ctlRequest{Command: "status"})
+	if err != nil || !resp.OK {
+		t.Fatalf("status: %+v, %v", resp, err)
+	}
+	var s ctlStatus
+	if err := json.Unmarshal(resp.Data, &s); err != nil || s.PID != 42 || s.Path != "/srv/in" || len(s.Batches) != 2 {
+		t.Errorf("status data %s: %v", resp.Data, err)
+	}
+	if text := ctlStatusText(s); !strings.Contains(text, "上传中 1 个批次, 已完成 1 个") {
+		t.Errorf("status text %q", text)
+	}
+
+	for _, req := range []ctlRequest{
+		{Command: "add-path", Args: []string{"/bad"}},
+		{Command: "add-path"},
+		{Command: "stop"}, // not supported by these actions
+		{Command: "format-disk"},
+	} {
+		if resp, err := sendCtl(req); err != nil || resp.OK || resp.Message == "" {
+			t.Errorf("%+v: expected a failure with a message, got %+v, %v", req, resp, err)
+		}
+	}
+
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, err := sendCtl(ctlRequest{Command: "status"}); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Control socket still answers after shutdown")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestCtlStaleSocket(t *testing.T) {
+	origConfigPath := configPath
+	defer func() { configPath = origConfigPath }()
+	configPath = filepath.Join(t.TempDir(), "config.json")
+
+	// Left behind by an instance that crashed
+	os.WriteFile(ctlSocketPath(), nil, 0600)
+	l, err := listenCtl()
+	if err != nil {
+		t.Fatalf("Stale socket not replaced: %v", err)
+	}
+	l.Close()
+}
+
+func TestCtlFlagsAfterArguments(t *testing.T) {
+	origConfigPath := configPath
+	defer func() { configPath = origConfigPath }()
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "config.json")
+	configPath = cfg
+
+	l, err := listenCtl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(ctlSocketPath()); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("socket mode %v, %v; want 0600", fi.Mode().Perm(), err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var added []string
+	go serveCtl(ctx, l, ctlActions{
+		AddPath: func(path string) error {
+			added = append(added, path)
+			return nil
+		},
+	})
+
+	// --json after the folder is a flag, not a second argument
+	origStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	code := runCtl([]string{"--config", cfg, "add-path", dir, "--json"})
+	w.Close()
+	os.Stdout = origStdout
+	out, _ := io.ReadAll(r)
+	if code != 0 || len(added) != 1 || added[0] != dir {
+		t.Fatalf("exit %d, added %v; want %s", code, added, dir)
+	}
+	var resp ctlResponse
+	if err := json.Unmarshal(out, &resp); err != nil || !resp.OK {
+		t.Errorf("output %q is not a JSON response: %v", out, err)
+	}
+}
+
+func TestParseInterspersed(t *testing.T) {
+	for _, tt := range []struct {
+		args, want []string
+		json       bool
+	}{
+		{[]string{"status", "--json"}, []string{"status"}, true},
+		{[]string{"--json", "add-path", "/in"}, []string{"add-path", "/in"}, true},
+		{[]string{"add-path", "/in", "--json"}, []string{"add-path", "/in"}, true},
+		{[]string{"add-path", "--", "--json"}, []string{"add-path", "--json"}, false},
+	} {
+		fs := flag.NewFlagSet("ctl", flag.ContinueOnError)
+		asJSON := fs.Bool("json", false, "")
+		got, err := parseInterspersed(fs, tt.args)
+		if err != nil || strings.Join(got, " ") != strings.Join(tt.want, " ") || *asJSON != tt.json {
+			t.Errorf("%q: %q, json %v, %v; want %q, json %v", tt.args, got, *asJSON, err, tt.want, tt.json)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// D-Bus names of the service published on the Linux session bus
+const (
+	dbusName      = "com.fidrua.watch"
+	dbusPath      = "/com/fidrua/watch"
+	dbusInterface = "com.fidrua.watch.Monitor"
+)
+
+// dbusBatch is a batch as returned by ListBatches, signature (sssixxx):
+// times are Unix seconds, 0 when unset
+type dbusBatch struct {
+	ID        string
+	Folder    string
+	Status    string
+	Files     int32
+	TotalSize int64
+	StartTime int64
+	LastTime  int64
+}
+
+// toDBusBatch converts a batch for D-Bus
+func toDBusBatch(b apiBatch) dbusBatch {
+	return dbusBatch{
+		ID:        b.ID,
+		Folder:    b.Folder,
+		Status:    b.Status,
+		Files:     int32(b.Files),
+		TotalSize: b.TotalSize,
+		StartTime: unixSeconds(b.StartTime),
+		LastTime:  unixSeconds(b.LastTime),
+	}
+}
+
+// unixSeconds is t in Unix seconds, 0 for the zero time
+func unixSeconds(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+// errSignToken is returned when a sign request lacks the token for signing
+var errSignToken = errors.New("签收令牌无效")
+
+// signBatchByID signs the batch with the given ID, as the configured
+// operator when by is empty, like the API's sign request. Any local
+// process can call the session bus, so token must be one the API accepts
+// for signing: the sign token, else the API token, when either is set.
+func signBatchByID(id, by, comment, token string) error {
+	if apiRoleFor("Bearer "+token) != apiRoleSigner {
+		return errSignToken
+	}
+	if strings.TrimSpace(by) == "" {
+		by = config.OperatorName
+	}
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	b, ok := batches[id]
+	if !ok {
+		return fmt.Errorf("找不到批次 %s", id)
+	}
+	return signBatchAs(b, by, apiPrincipal(), comment, time.Now())
+}
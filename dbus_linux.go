@@ -0,0 +1,167 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+)
+
+// dbusStateInterval is how often the state properties are refreshed
+const dbusStateInterval = 2 * time.Second
+
+// dbusMonitor is the object exported at dbusPath. Its exported methods are
+// the D-Bus methods.
+type dbusMonitor struct {
+	actions  ctlActions
+	updateUI func()
+}
+
+var errDBusUnsupported = dbus.MakeFailedError(errors.New("当前实例不支持此操作"))
+
+func (m *dbusMonitor) call(action func() error) *dbus.Error {
+	if action == nil {
+		return errDBusUnsupported
+	}
+	if err := action(); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+func (m *dbusMonitor) Start() *dbus.Error { return m.call(m.actions.Start) }
+
+func (m *dbusMonitor) Stop() *dbus.Error { return m.call(m.actions.Stop) }
+
+func (m *dbusMonitor) Show() *dbus.Error { return m.call(m.actions.Show) }
+
+func (m *dbusMonitor) AddPath(path string) *dbus.Error {
+	if m.actions.AddPath == nil {
+		return errDBusUnsupported
+	}
+	folder, err := launchFolder(path)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	logEvent("D-Bus: 监控 %s", folder)
+	return m.call(func() error { return m.actions.AddPath(folder) })
+}
+
+func (m *dbusMonitor) ListBatches() ([]dbusBatch, *dbus.Error) {
+	if m.actions.Status == nil {
+		return nil, errDBusUnsupported
+	}
+	out := []dbusBatch{}
+	for _, b := range m.actions.Status().Batches {
+		out = append(out, toDBusBatch(b))
+	}
+	return out, nil
+}
+
+func (m *dbusMonitor) Sign(id, by, comment, token string) *dbus.Error {
+	if err := signBatchByID(id, by, comment, token); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	m.updateUI()
+	return nil
+}
+
+// dbusIntrospection describes the Monitor interface with argument names,
+// for d-feet and busctl
+var dbusIntrospection = introspect.Interface{
+	Name: dbusInterface,
+	Methods: []introspect.Method{
+		{Name: "Start"},
+		{Name: "Stop"},
+		{Name: "Show"},
+		{Name: "AddPath", Args: []introspect.Arg{{Name: "path", Type: "s", Direction: "in"}}},
+		{Name: "ListBatches", Args: []introspect.Arg{{Name: "batches", Type: "a(sssixxx)", Direction: "out"}}},
+		{Name: "Sign", Args: []introspect.Arg{
+			{Name: "id", Type: "s", Direction: "in"},
+			{Name: "by", Type: "s", Direction: "in"},
+			{Name: "comment", Type: "s", Direction: "in"},
+			{Name: "token", Type: "s", Direction: "in"},
+		}},
+	},
+	Signals: []introspect.Signal{
+		{Name: "BatchChanged", Args: []introspect.Arg{
+			{Name: "event", Type: "s"},
+			{Name: "id", Type: "s"},
+			{Name: "status", Type: "s"},
+		}},
+	},
+}
+
+// runDBus publishes the Monitor interface on the session bus until ctx is
+// done: the Start, Stop, Show, AddPath, ListBatches and Sign methods, the
+// Monitoring, WatchPath and Uploading properties and a BatchChanged signal
+// for every batch event. Without a session bus, e.g. on a server, or when
+// another copy owns the name, it only logs why.
+func runDBus(ctx context.Context, actions ctlActions, updateUI func()) {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		logEvent("D-Bus 不可用: %v", err)
+		return
+	}
+	defer conn.Close()
+	reply, err := conn.RequestName(dbusName, dbus.NameFlagDoNotQueue)
+	if err != nil || reply != dbus.RequestNameReplyPrimaryOwner {
+		logEvent("D-Bus 名称 %s 已被占用, 未发布 D-Bus 接口", dbusName)
+		return
+	}
+
+	obj := &dbusMonitor{actions: actions, updateUI: updateUI}
+	if err := conn.Export(obj, dbusPath, dbusInterface); err != nil {
+		logEvent("D-Bus 导出失败: %v", err)
+		return
+	}
+	props, err := prop.Export(conn, dbusPath, prop.Map{dbusInterface: {
+		"Monitoring": {Value: false, Emit: prop.EmitTrue},
+		"WatchPath":  {Value: "", Emit: prop.EmitTrue},
+		"Uploading":  {Value: int32(0), Emit: prop.EmitTrue},
+	}})
+	if err != nil {
+		logEvent("D-Bus 导出失败: %v", err)
+		return
+	}
+	iface := dbusIntrospection
+	iface.Properties = props.Introspection(dbusInterface)
+	conn.Export(introspect.NewIntrospectable(&introspect.Node{
+		Name:       dbusPath,
+		Interfaces: []introspect.Interface{introspect.IntrospectData, prop.IntrospectData, iface},
+	}), dbusPath, "org.freedesktop.DBus.Introspectable")
+	logEvent("D-Bus 接口已发布: %s", dbusName)
+
+	// Properties only emit PropertiesChanged when they change
+	refresh := func() {
+		if actions.Status == nil {
+			return
+		}
+		s := actions.Status()
+		for name, v := range map[string]interface{}{"Monitoring": s.Monitoring, "WatchPath": s.Path, "Uploading": int32(s.Uploading)} {
+			if props.GetMust(dbusInterface, name) != v {
+				props.SetMust(dbusInterface, name, v)
+			}
+		}
+	}
+	refresh()
+	events, unsubscribe := batchEvents.Subscribe()
+	defer unsubscribe()
+	ticker := time.NewTicker(dbusStateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			conn.Emit(dbusPath, dbusInterface+".BatchChanged", ev.Type, ev.Batch.ID, ev.Batch.Status)
+		case <-ticker.C:
+			refresh()
+		}
+	}
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+import "context"
+
+// runDBus does nothing where there is no session bus to publish on
+func runDBus(ctx context.Context, actions ctlActions, updateUI func()) {}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestToDBusBatch(t *testing.T) {
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	got := toDBusBatch(apiBatch{ID: "a", Folder: "/in/a", Status: "uploading", Files: 3, TotalSize: 1 << 40, StartTime: start, LastTime: start.Add(time.Minute)})
+	want := dbusBatch{ID: "a", Folder: "/in/a", Status: "uploading", Files: 3, TotalSize: 1 << 40, StartTime: start.Unix(), LastTime: start.Unix() + 60}
+	if got != want {
+		t.Errorf("toDBusBatch = %+v, want %+v", got, want)
+	}
+	if got := toDBusBatch(apiBatch{ID: "b"}); got.StartTime != 0 || got.LastTime != 0 {
+		t.Errorf("Unset times should be 0, got %+v", got)
+	}
+}
+
+func TestSignBatchByID(t *testing.T) {
+	savedConfig, savedBatches := config, batches
+	defer func() { config, batches = savedConfig, savedBatches }()
+	config.SaveHistory = false
+	config.RequireReview = false
+	config.OperatorName = "Alice"
+	batches = map[string]*Batch{"1": {ID: "1", Status: "completed"}}
+
+	if err := signBatchByID("2", "", "", ""); err == nil {
+		t.Error("Signed a batch that doesn't exist")
+	}
+	if err := signBatchByID("1", "", "ok", ""); err != nil {
+		t.Fatal(err)
+	}
+	if b := batches["1"]; b.Status != "signed" || len(b.SignOffs) != 1 || b.SignOffs[0].By != "Alice" {
+		t.Errorf("Batch after signing: %s %+v", b.Status, b.SignOffs)
+	}
+	if err := signBatchByID("1", "Bob", "", ""); err == nil {
+		t.Error("Signed a batch twice")
+	}
+}
+
+func TestSignBatchByIDNeedsSignToken(t *testing.T) {
+	savedConfig, savedBatches := config, batches
+	defer func() { config, batches = savedConfig, savedBatches }()
+	config.SaveHistory = false
+	config.RequireReview = false
+	config.APIToken, config.SignToken = "view", "sign"
+	batches = map[string]*Batch{"1": {ID: "1", Status: "completed"}}
+
+	for _, token := range []string{"", "view", "wrong"} {
+		if err := signBatchByID("1", "Eve", "", token); err != errSignToken {
+			t.Errorf("token %q: err = %v, want errSignToken", token, err)
+		}
+	}
+	if batches["1"].Status != "completed" {
+		t.Fatalf("Signed without the sign token: %s", batches["1"].Status)
+	}
+	if err := signBatchByID("1", "Alice", "", "sign"); err != nil || batches["1"].Status != "signed" {
+		t.Errorf("Sign with the sign token: %v, status %s", err, batches["1"].Status)
+	}
+
+	// Without a sign token the API token is needed
+	config.SignToken = ""
+	batches["2"] = &Batch{ID: "2", Status: "completed"}
+	if err := signBatchByID("2", "Eve", "", ""); err != errSignToken {
+		t.Errorf("no token with an API token set: err = %v", err)
+	}
+	if err := signBatchByID("2", "Alice", "", "view"); err != nil {
+		t.Errorf("API token: %v", err)
+	}
+}
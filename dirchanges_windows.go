@@ -0,0 +1,180 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+	"golang.org/x/sys/windows"
+)
+
+// dirChangesBufSize is the notification buffer per root. Larger buffers
+// fail on network shares.
+const dirChangesBufSize = 64 * 1024
+
+const dirChangesFilter = windows.FILE_NOTIFY_CHANGE_FILE_NAME | windows.FILE_NOTIFY_CHANGE_DIR_NAME |
+	windows.FILE_NOTIFY_CHANGE_SIZE | windows.FILE_NOTIFY_CHANGE_LAST_WRITE | windows.FILE_NOTIFY_CHANGE_CREATION
+
+// dirChangesWatcher watches whole trees with a single recursive
+// ReadDirectoryChangesW per root, instead of one watch per directory.
+type dirChangesWatcher struct {
+	events chan fsnotify.Event
+	errors chan error
+	stop   windows.Handle // manual-reset event set by Close, ends the reads
+	done   chan struct{}  // closed by Close, ends pending sends
+	mu     sync.Mutex
+	closed bool
+	wg     sync.WaitGroup
+}
+
+// newRecursiveWatcher returns the ReadDirectoryChangesW backend, nil if the
+// stop event can't be created
+func newRecursiveWatcher() fsWatcher {
+	stop, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		return nil
+	}
+	return &dirChangesWatcher{
+		events: make(chan fsnotify.Event, 64),
+		errors: make(chan error, 8),
+		stop:   stop,
+		done:   make(chan struct{}),
+	}
+}
+
+func (w *dirChangesWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *dirChangesWatcher) Errors() <-chan error          { return w.errors }
+
+// Add starts watching the tree below dir
+func (w *dirChangesWatcher) Add(dir string) error {
+	name, err := windows.UTF16PtrFromString(extendedLengthPath(dir))
+	if err != nil {
+		return err
+	}
+	h, err := windows.CreateFile(name, windows.FILE_LIST_DIRECTORY,
+		windows.FILE_SHARE_READ|windows.FILE_SHARE_WRITE|windows.FILE_SHARE_DELETE, nil,
+		windows.OPEN_EXISTING, windows.FILE_FLAG_BACKUP_SEMANTICS|windows.FILE_FLAG_OVERLAPPED, 0)
+	if err != nil {
+		return err
+	}
+	ioEvent, err := windows.CreateEvent(nil, 1, 0, nil)
+	if err != nil {
+		windows.CloseHandle(h)
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		windows.CloseHandle(ioEvent)
+		windows.CloseHandle(h)
+		return fsnotify.ErrClosed
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		defer windows.CloseHandle(h)
+		defer windows.CloseHandle(ioEvent)
+		w.read(filepath.Clean(dir), h, ioEvent)
+	}()
+	return nil
+}
+
+// read reports the changes below root until the watcher is closed or the
+// root goes away
+func (w *dirChangesWatcher) read(root string, h, ioEvent windows.Handle) {
+	buf := make([]byte, dirChangesBufSize)
+	for {
+		ov := windows.Overlapped{HEvent: ioEvent}
+		err := windows.ReadDirectoryChanges(h, &buf[0], uint32(len(buf)), true, dirChangesFilter, nil, &ov, 0)
+		if err != nil && err != windows.ERROR_IO_PENDING {
+			// The root was deleted or its share disconnected
+			w.send(&fsnotify.Event{Name: root, Op: fsnotify.Remove}, nil)
+			return
+		}
+		which, _ := windows.WaitForMultipleObjects([]windows.Handle{ioEvent, w.stop}, false, windows.INFINITE)
+		var n uint32
+		if which != windows.WAIT_OBJECT_0 {
+			windows.CancelIoEx(h, &ov)
+			windows.GetOverlappedResult(h, &ov, &n, true)
+			return
+		}
+		err = windows.GetOverlappedResult(h, &ov, &n, false)
+		switch {
+		case errors.Is(err, windows.ERROR_NOTIFY_ENUM_DIR), err == nil && n == 0:
+			// The buffer overflowed and changes were lost; a rescan catches up
+			w.send(nil, fsnotify.ErrEventOverflow)
+			continue
+		case err != nil:
+			w.send(&fsnotify.Event{Name: root, Op: fsnotify.Remove}, nil)
+			return
+		}
+		w.dispatch(root, buf[:n])
+	}
+}
+
+// dispatch converts a buffer of FILE_NOTIFY_INFORMATION records to events
+func (w *dirChangesWatcher) dispatch(root string, buf []byte) {
+	for offset := uint32(0); ; {
+		info := (*windows.FileNotifyInformation)(unsafe.Pointer(&buf[offset]))
+		name := windows.UTF16ToString(unsafe.Slice(&info.FileName, info.FileNameLength/2))
+		var op fsnotify.Op
+		switch info.Action {
+		case windows.FILE_ACTION_ADDED, windows.FILE_ACTION_RENAMED_NEW_NAME:
+			op = fsnotify.Create
+		case windows.FILE_ACTION_REMOVED:
+			op = fsnotify.Remove
+		case windows.FILE_ACTION_MODIFIED:
+			op = fsnotify.Write
+		case windows.FILE_ACTION_RENAMED_OLD_NAME:
+			op = fsnotify.Rename
+		}
+		if op != 0 {
+			w.send(&fsnotify.Event{Name: filepath.Join(root, name), Op: op}, nil)
+		}
+		if info.NextEntryOffset == 0 {
+			return
+		}
+		offset += info.NextEntryOffset
+		if offset >= uint32(len(buf)) {
+			return
+		}
+	}
+}
+
+// send delivers an event or error unless the watcher is closing
+func (w *dirChangesWatcher) send(ev *fsnotify.Event, err error) {
+	if ev != nil {
+		select {
+		case w.events <- *ev:
+		case <-w.done:
+		}
+		return
+	}
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+// Close stops every root and closes the channels
+func (w *dirChangesWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.done)
+	windows.SetEvent(w.stop)
+	w.wg.Wait()
+	windows.CloseHandle(w.stop)
+	close(w.events)
+	close(w.errors)
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// diskCheckInterval is how often the watched volume's free space is checked
+var diskCheckInterval = 30 * time.Second
+
+// lowDiskState tracks the low-space warning so it fires once per episode
+// rather than on every check
+type lowDiskState struct {
+	warned bool
+}
+
+// Check reports whether a warning should be sent now. A warning is due when
+// free space is below the threshold while a batch is uploading; it re-arms
+// once free space recovers.
+func (s *lowDiskState) Check(free uint64, thresholdGB int, uploading bool) bool {
+	if thresholdGB <= 0 {
+		return false
+	}
+	low := free < uint64(thresholdGB)<<30
+	if !low {
+		s.warned = false
+		return false
+	}
+	if !uploading || s.warned {
+		return false
+	}
+	s.warned = true
+	return true
+}
+
+// hasUploadingBatch reports whether any batch is still receiving files
+func hasUploadingBatch() bool {
+	batchesMu.RLock()
+	defer batchesMu.RUnlock()
+	for _, b := range batches {
+		if b.Status == "uploading" {
+			return true
+		}
+	}
+	return false
+}
+
+// formatDiskSpace formats free/total space for the Monitor tab
+func formatDiskSpace(free, total uint64) string {
+	return fmt.Sprintf("💾 可用空间: %s / %s", formatSize(int64(free)), formatSize(int64(total)))
+}
+
+// watchDiskSpace reports the free space of the volume holding path and warns
+// when it runs low during an upload, since full disks break uploads
+func watchDiskSpace(ctx context.Context, path string, onUpdate func(free, total uint64), app fyne.App) {
+	var state lowDiskState
+	check := func() {
+		free, total, err := diskUsage(path)
+		if err != nil {
+			return
+		}
+		onUpdate(free, total)
+		if state.Check(free, config.LowDiskWarnGB, hasUploadingBatch()) {
+			logEvent("磁盘空间不足: %s 可用", formatSize(int64(free)))
+			sendNotification(app, "FidruaWatch - 磁盘空间不足",
+				fmt.Sprintf("监控目录所在磁盘仅剩 %s，上传可能失败", formatSize(int64(free))))
+			playSound(SoundTypeStart)
+		}
+	}
+
+	check()
+	ticker := time.NewTicker(diskCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
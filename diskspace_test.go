@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestLowDiskState(t *testing.T) {
+	var s lowDiskState
+	const gb = uint64(1) << 30
+
+	if s.Check(5*gb, 10, false) {
+		t.Error("No warning expected while nothing is uploading")
+	}
+	if !s.Check(5*gb, 10, true) {
+		t.Error("Expected warning when low during an upload")
+	}
+	if s.Check(4*gb, 10, true) {
+		t.Error("Warning should fire once per low-space episode")
+	}
+	if s.Check(20*gb, 10, true) {
+		t.Error("No warning expected above the threshold")
+	}
+	if !s.Check(5*gb, 10, true) {
+		t.Error("Expected warning to re-arm after space recovered")
+	}
+	if s.Check(0, 0, true) {
+		t.Error("Threshold 0 disables warnings")
+	}
+}
+
+func TestDiskUsage(t *testing.T) {
+	free, total, err := diskUsage(t.TempDir())
+	if err != nil {
+		t.Fatalf("diskUsage() error: %v", err)
+	}
+	if total == 0 || free > total {
+		t.Errorf("Implausible disk usage: free %d, total %d", free, total)
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Monitoring profiles
+const (
+	profileDefault   = ""
+	profileDownloads = "downloads" // browser downloads: flat folder, temp-file promotion
+)
+
+var profileOptions = []struct {
+	Profile string
+	Label   string
+}{
+	{profileDefault, "普通上传"},
+	{profileDownloads, "下载管理"},
+	{profileIngest, "存储卡导入"},
+}
+
+// downloadTempSuffixes are written by browsers while a download is running
+// and renamed to the final name once it finishes
+var downloadTempSuffixes = []string{".crdownload", ".part", ".download", ".partial"}
+
+// applyDownloadsPreset tunes the settings for watching a Downloads folder
+func applyDownloadsPreset() {
+	config.Profile = profileDownloads
+	config.MonitorSubdirs = false
+	config.GroupDepth = 0
+	config.VideoEnabled = true
+	config.ImageEnabled = true
+	config.AudioEnabled = true
+	config.DocEnabled = true
+	config.ArchiveEnabled = true
+	if config.GroupTimeWindow <= 0 {
+		config.GroupTimeWindow = 60
+	}
+}
+
+// recentUploadingBatch returns the uploading batch with activity within the
+// grouping time window, so downloads arriving close together form one batch.
+// Caller must hold batchesMu.
+func recentUploadingBatch(now time.Time) *Batch {
+	window := time.Duration(config.GroupTimeWindow) * time.Second
+	if window <= 0 {
+		window = 60 * time.Second
+	}
+	var recent *Batch
+	for _, b := range batches {
+		if b.Status != "uploading" || now.Sub(b.LastTime) > window {
+			continue
+		}
+		if recent == nil || b.LastTime.After(recent.LastTime) {
+			recent = b
+		}
+	}
+	return recent
+}
+
+// isDownloadTempFile reports whether name is an in-progress browser download
+func isDownloadTempFile(name string) bool {
+	name = strings.ToLower(name)
+	for _, suffix := range downloadTempSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// pendingDownloads counts in-progress downloads in dir
+func pendingDownloads(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+	n := 0
+	for _, e := range entries {
+		if !e.IsDir() && isDownloadTempFile(e.Name()) {
+			n++
+		}
+	}
+	return n
+}
+
+// holdForDownloads keeps a downloads batch open while the browser is still
+// writing temp files that will be promoted to their final names.
+// Caller must hold batchesMu.
+func holdForDownloads(b *Batch) bool {
+	return config.Profile == profileDownloads && pendingDownloads(b.Folder) > 0
+}
+
+// moveFile renames src to dst, copying across volumes when rename fails.
+// Copies keep to the transfer rate and job limits.
+func moveFile(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	release, err := transferJobs.Acquire(context.Background())
+	if err != nil {
+		return err
+	}
+	defer release()
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, limitReader(context.Background(), in)); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+	in.Close()
+	return os.Remove(src)
+}
+
+// moveBatchFiles moves the files of a completed batch into dest, e.g. from
+// Downloads into a project folder, and points the batch at its new location
+func moveBatchFiles(b *Batch, dest string) error {
+	batchesMu.RLock()
+	folder := b.Folder
+	files := append([]string(nil), b.Files...)
+	batchesMu.RUnlock()
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	moved := make([]string, 0, len(files))
+	for _, f := range files {
+		target := filepath.Join(dest, filepath.Base(f))
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Errorf("目标文件已存在: %s", target)
+		}
+		release := claimOwnOutput(target)
+		err := moveFile(filepath.Join(folder, f), target)
+		release()
+		if err != nil {
+			return fmt.Errorf("移动 %s 失败: %v", f, err)
+		}
+		moved = append(moved, filepath.Base(f))
+	}
+
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	sizes := make(map[string]int64, len(moved))
+	for i, f := range files {
+		sizes[moved[i]] = b.FileSizes[f]
+	}
+	b.Folder = dest
+	b.Files = moved
+	b.FileSizes = sizes
+	logEvent("批次 %s 已移动到 %s", b.ID, dest)
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDownloadsProfileGroupsByTime(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "report.pdf"), []byte("pdf"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "photo.jpg"), []byte("jpg"), 0644)
+
+	origConfig := config
+	origBatches := batches
+	defer func() {
+		config = origConfig
+		batches = origBatches
+	}()
+
+	config = Config{}
+	applyDownloadsPreset()
+	batches = make(map[string]*Batch)
+
+	addFileToBatch(filepath.Join(tmpDir, "report.pdf"))
+	if isNew := addFileToBatch(filepath.Join(tmpDir, "photo.jpg")); isNew {
+		t.Error("Files arriving within the window should share a batch")
+	}
+	if len(batches) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(batches))
+	}
+
+	var b *Batch
+	for _, batch := range batches {
+		b = batch
+	}
+
+	// An in-progress browser download keeps the batch open
+	os.WriteFile(filepath.Join(tmpDir, "movie.mp4.crdownload"), []byte("x"), 0644)
+	if !holdCompletion(b) {
+		t.Error("Expected completion to be held while a download is running")
+	}
+	os.Remove(filepath.Join(tmpDir, "movie.mp4.crdownload"))
+	if holdCompletion(b) {
+		t.Error("Expected completion once downloads finished")
+	}
+
+	dest := filepath.Join(t.TempDir(), "Project")
+	if err := moveBatchFiles(b, dest); err != nil {
+		t.Fatalf("moveBatchFiles() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "photo.jpg")); err != nil {
+		t.Errorf("photo.jpg not moved: %v", err)
+	}
+	if b.Folder != dest || b.FileSizes["report.pdf"] != 3 {
+		t.Errorf("Batch not updated after move: %+v", b)
+	}
+}
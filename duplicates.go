@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// seenFiles maps file fingerprints of completed batches to the batch they
+// were first seen in. It is loaded from history at startup, so completing a
+// batch never reads the history while holding batchesMu.
+var (
+	seenFilesMu sync.Mutex
+	seenFiles   map[string]string
+)
+
+// fileFingerprint identifies a file by base name and size, so the same
+// footage re-delivered into another folder is recognized
+func fileFingerprint(name string, size int64) string {
+	return fmt.Sprintf("%s|%d", pathKey(filepath.Base(name)), size)
+}
+
+// loadSeenFiles fills seenFiles from the history store
+func loadSeenFiles() {
+	records, _, err := loadHistory()
+	if err != nil {
+		logEvent("读取历史记录失败: %v", err)
+	}
+	seenFilesMu.Lock()
+	defer seenFilesMu.Unlock()
+	seenFiles = make(map[string]string)
+	// Oldest first so the earliest batch wins
+	for i := len(records) - 1; i >= 0; i-- {
+		rememberRecordFiles(records[i])
+	}
+}
+
+// forgetSeenFiles empties seenFiles, once the history is cleared
+func forgetSeenFiles() {
+	seenFilesMu.Lock()
+	defer seenFilesMu.Unlock()
+	seenFiles = make(map[string]string)
+}
+
+// rememberRecordFiles adds a record's files to seenFiles. Caller must hold seenFilesMu.
+func rememberRecordFiles(rec HistoryRecord) {
+	for name, size := range rec.FileSizes {
+		if size <= 0 {
+			continue // empty files would all look identical
+		}
+		fp := fileFingerprint(name, size)
+		if _, ok := seenFiles[fp]; !ok {
+			seenFiles[fp] = rec.ID
+		}
+	}
+}
+
+// checkDuplicates returns the files of a just-completed batch that match a
+// file of an earlier batch by name and size, then remembers the batch's own
+// files. Caller must hold batchesMu.
+func checkDuplicates(b *Batch) []string {
+	seenFilesMu.Lock()
+	defer seenFilesMu.Unlock()
+	if seenFiles == nil {
+		seenFiles = make(map[string]string)
+	}
+
+	var dups []string
+	for _, f := range b.Files {
+		size := b.FileSizes[f]
+		if size <= 0 {
+			continue
+		}
+		if id, ok := seenFiles[fileFingerprint(f, size)]; ok && id != b.ID {
+			dups = append(dups, f)
+		}
+	}
+	rememberRecordFiles(historyRecordFor(b))
+	return dups
+}
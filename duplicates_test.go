@@ -0,0 +1,59 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckDuplicates(t *testing.T) {
+	savedConfig, savedPath := config, historyPath
+	defer func() {
+		config, historyPath = savedConfig, savedPath
+		seenFiles = nil
+	}()
+	historyPath = filepath.Join(t.TempDir(), "history.jsonl")
+	config.SaveHistory = true
+
+	// An earlier delivery in history
+	if err := appendHistory(HistoryRecord{
+		ID:        "old",
+		Folder:    "/in/day1",
+		FileSizes: map[string]int64{"clip.mp4": 1000, "empty.txt": 0},
+		EndTime:   time.Now(),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	loadSeenFiles()
+
+	b := &Batch{
+		ID:        "new",
+		Folder:    "/in/day2",
+		Files:     []string{"sub/clip.mp4", "other.mp4", "empty.txt"},
+		FileSizes: map[string]int64{"sub/clip.mp4": 1000, "other.mp4": 1000, "empty.txt": 0},
+	}
+	dups := checkDuplicates(b)
+	if len(dups) != 1 || dups[0] != "sub/clip.mp4" {
+		t.Errorf("dups = %v, want [sub/clip.mp4]", dups)
+	}
+
+	// Same name, different size is not a duplicate
+	c := &Batch{
+		ID:        "third",
+		Files:     []string{"clip.mp4"},
+		FileSizes: map[string]int64{"clip.mp4": 999},
+	}
+	if dups := checkDuplicates(c); len(dups) != 0 {
+		t.Errorf("size mismatch reported as duplicate: %v", dups)
+	}
+
+	// Files of completed batches are remembered in-session
+	d := &Batch{
+		ID:        "fourth",
+		Files:     []string{"other.mp4"},
+		FileSizes: map[string]int64{"other.mp4": 1000},
+	}
+	if dups := checkDuplicates(d); len(dups) != 1 {
+		t.Errorf("dups = %v, want other.mp4 from batch new", dups)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// eventLogPath is the JSON Lines export of batch events, one object per
+// line in the API event format, for log shippers like Logstash or Vector
+var eventLogPath string
+
+// eventLogWriter appends events to eventLogPath. The file is rotated when
+// the day changes or it would grow past config.EventLogMaxMB; rotated files
+// are named after the day, e.g. events-2024-03-01.jsonl, then
+// events-2024-03-01.1.jsonl, and only the newest config.EventLogKeep are
+// kept.
+type eventLogWriter struct {
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	day    string // dayKey of the events in the open file
+	failed bool   // the last write failed and was logged
+}
+
+var eventLog = &eventLogWriter{}
+
+// Write appends one event
+func (l *eventLogWriter) Write(ev batchEvent) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		if err := l.open(ev.Time); err != nil {
+			return err
+		}
+	}
+	maxBytes := int64(config.EventLogMaxMB) << 20
+	if l.size > 0 && (l.day != dayKey(ev.Time) || maxBytes > 0 && l.size+int64(len(data)) > maxBytes) {
+		if err := l.rotate(ev.Time); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(data)
+	l.size += int64(n)
+	return err
+}
+
+// open opens or creates the file; an existing one belongs to the day it
+// was last written. Caller must hold l.mu.
+func (l *eventLogWriter) open(now time.Time) error {
+	if err := os.MkdirAll(filepath.Dir(eventLogPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(eventLogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size, l.day = f, info.Size(), dayKey(now)
+	if l.size > 0 {
+		l.day = dayKey(info.ModTime())
+	}
+	return nil
+}
+
+// rotate moves the current file aside and starts a new one. Caller must
+// hold l.mu.
+func (l *eventLogWriter) rotate(now time.Time) error {
+	l.f.Close()
+	l.f = nil
+	if err := os.Rename(eventLogPath, rotatedEventLogPath(l.day)); err != nil {
+		return err
+	}
+	pruneEventLogs(config.EventLogKeep)
+	return l.open(now)
+}
+
+// Close closes the file, e.g. when the export is turned off
+func (l *eventLogWriter) Close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f != nil {
+		l.f.Close()
+		l.f = nil
+	}
+}
+
+// rotatedEventLogPath returns the first free name for the events of day
+func rotatedEventLogPath(day string) string {
+	base := strings.TrimSuffix(eventLogPath, filepath.Ext(eventLogPath))
+	path := fmt.Sprintf("%s-%s.jsonl", base, day)
+	for i := 1; ; i++ {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path
+		}
+		path = fmt.Sprintf("%s-%s.%d.jsonl", base, day, i)
+	}
+}
+
+// pruneEventLogs removes all but the newest keep rotated files; 0 keeps
+// them all
+func pruneEventLogs(keep int) {
+	if keep <= 0 {
+		return
+	}
+	base := strings.TrimSuffix(eventLogPath, filepath.Ext(eventLogPath))
+	rotated, _ := filepath.Glob(base + "-*.jsonl")
+	if len(rotated) <= keep {
+		return
+	}
+	modTimes := make(map[string]time.Time)
+	for _, path := range rotated {
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	sort.Slice(rotated, func(i, j int) bool { return modTimes[rotated[i]].After(modTimes[rotated[j]]) })
+	for _, path := range rotated[keep:] {
+		if err := os.Remove(path); err != nil {
+			logEvent("删除旧事件日志失败: %v", err)
+		}
+	}
+}
+
+// exportEvent writes an event to the export file when it is enabled.
+// Failures are logged once until a write succeeds again.
+func exportEvent(ev batchEvent) {
+	if !config.EventLog {
+		return
+	}
+	err := eventLog.Write(ev)
+	eventLog.mu.Lock()
+	defer eventLog.mu.Unlock()
+	if err != nil && !eventLog.failed {
+		logEvent("写入事件日志失败: %v", err)
+	}
+	eventLog.failed = err != nil
+}
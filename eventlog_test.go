@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventLog(t *testing.T) {
+	origConfig, origPath := config, eventLogPath
+	dir := t.TempDir()
+	eventLogPath = filepath.Join(dir, "events.jsonl")
+	config = defaultConfig()
+	config.EventLog = true
+	config.EventLogMaxMB = 0
+	config.EventLogKeep = 2
+	defer func() {
+		eventLog.Close()
+		config, eventLogPath = origConfig, origPath
+	}()
+
+	day := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	ev := func(typ string, at time.Time) batchEvent {
+		return batchEvent{Type: typ, Time: at, Batch: apiBatch{ID: "b1", Folder: "/in/b1"}}
+	}
+	exportEvent(ev(eventCreated, day))
+	exportEvent(ev(eventCompleted, day.Add(time.Minute)))
+
+	f, err := os.Open(eventLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var got batchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &got); err != nil {
+			t.Fatalf("Line %q is not JSON: %v", scanner.Text(), err)
+		}
+		types = append(types, got.Type)
+	}
+	f.Close()
+	if len(types) != 2 || types[0] != eventCreated || types[1] != eventCompleted {
+		t.Errorf("Logged events = %v", types)
+	}
+
+	// A new day starts a new file
+	exportEvent(ev(eventSigned, day.AddDate(0, 0, 1)))
+	if _, err := os.Stat(filepath.Join(dir, "events-2024-03-01.jsonl")); err != nil {
+		t.Errorf("Previous day not rotated: %v", err)
+	}
+
+	// So does the size limit, with numbered names for the same day
+	config.EventLogMaxMB = 1
+	big := ev(eventStalled, day.AddDate(0, 0, 1))
+	big.Batch.Folder = strings.Repeat("x", 600<<10)
+	exportEvent(big)
+	exportEvent(big)
+	if _, err := os.Stat(filepath.Join(dir, "events-2024-03-02.jsonl")); err != nil {
+		t.Errorf("Full file not rotated: %v", err)
+	}
+	exportEvent(big)
+	exportEvent(big)
+	rotated, _ := filepath.Glob(filepath.Join(dir, "events-*.jsonl"))
+	if len(rotated) != config.EventLogKeep {
+		t.Errorf("Kept %d rotated files %v, want %d", len(rotated), rotated, config.EventLogKeep)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Batch lifecycle event types
+const (
+	eventCreated   = "created"
+	eventStalled   = "stalled"
+	eventCompleted = "completed"
+	eventReviewed  = "reviewed"
+	eventSigned    = "signed"
+	eventRequeued  = "requeued"
+	eventDeleted   = "deleted"
+	eventRestored  = "restored"
+)
+
+// batchEventTypes lists every event type, for clients choosing a subset
+var batchEventTypes = []string{eventCreated, eventStalled, eventCompleted, eventReviewed,
+	eventSigned, eventRequeued, eventDeleted, eventRestored}
+
+// batchEvent is a batch lifecycle change pushed to API subscribers
+type batchEvent struct {
+	Type  string    `json:"type"`
+	Time  time.Time `json:"time"`
+	Batch apiBatch  `json:"batch"`
+}
+
+// eventHub fans batch events out to subscribers. Slow subscribers miss
+// events rather than blocking batch processing.
+type eventHub struct {
+	mu   sync.Mutex
+	subs map[chan batchEvent]struct{}
+}
+
+var batchEvents = &eventHub{subs: make(map[chan batchEvent]struct{})}
+
+// Subscribe returns a channel of events and a function to unsubscribe
+func (h *eventHub) Subscribe() (<-chan batchEvent, func()) {
+	ch := make(chan batchEvent, 64)
+	h.mu.Lock()
+	h.subs[ch] = struct{}{}
+	h.mu.Unlock()
+	return ch, func() {
+		h.mu.Lock()
+		delete(h.subs, ch)
+		h.mu.Unlock()
+	}
+}
+
+// Publish sends an event to every subscriber without blocking
+func (h *eventHub) Publish(ev batchEvent) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+// publishBatchEvent announces a lifecycle change and writes it to the event
+// export; completions and stalls also go to the system log. Caller must
+// hold batchesMu.
+func publishBatchEvent(typ string, b *Batch) {
+	ev := batchEvent{Type: typ, Time: time.Now(), Batch: toAPIBatch(b)}
+	batchEvents.Publish(ev)
+	exportEvent(ev)
+	switch typ {
+	case eventCompleted:
+		sysLogBatch(sysLogInfo, b, "批次上传完成: %d 个文件, %s", len(b.Files), formatSize(b.TotalSize))
+	case eventStalled:
+		sysLogBatch(sysLogWarning, b, "上传停滞: %d 分钟无新数据", config.StallMinutes)
+	}
+}
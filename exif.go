@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// exifReadLimit bounds how much of an image is read looking for EXIF data;
+// the APP1 segment sits at the start of a JPEG
+const exifReadLimit = 256 * 1024
+
+// ExifInfo is the capture metadata of one image
+type ExifInfo struct {
+	Taken  time.Time `json:"taken"`
+	Camera string    `json:"camera,omitempty"`
+}
+
+// ExifSummary is the capture date range and cameras of an image batch
+type ExifSummary struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Cameras []string  `json:"cameras,omitempty"`
+}
+
+// String formats the summary for the batch card,
+// e.g. "2024-03-01 ~ 2024-03-03 · Canon EOS R5"
+func (s *ExifSummary) String() string {
+	var parts []string
+	if !s.From.IsZero() {
+		dates := formatDate(s.From)
+		if to := formatDate(s.To); to != dates {
+			dates += " ~ " + to
+		}
+		parts = append(parts, dates)
+	}
+	if len(s.Cameras) > 0 {
+		parts = append(parts, strings.Join(s.Cameras, ", "))
+	}
+	return strings.Join(parts, " · ")
+}
+
+var errNoExif = errors.New("no EXIF data")
+
+// readExif reads the capture date and camera model of a JPEG file
+func readExif(path string) (ExifInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return ExifInfo{}, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, exifReadLimit))
+	if err != nil {
+		return ExifInfo{}, err
+	}
+	return parseJPEGExif(data)
+}
+
+// parseJPEGExif finds the EXIF APP1 segment of a JPEG and parses it
+func parseJPEGExif(data []byte) (ExifInfo, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return ExifInfo{}, errNoExif
+	}
+	for i := 2; i+4 <= len(data); {
+		if data[i] != 0xFF {
+			return ExifInfo{}, errNoExif
+		}
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // image data starts, no EXIF before it
+			break
+		}
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + size
+		if size < 2 || end > len(data) {
+			break
+		}
+		if seg := data[i+4 : end]; marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return parseTIFFExif(seg[6:])
+		}
+		i = end
+	}
+	return ExifInfo{}, errNoExif
+}
+
+// EXIF tags used
+const (
+	tagModel            = 0x0110
+	tagMake             = 0x010F
+	tagDateTime         = 0x0132
+	tagExifIFD          = 0x8769
+	tagDateTimeOriginal = 0x9003
+)
+
+// parseTIFFExif reads Make/Model/DateTime from IFD0 and DateTimeOriginal
+// from the EXIF sub-IFD of a TIFF structure
+func parseTIFFExif(tiff []byte) (ExifInfo, error) {
+	if len(tiff) < 8 {
+		return ExifInfo{}, errNoExif
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return ExifInfo{}, errNoExif
+	}
+
+	ifd0 := readIFD(tiff, order, int(order.Uint32(tiff[4:])))
+	if ifd0 == nil {
+		return ExifInfo{}, errNoExif
+	}
+	maker := ifdString(tiff, order, ifd0[tagMake])
+	model := ifdString(tiff, order, ifd0[tagModel])
+	taken := ifdString(tiff, order, ifd0[tagDateTime])
+	if e, ok := ifd0[tagExifIFD]; ok {
+		if sub := readIFD(tiff, order, int(order.Uint32(e[8:]))); sub != nil {
+			if t := ifdString(tiff, order, sub[tagDateTimeOriginal]); t != "" {
+				taken = t
+			}
+		}
+	}
+
+	var info ExifInfo
+	// Models usually repeat the make ("Canon EOS R5"), add it otherwise
+	info.Camera = model
+	if brand := strings.Fields(maker); len(brand) > 0 && !strings.HasPrefix(strings.ToLower(model), strings.ToLower(brand[0])) {
+		info.Camera = strings.TrimSpace(maker + " " + model)
+	}
+	if t, err := time.ParseInLocation("2006:01:02 15:04:05", taken, time.Local); err == nil {
+		info.Taken = t
+	}
+	if info.Taken.IsZero() && info.Camera == "" {
+		return info, errNoExif
+	}
+	return info, nil
+}
+
+// readIFD returns the 12-byte entries of the IFD at offset, by tag
+func readIFD(tiff []byte, order binary.ByteOrder, offset int) map[uint16][]byte {
+	if offset < 8 || offset+2 > len(tiff) {
+		return nil
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	entries := make(map[uint16][]byte, n)
+	for i := 0; i < n; i++ {
+		start := offset + 2 + i*12
+		if start+12 > len(tiff) {
+			break
+		}
+		entry := tiff[start : start+12]
+		entries[order.Uint16(entry)] = entry
+	}
+	return entries
+}
+
+// ifdString returns the value of an ASCII IFD entry
+func ifdString(tiff []byte, order binary.ByteOrder, entry []byte) string {
+	if len(entry) != 12 || order.Uint16(entry[2:]) != 2 { // type 2 = ASCII
+		return ""
+	}
+	count := int(order.Uint32(entry[4:]))
+	var value []byte
+	if count <= 4 {
+		value = entry[8 : 8+count]
+	} else {
+		off := int(order.Uint32(entry[8:]))
+		if off < 0 || off+count > len(tiff) {
+			return ""
+		}
+		value = tiff[off : off+count]
+	}
+	return strings.TrimSpace(strings.TrimRight(string(value), "\x00"))
+}
+
+// summarizeExif builds the date range and camera list of an image batch,
+// nil when no image had EXIF data
+func summarizeExif(infos []ExifInfo) *ExifSummary {
+	var s *ExifSummary
+	seen := make(map[string]bool)
+	for _, info := range infos {
+		if s == nil {
+			s = &ExifSummary{}
+		}
+		if !info.Taken.IsZero() {
+			if s.From.IsZero() || info.Taken.Before(s.From) {
+				s.From = info.Taken
+			}
+			if info.Taken.After(s.To) {
+				s.To = info.Taken
+			}
+		}
+		if info.Camera != "" && !seen[info.Camera] {
+			seen[info.Camera] = true
+			s.Cameras = append(s.Cameras, info.Camera)
+		}
+	}
+	return s
+}
+
+// readBatchExif reads EXIF data of the JPEG images of a completed batch and
+// stores the summary on the batch and in history
+func readBatchExif(b *Batch, updateUI func()) {
+	batchesMu.RLock()
+	folder := b.Folder
+	var images []string
+	for _, f := range b.Files {
+		if hasExt(f, []string{".jpg", ".jpeg"}) {
+			images = append(images, f)
+		}
+	}
+	batchesMu.RUnlock()
+	if len(images) == 0 {
+		return
+	}
+
+	var infos []ExifInfo
+	for _, f := range images {
+		if info, err := readExif(filepath.Join(folder, f)); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	summary := summarizeExif(infos)
+	if summary == nil {
+		return
+	}
+
+	batchesMu.Lock()
+	b.Exif = summary
+	if b.Status != "uploading" {
+		recordHistory(b)
+	}
+	batchesMu.Unlock()
+	updateUI()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// buildTestJPEG returns a minimal JPEG with an EXIF segment holding make,
+// model and DateTimeOriginal, in little-endian TIFF layout
+func buildTestJPEG(maker, model, taken string) []byte {
+	le := binary.LittleEndian
+	var tiff bytes.Buffer
+	tiff.WriteString("II*\x00")
+	binary.Write(&tiff, le, uint32(8))
+
+	strs := []string{maker + "\x00", model + "\x00"}
+	// IFD0: 3 entries, next-IFD offset, then string data, then the EXIF IFD
+	ifd0Size := 2 + 3*12 + 4
+	dataOff := 8 + ifd0Size
+	exifOff := dataOff + len(strs[0]) + len(strs[1])
+
+	entry := func(tag, typ uint16, count, value uint32) {
+		binary.Write(&tiff, le, tag)
+		binary.Write(&tiff, le, typ)
+		binary.Write(&tiff, le, count)
+		binary.Write(&tiff, le, value)
+	}
+	binary.Write(&tiff, le, uint16(3))
+	entry(tagMake, 2, uint32(len(strs[0])), uint32(dataOff))
+	entry(tagModel, 2, uint32(len(strs[1])), uint32(dataOff+len(strs[0])))
+	entry(tagExifIFD, 4, 1, uint32(exifOff))
+	binary.Write(&tiff, le, uint32(0))
+	tiff.WriteString(strs[0] + strs[1])
+
+	takenStr := taken + "\x00"
+	binary.Write(&tiff, le, uint16(1))
+	entry(tagDateTimeOriginal, 2, uint32(len(takenStr)), uint32(exifOff+2+12+4))
+	binary.Write(&tiff, le, uint32(0))
+	tiff.WriteString(takenStr)
+
+	seg := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	var jpg bytes.Buffer
+	jpg.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&jpg, binary.BigEndian, uint16(len(seg)+2))
+	jpg.Write(seg)
+	jpg.Write([]byte{0xFF, 0xD9})
+	return jpg.Bytes()
+}
+
+func TestParseJPEGExif(t *testing.T) {
+	info, err := parseJPEGExif(buildTestJPEG("Canon", "Canon EOS R5", "2024:03:01 10:20:30"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Camera != "Canon EOS R5" {
+		t.Errorf("Camera = %q", info.Camera)
+	}
+	want := time.Date(2024, 3, 1, 10, 20, 30, 0, time.Local)
+	if !info.Taken.Equal(want) {
+		t.Errorf("Taken = %v, want %v", info.Taken, want)
+	}
+
+	info, err = parseJPEGExif(buildTestJPEG("SONY", "ILCE-7M4", "2024:03:02 08:00:00"))
+	if err != nil || info.Camera != "SONY ILCE-7M4" {
+		t.Errorf("make not prefixed: %q, %v", info.Camera, err)
+	}
+
+	if _, err := parseJPEGExif([]byte{0xFF, 0xD8, 0xFF, 0xD9}); err == nil {
+		t.Error("expected an error for a JPEG without EXIF")
+	}
+	if _, err := parseJPEGExif([]byte("not a jpeg")); err == nil {
+		t.Error("expected an error for non-JPEG data")
+	}
+}
+
+func TestSummarizeExif(t *testing.T) {
+	useLocale(t, "zh-CN")
+	day := func(d int) time.Time { return time.Date(2024, 3, d, 12, 0, 0, 0, time.Local) }
+	s := summarizeExif([]ExifInfo{
+		{Taken: day(2), Camera: "Canon EOS R5"},
+		{Taken: day(1), Camera: "Canon EOS R5"},
+		{Taken: day(3)},
+	})
+	if got := s.String(); got != "2024-03-01 ~ 2024-03-03 · Canon EOS R5" {
+		t.Errorf("String() = %q", got)
+	}
+	if summarizeExif(nil) != nil {
+		t.Error("summary without EXIF data should be nil")
+	}
+}
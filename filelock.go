@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// fileLockTimeout bounds the writer check of one batch, e.g. an lsof that
+// hangs on a stale network mount
+const fileLockTimeout = 5 * time.Second
+
+// lockCheck is a batch whose files are checked for writers, copied out so
+// the check runs without batchesMu
+type lockCheck struct {
+	id     string
+	folder string
+	files  []string
+}
+
+// refreshLockedFiles checks the local batches due to complete for files
+// another process still has open for writing and stores the result in
+// b.Locked. The check can take a while, so it runs without batchesMu; take
+// the lock only afterwards.
+func refreshLockedFiles(ctx context.Context, now time.Time) {
+	if !config.CheckFileLocks {
+		return
+	}
+	var checks []lockCheck
+	batchesMu.RLock()
+	for _, b := range batches {
+		if b.Status != "uploading" || isRemoteWatchPath(b.Folder) || now.Sub(b.LastTime) <= batchTimeout(b) {
+			continue
+		}
+		checks = append(checks, lockCheck{id: b.ID, folder: b.Folder, files: append([]string(nil), b.Files...)})
+	}
+	batchesMu.RUnlock()
+
+	for _, c := range checks {
+		paths := make([]string, len(c.files))
+		for i, f := range c.files {
+			paths[i] = filepath.Join(c.folder, f)
+		}
+		checkCtx, cancel := context.WithTimeout(ctx, fileLockTimeout)
+		busy := filesInUse(checkCtx, paths)
+		cancel()
+		var locked []string
+		for _, p := range busy {
+			if rel, err := filepath.Rel(c.folder, p); err == nil {
+				locked = append(locked, rel)
+			}
+		}
+		sort.Strings(locked)
+
+		batchesMu.Lock()
+		if b, ok := batches[c.id]; ok {
+			if len(locked) > 0 && len(b.Locked) == 0 {
+				logEvent("批次 %s 有 %d 个文件仍被其他程序占用, 暂不完成: %s", b.ID, len(locked), b.Folder)
+			}
+			b.Locked = locked
+			b.LockCheck = now
+		}
+		batchesMu.Unlock()
+	}
+}
+
+// holdForLockedFiles holds completion while another process still has files
+// of the batch open for writing, e.g. an uploader that paused between
+// chunks, as last found by refreshLockedFiles. A batch not yet checked since
+// it went idle waits for the next check. Only checked for local folders and
+// when config.CheckFileLocks is on. Caller must hold batchesMu.
+func holdForLockedFiles(b *Batch) bool {
+	if !config.CheckFileLocks || isRemoteWatchPath(b.Folder) {
+		b.Locked = nil
+		return false
+	}
+	if b.LockCheck.Sub(b.LastTime) <= batchTimeout(b) {
+		return true
+	}
+	return len(b.Locked) > 0
+}
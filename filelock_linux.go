@@ -0,0 +1,83 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procDir is where process information is read from
+var procDir = "/proc"
+
+// filesInUse returns the paths some process holds open for writing, found
+// by scanning the open descriptors in /proc. Processes of other users are
+// not visible, which covers the usual case of an upload server running as
+// the same user. This process counts too; it only reads batch files. The
+// scan stops with what it found once ctx is done.
+func filesInUse(ctx context.Context, paths []string) []string {
+	want := make(map[string]string, len(paths))
+	for _, p := range paths {
+		resolved := p
+		if r, err := filepath.EvalSymlinks(p); err == nil {
+			resolved = r
+		}
+		want[resolved] = p
+	}
+	procs, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil
+	}
+	found := make(map[string]bool)
+	for _, proc := range procs {
+		if ctx.Err() != nil {
+			break
+		}
+		if _, err := strconv.Atoi(proc.Name()); err != nil {
+			continue
+		}
+		fdDir := filepath.Join(procDir, proc.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue // gone, or not ours to look at
+		}
+		for _, fd := range fds {
+			target, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			orig, ok := want[target]
+			if err != nil || !ok || found[orig] {
+				continue
+			}
+			if openedForWriting(filepath.Join(procDir, proc.Name(), "fdinfo", fd.Name())) {
+				found[orig] = true
+			}
+		}
+	}
+	var busy []string
+	for _, p := range paths {
+		if found[p] {
+			busy = append(busy, p)
+		}
+	}
+	return busy
+}
+
+// openedForWriting reads the open flags of a descriptor from its fdinfo
+func openedForWriting(fdinfo string) bool {
+	f, err := os.Open(fdinfo)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), "flags:"); ok {
+			flags, err := strconv.ParseUint(strings.TrimSpace(v), 8, 64)
+			return err == nil && flags&uint64(os.O_WRONLY|os.O_RDWR) != 0
+		}
+	}
+	return false
+}
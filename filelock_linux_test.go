@@ -0,0 +1,58 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHoldForLockedFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "a.mp4"), []byte("data"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "b.mp4"), []byte("data"), 0644)
+
+	origConfig, origBatches := config, batches
+	defer func() { config, batches = origConfig, origBatches }()
+
+	b := &Batch{ID: "test", Folder: tmpDir, Files: []string{"a.mp4", "b.mp4"}, Status: "uploading", LastTime: time.Now().Add(-time.Hour)}
+	batches = map[string]*Batch{b.ID: b}
+	config = Config{CheckFileLocks: false, CompletionTimeout: 30}
+
+	writer, err := os.OpenFile(filepath.Join(tmpDir, "b.mp4"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if holdForLockedFiles(b) {
+		t.Error("Expected no hold while the lock check is off")
+	}
+
+	config.CheckFileLocks = true
+	if !holdForLockedFiles(b) {
+		t.Error("Expected a hold until the files were checked")
+	}
+	refreshLockedFiles(context.Background(), time.Now())
+	if !holdForLockedFiles(b) {
+		t.Fatal("Expected completion to be held while b.mp4 is open for writing")
+	}
+	if len(b.Locked) != 1 || b.Locked[0] != "b.mp4" {
+		t.Errorf("Locked = %v, want [b.mp4]", b.Locked)
+	}
+
+	writer.Close()
+	reader, err := os.Open(filepath.Join(tmpDir, "a.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	refreshLockedFiles(context.Background(), time.Now())
+	if holdForLockedFiles(b) {
+		t.Errorf("Readers should not hold completion, Locked = %v", b.Locked)
+	}
+	if b.Locked != nil {
+		t.Errorf("Locked = %v after release, want nil", b.Locked)
+	}
+}
@@ -0,0 +1,61 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// lsofArgBytes caps the paths passed to one lsof run, well under ARG_MAX
+const lsofArgBytes = 64 << 10
+
+// filesInUse returns the paths some process holds open for writing, as
+// reported by lsof. Without lsof, or once ctx is done, nothing more is
+// considered in use.
+func filesInUse(ctx context.Context, paths []string) []string {
+	if len(paths) == 0 {
+		return nil
+	}
+	var out []byte
+	for start := 0; start < len(paths); {
+		end, size := start, 0
+		for end < len(paths) && (end == start || size+len(paths[end])+1 <= lsofArgBytes) {
+			size += len(paths[end]) + 1
+			end++
+		}
+		// lsof exits 1 when none of the files is open
+		chunk, _ := exec.CommandContext(ctx, "lsof", append([]string{"-F", "an", "--"}, paths[start:end]...)...).Output()
+		out = append(out, chunk...)
+		start = end
+	}
+	want := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		want[p] = true
+	}
+	found := make(map[string]bool)
+	writing := false
+	for _, line := range strings.Split(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		switch line[0] {
+		case 'f':
+			writing = false
+		case 'a':
+			writing = strings.ContainsAny(line[1:], "wu")
+		case 'n':
+			if writing && want[line[1:]] {
+				found[line[1:]] = true
+			}
+		}
+	}
+	var busy []string
+	for _, p := range paths {
+		if found[p] {
+			busy = append(busy, p)
+		}
+	}
+	return busy
+}
@@ -0,0 +1,34 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+
+	"golang.org/x/sys/windows"
+)
+
+// filesInUse returns the paths another process holds open for writing.
+// Opening a file while only sharing reads fails with a sharing violation
+// as long as any writer has it open. The check stops once ctx is done.
+func filesInUse(ctx context.Context, paths []string) []string {
+	var busy []string
+	for _, p := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		name, err := windows.UTF16PtrFromString(extendedLengthPath(p))
+		if err != nil {
+			continue
+		}
+		h, err := windows.CreateFile(name, windows.GENERIC_READ, windows.FILE_SHARE_READ, nil,
+			windows.OPEN_EXISTING, windows.FILE_ATTRIBUTE_NORMAL, 0)
+		switch err {
+		case nil:
+			windows.CloseHandle(h)
+		case windows.ERROR_SHARING_VIOLATION, windows.ERROR_LOCK_VIOLATION:
+			busy = append(busy, p)
+		}
+	}
+	return busy
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// filterStep is one rule the filter tester checked
+type filterStep struct {
+	Rule   string
+	Result string
+	Pass   bool // false when the rule keeps the file from being tracked
+}
+
+// filterVerdict is how the current settings treat a path, step by step in
+// the order file events go through them
+type filterVerdict struct {
+	Path     string
+	Tracked  bool
+	Category string // file type of a tracked file
+	Folder   string // the batch folder it groups under
+	Steps    []filterStep
+}
+
+// explainPath runs path through the watch rules without touching any batch.
+// It answers for the saved settings and the current watch folder.
+func explainPath(path string) filterVerdict {
+	path = filepath.Clean(path)
+	v := filterVerdict{Path: path, Tracked: true}
+	step := func(rule string, pass bool, format string, args ...interface{}) {
+		v.Steps = append(v.Steps, filterStep{Rule: rule, Result: fmt.Sprintf(format, args...), Pass: pass})
+		v.Tracked = v.Tracked && pass
+	}
+
+	dir := filepath.Dir(path)
+	switch rel, err := filepath.Rel(filepath.Clean(monitorPath), dir); {
+	case monitorPath == "":
+		step("监控文件夹", true, "未选择, 以下按文件所在文件夹判断")
+	case err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)):
+		step("监控文件夹", false, "不在 %s 内", displayWindowsPath(monitorPath))
+	case rel != "." && !config.MonitorSubdirs:
+		step("监控文件夹", false, "位于子文件夹 %s, 但未开启监控子文件夹", rel)
+	default:
+		step("监控文件夹", true, "位于 %s 内", displayWindowsPath(monitorPath))
+	}
+
+	name := filepath.Base(path)
+	switch pattern, tool := tempFilePattern(path), detectUploader(path); {
+	case strings.HasPrefix(name, selfTestPrefix):
+		step("临时文件", false, "自检测试文件")
+	case pattern != "":
+		step("临时文件", false, "文件名含 %q, 视为临时文件", pattern)
+	case tool != "":
+		step("临时文件", true, "%s 的传输临时文件, 只用于识别上传工具", tool)
+	default:
+		step("临时文件", true, "不是临时文件")
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case enabledExtSet()[ext]:
+		v.Category = fileCategory(name)
+		step("文件类型", true, "%s 已启用 (%s)", ext, v.Category)
+	case config.PairSidecars && isSidecar(path):
+		v.Category = otherCategory
+		step("文件类型", true, "附属文件, 与同名文件一起计入")
+	case config.VerifyChecksums && isSumsFile(path):
+		v.Category = otherCategory
+		step("文件类型", true, "校验文件, 批次完成后用于校验")
+	case ext == "":
+		step("文件类型", false, "没有扩展名")
+	default:
+		step("文件类型", false, "%s 未在文件类型中启用", ext)
+	}
+
+	switch pattern := excludeMatch(path); {
+	case pattern != "":
+		step("排除规则", false, "匹配 %q", pattern)
+	case strings.TrimSpace(config.ExcludePatterns) == "":
+		step("排除规则", true, "未设置排除规则")
+	default:
+		step("排除规则", true, "未匹配任何排除规则")
+	}
+
+	if isOwnOutput(path) {
+		step("自身输出", false, "FidruaWatch 刚写入的文件 (打包、整理等), 不计入")
+	}
+
+	size := int64(-1)
+	if !isRemoteWatchPath(path) {
+		if info, err := os.Stat(path); err != nil {
+			step("文件大小", true, "文件不存在或无法读取, 按路径判断")
+		} else if size = info.Size(); size == 0 {
+			mode := config.ZeroByteMode
+			for _, m := range zeroByteModes {
+				if m.Mode == config.ZeroByteMode {
+					mode = m.Label
+				}
+			}
+			step("文件大小", config.ZeroByteMode != zeroByteIgnore, "0 字节文件: %s", mode)
+		}
+	}
+
+	now := time.Now()
+	if rule := ignoringRule(path, size, now); rule != "" {
+		step("规则", false, "被规则 %q 忽略", rule)
+	} else {
+		var names []string
+		for _, r := range config.Rules {
+			if r.Enabled && r.Scope == ruleScopeFile && r.matches(fileSubject(path, size), now) {
+				names = append(names, r.Name)
+			}
+		}
+		if len(names) > 0 {
+			step("规则", true, "批次完成时执行规则 %s", strings.Join(names, ", "))
+		}
+	}
+
+	if allow, known := scriptDecision(path); config.ScriptsEnabled && known && !allow {
+		step("脚本", false, "被脚本的 onFileDetected 忽略")
+	}
+
+	v.Folder = groupFolder(monitorPath, dir, config.GroupDepth)
+	if !v.Tracked {
+		return v
+	}
+	batchesMu.RLock()
+	defer batchesMu.RUnlock()
+	key := pathKey(v.Folder)
+	if config.Profile == profileDownloads {
+		if b := recentUploadingBatch(now); b != nil {
+			step("批次", true, "按下载时间加入进行中的批次 %s", displayFolder(b.Folder))
+		} else {
+			step("批次", true, "按下载时间分组, 会新建批次")
+		}
+		return v
+	}
+	for _, b := range batches {
+		if pathKey(b.Folder) == key && b.Status == "uploading" {
+			step("批次", true, "加入进行中的批次 %s", displayFolder(b.Folder))
+			return v
+		}
+	}
+	if reopenableBatch(key, now) != nil {
+		step("批次", true, "重新打开刚完成的批次 %s", displayFolder(v.Folder))
+	} else {
+		step("批次", true, "新建批次 %s (分组深度 %d)", displayFolder(v.Folder), config.GroupDepth)
+	}
+	return v
+}
+
+// String describes the verdict one rule per line
+func (v filterVerdict) String() string {
+	var sb strings.Builder
+	if v.Tracked {
+		fmt.Fprintf(&sb, "✅ 会被监控 (%s)\n", v.Category)
+	} else {
+		sb.WriteString("❌ 不会被监控\n")
+	}
+	for _, s := range v.Steps {
+		mark := "✔"
+		if !s.Pass {
+			mark = "✘"
+		}
+		fmt.Fprintf(&sb, "\n%s %s: %s", mark, s.Rule, s.Result)
+	}
+	return sb.String()
+}
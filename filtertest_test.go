@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExplainPath(t *testing.T) {
+	origConfig, origPath, origBatches := config, monitorPath, batches
+	defer func() { config, monitorPath, batches = origConfig, origPath, origBatches }()
+	config = defaultConfig()
+	config.VideoEnabled = true
+	config.ImageEnabled = false
+	config.MonitorSubdirs = true
+	config.ExcludePatterns = "*_proxy.mp4"
+	monitorPath = filepath.FromSlash("/in")
+	batches = make(map[string]*Batch)
+
+	tests := []struct {
+		path    string
+		tracked bool
+		failing string // rule that stops the file
+	}{
+		{"/in/day1/clip.mp4", true, ""},
+		{"/in/day1/clip.mp4.part", false, "临时文件"},
+		{"/in/day1/photo.jpg", false, "文件类型"},
+		{"/in/day1/README", false, "文件类型"},
+		{"/in/day1/clip_proxy.mp4", false, "排除规则"},
+		{"/elsewhere/clip.mp4", false, "监控文件夹"},
+	}
+	for _, tt := range tests {
+		p := filepath.FromSlash(tt.path)
+		v := explainPath(p)
+		if v.Tracked != tt.tracked {
+			t.Errorf("explainPath(%s).Tracked = %v, want %v", tt.path, v.Tracked, tt.tracked)
+		}
+		if v.Tracked != isMonitoredFile(p) && !strings.HasPrefix(tt.path, "/elsewhere") {
+			t.Errorf("explainPath(%s) disagrees with isMonitoredFile", tt.path)
+		}
+		failing := ""
+		for _, s := range v.Steps {
+			if !s.Pass && failing == "" {
+				failing = s.Rule
+			}
+		}
+		if failing != tt.failing {
+			t.Errorf("explainPath(%s) stopped at %q, want %q\n%s", tt.path, failing, tt.failing, v)
+		}
+	}
+
+	config.MonitorSubdirs = false
+	if v := explainPath(filepath.FromSlash("/in/day1/clip.mp4")); v.Tracked {
+		t.Errorf("File in a subfolder tracked without subfolder monitoring:\n%s", v)
+	}
+	config.MonitorSubdirs = true
+
+	// Grouping names the batch the file would join
+	config.GroupDepth = 1
+	batches["1"] = &Batch{ID: "1", Folder: filepath.FromSlash("/in/day1"), Status: "uploading"}
+	v := explainPath(filepath.FromSlash("/in/day1/camA/clip.mp4"))
+	if v.Folder != filepath.FromSlash("/in/day1") {
+		t.Errorf("Folder = %s, want /in/day1", v.Folder)
+	}
+	if last := v.Steps[len(v.Steps)-1]; last.Rule != "批次" || !strings.Contains(last.Result, "进行中") {
+		t.Errorf("Last step = %+v, want joining the uploading batch", last)
+	}
+	if v := explainPath(filepath.FromSlash("/in/day2/clip.mp4")); !strings.Contains(v.String(), "新建批次 day2") {
+		t.Errorf("New batch not described:\n%s", v)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/storage"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showFilterTestDialog lets the user paste or pick a path and shows how the
+// saved rules treat it
+func showFilterTestDialog(w fyne.Window) {
+	result := widget.NewLabel("输入或选择一个文件路径, 查看它是否会被监控以及原因。\n按已保存的设置判断, 修改设置后请先保存。")
+	result.Wrapping = fyne.TextWrapWord
+	pathEntry := widget.NewEntry()
+	pathEntry.SetPlaceHolder("文件路径")
+	if monitorPath != "" {
+		pathEntry.SetText(monitorPath + string(filepath.Separator))
+	}
+	test := func() {
+		if p := strings.TrimSpace(pathEntry.Text); p != "" {
+			result.SetText(explainPath(p).String())
+		}
+	}
+	pathEntry.OnChanged = func(string) { test() }
+	pickBtn := widget.NewButton("选择...", func() {
+		d := dialog.NewFileOpen(func(r fyne.URIReadCloser, err error) {
+			if err != nil || r == nil {
+				return
+			}
+			pathEntry.SetText(r.URI().Path())
+			r.Close()
+		}, w)
+		if monitorPath != "" && !isRemoteWatchPath(monitorPath) {
+			if loc, err := storage.ListerForURI(storage.NewFileURI(monitorPath)); err == nil {
+				d.SetLocation(loc)
+			}
+		}
+		d.Resize(fyne.NewSize(600, 450))
+		d.Show()
+	})
+	content := container.NewBorder(
+		container.NewBorder(nil, nil, nil, pickBtn, pathEntry), nil, nil, nil,
+		container.NewVScroll(result),
+	)
+	d := dialog.NewCustom("🔍 过滤规则测试", "关闭", content, w)
+	d.Resize(fyne.NewSize(560, 420))
+	d.Show()
+}
@@ -0,0 +1,192 @@
+//go:build darwin && cgo
+
+package main
+
+/*
+#include <CoreServices/CoreServices.h>
+*/
+import "C"
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/cgo"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// fsEventsLatency is how long FSEvents coalesces changes, in seconds
+const fsEventsLatency = 0.2
+
+// fsEventsWatcher watches whole trees with FSEvents: one stream per added
+// root instead of a kqueue descriptor per file, which is what fsnotify uses
+// on macOS and which runs out quickly on large trees.
+type fsEventsWatcher struct {
+	events  chan fsnotify.Event
+	errors  chan error
+	done    chan struct{}
+	mu      sync.Mutex
+	closed  bool
+	sending sync.WaitGroup
+	streams []*fsEventsStream
+}
+
+// fsEventsStream is the stream of one root. FSEvents reports resolved
+// paths (/private/var/... for /var/...), which are mapped back to the root
+// as given so batch folders stay below the watch path.
+type fsEventsStream struct {
+	w        *fsEventsWatcher
+	root     string
+	resolved string
+	ref      C.FSEventStreamRef
+	handle   cgo.Handle
+}
+
+// newRecursiveWatcher returns the FSEvents backend
+func newRecursiveWatcher() fsWatcher {
+	return &fsEventsWatcher{
+		events: make(chan fsnotify.Event, 64),
+		errors: make(chan error, 8),
+		done:   make(chan struct{}),
+	}
+}
+
+func (w *fsEventsWatcher) Events() <-chan fsnotify.Event { return w.events }
+func (w *fsEventsWatcher) Errors() <-chan error          { return w.errors }
+
+// Add starts watching the tree below dir
+func (w *fsEventsWatcher) Add(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New(dir + " 不是文件夹")
+	}
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		resolved = dir
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return fsnotify.ErrClosed
+	}
+	s := &fsEventsStream{w: w, root: filepath.Clean(dir), resolved: filepath.Clean(resolved)}
+	s.handle = cgo.NewHandle(s)
+	s.ref = startFSEventStream(uintptr(s.handle), s.resolved, fsEventsLatency)
+	if s.ref == nil {
+		s.handle.Delete()
+		return errors.New("FSEvents 无法监控 " + dir)
+	}
+	w.streams = append(w.streams, s)
+	return nil
+}
+
+// Close stops every stream and closes the channels
+func (w *fsEventsWatcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	streams := w.streams
+	w.streams = nil
+	w.mu.Unlock()
+
+	for _, s := range streams {
+		stopFSEventStream(s.ref)
+		s.handle.Delete()
+	}
+	w.sending.Wait()
+	close(w.events)
+	close(w.errors)
+	return nil
+}
+
+// path maps a reported path back below the root as it was added
+func (s *fsEventsStream) path(p string) string {
+	p = filepath.Clean(p)
+	if p == s.resolved {
+		return s.root
+	}
+	if rest, ok := strings.CutPrefix(p, s.resolved+string(filepath.Separator)); ok {
+		return filepath.Join(s.root, rest)
+	}
+	return p
+}
+
+// fsEventsOp converts FSEvents item flags to fsnotify operations
+func fsEventsOp(flags C.FSEventStreamEventFlags) fsnotify.Op {
+	var op fsnotify.Op
+	if flags&C.kFSEventStreamEventFlagItemCreated != 0 {
+		op |= fsnotify.Create
+	}
+	if flags&C.kFSEventStreamEventFlagItemModified != 0 {
+		op |= fsnotify.Write
+	}
+	if flags&C.kFSEventStreamEventFlagItemRenamed != 0 {
+		op |= fsnotify.Rename
+	}
+	if flags&C.kFSEventStreamEventFlagItemRemoved != 0 {
+		op |= fsnotify.Remove
+	}
+	if flags&(C.kFSEventStreamEventFlagItemInodeMetaMod|C.kFSEventStreamEventFlagItemChangeOwner|C.kFSEventStreamEventFlagItemXattrMod) != 0 {
+		op |= fsnotify.Chmod
+	}
+	return op
+}
+
+// send delivers an event or error unless the watcher is closing
+func (s *fsEventsStream) send(ev *fsnotify.Event, err error) {
+	w := s.w
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return
+	}
+	w.sending.Add(1)
+	w.mu.Unlock()
+	defer w.sending.Done()
+
+	if ev != nil {
+		select {
+		case w.events <- *ev:
+		case <-w.done:
+		}
+		return
+	}
+	select {
+	case w.errors <- err:
+	case <-w.done:
+	}
+}
+
+//export fseventsCallback
+func fseventsCallback(stream C.ConstFSEventStreamRef, info C.uintptr_t, n C.size_t, paths **C.char, flags *C.FSEventStreamEventFlags, ids *C.FSEventStreamEventId) {
+	s := cgo.Handle(info).Value().(*fsEventsStream)
+	cpaths := unsafe.Slice(paths, int(n))
+	cflags := unsafe.Slice(flags, int(n))
+	for i := range cpaths {
+		f := cflags[i]
+		// Events were coalesced or dropped; a rescan catches up
+		if f&(C.kFSEventStreamEventFlagMustScanSubDirs|C.kFSEventStreamEventFlagUserDropped|C.kFSEventStreamEventFlagKernelDropped) != 0 {
+			s.send(nil, fsnotify.ErrEventOverflow)
+			continue
+		}
+		// The root itself was moved or deleted
+		if f&C.kFSEventStreamEventFlagRootChanged != 0 {
+			s.send(&fsnotify.Event{Name: s.root, Op: fsnotify.Remove}, nil)
+			continue
+		}
+		if op := fsEventsOp(f); op != 0 {
+			s.send(&fsnotify.Event{Name: s.path(C.GoString(cpaths[i])), Op: op}, nil)
+		}
+	}
+}
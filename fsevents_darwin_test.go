@@ -0,0 +1,38 @@
+//go:build darwin && cgo
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestFSEventsWatcherRecursive(t *testing.T) {
+	root := t.TempDir() // below /var, which FSEvents reports as /private/var
+	sub := filepath.Join(root, "a", "b")
+	os.MkdirAll(sub, 0755)
+
+	w := newRecursiveWatcher()
+	if err := w.Add(root); err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	target := filepath.Join(sub, "clip.mp4")
+	os.WriteFile(target, []byte("data"), 0644)
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-w.Events():
+			if ev.Name == target && ev.Op&fsnotify.Create != 0 {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("No create event for %s", target)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+//go:build darwin && cgo
+
+package main
+
+// The FSEvents stream is created and run on a dispatch queue in C; events
+// come back through fseventsCallback in fsevents_darwin.go.
+
+/*
+#cgo LDFLAGS: -framework CoreServices
+#include <CoreServices/CoreServices.h>
+#include <dispatch/dispatch.h>
+#include <stdlib.h>
+
+extern void fseventsCallback(ConstFSEventStreamRef, uintptr_t, size_t, char **, FSEventStreamEventFlags *, FSEventStreamEventId *);
+
+static void fseventsCallbackThunk(ConstFSEventStreamRef stream, void *info, size_t n, void *paths,
+		const FSEventStreamEventFlags flags[], const FSEventStreamEventId ids[]) {
+	fseventsCallback(stream, (uintptr_t)info, n, (char **)paths, (FSEventStreamEventFlags *)flags, (FSEventStreamEventId *)ids);
+}
+
+FSEventStreamRef fseventsStart(uintptr_t handle, const char *path, double latency) {
+	CFStringRef cfPath = CFStringCreateWithCString(NULL, path, kCFStringEncodingUTF8);
+	if (cfPath == NULL) {
+		return NULL;
+	}
+	CFArrayRef paths = CFArrayCreate(NULL, (const void **)&cfPath, 1, &kCFTypeArrayCallBacks);
+	CFRelease(cfPath);
+	FSEventStreamContext ctx = {0, (void *)handle, NULL, NULL, NULL};
+	FSEventStreamRef stream = FSEventStreamCreate(NULL, fseventsCallbackThunk, &ctx, paths,
+		kFSEventStreamEventIdSinceNow, latency,
+		kFSEventStreamCreateFlagFileEvents | kFSEventStreamCreateFlagNoDefer | kFSEventStreamCreateFlagWatchRoot);
+	CFRelease(paths);
+	if (stream == NULL) {
+		return NULL;
+	}
+	dispatch_queue_t queue = dispatch_queue_create("fidruawatch.fsevents", DISPATCH_QUEUE_SERIAL);
+	FSEventStreamSetDispatchQueue(stream, queue);
+	dispatch_release(queue);
+	if (!FSEventStreamStart(stream)) {
+		FSEventStreamInvalidate(stream);
+		FSEventStreamRelease(stream);
+		return NULL;
+	}
+	return stream;
+}
+
+void fseventsStop(FSEventStreamRef stream) {
+	FSEventStreamStop(stream);
+	FSEventStreamInvalidate(stream);
+	FSEventStreamRelease(stream);
+}
+*/
+import "C"
+
+import "unsafe"
+
+// startFSEventStream starts a stream for path whose callbacks carry handle.
+// It returns nil if FSEvents refused the path.
+func startFSEventStream(handle uintptr, path string, latency float64) C.FSEventStreamRef {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	return C.fseventsStart(C.uintptr_t(handle), cpath, C.double(latency))
+}
+
+// stopFSEventStream stops a stream; no callbacks run after it returns
+func stopFSEventStream(stream C.FSEventStreamRef) {
+	C.fseventsStop(stream)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// fileSampleCount is how many size samples are kept per file; enough for a
+// current rate without growing with the batch
+const fileSampleCount = 8
+
+// noteFileSize records the size of one file of a batch in its growth
+// history. It returns true when the file shrank, i.e. it was truncated and
+// is being rewritten. Caller must hold batchesMu.
+func noteFileSize(b *Batch, name string, size int64, now time.Time) (shrank bool) {
+	if b.Growth == nil {
+		b.Growth = make(map[string]*SampleRing)
+	}
+	ring := b.Growth[name]
+	if ring == nil {
+		ring = NewSampleRing(fileSampleCount, time.Second)
+		b.Growth[name] = ring
+	}
+	if last, ok := ring.Latest(); ok && size < last.Size {
+		shrank = true
+		// Rates across the rewrite would be meaningless
+		ring = NewSampleRing(fileSampleCount, time.Second)
+		b.Growth[name] = ring
+	}
+	ring.Add(Sample{Time: now, Size: size})
+	return shrank
+}
+
+// fileGrowth is the current growth of one file
+type fileGrowth struct {
+	Name string
+	Size int64
+	Rate float64 // bytes per second over the rate window
+}
+
+// growingFiles returns the files of b that grew within window before now,
+// fastest first. Caller must hold batchesMu.
+func growingFiles(b *Batch, window time.Duration, now time.Time) []fileGrowth {
+	var out []fileGrowth
+	for name, ring := range b.Growth {
+		if rate := ring.Rate(window, now); rate > 0 {
+			out = append(out, fileGrowth{Name: name, Size: b.FileSizes[name], Rate: rate})
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Rate != out[j].Rate {
+			return out[i].Rate > out[j].Rate
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
+
+// fileSizeCurve returns the recorded sizes of one file, oldest first.
+// Caller must hold batchesMu.
+func fileSizeCurve(b *Batch, name string) []Sample {
+	return b.Growth[name].Samples()
+}
+
+// growingFilesText lists the fastest growing files for the batch card, at
+// most max of them, e.g. "⏫ a.mp4 12.5 MB/s · b.mp4 3.0 MB/s (+2)"
+func growingFilesText(growing []fileGrowth, max int) string {
+	if len(growing) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, max)
+	for i, g := range growing {
+		if i == max {
+			break
+		}
+		parts = append(parts, fmt.Sprintf("%s %s", filepath.Base(g.Name), formatRate(g.Rate)))
+	}
+	text := "⏫ " + strings.Join(parts, " · ")
+	if extra := len(growing) - len(parts); extra > 0 {
+		text += fmt.Sprintf(" (+%d)", extra)
+	}
+	return text
+}
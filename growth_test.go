@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRewrittenFileLowersTotal(t *testing.T) {
+	origConfig, origBatches, origMonitorPath := config, batches, monitorPath
+	defer func() {
+		config, batches, monitorPath = origConfig, origBatches, origMonitorPath
+	}()
+	config = Config{VideoEnabled: true, MonitorSubdirs: true}
+	batches = make(map[string]*Batch)
+	monitorPath = t.TempDir()
+
+	a := filepath.Join(monitorPath, "cam", "a.mp4")
+	b := filepath.Join(monitorPath, "cam", "b.mp4")
+	addObservedFile(a, 800)
+	addObservedFile(b, 200)
+	// a.mp4 is truncated and written again from the start
+	addObservedFile(a, 100)
+	// An unknown size keeps what was recorded
+	addObservedFile(b, -1)
+
+	if len(batches) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(batches))
+	}
+	for _, batch := range batches {
+		if batch.TotalSize != 300 {
+			t.Errorf("TotalSize = %d, want 300", batch.TotalSize)
+		}
+		if batch.FileSizes["b.mp4"] != 200 {
+			t.Errorf("b.mp4 size = %d, want 200", batch.FileSizes["b.mp4"])
+		}
+		// The rewrite starts a fresh curve
+		if curve := fileSizeCurve(batch, "a.mp4"); len(curve) != 1 || curve[0].Size != 100 {
+			t.Errorf("a.mp4 curve = %v, want one sample of 100", curve)
+		}
+	}
+}
+
+func TestGrowingFiles(t *testing.T) {
+	now := time.Now()
+	b := &Batch{FileSizes: map[string]int64{}}
+	grow := func(name string, sizes ...int64) {
+		for i, size := range sizes {
+			at := now.Add(time.Duration(i-len(sizes)+1) * time.Second)
+			noteFileSize(b, name, size, at)
+			b.FileSizes[name] = size
+		}
+	}
+	grow("fast.mp4", 0, 1000, 2000)
+	grow("done.mp4", 500, 500)
+	noteFileSize(b, "slow.mp4", 60, now.Add(-3*time.Second))
+	if !noteFileSize(b, "slow.mp4", 5, now.Add(-time.Second)) {
+		t.Error("Expected a shrinking file to be reported")
+	}
+	// Only growth since the rewrite counts
+	grow("slow.mp4", 15)
+
+	got := growingFiles(b, 10*time.Second, now)
+	if len(got) != 2 || got[0].Name != "fast.mp4" || got[1].Name != "slow.mp4" {
+		t.Fatalf("growingFiles = %+v", got)
+	}
+	if got[0].Rate != 1000 || got[1].Rate != 10 {
+		t.Errorf("Rates = %v, %v; want 1000, 10", got[0].Rate, got[1].Rate)
+	}
+	if text := growingFilesText(got, 1); text != "⏫ fast.mp4 "+formatRate(1000)+" (+1)" {
+		t.Errorf("growingFilesText = %q", text)
+	}
+	if growingFilesText(nil, 2) != "" {
+		t.Error("Expected no text without growing files")
+	}
+}
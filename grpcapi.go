@@ -0,0 +1,228 @@
+package main
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative watchpb/watch.proto
+
+import (
+	"context"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"fidruawatch/watchpb"
+)
+
+// grpcServer is the running gRPC server, nil when it is disabled
+var grpcServer *grpc.Server
+
+// defaultGRPCListen is used when grpc_listen is empty
+const defaultGRPCListen = "127.0.0.1:8766"
+
+// grpcSocketPath returns the socket path of a "unix:" listen address
+func grpcSocketPath(addr string) (string, bool) {
+	path, ok := strings.CutPrefix(addr, "unix:")
+	return strings.TrimPrefix(path, "//"), ok
+}
+
+// validGRPCListen reports whether addr is host:port or unix:<path>
+func validGRPCListen(addr string) bool {
+	if path, ok := grpcSocketPath(addr); ok {
+		return path != ""
+	}
+	_, _, err := net.SplitHostPort(addr)
+	return err == nil
+}
+
+// grpcListen listens on TCP or, for "unix:<path>", on a local socket only
+// this user may connect to
+func grpcListen(addr string) (net.Listener, error) {
+	path, ok := grpcSocketPath(addr)
+	if !ok {
+		return net.Listen("tcp", addr)
+	}
+	// A socket left behind by a crash would make Listen fail
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	return listenPrivateSocket(path)
+}
+
+// grpcRole returns the role the call's "authorization" metadata grants,
+// with the same tokens as the HTTP API
+func grpcRole(ctx context.Context) string {
+	var auth string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			auth = v[0]
+		}
+	}
+	return apiRoleFor(auth)
+}
+
+// grpcWatchService implements the Watch service on the batch pipeline
+type grpcWatchService struct {
+	watchpb.UnimplementedWatchServer
+	updateUI func()
+}
+
+func (s *grpcWatchService) ListBatches(ctx context.Context, req *watchpb.ListBatchesRequest) (*watchpb.ListBatchesResponse, error) {
+	batchesMu.RLock()
+	list := make([]*Batch, 0, len(batches))
+	for _, b := range batches {
+		list = append(list, b)
+	}
+	sortBatches(list, sortByStartTime)
+	resp := &watchpb.ListBatchesResponse{Session: sessionID}
+	for _, b := range list {
+		resp.Batches = append(resp.Batches, toProtoBatch(toAPIBatch(b)))
+	}
+	batchesMu.RUnlock()
+	return resp, nil
+}
+
+func (s *grpcWatchService) SignBatch(ctx context.Context, req *watchpb.SignBatchRequest) (*watchpb.Batch, error) {
+	if grpcRole(ctx) != apiRoleSigner {
+		return nil, status.Error(codes.PermissionDenied, "signing requires the sign token")
+	}
+	by := req.GetBy()
+	if by == "" {
+		by = config.OperatorName
+	}
+	batchesMu.Lock()
+	b, ok := batches[req.GetId()]
+	if !ok {
+		batchesMu.Unlock()
+		return nil, status.Error(codes.NotFound, "batch not found")
+	}
+	err := signBatchAs(b, by, apiPrincipal(), req.GetComment(), time.Now())
+	out := toProtoBatch(toAPIBatch(b))
+	batchesMu.Unlock()
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	s.updateUI()
+	return out, nil
+}
+
+func (s *grpcWatchService) WatchEvents(req *watchpb.WatchEventsRequest, stream grpc.ServerStreamingServer[watchpb.BatchEvent]) error {
+	known := make(map[string]bool)
+	for _, typ := range batchEventTypes {
+		known[typ] = true
+	}
+	var want map[string]bool // nil = every type
+	for _, typ := range req.GetTypes() {
+		if !known[typ] {
+			return status.Errorf(codes.InvalidArgument, "unknown event type %q", typ)
+		}
+		if want == nil {
+			want = make(map[string]bool)
+		}
+		want[typ] = true
+	}
+	events, unsubscribe := batchEvents.Subscribe()
+	defer unsubscribe()
+
+	// Headers go out now, so clients know the stream is up before the
+	// first event
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev := <-events:
+			if want != nil && !want[ev.Type] {
+				continue
+			}
+			if err := stream.Send(&watchpb.BatchEvent{
+				Type:  ev.Type,
+				Time:  protoTime(ev.Time),
+				Batch: toProtoBatch(ev.Batch),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// toProtoBatch converts an API batch for gRPC
+func toProtoBatch(b apiBatch) *watchpb.Batch {
+	pb := &watchpb.Batch{
+		Id:        b.ID,
+		Folder:    b.Folder,
+		Status:    b.Status,
+		Files:     int64(b.Files),
+		TotalSize: b.TotalSize,
+		StartTime: protoTime(b.StartTime),
+		LastTime:  protoTime(b.LastTime),
+		SessionId: b.SessionID,
+		CheckCode: b.CheckCode,
+	}
+	for _, so := range b.SignOffs {
+		pb.SignOffs = append(pb.SignOffs, &watchpb.SignOff{
+			Action:  so.Action,
+			By:      so.By,
+			At:      protoTime(so.At),
+			Comment: so.Comment,
+		})
+	}
+	return pb
+}
+
+// protoTime converts t, leaving the zero time unset
+func protoTime(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+// newGRPCServer builds the gRPC server. Every call needs the API or sign
+// token when one is configured.
+func newGRPCServer(updateUI func()) *grpc.Server {
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if grpcRole(ctx) == "" {
+				return nil, status.Error(codes.Unauthenticated, "unauthorized")
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if grpcRole(ss.Context()) == "" {
+				return status.Error(codes.Unauthenticated, "unauthorized")
+			}
+			return handler(srv, ss)
+		}),
+	)
+	watchpb.RegisterWatchServer(srv, &grpcWatchService{updateUI: updateUI})
+	return srv
+}
+
+// startGRPCServer serves gRPC on config.GRPCListen in the background, or
+// returns nil when it can't listen
+func startGRPCServer(updateUI func()) *grpc.Server {
+	addr := strings.TrimSpace(config.GRPCListen)
+	if addr == "" {
+		addr = defaultGRPCListen
+	}
+	l, err := grpcListen(addr)
+	if err != nil {
+		logEvent("gRPC 服务启动失败: %v", err)
+		return nil
+	}
+	srv := newGRPCServer(updateUI)
+	go func() {
+		logEvent("gRPC 服务监听 %s", addr)
+		if err := srv.Serve(l); err != nil && err != grpc.ErrServerStopped {
+			logEvent("gRPC 服务出错: %v", err)
+		}
+	}()
+	return srv
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"fidruawatch/watchpb"
+)
+
+func TestGRPCWatchService(t *testing.T) {
+	origConfig, origBatches := config, batches
+	defer func() { config, batches = origConfig, origBatches }()
+	socket := filepath.Join(t.TempDir(), "watch.sock")
+	config = Config{APIToken: "view", SignToken: "sign", OperatorName: "api", GRPCListen: "unix:" + socket}
+	batches = map[string]*Batch{"b1": {ID: "b1", Status: "completed", FileSizes: map[string]int64{}}}
+
+	srv := startGRPCServer(func() {})
+	if srv == nil {
+		t.Fatal("gRPC server did not start")
+	}
+	defer srv.Stop()
+	if fi, err := os.Stat(socket); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("socket mode %v, %v; want 0600", fi.Mode().Perm(), err)
+	}
+	conn, err := grpc.NewClient("unix:"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := watchpb.NewWatchClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	as := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+	}
+
+	if _, err := client.ListBatches(ctx, &watchpb.ListBatchesRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("list without token: %v, want Unauthenticated", err)
+	}
+	list, err := client.ListBatches(as("view"), &watchpb.ListBatchesRequest{})
+	if err != nil || len(list.Batches) != 1 || list.Batches[0].Id != "b1" {
+		t.Fatalf("list = %v, %v", list, err)
+	}
+	if _, err := client.SignBatch(as("view"), &watchpb.SignBatchRequest{Id: "b1"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("viewer sign: %v, want PermissionDenied", err)
+	}
+
+	stream, err := client.WatchEvents(as("view"), &watchpb.WatchEventsRequest{Types: []string{eventSigned}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Headers arrive once the server is subscribed
+	if _, err := stream.Header(); err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := client.SignBatch(as("sign"), &watchpb.SignBatchRequest{Id: "b1", By: "Bob", Comment: "ok"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signed.Status != "signed" || len(signed.SignOffs) != 1 || signed.SignOffs[0].By != "Bob" {
+		t.Errorf("signed batch = %v", signed)
+	}
+	ev, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Type != eventSigned || ev.Batch.GetId() != "b1" || ev.Time == nil {
+		t.Errorf("event = %v, want signed b1", ev)
+	}
+
+	// Signing again fails the precondition; unknown batches and event types
+	// are rejected
+	if _, err := client.SignBatch(as("sign"), &watchpb.SignBatchRequest{Id: "b1"}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("second sign: %v, want FailedPrecondition", err)
+	}
+	if _, err := client.SignBatch(as("sign"), &watchpb.SignBatchRequest{Id: "nope"}); status.Code(err) != codes.NotFound {
+		t.Errorf("unknown batch: %v, want NotFound", err)
+	}
+	bad, err := client.WatchEvents(as("view"), &watchpb.WatchEventsRequest{Types: []string{"exploded"}})
+	if err == nil {
+		_, err = bad.Recv()
+	}
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("unknown event type: %v, want InvalidArgument", err)
+	}
+}
+
+func TestValidGRPCListen(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1:8766":             true,
+		":8766":                      true,
+		"unix:/run/fidruawatch.sock": true,
+		"unix:///tmp/w.sock":         true,
+		"unix:":                      false,
+		"localhost":                  false,
+		"":                           false,
+	} {
+		if got := validGRPCListen(addr); got != want {
+			t.Errorf("validGRPCListen(%q) = %v, want %v", addr, got, want)
+		}
+	}
+}
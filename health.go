@@ -0,0 +1,176 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// lastWatchEvent is when the watchers last reported a change, in Unix
+	// nanoseconds
+	lastWatchEvent atomic.Int64
+	// statPool is the worker pool of the running event loop
+	statPool atomic.Pointer[workPool]
+)
+
+// healthReport is the body of /healthz
+type healthReport struct {
+	Status     string          `json:"status"` // ok, degraded, failed or stopped
+	Monitoring bool            `json:"monitoring"`
+	Watchers   []healthWatcher `json:"watchers"`
+	LastEvent  *time.Time      `json:"last_event,omitempty"`
+	Queues     healthQueues    `json:"queues"`
+	Uploading  int             `json:"uploading"`
+}
+
+// healthWatcher is the state of one watched root. The path is only shown
+// to authorized callers.
+type healthWatcher struct {
+	Root    string `json:"root,omitempty"`
+	State   string `json:"state"`
+	Watched int    `json:"watched"`
+	Failed  int    `json:"failed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// healthQueues are the backlogs between the watchers and the batches
+type healthQueues struct {
+	Events   int `json:"events"`    // watch events not yet read by the event loop
+	StatJobs int `json:"stat_jobs"` // files waiting to be stat'ed
+}
+
+// currentHealth gathers the health report. A monitoring session is running
+// in the window and headless alike.
+func currentHealth(withPaths bool) healthReport {
+	r := healthReport{Status: "ok", Monitoring: sessionID != "", Watchers: []healthWatcher{}}
+	for _, s := range watchers.Statuses() {
+		hw := healthWatcher{State: s.State, Watched: s.Watched, Failed: s.Failed}
+		if withPaths {
+			hw.Root = s.Root
+		}
+		if s.Err != nil {
+			hw.Error = s.Err.Error()
+		}
+		switch {
+		case s.State == rootFailed:
+			r.Status = "failed"
+		case s.State == rootDegraded && r.Status == "ok":
+			r.Status = "degraded"
+		}
+		r.Watchers = append(r.Watchers, hw)
+	}
+	if !r.Monitoring {
+		r.Status = "stopped"
+	}
+	if ns := lastWatchEvent.Load(); ns != 0 {
+		t := time.Unix(0, ns)
+		r.LastEvent = &t
+	}
+	r.Queues.Events = watchers.QueueDepth()
+	if p := statPool.Load(); p != nil {
+		r.Queues.StatJobs = p.Len()
+	}
+	batchesMu.RLock()
+	for _, b := range batches {
+		if b.Status == "uploading" {
+			r.Uploading++
+		}
+	}
+	batchesMu.RUnlock()
+	return r
+}
+
+// handleHealthz serves /healthz: 200 while healthy or stopped, 503 when a
+// watched root has failed. It needs no token, but only shows the watched
+// paths to callers that have one.
+func handleHealthz(w http.ResponseWriter, r *http.Request) {
+	report := currentHealth(apiAuthorized(r))
+	status := http.StatusOK
+	if report.Status == "failed" {
+		status = http.StatusServiceUnavailable
+	}
+	writeAPIResponse(w, status, report, "")
+}
+
+// Self-test probes are hidden temp files, ignored by batching
+const (
+	selfTestPrefix  = ".fidruawatch-selftest-"
+	selfTestTimeout = 5 * time.Second
+)
+
+var selfTests = struct {
+	mu      sync.Mutex
+	waiting map[string]chan struct{} // by probe file name
+}{waiting: make(map[string]chan struct{})}
+
+// noteSelfTestEvent reports whether path is a self-test probe, telling its
+// test it arrived
+func noteSelfTestEvent(path string) bool {
+	name := filepath.Base(path)
+	if !strings.HasPrefix(name, selfTestPrefix) {
+		return false
+	}
+	selfTests.mu.Lock()
+	defer selfTests.mu.Unlock()
+	if ch, ok := selfTests.waiting[name]; ok {
+		close(ch)
+		delete(selfTests.waiting, name)
+	}
+	return true
+}
+
+// runSelfTest writes a probe file into root and waits until the watch
+// pipeline hands it to file processing, returning how long that took
+func runSelfTest(root string, timeout time.Duration) (time.Duration, error) {
+	if isRemoteWatchPath(root) {
+		return 0, fmt.Errorf("远程文件夹不支持自检: %s", root)
+	}
+	name := fmt.Sprintf("%s%d.tmp", selfTestPrefix, time.Now().UnixNano())
+	path := filepath.Join(root, name)
+	arrived := make(chan struct{})
+	selfTests.mu.Lock()
+	selfTests.waiting[name] = arrived
+	selfTests.mu.Unlock()
+	defer func() {
+		selfTests.mu.Lock()
+		delete(selfTests.waiting, name)
+		selfTests.mu.Unlock()
+		os.Remove(path)
+	}()
+
+	start := time.Now()
+	if err := os.WriteFile(path, []byte("FidruaWatch self-test\n"), 0644); err != nil {
+		return 0, fmt.Errorf("无法写入测试文件: %v", err)
+	}
+	select {
+	case <-arrived:
+		return time.Since(start), nil
+	case <-time.After(timeout):
+		return 0, fmt.Errorf("%s 未在 %v 内检测到测试文件, 请检查监控状态", root, timeout)
+	}
+}
+
+// selfTestAll tests every watched root and describes the outcome
+func selfTestAll() (string, error) {
+	statuses := watchers.Statuses()
+	if sessionID == "" || len(statuses) == 0 {
+		return "", fmt.Errorf("请先开始监控")
+	}
+	var lines []string
+	for _, s := range statuses {
+		took, err := runSelfTest(s.Root, selfTestTimeout)
+		if err != nil {
+			logEvent("自检失败: %v", err)
+			return "", err
+		}
+		lines = append(lines, fmt.Sprintf("✅ %s: %d 毫秒内检测到测试文件", displayWindowsPath(s.Root), took.Milliseconds()))
+	}
+	logEvent("自检通过")
+	return strings.Join(lines, "\n"), nil
+}
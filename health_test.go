@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHealthz(t *testing.T) {
+	origConfig, origBatches, origSession := config, batches, sessionID
+	defer func() { config, batches, sessionID = origConfig, origBatches, origSession }()
+	config = Config{APIToken: "secret"}
+	batches = map[string]*Batch{
+		"a": {ID: "a", Status: "uploading"},
+		"b": {ID: "b", Status: "completed"},
+	}
+	sessionID = "s1"
+
+	req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+	rec := httptest.NewRecorder()
+	newAPIHandler(func() {}, nil).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Status = %d, want 200 without a token", rec.Code)
+	}
+	var resp struct {
+		Data healthReport `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Data.Monitoring || resp.Data.Uploading != 1 {
+		t.Errorf("Report = %+v, want monitoring with 1 uploading", resp.Data)
+	}
+
+	sessionID = ""
+	if got := currentHealth(false).Status; got != "stopped" {
+		t.Errorf("Status = %s when not monitoring, want stopped", got)
+	}
+}
+
+func TestRunSelfTest(t *testing.T) {
+	dir := t.TempDir()
+	// Stand in for the watch pipeline: report whatever appears in dir
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			entries, _ := os.ReadDir(dir)
+			for _, e := range entries {
+				noteSelfTestEvent(filepath.Join(dir, e.Name()))
+			}
+		}
+	}()
+	if _, err := runSelfTest(dir, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Probe file left behind: %v", entries)
+	}
+
+	if _, err := runSelfTest(t.TempDir(), 50*time.Millisecond); err == nil {
+		t.Error("Self-test should fail when nothing picks the probe up")
+	}
+	if noteSelfTestEvent(filepath.Join(dir, "clip.mp4")) {
+		t.Error("Ordinary files aren't probes")
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"image/color"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/widget"
+)
+
+// heatMapWeeks is how many weeks the activity calendar shows
+const heatMapWeeks = 53
+
+// dayVolume is the upload activity of one calendar day
+type dayVolume struct {
+	Batches int
+	Bytes   int64
+}
+
+// dayKey identifies a local calendar day
+func dayKey(t time.Time) string {
+	return t.Local().Format("2006-01-02")
+}
+
+// startOfDay returns local midnight of t's day
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// dailyVolumes sums the batches of the history by the day they ended
+func dailyVolumes(records []HistoryRecord) map[string]dayVolume {
+	days := make(map[string]dayVolume)
+	for _, rec := range records {
+		d := days[dayKey(rec.EndTime)]
+		d.Batches++
+		d.Bytes += rec.TotalSize
+		days[dayKey(rec.EndTime)] = d
+	}
+	return days
+}
+
+// heatMapDays returns the days of the calendar ending on today's day, in
+// column order: weeks left to right starting on Sunday, days top to bottom
+func heatMapDays(today time.Time) []time.Time {
+	end := startOfDay(today)
+	start := end.AddDate(0, 0, -int(end.Weekday())-(heatMapWeeks-1)*7)
+	var days []time.Time
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		days = append(days, d)
+	}
+	return days
+}
+
+// heatLevel buckets a day's volume into 0 (nothing) and 1 to 4 by quarters
+// of the busiest day, like a contribution graph
+func heatLevel(bytes, max int64) int {
+	if bytes <= 0 || max <= 0 {
+		return 0
+	}
+	level := int(bytes*4/max) + 1
+	if level > 4 {
+		level = 4
+	}
+	return level
+}
+
+// heatColor is the cell color of a level
+func heatColor(level int) color.Color {
+	if level == 0 {
+		return color.NRGBA{R: 128, G: 128, B: 140, A: 40}
+	}
+	c := colorGreen
+	c.A = uint8(60 + level*48)
+	return c
+}
+
+// heatCell is one tappable day of the calendar
+type heatCell struct {
+	widget.BaseWidget
+	rect  *canvas.Rectangle
+	onTap func()
+}
+
+func newHeatCell(c color.Color, onTap func()) *heatCell {
+	cell := &heatCell{rect: canvas.NewRectangle(c), onTap: onTap}
+	cell.rect.CornerRadius = 1
+	cell.ExtendBaseWidget(cell)
+	return cell
+}
+
+func (c *heatCell) CreateRenderer() fyne.WidgetRenderer {
+	return widget.NewSimpleRenderer(c.rect)
+}
+
+func (c *heatCell) MinSize() fyne.Size {
+	return fyne.NewSize(5, 5)
+}
+
+func (c *heatCell) Tapped(*fyne.PointEvent) {
+	if c.onTap != nil {
+		c.onTap()
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHeatMapDays(t *testing.T) {
+	today := time.Date(2026, 10, 14, 15, 30, 0, 0, time.Local) // a Wednesday
+	days := heatMapDays(today)
+	if len(days) != (heatMapWeeks-1)*7+4 {
+		t.Errorf("Got %d days, want %d", len(days), (heatMapWeeks-1)*7+4)
+	}
+	if days[0].Weekday() != time.Sunday {
+		t.Errorf("Calendar starts on %s, want Sunday", days[0].Weekday())
+	}
+	if dayKey(days[len(days)-1]) != "2026-10-14" {
+		t.Errorf("Calendar ends on %s, want today", dayKey(days[len(days)-1]))
+	}
+}
+
+func TestDailyVolumes(t *testing.T) {
+	day := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	volumes := dailyVolumes([]HistoryRecord{
+		{EndTime: day, TotalSize: 100},
+		{EndTime: day.Add(10 * time.Hour), TotalSize: 50},
+		{EndTime: day.AddDate(0, 0, 1), TotalSize: 10},
+	})
+	if v := volumes["2026-03-01"]; v.Batches != 2 || v.Bytes != 150 {
+		t.Errorf("2026-03-01 = %+v, want 2 batches, 150 bytes", v)
+	}
+
+	for _, tc := range []struct {
+		bytes, max int64
+		want       int
+	}{
+		{0, 100, 0},
+		{1, 100, 1},
+		{30, 100, 2},
+		{74, 100, 3},
+		{100, 100, 4},
+	} {
+		if got := heatLevel(tc.bytes, tc.max); got != tc.want {
+			t.Errorf("heatLevel(%d, %d) = %d, want %d", tc.bytes, tc.max, got, tc.want)
+		}
+	}
+}
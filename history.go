@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HistoryRecord is the persisted summary of a batch. The history file is
+// append-only JSON Lines: a batch is written when it completes and again on
+// later status changes, and the last line for an ID wins when loading.
+type HistoryRecord struct {
+	ID        string               `json:"id"`
+	Folder    string               `json:"folder"`
+	Status    string               `json:"status"`
+	FileCount int                  `json:"file_count"`
+	Files     []string             `json:"files,omitempty"`
+	FileSizes map[string]int64     `json:"file_sizes,omitempty"`
+	TotalSize int64                `json:"total_size"`
+	StartTime time.Time            `json:"start_time"`
+	EndTime   time.Time            `json:"end_time"`
+	SessionID string               `json:"session_id,omitempty"`
+	Uploader  string               `json:"uploader,omitempty"`
+	CheckCode string               `json:"check_code,omitempty"`
+	Media     map[string]VideoInfo `json:"media,omitempty"`
+	Exif      *ExifSummary         `json:"exif,omitempty"`
+	Archive   string               `json:"archive,omitempty"`
+	SignOffs  []SignOff            `json:"sign_offs,omitempty"`
+	Manifest  *Manifest            `json:"manifest,omitempty"`
+	Checksum  string               `json:"checksum,omitempty"`
+	Tags      []string             `json:"tags,omitempty"`
+	Timeline  []TimelineEntry      `json:"timeline,omitempty"`
+	DupFiles  []string             `json:"dup_files,omitempty"`
+}
+
+var (
+	historyPath string
+	historyMu   sync.Mutex
+)
+
+// historyRecordFor snapshots a batch. Caller must hold batchesMu.
+func historyRecordFor(b *Batch) HistoryRecord {
+	rec := HistoryRecord{
+		ID:        b.ID,
+		Folder:    b.Folder,
+		Status:    b.Status,
+		FileCount: len(b.Files),
+		Files:     append([]string(nil), b.Files...),
+		FileSizes: make(map[string]int64, len(b.FileSizes)),
+		TotalSize: b.TotalSize,
+		StartTime: b.StartTime,
+		EndTime:   b.LastTime,
+		SessionID: b.SessionID,
+		Uploader:  b.Uploader,
+		CheckCode: b.CheckCode,
+		Media:     b.Media,
+		Exif:      b.Exif,
+		Archive:   b.Archive,
+		SignOffs:  append([]SignOff(nil), b.SignOffs...),
+		Manifest:  b.Manifest,
+		Checksum:  b.Checksum,
+		Tags:      append([]string(nil), b.Tags...),
+		Timeline:  append([]TimelineEntry(nil), b.Timeline...),
+		DupFiles:  append([]string(nil), b.DupFiles...),
+	}
+	for f, size := range b.FileSizes {
+		rec.FileSizes[f] = size
+	}
+	return rec
+}
+
+// recordHistory appends the current state of a batch to the history file
+// when history is enabled, and passes it to the store plugins. Simulated
+// batches are left out. Caller must hold batchesMu.
+func recordHistory(b *Batch) {
+	if strings.HasPrefix(b.Folder, simScheme) {
+		return
+	}
+	rec := historyRecordFor(b)
+	storePlugins(rec)
+	if !config.SaveHistory {
+		return
+	}
+	if err := appendHistory(rec); err != nil {
+		logEvent("写入历史记录失败: %v", err)
+	}
+}
+
+// appendHistory writes one record to the history file
+func appendHistory(rec HistoryRecord) error {
+	if useDatabase() {
+		return dbAppendHistory(rec)
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(historyPath), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(historyPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// loadHistory reads the history file, keeping the latest record per batch,
+// sorted by end time (newest first). Unparsable lines are skipped, counted
+// and logged; the next start sets the damaged file aside.
+func loadHistory() (records []HistoryRecord, badLines int, err error) {
+	if useDatabase() {
+		return dbQueryHistory(time.Time{}, time.Time{})
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	records, badLines, err = readHistory()
+	if badLines > 0 {
+		logEvent("历史记录中有 %d 行无法解析", badLines)
+	}
+	return records, badLines, err
+}
+
+// readHistory is loadHistory for callers holding historyMu
+func readHistory() (records []HistoryRecord, badLines int, err error) {
+	f, err := os.Open(historyPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+	defer f.Close()
+
+	index := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var rec HistoryRecord
+		if json.Unmarshal(line, &rec) != nil || rec.ID == "" {
+			badLines++
+			continue
+		}
+		if i, ok := index[rec.ID]; ok {
+			records[i] = rec
+			continue
+		}
+		index[rec.ID] = len(records)
+		records = append(records, rec)
+	}
+	if err := scanner.Err(); err != nil {
+		return records, badLines, err
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].EndTime.After(records[j].EndTime)
+	})
+	return records, badLines, nil
+}
+
+// queryHistory returns the records of batches that ended in [from, to),
+// newest first. The database answers from its index; the JSON history is
+// read in full.
+func queryHistory(from, to time.Time) ([]HistoryRecord, error) {
+	if useDatabase() {
+		records, _, err := dbQueryHistory(from, to)
+		return records, err
+	}
+	all, _, err := loadHistory()
+	var records []HistoryRecord
+	for _, rec := range all {
+		if !rec.EndTime.Before(from) && rec.EndTime.Before(to) {
+			records = append(records, rec)
+		}
+	}
+	return records, err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestHistoryAppendAndLoad(t *testing.T) {
+	origConfig := config
+	origHistoryPath := historyPath
+	defer func() {
+		config = origConfig
+		historyPath = origHistoryPath
+	}()
+	historyPath = filepath.Join(t.TempDir(), "history.jsonl")
+	config = Config{SaveHistory: true}
+
+	now := time.Now()
+	b := &Batch{ID: "1", Folder: "/up/a", Status: "completed", Files: []string{"a.mp4"}, TotalSize: 10, LastTime: now, DupFiles: []string{"a.mp4"}}
+	recordHistory(b)
+	signBatch(b)
+	recordHistory(&Batch{ID: "2", Folder: "/up/b", Status: "completed", LastTime: now.Add(time.Minute)})
+
+	// A torn write must not hide the valid records
+	f, _ := os.OpenFile(historyPath, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("{\"id\": \"3\", \"fol")
+	f.Close()
+
+	records, bad, err := loadHistory()
+	if err != nil {
+		t.Fatalf("loadHistory() error: %v", err)
+	}
+	if bad != 1 {
+		t.Errorf("badLines = %d, want 1", bad)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	if records[0].ID != "2" {
+		t.Errorf("Expected newest record first, got %s", records[0].ID)
+	}
+	if records[1].Status != "signed" {
+		t.Errorf("Expected later status to win, got %s", records[1].Status)
+	}
+	if len(records[1].DupFiles) != 1 || records[1].DupFiles[0] != "a.mp4" {
+		t.Errorf("DupFiles = %v, want [a.mp4]", records[1].DupFiles)
+	}
+
+	config.SaveHistory = false
+	recordHistory(&Batch{ID: "4"})
+	if records, _, _ := loadHistory(); len(records) != 2 {
+		t.Errorf("History written while disabled")
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// historyQuery selects history records. Zero fields don't filter.
+type historyQuery struct {
+	Text    string    // matched against folder, file names, operators and sign-off notes
+	From    time.Time // end time, inclusive
+	To      time.Time // end time, exclusive
+	MinSize int64
+	MaxSize int64
+}
+
+// historySearchText is what a query's Text is matched against
+func historySearchText(rec HistoryRecord) string {
+	parts := []string{rec.Folder, rec.Uploader}
+	parts = append(parts, rec.Files...)
+	parts = append(parts, rec.Tags...)
+	for _, so := range rec.SignOffs {
+		parts = append(parts, so.By, so.Comment)
+	}
+	return strings.Join(parts, "\n")
+}
+
+// matches applies the query to one record
+func (q historyQuery) matches(rec HistoryRecord) bool {
+	switch {
+	case !q.From.IsZero() && rec.EndTime.Before(q.From),
+		!q.To.IsZero() && !rec.EndTime.Before(q.To),
+		q.MinSize > 0 && rec.TotalSize < q.MinSize,
+		q.MaxSize > 0 && rec.TotalSize > q.MaxSize:
+		return false
+	}
+	text := strings.TrimSpace(q.Text)
+	return text == "" || strings.Contains(strings.ToLower(historySearchText(rec)), strings.ToLower(text))
+}
+
+// searchHistory returns the records matching q, newest first. The SQLite
+// store answers from its full-text index.
+func searchHistory(q historyQuery) ([]HistoryRecord, error) {
+	if useDatabase() {
+		return dbSearchHistory(q)
+	}
+	all, _, err := loadHistory()
+	var records []HistoryRecord
+	for _, rec := range all {
+		if q.matches(rec) {
+			records = append(records, rec)
+		}
+	}
+	return records, err
+}
+
+// parseDay parses a YYYY-MM-DD date in local time, empty for none
+func parseDay(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02", s, time.Local)
+}
+
+// historyQueryFromForm parses the search form. Dates are whole days, the
+// "to" day included; sizes are in MB.
+func historyQueryFromForm(text, from, to, minMB, maxMB string) (historyQuery, error) {
+	q := historyQuery{Text: text}
+	var err error
+	if q.From, err = parseDay(from); err != nil {
+		return q, fmt.Errorf("开始日期无效: %s", from)
+	}
+	if q.To, err = parseDay(to); err != nil {
+		return q, fmt.Errorf("结束日期无效: %s", to)
+	}
+	if !q.To.IsZero() {
+		q.To = q.To.AddDate(0, 0, 1)
+	}
+	for _, f := range []struct {
+		text string
+		dst  *int64
+	}{{minMB, &q.MinSize}, {maxMB, &q.MaxSize}} {
+		if t := strings.TrimSpace(f.text); t != "" {
+			var mb float64
+			if _, err := fmt.Sscanf(t, "%g", &mb); err != nil || mb < 0 {
+				return q, fmt.Errorf("大小无效: %s", t)
+			}
+			*f.dst = int64(mb * (1 << 20))
+		}
+	}
+	return q, nil
+}
+
+// historyRecordText is a result line of the search screen
+func historyRecordText(rec HistoryRecord) string {
+	return fmt.Sprintf("%s  %s · %d个文件 · %s · %s",
+		formatDateTime(rec.EndTime, false), displayFolder(rec.Folder), rec.FileCount, formatSize(rec.TotalSize), statusLabel(rec.Status))
+}
+
+// writeHistoryCSV exports records, one row per batch
+func writeHistoryCSV(w io.Writer, records []HistoryRecord) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "folder", "status", "files", "total_size", "start_time", "end_time", "uploader", "check_code"})
+	for _, rec := range records {
+		cw.Write([]string{
+			rec.ID,
+			rec.Folder,
+			rec.Status,
+			fmt.Sprint(rec.FileCount),
+			fmt.Sprint(rec.TotalSize),
+			rec.StartTime.Format(time.RFC3339),
+			rec.EndTime.Format(time.RFC3339),
+			rec.Uploader,
+			rec.CheckCode,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchHistory(t *testing.T) {
+	origConfig, origHistory := config, historyPath
+	defer func() { config, historyPath = origConfig, origHistory }()
+	historyPath = filepath.Join(t.TempDir(), "history.jsonl")
+	config = defaultConfig()
+	config.SaveHistory = true
+
+	day := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	recordHistory(&Batch{ID: "a", Folder: "/in/客户A", Status: "signed", Files: []string{"IMG_0001.CR2"}, TotalSize: 5 << 20, LastTime: day,
+		SignOffs: []SignOff{{Action: "sign", By: "王五", Comment: "色彩已确认"}}})
+	recordHistory(&Batch{ID: "b", Folder: "/in/客户B", Status: "completed", Files: []string{"clip.mp4"}, TotalSize: 500 << 20, LastTime: day.AddDate(0, 0, 1)})
+
+	search := func(text, from, to, minMB, maxMB string) string {
+		q, err := historyQueryFromForm(text, from, to, minMB, maxMB)
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := searchHistory(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, rec := range records {
+			ids = append(ids, rec.ID)
+		}
+		return strings.Join(ids, ",")
+	}
+	for _, tc := range []struct {
+		text, from, to, minMB, maxMB string
+		want                         string
+	}{
+		{"", "", "", "", "", "b,a"},
+		{"客户a", "", "", "", "", "a"},
+		{"img_0001", "", "", "", "", "a"},
+		{"色彩", "", "", "", "", "a"},
+		{"", "2026-03-11", "", "", "", "b"},
+		{"", "", "2026-03-10", "", "", "a"},
+		{"", "", "", "100", "", "b"},
+		{"", "", "", "", "10", "a"},
+		{"客户", "2026-03-12", "", "", "", ""},
+	} {
+		if got := search(tc.text, tc.from, tc.to, tc.minMB, tc.maxMB); got != tc.want {
+			t.Errorf("search(%q, %q, %q, %q, %q) = %q, want %q", tc.text, tc.from, tc.to, tc.minMB, tc.maxMB, got, tc.want)
+		}
+	}
+
+	if _, err := historyQueryFromForm("", "10/03/2026", "", "", ""); err == nil {
+		t.Error("Expected an error for an invalid date")
+	}
+}
+
+func TestWriteHistoryCSV(t *testing.T) {
+	var buf bytes.Buffer
+	recs := []HistoryRecord{{ID: "a", Folder: "/in/a, b", Status: "signed", FileCount: 2, TotalSize: 10}}
+	if err := writeHistoryCSV(&buf, recs); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[1], `a,"/in/a, b",signed,2,10,`) {
+		t.Errorf("CSV = %q", buf.String())
+	}
+}
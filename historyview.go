@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// maxDetailFiles caps the file names listed for one history record
+const maxDetailFiles = 50
+
+// newHistoryView builds the history tab: a search form over past batches
+// and the matching records, which can be exported as CSV, and the trash of
+// deleted batches
+func newHistoryView(updateUI func(), w fyne.Window) fyne.CanvasObject {
+	textEntry := widget.NewEntry()
+	textEntry.SetPlaceHolder("🔍 文件夹、文件名、操作员或签收备注")
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("2006-01-02")
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("2006-01-02")
+	minEntry := widget.NewEntry()
+	minEntry.SetPlaceHolder("0")
+	maxEntry := widget.NewEntry()
+	maxEntry.SetPlaceHolder("0")
+	countLabel := widget.NewLabel("")
+
+	var results []HistoryRecord
+	list := widget.NewList(
+		func() int { return len(results) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(historyRecordText(results[i]))
+		},
+	)
+	list.OnSelected = func(i widget.ListItemID) {
+		list.Unselect(i)
+		showHistoryRecordDialog(results[i], w)
+	}
+
+	search := func() {
+		q, err := historyQueryFromForm(textEntry.Text, fromEntry.Text, toEntry.Text, minEntry.Text, maxEntry.Text)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		countLabel.SetText("搜索中...")
+		go func() {
+			records, err := searchHistory(q)
+			fyne.Do(func() {
+				if err != nil {
+					countLabel.SetText("")
+					dialog.ShowError(err, w)
+					return
+				}
+				results = records
+				countLabel.SetText(fmt.Sprintf("找到 %d 个批次", len(results)))
+				list.ScrollToTop()
+				list.Refresh()
+			})
+		}()
+	}
+	textEntry.OnSubmitted = func(string) { search() }
+
+	exportBtn := widget.NewButton("📤 导出结果...", func() {
+		if len(results) == 0 {
+			dialog.ShowInformation("导出结果", "没有可导出的批次", w)
+			return
+		}
+		selection := results
+		d := dialog.NewFileSave(func(wc fyne.URIWriteCloser, err error) {
+			if err != nil || wc == nil {
+				return
+			}
+			defer wc.Close()
+			if err := writeHistoryCSV(wc, selection); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			logEvent("已导出 %d 条历史记录: %s", len(selection), wc.URI().Path())
+		}, w)
+		d.SetFileName("fidruawatch-history.csv")
+		d.Resize(fyne.NewSize(600, 450))
+		d.Show()
+	})
+
+	trashBtn := widget.NewButton("♻️ 回收站", func() {
+		showTrashDialog(updateUI, w)
+	})
+
+	form := container.NewVBox(
+		textEntry,
+		container.NewGridWithColumns(2,
+			container.NewBorder(nil, nil, widget.NewLabel("从"), nil, fromEntry),
+			container.NewBorder(nil, nil, widget.NewLabel("到"), nil, toEntry),
+			container.NewBorder(nil, nil, widget.NewLabel("最小 MB"), nil, minEntry),
+			container.NewBorder(nil, nil, widget.NewLabel("最大 MB"), nil, maxEntry),
+		),
+		container.NewBorder(nil, nil, nil, container.NewHBox(widget.NewButton("搜索", search), exportBtn, trashBtn), countLabel),
+		widget.NewSeparator(),
+	)
+	return container.NewBorder(form, nil, nil, nil, list)
+}
+
+// showHistoryRecordDialog shows what the history knows about a past batch
+func showHistoryRecordDialog(rec HistoryRecord, w fyne.Window) {
+	text := fmt.Sprintf("📁 %s\n📄 %d个文件 · %s · %s\n🕐 %s ~ %s",
+		displayWindowsPath(rec.Folder), rec.FileCount, formatSize(rec.TotalSize), statusLabel(rec.Status),
+		formatDateTime(rec.StartTime, true), formatDateTime(rec.EndTime, true))
+	if rec.Uploader != "" {
+		text += "\n🔧 上传工具: " + rec.Uploader
+	}
+	if rec.CheckCode != "" {
+		text += "\n🔐 校验码: " + rec.CheckCode
+	}
+	if len(rec.Tags) > 0 {
+		text += "\n🏷️ 标签: " + strings.Join(rec.Tags, ", ")
+	}
+	for _, so := range rec.SignOffs {
+		text += "\n✍️ " + signOffText(so)
+	}
+	if len(rec.Timeline) > 0 {
+		text += "\n\n🕒 时间线:\n" + strings.Join(timelineLines(rec.Timeline), "\n")
+	}
+	files := rec.Files
+	if len(files) > maxDetailFiles {
+		files = files[:maxDetailFiles]
+	}
+	if len(files) > 0 {
+		text += "\n\n" + strings.Join(files, "\n")
+		if more := len(rec.Files) - len(files); more > 0 {
+			text += fmt.Sprintf("\n… 还有 %d 个文件", more)
+		}
+	}
+	label := widget.NewLabel(text)
+	label.Wrapping = fyne.TextWrapWord
+	scroll := container.NewVScroll(label)
+	scroll.SetMinSize(fyne.NewSize(380, 320))
+	dialog.ShowCustom("历史批次", "关闭", scroll, w)
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/fsnotify/fsnotify"
+)
+
+// configReloadDelay lets an editor finish writing before the file is read
+const configReloadDelay = 500 * time.Millisecond
+
+var (
+	// appliedConfig is the config the running session was last brought in
+	// line with, see configChanged
+	appliedConfig    Config
+	configListeners  []func(old, cur Config)
+	lastSavedConfig  []byte // what saveConfig last wrote, to ignore our own writes
+	activeOverrides  []configOverride
+	extCacheMu       sync.Mutex
+	extCacheKey      string
+	extCache         map[string]bool
+	excludeCacheKey  string
+	excludeCachePats []string
+)
+
+// onConfigChange registers fn to run when saved or reloaded settings differ
+// from the ones last applied
+func onConfigChange(fn func(old, cur Config)) {
+	configListeners = append(configListeners, fn)
+}
+
+// configChanged runs the change listeners if the settings differ from the
+// ones last applied. saveConfig and config reloads call it.
+func configChanged() {
+	old := appliedConfig
+	appliedConfig = config
+	appliedConfig.ScheduleDays = append([]int(nil), config.ScheduleDays...)
+	appliedConfig.Rules = append([]Rule(nil), config.Rules...)
+	appliedConfig.Plugins = append([]PluginConfig(nil), config.Plugins...)
+	appliedConfig.Presets = append([]Preset(nil), config.Presets...)
+	if reflect.DeepEqual(old, appliedConfig) {
+		return
+	}
+	for _, fn := range configListeners {
+		fn(old, config)
+	}
+}
+
+// watchSettingsChanged reports whether the watchers must be restarted to
+// apply cur: a different watch path, subfolder mode or remote source
+func watchSettingsChanged(old, cur Config) bool {
+	return old.WatchPath != cur.WatchPath ||
+		old.MonitorSubdirs != cur.MonitorSubdirs ||
+		old.SFTPHost != cur.SFTPHost || old.SFTPPort != cur.SFTPPort ||
+		old.SFTPKey != cur.SFTPKey || old.SFTPDir != cur.SFTPDir ||
+		old.WebDAVURL != cur.WebDAVURL || old.WebDAVUser != cur.WebDAVUser ||
+		old.WebDAVPass != cur.WebDAVPass ||
+		old.CloudProvider != cur.CloudProvider || old.CloudFolder != cur.CloudFolder ||
+		old.CloudClientID != cur.CloudClientID ||
+		old.RemoteInterval != cur.RemoteInterval
+}
+
+// apiSettingsChanged reports whether the API server must be restarted
+func apiSettingsChanged(old, cur Config) bool {
+	return old.APIEnabled != cur.APIEnabled || old.APIListen != cur.APIListen
+}
+
+// grpcSettingsChanged reports whether the gRPC server must be restarted
+func grpcSettingsChanged(old, cur Config) bool {
+	return old.GRPCEnabled != cur.GRPCEnabled || old.GRPCListen != cur.GRPCListen
+}
+
+// enabledExtSet returns the enabled extensions as a set. It is rebuilt
+// whenever the file type settings change, so every check sees one
+// consistent list.
+func enabledExtSet() map[string]bool {
+	key := fmtBools(config.VideoEnabled, config.ImageEnabled, config.AudioEnabled,
+		config.DocEnabled, config.ArchiveEnabled) + config.CustomExts
+
+	extCacheMu.Lock()
+	defer extCacheMu.Unlock()
+	if extCache == nil || key != extCacheKey {
+		set := make(map[string]bool)
+		for _, ext := range getEnabledExts() {
+			set[ext] = true
+		}
+		extCache, extCacheKey = set, key
+	}
+	return extCache
+}
+
+// fmtBools encodes flags as a string of 0s and 1s
+func fmtBools(flags ...bool) string {
+	b := make([]byte, len(flags))
+	for i, f := range flags {
+		b[i] = '0'
+		if f {
+			b[i] = '1'
+		}
+	}
+	return string(b)
+}
+
+// excludePatterns returns the configured exclude globs in pathKey form
+func excludePatterns() []string {
+	key := fmtBools(config.CaseInsensitive) + config.ExcludePatterns
+	extCacheMu.Lock()
+	defer extCacheMu.Unlock()
+	if key != excludeCacheKey || excludeCachePats == nil {
+		pats := []string{}
+		for _, p := range strings.Split(config.ExcludePatterns, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				pats = append(pats, pathKey(filepath.ToSlash(p)))
+			}
+		}
+		excludeCachePats, excludeCacheKey = pats, key
+	}
+	return excludeCachePats
+}
+
+// isExcluded reports whether path matches an exclude pattern
+func isExcluded(path string) bool {
+	return excludeMatch(path) != ""
+}
+
+// excludeMatch returns the exclude pattern path matches, or "". Patterns
+// without a slash match the file name, others the path below the watch root.
+func excludeMatch(path string) string {
+	pats := excludePatterns()
+	if len(pats) == 0 {
+		return ""
+	}
+	name := pathKey(filepath.Base(path))
+	rel := name
+	if r, err := filepath.Rel(filepath.Clean(monitorPath), path); err == nil && monitorPath != "" {
+		rel = pathKey(filepath.ToSlash(r))
+	}
+	for _, p := range pats {
+		target := name
+		if strings.Contains(p, "/") {
+			target = rel
+		}
+		if ok, _ := pathpkg.Match(p, target); ok {
+			return p
+		}
+	}
+	return ""
+}
+
+// reloadConfigFromDisk re-reads the config file after an outside edit and
+// re-applies the command line and environment overrides. It reports whether
+// the file differed from what was last saved.
+func reloadConfigFromDisk() (bool, error) {
+	data, err := os.ReadFile(configPath)
+	if err != nil || bytes.Equal(data, lastSavedConfig) {
+		return false, err
+	}
+	prev := config
+	if err := loadConfig(); err != nil {
+		config = prev
+		return true, err
+	}
+	lastSavedConfig = data
+	if err := applyConfigOverrides(activeOverrides); err != nil {
+		config = prev
+		return true, err
+	}
+	return true, nil
+}
+
+// reloadChangedConfig reloads the config file after an outside edit and
+// applies the changes
+func reloadChangedConfig() {
+	changed, err := reloadConfigFromDisk()
+	switch {
+	case err != nil:
+		logEvent("重新加载配置失败: %v", err)
+	case changed:
+		logEvent("配置文件已变更, 已重新加载")
+		configChanged()
+	}
+}
+
+// watchConfigFile calls reload after the config file was changed by
+// something else than this process, until ctx is cancelled. The folder is
+// watched since editors often replace the file instead of writing to it.
+func watchConfigFile(ctx context.Context, reload func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := w.Add(filepath.Dir(configPath)); err != nil {
+		w.Close()
+		return err
+	}
+	go func() {
+		defer w.Close()
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case ev, ok := <-w.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) == filepath.Clean(configPath) && ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					timer = time.After(configReloadDelay)
+				}
+			case <-w.Errors:
+			case <-timer:
+				timer = nil
+				reload()
+			}
+		}
+	}()
+	return nil
+}
+
+// watchRun is the set of watchers of one watch path within a monitoring
+// session. Restarting it applies a new path or subfolder setting without
+// ending the session.
+type watchRun struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	remote  bool
+	scanned chan struct{} // closed once the startup scan of subfolders ended
+}
+
+// startWatchRun starts watching monitorPath under parent. onDisk receives
+// free space updates for local folders. Subfolders are added in the
+// background, reporting the number of watched folders to onScan; files in
+// the folders added so far are picked up meanwhile.
+func startWatchRun(parent context.Context, updateUI func(), app fyne.App, onDisk func(free, total uint64), onScan func(dirs int)) (*watchRun, error) {
+	ctx, cancel := context.WithCancel(parent)
+	run := &watchRun{ctx: ctx, cancel: cancel, remote: isRemoteWatchPath(monitorPath), scanned: make(chan struct{})}
+	if run.remote {
+		if err := startRemoteWatch(ctx, updateUI, app); err != nil {
+			cancel()
+			return nil, err
+		}
+		close(run.scanned)
+		return run, nil
+	}
+	if err := startMonitor(monitorPath); err != nil {
+		cancel()
+		return nil, err
+	}
+	go handleFileEvents(ctx, updateUI, app)
+	go func() {
+		defer close(run.scanned)
+		start := time.Now()
+		if err := watchers.Scan(ctx, onScan); err == nil && config.MonitorSubdirs {
+			logEvent("子文件夹扫描完成: %d 个文件夹, 用时 %s", watchedDirCount(), time.Since(start).Round(time.Second))
+		}
+	}()
+	go reconcileBatches(ctx, updateUI)
+	go watchDiskSpace(ctx, monitorPath, onDisk, app)
+	return run, nil
+}
+
+// Stop ends the watchers; batches and the session are left alone
+func (r *watchRun) Stop() {
+	if r == nil {
+		return
+	}
+	r.cancel()
+	if !r.remote {
+		stopMonitor()
+	}
+}
+
+// restartAPIServer stops the API server and starts it again if enabled,
+// picking up a new listen address
+func restartAPIServer(updateUI func(), app fyne.App) {
+	if apiServer != nil {
+		apiServer.Close()
+		apiServer = nil
+	}
+	if config.APIEnabled {
+		apiServer = startAPIServer(updateUI, app)
+	}
+}
+
+// restartGRPCServer stops the gRPC server, ending open event streams, and
+// starts it again if enabled
+func restartGRPCServer(updateUI func()) {
+	if grpcServer != nil {
+		grpcServer.Stop()
+		grpcServer = nil
+	}
+	if config.GRPCEnabled {
+		grpcServer = startGRPCServer(updateUI)
+	}
+}
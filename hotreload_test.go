@@ -0,0 +1,146 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigChangedNotifiesOnce(t *testing.T) {
+	origConfig, origApplied, origListeners := config, appliedConfig, configListeners
+	defer func() {
+		config, appliedConfig, configListeners = origConfig, origApplied, origListeners
+	}()
+	config = defaultConfig()
+	configListeners = nil
+	configChanged()
+
+	calls := 0
+	var gotOld, gotCur Config
+	onConfigChange(func(old, cur Config) {
+		calls++
+		gotOld, gotCur = old, cur
+	})
+	configChanged()
+	if calls != 0 {
+		t.Fatalf("Listener called %d times without a change", calls)
+	}
+
+	config.CompletionTimeout = 90
+	config.WatchPath = "/data/in"
+	configChanged()
+	configChanged()
+	if calls != 1 {
+		t.Fatalf("Listener called %d times for one change", calls)
+	}
+	if gotOld.CompletionTimeout != 30 || gotCur.CompletionTimeout != 90 {
+		t.Errorf("Listener got timeout %d -> %d", gotOld.CompletionTimeout, gotCur.CompletionTimeout)
+	}
+	if !watchSettingsChanged(gotOld, gotCur) || apiSettingsChanged(gotOld, gotCur) {
+		t.Error("Watch path change should restart the watchers only")
+	}
+
+	// Editing the schedule in place must still be noticed
+	config.ScheduleDays = append(config.ScheduleDays[:0:0], 1, 2)
+	configChanged()
+	config.ScheduleDays[0] = 3
+	configChanged()
+	if calls != 3 {
+		t.Errorf("Listener called %d times, want 3", calls)
+	}
+}
+
+func TestEnabledExtSetFollowsConfig(t *testing.T) {
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = defaultConfig()
+	config.VideoEnabled, config.ImageEnabled = true, false
+
+	if !isMonitoredFile("/in/clip.mp4") || isMonitoredFile("/in/photo.jpg") {
+		t.Fatal("Unexpected initial file types")
+	}
+	config.ImageEnabled = true
+	config.CustomExts = "r3d"
+	if !isMonitoredFile("/in/photo.jpg") || !isMonitoredFile("/in/A001.R3D") {
+		t.Error("File type changes not applied to the next event")
+	}
+	config.VideoEnabled = false
+	if isMonitoredFile("/in/clip.mp4") {
+		t.Error("Disabled type still monitored")
+	}
+}
+
+func TestIsExcluded(t *testing.T) {
+	origConfig, origPath := config, monitorPath
+	defer func() { config, monitorPath = origConfig, origPath }()
+	config = defaultConfig()
+	config.VideoEnabled = true
+	monitorPath = filepath.FromSlash("/in")
+
+	config.ExcludePatterns = " *_proxy.mp4, cache/* ,"
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/in/clip.mp4", false},
+		{"/in/clip_proxy.mp4", true},
+		{"/in/day1/clip_proxy.mp4", true},
+		{"/in/cache/clip.mp4", true},
+		{"/in/day1/cache/clip.mp4", false},
+	}
+	for _, tt := range tests {
+		if got := isExcluded(filepath.FromSlash(tt.path)); got != tt.expected {
+			t.Errorf("isExcluded(%s) = %v, want %v", tt.path, got, tt.expected)
+		}
+	}
+	if isMonitoredFile(filepath.FromSlash("/in/clip_proxy.mp4")) {
+		t.Error("Excluded file still monitored")
+	}
+
+	config.ExcludePatterns = ""
+	if isExcluded(filepath.FromSlash("/in/clip_proxy.mp4")) {
+		t.Error("Cleared patterns still exclude")
+	}
+	config.ExcludePatterns = "*.MP4"
+	config.CaseInsensitive = true
+	if !isExcluded(filepath.FromSlash("/in/clip.mp4")) {
+		t.Error("Case-insensitive pattern did not match")
+	}
+}
+
+func TestReloadConfigFromDisk(t *testing.T) {
+	origConfig, origConfigPath, origOverrides := config, configPath, activeOverrides
+	origFile, origOverride := fileValues, overrideValues
+	defer func() {
+		config, configPath, activeOverrides = origConfig, origConfigPath, origOverrides
+		fileValues, overrideValues = origFile, origOverride
+	}()
+	configPath = filepath.Join(t.TempDir(), "config.yaml")
+	config = defaultConfig()
+	activeOverrides = []configOverride{{Key: "stall_minutes", Value: "7", Source: "--stall-minutes"}}
+	saveConfig()
+
+	// Our own writes are not reloaded
+	if changed, err := reloadConfigFromDisk(); changed || err != nil {
+		t.Fatalf("Own write reloaded: %v, %v", changed, err)
+	}
+
+	c := defaultConfig()
+	c.CompletionTimeout = 75
+	c.StallMinutes = 20
+	data, _ := encodeConfig(c, formatYAML)
+	os.WriteFile(configPath, data, 0644)
+	changed, err := reloadConfigFromDisk()
+	if !changed || err != nil {
+		t.Fatalf("External edit not reloaded: %v, %v", changed, err)
+	}
+	if config.CompletionTimeout != 75 || config.StallMinutes != 7 {
+		t.Errorf("Reloaded timeout %d, stall %d; want 75 and the override 7", config.CompletionTimeout, config.StallMinutes)
+	}
+
+	// A broken file keeps the running settings
+	os.WriteFile(configPath, []byte("completion_timeout: [oops"), 0644)
+	if _, err := reloadConfigFromDisk(); err == nil || config.CompletionTimeout != 75 {
+		t.Errorf("Broken file: err %v, timeout %d", err, config.CompletionTimeout)
+	}
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// profileIngest offloads camera cards: completed batches are copied to a
+// dated folder in config.IngestDest and verified
+const profileIngest = "ingest"
+
+// cameraRawExts are the raw formats of common cameras, added to the custom
+// extensions by the ingest preset
+var cameraRawExts = []string{".cr2", ".cr3", ".nef", ".arw", ".raf", ".orf", ".rw2", ".dng", ".srw", ".pef"}
+
+// applyIngestPreset tunes the settings for offloading camera cards: the
+// card's DCIM folder is watched when it is plugged in and each of its
+// folders (100CANON, 101CANON...) becomes a batch
+func applyIngestPreset() {
+	config.Profile = profileIngest
+	config.MonitorSubdirs = true
+	config.GroupDepth = 1
+	config.VideoEnabled = true
+	config.ImageEnabled = true
+	config.VolumeWatch = true
+	if config.VolumeSubdir == "" {
+		config.VolumeSubdir = "DCIM"
+	}
+	have := make(map[string]bool)
+	var exts []string
+	for _, ext := range strings.Split(config.CustomExts, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" {
+			have[strings.ToLower(ext)] = true
+			exts = append(exts, ext)
+		}
+	}
+	for _, ext := range cameraRawExts {
+		if !have[ext] && !have[strings.TrimPrefix(ext, ".")] {
+			exts = append(exts, ext)
+		}
+	}
+	config.CustomExts = strings.Join(exts, ",")
+}
+
+// ingestEnabled reports whether completed batches are offloaded
+func ingestEnabled() bool {
+	return config.Profile == profileIngest && config.IngestDest != ""
+}
+
+// ingestDest returns the folder a batch is offloaded to: a folder named
+// after the day and the card in destRoot, with the batch's path below the
+// watched folder, e.g. 2024-03-01_EOS_DIGITAL/100CANON
+func ingestDest(destRoot, root, folder, card string, t time.Time) string {
+	card = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(card))
+	name := t.Format("2006-01-02")
+	if card != "" {
+		name += "_" + card
+	}
+	dest := filepath.Join(destRoot, name)
+	if rel, err := filepath.Rel(filepath.Clean(root), folder); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		dest = filepath.Join(dest, rel)
+	}
+	return dest
+}
+
+// copyVerified copies src to dst at the transfer rate and reads the copy
+// back to compare its SHA-256 with the source's. A file already at dst
+// with the same content counts as copied, so a card can be offloaded
+// again; a different one is never overwritten.
+func copyVerified(ctx context.Context, src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dst); err == nil {
+		want, err := fileSum(ctx, src, "sha256")
+		if err != nil {
+			return err
+		}
+		if got, err := fileSum(ctx, dst, "sha256"); err != nil || got != want {
+			return fmt.Errorf("%s 已存在且内容不同", dst)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	tmp := dst + ".fidruawatch-tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	h := sha256.New()
+	_, err = io.Copy(out, io.TeeReader(limitReader(ctx, in), h))
+	if err == nil {
+		err = out.Sync()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		var got string
+		if got, err = fileSum(ctx, tmp, "sha256"); err == nil && got != hex.EncodeToString(h.Sum(nil)) {
+			err = fmt.Errorf("%s 复制后校验不一致", filepath.Base(src))
+		}
+	}
+	if err == nil {
+		// Keep the capture time photo tools sort by
+		os.Chtimes(tmp, info.ModTime(), info.ModTime())
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// offloadFiles copies the files of a batch (relative to folder) into dest,
+// verifying each, and reports the bytes copied so far to progress
+func offloadFiles(ctx context.Context, folder string, files []string, dest string, progress func(done int64)) error {
+	var done int64
+	for _, f := range files {
+		if isTempFile(f) {
+			continue
+		}
+		src := filepath.Join(folder, f)
+		if err := copyVerified(ctx, src, filepath.Join(dest, f)); err != nil {
+			return err
+		}
+		if info, err := os.Stat(src); err == nil {
+			done += info.Size()
+			progress(done)
+		}
+	}
+	return nil
+}
+
+// ejectChan asks the UI to stop monitoring a card and eject it
+var ejectChan = make(chan volumeInfo, 1)
+
+// offloadBatch copies a completed batch to its dated folder in
+// config.IngestDest and verifies the copies, reporting whether that
+// succeeded. The batch's Ingest and IngestPct track progress for the card.
+func offloadBatch(ctx context.Context, b *Batch, updateUI func(), app fyne.App) bool {
+	batchesMu.Lock()
+	folder := b.Folder
+	files := append([]string(nil), b.Files...)
+	total := b.TotalSize
+	b.Ingest, b.IngestPct = true, 0
+	batchesMu.Unlock()
+	updateUI()
+
+	card := volumeInfo{Mount: monitorPath}
+	if v := currentVolume.Load(); v != nil && folderUnder(folder, v.Mount) {
+		card = *v
+	}
+	dest := ingestDest(config.IngestDest, monitorPath, folder, volumeName(card), time.Now())
+
+	lastPct := 0
+	setPct := func(done int64) {
+		if total <= 0 {
+			return
+		}
+		pct := int(done * 100 / total)
+		if pct >= 100 {
+			pct = 99
+		}
+		if pct < lastPct+5 {
+			return
+		}
+		lastPct = pct
+		batchesMu.Lock()
+		b.IngestPct = pct
+		batchesMu.Unlock()
+		updateUI()
+	}
+
+	done, err := transferJobs.Acquire(ctx)
+	if err == nil {
+		err = offloadFiles(ctx, folder, files, dest, setPct)
+		done()
+	}
+
+	batchesMu.Lock()
+	b.Ingest = false
+	if err != nil {
+		batchesMu.Unlock()
+		logEvent("导入批次 %s 失败: %v", b.ID, err)
+		sendNotification(app, "FidruaWatch - 导入失败", fmt.Sprintf("%s: %v", displayFolder(folder), err))
+		updateUI()
+		return false
+	}
+	b.IngestTo = dest
+	noteTimeline(b, timelineIngested, dest, time.Now())
+	batchesMu.Unlock()
+	logEvent("批次 %s 已导入并校验: %s", b.ID, dest)
+	sendNotification(app, "FidruaWatch - 导入完成", fmt.Sprintf("%s → %s\n%d 个文件已校验", displayFolder(folder), displayWindowsPath(dest), len(files)))
+	updateUI()
+	return true
+}
+
+// ejectIdleCard ejects the card holding b unless one of its batches is
+// still arriving, being copied or post-processed; the last one to finish
+// ejects it
+func ejectIdleCard(b *Batch) {
+	v := currentVolume.Load()
+	if v == nil {
+		return
+	}
+	batchesMu.RLock()
+	onCard := folderUnder(b.Folder, v.Mount)
+	busy := false
+	for _, o := range batches {
+		if folderUnder(o.Folder, v.Mount) && (o.Status == "uploading" || o.Ingest || o.Packing || o.PostProc) {
+			busy = true
+		}
+	}
+	batchesMu.RUnlock()
+	if !onCard || busy {
+		return
+	}
+	select {
+	case ejectChan <- *v:
+	default:
+	}
+}
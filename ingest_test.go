@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyIngestPreset(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config = Config{CustomExts: "mkv, CR2"}
+
+	applyIngestPreset()
+	if config.Profile != profileIngest || !config.VolumeWatch || config.VolumeSubdir != "DCIM" || config.GroupDepth != 1 {
+		t.Errorf("preset config = %+v", config)
+	}
+	exts := strings.Split(config.CustomExts, ",")
+	if exts[0] != "mkv" || exts[1] != "CR2" || strings.Contains(config.CustomExts, ".cr2") || !strings.Contains(config.CustomExts, ".nef") {
+		t.Errorf("custom exts = %q", config.CustomExts)
+	}
+}
+
+func TestIngestDest(t *testing.T) {
+	day := time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local)
+	root := filepath.FromSlash("/media/card/DCIM")
+	got := ingestDest(filepath.FromSlash("/photos"), root, filepath.Join(root, "100CANON"), "EOS:DIGITAL", day)
+	if want := filepath.FromSlash("/photos/2024-03-01_EOS_DIGITAL/100CANON"); got != want {
+		t.Errorf("ingestDest = %q, want %q", got, want)
+	}
+	if got := ingestDest("/photos", root, root, "", day); got != filepath.Join("/photos", "2024-03-01") {
+		t.Errorf("batch at the root: %q", got)
+	}
+}
+
+func TestOffloadFiles(t *testing.T) {
+	card, dest := t.TempDir(), t.TempDir()
+	os.MkdirAll(filepath.Join(card, "sub"), 0755)
+	os.WriteFile(filepath.Join(card, "a.jpg"), []byte("photo a"), 0644)
+	os.WriteFile(filepath.Join(card, "sub", "b.cr3"), []byte("raw b"), 0644)
+	taken := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	os.Chtimes(filepath.Join(card, "a.jpg"), taken, taken)
+
+	var copied int64
+	files := []string{"a.jpg", filepath.Join("sub", "b.cr3")}
+	if err := offloadFiles(context.Background(), card, files, dest, func(done int64) { copied = done }); err != nil {
+		t.Fatal(err)
+	}
+	if copied != 12 {
+		t.Errorf("progress = %d bytes, want 12", copied)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "sub", "b.cr3")); string(data) != "raw b" {
+		t.Errorf("copy = %q", data)
+	}
+	if info, err := os.Stat(filepath.Join(dest, "a.jpg")); err != nil || !info.ModTime().Equal(taken) {
+		t.Errorf("copy lost its time: %v", err)
+	}
+
+	// Offloading the same card again is fine, a different file is kept
+	if err := offloadFiles(context.Background(), card, files, dest, func(int64) {}); err != nil {
+		t.Errorf("second offload: %v", err)
+	}
+	os.WriteFile(filepath.Join(card, "a.jpg"), []byte("another photo"), 0644)
+	if err := offloadFiles(context.Background(), card, files, dest, func(int64) {}); err == nil {
+		t.Error("different file at the destination was not reported")
+	}
+	if data, _ := os.ReadFile(filepath.Join(dest, "a.jpg")); string(data) != "photo a" {
+		t.Errorf("destination overwritten: %q", data)
+	}
+}
+
+func TestPostProcessingPacksThenOffloads(t *testing.T) {
+	saved, savedPath := config, monitorPath
+	defer func() { config, monitorPath = saved, savedPath }()
+
+	dir := t.TempDir()
+	monitorPath = filepath.Join(dir, "card")
+	folder := filepath.Join(monitorPath, "100CANON")
+	os.MkdirAll(folder, 0755)
+	b := &Batch{ID: "1", Folder: folder, Status: "completed", FileSizes: map[string]int64{}}
+	for _, name := range []string{"IMG_0001.CR3", "IMG_0002.CR3"} {
+		data := strings.Repeat(name, 10000)
+		os.WriteFile(filepath.Join(folder, name), []byte(data), 0644)
+		b.Files = append(b.Files, name)
+		b.FileSizes[name] = int64(len(data))
+		b.TotalSize += int64(len(data))
+	}
+
+	// Both actions delete the originals, which must wait for both copies
+	config = Config{
+		PackEnabled: true, PackDeleteOrig: true, PackDir: filepath.Join(dir, "zips"),
+		Profile: profileIngest, IngestDest: filepath.Join(dir, "ingest"), IngestClearCard: true,
+	}
+	batchesMu.Lock()
+	startPostProcessing(context.Background(), b, func() {}, nil)
+	batchesMu.Unlock()
+	for deadline := time.Now().Add(30 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		batchesMu.RLock()
+		running := b.PostProc
+		batchesMu.RUnlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("post-processing did not finish")
+		}
+	}
+
+	if b.PackPath == "" || b.IngestTo == "" {
+		t.Fatalf("PackPath = %q, IngestTo = %q, want both", b.PackPath, b.IngestTo)
+	}
+	for _, name := range b.Files {
+		if _, err := os.Stat(filepath.Join(b.IngestTo, name)); err != nil {
+			t.Errorf("%s not offloaded: %v", name, err)
+		}
+		if _, err := os.Stat(filepath.Join(folder, name)); !os.IsNotExist(err) {
+			t.Errorf("%s still on the card", name)
+		}
+	}
+}
+
+func TestEjectIdleCard(t *testing.T) {
+	card := &volumeInfo{Mount: filepath.Join(t.TempDir(), "card")}
+	currentVolume.Store(card)
+	defer currentVolume.Store(nil)
+	a := &Batch{ID: "a", Folder: filepath.Join(card.Mount, "DCIM", "100CANON"), Status: "completed"}
+	other := &Batch{ID: "b", Folder: filepath.Join(card.Mount, "DCIM", "101CANON"), Status: "completed", PostProc: true}
+	batchesMu.Lock()
+	saved := batches
+	batches = map[string]*Batch{"a": a, "b": other}
+	batchesMu.Unlock()
+	defer func() {
+		batchesMu.Lock()
+		batches = saved
+		batchesMu.Unlock()
+	}()
+
+	ejectIdleCard(a)
+	select {
+	case <-ejectChan:
+		t.Error("card ejected while another of its batches is still checked")
+	default:
+	}
+
+	batchesMu.Lock()
+	other.PostProc = false
+	batchesMu.Unlock()
+	ejectIdleCard(a)
+	select {
+	case v := <-ejectChan:
+		if v.Mount != card.Mount {
+			t.Errorf("ejected %q, want %q", v.Mount, card.Mount)
+		}
+	default:
+		t.Error("idle card not ejected")
+	}
+}
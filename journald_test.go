@@ -0,0 +1,35 @@
+//go:build !windows
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestJournaldEntry(t *testing.T) {
+	entry := journaldEntry(sysLogError, "文件校验失败", map[string]string{"BATCH_ID": "42", "SESSION_ID": ""})
+	for _, line := range []string{
+		"MESSAGE=文件校验失败\n",
+		"PRIORITY=3\n",
+		"SYSLOG_IDENTIFIER=fidruawatch\n",
+		"FIDRUAWATCH_BATCH_ID=42\n",
+	} {
+		if !bytes.Contains(entry, []byte(line)) {
+			t.Errorf("Entry %q lacks %q", entry, line)
+		}
+	}
+	if bytes.Contains(entry, []byte("SESSION_ID")) {
+		t.Error("Empty fields should be left out")
+	}
+
+	// Multi-line values are length-prefixed
+	entry = journaldEntry(sysLogInfo, "a\nb", nil)
+	want := []byte("MESSAGE\n")
+	want = binary.LittleEndian.AppendUint64(want, 3)
+	want = append(want, "a\nb\n"...)
+	if !bytes.HasPrefix(entry, want) {
+		t.Errorf("Entry = %q, want prefix %q", entry, want)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// launchAgentLabel is the launchd label of the login item, matching the app ID
+const launchAgentLabel = "com.fidrua.watch"
+
+// runLaunchctl runs launchctl with args and returns its trimmed output; a
+// variable so tests can stand in for launchd
+var runLaunchctl = func(args ...string) (string, error) {
+	out, err := exec.Command("launchctl", args...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// launchAgentPath returns ~/Library/LaunchAgents/com.fidrua.watch.plist
+func launchAgentPath() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, "Library", "LaunchAgents", launchAgentLabel+".plist"), nil
+}
+
+// plistString renders a <string> element with s escaped
+func plistString(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return "<string>" + b.String() + "</string>"
+}
+
+// launchAgentPlist returns the LaunchAgent starting exePath with args at
+// login. With keepAlive, launchd relaunches the app when it crashes, but not
+// when it is quit normally.
+func launchAgentPlist(exePath string, args []string, keepAlive bool) string {
+	var program strings.Builder
+	for _, a := range append([]string{exePath}, args...) {
+		program.WriteString("\n        " + plistString(a))
+	}
+	var extra string
+	if keepAlive {
+		extra = `
+    <key>KeepAlive</key>
+    <dict>
+        <key>SuccessfulExit</key>
+        <false/>
+    </dict>
+    <key>ThrottleInterval</key>
+    <integer>10</integer>`
+	}
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+    <key>Label</key>
+    %s
+    <key>ProgramArguments</key>
+    <array>%s
+    </array>
+    <key>RunAtLoad</key>
+    <true/>
+    <key>ProcessType</key>
+    <string>Interactive</string>
+    <key>LimitLoadToSessionType</key>
+    <string>Aqua</string>%s
+</dict>
+</plist>
+`, plistString(launchAgentLabel), program.String(), extra)
+}
+
+// setAutoStartMacOS writes or removes the LaunchAgent and updates launchd
+// right away, so the change does not wait for the next login. Starting
+// hidden is the --minimized argument; LSUIElement itself belongs in the
+// app bundle's Info.plist.
+func setAutoStartMacOS(exePath string, args []string, enable bool) error {
+	plistPath, err := launchAgentPath()
+	if err != nil {
+		return err
+	}
+	if enable {
+		if err := os.MkdirAll(filepath.Dir(plistPath), 0755); err != nil {
+			return err
+		}
+		plist := launchAgentPlist(exePath, args, config.LoginKeepAlive)
+		if err := writeFileAtomic(plistPath, []byte(plist), 0644); err != nil {
+			return fmt.Errorf("无法写入 %s: %v", plistPath, err)
+		}
+		return reloadLaunchAgent(plistPath, true)
+	}
+	if _, err := os.Stat(plistPath); os.IsNotExist(err) {
+		return nil
+	}
+	if err := reloadLaunchAgent(plistPath, false); err != nil {
+		return err
+	}
+	return os.Remove(plistPath)
+}
+
+// reloadLaunchAgent brings launchd in line with the plist: a loaded job is
+// booted out and, when enabled, bootstrapped again with the new options. A
+// job that isn't loaded is only enabled, since bootstrapping it would start
+// a second copy of the app now instead of at the next login. When this
+// process is the job itself, launchd is left alone, as booting it out would
+// quit the app.
+func reloadLaunchAgent(plistPath string, enable bool) error {
+	if os.Getenv("XPC_SERVICE_NAME") == launchAgentLabel {
+		logEvent("登录项已更新, 将在下次登录时生效")
+		return nil
+	}
+	domain := fmt.Sprintf("gui/%d", os.Getuid())
+	target := domain + "/" + launchAgentLabel
+	_, err := runLaunchctl("print", target)
+	loaded := err == nil
+	if loaded {
+		if out, err := runLaunchctl("bootout", domain, plistPath); err != nil {
+			return fmt.Errorf("launchctl bootout 失败: %v %s", err, out)
+		}
+	}
+	if !enable {
+		return nil
+	}
+	if out, err := runLaunchctl("enable", target); err != nil {
+		return fmt.Errorf("launchctl enable 失败: %v %s", err, out)
+	}
+	if loaded {
+		if out, err := runLaunchctl("bootstrap", domain, plistPath); err != nil {
+			return fmt.Errorf("launchctl bootstrap 失败: %v %s", err, out)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLaunchAgentPlist(t *testing.T) {
+	plist := launchAgentPlist("/Applications/A&B.app/Contents/MacOS/fidruawatch", []string{"--minimized"}, false)
+	if err := xml.Unmarshal([]byte(plist), new(struct{})); err != nil {
+		t.Fatalf("Plist is not valid XML: %v\n%s", err, plist)
+	}
+	for _, want := range []string{"<string>/Applications/A&amp;B.app/Contents/MacOS/fidruawatch</string>", "<string>--minimized</string>", "<key>RunAtLoad</key>"} {
+		if !strings.Contains(plist, want) {
+			t.Errorf("Plist lacks %s:\n%s", want, plist)
+		}
+	}
+	if strings.Contains(plist, "KeepAlive") {
+		t.Error("KeepAlive set without the option")
+	}
+	if plist := launchAgentPlist("/x", nil, true); !strings.Contains(plist, "<key>SuccessfulExit</key>\n        <false/>") {
+		t.Errorf("KeepAlive should restart only after a crash:\n%s", plist)
+	}
+}
+
+func TestReloadLaunchAgent(t *testing.T) {
+	t.Setenv("XPC_SERVICE_NAME", "")
+	origRun := runLaunchctl
+	defer func() { runLaunchctl = origRun }()
+	var calls []string
+	loaded := false
+	runLaunchctl = func(args ...string) (string, error) {
+		calls = append(calls, args[0])
+		if args[0] == "print" && !loaded {
+			return "", fmt.Errorf("not found")
+		}
+		return "", nil
+	}
+
+	// Not loaded: enable for the next login without starting a second copy
+	if err := reloadLaunchAgent("/tmp/a.plist", true); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(calls, " "); got != "print enable" {
+		t.Errorf("Unloaded job: launchctl %s", got)
+	}
+
+	// Loaded: replace the job so new options apply now
+	calls, loaded = nil, true
+	reloadLaunchAgent("/tmp/a.plist", true)
+	if got := strings.Join(calls, " "); got != "print bootout enable bootstrap" {
+		t.Errorf("Loaded job: launchctl %s", got)
+	}
+	calls = nil
+	reloadLaunchAgent("/tmp/a.plist", false)
+	if got := strings.Join(calls, " "); got != "print bootout" {
+		t.Errorf("Disabling: launchctl %s", got)
+	}
+
+	// Running as the job: leave launchd alone
+	os.Setenv("XPC_SERVICE_NAME", launchAgentLabel)
+	calls = nil
+	reloadLaunchAgent("/tmp/a.plist", false)
+	if len(calls) != 0 {
+		t.Errorf("Booted out our own job: %v", calls)
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/widget"
+)
+
+// liveLabel is a batch card line kept up to date by runLiveLabels, for
+// text that changes with time rather than with batch events
+type liveLabel struct {
+	batch *Batch
+	label *widget.Label
+	text  func(b *Batch, now time.Time) string
+}
+
+var (
+	liveLabelsMu sync.Mutex
+	liveLabels   []liveLabel
+)
+
+// resetLiveLabels forgets the labels of the previous batch list, before
+// it is rebuilt
+func resetLiveLabels() {
+	liveLabelsMu.Lock()
+	liveLabels = nil
+	liveLabelsMu.Unlock()
+}
+
+// newLiveLabel returns a batch card line showing text(b, now) and keeps it
+// ticking. Caller must hold batchesMu.
+func newLiveLabel(b *Batch, text func(b *Batch, now time.Time) string) *widget.Label {
+	label := widget.NewLabel(text(b, time.Now()))
+	liveLabelsMu.Lock()
+	liveLabels = append(liveLabels, liveLabel{b, label, text})
+	liveLabelsMu.Unlock()
+	return label
+}
+
+// runLiveLabels updates the live labels every second until ctx is
+// cancelled, without rebuilding the batch list
+func runLiveLabels(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		liveLabelsMu.Lock()
+		list := append([]liveLabel(nil), liveLabels...)
+		liveLabelsMu.Unlock()
+		if len(list) == 0 {
+			continue
+		}
+		now := time.Now()
+		texts := make([]string, len(list))
+		batchesMu.RLock()
+		for i, l := range list {
+			texts[i] = l.text(l.batch, now)
+		}
+		batchesMu.RUnlock()
+		fyne.Do(func() {
+			for i, l := range list {
+				l.label.SetText(texts[i])
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2/lang"
+)
+
+// localeFormat is how sizes, decimals and times are written in a locale
+type localeFormat struct {
+	Decimal  string // decimal separator
+	ByteUnit string // "B", or "o" for octets
+	Clock12  bool   // 12-hour clock with AM/PM
+	Date     string // full date layout
+	MonthDay string // date layout without the year
+}
+
+// localeFormats by language or language-region tag. Anything else is
+// written the Chinese way, like the rest of the UI.
+var localeFormats = map[string]localeFormat{
+	"zh":    {".", "B", false, "2006-01-02", "01-02"},
+	"ja":    {".", "B", false, "2006/01/02", "01/02"},
+	"ko":    {".", "B", false, "2006. 01. 02.", "01. 02."},
+	"en":    {".", "B", true, "01/02/2006", "01/02"},
+	"en-GB": {".", "B", false, "02/01/2006", "02/01"},
+	"de":    {",", "B", false, "02.01.2006", "02.01."},
+	"fr":    {",", "o", false, "02/01/2006", "02/01"},
+	"es":    {",", "B", false, "02/01/2006", "02/01"},
+	"it":    {",", "B", false, "02/01/2006", "02/01"},
+	"pt":    {",", "B", false, "02/01/2006", "02/01"},
+	"ru":    {",", "Б", false, "02.01.2006", "02.01"},
+}
+
+// localeOptions are the choices of the format setting; "" follows the system
+var localeOptions = []struct {
+	Tag   string
+	Label string
+}{
+	{"", "跟随系统"},
+	{"zh-CN", "中文"},
+	{"en-US", "English (US)"},
+	{"en-GB", "English (UK)"},
+	{"de-DE", "Deutsch"},
+	{"fr-FR", "Français"},
+	{"es-ES", "Español"},
+	{"ja-JP", "日本語"},
+	{"ru-RU", "Русский"},
+}
+
+// systemLocale is looked up once, it doesn't change while running
+var systemLocale = sync.OnceValue(func() string {
+	return string(lang.SystemLocale())
+})
+
+// localeFormatFor returns the formats of a tag like "en-GB", trying the
+// language and region first, then the language alone
+func localeFormatFor(tag string) localeFormat {
+	tag = strings.ReplaceAll(tag, "_", "-")
+	parts := strings.Split(tag, "-")
+	if len(parts) > 1 {
+		if f, ok := localeFormats[strings.ToLower(parts[0])+"-"+strings.ToUpper(parts[1])]; ok {
+			return f
+		}
+	}
+	if f, ok := localeFormats[strings.ToLower(parts[0])]; ok {
+		return f
+	}
+	return localeFormats["zh"]
+}
+
+// currentLocale is the format selected in the settings
+func currentLocale() localeFormat {
+	if config.Locale != "" {
+		return localeFormatFor(config.Locale)
+	}
+	return localeFormatFor(systemLocale())
+}
+
+// formatDecimal writes v with one decimal in the current locale
+func formatDecimal(v float64) string {
+	return strings.Replace(strconv.FormatFloat(v, 'f', 1, 64), ".", currentLocale().Decimal, 1)
+}
+
+// formatClock writes the time of day in the current locale
+func formatClock(t time.Time, seconds bool) string {
+	layout := "15:04"
+	clock12 := currentLocale().Clock12
+	if clock12 {
+		layout = "3:04"
+	}
+	if seconds {
+		layout += ":05"
+	}
+	if clock12 {
+		layout += " PM"
+	}
+	return t.Format(layout)
+}
+
+// formatDate writes the full date in the current locale
+func formatDate(t time.Time) string {
+	return t.Format(currentLocale().Date)
+}
+
+// formatDateTime writes the full date and time of day
+func formatDateTime(t time.Time, seconds bool) string {
+	return formatDate(t) + " " + formatClock(t, seconds)
+}
+
+// formatBatchTime writes a timestamp as short as it stays unambiguous:
+// the time alone today, day and month this year, the full date otherwise
+func formatBatchTime(t, now time.Time) string {
+	t, now = t.Local(), now.Local()
+	switch {
+	case dayKey(t) == dayKey(now):
+		return formatClock(t, true)
+	case t.Year() == now.Year():
+		return t.Format(currentLocale().MonthDay) + " " + formatClock(t, false)
+	}
+	return formatDateTime(t, false)
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// useLocale pins the number and time format for the rest of the test
+func useLocale(t *testing.T, tag string) {
+	orig := config.Locale
+	t.Cleanup(func() { config.Locale = orig })
+	config.Locale = tag
+}
+
+func TestLocaleFormatFor(t *testing.T) {
+	for _, tc := range []struct {
+		tag  string
+		want string // full date layout
+	}{
+		{"en-US", "01/02/2006"},
+		{"en_GB", "02/01/2006"},
+		{"de-AT", "02.01.2006"},
+		{"zh-Hans-CN", "2006-01-02"},
+		{"xx", "2006-01-02"},
+	} {
+		if got := localeFormatFor(tc.tag).Date; got != tc.want {
+			t.Errorf("%s date layout = %s, want %s", tc.tag, got, tc.want)
+		}
+	}
+}
+
+func TestLocalizedFormatting(t *testing.T) {
+	at := time.Date(2024, 3, 4, 15, 7, 9, 0, time.Local)
+	for _, tc := range []struct {
+		tag, size, clock, date string
+	}{
+		{"zh-CN", "1.5 KB", "15:07:09", "2024-03-04"},
+		{"en-US", "1.5 KB", "3:07:09 PM", "03/04/2024"},
+		{"de-DE", "1,5 KB", "15:07:09", "04.03.2024"},
+		{"fr-FR", "1,5 Ko", "15:07:09", "04/03/2024"},
+	} {
+		useLocale(t, tc.tag)
+		if got := formatSize(1536); got != tc.size {
+			t.Errorf("%s: formatSize = %q, want %q", tc.tag, got, tc.size)
+		}
+		if got := formatClock(at, true); got != tc.clock {
+			t.Errorf("%s: formatClock = %q, want %q", tc.tag, got, tc.clock)
+		}
+		if got := formatDate(at); got != tc.date {
+			t.Errorf("%s: formatDate = %q, want %q", tc.tag, got, tc.date)
+		}
+	}
+}
+
+func TestFormatBatchTime(t *testing.T) {
+	useLocale(t, "zh-CN")
+	now := time.Date(2024, 3, 4, 18, 0, 0, 0, time.Local)
+	for _, tc := range []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Date(2024, 3, 4, 9, 5, 0, 0, time.Local), "09:05:00"},
+		{time.Date(2024, 1, 20, 9, 5, 0, 0, time.Local), "01-20 09:05"},
+		{time.Date(2023, 12, 31, 9, 5, 0, 0, time.Local), "2023-12-31 09:05"},
+	} {
+		if got := formatBatchTime(tc.t, now); got != tc.want {
+			t.Errorf("formatBatchTime(%v) = %q, want %q", tc.t, got, tc.want)
+		}
+	}
+}
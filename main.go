@@ -1,14 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"image/color"
 	"net/url"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
 	"sort"
 	"strings"
@@ -21,48 +23,47 @@ import (
 	"fyne.io/fyne/v2/container"
 	"fyne.io/fyne/v2/dialog"
 	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/storage"
 	"fyne.io/fyne/v2/theme"
 	"fyne.io/fyne/v2/widget"
 	"github.com/fsnotify/fsnotify"
+	"golang.org/x/text/unicode/norm"
 )
 
 // Custom dark theme with blue tint
-type customTheme struct{}
+// customTheme is the app theme: dark, light or following the system, with a
+// configurable accent color
+type customTheme struct {
+	mode   string
+	accent color.NRGBA
+	scale  float32
+}
 
 func (t *customTheme) Color(name fyne.ThemeColorName, variant fyne.ThemeVariant) color.Color {
-	switch name {
-	case theme.ColorNameBackground:
-		return color.NRGBA{R: 20, G: 22, B: 35, A: 255} // Dark blue background
-	case theme.ColorNameButton:
-		return color.NRGBA{R: 45, G: 50, B: 80, A: 255}
-	case theme.ColorNameDisabledButton:
-		return color.NRGBA{R: 35, G: 40, B: 60, A: 255}
-	case theme.ColorNameInputBackground:
-		return color.NRGBA{R: 30, G: 35, B: 55, A: 255}
-	case theme.ColorNameOverlayBackground:
-		return color.NRGBA{R: 25, G: 28, B: 45, A: 255}
-	case theme.ColorNameMenuBackground:
-		return color.NRGBA{R: 30, G: 35, B: 55, A: 255}
-	case theme.ColorNameSeparator:
-		return color.NRGBA{R: 60, G: 65, B: 90, A: 255}
-	case theme.ColorNamePrimary:
-		return color.NRGBA{R: 138, G: 43, B: 226, A: 255} // Purple
-	case theme.ColorNameForeground:
-		return color.NRGBA{R: 220, G: 220, B: 230, A: 255}
-	}
-	return theme.DarkTheme().Color(name, variant)
+	v := t.variant(variant)
+	if name == theme.ColorNamePrimary {
+		return t.accent
+	}
+	palette := darkPalette
+	if v == theme.VariantLight {
+		palette = lightPalette
+	}
+	if c, ok := palette[name]; ok {
+		return c
+	}
+	return theme.DefaultTheme().Color(name, v)
 }
 
 func (t *customTheme) Font(style fyne.TextStyle) fyne.Resource {
-	return theme.DarkTheme().Font(style)
+	return theme.DefaultTheme().Font(style)
 }
 
 func (t *customTheme) Icon(name fyne.ThemeIconName) fyne.Resource {
-	return theme.DarkTheme().Icon(name)
+	return theme.DefaultTheme().Icon(name)
 }
 
 func (t *customTheme) Size(name fyne.ThemeSizeName) float32 {
-	return theme.DarkTheme().Size(name)
+	return theme.DefaultTheme().Size(name) * t.scale
 }
 
 // Batch represents an upload batch
@@ -75,42 +76,203 @@ type Batch struct {
 	Status    string
 	StartTime time.Time
 	LastTime  time.Time
+	Uploader  string                 // transfer tool guessed from temp file patterns, empty if unknown
+	Samples   *SampleRing            // recent TotalSize observations for rate/ETA
+	Growth    map[string]*SampleRing // recent sizes per file while uploading, see noteFileSize
+	SessionID string                 // monitoring session the batch was detected in
+	CheckCode string                 // short manifest hash, set when the batch completes
+	GrowTime  time.Time              // last time a file was added or grew
+	Stalled   bool                   // uploading but nothing grew for config.StallMinutes
+	DupFiles  []string               // files matching an earlier batch by name and size
+	Media     map[string]VideoInfo   // ffprobe metadata of video files, by file
+	Exif      *ExifSummary           // capture dates and cameras of the images
+	Archive   string                 // archive check result: archiveOK, archiveBad or unchecked
+	BadFiles  []string               // archives that failed the check
+	Packing   bool                   // being zipped by the packaging action
+	PackPct   int                    // packaging progress while Packing
+	PackPath  string                 // zip the batch was packed into
+	Ingest    bool                   // being copied off the card by the ingest profile
+	IngestPct int                    // copy progress while Ingest
+	IngestTo  string                 // folder the batch was offloaded to
+	PostProc  bool                   // completion checks and actions still running
+	SignOffs  []SignOff              // who reviewed and signed, in order
+	DoneTime  time.Time              // when the batch last completed, start of the reopen window
+	Locked    []string               // files another process still has open for writing
+	LockCheck time.Time              // when Locked was last refreshed, see refreshLockedFiles
+	Manifest  *Manifest              // expected files, completion waits until they all arrived
+	Checksum  string                 // checksum verification result: checksumOK, checksumBad or unchecked
+	Sums      map[string]string      // per-file checksum results: sumOK, sumMismatch or sumMissing
+	Tags      []string               // added by tag rules
+	Timeline  []TimelineEntry        // how the batch arrived, see noteTimeline
+	Gaps      []time.Duration        // recent times between arrivals, for the adaptive timeout
 }
 
 // Config represents app settings
 type Config struct {
-	VideoEnabled      bool   `json:"video_enabled"`
-	ImageEnabled      bool   `json:"image_enabled"`
-	AudioEnabled      bool   `json:"audio_enabled"`
-	DocEnabled        bool   `json:"doc_enabled"`
-	ArchiveEnabled    bool   `json:"archive_enabled"`
-	CustomExts        string `json:"custom_exts"`
-	MonitorSubdirs    bool   `json:"monitor_subdirs"`
-	CompletionTimeout int    `json:"completion_timeout"`
-	NotifyOnStart     bool   `json:"notify_on_start"`
-	NotifyOnComplete  bool   `json:"notify_on_complete"`
-	SoundEnabled      bool   `json:"sound_enabled"`
-	SoundStart        string `json:"sound_start"`    // sound for upload start
-	SoundComplete     string `json:"sound_complete"` // sound for upload complete
-	SaveHistory       bool   `json:"save_history"`
-	AutoStart         bool   `json:"auto_start"`
-	RemindUnsigned    bool   `json:"remind_unsigned"`
-	RemindInterval    int    `json:"remind_interval"` // seconds, default 60
+	Version           int            `json:"version"` // config schema version, see configVersion
+	VideoEnabled      bool           `json:"video_enabled"`
+	ImageEnabled      bool           `json:"image_enabled"`
+	AudioEnabled      bool           `json:"audio_enabled"`
+	DocEnabled        bool           `json:"doc_enabled"`
+	ArchiveEnabled    bool           `json:"archive_enabled"`
+	CustomExts        string         `json:"custom_exts"`
+	MonitorSubdirs    bool           `json:"monitor_subdirs"`
+	ExcludePatterns   string         `json:"exclude_patterns"` // comma-separated name globs to ignore, e.g. *.part, cache/*
+	CompletionTimeout int            `json:"completion_timeout"`
+	AdaptiveTimeout   bool           `json:"adaptive_timeout"` // per batch from its arrival gaps instead, see batchTimeout
+	AdaptiveMin       int            `json:"adaptive_min"`     // bounds of the adaptive timeout, in seconds
+	AdaptiveMax       int            `json:"adaptive_max"`     //
+	NotifyOnStart     bool           `json:"notify_on_start"`
+	NotifyOnComplete  bool           `json:"notify_on_complete"`
+	NotifyPerMinute   int            `json:"notify_per_minute"` // desktop notifications shown per minute at most, 0 = no limit
+	MuteMinutes       int            `json:"mute_minutes"`      // how long the mute toggle silences sounds and popups, 0 = until turned off
+	ReattachRoots     bool           `json:"reattach_roots"`    // watch a lost folder again when it reappears, e.g. a remounted share
+	SoundEnabled      bool           `json:"sound_enabled"`
+	SoundStart        string         `json:"sound_start"`    // sound for upload start
+	SoundComplete     string         `json:"sound_complete"` // sound for upload complete
+	SaveHistory       bool           `json:"save_history"`
+	HistoryKeepDays   int            `json:"history_keep_days"`   // prune history older than this on startup, 0 = forever
+	HistoryMaxBatches int            `json:"history_max_batches"` // keep at most this many batches in the history, 0 = no limit
+	HistoryMaxMB      int            `json:"history_max_mb"`      // size limit of the history file, 0 = no limit
+	StorageEngine     string         `json:"storage_engine"`      // history and checkpoint storage: json or sqlite
+	AutoStart         bool           `json:"auto_start"`
+	StartMinimized    bool           `json:"start_minimized"`  // auto-start hidden in the system tray
+	MaxBatches        int            `json:"max_batches"`      // finished batches kept in memory, 0 = no limit
+	KeepBatchHours    int            `json:"keep_batch_hours"` // drop finished batches from memory after this long, 0 = never
+	ConfirmQuit       bool           `json:"confirm_quit"`     // ask before quitting while batches are uploading
+	LoginKeepAlive    bool           `json:"login_keep_alive"` // macOS: relaunch the login item after a crash
+	RemindUnsigned    bool           `json:"remind_unsigned"`
+	RemindInterval    int            `json:"remind_interval"`    // seconds, default 60
+	GroupDepth        int            `json:"group_depth"`        // 0 = group by parent folder, N = group at depth N below watch root
+	RescanInterval    int            `json:"rescan_interval"`    // seconds between reconciliation rescans, 0 disables
+	SampleBufferSize  int            `json:"sample_buffer_size"` // advanced: max size samples kept per batch
+	SampleResolution  int            `json:"sample_resolution"`  // advanced: seconds per sample, finer samples are merged
+	CaseInsensitive   bool           `json:"case_insensitive"`   // compare file names case-insensitively (always on for Windows)
+	ZeroByteMode      string         `json:"zero_byte_mode"`     // include, ignore, hold or flag empty files
+	BatchSort         string         `json:"batch_sort"`         // batch list order, see batchSortOptions
+	APIEnabled        bool           `json:"api_enabled"`        // serve the local HTTP API
+	APIListen         string         `json:"api_listen"`         // API listen address, e.g. 127.0.0.1:8765
+	APIToken          string         `json:"api_token"`          // bearer token required by the API, empty = none
+	SignToken         string         `json:"sign_token"`         // bearer token that may also sign; when set, the API token only views, and without an API token every call needs it
+	GRPCEnabled       bool           `json:"grpc_enabled"`       // serve the gRPC API, with the same tokens
+	GRPCListen        string         `json:"grpc_listen"`        // gRPC listen address, host:port or unix:<socket path>
+	EventLog          bool           `json:"event_log"`          // append batch events to events.jsonl
+	EventLogMaxMB     int            `json:"event_log_max_mb"`   // rotate events.jsonl past this size, 0 = daily only
+	EventLogKeep      int            `json:"event_log_keep"`     // rotated event logs kept, 0 = all
+	SysLog            string         `json:"sys_log"`            // system log backend: off, syslog, journald or eventlog
+	SummaryEnabled    bool           `json:"summary_enabled"`    // send a scheduled summary notification
+	SummaryTime       string         `json:"summary_time"`       // "HH:MM" local time
+	SummaryPeriod     string         `json:"summary_period"`     // daily or weekly
+	Profile           string         `json:"profile"`            // monitoring profile, see profileOptions
+	GroupTimeWindow   int            `json:"group_time_window"`  // seconds; downloads profile groups files arriving within it
+	LowDiskWarnGB     int            `json:"low_disk_warn_gb"`   // warn below this much free space while uploading, 0 disables
+	StallMinutes      int            `json:"stall_minutes"`      // flag uploading batches with no growth for this long, 0 disables
+	StallAlert        bool           `json:"stall_alert"`        // notify when a batch stalls
+	ReopenMinutes     int            `json:"reopen_minutes"`     // reopen a completed, unsigned batch when its folder changes within this long, 0 = new batch
+	CheckFileLocks    bool           `json:"check_file_locks"`   // hold completion while another process has a file open for writing
+	PairSidecars      bool           `json:"pair_sidecars"`      // count .xmp/.srt/.thm/.lrc with their primary file, see sidecarExts
+	ProbeVideo        bool           `json:"probe_video"`        // read video metadata with ffprobe when a batch completes
+	ThumbCacheMB      int            `json:"thumb_cache_mb"`     // size limit of the thumbnail cache
+	VerifyArchives    bool           `json:"verify_archives"`    // test completed zip/gz/7z/rar files
+	VerifyChecksums   bool           `json:"verify_checksums"`   // check completed files against .md5/.sha256 files shipped with them
+	PackEnabled       bool           `json:"pack_enabled"`       // zip completed batches
+	PackDir           string         `json:"pack_dir"`           // where zips go, empty = next to the batch folder
+	PackDeleteOrig    bool           `json:"pack_delete_orig"`   // delete the originals once the zip verified
+	Presets           []Preset       `json:"presets,omitempty"`  // user presets, see builtinPresets
+	IngestDest        string         `json:"ingest_dest"`        // ingest profile: completed batches are copied to a dated folder here
+	IngestClearCard   bool           `json:"ingest_clear_card"`  // delete the files from the card once the copies verified
+	IngestEject       bool           `json:"ingest_eject"`       // eject the card once all of it is offloaded
+	RenameTemplate    string         `json:"rename_template"`    // text/template for organizing completed batches
+	Theme             string         `json:"theme"`              // dark, light or system
+	Locale            string         `json:"locale"`             // number and time format, e.g. "en-GB"; empty = system
+	AccentColor       string         `json:"accent_color"`       // "#rrggbb" primary color
+	UIScale           int            `json:"ui_scale"`           // text and spacing scale in percent, 100 = default
+	MiniWidget        bool           `json:"mini_widget"`        // show the small floating status window
+	WatchPath         string         `json:"watch_path"`         // last selected watch folder
+	ScheduleEnabled   bool           `json:"schedule_enabled"`   // start/stop monitoring on a schedule
+	ScheduleDays      []int          `json:"schedule_days"`      // weekdays, 0 = Sunday
+	ScheduleStart     string         `json:"schedule_start"`     // "HH:MM"
+	ScheduleEnd       string         `json:"schedule_end"`       // "HH:MM", before start = overnight
+	VolumeWatch       bool           `json:"volume_watch"`       // start monitoring when the volume VolumeID is plugged in, stop on eject
+	VolumeID          string         `json:"volume_id"`          // label or UUID (volume serial number on Windows)
+	VolumeSubdir      string         `json:"volume_subdir"`      // folder watched on the volume if it has it, e.g. DCIM
+	OperatorName      string         `json:"operator_name"`      // recorded as reviewer/signer
+	RequireReview     bool           `json:"require_review"`     // two-person sign-off: review, then sign by someone else
+	CompactCards      bool           `json:"compact_cards"`      // one-line batch cards
+	SFTPHost          string         `json:"sftp_host"`          // user@host of the remote watch
+	SFTPPort          int            `json:"sftp_port"`          // 0 = ssh default
+	SFTPKey           string         `json:"sftp_key"`           // private key file, empty = ssh defaults and agent
+	SFTPDir           string         `json:"sftp_dir"`           // remote directory to watch
+	RemoteInterval    int            `json:"remote_interval"`    // seconds between remote listings
+	WebDAVURL         string         `json:"webdav_url"`         // WebDAV folder of the remote watch
+	WebDAVUser        string         `json:"webdav_user"`        // WebDAV login, empty = anonymous
+	WebDAVPass        string         `json:"webdav_pass"`        // WebDAV password or app password
+	CloudProvider     string         `json:"cloud_provider"`     // dropbox, gdrive or onedrive, see cloudProviders
+	CloudClientID     string         `json:"cloud_client_id"`    // OAuth client ID of the user's app registration
+	CloudSecret       string         `json:"cloud_secret"`       // OAuth client secret, only if the provider needs one
+	CloudFolder       string         `json:"cloud_folder"`       // folder path, or folder ID for Google Drive
+	CloudRefresh      string         `json:"cloud_refresh"`      // OAuth refresh token
+	SimRate           int            `json:"sim_rate"`           // simulated file events per second
+	SimMinMB          int            `json:"sim_min_mb"`         // simulated file sizes, in MB
+	SimMaxMB          int            `json:"sim_max_mb"`         //
+	SimExts           string         `json:"sim_exts"`           // comma-separated extensions of simulated files
+	SimFolders        int            `json:"sim_folders"`        // simulated batches uploading at once
+	SimFilesPerBatch  int            `json:"sim_files_per_batch"`
+	Rules             []Rule         `json:"rules"`             // conditional actions per file and per completed batch
+	ScriptsEnabled    bool           `json:"scripts_enabled"`   // run the hooks of the .tengo files in scriptsDir
+	Plugins           []PluginConfig `json:"plugins"`           // enabled notifier, uploader and store plugins
+	TeamServer        string         `json:"team_server"`       // FidruaWatch whose batches the team page mirrors, empty = none
+	TeamToken         string         `json:"team_token"`        // that server's API token
+	SecretStore       string         `json:"secret_store"`      // where tokens and passwords are kept, see secretStoreOptions
+	EcoOnBattery      bool           `json:"eco_on_battery"`    // save power while on battery, see ecoActive
+	EcoOnMetered      bool           `json:"eco_on_metered"`    // and while on a metered connection
+	EcoSlowPolling    bool           `json:"eco_slow_polling"`  // what saving power does: poll less often,
+	EcoSkipHashing    bool           `json:"eco_skip_hashing"`  // don't hash files,
+	EcoPauseUploads   bool           `json:"eco_pause_uploads"` // hold uploader plugins
+	TransferLimitKB   int            `json:"transfer_limit_kb"` // rate cap of copies and uploads by actions, KB/s, 0 = none
+	TransferJobs      int            `json:"transfer_jobs"`     // copies by actions running at once, 0 = no limit
 }
 
 var tempFilePatterns = []string{".tmp", ".temp", ".part", ".partial", ".crdownload", "~$", ".swp", ".lock"}
 
+// uploaderSignature recognizes a transfer tool by the temp files it writes
+type uploaderSignature struct {
+	Name  string
+	Match func(name string) bool
+}
+
+// rsync writes to ".<name>.XXXXXX" before renaming into place
+var rsyncTempPattern = regexp.MustCompile(`^\..+\.[A-Za-z0-9]{6}$`)
+
+// Ordered from most to least specific, the first match wins
+var uploaderSignatures = []uploaderSignature{
+	{"WinSCP", func(n string) bool { return strings.HasSuffix(n, ".filepart") }},
+	{"Pure-FTPd", func(n string) bool { return strings.HasPrefix(n, ".pureftpd-upload.") }},
+	{"ProFTPD", func(n string) bool { return strings.HasPrefix(n, ".in.") && strings.HasSuffix(n, ".") }},
+	{"rclone", func(n string) bool { return strings.HasSuffix(n, ".partial") }},
+	{"Chrome 浏览器", func(n string) bool { return strings.HasSuffix(n, ".crdownload") }},
+	{"Firefox 浏览器", func(n string) bool { return strings.HasSuffix(n, ".part") }},
+	{"Safari 浏览器", func(n string) bool { return strings.HasSuffix(n, ".download") }},
+	{"macOS Finder (SMB/AFP)", func(n string) bool { return strings.HasPrefix(n, "._") }},
+	{"rsync", rsyncTempPattern.MatchString},
+}
+
 var (
 	monitorPath   string
 	isMonitoring  bool
 	batches       = make(map[string]*Batch)
 	batchesMu     sync.RWMutex
-	watcher       *fsnotify.Watcher
-	watcherMu     sync.Mutex
 	config        Config
 	configPath    string
+	configLoadErr error // set when config.json exists but could not be read
+	configProblems []string // invalid settings reset to defaults while loading
 	monitorCtx    context.Context
 	monitorCancel context.CancelFunc
+	rescanChan    = make(chan struct{}, 1) // requests an immediate reconciliation rescan
+
+	// pendingUploaders holds tools detected in folders that have no batch yet,
+	// keyed by normalized batch folder. Guarded by batchesMu.
+	pendingUploaders = make(map[string]pendingUploader)
 
 	videoExts   = []string{".mp4", ".avi", ".mkv", ".mov", ".wmv", ".flv", ".webm", ".m4v", ".mpeg", ".mpg", ".3gp", ".ts"}
 	imageExts   = []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp", ".svg", ".ico", ".tiff", ".psd"}
@@ -122,10 +284,31 @@ var (
 	colorCyan   = color.NRGBA{R: 0, G: 220, B: 255, A: 255}
 	colorGreen  = color.NRGBA{R: 0, G: 230, B: 118, A: 255}
 	colorGray   = color.NRGBA{R: 100, G: 100, B: 120, A: 255}
+	colorOrange = color.NRGBA{R: 255, G: 160, B: 0, A: 255}
 )
 
 func init() {
-	config = Config{
+	config = defaultConfig()
+	configDir, _ := os.UserConfigDir()
+	configPath = findConfigPath(filepath.Join(configDir, "fidruawatch"))
+	historyPath = filepath.Join(configDir, "fidruawatch", "history.jsonl")
+	checkpointPath = filepath.Join(configDir, "fidruawatch", "batches.json")
+	trashPath = filepath.Join(configDir, "fidruawatch", "trash.json")
+	eventLogPath = filepath.Join(configDir, "fidruawatch", "events.jsonl")
+	databasePath = filepath.Join(configDir, "fidruawatch", "fidruawatch.db")
+	thumbDir = filepath.Join(configDir, "fidruawatch", "thumbs")
+	secretsPath = filepath.Join(configDir, "fidruawatch", "secrets.enc")
+	if stateDir, err := localStateDir(); err == nil {
+		secretsKeyPath = filepath.Join(stateDir, "fidruawatch", "secrets.key")
+	}
+	scriptsDir = filepath.Join(configDir, "fidruawatch", "scripts")
+	configLoadErr = loadConfig()
+}
+
+// defaultConfig returns the settings used on first run and after a reset
+func defaultConfig() Config {
+	return Config{
+		Version:           configVersion,
 		VideoEnabled:      true,
 		ImageEnabled:      false,
 		AudioEnabled:      false,
@@ -134,33 +317,138 @@ func init() {
 		CustomExts:        "",
 		MonitorSubdirs:    true,
 		CompletionTimeout: 30,
+		AdaptiveMin:       10,
+		AdaptiveMax:       600,
 		NotifyOnStart:     true,
 		NotifyOnComplete:  true,
+		NotifyPerMinute:   10,
+		MuteMinutes:       60,
+		ReattachRoots:     true,
 		SoundEnabled:      true,
 		SoundStart:        "", // empty means default system sound
 		SoundComplete:     "", // empty means default system sound
 		SaveHistory:       true,
+		HistoryKeepDays:   365,
+		HistoryMaxMB:      100,
+		StorageEngine:     storageJSON,
+		ConfirmQuit:       true,
+		MaxBatches:        500,
 		AutoStart:         false,
 		RemindUnsigned:    true,
 		RemindInterval:    60, // 1 minute
+		GroupDepth:        0,
+		RescanInterval:    20,
+		SampleBufferSize:  120,
+		SampleResolution:  1,
+		ZeroByteMode:      zeroByteInclude,
+		PairSidecars:      true,
+		VerifyChecksums:   true,
+		BatchSort:         sortByStartTime,
+		APIEnabled:        false,
+		APIListen:         "127.0.0.1:8765",
+		GRPCListen:        defaultGRPCListen,
+		EventLogMaxMB:     10,
+		EventLogKeep:      14,
+		SysLog:            sysLogOff,
+		SecretStore:       secretStoreKeyring,
+		SummaryEnabled:    false,
+		SummaryTime:       "18:00",
+		SummaryPeriod:     summaryDaily,
+		Profile:           profileDefault,
+		GroupTimeWindow:   60,
+		LowDiskWarnGB:     10,
+		StallMinutes:      10,
+		StallAlert:        true,
+		ThumbCacheMB:      100,
+		RenameTemplate:    defaultRenameTemplate,
+		Theme:             themeDark,
+		AccentColor:       defaultAccent,
+		UIScale:           100,
+		ScheduleDays:      []int{1, 2, 3, 4, 5},
+		ScheduleStart:     "09:00",
+		ScheduleEnd:       "19:00",
+		VolumeSubdir:      "DCIM",
+		RemoteInterval:    30,
+		SimRate:           5,
+		SimMinMB:          1,
+		SimMaxMB:          200,
+		SimExts:           ".mp4,.jpg,.mov,.part",
+		SimFolders:        2,
+		SimFilesPerBatch:  10,
+		EcoSlowPolling:    true,
+		EcoSkipHashing:    true,
+		EcoPauseUploads:   true,
+		TransferJobs:      2,
 	}
-	configDir, _ := os.UserConfigDir()
-	configPath = filepath.Join(configDir, "fidruawatch", "config.json")
-	loadConfig()
 }
 
-func loadConfig() {
+// loadConfig reads the config file over the current settings. A missing file
+// is not an error; a corrupted one leaves the defaults in place and is
+// reported so the user can recover it. Older files are migrated to the
+// current schema, keeping a copy of the original, and invalid values are
+// reset to their defaults and listed in configProblems.
+func loadConfig() error {
+	firstRun = false
 	data, err := os.ReadFile(configPath)
 	if err != nil {
-		return
+		if os.IsNotExist(err) {
+			firstRun = true
+			return nil
+		}
+		return err
+	}
+	// A file without a version predates versioning, whatever the defaults say
+	base := config
+	base.Version = 0
+	loaded, err := decodeConfig(data, configFormat(configPath), base)
+	if err != nil {
+		return err
+	}
+	from := migrateConfig(&loaded)
+	configProblems = append(validateConfig(&loaded), openSecrets(&loaded)...)
+	if loaded.Version > configVersion {
+		configProblems = append(configProblems, fmt.Sprintf("配置文件版本 %d 比当前程序支持的 %d 新, 部分设置可能被忽略", loaded.Version, configVersion))
+	}
+	config = loaded
+	if from < configVersion {
+		writeFileAtomic(fmt.Sprintf("%s.v%d", configPath, from), data, 0644)
+		saveConfig()
 	}
-	json.Unmarshal(data, &config)
+	return nil
 }
 
-func saveConfig() {
-	os.MkdirAll(filepath.Dir(configPath), 0755)
-	data, _ := json.MarshalIndent(config, "", "  ")
-	os.WriteFile(configPath, data, 0644)
+// saveConfig writes the settings atomically, after backing up the previous
+// file. On failure the file on disk is left as it was and the error is
+// logged and returned for the UI to show; the running settings still apply.
+func saveConfig() error {
+	err := writeConfigFile()
+	if err != nil {
+		logEvent("保存配置失败: %v", err)
+	}
+	configChanged()
+	return err
+}
+
+func writeConfigFile() error {
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	data, err := encodeConfig(sealSecrets(withoutOverrides(config)), configFormat(configPath))
+	if err != nil {
+		return err
+	}
+	if current, err := os.ReadFile(configPath); err == nil && bytes.Equal(current, data) {
+		lastSavedConfig = data
+		return nil
+	}
+	if err := backupConfig(); err != nil {
+		return fmt.Errorf("备份配置失败: %v", err)
+	}
+	if err := writeFileAtomic(configPath, data, 0644); err != nil {
+		return err
+	}
+	lastSavedConfig = data
+	return nil
 }
 
 // getExecutablePath returns the path to the current executable
@@ -172,6 +460,14 @@ func getExecutablePath() string {
 	return exePath
 }
 
+// autoStartArgs are the arguments the app is started with at sign-in
+func autoStartArgs() []string {
+	if config.StartMinimized {
+		return []string{"--minimized"}
+	}
+	return nil
+}
+
 // setAutoStart enables or disables auto-start on boot
 func setAutoStart(enable bool) error {
 	exePath := getExecutablePath()
@@ -181,67 +477,17 @@ func setAutoStart(enable bool) error {
 
 	switch runtime.GOOS {
 	case "windows":
-		return setAutoStartWindows(exePath, enable)
+		return setAutoStartWindows(exePath, autoStartArgs(), enable)
 	case "darwin":
-		return setAutoStartMacOS(exePath, enable)
+		return setAutoStartMacOS(exePath, autoStartArgs(), enable)
 	case "linux":
-		return setAutoStartLinux(exePath, enable)
+		return setAutoStartLinux(exePath, autoStartArgs(), enable)
 	default:
 		return fmt.Errorf("不支持的操作系统")
 	}
 }
 
-func setAutoStartWindows(exePath string, enable bool) error {
-	// Use reg command to add/remove from Run key
-	if enable {
-		cmd := exec.Command("reg", "add",
-			`HKCU\Software\Microsoft\Windows\CurrentVersion\Run`,
-			"/v", "FidruaWatch",
-			"/t", "REG_SZ",
-			"/d", exePath,
-			"/f")
-		return cmd.Run()
-	} else {
-		cmd := exec.Command("reg", "delete",
-			`HKCU\Software\Microsoft\Windows\CurrentVersion\Run`,
-			"/v", "FidruaWatch",
-			"/f")
-		cmd.Run() // Ignore error if key doesn't exist
-		return nil
-	}
-}
-
-func setAutoStartMacOS(exePath string, enable bool) error {
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-	plistPath := filepath.Join(homeDir, "Library", "LaunchAgents", "com.fidrua.watch.plist")
-
-	if enable {
-		os.MkdirAll(filepath.Dir(plistPath), 0755)
-		plistContent := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
-<plist version="1.0">
-<dict>
-    <key>Label</key>
-    <string>com.fidrua.watch</string>
-    <key>ProgramArguments</key>
-    <array>
-        <string>%s</string>
-    </array>
-    <key>RunAtLoad</key>
-    <true/>
-</dict>
-</plist>`, exePath)
-		return os.WriteFile(plistPath, []byte(plistContent), 0644)
-	} else {
-		os.Remove(plistPath)
-		return nil
-	}
-}
-
-func setAutoStartLinux(exePath string, enable bool) error {
+func setAutoStartLinux(exePath string, args []string, enable bool) error {
 	configDir, err := os.UserConfigDir()
 	if err != nil {
 		return err
@@ -259,7 +505,7 @@ Hidden=false
 NoDisplay=false
 X-GNOME-Autostart-enabled=true
 Comment=File upload monitor
-`, exePath)
+`, strings.Join(append([]string{exePath}, args...), " "))
 		return os.WriteFile(desktopPath, []byte(desktopContent), 0644)
 	} else {
 		os.Remove(desktopPath)
@@ -271,16 +517,12 @@ Comment=File upload monitor
 func isAutoStartEnabled() bool {
 	switch runtime.GOOS {
 	case "windows":
-		cmd := exec.Command("reg", "query",
-			`HKCU\Software\Microsoft\Windows\CurrentVersion\Run`,
-			"/v", "FidruaWatch")
-		return cmd.Run() == nil
+		return isAutoStartEnabledWindows()
 	case "darwin":
-		homeDir, err := os.UserHomeDir()
+		plistPath, err := launchAgentPath()
 		if err != nil {
 			return false
 		}
-		plistPath := filepath.Join(homeDir, "Library", "LaunchAgents", "com.fidrua.watch.plist")
 		_, err = os.Stat(plistPath)
 		return err == nil
 	case "linux":
@@ -330,15 +572,16 @@ func getEnabledExts() []string {
 
 func formatSize(bytes int64) string {
 	const unit = 1024
+	byteUnit := currentLocale().ByteUnit
 	if bytes < unit {
-		return fmt.Sprintf("%d B", bytes)
+		return fmt.Sprintf("%d %s", bytes, byteUnit)
 	}
 	div, exp := int64(unit), 0
 	for n := bytes / unit; n >= unit; n /= unit {
 		div *= unit
 		exp++
 	}
-	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+	return fmt.Sprintf("%s %c%s", formatDecimal(float64(bytes)/float64(div)), "KMGTPE"[exp], byteUnit)
 }
 
 // SoundOption represents a sound choice
@@ -418,8 +661,21 @@ func scanSoundDir(dir string, extensions []string, sounds *[]SoundOption) {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "ctl" {
+		os.Exit(runCtl(os.Args[2:]))
+	}
+	opts := setupFromCommandLine(os.Args[1:])
+	if opts.Headless {
+		os.Exit(runHeadless())
+	}
+	// A second launch, e.g. from the context menu, goes to the running copy
+	if forwardToRunning(opts) {
+		os.Exit(0)
+	}
+
 	a := app.NewWithID("com.fidrua.watch")
-	a.Settings().SetTheme(&customTheme{})
+	scripts.SetApp(a)
+	a.Settings().SetTheme(newCustomTheme())
 	
 	// Set application icon
 	if resourceLogoPng != nil {
@@ -444,6 +700,15 @@ func main() {
 	statusText := widget.NewLabel("点击开始监控")
 	statusText.Alignment = fyne.TextAlignCenter
 
+	diskLabel := widget.NewLabel("")
+	diskLabel.Alignment = fyne.TextAlignCenter
+	diskLabel.Hide()
+
+	// Roots that are only partly or not at all watched
+	watchLabel := widget.NewLabel("")
+	watchLabel.Alignment = fyne.TextAlignCenter
+	watchLabel.Hide()
+
 	// Play button - large, prominent button with icon and text
 	var playBtn *widget.Button
 	playBtnLabel := "▶  开始监控"
@@ -466,6 +731,8 @@ func main() {
 	var folderBtn *widget.Button
 	folderBtn = widget.NewButton("📁 选择监控文件夹", nil)
 	folderBtn.Importance = widget.HighImportance
+	var remoteBtn *widget.Button
+	remoteBtn = widget.NewButton("🌐 远程", nil)
 
 	// Batch list
 	batchList := container.NewVBox()
@@ -473,12 +740,36 @@ func main() {
 	batchScroll.SetMinSize(fyne.NewSize(390, 250))
 
 	uiUpdateChan := make(chan struct{}, 1)
+	filter := newBatchFilter()
+	tray := setupTray(a, w)
+	mini := newMiniWidget(a, func() {
+		if isMonitoring {
+			playBtn.OnTapped()
+		}
+	})
 
 	var updateBatchList func()
 	updateBatchList = func() {
 		batchList.Objects = nil
+		resetLiveLabels()
 		batchesMu.RLock()
 		defer batchesMu.RUnlock()
+		uploading := uploadingCount()
+		tray.Update(uploading)
+		mini.Update(uploading, totalThroughput(time.Now()), isMonitoring)
+		if statuses := watchers.Statuses(); watchStatusText(statuses) != "" && isMonitoring {
+			// A failed root stands out in red, partly watched ones in amber
+			watchLabel.Importance = widget.WarningImportance
+			for _, s := range statuses {
+				if s.State == rootFailed {
+					watchLabel.Importance = widget.DangerImportance
+				}
+			}
+			watchLabel.SetText(watchStatusText(statuses))
+			watchLabel.Show()
+		} else {
+			watchLabel.Hide()
+		}
 
 		if len(batches) == 0 {
 			emptyLabel := widget.NewLabel("暂无上传批次")
@@ -487,14 +778,29 @@ func main() {
 		} else {
 			sortedBatches := make([]*Batch, 0, len(batches))
 			for _, b := range batches {
-				sortedBatches = append(sortedBatches, b)
+				if filter.Match(b) {
+					sortedBatches = append(sortedBatches, b)
+				}
 			}
-			sort.Slice(sortedBatches, func(i, j int) bool {
-				return sortedBatches[i].StartTime.After(sortedBatches[j].StartTime)
-			})
+			sortBatches(sortedBatches, config.BatchSort)
+			var highlighted fyne.CanvasObject
 			for _, batch := range sortedBatches {
-				card := createBatchCard(batch, updateBatchList)
+				card := createBatchCard(batch, updateBatchList, w)
 				batchList.Add(card)
+				if batch.ID == highlightBatchID {
+					highlighted = card
+				}
+			}
+			if highlighted != nil {
+				defer func() {
+					batchScroll.Offset = fyne.NewPos(0, highlighted.Position().Y)
+					batchScroll.Refresh()
+				}()
+			}
+			if len(sortedBatches) == 0 {
+				noMatchLabel := widget.NewLabel("没有匹配的批次")
+				noMatchLabel.Alignment = fyne.TextAlignCenter
+				batchList.Add(container.NewCenter(noMatchLabel))
 			}
 		}
 		batchList.Refresh()
@@ -508,35 +814,390 @@ func main() {
 		}
 	}
 
+	// Search box and status filter above the batch list
+	searchEntry := widget.NewEntry()
+	searchEntry.SetPlaceHolder("🔍 搜索文件夹或文件名")
+	searchEntry.OnChanged = func(text string) {
+		filter.SetQuery(text)
+		requestUIUpdate()
+	}
+	statusLabels := make([]string, len(batchStatusOptions))
+	for i, opt := range batchStatusOptions {
+		statusLabels[i] = opt.Label
+	}
+	statusFilter := widget.NewCheckGroup(statusLabels, func(selected []string) {
+		filter.SetStatusLabels(selected)
+		requestUIUpdate()
+	})
+	statusFilter.Horizontal = true
+	statusFilter.SetSelected(statusLabels)
+	sortSelect := newSortSelect(requestUIUpdate)
+	sortRow := container.NewBorder(nil, nil, widget.NewLabel("排序:"), nil, sortSelect)
+	filterBar := container.NewVBox(searchEntry, statusFilter, sortRow)
+
+	if config.APIEnabled {
+		apiServer = startAPIServer(requestUIUpdate, a)
+	}
+	if config.GRPCEnabled {
+		grpcServer = startGRPCServer(requestUIUpdate)
+	}
+	go runSummaryScheduler(appCtx, a)
+	pruneHistoryOnStartup()
+	// Batches interrupted by a crash or reboot carry on where they were
+	if restoreInterruptedBatches() > 0 {
+		requestUIUpdate()
+	}
+	go runCheckpoints(appCtx)
+	go runLiveLabels(appCtx)
+	go runPowerMonitor(appCtx)
 	go func() {
+		throttle := newRefreshThrottle()
 		for range uiUpdateChan {
+			// Requests arriving while we wait coalesce in uiUpdateChan
+			if d := throttle.Delay(time.Now()); d > 0 {
+				time.Sleep(d)
+			}
+			start := time.Now()
 			updateBatchList()
+			throttle.Done(start, time.Now())
 		}
 	}()
 
+	setWatchPath := func(path string) {
+		// On Windows, clean up drive, UNC and long paths
+		monitorPath = path
+		if !isRemoteWatchPath(path) {
+			monitorPath = normalizeWatchPath(path)
+		}
+		// 显示路径，如果太长则截断
+		displayPath := displayWindowsPath(monitorPath)
+		if len(displayPath) > 45 {
+			displayPath = "..." + displayPath[len(displayPath)-42:]
+		}
+		folderLabel.SetText(displayPath)
+		config.WatchPath = monitorPath
+	}
+	if config.WatchPath != "" {
+		setWatchPath(config.WatchPath)
+	}
+
+	// saveSettings saves the config, telling the user when it could not be
+	// written (disk full, read-only folder...)
+	saveSettings := func() bool {
+		if err := saveConfig(); err != nil {
+			dialog.ShowError(fmt.Errorf("设置未能保存到 %s: %v", configPath, err), w)
+			return false
+		}
+		return true
+	}
+
+	// idleStatus is the status line while not monitoring
+	idleStatus := func() string {
+		if config.VolumeWatch && config.VolumeID != "" {
+			return fmt.Sprintf("💾 等待插入 %s", config.VolumeID)
+		}
+		if config.ScheduleEnabled {
+			if sw, err := newScheduleWindow(config.ScheduleDays, config.ScheduleStart, config.ScheduleEnd); err == nil {
+				return scheduleText(sw.NextStart(time.Now()))
+			}
+		}
+		return "点击开始监控"
+	}
+	statusText.SetText(idleStatus())
+
 	folderBtn.OnTapped = func() {
 		d := dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
 			if err != nil || uri == nil {
 				return
 			}
-			monitorPath = uri.Path()
-			// On Windows, clean up the path
-			if runtime.GOOS == "windows" {
-				monitorPath = filepath.Clean(monitorPath)
-				// Remove leading slash if present (e.g., /C:/path -> C:/path)
-				if len(monitorPath) > 2 && monitorPath[0] == '/' && monitorPath[2] == ':' {
-					monitorPath = monitorPath[1:]
+			setWatchPath(uri.Path())
+			saveSettings()
+		}, w)
+		d.Resize(fyne.NewSize(600, 450))
+		d.Show()
+	}
+
+	showSFTPForm := func() {
+		hostEntry := widget.NewEntry()
+		hostEntry.SetPlaceHolder("user@host")
+		hostEntry.SetText(config.SFTPHost)
+		portEntry := widget.NewEntry()
+		portEntry.SetPlaceHolder("22")
+		if config.SFTPPort > 0 {
+			portEntry.SetText(fmt.Sprintf("%d", config.SFTPPort))
+		}
+		keyEntry := widget.NewEntry()
+		keyEntry.SetPlaceHolder("~/.ssh/id_ed25519 (可选)")
+		keyEntry.SetText(config.SFTPKey)
+		dirEntry := widget.NewEntry()
+		dirEntry.SetPlaceHolder("/uploads")
+		dirEntry.SetText(config.SFTPDir)
+		items := []*widget.FormItem{
+			widget.NewFormItem("主机", hostEntry),
+			widget.NewFormItem("端口", portEntry),
+			widget.NewFormItem("私钥", keyEntry),
+			widget.NewFormItem("远程目录", dirEntry),
+		}
+		dialog.ShowForm("远程 SFTP 监控", "确定", "取消", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			host := strings.TrimSpace(hostEntry.Text)
+			if host == "" {
+				dialog.ShowInformation("提示", "请填写 SFTP 主机", w)
+				return
+			}
+			port := 0
+			fmt.Sscanf(portEntry.Text, "%d", &port)
+			config.SFTPHost = host
+			config.SFTPPort = port
+			config.SFTPKey = strings.TrimSpace(keyEntry.Text)
+			config.SFTPDir = strings.TrimSpace(dirEntry.Text)
+			setWatchPath(sftpWatchPath(config.SFTPHost, config.SFTPDir))
+			saveSettings()
+		}, w)
+	}
+	showWebDAVForm := func() {
+		urlEntry := widget.NewEntry()
+		urlEntry.SetPlaceHolder("https://cloud.example.com/remote.php/dav/files/me/uploads")
+		urlEntry.SetText(config.WebDAVURL)
+		userEntry := widget.NewEntry()
+		userEntry.SetText(config.WebDAVUser)
+		passEntry := widget.NewPasswordEntry()
+		passEntry.SetText(config.WebDAVPass)
+		items := []*widget.FormItem{
+			widget.NewFormItem("地址", urlEntry),
+			widget.NewFormItem("用户名", userEntry),
+			widget.NewFormItem("密码", passEntry),
+		}
+		dialog.ShowForm("WebDAV 监控", "确定", "取消", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			watchPath, err := webdavWatchPath(urlEntry.Text)
+			if err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			config.WebDAVURL = strings.TrimSpace(urlEntry.Text)
+			config.WebDAVUser = strings.TrimSpace(userEntry.Text)
+			config.WebDAVPass = passEntry.Text
+			setWatchPath(watchPath)
+			saveSettings()
+		}, w)
+	}
+	showCloudForm := func() {
+		labels := make([]string, len(cloudProviders))
+		for i, p := range cloudProviders {
+			labels[i] = p.Label
+		}
+		folderEntry := widget.NewEntry()
+		folderEntry.SetText(config.CloudFolder)
+		providerSelect := widget.NewSelect(labels, func(label string) {
+			for _, p := range cloudProviders {
+				if p.Label == label {
+					folderEntry.SetPlaceHolder(p.Folder)
 				}
 			}
-			// 显示路径，如果太长则截断
-			displayPath := monitorPath
-			if len(displayPath) > 45 {
-				displayPath = "..." + displayPath[len(displayPath)-42:]
+		})
+		providerSelect.SetSelectedIndex(0)
+		for i, p := range cloudProviders {
+			if p.Name == config.CloudProvider {
+				providerSelect.SetSelectedIndex(i)
+			}
+		}
+		idEntry := widget.NewEntry()
+		idEntry.SetText(config.CloudClientID)
+		secretEntry := widget.NewPasswordEntry()
+		secretEntry.SetPlaceHolder("(可选)")
+		secretEntry.SetText(config.CloudSecret)
+		items := []*widget.FormItem{
+			widget.NewFormItem("云盘", providerSelect),
+			widget.NewFormItem("Client ID", idEntry),
+			widget.NewFormItem("Client Secret", secretEntry),
+			widget.NewFormItem("文件夹", folderEntry),
+		}
+		dialog.ShowForm("云盘监控", "登录授权", "取消", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			p := cloudProviders[providerSelect.SelectedIndex()]
+			clientID := strings.TrimSpace(idEntry.Text)
+			if clientID == "" {
+				dialog.ShowInformation("提示", "请填写在 "+p.Label+" 开发者后台注册的应用 Client ID\n回调地址: http://"+oauthRedirectAddr+"/", w)
+				return
 			}
-			folderLabel.SetText(displayPath)
+			secret := strings.TrimSpace(secretEntry.Text)
+			folder := strings.TrimSpace(folderEntry.Text)
+			waiting := dialog.NewCustomWithoutButtons("云盘监控", widget.NewLabel("请在浏览器中完成 "+p.Label+" 授权..."), w)
+			waiting.Show()
+			go func() {
+				tok, err := oauthAuthorize(context.Background(), p.Endpoint, clientID, secret, a.OpenURL)
+				fyne.Do(func() {
+					waiting.Hide()
+					if err == nil && tok.RefreshToken == "" {
+						err = fmt.Errorf("%s 没有返回 refresh token, 无法长期监控", p.Label)
+					}
+					if err != nil {
+						logEvent("云盘授权失败: %v", err)
+						dialog.ShowError(err, w)
+						return
+					}
+					config.CloudProvider = p.Name
+					config.CloudClientID = clientID
+					config.CloudSecret = secret
+					config.CloudFolder = folder
+					config.CloudRefresh = tok.RefreshToken
+					setWatchPath(cloudWatchPath(p.Name, folder))
+					saveSettings()
+					logEvent("云盘已授权: %s", p.Label)
+				})
+			}()
 		}, w)
-		d.Resize(fyne.NewSize(600, 450))
-		d.Show()
+	}
+	showSimulationForm := func() {
+		rateEntry := widget.NewEntry()
+		rateEntry.SetText(fmt.Sprintf("%d", config.SimRate))
+		minEntry := widget.NewEntry()
+		minEntry.SetText(fmt.Sprintf("%d", config.SimMinMB))
+		maxEntry := widget.NewEntry()
+		maxEntry.SetText(fmt.Sprintf("%d", config.SimMaxMB))
+		extsEntry := widget.NewEntry()
+		extsEntry.SetText(config.SimExts)
+		foldersEntry := widget.NewEntry()
+		foldersEntry.SetText(fmt.Sprintf("%d", config.SimFolders))
+		filesEntry := widget.NewEntry()
+		filesEntry.SetText(fmt.Sprintf("%d", config.SimFilesPerBatch))
+		items := []*widget.FormItem{
+			widget.NewFormItem("每秒事件数", rateEntry),
+			widget.NewFormItem("最小文件 (MB)", minEntry),
+			widget.NewFormItem("最大文件 (MB)", maxEntry),
+			widget.NewFormItem("文件后缀", extsEntry),
+			widget.NewFormItem("并行批次", foldersEntry),
+			widget.NewFormItem("每批次文件数", filesEntry),
+		}
+		dialog.ShowForm("模拟数据源 (不读写磁盘)", "确定", "取消", items, func(ok bool) {
+			if !ok {
+				return
+			}
+			for _, f := range []struct {
+				entry *widget.Entry
+				dst   *int
+			}{
+				{rateEntry, &config.SimRate},
+				{minEntry, &config.SimMinMB},
+				{maxEntry, &config.SimMaxMB},
+				{foldersEntry, &config.SimFolders},
+				{filesEntry, &config.SimFilesPerBatch},
+			} {
+				var n int
+				if _, err := fmt.Sscanf(f.entry.Text, "%d", &n); err == nil && n >= 0 {
+					*f.dst = n
+				}
+			}
+			config.SimExts = strings.TrimSpace(extsEntry.Text)
+			setWatchPath(simWatchPath)
+			saveSettings()
+		}, w)
+	}
+	remoteBtn.OnTapped = func() {
+		menu := fyne.NewMenu("",
+			fyne.NewMenuItem("SFTP 服务器...", showSFTPForm),
+			fyne.NewMenuItem("WebDAV 文件夹...", showWebDAVForm),
+			fyne.NewMenuItem("云盘 (Dropbox / Google Drive / OneDrive)...", showCloudForm),
+			fyne.NewMenuItemSeparator(),
+			fyne.NewMenuItem("模拟数据源 (测试规则)...", showSimulationForm),
+		)
+		widget.ShowPopUpMenuAtRelativePosition(menu, w.Canvas(), fyne.NewPos(0, remoteBtn.Size().Height), remoteBtn)
+	}
+
+	// activeWatch watches the current path while monitoring. It is replaced
+	// when the watch path or subfolder setting changes mid-session.
+	var activeWatch *watchRun
+	startWatch := func() error {
+		scanLabel := widget.NewLabel("正在扫描子文件夹...")
+		run, err := startWatchRun(monitorCtx, requestUIUpdate, a, func(free, total uint64) {
+			fyne.Do(func() {
+				diskLabel.SetText(formatDiskSpace(free, total))
+				diskLabel.Show()
+			})
+		}, func(dirs int) {
+			fyne.Do(func() {
+				scanLabel.SetText(fmt.Sprintf("已加入监控 %d 个文件夹...", dirs))
+			})
+		})
+		if err != nil {
+			return err
+		}
+		activeWatch = run
+		requestUIUpdate()
+		// Large trees take a while to walk; show progress with a way out.
+		// Folders added so far are watched in the meantime.
+		go func() {
+			var scanDialog *dialog.CustomDialog
+			select {
+			case <-run.scanned:
+			case <-time.After(scanDialogDelay):
+				fyne.Do(func() {
+					scanDialog = dialog.NewCustomWithoutButtons("正在扫描文件夹",
+						container.NewVBox(scanLabel, widget.NewLabel("已加入的文件夹已在监控中")), w)
+					scanDialog.SetButtons([]fyne.CanvasObject{widget.NewButton("取消监控", func() {
+						scanDialog.Hide()
+						if isMonitoring && activeWatch == run {
+							playBtn.OnTapped()
+						}
+					})})
+					scanDialog.Show()
+				})
+				<-run.scanned
+			}
+			fyne.Do(func() {
+				if scanDialog != nil {
+					scanDialog.Hide()
+				}
+				requestUIUpdate()
+				if failed := failedWatchCount(); !run.remote && run.ctx.Err() == nil && failed > 0 {
+					showWatchLimitDialog(failed, w, func() {
+						startPolling(run.ctx, requestUIUpdate, a)
+					})
+				}
+			})
+		}()
+		return nil
+	}
+
+	// Saved or reloaded settings take effect right away: file types, excludes
+	// and timeouts are read per event, the rest is restarted here
+	onConfigChange(func(old, cur Config) {
+		if apiSettingsChanged(old, cur) {
+			restartAPIServer(requestUIUpdate, a)
+		}
+		if grpcSettingsChanged(old, cur) {
+			restartGRPCServer(requestUIUpdate)
+		}
+		if cur.WatchPath != monitorPath {
+			setWatchPath(cur.WatchPath)
+		}
+		if !isMonitoring || !watchSettingsChanged(old, cur) {
+			return
+		}
+		activeWatch.Stop()
+		activeWatch = nil
+		diskLabel.Hide()
+		if err := startWatch(); err != nil {
+			logEvent("切换监控失败: %v", err)
+			playBtn.OnTapped()
+			dialog.ShowError(err, w)
+			return
+		}
+		logEvent("监控已切换: %s", monitorPath)
+		statusText.SetText("正在监控: " + filepath.Base(monitorPath))
+	})
+	err := watchConfigFile(appCtx, func() {
+		fyne.Do(reloadChangedConfig)
+	})
+	if err != nil {
+		logEvent("无法监视配置文件: %v", err)
 	}
 
 	playBtn.OnTapped = func() {
@@ -551,56 +1212,154 @@ func main() {
 			}
 
 			monitorCtx, monitorCancel = context.WithCancel(context.Background())
-			if err := startMonitor(monitorPath); err != nil {
+			sessionID = newSessionID()
+			if err := startWatch(); err != nil {
+				logEvent("启动监控失败: %v", err)
+				sessionID = ""
 				monitorCancel()
 				dialog.ShowError(err, w)
 				return
 			}
+			logEvent("开始监控: %s", monitorPath)
 
 			isMonitoring = true
 			playBtn.SetText("⏹  停止监控")
 			playBtn.Importance = widget.DangerImportance
 			playBtn.Refresh()
 			statusText.SetText("正在监控: " + filepath.Base(monitorPath))
-			folderBtn.Disable()
 
-			go handleFileEvents(monitorCtx, requestUIUpdate, a)
 			go checkCompletions(monitorCtx, requestUIUpdate, a)
 			go remindUnsignedBatches(monitorCtx, a)
 		} else {
 			if monitorCancel != nil {
 				monitorCancel()
 			}
-			stopMonitor()
+			activeWatch.Stop()
+			activeWatch = nil
+			logEvent("停止监控")
+			sessionID = ""
 			isMonitoring = false
 			playBtn.SetText("▶  开始监控")
 			playBtn.Importance = widget.HighImportance
 			playBtn.Refresh()
-			statusText.SetText("点击开始监控")
-			folderBtn.Enable()
+			statusText.SetText(idleStatus())
+			diskLabel.Hide()
+			watchLabel.Hide()
 		}
 	}
 
+	// Start and stop at the scheduled window boundaries; manual use in
+	// between is left alone
+	go runSchedule(appCtx, func(start bool) {
+		fyne.Do(func() {
+			switch {
+			case start && !isMonitoring:
+				if monitorPath == "" {
+					logEvent("计划监控: 未选择监控文件夹, 跳过")
+					return
+				}
+				logEvent("计划监控: 开始")
+				playBtn.OnTapped()
+			case !start && isMonitoring:
+				logEvent("计划监控: 停止")
+				playBtn.OnTapped()
+			}
+			if !isMonitoring {
+				statusText.SetText(idleStatus())
+			}
+		})
+	})
+
+	// Follow the chosen card or drive: watch it when plugged in, stop on
+	// eject. Like the schedule, manual use in between is left alone.
+	go runVolumeWatch(appCtx, func(ev volumeEvent) {
+		fyne.Do(func() {
+			name := volumeName(ev.Volume)
+			if ev.Present {
+				if isMonitoring && monitorPath == ev.Path {
+					return
+				}
+				if isMonitoring {
+					playBtn.OnTapped()
+				}
+				logEvent("已插入 %s, 开始监控 %s", name, ev.Path)
+				setWatchPath(ev.Path)
+				playBtn.OnTapped()
+				sendNotification(a, "FidruaWatch - 已插入 "+name, "开始监控 "+displayWindowsPath(ev.Path))
+				return
+			}
+			if isMonitoring && folderUnder(monitorPath, ev.Volume.Mount) {
+				logEvent("%s 已弹出, 停止监控", name)
+				playBtn.OnTapped()
+				sendNotification(a, "FidruaWatch - 已弹出 "+name, "已停止监控")
+			}
+			if !isMonitoring {
+				statusText.SetText(idleStatus())
+			}
+		})
+	})
+
+	// Eject a card the ingest profile has offloaded, after stopping the
+	// watch that keeps it busy
+	go func() {
+		for {
+			select {
+			case <-appCtx.Done():
+				return
+			case v := <-ejectChan:
+				fyne.Do(func() {
+					if isMonitoring && folderUnder(monitorPath, v.Mount) {
+						playBtn.OnTapped()
+					}
+					go func() {
+						name := volumeName(v)
+						if err := ejectVolume(appCtx, v.Mount); err != nil {
+							logEvent("弹出 %s 失败: %v", name, err)
+							sendNotification(a, "FidruaWatch - 弹出失败", fmt.Sprintf("%s: %v", name, err))
+							return
+						}
+						logEvent("已弹出 %s", name)
+						sendNotification(a, "FidruaWatch - 可以拔出 "+name, "存储卡已导入并弹出")
+					}()
+				})
+			}
+		}
+	}()
+
 	signAllBtn := widget.NewButton("✅ 全部签收", func() {
+		refused := make(map[string]int) // reason → batches
 		batchesMu.Lock()
 		for _, b := range batches {
-			if b.Status == "completed" {
-				b.Status = "signed"
+			if b.Status == "completed" || b.Status == statusReview {
+				if err := signBatch(b); err != nil {
+					refused[err.Error()]++
+				}
 			}
 		}
 		batchesMu.Unlock()
 		updateBatchList()
+		if len(refused) > 0 {
+			var lines []string
+			for reason, n := range refused {
+				lines = append(lines, fmt.Sprintf("%d 个批次: %s", n, reason))
+			}
+			sort.Strings(lines)
+			dialog.ShowInformation("部分批次未签收", strings.Join(lines, "\n"), w)
+		}
 	})
 
 	clearBtn := widget.NewButton("🗑", func() {
+		var signed []*Batch
 		batchesMu.Lock()
-		for id, b := range batches {
+		for _, b := range batches {
 			if b.Status == "signed" {
-				delete(batches, id)
+				signed = append(signed, b)
 			}
 		}
+		trashBatches(signed, time.Now())
 		batchesMu.Unlock()
 		updateBatchList()
+		showUndoToast(signed, updateBatchList, w)
 	})
 
 	batchHeader := container.NewHBox(
@@ -610,15 +1369,49 @@ func main() {
 		clearBtn,
 	)
 
+	// Mute silences sounds and popups for config.MuteMinutes without
+	// touching the notification settings
+	muteBtn := widget.NewButton("", nil)
+	muteBtn.Importance = widget.LowImportance
+	var showMute func()
+	toggleMute := func() {
+		if on, _ := mute.Active(time.Now()); on {
+			mute.Clear()
+			logEvent("已取消静音")
+		} else {
+			mute.Set(time.Duration(config.MuteMinutes)*time.Minute, time.Now(), func() {
+				logEvent("静音已结束")
+				fyne.Do(showMute)
+			})
+			if config.MuteMinutes > 0 {
+				logEvent("已静音 %d 分钟", config.MuteMinutes)
+			} else {
+				logEvent("已静音, 直到手动取消")
+			}
+		}
+		showMute()
+	}
+	showMute = func() {
+		label := muteButtonText(mute.Active(time.Now()))
+		muteBtn.SetText(label)
+		tray.SetMute(label, toggleMute)
+	}
+	muteBtn.OnTapped = toggleMute
+	showMute()
+
 	monitorContent := container.NewVBox(
 		container.NewCenter(title),
 		container.NewCenter(playBtnWrapper),
 		container.NewCenter(statusText),
+		container.NewCenter(muteBtn),
+		container.NewCenter(diskLabel),
+		container.NewCenter(watchLabel),
 		widget.NewSeparator(),
-		folderBtn,
+		container.NewBorder(nil, nil, nil, remoteBtn, folderBtn),
 		container.NewCenter(folderLabel),
 		widget.NewSeparator(),
 		batchHeader,
+		filterBar,
 		batchScroll,
 	)
 
@@ -632,6 +1425,72 @@ func main() {
 	})
 	subdirCheck.Checked = config.MonitorSubdirs
 
+	reattachCheck := widget.NewCheck("🔗 文件夹恢复后自动重新监控 (如 NAS 重新挂载)", func(checked bool) {
+		config.ReattachRoots = checked
+	})
+	reattachCheck.Checked = config.ReattachRoots
+
+	excludeEntry := widget.NewEntry()
+	excludeEntry.SetText(config.ExcludePatterns)
+	excludeEntry.SetPlaceHolder("如: *.part, thumbs.db, cache/*")
+	excludeRow := container.NewBorder(nil, nil, widget.NewLabel("排除:"), nil, excludeEntry)
+
+	volumeCheck := widget.NewCheck("💾 插入指定存储卡/移动硬盘时自动开始监控, 弹出时停止", func(checked bool) {
+		config.VolumeWatch = checked
+	})
+	volumeCheck.Checked = config.VolumeWatch
+	volumeIDEntry := widget.NewEntry()
+	volumeIDEntry.SetPlaceHolder("卷标或 UUID")
+	volumeIDEntry.SetText(config.VolumeID)
+	volumeSubdirEntry := widget.NewEntry()
+	volumeSubdirEntry.SetPlaceHolder("DCIM")
+	volumeSubdirEntry.SetText(config.VolumeSubdir)
+	volumeListBtn := widget.NewButton("🔍 已插入的卷", func() {
+		var lines []string
+		for _, v := range listVolumes(context.Background()) {
+			lines = append(lines, fmt.Sprintf("%s  卷标: %s  UUID: %s", displayWindowsPath(v.Mount), v.Label, v.UUID))
+		}
+		if len(lines) == 0 {
+			lines = append(lines, "未找到已挂载的卷")
+		}
+		dialog.ShowInformation("已插入的卷", strings.Join(lines, "\n"), w)
+	})
+	volumeRow := container.NewBorder(nil, nil, widget.NewLabel("卷:"), volumeListBtn, volumeIDEntry)
+	volumeSubdirRow := container.NewBorder(nil, nil, widget.NewLabel("监控卷上的文件夹:"), nil, volumeSubdirEntry)
+
+	var groupDepthEntry *widget.Entry
+	profileLabels := make([]string, len(profileOptions))
+	for i, p := range profileOptions {
+		profileLabels[i] = p.Label
+	}
+	profileSelect := widget.NewSelect(profileLabels, func(selected string) {
+		for _, p := range profileOptions {
+			if p.Label != selected || p.Profile == config.Profile {
+				continue
+			}
+			switch p.Profile {
+			case profileDownloads:
+				applyDownloadsPreset()
+				subdirCheck.SetChecked(config.MonitorSubdirs)
+				groupDepthEntry.SetText(fmt.Sprintf("%d", config.GroupDepth))
+			case profileIngest:
+				applyIngestPreset()
+				subdirCheck.SetChecked(config.MonitorSubdirs)
+				groupDepthEntry.SetText(fmt.Sprintf("%d", config.GroupDepth))
+				volumeCheck.SetChecked(config.VolumeWatch)
+				volumeSubdirEntry.SetText(config.VolumeSubdir)
+			default:
+				config.Profile = p.Profile
+			}
+		}
+	})
+	for i, p := range profileOptions {
+		if p.Profile == config.Profile {
+			profileSelect.SetSelectedIndex(i)
+		}
+	}
+	profileRow := container.NewBorder(nil, nil, widget.NewLabel("🎛️ 监控模式:"), nil, profileSelect)
+
 	timeoutEntry := widget.NewEntry()
 	timeoutEntry.SetText(fmt.Sprintf("%d", config.CompletionTimeout))
 	timeoutEntry.Resize(fyne.NewSize(60, timeoutEntry.MinSize().Height))
@@ -642,41 +1501,332 @@ func main() {
 		widget.NewLabel("秒"),
 	)
 
-	soundCheck := widget.NewCheck("🔊 声音提醒", func(checked bool) {
-		config.SoundEnabled = checked
+	adaptiveCheck := widget.NewCheck("🧠 自适应: 按每个批次文件到达的间隔判定 (最长间隔的 3 倍)", func(checked bool) {
+		config.AdaptiveTimeout = checked
 	})
-	soundCheck.Checked = config.SoundEnabled
+	adaptiveCheck.Checked = config.AdaptiveTimeout
+	adaptiveMinEntry := widget.NewEntry()
+	adaptiveMinEntry.SetText(fmt.Sprintf("%d", config.AdaptiveMin))
+	adaptiveMaxEntry := widget.NewEntry()
+	adaptiveMaxEntry.SetText(fmt.Sprintf("%d", config.AdaptiveMax))
+	adaptiveRow := container.NewVBox(adaptiveCheck, container.NewHBox(
+		widget.NewLabel("    不少于"),
+		adaptiveMinEntry,
+		widget.NewLabel("秒, 不超过"),
+		adaptiveMaxEntry,
+		widget.NewLabel("秒"),
+	))
+
+	reopenEntry := widget.NewEntry()
+	reopenEntry.SetText(fmt.Sprintf("%d", config.ReopenMinutes))
+	reopenRow := container.NewHBox(
+		widget.NewLabel("🔁 完成后"),
+		reopenEntry,
+		widget.NewLabel("分钟内有变化则重新打开批次 (0=新建批次)"),
+	)
 
-	// Sound selection dropdowns
-	availableSounds := getAvailableSounds()
-	soundNames := make([]string, len(availableSounds))
-	for i, s := range availableSounds {
-		soundNames[i] = s.Name
-	}
-	
-	// Start sound selection
-	var startSoundIndex int
-	for i, s := range availableSounds {
-		if s.Path == config.SoundStart {
-			startSoundIndex = i
-			break
-		}
-	}
-	startSoundSelect := widget.NewSelect(soundNames, func(selected string) {
-		for _, s := range availableSounds {
-			if s.Name == selected {
-				config.SoundStart = s.Path
-				break
-			}
-		}
+	sidecarCheck := widget.NewCheck("🧾 附属文件 (xmp/srt/thm/lrc) 与主文件配对计数", func(checked bool) {
+		config.PairSidecars = checked
 	})
-	if len(soundNames) > 0 {
-		startSoundSelect.SetSelectedIndex(startSoundIndex)
-	}
-	testStartBtn := widget.NewButton("🔈", func() {
-		playSound(SoundTypeStart)
+	sidecarCheck.Checked = config.PairSidecars
+
+	lockCheck := widget.NewCheck("🔒 文件仍被其他程序占用时暂缓完成", func(checked bool) {
+		config.CheckFileLocks = checked
 	})
-	startSoundLabel := widget.NewLabel("开始上传:")
+	lockCheck.Checked = config.CheckFileLocks
+
+	caseCheck := widget.NewCheck("🔤 文件名不区分大小写", func(checked bool) {
+		config.CaseInsensitive = checked
+	})
+	caseCheck.Checked = config.CaseInsensitive
+
+	lowDiskEntry := widget.NewEntry()
+	lowDiskEntry.SetText(fmt.Sprintf("%d", config.LowDiskWarnGB))
+	lowDiskRow := container.NewHBox(
+		widget.NewLabel("💾 空间不足提醒"),
+		lowDiskEntry,
+		widget.NewLabel("GB (0=关闭)"),
+	)
+
+	stallEntry := widget.NewEntry()
+	stallEntry.SetText(fmt.Sprintf("%d", config.StallMinutes))
+	stallRow := container.NewHBox(
+		widget.NewLabel("⚠️ 停滞判定"),
+		stallEntry,
+		widget.NewLabel("分钟无新数据 (0=关闭)"),
+	)
+	stallAlertCheck := widget.NewCheck("⚠️ 上传停滞提醒", func(checked bool) {
+		config.StallAlert = checked
+	})
+	stallAlertCheck.Checked = config.StallAlert
+
+	probeCheck := widget.NewCheck("🎬 完成后读取视频信息 (需要 ffprobe)", func(checked bool) {
+		config.ProbeVideo = checked
+	})
+	probeCheck.Checked = config.ProbeVideo
+
+	verifyArchivesCheck := widget.NewCheck("📦 完成后校验压缩包 (7z/rar 需要 7z 或 unrar)", func(checked bool) {
+		config.VerifyArchives = checked
+	})
+	verifyArchivesCheck.Checked = config.VerifyArchives
+
+	verifyChecksumsCheck := widget.NewCheck("🔑 完成后按附带的 MD5/SHA256 文件校验", func(checked bool) {
+		config.VerifyChecksums = checked
+	})
+	verifyChecksumsCheck.Checked = config.VerifyChecksums
+
+	packCheck := widget.NewCheck("🗜️ 完成后打包为 zip", func(checked bool) {
+		config.PackEnabled = checked
+	})
+	packCheck.Checked = config.PackEnabled
+	packDirEntry := widget.NewEntry()
+	packDirEntry.SetPlaceHolder("留空 = 批次文件夹旁边")
+	packDirEntry.SetText(config.PackDir)
+	packDirBtn := widget.NewButton("选择", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err == nil && uri != nil {
+				packDirEntry.SetText(uri.Path())
+			}
+		}, w)
+	})
+	packDirRow := container.NewBorder(nil, nil, widget.NewLabel("打包到:"), packDirBtn, packDirEntry)
+	packDeleteCheck := widget.NewCheck("🗑️ 打包校验通过后删除原文件", func(checked bool) {
+		config.PackDeleteOrig = checked
+	})
+	packDeleteCheck.Checked = config.PackDeleteOrig
+
+	ingestDirEntry := widget.NewEntry()
+	ingestDirEntry.SetPlaceHolder("存储卡导入模式: 复制到此文件夹下的日期文件夹")
+	ingestDirEntry.SetText(config.IngestDest)
+	ingestDirBtn := widget.NewButton("选择", func() {
+		dialog.ShowFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err == nil && uri != nil {
+				ingestDirEntry.SetText(uri.Path())
+			}
+		}, w)
+	})
+	ingestDirRow := container.NewBorder(nil, nil, widget.NewLabel("📥 导入到:"), ingestDirBtn, ingestDirEntry)
+	ingestClearCheck := widget.NewCheck("🧹 导入校验通过后清空存储卡上的文件", func(checked bool) {
+		config.IngestClearCard = checked
+	})
+	ingestClearCheck.Checked = config.IngestClearCard
+	ingestEjectCheck := widget.NewCheck("⏏️ 全部导入后弹出存储卡", func(checked bool) {
+		config.IngestEject = checked
+	})
+	ingestEjectCheck.Checked = config.IngestEject
+
+	transferLimitEntry := widget.NewEntry()
+	transferLimitEntry.SetText(fmt.Sprintf("%d", config.TransferLimitKB))
+	transferJobsEntry := widget.NewEntry()
+	transferJobsEntry.SetText(fmt.Sprintf("%d", config.TransferJobs))
+	transferRow := container.NewHBox(
+		widget.NewLabel("🚦 移动/打包/上传限速"),
+		transferLimitEntry,
+		widget.NewLabel("KB/s, 同时最多"),
+		transferJobsEntry,
+		widget.NewLabel("个复制 (0 = 不限)"),
+	)
+
+	themeLabels := make([]string, len(themeOptions))
+	for i, opt := range themeOptions {
+		themeLabels[i] = opt.Label
+	}
+	themeSelect := widget.NewSelect(themeLabels, func(selected string) {
+		for _, opt := range themeOptions {
+			if opt.Label == selected && opt.Mode != config.Theme {
+				config.Theme = opt.Mode
+				a.Settings().SetTheme(newCustomTheme())
+			}
+		}
+	})
+	for i, opt := range themeOptions {
+		if opt.Mode == config.Theme {
+			themeSelect.SetSelectedIndex(i)
+		}
+	}
+	themeRow := container.NewBorder(nil, nil, widget.NewLabel("🎨 主题:"), nil, themeSelect)
+
+	localeLabels := make([]string, len(localeOptions))
+	for i, opt := range localeOptions {
+		localeLabels[i] = opt.Label
+	}
+	localeSelect := widget.NewSelect(localeLabels, func(selected string) {
+		for _, opt := range localeOptions {
+			if opt.Label == selected && opt.Tag != config.Locale {
+				config.Locale = opt.Tag
+				updateBatchList()
+			}
+		}
+	})
+	for i, opt := range localeOptions {
+		if opt.Tag == config.Locale {
+			localeSelect.SetSelectedIndex(i)
+		}
+	}
+	localeRow := container.NewBorder(nil, nil, widget.NewLabel("🌐 数字与时间格式:"), nil, localeSelect)
+
+	accentSwatch := canvas.NewRectangle(theme.Color(theme.ColorNamePrimary))
+	accentSwatch.SetMinSize(fyne.NewSize(24, 24))
+	accentSwatch.CornerRadius = 4
+	accentBtn := widget.NewButton("选择强调色", func() {
+		picker := dialog.NewColorPicker("强调色", "选择界面强调色", func(c color.Color) {
+			config.AccentColor = hexColor(c)
+			a.Settings().SetTheme(newCustomTheme())
+			accentSwatch.FillColor = c
+			accentSwatch.Refresh()
+		}, w)
+		picker.Advanced = true
+		picker.Show()
+	})
+	accentResetBtn := widget.NewButton("恢复默认", func() {
+		config.AccentColor = defaultAccent
+		a.Settings().SetTheme(newCustomTheme())
+		accentSwatch.FillColor = theme.Color(theme.ColorNamePrimary)
+		accentSwatch.Refresh()
+	})
+	accentRow := container.NewHBox(widget.NewLabel("🖌️ 强调色:"), accentSwatch, accentBtn, accentResetBtn)
+
+	scaleLabel := widget.NewLabel(fmt.Sprintf("%d%%", config.UIScale))
+	scaleSlider := widget.NewSlider(minUIScale, maxUIScale)
+	scaleSlider.Step = 10
+	scaleSlider.SetValue(float64(config.UIScale))
+	scaleSlider.OnChanged = func(v float64) {
+		scaleLabel.SetText(fmt.Sprintf("%d%%", int(v)))
+	}
+	// Re-theming relayouts the whole window, so only apply on release
+	scaleSlider.OnChangeEnded = func(v float64) {
+		config.UIScale = int(v)
+		a.Settings().SetTheme(newCustomTheme())
+	}
+	scaleRow := container.NewBorder(nil, nil, widget.NewLabel("🔠 界面缩放:"), scaleLabel, scaleSlider)
+
+	compactCheck := widget.NewCheck("📏 紧凑模式 (批次卡片单行显示)", func(checked bool) {
+		config.CompactCards = checked
+		requestUIUpdate()
+	})
+	compactCheck.Checked = config.CompactCards
+
+	miniCheck := widget.NewCheck("🪟 迷你状态窗口", func(checked bool) {
+		config.MiniWidget = checked
+		mini.SetVisible(checked)
+	})
+	miniCheck.Checked = config.MiniWidget
+	mini.OnClose = func() { miniCheck.SetChecked(false) }
+
+	scheduleCheck := widget.NewCheck("⏰ 按计划自动开始/停止监控", func(checked bool) {
+		config.ScheduleEnabled = checked
+	})
+	scheduleCheck.Checked = config.ScheduleEnabled
+	// Monday first, as on a calendar
+	scheduleDayOrder := []int{1, 2, 3, 4, 5, 6, 0}
+	scheduleDayLabels := make([]string, len(scheduleDayOrder))
+	for i, d := range scheduleDayOrder {
+		scheduleDayLabels[i] = weekdayLabels[d]
+	}
+	scheduleDays := widget.NewCheckGroup(scheduleDayLabels, nil)
+	scheduleDays.Horizontal = true
+	for _, d := range config.ScheduleDays {
+		if d >= 0 && d <= 6 {
+			scheduleDays.Selected = append(scheduleDays.Selected, weekdayLabels[d])
+		}
+	}
+	scheduleStartEntry := widget.NewEntry()
+	scheduleStartEntry.SetText(config.ScheduleStart)
+	scheduleEndEntry := widget.NewEntry()
+	scheduleEndEntry.SetText(config.ScheduleEnd)
+	scheduleTimeRow := container.NewHBox(
+		widget.NewLabel("时间段"),
+		scheduleStartEntry,
+		widget.NewLabel("~"),
+		scheduleEndEntry,
+	)
+
+	operatorEntry := widget.NewEntry()
+	operatorEntry.SetPlaceHolder("签收时记录的姓名")
+	operatorEntry.SetText(config.OperatorName)
+	operatorRow := container.NewBorder(nil, nil, widget.NewLabel("👤 操作员:"), nil, operatorEntry)
+	reviewCheck := widget.NewCheck("👥 双人签收 (先复核, 再由另一人签收)", func(checked bool) {
+		config.RequireReview = checked
+	})
+	reviewCheck.Checked = config.RequireReview
+
+	renameEntry := widget.NewEntry()
+	renameEntry.SetText(config.RenameTemplate)
+	renameRow := container.NewBorder(nil, nil, widget.NewLabel("🏷️ 整理模板:"), nil, renameEntry)
+	renameHint := widget.NewLabel("可用: {{.Date}} {{.Time}} {{.Folder}} {{.Category}} {{.Index}} {{.Name}} {{.Ext}} {{.Dir}}")
+	renameHint.Wrapping = fyne.TextWrapWord
+
+	zeroByteLabels := make([]string, len(zeroByteModes))
+	for i, m := range zeroByteModes {
+		zeroByteLabels[i] = m.Label
+	}
+	zeroByteSelect := widget.NewSelect(zeroByteLabels, func(selected string) {
+		for _, m := range zeroByteModes {
+			if m.Label == selected {
+				config.ZeroByteMode = m.Mode
+				break
+			}
+		}
+	})
+	for i, m := range zeroByteModes {
+		if m.Mode == config.ZeroByteMode {
+			zeroByteSelect.SetSelectedIndex(i)
+			break
+		}
+	}
+	zeroByteRow := container.NewBorder(nil, nil, widget.NewLabel("🕳️ 空文件:"), nil, zeroByteSelect)
+
+	groupDepthEntry = widget.NewEntry()
+	groupDepthEntry.SetText(fmt.Sprintf("%d", config.GroupDepth))
+	groupDepthRow := container.NewHBox(
+		widget.NewLabel("🗂️ 分组深度"),
+		groupDepthEntry,
+		widget.NewLabel("层 (0=按所在文件夹)"),
+	)
+
+	rescanEntry := widget.NewEntry()
+	rescanEntry.SetText(fmt.Sprintf("%d", config.RescanInterval))
+	rescanRow := container.NewHBox(
+		widget.NewLabel("🔄 补漏扫描"),
+		rescanEntry,
+		widget.NewLabel("秒 (0=关闭)"),
+	)
+
+	soundCheck := widget.NewCheck("🔊 声音提醒", func(checked bool) {
+		config.SoundEnabled = checked
+	})
+	soundCheck.Checked = config.SoundEnabled
+
+	// Sound selection dropdowns
+	availableSounds := getAvailableSounds()
+	soundNames := make([]string, len(availableSounds))
+	for i, s := range availableSounds {
+		soundNames[i] = s.Name
+	}
+	
+	// Start sound selection
+	var startSoundIndex int
+	for i, s := range availableSounds {
+		if s.Path == config.SoundStart {
+			startSoundIndex = i
+			break
+		}
+	}
+	startSoundSelect := widget.NewSelect(soundNames, func(selected string) {
+		for _, s := range availableSounds {
+			if s.Name == selected {
+				config.SoundStart = s.Path
+				break
+			}
+		}
+	})
+	if len(soundNames) > 0 {
+		startSoundSelect.SetSelectedIndex(startSoundIndex)
+	}
+	testStartBtn := widget.NewButton("🔈", func() {
+		playSound(SoundTypeStart)
+	})
+	startSoundLabel := widget.NewLabel("开始上传:")
 	startSoundRow := container.NewBorder(nil, nil, startSoundLabel, testStartBtn, startSoundSelect)
 	
 	// Complete sound selection
@@ -714,6 +1864,15 @@ func main() {
 	})
 	completeNotifyCheck.Checked = config.NotifyOnComplete
 
+	notifyRateEntry := widget.NewEntry()
+	notifyRateEntry.SetText(fmt.Sprintf("%d", config.NotifyPerMinute))
+	notifyRateEntry.SetPlaceHolder("10")
+	notifyRateRow := container.NewHBox(widget.NewLabel("每分钟最多弹出通知(0=不限):"), notifyRateEntry)
+	muteMinutesEntry := widget.NewEntry()
+	muteMinutesEntry.SetText(fmt.Sprintf("%d", config.MuteMinutes))
+	muteMinutesEntry.SetPlaceHolder("60")
+	muteMinutesRow := container.NewHBox(widget.NewLabel("🔇 静音持续分钟(0=直到手动取消):"), muteMinutesEntry)
+
 	remindUnsignedCheck := widget.NewCheck("🔔 未签名批次定时提醒", func(checked bool) {
 		config.RemindUnsigned = checked
 	})
@@ -730,6 +1889,293 @@ func main() {
 	})
 	historyCheck.Checked = config.SaveHistory
 
+	historyDaysEntry := widget.NewEntry()
+	historyDaysEntry.SetText(fmt.Sprintf("%d", config.HistoryKeepDays))
+	historyBatchesEntry := widget.NewEntry()
+	historyBatchesEntry.SetText(fmt.Sprintf("%d", config.HistoryMaxBatches))
+	historyMBEntry := widget.NewEntry()
+	historyMBEntry.SetText(fmt.Sprintf("%d", config.HistoryMaxMB))
+	historyRetentionRow := container.NewHBox(
+		widget.NewLabel("🗂️ 历史记录保留"),
+		historyDaysEntry,
+		widget.NewLabel("天, 最多"),
+		historyBatchesEntry,
+		widget.NewLabel("个批次,"),
+		historyMBEntry,
+		widget.NewLabel("MB (0 = 不限, 启动时清理)"),
+	)
+	clearHistoryBtn := widget.NewButton("🗑️ 清空历史", func() {
+		dialog.ShowConfirm("清空历史", "确定删除全部历史记录吗？\n（重复上传检测和汇总也会从头开始）", func(ok bool) {
+			if !ok {
+				return
+			}
+			if err := clearHistory(); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			logEvent("历史记录已清空")
+		}, w)
+	})
+
+	summaryCheck := widget.NewCheck("📊 定时汇总通知", func(checked bool) {
+		config.SummaryEnabled = checked
+	})
+	summaryCheck.Checked = config.SummaryEnabled
+
+	summaryTimeEntry := widget.NewEntry()
+	summaryTimeEntry.SetText(config.SummaryTime)
+	summaryTimeEntry.SetPlaceHolder("18:00")
+	summaryPeriodSelect := widget.NewSelect([]string{"每天", "每周一"}, func(selected string) {
+		if selected == "每周一" {
+			config.SummaryPeriod = summaryWeekly
+		} else {
+			config.SummaryPeriod = summaryDaily
+		}
+	})
+	if config.SummaryPeriod == summaryWeekly {
+		summaryPeriodSelect.SetSelectedIndex(1)
+	} else {
+		summaryPeriodSelect.SetSelectedIndex(0)
+	}
+	summaryRow := container.NewHBox(summaryPeriodSelect, summaryTimeEntry, widget.NewLabel("发送"))
+
+	sampleSizeEntry := widget.NewEntry()
+	sampleSizeEntry.SetText(fmt.Sprintf("%d", config.SampleBufferSize))
+	sampleSizeRow := container.NewHBox(
+		widget.NewLabel("📈 每批次采样缓冲"),
+		sampleSizeEntry,
+		widget.NewLabel("个"),
+	)
+
+	maxBatchesEntry := widget.NewEntry()
+	maxBatchesEntry.SetText(fmt.Sprintf("%d", config.MaxBatches))
+	keepHoursEntry := widget.NewEntry()
+	keepHoursEntry.SetText(fmt.Sprintf("%d", config.KeepBatchHours))
+	retentionRow := container.NewHBox(
+		widget.NewLabel("🧹 内存中最多保留"),
+		maxBatchesEntry,
+		widget.NewLabel("个已完成批次,"),
+		keepHoursEntry,
+		widget.NewLabel("小时后移除 (0 = 不限)"),
+	)
+
+	formatLabels := make([]string, len(configFormats))
+	for i, f := range configFormats {
+		formatLabels[i] = f.Label
+	}
+	formatSelect := widget.NewSelect(formatLabels, nil)
+	for i, f := range configFormats {
+		if f.Format == configFormat(configPath) {
+			formatSelect.SetSelectedIndex(i)
+		}
+	}
+	formatSelect.OnChanged = func(string) {
+		f := configFormats[formatSelect.SelectedIndex()]
+		if f.Format == configFormat(configPath) {
+			return
+		}
+		if err := convertConfigFile(f.Format); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		logEvent("配置文件已转换为 %s: %s", f.Label, configPath)
+	}
+	configFormatRow := container.NewBorder(nil, nil, widget.NewLabel("📝 配置文件格式:"), nil, formatSelect)
+
+	storageLabels := make([]string, len(storageEngineOptions))
+	for i, opt := range storageEngineOptions {
+		storageLabels[i] = opt.Label
+	}
+	storageSelect := widget.NewSelect(storageLabels, func(selected string) {
+		for _, opt := range storageEngineOptions {
+			if opt.Label == selected && opt.Engine != config.StorageEngine {
+				config.StorageEngine = opt.Engine
+				logEvent("历史记录与检查点改为保存到 %s", opt.Label)
+			}
+		}
+	})
+	for i, opt := range storageEngineOptions {
+		if opt.Engine == config.StorageEngine {
+			storageSelect.SetSelectedIndex(i)
+		}
+	}
+	storageRow := container.NewBorder(nil, nil, widget.NewLabel("🗄️ 存储引擎:"), nil, storageSelect)
+
+	filterTestBtn := widget.NewButton("🔍 过滤规则测试...", func() { showFilterTestDialog(w) })
+	rulesBtn := widget.NewButton("🧩 编辑规则...", func() { showRulesDialog(saveSettings, w) })
+	pluginsBtn := widget.NewButton("🧱 插件...", func() { showPluginsDialog(saveSettings, w) })
+	presetsBtn := widget.NewButton("📚 预设: 视频交付、摄影素材、文档收集...", func() {
+		showPresetsDialog(saveSettings, func() {
+			subdirCheck.SetChecked(config.MonitorSubdirs)
+			groupDepthEntry.SetText(fmt.Sprintf("%d", config.GroupDepth))
+			timeoutEntry.SetText(fmt.Sprintf("%d", config.CompletionTimeout))
+			sidecarCheck.SetChecked(config.PairSidecars)
+			probeCheck.SetChecked(config.ProbeVideo)
+			verifyArchivesCheck.SetChecked(config.VerifyArchives)
+			verifyChecksumsCheck.SetChecked(config.VerifyChecksums)
+			packCheck.SetChecked(config.PackEnabled)
+		}, w)
+	})
+	scriptsCheck := widget.NewCheck("📜 运行脚本钩子 (onFileDetected / onBatchCompleted)", func(checked bool) {
+		config.ScriptsEnabled = checked
+	})
+	scriptsCheck.Checked = config.ScriptsEnabled
+	scriptsBtn := widget.NewButton("📂 脚本文件夹", func() {
+		example, err := ensureScriptsDir()
+		if err == nil {
+			err = openFileLocation(example)
+		}
+		if err != nil {
+			dialog.ShowError(err, w)
+		}
+	})
+
+	var selfTestBtn *widget.Button
+	selfTestBtn = widget.NewButton("🩺 自检", func() {
+		selfTestBtn.Disable()
+		go func() {
+			text, err := selfTestAll()
+			fyne.Do(func() {
+				selfTestBtn.Enable()
+				if err != nil {
+					dialog.ShowError(err, w)
+					return
+				}
+				dialog.ShowInformation("自检通过", text, w)
+			})
+		}()
+	})
+
+	thumbCacheEntry := widget.NewEntry()
+	thumbCacheEntry.SetText(fmt.Sprintf("%d", config.ThumbCacheMB))
+	thumbCacheRow := container.NewHBox(
+		widget.NewLabel("🖼️ 缩略图缓存上限"),
+		thumbCacheEntry,
+		widget.NewLabel("MB"),
+	)
+
+	sampleResEntry := widget.NewEntry()
+	sampleResEntry.SetText(fmt.Sprintf("%d", config.SampleResolution))
+	sampleResRow := container.NewHBox(
+		widget.NewLabel("📉 采样精度"),
+		sampleResEntry,
+		widget.NewLabel("秒"),
+	)
+
+	apiCheck := widget.NewCheck("🔌 启用本地 API", func(checked bool) {
+		config.APIEnabled = checked
+	})
+	apiCheck.Checked = config.APIEnabled
+
+	apiListenEntry := widget.NewEntry()
+	apiListenEntry.SetText(config.APIListen)
+	apiListenEntry.SetPlaceHolder("127.0.0.1:8765")
+	apiListenRow := container.NewBorder(nil, nil, widget.NewLabel("监听地址:"), nil, apiListenEntry)
+
+	apiTokenEntry := widget.NewPasswordEntry()
+	apiTokenEntry.SetText(config.APIToken)
+	apiTokenEntry.SetPlaceHolder("留空表示不校验 (设了签收令牌则一律需要签收令牌)")
+	apiTokenRow := container.NewBorder(nil, nil, widget.NewLabel("访问令牌:"), nil, apiTokenEntry)
+
+	signTokenEntry := widget.NewPasswordEntry()
+	signTokenEntry.SetText(config.SignToken)
+	signTokenEntry.SetPlaceHolder("留空表示访问令牌也可签收")
+	signTokenRow := container.NewBorder(nil, nil, widget.NewLabel("签收令牌:"), nil, signTokenEntry)
+
+	grpcCheck := widget.NewCheck("📡 启用 gRPC 服务 (使用同样的令牌)", func(checked bool) {
+		config.GRPCEnabled = checked
+	})
+	grpcCheck.Checked = config.GRPCEnabled
+	grpcListenEntry := widget.NewEntry()
+	grpcListenEntry.SetText(config.GRPCListen)
+	grpcListenEntry.SetPlaceHolder(defaultGRPCListen + " 或 unix:/路径/fidruawatch.sock")
+	grpcListenRow := container.NewBorder(nil, nil, widget.NewLabel("gRPC 地址:"), nil, grpcListenEntry)
+
+	eventLogCheck := widget.NewCheck("📜 导出事件到 events.jsonl", func(checked bool) {
+		config.EventLog = checked
+		if !checked {
+			eventLog.Close()
+		}
+	})
+	eventLogCheck.Checked = config.EventLog
+	eventLogMBEntry := widget.NewEntry()
+	eventLogMBEntry.SetText(fmt.Sprintf("%d", config.EventLogMaxMB))
+	eventLogKeepEntry := widget.NewEntry()
+	eventLogKeepEntry.SetText(fmt.Sprintf("%d", config.EventLogKeep))
+	var sysLogLabels []string
+	for _, opt := range sysLogOptions {
+		for _, backend := range sysLogBackends {
+			if opt.Backend == backend {
+				sysLogLabels = append(sysLogLabels, opt.Label)
+			}
+		}
+	}
+	sysLogSelect := widget.NewSelect(sysLogLabels, func(selected string) {
+		for _, opt := range sysLogOptions {
+			if opt.Label == selected && opt.Backend != config.SysLog {
+				config.SysLog = opt.Backend
+				logEvent("系统日志输出改为 %s", opt.Label)
+			}
+		}
+	})
+	for _, opt := range sysLogOptions {
+		if opt.Backend == config.SysLog {
+			sysLogSelect.SetSelected(opt.Label)
+		}
+	}
+	sysLogRow := container.NewBorder(nil, nil, widget.NewLabel("🖥️ 系统日志:"), nil, sysLogSelect)
+
+	var secretStoreLabels []string
+	for _, opt := range secretStoreOptions {
+		secretStoreLabels = append(secretStoreLabels, opt.Label)
+	}
+	secretStoreSelect := widget.NewSelect(secretStoreLabels, func(selected string) {
+		for _, opt := range secretStoreOptions {
+			if opt.Label == selected {
+				config.SecretStore = opt.Store
+			}
+		}
+	})
+	for _, opt := range secretStoreOptions {
+		if opt.Store == config.SecretStore {
+			secretStoreSelect.SetSelected(opt.Label)
+		}
+	}
+	secretStoreRow := container.NewBorder(nil, nil, widget.NewLabel("🔐 令牌和密码保存在:"), nil, secretStoreSelect)
+
+	ecoBatteryCheck := widget.NewCheck("🔋 使用电池时节能", func(checked bool) {
+		config.EcoOnBattery = checked
+	})
+	ecoBatteryCheck.Checked = config.EcoOnBattery
+	ecoMeteredCheck := widget.NewCheck("📶 使用计费网络时节能", func(checked bool) {
+		config.EcoOnMetered = checked
+	})
+	ecoMeteredCheck.Checked = config.EcoOnMetered
+	ecoPollCheck := widget.NewCheck("降低扫描和轮询频率", func(checked bool) {
+		config.EcoSlowPolling = checked
+	})
+	ecoPollCheck.Checked = config.EcoSlowPolling
+	ecoHashCheck := widget.NewCheck("跳过校验和与压缩包校验", func(checked bool) {
+		config.EcoSkipHashing = checked
+	})
+	ecoHashCheck.Checked = config.EcoSkipHashing
+	ecoUploadCheck := widget.NewCheck("暂停上传插件, 恢复后继续", func(checked bool) {
+		config.EcoPauseUploads = checked
+	})
+	ecoUploadCheck.Checked = config.EcoPauseUploads
+	ecoRow := container.NewVBox(
+		container.NewHBox(ecoBatteryCheck, ecoMeteredCheck),
+		container.NewHBox(widget.NewLabel("    节能时:"), ecoPollCheck, ecoHashCheck, ecoUploadCheck),
+	)
+
+	eventLogRotateRow := container.NewHBox(
+		widget.NewLabel("每天或超过"),
+		eventLogMBEntry,
+		widget.NewLabel("MB 时轮转, 保留"),
+		eventLogKeepEntry,
+		widget.NewLabel("个旧文件 (0 = 不限)"),
+	)
+
 	saveBtn := widget.NewButton("💾 保存设置", func() {
 		if t := timeoutEntry.Text; t != "" {
 			var timeout int
@@ -737,6 +2183,162 @@ func main() {
 				config.CompletionTimeout = timeout
 			}
 		}
+		var adaptiveMin, adaptiveMax int
+		if _, err := fmt.Sscanf(adaptiveMinEntry.Text, "%d", &adaptiveMin); err == nil && adaptiveMin >= 10 {
+			config.AdaptiveMin = adaptiveMin
+		}
+		if _, err := fmt.Sscanf(adaptiveMaxEntry.Text, "%d", &adaptiveMax); err == nil && adaptiveMax >= config.AdaptiveMin {
+			config.AdaptiveMax = adaptiveMax
+		}
+		if t := groupDepthEntry.Text; t != "" {
+			var depth int
+			if _, err := fmt.Sscanf(t, "%d", &depth); err == nil && depth >= 0 {
+				config.GroupDepth = depth
+			}
+		}
+		if t := rescanEntry.Text; t != "" {
+			var interval int
+			if _, err := fmt.Sscanf(t, "%d", &interval); err == nil && (interval == 0 || interval >= 5) {
+				config.RescanInterval = interval
+			}
+		}
+		// Advanced: sample buffers apply to batches created after saving
+		if t := sampleSizeEntry.Text; t != "" {
+			var size int
+			if _, err := fmt.Sscanf(t, "%d", &size); err == nil && size >= 10 && size <= 10000 {
+				config.SampleBufferSize = size
+			}
+		}
+		if t := maxBatchesEntry.Text; t != "" {
+			var n int
+			if _, err := fmt.Sscanf(t, "%d", &n); err == nil && n >= 0 {
+				config.MaxBatches = n
+			}
+		}
+		if t := keepHoursEntry.Text; t != "" {
+			var hours int
+			if _, err := fmt.Sscanf(t, "%d", &hours); err == nil && hours >= 0 {
+				config.KeepBatchHours = hours
+			}
+		}
+		if t := historyDaysEntry.Text; t != "" {
+			var days int
+			if _, err := fmt.Sscanf(t, "%d", &days); err == nil && days >= 0 {
+				config.HistoryKeepDays = days
+			}
+		}
+		if t := historyBatchesEntry.Text; t != "" {
+			var n int
+			if _, err := fmt.Sscanf(t, "%d", &n); err == nil && n >= 0 {
+				config.HistoryMaxBatches = n
+			}
+		}
+		if t := historyMBEntry.Text; t != "" {
+			var mb int
+			if _, err := fmt.Sscanf(t, "%d", &mb); err == nil && mb >= 0 {
+				config.HistoryMaxMB = mb
+			}
+		}
+		if t := eventLogMBEntry.Text; t != "" {
+			var mb int
+			if _, err := fmt.Sscanf(t, "%d", &mb); err == nil && mb >= 0 {
+				config.EventLogMaxMB = mb
+			}
+		}
+		if t := eventLogKeepEntry.Text; t != "" {
+			var n int
+			if _, err := fmt.Sscanf(t, "%d", &n); err == nil && n >= 0 {
+				config.EventLogKeep = n
+			}
+		}
+		if t := transferLimitEntry.Text; t != "" {
+			var kb int
+			if _, err := fmt.Sscanf(t, "%d", &kb); err == nil && kb >= 0 {
+				config.TransferLimitKB = kb
+			}
+		}
+		if t := transferJobsEntry.Text; t != "" {
+			var n int
+			if _, err := fmt.Sscanf(t, "%d", &n); err == nil && n >= 0 {
+				config.TransferJobs = n
+			}
+		}
+		if t := sampleResEntry.Text; t != "" {
+			var res int
+			if _, err := fmt.Sscanf(t, "%d", &res); err == nil && res >= 1 {
+				config.SampleResolution = res
+			}
+		}
+		if t := strings.TrimSpace(summaryTimeEntry.Text); t != "" {
+			if _, _, ok := parseClock(t); ok {
+				config.SummaryTime = t
+			}
+		}
+		if t := lowDiskEntry.Text; t != "" {
+			var gb int
+			if _, err := fmt.Sscanf(t, "%d", &gb); err == nil && gb >= 0 {
+				config.LowDiskWarnGB = gb
+			}
+		}
+		if t := thumbCacheEntry.Text; t != "" {
+			var mb int
+			if _, err := fmt.Sscanf(t, "%d", &mb); err == nil && mb > 0 {
+				config.ThumbCacheMB = mb
+			}
+		}
+		if t := reopenEntry.Text; t != "" {
+			var minutes int
+			if _, err := fmt.Sscanf(t, "%d", &minutes); err == nil && minutes >= 0 {
+				config.ReopenMinutes = minutes
+			}
+		}
+		if t := stallEntry.Text; t != "" {
+			var minutes int
+			if _, err := fmt.Sscanf(t, "%d", &minutes); err == nil && minutes >= 0 {
+				config.StallMinutes = minutes
+			}
+		}
+		config.ScheduleDays = nil
+		for _, d := range scheduleDayOrder {
+			for _, label := range scheduleDays.Selected {
+				if label == weekdayLabels[d] {
+					config.ScheduleDays = append(config.ScheduleDays, d)
+				}
+			}
+		}
+		if _, _, ok := parseClock(scheduleStartEntry.Text); ok {
+			config.ScheduleStart = scheduleStartEntry.Text
+		}
+		if _, _, ok := parseClock(scheduleEndEntry.Text); ok {
+			config.ScheduleEnd = scheduleEndEntry.Text
+		}
+		config.VolumeID = strings.TrimSpace(volumeIDEntry.Text)
+		config.VolumeSubdir = strings.TrimSpace(volumeSubdirEntry.Text)
+		config.ExcludePatterns = strings.TrimSpace(excludeEntry.Text)
+		config.OperatorName = strings.TrimSpace(operatorEntry.Text)
+		config.PackDir = strings.TrimSpace(packDirEntry.Text)
+		config.IngestDest = strings.TrimSpace(ingestDirEntry.Text)
+		if t := strings.TrimSpace(renameEntry.Text); t != "" {
+			if validRenameTemplate(t) {
+				config.RenameTemplate = t
+			}
+		}
+		config.APIListen = strings.TrimSpace(apiListenEntry.Text)
+		config.APIToken = apiTokenEntry.Text
+		config.SignToken = signTokenEntry.Text
+		config.GRPCListen = strings.TrimSpace(grpcListenEntry.Text)
+		if t := notifyRateEntry.Text; t != "" {
+			var n int
+			if _, err := fmt.Sscanf(t, "%d", &n); err == nil && n >= 0 {
+				config.NotifyPerMinute = n
+			}
+		}
+		if t := muteMinutesEntry.Text; t != "" {
+			var n int
+			if _, err := fmt.Sscanf(t, "%d", &n); err == nil && n >= 0 {
+				config.MuteMinutes = n
+			}
+		}
 		// Parse remind interval
 		if t := remindIntervalEntry.Text; t != "" {
 			var interval int
@@ -749,7 +2351,12 @@ func main() {
 			dialog.ShowError(fmt.Errorf("设置开机启动失败: %v", err), w)
 			return
 		}
-		saveConfig()
+		if !saveSettings() {
+			return
+		}
+		if !isMonitoring {
+			statusText.SetText(idleStatus())
+		}
 		dialog.ShowInformation("成功", "设置已保存", w)
 	})
 	saveBtn.Importance = widget.HighImportance
@@ -761,28 +2368,133 @@ func main() {
 	// Check actual system state
 	autoStartCheck.Checked = isAutoStartEnabled()
 	config.AutoStart = autoStartCheck.Checked
-
-	settingsContent := container.NewVBox(
-		widget.NewLabelWithStyle("📁 文件监控", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		fileTypeBtn,
-		subdirCheck,
-		timeoutRow,
-		widget.NewSeparator(),
-		widget.NewLabelWithStyle("🔔 通知设置", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		soundCheck,
-		startSoundRow,
-		completeSoundRow,
-		startNotifyCheck,
-		completeNotifyCheck,
-		remindUnsignedCheck,
-		remindIntervalRow,
-		widget.NewSeparator(),
-		widget.NewLabelWithStyle("⚙️ 其他", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
-		historyCheck,
-		autoStartCheck,
-		widget.NewSeparator(),
-		saveBtn,
-	)
+	minimizedCheck := widget.NewCheck("开机启动时最小化到托盘", func(checked bool) {
+		config.StartMinimized = checked
+	})
+	minimizedCheck.Checked = config.StartMinimized
+	confirmQuitCheck := widget.NewCheck("上传中退出时确认", func(checked bool) {
+		config.ConfirmQuit = checked
+	})
+	confirmQuitCheck.Checked = config.ConfirmQuit
+
+	otherItems := []settingItem{
+		{"保存历史记录 history", historyCheck},
+		{"历史记录 保留 天 清理 history retention prune", historyRetentionRow},
+		{"清空历史 删除 history clear", clearHistoryBtn},
+		{"操作员 姓名 签收 operator", operatorRow},
+		{"双人签收 复核 review", reviewCheck},
+		{"开机自动启动 autostart", autoStartCheck},
+		{"最小化 托盘 minimized tray", minimizedCheck},
+		{"退出 确认 关闭 quit confirm", confirmQuitCheck},
+		{"密钥 密码 令牌 钥匙串 加密 keychain keyring credential secret password", secretStoreRow},
+		{"节能 电池 计费网络 笔记本 battery metered power", ecoRow},
+		{"读取视频信息 ffprobe 时长 编码 分辨率", probeCheck},
+		{"校验压缩包 zip rar 7z 损坏 crc", verifyArchivesCheck},
+		{"校验 md5 sha256 checksum 哈希", verifyChecksumsCheck},
+		{"完成后打包 zip 压缩", packCheck},
+		{"打包到 目标文件夹 zip", packDirRow},
+		{"打包后删除原文件 zip", packDeleteCheck},
+		{"存储卡导入 复制 目标文件夹 日期 ingest offload", ingestDirRow},
+		{"存储卡导入 清空 格式化 删除 ingest format", ingestClearCheck},
+		{"存储卡导入 弹出 ingest eject", ingestEjectCheck},
+		{"限速 带宽 并发 复制 上传 NAS bandwidth limit rate concurrency", transferRow},
+		{"整理模板 重命名 rename template", renameRow},
+		{"整理模板 重命名 rename template", renameHint},
+	}
+	if runtime.GOOS == "darwin" {
+		keepAliveCheck := widget.NewCheck("崩溃后自动重新启动 (登录项)", func(checked bool) {
+			config.LoginKeepAlive = checked
+		})
+		keepAliveCheck.Checked = config.LoginKeepAlive
+		otherItems = append(otherItems, settingItem{"崩溃 重启 登录项 keepalive launchagent", keepAliveCheck})
+		otherItems = append(otherItems, settingItem{"applescript 快捷指令 shortcuts 自动化 automation", newAppleScriptRow(w)})
+	}
+	if runtime.GOOS == "linux" {
+		otherItems = append(otherItems, settingItem{"systemd 服务 无界面 开机 service headless", newSystemdRow(w)})
+	}
+	otherItems = append(otherItems, settingItem{"右键菜单 资源管理器 访达 快速操作 nautilus context menu explorer finder", newContextMenuRow(w)})
+
+	settingsContent := newSettingsView([]settingsSection{
+		{"📁 文件监控", []settingItem{
+			{"监控模式 普通上传 下载管理 profile", profileRow},
+			{"预设 模板 视频交付 摄影素材 文档收集 印刷文件 preset template", presetsBtn},
+			{"文件类型 视频 图片 音频 文档 压缩包", fileTypeBtn},
+			{"监控子文件夹 subdir", subdirCheck},
+			{"重新监控 卸载 挂载 NAS 断开 reattach remount", reattachCheck},
+			{"排除文件 忽略 exclude ignore", excludeRow},
+			{"文件名不区分大小写 case", caseCheck},
+			{"分组深度 group", groupDepthRow},
+			{"完成判定 超时 timeout", timeoutRow},
+			{"自适应 完成判定 到达间隔 adaptive timeout", adaptiveRow},
+			{"重新打开 完成后变化 reopen", reopenRow},
+			{"文件占用 锁定 句柄 lock in use", lockCheck},
+			{"附属文件 配对 sidecar xmp srt thm lrc", sidecarCheck},
+			{"补漏扫描 rescan", rescanRow},
+			{"空文件 0 字节 zero", zeroByteRow},
+			{"过滤规则测试 试运行 为什么没有监控 dry run filter test", filterTestBtn},
+			{"停滞判定 中断 stall", stallRow},
+			{"空间不足提醒 磁盘 disk", lowDiskRow},
+		}},
+		{"🎨 外观", []settingItem{
+			{"主题 深色 浅色 跟随系统 theme dark light", themeRow},
+			{"数字 时间 日期 格式 区域 语言 locale date time format", localeRow},
+			{"强调色 颜色 accent color", accentRow},
+			{"界面缩放 字体大小 scale font size", scaleRow},
+			{"紧凑模式 单行 compact", compactCheck},
+			{"迷你状态窗口 悬浮 mini widget", miniCheck},
+		}},
+		{"🔔 通知设置", []settingItem{
+			{"声音提醒 sound", soundCheck},
+			{"开始上传 提示音 声音 sound", startSoundRow},
+			{"上传完成 提示音 声音 sound", completeSoundRow},
+			{"上传开始提醒 通知", startNotifyCheck},
+			{"上传完成提醒 通知", completeNotifyCheck},
+			{"通知频率 上限 合并 每分钟 rate limit", notifyRateRow},
+			{"静音 免打扰 时长 mute", muteMinutesRow},
+			{"上传停滞提醒 中断 stall", stallAlertCheck},
+			{"未签名批次定时提醒 签名", remindUnsignedCheck},
+			{"提醒间隔 未签名 签名", remindIntervalRow},
+			{"定时汇总通知 每日 每周 summary", summaryCheck},
+			{"汇总时间 每日 每周 summary", summaryRow},
+		}},
+		{"⏰ 计划监控", []settingItem{
+			{"计划监控 自动开始 停止 schedule", scheduleCheck},
+			{"计划监控 星期 日期 schedule days", scheduleDays},
+			{"计划监控 时间段 schedule time", scheduleTimeRow},
+			{"存储卡 移动硬盘 U盘 插入 弹出 卷标 removable volume sd card", volumeCheck},
+			{"存储卡 卷标 UUID volume label", volumeRow},
+			{"存储卡 DCIM 文件夹 volume folder", volumeSubdirRow},
+		}},
+		{"⚙️ 其他", otherItems},
+		{"🧩 规则", []settingItem{
+			{"规则 条件 动作 通知 命令 移动 标签 忽略 rules action command move tag ignore", rulesBtn},
+			{"脚本 钩子 tengo script hook", scriptsCheck},
+			{"脚本 文件夹 tengo script folder", scriptsBtn},
+			{"插件 通知 上传 存储 后端 plugin notifier uploader store backend", pluginsBtn},
+		}},
+		{"🔌 API", []settingItem{
+			{"启用本地 API http", apiCheck},
+			{"监听地址 API listen", apiListenRow},
+			{"访问令牌 API token", apiTokenRow},
+			{"签收令牌 权限 角色 API sign token role signer viewer", signTokenRow},
+			{"gRPC 服务 流式 事件 grpc stream events", grpcCheck},
+			{"gRPC 监听地址 套接字 grpc listen unix socket", grpcListenRow},
+		}},
+		{"📜 日志", []settingItem{
+			{"系统日志 syslog journald windows 事件日志 event viewer", sysLogRow},
+			{"事件日志 导出 jsonl logstash vector event log export", eventLogCheck},
+			{"事件日志 轮转 保留 event log rotate", eventLogRotateRow},
+		}},
+		{"🧪 高级", []settingItem{
+			{"每批次采样缓冲 sample", sampleSizeRow},
+			{"采样精度 sample", sampleResRow},
+			{"内存 保留 批次 移除 memory retention", retentionRow},
+			{"缩略图缓存上限 thumbnail", thumbCacheRow},
+			{"配置文件格式 json yaml toml config", configFormatRow},
+			{"存储引擎 数据库 历史 sqlite json storage database", storageRow},
+			{"自检 测试 健康 self test health", selfTestBtn},
+		}},
+	}, saveBtn)
 
 	// ========== ABOUT TAB ==========
 	// Use bundled logo
@@ -853,12 +2565,16 @@ func main() {
 	monitorPage := container.NewPadded(monitorContent)
 	settingsPage := container.NewPadded(settingsContent)
 	aboutPage := container.NewPadded(aboutContent)
+	historyPage := container.NewPadded(newHistoryView(updateBatchList, w))
+	statsContent, refreshStats := newStatsView(w)
+	statsPage := container.NewPadded(statsContent)
+	teamPage := container.NewPadded(newTeamView(saveSettings, w))
 
 	// Container to hold current page
 	pageContainer := container.NewStack(monitorPage)
 
 	// Tab button style helper
-	var tabMonitor, tabSettings, tabAbout *widget.Button
+	var tabMonitor, tabSettings, tabAbout, tabHistory, tabStats, tabTeam *widget.Button
 	var currentTab int = 0
 
 	updateTabStyle := func() {
@@ -866,6 +2582,9 @@ func main() {
 		tabMonitor.Importance = widget.MediumImportance
 		tabSettings.Importance = widget.MediumImportance
 		tabAbout.Importance = widget.MediumImportance
+		tabHistory.Importance = widget.MediumImportance
+		tabStats.Importance = widget.MediumImportance
+		tabTeam.Importance = widget.MediumImportance
 		// Highlight current
 		switch currentTab {
 		case 0:
@@ -874,10 +2593,19 @@ func main() {
 			tabSettings.Importance = widget.HighImportance
 		case 2:
 			tabAbout.Importance = widget.HighImportance
+		case 3:
+			tabHistory.Importance = widget.HighImportance
+		case 4:
+			tabStats.Importance = widget.HighImportance
+		case 5:
+			tabTeam.Importance = widget.HighImportance
 		}
 		tabMonitor.Refresh()
 		tabSettings.Refresh()
 		tabAbout.Refresh()
+		tabHistory.Refresh()
+		tabStats.Refresh()
+		tabTeam.Refresh()
 	}
 
 	showPage := func(index int) {
@@ -890,6 +2618,13 @@ func main() {
 			pageContainer.Objects = []fyne.CanvasObject{settingsPage}
 		case 2:
 			pageContainer.Objects = []fyne.CanvasObject{aboutPage}
+		case 3:
+			pageContainer.Objects = []fyne.CanvasObject{historyPage}
+		case 4:
+			pageContainer.Objects = []fyne.CanvasObject{statsPage}
+			refreshStats()
+		case 5:
+			pageContainer.Objects = []fyne.CanvasObject{teamPage}
 		}
 		pageContainer.Refresh()
 		updateTabStyle()
@@ -898,12 +2633,15 @@ func main() {
 	tabMonitor = widget.NewButton("📡 监控", func() { showPage(0) })
 	tabSettings = widget.NewButton("⚙️ 设置", func() { showPage(1) })
 	tabAbout = widget.NewButton("ℹ️ 关于", func() { showPage(2) })
+	tabHistory = widget.NewButton("🗂️ 历史", func() { showPage(3) })
+	tabStats = widget.NewButton("📊 统计", func() { showPage(4) })
+	tabTeam = widget.NewButton("👥 团队", func() { showPage(5) })
 
 	tabMonitor.Importance = widget.HighImportance
 
 	// Create tab bar with equal-width buttons using GridWithColumns
-	tabBar := container.New(layout.NewGridLayoutWithColumns(3),
-		tabMonitor, tabSettings, tabAbout,
+	tabBar := container.New(layout.NewGridLayoutWithColumns(6),
+		tabMonitor, tabHistory, tabStats, tabTeam, tabSettings, tabAbout,
 	)
 
 	// Add separator under tab bar
@@ -912,59 +2650,706 @@ func main() {
 	// Main layout: tab bar at top, content below
 	mainContent := container.NewBorder(tabBarWithSep, nil, nil, nil, pageContainer)
 
+	// Clicking a completion notification shows its batch for a while
+	highlightBatch := func(id string) {
+		batchesMu.Lock()
+		highlightBatchID = id
+		batchesMu.Unlock()
+		requestUIUpdate()
+		time.AfterFunc(10*time.Second, func() {
+			batchesMu.Lock()
+			if highlightBatchID == id {
+				highlightBatchID = ""
+			}
+			batchesMu.Unlock()
+			requestUIUpdate()
+		})
+	}
+	notificationClicked = func(id string) {
+		fyne.Do(func() {
+			w.Show()
+			w.RequestFocus()
+			showPage(0)
+			highlightBatch(id)
+		})
+	}
+	// Without click reports, activating the app right after a notification
+	// is taken for a click, but only marks the batch if its page is open
+	a.Lifecycle().SetOnEnteredForeground(func() {
+		if id, ok := lastNotified.Take(time.Now()); ok && currentTab == 0 {
+			highlightBatch(id)
+		}
+	})
+
+	// Dropping a folder from Explorer/Finder onto the Monitor tab sets the watch path
+	w.SetOnDropped(func(_ fyne.Position, uris []fyne.URI) {
+		if currentTab != 0 {
+			return
+		}
+		dir, ok := droppedFolder(uris)
+		if !ok {
+			return
+		}
+		setWatchPath(dir)
+		saveSettings()
+	})
+
 	w.SetContent(mainContent)
+	if historyLoadErr != nil {
+		showHistoryRecoveryDialog(historyLoadErr, w)
+	}
+	if configLoadErr != nil {
+		logEvent("配置文件读取失败: %v", configLoadErr)
+		showConfigRecoveryDialog(configLoadErr, w)
+	} else if len(configProblems) > 0 {
+		logEvent("配置文件中有 %d 项无效设置", len(configProblems))
+		dialog.ShowInformation("配置已调整", "以下设置无效：\n\n"+strings.Join(configProblems, "\n"), w)
+	} else if firstRun && config.WatchPath == "" {
+		showFirstRunWizard(w, func(c Config) {
+			config = c
+			for i, opt := range localeOptions {
+				if opt.Tag == config.Locale {
+					localeSelect.SetSelectedIndex(i)
+				}
+			}
+			subdirCheck.SetChecked(config.MonitorSubdirs)
+			groupDepthEntry.SetText(fmt.Sprintf("%d", config.GroupDepth))
+			timeoutEntry.SetText(fmt.Sprintf("%d", config.CompletionTimeout))
+			sidecarCheck.SetChecked(config.PairSidecars)
+			probeCheck.SetChecked(config.ProbeVideo)
+			verifyArchivesCheck.SetChecked(config.VerifyArchives)
+			verifyChecksumsCheck.SetChecked(config.VerifyChecksums)
+			packCheck.SetChecked(config.PackEnabled)
+			startNotifyCheck.SetChecked(config.NotifyOnStart)
+			completeNotifyCheck.SetChecked(config.NotifyOnComplete)
+			soundCheck.SetChecked(config.SoundEnabled)
+			for _, opt := range sysLogOptions {
+				if opt.Backend == config.SysLog {
+					sysLogSelect.SetSelected(opt.Label)
+				}
+			}
+			if config.WatchPath != "" {
+				setWatchPath(config.WatchPath)
+			}
+			saveSettings()
+			updateBatchList()
+		})
+	}
+	// Launched with a folder, e.g. from the file manager's context menu:
+	// it was set through the overrides, so only start watching it
+	if opts.Folder != "" && configLoadErr == nil && !isMonitoring {
+		logEvent("按启动参数监控: %s", opts.Folder)
+		playBtn.OnTapped()
+	}
+
+	// Commands from `fidruawatch ctl` and later launches. They run on the
+	// UI goroutine like a click would.
+	onUI := func(f func() error) error {
+		var err error
+		fyne.DoAndWait(func() { err = f() })
+		return err
+	}
+	ctlActions := ctlActions{
+		Status: func() ctlStatus {
+			var s ctlStatus
+			fyne.DoAndWait(func() { s = currentCtlStatus() })
+			return s
+		},
+		AddPath: func(path string) error {
+			return onUI(func() error {
+				if isMonitoring && monitorPath == path {
+					return nil
+				}
+				if isMonitoring {
+					playBtn.OnTapped()
+				}
+				setWatchPath(path)
+				playBtn.OnTapped()
+				if !isMonitoring {
+					return fmt.Errorf("无法开始监控 %s, 详见窗口中的提示", path)
+				}
+				return nil
+			})
+		},
+		Start: func() error {
+			return onUI(func() error {
+				if !isMonitoring {
+					playBtn.OnTapped()
+				}
+				if !isMonitoring {
+					return fmt.Errorf("无法开始监控, 详见窗口中的提示")
+				}
+				return nil
+			})
+		},
+		Stop: func() error {
+			return onUI(func() error {
+				if isMonitoring {
+					playBtn.OnTapped()
+				}
+				return nil
+			})
+		},
+		Show: func() error {
+			return onUI(func() error {
+				w.Show()
+				w.RequestFocus()
+				return nil
+			})
+		},
+	}
+	if l, err := listenCtl(); err == nil {
+		go serveCtl(appCtx, l, ctlActions)
+	} else {
+		logEvent("控制通道不可用: %v", err)
+	}
+	go runDBus(appCtx, ctlActions, requestUIUpdate)
+	// Closing the main window quits even while the mini window is open, after
+	// confirming if uploads are in flight. Quitting from the tray menu ends
+	// up in OnStopped too, so cleanup happens there.
+	w.SetMaster()
+	w.SetCloseIntercept(func() {
+		confirmQuit(w, a.Quit)
+	})
+	a.Lifecycle().SetOnStopped(func() {
+		if isMonitoring {
+			monitorCancel()
+			activeWatch.Stop()
+			logEvent("停止监控")
+		}
+		finishShutdown()
+	})
+	mini.SetVisible(config.MiniWidget)
+	// Started minimized: stay in the tray until "显示窗口", if there is one
+	if opts.Minimized && tray.desk != nil {
+		logEvent("已最小化到托盘启动")
+		a.Run()
+		return
+	}
 	w.ShowAndRun()
 }
 
-func createBatchCard(b *Batch, updateUI func()) fyne.CanvasObject {
+func createBatchCard(b *Batch, updateUI func(), w fyne.Window) fyne.CanvasObject {
 	var statusColor color.Color
 	var statusLabel string
 	switch b.Status {
 	case "uploading":
 		statusColor = colorCyan
 		statusLabel = "上传中"
+		if b.Stalled {
+			statusColor = colorOrange
+			statusLabel = "⚠️ 已停滞"
+		}
 	case "completed":
 		statusColor = colorGreen
 		statusLabel = "已完成"
+	case statusReview:
+		statusColor = colorPurple
+		statusLabel = "待复核"
 	case "signed":
 		statusColor = colorGray
 		statusLabel = "已签收"
 	}
 
-	colorBar := canvas.NewRectangle(statusColor)
-	colorBar.SetMinSize(fyne.NewSize(5, 70))
-
-	folderName := filepath.Base(b.Folder)
-	titleLabel := widget.NewLabelWithStyle(
-		fmt.Sprintf("📁 %s（%d个文件）", folderName, len(b.Files)),
-		fyne.TextAlignLeading,
-		fyne.TextStyle{Bold: true},
+	if config.CompactCards {
+		return createCompactBatchCard(b, statusColor, statusLabel, updateUI, w)
+	}
+
+	colorBar := canvas.NewRectangle(statusColor)
+	colorBar.SetMinSize(fyne.NewSize(5, 70))
+
+	// A second bar and the title icon show the batch's main file type
+	breakdown := categoryBreakdown(b)
+	categoryIcon, categoryColor := categoryStyle(dominantCategory(breakdown))
+	categoryBar := canvas.NewRectangle(categoryColor)
+	categoryBar.SetMinSize(fyne.NewSize(3, 70))
+
+	folderName := displayFolder(b.Folder)
+	titleLabel := widget.NewLabelWithStyle(
+		fmt.Sprintf("%s %s（%s）", categoryIcon, folderName, fileCountText(b)),
+		fyne.TextAlignLeading,
+		fyne.TextStyle{Bold: true},
+	)
+
+	sizeStr := formatSize(b.TotalSize)
+	infoLabel := widget.NewLabel(fmt.Sprintf("🕐 %s · %s · %s", formatBatchTime(b.StartTime, time.Now()), sizeStr, statusLabel))
+
+	content := container.NewVBox(titleLabel, infoLabel)
+
+	// Files being written right now, from their own size histories, and
+	// when the batch will be checked for completion
+	if b.Status == "uploading" {
+		content.Add(newLiveLabel(b, countdownText))
+		content.Add(newLiveLabel(b, stableFilesText))
+		if text := growingFilesText(growingFiles(b, rateWindow, time.Now()), 2); text != "" {
+			content.Add(widget.NewLabel(text))
+		}
+	}
+
+	// Mixed batches list their categories
+	if len(breakdown) > 1 {
+		content.Add(widget.NewLabel(breakdownText(breakdown)))
+	}
+
+	if b.CheckCode != "" {
+		content.Add(widget.NewLabelWithStyle("🔐 校验码 "+b.CheckCode, fyne.TextAlignLeading, fyne.TextStyle{Monospace: true}))
+	}
+
+	if b.Exif != nil {
+		content.Add(widget.NewLabel("📷 " + b.Exif.String()))
+	}
+
+	if b.Ingest {
+		content.Add(widget.NewLabel(fmt.Sprintf("📥 导入中 %d%%", b.IngestPct)))
+	} else if b.IngestTo != "" {
+		content.Add(widget.NewLabel("📥 已导入: " + displayWindowsPath(b.IngestTo)))
+	}
+
+	if b.Packing {
+		content.Add(widget.NewLabel(fmt.Sprintf("📦 打包中 %d%%", b.PackPct)))
+	} else if b.PackPath != "" {
+		content.Add(widget.NewLabel("📦 已打包: " + filepath.Base(b.PackPath)))
+	}
+
+	switch b.Archive {
+	case archiveOK:
+		content.Add(widget.NewLabel("✅校验通过"))
+	case archiveBad:
+		content.Add(widget.NewLabel(fmt.Sprintf("❌损坏 (%d 个压缩包)", len(b.BadFiles))))
+	}
+
+	switch b.Checksum {
+	case checksumOK:
+		content.Add(widget.NewLabel("✅校验成功"))
+	case checksumBad:
+		content.Add(widget.NewLabel(fmt.Sprintf("❌校验失败 (%d 个文件)", len(checksumFailures(b)))))
+	}
+
+	if len(b.DupFiles) > 0 {
+		content.Add(widget.NewLabel(fmt.Sprintf("⚠️ 可能重复上传 (%d 个文件)", len(b.DupFiles))))
+	}
+
+	if len(b.Tags) > 0 {
+		content.Add(widget.NewLabel("🏷️ " + strings.Join(b.Tags, ", ")))
+	}
+
+	if b.Manifest != nil {
+		content.Add(widget.NewLabel(manifestSummary(compareManifest(b))))
+	}
+
+	if config.PairSidecars && b.Status == "uploading" {
+		if p := pairSidecars(b); len(p.Orphans)+len(p.Waiting) > 0 {
+			content.Add(widget.NewLabel(fmt.Sprintf("⏳ %d 个文件等待配对的附属文件或主文件", len(p.Orphans)+len(p.Waiting))))
+		}
+	}
+
+	if len(b.Locked) > 0 && b.Status == "uploading" {
+		content.Add(widget.NewLabel(fmt.Sprintf("🔒 %d 个文件仍被占用", len(b.Locked))))
+	}
+
+	if n := zeroByteCount(b); n > 0 {
+		switch config.ZeroByteMode {
+		case zeroByteHold:
+			if b.Status == "uploading" {
+				content.Add(widget.NewLabel(fmt.Sprintf("⏸ %d 个空文件等待写入", n)))
+			}
+		case zeroByteFlag:
+			content.Add(widget.NewLabel(fmt.Sprintf("⚠️ %d 个空文件", n)))
+		}
+	}
+
+	detailBtn := widget.NewButton("📋 详情", func() {
+		showBatchDetailDialog(b, updateUI, w)
+	})
+	var moreBtn *widget.Button
+	moreBtn = widget.NewButton("⋯", func() {
+		menu := fyne.NewMenu("",
+			fyne.NewMenuItem("复制路径", func() {
+				fyne.CurrentApp().Clipboard().SetContent(displayWindowsPath(b.Folder))
+			}),
+			fyne.NewMenuItem("删除此批次", func() {
+				dialog.ShowConfirm("删除批次", fmt.Sprintf("确定删除批次 %s 吗？\n（不会删除磁盘上的文件）", folderName), func(ok bool) {
+					if ok {
+						if deleted := deleteBatch(b.ID); deleted != nil {
+							updateUI()
+							showUndoToast([]*Batch{deleted}, updateUI, w)
+						}
+					}
+				}, w)
+			}),
+			fyne.NewMenuItem("重新监控", func() {
+				requeueBatch(b)
+				updateUI()
+			}),
+			fyne.NewMenuItem("打包为 zip", func() {
+				go packBatchNow(b, updateUI, fyne.CurrentApp())
+			}),
+			fyne.NewMenuItem("对照清单...", func() {
+				showManifestPicker(b, updateUI, w)
+			}),
+		)
+		widget.ShowPopUpMenuAtRelativePosition(menu, w.Canvas(), fyne.NewPos(0, moreBtn.Size().Height), moreBtn)
+	})
+	actions := container.NewHBox(detailBtn, moreBtn)
+
+	if b.Status == "completed" || b.Status == statusReview {
+		label := "✅ 签收此批次"
+		if b.Status == "completed" && config.RequireReview {
+			label = "🔍 复核此批次"
+		}
+		signBtn := widget.NewButton(label, func() {
+			showSignDialog(b, updateUI, w)
+		})
+		signBtn.Importance = widget.SuccessImportance
+		actions.Add(signBtn)
+	}
+
+	if config.Profile == profileDownloads && b.Status != "uploading" {
+		moveBtn := widget.NewButton("📦 移动到项目文件夹", func() {
+			d := dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
+				if err != nil || uri == nil {
+					return
+				}
+				if err := moveBatchFiles(b, normalizeWatchPath(uri.Path())); err != nil {
+					dialog.ShowError(err, w)
+				}
+				updateUI()
+			}, w)
+			d.Resize(fyne.NewSize(600, 450))
+			d.Show()
+		})
+		actions.Add(moveBtn)
+	}
+	content.Add(actions)
+
+	// Card background
+	cardBg := canvas.NewRectangle(theme.Color(colorNameCard))
+	cardBg.CornerRadius = 8
+	if b.ID == highlightBatchID {
+		cardBg.StrokeColor = theme.Color(theme.ColorNamePrimary)
+		cardBg.StrokeWidth = 2
+	}
+
+	cardContent := container.NewHBox(colorBar, categoryBar, container.NewPadded(content))
+	card := container.NewStack(cardBg, cardContent)
+
+	return container.NewPadded(card)
+}
+
+// deleteBatch moves a batch from the list to the trash and returns it, nil
+// when there is no such batch; files on disk are untouched
+func deleteBatch(id string) *Batch {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	b, ok := batches[id]
+	if !ok {
+		return nil
+	}
+	trashBatches([]*Batch{b}, time.Now())
+	return b
+}
+
+// requeueBatch puts a batch back into uploading so stability checking
+// resumes, e.g. after it was completed or signed by mistake
+func requeueBatch(b *Batch) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	resumeUploading(b, time.Now())
+	logEvent("重新监控批次 %s: %s", b.ID, b.Folder)
+	publishBatchEvent(eventRequeued, b)
+}
+
+// createCompactBatchCard renders a batch as a single line for small screens;
+// everything else is in the detail dialog
+func createCompactBatchCard(b *Batch, statusColor color.Color, statusLabel string, updateUI func(), w fyne.Window) fyne.CanvasObject {
+	colorBar := canvas.NewRectangle(statusColor)
+	colorBar.SetMinSize(fyne.NewSize(4, 24))
+
+	categoryIcon, _ := categoryStyle(dominantCategory(categoryBreakdown(b)))
+	line := widget.NewLabel(fmt.Sprintf("%s %s · %s · %s · %s",
+		categoryIcon, displayFolder(b.Folder), fileCountText(b), formatSize(b.TotalSize), statusLabel))
+	line.Truncation = fyne.TextTruncateEllipsis
+
+	actions := container.NewHBox(widget.NewButton("📋", func() {
+		showBatchDetailDialog(b, updateUI, w)
+	}))
+	if b.Status == "completed" || b.Status == statusReview {
+		signBtn := widget.NewButton("✅", func() {
+			showSignDialog(b, updateUI, w)
+		})
+		signBtn.Importance = widget.SuccessImportance
+		actions.Add(signBtn)
+	}
+
+	cardBg := canvas.NewRectangle(theme.Color(colorNameCard))
+	cardBg.CornerRadius = 4
+	if b.ID == highlightBatchID {
+		cardBg.StrokeColor = theme.Color(theme.ColorNamePrimary)
+		cardBg.StrokeWidth = 2
+	}
+	return container.NewStack(cardBg, container.NewBorder(nil, nil, colorBar, actions, line))
+}
+
+// showSignDialog asks for the operator name and an optional comment, then
+// reviews or signs the batch
+func showSignDialog(b *Batch, updateUI func(), w fyne.Window) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(config.OperatorName)
+	commentEntry := widget.NewMultiLineEntry()
+	commentEntry.SetPlaceHolder("备注 (可选)")
+	items := []*widget.FormItem{
+		widget.NewFormItem("操作员", nameEntry),
+		widget.NewFormItem("备注", commentEntry),
+	}
+	title := "签收批次"
+	if b.Status == "completed" && config.RequireReview {
+		title = "复核批次"
+	}
+	dialog.ShowForm(title, "确定", "取消", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		batchesMu.Lock()
+		err := signBatchAs(b, nameEntry.Text, localPrincipal(), commentEntry.Text, time.Now())
+		batchesMu.Unlock()
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		updateUI()
+	}, w)
+}
+
+// maxDetailThumbs caps the thumbnails generated for one detail dialog
+const maxDetailThumbs = 24
+
+// showBatchDetailDialog shows a batch's location, timing and per-subfolder breakdown
+func showBatchDetailDialog(b *Batch, updateUI func(), w fyne.Window) {
+	batchesMu.RLock()
+	info := widget.NewLabel(fmt.Sprintf("📁 %s\n📄 %s · %s\n🕐 %s ~ %s",
+		displayWindowsPath(b.Folder), fileCountText(b), formatSize(b.TotalSize),
+		formatBatchTime(b.StartTime, time.Now()), formatBatchTime(b.LastTime, time.Now())))
+	info.Wrapping = fyne.TextWrapWord
+	if b.SessionID != "" {
+		info.SetText(info.Text + "\n🆔 会话: " + b.SessionID)
+	}
+	if b.Uploader != "" {
+		info.SetText(info.Text + "\n🔧 上传工具: " + b.Uploader)
+	}
+	if b.Status == "uploading" && config.AdaptiveTimeout {
+		info.SetText(info.Text + fmt.Sprintf("\n🧠 自适应完成判定: 空闲 %d 秒", int(batchTimeout(b).Seconds())))
+	}
+	if b.CheckCode != "" {
+		info.SetText(info.Text + "\n🔐 校验码: " + b.CheckCode)
+	}
+	if len(b.Tags) > 0 {
+		info.SetText(info.Text + "\n🏷️ 标签: " + strings.Join(b.Tags, ", "))
+	}
+	if len(b.DupFiles) > 0 {
+		info.SetText(info.Text + "\n⚠️ 可能重复上传: " + strings.Join(b.DupFiles, ", "))
+	}
+	if len(b.BadFiles) > 0 {
+		info.SetText(info.Text + "\n❌ 损坏的压缩包: " + strings.Join(b.BadFiles, ", "))
+	}
+	if b.Checksum != checksumUnchecked {
+		info.SetText(info.Text + "\n🔑 文件校验:" + checksumDetails(b))
+	}
+	if b.Manifest != nil {
+		d := compareManifest(b)
+		info.SetText(info.Text + "\n" + manifestSummary(d))
+		if len(d.Missing) > 0 {
+			info.SetText(info.Text + "\n  缺少: " + strings.Join(d.Missing, ", "))
+		}
+		if len(d.Mismatched) > 0 {
+			info.SetText(info.Text + "\n  大小不符: " + strings.Join(d.Mismatched, ", "))
+		}
+		if len(d.Extra) > 0 {
+			info.SetText(info.Text + "\n  多余: " + strings.Join(d.Extra, ", "))
+		}
+	}
+	for _, so := range b.SignOffs {
+		info.SetText(info.Text + "\n✍️ " + signOffText(so))
+	}
+	breakdown := subfolderBreakdown(b)
+	media := make([]string, 0, len(b.Media))
+	for f, v := range b.Media {
+		media = append(media, fmt.Sprintf("🎬 %s — %s", f, v))
+	}
+	var previews []string
+	for _, f := range b.Files {
+		if len(previews) == maxDetailThumbs {
+			break
+		}
+		if hasExt(f, thumbExts) || hasExt(f, videoExts) {
+			previews = append(previews, filepath.Join(b.Folder, f))
+		}
+	}
+	var rates []ratePoint
+	if b.Samples != nil {
+		rates = rateSeries(b.Samples.Samples())
+	}
+	timeline := timelineLines(b.Timeline)
+	var files []string
+	now := time.Now()
+	for _, f := range b.Files {
+		if len(files) == maxDetailFiles {
+			files = append(files, fmt.Sprintf("… 还有 %d 个文件", len(b.Files)-maxDetailFiles))
+			break
+		}
+		mark := "✅"
+		if !fileStable(b, f, now) {
+			mark = "⏳"
+		}
+		files = append(files, fmt.Sprintf("%s %s · %s", mark, f, formatSize(b.FileSizes[f])))
+	}
+	batchesMu.RUnlock()
+	sort.Strings(media)
+
+	subList := container.NewVBox()
+	for _, sub := range breakdown {
+		name := sub.Path
+		if name == "." {
+			name = "(根目录)"
+		}
+		subList.Add(widget.NewLabel(fmt.Sprintf("📂 %s — %d 个文件 · %s", name, sub.Files, formatSize(sub.Size))))
+	}
+	subScroll := container.NewVScroll(subList)
+	subScroll.SetMinSize(fyne.NewSize(360, 200))
+
+	content := container.NewVBox(
+		info,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("子文件夹明细：", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		subScroll,
 	)
+	if len(files) > 0 {
+		filesScroll := container.NewVScroll(widget.NewLabel(strings.Join(files, "\n")))
+		filesScroll.SetMinSize(fyne.NewSize(360, 160))
+		content.Add(widget.NewSeparator())
+		content.Add(widget.NewLabelWithStyle("文件 (✅ 已稳定 · ⏳ 仍在变化)：", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		content.Add(filesScroll)
+	}
+	if len(timeline) > 0 {
+		timelineScroll := container.NewVScroll(widget.NewLabel(strings.Join(timeline, "\n")))
+		timelineScroll.SetMinSize(fyne.NewSize(360, 160))
+		content.Add(widget.NewSeparator())
+		content.Add(widget.NewLabelWithStyle("时间线：", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		content.Add(timelineScroll)
+	}
+	if len(rates) > 0 {
+		content.Add(widget.NewSeparator())
+		content.Add(widget.NewLabelWithStyle("传输速率：", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		content.Add(newRateChart(rates))
+	}
+	if len(media) > 0 {
+		content.Add(widget.NewSeparator())
+		content.Add(widget.NewLabelWithStyle("视频信息：", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		content.Add(widget.NewLabel(strings.Join(media, "\n")))
+	}
+	if len(previews) > 0 {
+		// Thumbnails are generated in the background and appear as they're ready
+		grid := container.NewGridWrap(fyne.NewSize(96, 96))
+		gridScroll := container.NewVScroll(grid)
+		gridScroll.SetMinSize(fyne.NewSize(360, 110))
+		content.Add(widget.NewSeparator())
+		content.Add(widget.NewLabelWithStyle("预览：", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}))
+		content.Add(gridScroll)
+		go func() {
+			for _, src := range previews {
+				thumb, err := thumbnail(src)
+				if err != nil {
+					continue
+				}
+				fyne.Do(func() {
+					img := canvas.NewImageFromFile(thumb)
+					img.FillMode = canvas.ImageFillContain
+					grid.Add(img)
+				})
+			}
+		}()
+	}
 
-	sizeStr := formatSize(b.TotalSize)
-	infoLabel := widget.NewLabel(fmt.Sprintf("🕐 %s · %s · %s", b.StartTime.Format("15:04:05"), sizeStr, statusLabel))
+	if b.Status != "uploading" {
+		content.Add(widget.NewSeparator())
+		content.Add(widget.NewButton("🏷️ 按模板整理文件", func() {
+			showRenamePreview(b, updateUI, w)
+		}))
+	}
 
-	content := container.NewVBox(titleLabel, infoLabel)
+	d := dialog.NewCustom("批次详情 - "+displayFolder(b.Folder), "关闭", content, w)
+	d.Resize(fyne.NewSize(400, 400))
+	d.Show()
+}
 
-	if b.Status == "completed" {
-		signBtn := widget.NewButton("✅ 签收此批次", func() {
-			batchesMu.Lock()
-			b.Status = "signed"
-			batchesMu.Unlock()
-			updateUI()
-		})
-		signBtn.Importance = widget.SuccessImportance
-		content.Add(signBtn)
+// showRenamePreview shows a dry run of the rename template for a batch and
+// applies it on confirmation
+func showRenamePreview(b *Batch, updateUI func(), w fyne.Window) {
+	batchesMu.RLock()
+	ops, err := planRename(b, config.RenameTemplate)
+	batchesMu.RUnlock()
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
 	}
 
-	// Card background
-	cardBg := canvas.NewRectangle(color.NRGBA{R: 35, G: 40, B: 60, A: 255})
-	cardBg.CornerRadius = 8
+	preview := widget.NewLabel(renamePreview(ops))
+	scroll := container.NewVScroll(preview)
+	scroll.SetMinSize(fyne.NewSize(420, 240))
+	content := container.NewBorder(widget.NewLabel("模板: "+config.RenameTemplate), nil, nil, nil, scroll)
+	dialog.ShowCustomConfirm("整理文件预览", "执行", "取消", content, func(ok bool) {
+		if !ok {
+			return
+		}
+		if err := applyRename(b, ops); err != nil {
+			dialog.ShowError(err, w)
+		}
+		updateUI()
+	}, w)
+}
 
-	cardContent := container.NewHBox(colorBar, container.NewPadded(content))
-	card := container.NewStack(cardBg, cardContent)
+// showManifestPicker lets the user pick a CSV or JSON manifest to compare
+// a batch against
+func showManifestPicker(b *Batch, updateUI func(), w fyne.Window) {
+	d := dialog.NewFileOpen(func(r fyne.URIReadCloser, err error) {
+		if err != nil || r == nil {
+			return
+		}
+		path := r.URI().Path()
+		r.Close()
+		m, err := loadManifest(path)
+		if err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		attachManifest(b, m)
+		saveCheckpoint()
+		updateUI()
+	}, w)
+	d.SetFilter(storage.NewExtensionFileFilter([]string{".csv", ".json"}))
+	if loc, err := storage.ListerForURI(storage.NewFileURI(b.Folder)); err == nil {
+		d.SetLocation(loc)
+	}
+	d.Resize(fyne.NewSize(600, 450))
+	d.Show()
+}
 
-	return container.NewPadded(card)
+// droppedFolder returns the first local folder among dropped items; for a
+// dropped file its containing folder is used
+func droppedFolder(uris []fyne.URI) (string, bool) {
+	for _, u := range uris {
+		if u == nil || u.Scheme() != "file" {
+			continue
+		}
+		info, err := os.Stat(u.Path())
+		if err != nil {
+			continue
+		}
+		if info.IsDir() {
+			return u.Path(), true
+		}
+		return filepath.Dir(u.Path()), true
+	}
+	return "", false
 }
 
 func showFileTypeDialog(w fyne.Window) {
@@ -1026,141 +3411,180 @@ func showFileTypeDialog(w fyne.Window) {
 }
 
 func startMonitor(path string) error {
-	watcherMu.Lock()
-	defer watcherMu.Unlock()
-
-	var err error
-	watcher, err = fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-
-	if config.MonitorSubdirs {
-		err = filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
-			if err != nil {
-				return nil
-			}
-			if info.IsDir() {
-				watcher.Add(p)
-			}
-			return nil
-		})
-	} else {
-		err = watcher.Add(path)
-	}
-	return err
+	return watchers.Start(path)
 }
 
 func stopMonitor() {
-	watcherMu.Lock()
-	defer watcherMu.Unlock()
-	if watcher != nil {
-		watcher.Close()
-		watcher = nil
-	}
+	watchers.Close()
 }
 
 func handleFileEvents(ctx context.Context, updateUI func(), app fyne.App) {
-	watcherMu.Lock()
-	w := watcher
-	watcherMu.Unlock()
-
-	if w == nil {
+	events, errs := watchers.Run(ctx, updateUI, func(o rootOutage) { noteRootOutage(app, o) })
+	if events == nil {
 		return
 	}
 
+	pool := newWorkPool(ctx, statWorkers, statQueueSize)
+	statPool.Store(pool)
+	overflowed := false
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case event, ok := <-w.Events:
-			if !ok {
-				return
-			}
+		case event := <-events:
+			lastWatchEvent.Store(time.Now().UnixNano())
 			if event.Op&(fsnotify.Create|fsnotify.Write|fsnotify.Rename) != 0 {
-				if config.MonitorSubdirs {
-					if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
-						watcherMu.Lock()
-						if watcher != nil {
-							watcher.Add(event.Name)
-						}
-						watcherMu.Unlock()
-						continue
-					}
-				}
-				if isMonitoredFile(event.Name) {
-					isNewBatch := addFileToBatch(event.Name)
-					if isNewBatch && config.NotifyOnStart {
-						app.SendNotification(&fyne.Notification{
-							Title:   "FidruaWatch - 新上传",
-							Content: fmt.Sprintf("检测到新文件: %s", filepath.Base(event.Name)),
-						})
-						// Play sound for new upload
-						playSound(SoundTypeStart)
-					}
-					updateUI()
+				// Stat in the pool: on slow shares it would block this loop
+				// and the kernel would drop events meanwhile
+				path := event.Name
+				if pool.Submit(path, func() { processFileEvent(path, updateUI, app) }) {
+					overflowed = false
+				} else if !overflowed {
+					overflowed = true
+					logEvent("文件事件过多, 处理队列已满, 执行补漏扫描")
+					requestRescan()
 				}
 			}
-		case _, ok := <-w.Errors:
-			if !ok {
-				return
+		case err := <-errs:
+			// The kernel queue overflowed and events were lost; reconcile now
+			// instead of waiting for the next periodic rescan
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				logEvent("事件队列溢出，执行补漏扫描")
+				requestRescan()
+			} else {
+				logEvent("监控错误: %v", err)
 			}
 		}
 	}
 }
 
+// processFileEvent handles a created or written path: new folders are
+// watched when subfolders are monitored, monitored files are added to
+// their batch
+func processFileEvent(path string, updateUI func(), app fyne.App) {
+	if noteSelfTestEvent(path) {
+		return
+	}
+	info, err := os.Stat(path)
+	if err == nil && info.IsDir() {
+		if config.MonitorSubdirs {
+			watchers.AddDir(path)
+		}
+		return
+	}
+	if tool := detectUploader(path); tool != "" {
+		noteUploader(path, tool)
+	}
+	if isMonitoredFile(path) {
+		size := int64(-1)
+		if err == nil {
+			size = info.Size()
+		}
+		announceIngest(addObservedFile(path, size), path, updateUI, app)
+	}
+}
+
+// ingestFile adds a monitored file to its batch and announces new batches
+func ingestFile(path string, updateUI func(), app fyne.App) {
+	announceIngest(addFileToBatch(path), path, updateUI, app)
+}
+
+// announceIngest notifies about a new batch started by path and refreshes the UI
+func announceIngest(isNewBatch bool, path string, updateUI func(), app fyne.App) {
+	if isNewBatch && config.NotifyOnStart {
+		// Starts close together share one notification and sound
+		batchStarts.Add(app, path)
+	}
+	updateUI()
+}
+
 func isMonitoredFile(path string) bool {
 	if isTempFile(path) {
 		return false
 	}
-	ext := strings.ToLower(filepath.Ext(path))
-	for _, ve := range getEnabledExts() {
-		if ext == ve {
-			return true
-		}
-	}
-	return false
+	monitored := enabledExtSet()[strings.ToLower(filepath.Ext(path))] || config.PairSidecars && isSidecar(path) ||
+		config.VerifyChecksums && isSumsFile(path)
+	return monitored && !isExcluded(path) && !isOwnOutput(path)
 }
 
 func isTempFile(path string) bool {
+	return tempFilePattern(path) != ""
+}
+
+// tempFilePattern returns the temp file pattern path matches, or ""
+func tempFilePattern(path string) string {
 	name := strings.ToLower(filepath.Base(path))
 	for _, pattern := range tempFilePatterns {
 		if strings.Contains(name, pattern) || strings.HasPrefix(name, pattern) {
-			return true
+			return pattern
 		}
 	}
-	return false
+	return ""
 }
 
 func addFileToBatch(filePath string) (isNewBatch bool) {
+	fileSize := int64(-1)
+	if info, err := os.Stat(filePath); err == nil {
+		fileSize = info.Size()
+	}
+	return addObservedFile(filePath, fileSize)
+}
+
+// addObservedFile records a file of the given size in its batch. Sizes come
+// from os.Stat for watched files or from the reporting system for external
+// events; -1 means unknown (e.g. the file was renamed away) and keeps the
+// size already recorded. A smaller size than before is a file being
+// rewritten and lowers the batch total accordingly.
+func addObservedFile(filePath string, fileSize int64) (isNewBatch bool) {
 	// Normalize path for consistent comparison (especially on Windows)
 	filePath = filepath.Clean(filePath)
-	folder := filepath.Dir(filePath)
-	fileName := filepath.Base(filePath)
-
-	// On Windows, normalize to lowercase for comparison
-	folderNorm := folder
-	if runtime.GOOS == "windows" {
-		folderNorm = strings.ToLower(folder)
+	folder := groupFolder(monitorPath, filepath.Dir(filePath), config.GroupDepth)
+	// Files are stored relative to the batch folder so that grouped batches
+	// keep their subfolder structure (e.g. "Day1/CamA/clip.mp4")
+	fileName, err := filepath.Rel(folder, filePath)
+	if err != nil {
+		fileName = filepath.Base(filePath)
 	}
 
-	var fileSize int64
-	if info, err := os.Stat(filePath); err == nil {
-		fileSize = info.Size()
+	// Compare paths in normalized form (NFC, case-folded where configured)
+	folderNorm := pathKey(folder)
+
+	// Only a new file known to be empty is skipped; an unknown size or a
+	// tracked file truncated mid-upload is checked again at completion
+	if fileSize == 0 && config.ZeroByteMode == zeroByteIgnore && !trackedFile(folderNorm, fileName) {
+		return false
+	}
+	if rule := ignoringRule(filePath, fileSize, time.Now()); rule != "" {
+		dropIgnoredFile(filePath, rule)
+		return false
+	}
+	if !scriptAllowsFile(filePath, fileSize) {
+		return false
 	}
 
 	batchesMu.Lock()
 	defer batchesMu.Unlock()
 
 	var batch *Batch
-	for _, b := range batches {
-		bFolderNorm := b.Folder
-		if runtime.GOOS == "windows" {
-			bFolderNorm = strings.ToLower(b.Folder)
+	if config.Profile == profileDownloads {
+		// Downloads land flat in one folder, so group by arrival time instead
+		batch = recentUploadingBatch(time.Now())
+	} else {
+		for _, b := range batches {
+			if pathKey(b.Folder) == folderNorm && b.Status == "uploading" {
+				batch = b
+				break
+			}
 		}
-		if bFolderNorm == folderNorm && b.Status == "uploading" {
-			batch = b
-			break
+	}
+
+	// A late file or a change to a recently completed batch continues it
+	// rather than starting a new one
+	if batch == nil && config.Profile != profileDownloads {
+		if batch = reopenableBatch(folderNorm, time.Now()); batch != nil {
+			resumeUploading(batch, time.Now())
+			logEvent("批次 %s 完成后有文件变化, 重新打开: %s", batch.ID, batch.Folder)
+			publishBatchEvent(eventRequeued, batch)
 		}
 	}
 
@@ -1172,32 +3596,177 @@ func addFileToBatch(filePath string) (isNewBatch bool) {
 			FileSizes: make(map[string]int64),
 			Status:    "uploading",
 			StartTime: time.Now(),
+			Uploader:  pendingUploaders[folderNorm].Tool,
+			Samples:   newBatchSampleRing(),
+			SessionID: sessionID,
 		}
+		delete(pendingUploaders, folderNorm)
 		batches[batch.ID] = batch
 		isNewBatch = true
+		logEvent("新批次 %s: %s", batch.ID, folder)
+		publishBatchEvent(eventCreated, batch)
 	}
 
+	// Keep the first spelling seen so an NFD name from macOS and its NFC
+	// form elsewhere count as one file
 	exists := false
+	fileKey := pathKey(fileName)
 	for _, f := range batch.Files {
-		if f == fileName {
+		if pathKey(f) == fileKey {
+			fileName = f
 			exists = true
 			break
 		}
 	}
 	if !exists {
 		batch.Files = append(batch.Files, fileName)
+		if isNewBatch {
+			noteTimeline(batch, timelineCreated, fileName, time.Now())
+		} else {
+			noteTimeline(batch, timelineFile, fileName, time.Now())
+		}
 	}
 
 	oldSize := batch.FileSizes[fileName]
-	if fileSize > oldSize {
-		batch.TotalSize += fileSize - oldSize
-		batch.FileSizes[fileName] = fileSize
+	if fileSize < 0 {
+		fileSize = oldSize
 	}
+	batch.TotalSize += fileSize - oldSize
+	batch.FileSizes[fileName] = fileSize
 
 	batch.LastTime = time.Now()
+	if !exists || fileSize != oldSize {
+		noteGrowth(batch, batch.LastTime)
+		if noteFileSize(batch, fileName, fileSize, batch.LastTime) {
+			logEvent("批次 %s: %s 被截断重写 (%s → %s)", batch.ID, fileName, formatSize(oldSize), formatSize(fileSize))
+		}
+	}
+	batch.Samples.Add(Sample{Time: batch.LastTime, Size: batch.TotalSize})
 	return
 }
 
+// pathKey returns the form of a path used for comparisons: Unicode NFC, so
+// NFD names from macOS match their NFC spelling, and lower-cased on Windows
+// or when case-insensitive matching is enabled
+func pathKey(p string) string {
+	p = norm.NFC.String(p)
+	if runtime.GOOS == "windows" || config.CaseInsensitive {
+		p = strings.ToLower(p)
+	}
+	return p
+}
+
+// detectUploader guesses the transfer tool from a temp file name, or returns ""
+func detectUploader(path string) string {
+	name := filepath.Base(path)
+	for _, sig := range uploaderSignatures {
+		if sig.Match(name) {
+			return sig.Name
+		}
+	}
+	return ""
+}
+
+// noteUploader records the tool seen writing path on the uploading batch for
+// its folder, or remembers it until that batch is created
+func noteUploader(path, tool string) {
+	folder := groupFolder(monitorPath, filepath.Dir(filepath.Clean(path)), config.GroupDepth)
+	folderNorm := pathKey(folder)
+
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	for _, b := range batches {
+		if pathKey(b.Folder) == folderNorm && b.Status == "uploading" {
+			if b.Uploader == "" {
+				b.Uploader = tool
+			}
+			return
+		}
+	}
+	pendingUploaders[folderNorm] = pendingUploader{Tool: tool, Seen: time.Now()}
+}
+
+// pendingUploader is a tool seen in a folder before its batch was created
+type pendingUploader struct {
+	Tool string
+	Seen time.Time
+}
+
+// pendingUploaderTTL is how long a detected tool waits for its batch. Temp
+// files of a transfer that never produced a monitored file, e.g. cancelled
+// or filtered out, would otherwise stay forever.
+const pendingUploaderTTL = 10 * time.Minute
+
+// prunePendingUploaders forgets tools detected too long ago. Caller must
+// hold batchesMu.
+func prunePendingUploaders(now time.Time) {
+	for folder, p := range pendingUploaders {
+		if now.Sub(p.Seen) > pendingUploaderTTL {
+			delete(pendingUploaders, folder)
+		}
+	}
+}
+
+// groupFolder returns the folder that files in dir are batched under.
+// With depth 0, or when dir is not below root, it is dir itself; otherwise
+// dir is truncated to depth path components below root, so an upload
+// spread across Project/Day1/CamA and Project/Day1/CamB lands in one batch.
+func groupFolder(root, dir string, depth int) string {
+	if depth <= 0 || root == "" {
+		return dir
+	}
+	rel, err := filepath.Rel(filepath.Clean(root), dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return dir
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) <= depth {
+		return dir
+	}
+	return filepath.Join(append([]string{root}, parts[:depth]...)...)
+}
+
+// displayFolder returns a batch folder relative to the watch root for display,
+// falling back to the folder name when it is the root itself or outside it
+func displayFolder(folder string) string {
+	if monitorPath != "" {
+		rel, err := filepath.Rel(filepath.Clean(monitorPath), folder)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.Base(folder)
+}
+
+// SubfolderStat summarizes the files of a batch that live in one subfolder
+type SubfolderStat struct {
+	Path  string // relative to the batch folder, "." for top-level files
+	Files int
+	Size  int64
+}
+
+// subfolderBreakdown groups a batch's files by their subfolder, sorted by path.
+// Caller must hold batchesMu.
+func subfolderBreakdown(b *Batch) []SubfolderStat {
+	index := make(map[string]int)
+	var stats []SubfolderStat
+	for _, f := range b.Files {
+		dir := filepath.ToSlash(filepath.Dir(f))
+		i, ok := index[dir]
+		if !ok {
+			i = len(stats)
+			index[dir] = i
+			stats = append(stats, SubfolderStat{Path: dir})
+		}
+		stats[i].Files++
+		stats[i].Size += b.FileSizes[f]
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Path < stats[j].Path
+	})
+	return stats
+}
+
 // playSound plays a notification sound repeatedly for better attention
 // SoundType indicates which sound to play
 type SoundType int
@@ -1208,7 +3777,10 @@ const (
 )
 
 func playSound(soundType SoundType) {
-	if !config.SoundEnabled {
+	if !config.SoundEnabled || headless {
+		return
+	}
+	if muted, _ := mute.Active(time.Now()); muted {
 		return
 	}
 	// Play sound in goroutine to not block UI
@@ -1296,41 +3868,267 @@ objShell.Run "powershell -NoProfile -ExecutionPolicy Bypass -Command ""%s""", 0,
 	os.Remove(vbsPath)
 }
 
+// completionTimeout is the idle time after which a batch completes
+func completionTimeout() time.Duration {
+	timeout := time.Duration(config.CompletionTimeout) * time.Second
+	if timeout < 10*time.Second {
+		timeout = 30 * time.Second
+	}
+	return timeout
+}
+
+// holdCompletion reports whether an idle batch must not complete yet.
+// Caller must hold batchesMu.
+func holdCompletion(b *Batch) bool {
+	return holdForZeroByte(b) || holdForDownloads(b) || holdForSidecars(b) || holdForManifest(b) || holdForLockedFiles(b) || holdForUnstableFiles(b) ||
+		holdForLostRoot(b)
+}
+
 func checkCompletions(ctx context.Context, updateUI func(), app fyne.App) {
-	ticker := time.NewTicker(3 * time.Second) // Check more frequently
+	const interval = 3 * time.Second // Check more frequently
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
+	var clock clockWatch
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
-		case <-ticker.C:
-			// Read timeout from config each time (in case it changed)
-			timeout := time.Duration(config.CompletionTimeout) * time.Second
-			if timeout < 10*time.Second {
-				timeout = 30 * time.Second
-			}
-
+		case tick := <-ticker.C:
+			refreshLockedFiles(ctx, time.Now())
 			batchesMu.Lock()
+			reconcileClock(&clock, tick, interval)
+			sessionRates.Note(time.Now())
 			for _, b := range batches {
-				if b.Status == "uploading" && time.Since(b.LastTime) > timeout {
+				if checkStalled(b, time.Now()) {
+					logEvent("批次停滞 %s: %s (%d 分钟无新数据)", b.ID, b.Folder, config.StallMinutes)
+					publishBatchEvent(eventStalled, b)
+					if config.StallAlert {
+						sendNotification(app, "FidruaWatch - 上传停滞", fmt.Sprintf("%s 已 %d 分钟没有新数据, 请检查传输是否中断", displayFolder(b.Folder), config.StallMinutes))
+					}
+				}
+				// The timeout is read each time, in case the settings changed
+				if b.Status == "uploading" && time.Since(b.LastTime) > batchTimeout(b) && !holdCompletion(b) {
 					b.Status = "completed"
+					b.DoneTime = time.Now()
+					b.Stalled = false
+					b.Growth = nil
+					b.CheckCode = verificationCode(batchManifest(b))
+					noteTimeline(b, timelineCompleted, fmt.Sprintf("(%d个文件, %s)", len(b.Files), formatSize(b.TotalSize)), b.DoneTime)
+					logEvent("批次完成 %s: %s (%d个文件, %s, 校验码 %s)", b.ID, b.Folder, len(b.Files), formatSize(b.TotalSize), b.CheckCode)
+					// Duplicates go into the history record and the event
+					if b.DupFiles = checkDuplicates(b); len(b.DupFiles) > 0 {
+						logEvent("批次 %s 可能重复上传: %d 个文件与历史记录相同", b.ID, len(b.DupFiles))
+						sendNotification(app, "FidruaWatch - 可能重复上传", fmt.Sprintf("%s 中有 %d 个文件与之前的批次相同", displayFolder(b.Folder), len(b.DupFiles)))
+					}
+					recordHistory(b)
+					publishBatchEvent(eventCompleted, b)
+					// Rule moves go first, so post-processing finds the files
+					// where they ended up
+					outcome := applyBatchRules(b, app, time.Now())
+					if outcome.Pending() {
+						go func(b *Batch) {
+							runRuleActions(ctx, b, outcome)
+							batchesMu.Lock()
+							startPostProcessing(ctx, b, updateUI, app)
+							batchesMu.Unlock()
+							updateUI()
+						}(b)
+					} else {
+						startPostProcessing(ctx, b, updateUI, app)
+					}
+					if outcome.Silent {
+						continue
+					}
 					if config.NotifyOnComplete {
-						app.SendNotification(&fyne.Notification{
-							Title:   "FidruaWatch - 上传完成",
-							Content: fmt.Sprintf("批次完成: %s (%d个文件)", filepath.Base(b.Folder), len(b.Files)),
-						})
+						sendBatchNotification(app, b.ID, "FidruaWatch - 上传完成", fmt.Sprintf("批次完成: %s (%s)\n校验码: %s", displayFolder(b.Folder), fileCountText(b), b.CheckCode))
 					}
 					// Play completion sound
 					playSound(SoundTypeComplete)
 				}
 			}
+			evictBatches(time.Now())
+			prunePendingUploaders(time.Now())
+			batchesMu.Unlock()
+			updateUI()
+		}
+	}
+}
+
+// startPostProcessing starts the checks and actions for a completed batch.
+// Scripts, uploads and checks only read the files and run side by side.
+// Packing and offloading copy the files one after the other once they are
+// all done; then the originals are deleted, if enabled and every copy
+// succeeded, and the card is ejected. The batch's PostProc is set until
+// all of it has finished. Caller must hold batchesMu.
+func startPostProcessing(ctx context.Context, b *Batch, updateUI func(), app fyne.App) {
+	b.PostProc = true
+	var readers sync.WaitGroup
+	read := func(f func()) {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			f()
+		}()
+	}
+	read(func() {
+		runBatchScripts(ctx, b)
+		uploadPlugins(ctx, b)
+	})
+	// The rest needs the files, which only exist locally for folder watches
+	if isRemoteWatchPath(b.Folder) {
+		go func() {
+			readers.Wait()
+			batchesMu.Lock()
+			b.PostProc = false
 			batchesMu.Unlock()
+		}()
+		return
+	}
+	if config.ProbeVideo {
+		read(func() { probeBatchVideos(ctx, b, updateUI) })
+	}
+	read(func() { readBatchExif(b, updateUI) })
+	if ecoSkipHashing() && (config.VerifyArchives || config.VerifyChecksums) {
+		logEvent("节能模式: 批次 %s 跳过校验", b.ID)
+	} else {
+		if config.ArchiveEnabled && config.VerifyArchives {
+			read(func() { verifyBatchArchives(ctx, b, updateUI) })
+		}
+		if config.VerifyChecksums {
+			read(func() { verifyBatchChecksums(ctx, b, updateUI) })
+		}
+	}
+	pack, ingest := config.PackEnabled, ingestEnabled()
+	deleteOrig := pack && config.PackDeleteOrig || ingest && config.IngestClearCard
+	eject := ingest && config.IngestEject
+	go func() {
+		readers.Wait()
+		// A batch requeued meanwhile is handled when it completes again
+		reopened := func() bool {
+			batchesMu.RLock()
+			defer batchesMu.RUnlock()
+			return b.Status == "uploading"
+		}
+		batchesMu.RLock()
+		folder, files := b.Folder, append([]string(nil), b.Files...)
+		batchesMu.RUnlock()
+
+		// A copy that fails keeps the originals for the other one
+		copied := !reopened()
+		if pack && copied {
+			copied = packBatch(b, updateUI, app)
+		}
+		if ingest && copied {
+			copied = offloadBatch(ctx, b, updateUI, app)
+		}
+		if copied && deleteOrig && !reopened() {
+			removeOriginals(folder, files)
+		}
+
+		batchesMu.Lock()
+		b.PostProc = false
+		batchesMu.Unlock()
+		if copied && eject {
+			ejectIdleCard(b)
+		}
+		updateUI()
+	}()
+}
+
+// requestRescan asks reconcileBatches to rescan active batches as soon as possible
+func requestRescan() {
+	select {
+	case rescanChan <- struct{}{}:
+	default:
+	}
+}
+
+// reconcileBatches periodically rescans the folders of uploading batches and
+// ingests files whose events fsnotify dropped (e.g. on queue overflow)
+func reconcileBatches(ctx context.Context, updateUI func()) {
+	for {
+		interval := config.RescanInterval
+		if interval <= 0 {
+			interval = 60 // disabled: only react to explicit rescan requests
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-rescanChan:
+		case <-time.After(ecoInterval(time.Duration(interval) * time.Second)):
+			if config.RescanInterval <= 0 {
+				continue
+			}
+		}
+
+		if reconcileActiveBatches() > 0 {
 			updateUI()
 		}
 	}
 }
 
+// reconcileActiveBatches compares the contents of every uploading batch's
+// folder against the tracked files and ingests anything missed or grown.
+// It returns the number of files ingested.
+func reconcileActiveBatches() int {
+	// Snapshot active batches so the scan itself runs without holding the lock
+	type snapshot struct {
+		folder string
+		sizes  map[string]int64
+	}
+	batchesMu.RLock()
+	var active []snapshot
+	for _, b := range batches {
+		if b.Status != "uploading" {
+			continue
+		}
+		sizes := make(map[string]int64, len(b.FileSizes))
+		for _, f := range b.Files {
+			sizes[pathKey(f)] = b.FileSizes[f]
+		}
+		active = append(active, snapshot{folder: b.Folder, sizes: sizes})
+	}
+	batchesMu.RUnlock()
+
+	// Grouped batches span subfolders; otherwise subfolders are batches of their own
+	recursive := config.GroupDepth > 0 && config.MonitorSubdirs
+
+	ingested := 0
+	for _, snap := range active {
+		filepath.Walk(snap.folder, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return nil
+			}
+			if info.IsDir() {
+				if p != snap.folder && !recursive {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !isMonitoredFile(p) {
+				return nil
+			}
+			rel, err := filepath.Rel(snap.folder, p)
+			if err != nil {
+				return nil
+			}
+			size, known := snap.sizes[pathKey(rel)]
+			if known && info.Size() == size {
+				return nil
+			}
+			if !known && info.Size() == 0 && config.ZeroByteMode == zeroByteIgnore {
+				return nil
+			}
+			addFileToBatch(p)
+			ingested++
+			return nil
+		})
+	}
+	return ingested
+}
+
 // remindUnsignedBatches periodically reminds user about unsigned completed batches
 func remindUnsignedBatches(ctx context.Context, app fyne.App) {
 	// Wait a bit before first check to avoid immediate reminder after completion
@@ -1355,17 +4153,14 @@ func remindUnsignedBatches(ctx context.Context, app fyne.App) {
 			batchesMu.Lock()
 			unsignedCount := 0
 			for _, b := range batches {
-				if b.Status == "completed" {
+				if b.Status == "completed" || b.Status == statusReview {
 					unsignedCount++
 				}
 			}
 			batchesMu.Unlock()
 			
 			if unsignedCount > 0 {
-				app.SendNotification(&fyne.Notification{
-					Title:   "FidruaWatch - 待签名提醒",
-					Content: fmt.Sprintf("有 %d 个批次等待签名确认", unsignedCount),
-				})
+				sendNotification(app, "FidruaWatch - 待签名提醒", fmt.Sprintf("有 %d 个批次等待签名确认", unsignedCount))
 				playSound(SoundTypeComplete) // Use complete sound for reminder
 			}
 		}
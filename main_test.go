@@ -4,11 +4,16 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/storage"
 )
 
 func TestFormatSize(t *testing.T) {
+	useLocale(t, "zh-CN")
 	tests := []struct {
 		input    int64
 		expected string
@@ -323,3 +328,230 @@ func TestAutoStartFunctions(t *testing.T) {
 		t.Logf("Executable path: %s", path)
 	}
 }
+
+func TestGroupFolder(t *testing.T) {
+	root := filepath.Join("/watch", "root")
+	tests := []struct {
+		dir      string
+		depth    int
+		expected string
+	}{
+		{filepath.Join(root, "Project", "Day1", "CamA"), 0, filepath.Join(root, "Project", "Day1", "CamA")},
+		{filepath.Join(root, "Project", "Day1", "CamA"), 1, filepath.Join(root, "Project")},
+		{filepath.Join(root, "Project", "Day1", "CamB"), 2, filepath.Join(root, "Project", "Day1")},
+		{filepath.Join(root, "Project"), 2, filepath.Join(root, "Project")},
+		{root, 1, root},
+		{filepath.Join("/elsewhere", "dir"), 1, filepath.Join("/elsewhere", "dir")},
+	}
+	for _, tt := range tests {
+		result := groupFolder(root, tt.dir, tt.depth)
+		if result != tt.expected {
+			t.Errorf("groupFolder(%s, %d) = %s, want %s", tt.dir, tt.depth, result, tt.expected)
+		}
+	}
+}
+
+func TestBatchGroupingByDepth(t *testing.T) {
+	tmpDir := t.TempDir()
+	camA := filepath.Join(tmpDir, "Project", "Day1", "CamA")
+	camB := filepath.Join(tmpDir, "Project", "Day1", "CamB")
+	os.MkdirAll(camA, 0755)
+	os.MkdirAll(camB, 0755)
+	os.WriteFile(filepath.Join(camA, "a.mp4"), []byte("aaaa"), 0644)
+	os.WriteFile(filepath.Join(camB, "b.mp4"), []byte("bb"), 0644)
+
+	origConfig := config
+	origBatches := batches
+	origMonitorPath := monitorPath
+	defer func() {
+		config = origConfig
+		batches = origBatches
+		monitorPath = origMonitorPath
+	}()
+
+	config = Config{VideoEnabled: true, GroupDepth: 1}
+	batches = make(map[string]*Batch)
+	monitorPath = tmpDir
+
+	addFileToBatch(filepath.Join(camA, "a.mp4"))
+	if isNew := addFileToBatch(filepath.Join(camB, "b.mp4")); isNew {
+		t.Error("Expected CamB file to join the Project batch")
+	}
+	if len(batches) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(batches))
+	}
+	for _, b := range batches {
+		if displayFolder(b.Folder) != "Project" {
+			t.Errorf("displayFolder = %s, want Project", displayFolder(b.Folder))
+		}
+		stats := subfolderBreakdown(b)
+		if len(stats) != 2 {
+			t.Fatalf("Expected 2 subfolders, got %d", len(stats))
+		}
+		if stats[0].Path != "Day1/CamA" || stats[0].Size != 4 {
+			t.Errorf("Unexpected first subfolder: %+v", stats[0])
+		}
+		if stats[1].Path != "Day1/CamB" || stats[1].Size != 2 {
+			t.Errorf("Unexpected second subfolder: %+v", stats[1])
+		}
+	}
+}
+
+func TestReconcileActiveBatches(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "first.mp4"), []byte("1"), 0644)
+
+	origConfig := config
+	origBatches := batches
+	defer func() {
+		config = origConfig
+		batches = origBatches
+	}()
+
+	config = Config{VideoEnabled: true}
+	batches = make(map[string]*Batch)
+
+	addFileToBatch(filepath.Join(tmpDir, "first.mp4"))
+
+	// Simulate files whose events were dropped
+	os.WriteFile(filepath.Join(tmpDir, "missed.mp4"), []byte("missed"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "first.mp4"), []byte("grown"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "ignored.txt"), []byte("x"), 0644)
+
+	if n := reconcileActiveBatches(); n != 2 {
+		t.Errorf("reconcileActiveBatches() = %d, want 2", n)
+	}
+	for _, b := range batches {
+		if len(b.Files) != 2 {
+			t.Errorf("Expected 2 files after rescan, got %d", len(b.Files))
+		}
+		if b.TotalSize != int64(len("grown")+len("missed")) {
+			t.Errorf("TotalSize = %d, want %d", b.TotalSize, len("grown")+len("missed"))
+		}
+	}
+
+	if n := reconcileActiveBatches(); n != 0 {
+		t.Errorf("Second rescan ingested %d files, want 0", n)
+	}
+}
+
+func TestDetectUploader(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"/up/.clip.mp4.a1B2c3", "rsync"},
+		{"/up/clip.mp4.crdownload", "Chrome 浏览器"},
+		{"/up/clip.mp4.part", "Firefox 浏览器"},
+		{"/up/clip.mp4.filepart", "WinSCP"},
+		{"/up/.pureftpd-upload.5f3a.clip.mp4", "Pure-FTPd"},
+		{"/up/.in.clip.mp4.", "ProFTPD"},
+		{"/up/clip.mp4.partial", "rclone"},
+		{"/up/._clip.mp4", "macOS Finder (SMB/AFP)"},
+		{"/up/clip.mp4", ""},
+	}
+	for _, tt := range tests {
+		result := detectUploader(tt.path)
+		if result != tt.expected {
+			t.Errorf("detectUploader(%s) = %q, want %q", tt.path, result, tt.expected)
+		}
+	}
+}
+
+func TestPendingUploadersExpire(t *testing.T) {
+	origBatches, origPending, origMonitorPath := batches, pendingUploaders, monitorPath
+	defer func() { batches, pendingUploaders, monitorPath = origBatches, origPending, origMonitorPath }()
+	batches = make(map[string]*Batch)
+	pendingUploaders = make(map[string]pendingUploader)
+	monitorPath = "/up"
+
+	noteUploader("/up/a/clip.mp4.part", "Firefox 浏览器")
+	noteUploader("/up/b/clip.mp4.filepart", "WinSCP")
+	pendingUploaders[pathKey("/up/a")] = pendingUploader{Tool: "Firefox 浏览器", Seen: time.Now().Add(-pendingUploaderTTL - time.Second)}
+
+	prunePendingUploaders(time.Now())
+	if _, ok := pendingUploaders[pathKey("/up/a")]; ok {
+		t.Error("Expired tool kept for a folder that never got a batch")
+	}
+	if p := pendingUploaders[pathKey("/up/b")]; p.Tool != "WinSCP" {
+		t.Errorf("Recent tool dropped: %+v", p)
+	}
+}
+
+func TestUnicodeNormalizedBatching(t *testing.T) {
+	tmpDir := t.TempDir()
+	nfc := "café.mp4"  // é as one code point
+	nfd := "café.mp4" // e + combining acute, as written by macOS
+	os.WriteFile(filepath.Join(tmpDir, nfd), []byte("data"), 0644)
+
+	origConfig := config
+	origBatches := batches
+	defer func() {
+		config = origConfig
+		batches = origBatches
+	}()
+
+	config = Config{VideoEnabled: true}
+	batches = make(map[string]*Batch)
+
+	addFileToBatch(filepath.Join(tmpDir, nfd))
+	addFileToBatch(filepath.Join(tmpDir, nfc))
+	for _, b := range batches {
+		if len(b.Files) != 1 {
+			t.Errorf("NFC and NFD names counted as %d files, want 1", len(b.Files))
+		}
+	}
+
+	config.CaseInsensitive = true
+	if pathKey("/Up/Clip.MP4") != pathKey("/up/clip.mp4") {
+		t.Error("Expected case-insensitive keys to match")
+	}
+	config.CaseInsensitive = false
+	if runtime.GOOS != "windows" && pathKey("/Up/Clip.MP4") == pathKey("/up/clip.mp4") {
+		t.Error("Expected case-sensitive keys to differ")
+	}
+}
+
+func TestDeleteAndRequeueBatch(t *testing.T) {
+	origBatches, origTrash := batches, trashPath
+	defer func() { batches, trashPath = origBatches, origTrash }()
+	trashPath = filepath.Join(t.TempDir(), "trash.json")
+
+	batches = map[string]*Batch{
+		"a": {ID: "a", Folder: "/up/a", Status: "signed"},
+		"b": {ID: "b", Folder: "/up/b", Status: "completed"},
+	}
+
+	requeueBatch(batches["a"])
+	if batches["a"].Status != "uploading" {
+		t.Errorf("Requeued status = %s, want uploading", batches["a"].Status)
+	}
+	if time.Since(batches["a"].LastTime) > time.Second {
+		t.Error("Requeue should reset LastTime")
+	}
+
+	deleteBatch("b")
+	if _, ok := batches["b"]; ok {
+		t.Error("Batch b should have been deleted")
+	}
+	if len(batches) != 1 {
+		t.Errorf("Expected 1 batch left, got %d", len(batches))
+	}
+}
+
+func TestDroppedFolder(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "clip.mp4")
+	os.WriteFile(file, []byte("x"), 0644)
+
+	if got, ok := droppedFolder([]fyne.URI{storage.NewFileURI(dir)}); !ok || got != dir {
+		t.Errorf("folder drop = %q, %v; want %q", got, ok, dir)
+	}
+	if got, ok := droppedFolder([]fyne.URI{storage.NewFileURI(file)}); !ok || got != dir {
+		t.Errorf("file drop = %q, %v; want its folder %q", got, ok, dir)
+	}
+	missing := storage.NewFileURI(filepath.Join(dir, "missing"))
+	if _, ok := droppedFolder([]fyne.URI{missing}); ok {
+		t.Error("missing path accepted")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Manifest lists the files a delivery is expected to contain. A batch
+// pointed at one only completes once every listed file arrived in full.
+type Manifest struct {
+	Path    string          `json:"path"` // file the manifest was read from
+	Entries []ManifestEntry `json:"entries"`
+}
+
+// ManifestEntry is one expected file, relative to the batch folder
+type ManifestEntry struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"` // -1 = any size
+}
+
+// ManifestDiff is how a batch differs from its manifest
+type ManifestDiff struct {
+	Missing    []string
+	Extra      []string
+	Mismatched []string // present with a different size
+}
+
+// Satisfied reports whether every expected file is there with its size
+func (d ManifestDiff) Satisfied() bool {
+	return len(d.Missing) == 0 && len(d.Mismatched) == 0
+}
+
+// loadManifest reads a CSV or JSON manifest. CSV rows are name[,size] with
+// an optional header; JSON is a list of {"name", "size"} objects, either
+// bare or under "files".
+func loadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []ManifestEntry
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		entries, err = parseManifestJSON(f)
+	} else {
+		entries, err = parseManifestCSV(f)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filepath.Base(path), err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%s: 清单为空", filepath.Base(path))
+	}
+	return &Manifest{Path: path, Entries: entries}, nil
+}
+
+func parseManifestCSV(r io.Reader) ([]ManifestEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	rows, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var entries []ManifestEntry
+	for i, row := range rows {
+		if len(row) == 0 || strings.TrimSpace(row[0]) == "" {
+			continue
+		}
+		e := ManifestEntry{Name: strings.TrimSpace(row[0]), Size: -1}
+		if len(row) > 1 && strings.TrimSpace(row[1]) != "" {
+			size, err := strconv.ParseInt(strings.TrimSpace(row[1]), 10, 64)
+			if err != nil {
+				if i == 0 {
+					continue // header
+				}
+				return nil, fmt.Errorf("第 %d 行: 大小无效: %q", i+1, row[1])
+			}
+			e.Size = size
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func parseManifestJSON(r io.Reader) ([]ManifestEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	type entry struct {
+		Name string `json:"name"`
+		Size *int64 `json:"size"`
+	}
+	var list []entry
+	if err := json.Unmarshal(data, &list); err != nil {
+		var wrapped struct {
+			Files []entry `json:"files"`
+		}
+		if json.Unmarshal(data, &wrapped) != nil {
+			return nil, errors.New("无法解析 JSON 清单")
+		}
+		list = wrapped.Files
+	}
+	entries := make([]ManifestEntry, 0, len(list))
+	for _, e := range list {
+		if e.Name == "" {
+			continue
+		}
+		size := int64(-1)
+		if e.Size != nil {
+			size = *e.Size
+		}
+		entries = append(entries, ManifestEntry{Name: e.Name, Size: size})
+	}
+	return entries, nil
+}
+
+// compareManifest checks the files of a batch against its manifest. Names
+// are compared like batch paths, see pathKey. The manifest file itself is
+// not counted as extra. Caller must hold batchesMu.
+func compareManifest(b *Batch) ManifestDiff {
+	var d ManifestDiff
+	if b.Manifest == nil {
+		return d
+	}
+	have := make(map[string]string, len(b.Files))
+	for _, f := range b.Files {
+		have[pathKey(filepath.ToSlash(f))] = f
+	}
+	expected := make(map[string]bool, len(b.Manifest.Entries))
+	for _, e := range b.Manifest.Entries {
+		key := pathKey(filepath.ToSlash(e.Name))
+		expected[key] = true
+		f, ok := have[key]
+		switch {
+		case !ok:
+			d.Missing = append(d.Missing, e.Name)
+		case e.Size >= 0 && b.FileSizes[f] != e.Size:
+			d.Mismatched = append(d.Mismatched, e.Name)
+		}
+	}
+	self := ""
+	if rel, err := filepath.Rel(b.Folder, b.Manifest.Path); err == nil {
+		self = pathKey(filepath.ToSlash(rel))
+	}
+	for key, f := range have {
+		if !expected[key] && key != self {
+			d.Extra = append(d.Extra, f)
+		}
+	}
+	sort.Strings(d.Missing)
+	sort.Strings(d.Extra)
+	sort.Strings(d.Mismatched)
+	return d
+}
+
+// holdForManifest holds completion until the manifest is satisfied.
+// Caller must hold batchesMu.
+func holdForManifest(b *Batch) bool {
+	return b.Manifest != nil && !compareManifest(b).Satisfied()
+}
+
+// manifestSummary is the card line for a batch with a manifest
+func manifestSummary(d ManifestDiff) string {
+	if d.Satisfied() && len(d.Extra) == 0 {
+		return "✅ 与清单一致"
+	}
+	var parts []string
+	if n := len(d.Missing); n > 0 {
+		parts = append(parts, fmt.Sprintf("缺少 %d", n))
+	}
+	if n := len(d.Mismatched); n > 0 {
+		parts = append(parts, fmt.Sprintf("大小不符 %d", n))
+	}
+	if n := len(d.Extra); n > 0 {
+		parts = append(parts, fmt.Sprintf("多余 %d", n))
+	}
+	return "📋 清单: " + strings.Join(parts, " · ")
+}
+
+// attachManifest points a batch at a manifest. A finished but unsigned
+// batch that doesn't satisfy it goes back to uploading until it does.
+func attachManifest(b *Batch, m *Manifest) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	b.Manifest = m
+	d := compareManifest(b)
+	logEvent("批次 %s 对照清单 %s: %d 个文件, %s", b.ID, filepath.Base(m.Path), len(m.Entries), manifestSummary(d))
+	if (b.Status == "completed" || b.Status == statusReview) && !d.Satisfied() {
+		resumeUploading(b, time.Now())
+		publishBatchEvent(eventRequeued, b)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	entries, err := parseManifestCSV(strings.NewReader("name,size\na.mp4,100\nsub/b.jpg\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0] != (ManifestEntry{"a.mp4", 100}) || entries[1] != (ManifestEntry{"sub/b.jpg", -1}) {
+		t.Errorf("CSV entries = %+v", entries)
+	}
+	if _, err := parseManifestCSV(strings.NewReader("a.mp4,100\nb.mp4,big\n")); err == nil {
+		t.Error("Expected an error for an invalid size")
+	}
+
+	entries, err = parseManifestJSON(strings.NewReader(`{"files": [{"name": "a.mp4", "size": 0}, {"name": "b.mp4"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Size != 0 || entries[1].Size != -1 {
+		t.Errorf("JSON entries = %+v", entries)
+	}
+	if _, err := parseManifestJSON(strings.NewReader(`[{"name": "a.mp4"}]`)); err != nil {
+		t.Errorf("Bare JSON list rejected: %v", err)
+	}
+}
+
+func TestCompareManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	manifestPath := filepath.Join(tmpDir, "list.csv")
+	os.WriteFile(manifestPath, []byte("a.mp4,100\nb.mp4,50\nc.mp4\n"), 0644)
+	m, err := loadManifest(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := &Batch{
+		ID:        "test",
+		Folder:    tmpDir,
+		Files:     []string{"a.mp4", "b.mp4", "d.mp4", "list.csv"},
+		FileSizes: map[string]int64{"a.mp4": 100, "b.mp4": 20, "d.mp4": 1, "list.csv": 30},
+	}
+	if holdForManifest(b) {
+		t.Error("Expected no hold without a manifest")
+	}
+	b.Manifest = m
+	d := compareManifest(b)
+	if strings.Join(d.Missing, ",") != "c.mp4" || strings.Join(d.Mismatched, ",") != "b.mp4" || strings.Join(d.Extra, ",") != "d.mp4" {
+		t.Errorf("Diff = %+v", d)
+	}
+	if !holdForManifest(b) {
+		t.Error("Expected completion to be held while files are missing")
+	}
+
+	b.Files = append(b.Files, "c.mp4")
+	b.FileSizes["b.mp4"] = 50
+	if holdForManifest(b) {
+		t.Errorf("Expected the manifest to be satisfied, diff = %+v", compareManifest(b))
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VideoInfo is the ffprobe metadata of one video file
+type VideoInfo struct {
+	Duration float64 `json:"duration"` // seconds
+	Codec    string  `json:"codec"`
+	Width    int     `json:"width"`
+	Height   int     `json:"height"`
+}
+
+// String formats the info for the detail view, e.g. "01:02:03 · h264 · 1920x1080"
+func (v VideoInfo) String() string {
+	parts := []string{formatDuration(v.Duration)}
+	if v.Codec != "" {
+		parts = append(parts, v.Codec)
+	}
+	if v.Width > 0 && v.Height > 0 {
+		parts = append(parts, fmt.Sprintf("%dx%d", v.Width, v.Height))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// formatDuration formats seconds as HH:MM:SS
+func formatDuration(seconds float64) string {
+	s := int(seconds + 0.5)
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
+
+// hasExt reports whether name has one of exts (lower-case, with dot)
+func hasExt(name string, exts []string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, e := range exts {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// parseFFprobe reads the output of
+// ffprobe -print_format json -show_format -show_streams
+func parseFFprobe(data []byte) (VideoInfo, error) {
+	var out struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+		Streams []struct {
+			CodecType string `json:"codec_type"`
+			CodecName string `json:"codec_name"`
+			Width     int    `json:"width"`
+			Height    int    `json:"height"`
+		} `json:"streams"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return VideoInfo{}, err
+	}
+	var info VideoInfo
+	info.Duration, _ = strconv.ParseFloat(out.Format.Duration, 64)
+	for _, s := range out.Streams {
+		if s.CodecType == "video" {
+			info.Codec, info.Width, info.Height = s.CodecName, s.Width, s.Height
+			break
+		}
+	}
+	return info, nil
+}
+
+// probeVideo runs ffprobe on one file
+func probeVideo(ctx context.Context, ffprobe, path string) (VideoInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, ffprobe,
+		"-v", "error", "-print_format", "json", "-show_format", "-show_streams", path).Output()
+	if err != nil {
+		return VideoInfo{}, err
+	}
+	return parseFFprobe(out)
+}
+
+// probeBatchVideos collects metadata for the videos of a completed batch and
+// stores it on the batch and in history. Without ffprobe on PATH it only logs.
+func probeBatchVideos(ctx context.Context, b *Batch, updateUI func()) {
+	ffprobe, err := exec.LookPath("ffprobe")
+	if err != nil {
+		logEvent("未找到 ffprobe, 跳过视频信息读取")
+		return
+	}
+
+	batchesMu.RLock()
+	folder := b.Folder
+	var videos []string
+	for _, f := range b.Files {
+		if hasExt(f, videoExts) {
+			videos = append(videos, f)
+		}
+	}
+	batchesMu.RUnlock()
+	if len(videos) == 0 {
+		return
+	}
+
+	media := make(map[string]VideoInfo, len(videos))
+	for _, f := range videos {
+		info, err := probeVideo(ctx, ffprobe, filepath.Join(folder, f))
+		if err != nil {
+			logEvent("ffprobe %s 失败: %v", f, err)
+			continue
+		}
+		media[f] = info
+	}
+
+	batchesMu.Lock()
+	b.Media = media
+	if b.Status != "uploading" {
+		recordHistory(b)
+	}
+	batchesMu.Unlock()
+	updateUI()
+}
@@ -0,0 +1,37 @@
+package main
+
+import "testing"
+
+func TestParseFFprobe(t *testing.T) {
+	out := []byte(`{
+		"streams": [
+			{"codec_type": "audio", "codec_name": "aac"},
+			{"codec_type": "video", "codec_name": "h264", "width": 1920, "height": 1080}
+		],
+		"format": {"duration": "3723.4"}
+	}`)
+	info, err := parseFFprobe(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VideoInfo{Duration: 3723.4, Codec: "h264", Width: 1920, Height: 1080}
+	if info != want {
+		t.Errorf("parseFFprobe = %+v, want %+v", info, want)
+	}
+	if got := info.String(); got != "01:02:03 · h264 · 1920x1080" {
+		t.Errorf("String() = %q", got)
+	}
+
+	if _, err := parseFFprobe([]byte("not json")); err == nil {
+		t.Error("expected an error for invalid output")
+	}
+}
+
+func TestHasExt(t *testing.T) {
+	if !hasExt("sub/CLIP.MP4", videoExts) {
+		t.Error("upper-case extension not matched")
+	}
+	if hasExt("photo.jpg", videoExts) {
+		t.Error("image matched as video")
+	}
+}
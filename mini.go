@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/driver/desktop"
+	"fyne.io/fyne/v2/widget"
+)
+
+// rateWindow is the span throughput is averaged over
+const rateWindow = 10 * time.Second
+
+// totalThroughput sums the recent growth rate of uploading batches in
+// bytes per second. Caller must hold batchesMu.
+func totalThroughput(now time.Time) float64 {
+	var total float64
+	for _, b := range batches {
+		if b.Status == "uploading" {
+			total += b.Samples.Rate(rateWindow, now)
+		}
+	}
+	return total
+}
+
+// formatRate formats bytes per second, e.g. "12.5 MB/s"
+func formatRate(bps float64) string {
+	return formatSize(int64(bps)) + "/s"
+}
+
+// miniStatusText is the line shown in the mini window
+func miniStatusText(uploading int, bps float64) string {
+	if uploading == 0 {
+		return "⏸ 无上传"
+	}
+	return fmt.Sprintf("⏫ %d 个批次 · %s", uploading, formatRate(bps))
+}
+
+// miniWidget is a small borderless window with the active batch count,
+// throughput and a stop button, for keeping an eye on uploads while another
+// app is full-screen. Fyne can't keep windows on top, so it relies on the
+// window manager's placement.
+type miniWidget struct {
+	win    fyne.Window
+	status *widget.Label
+	stop   *widget.Button
+
+	OnClose func() // called when closed from its own ✕ button
+}
+
+// newMiniWidget creates the (hidden) mini window. onStop stops monitoring.
+func newMiniWidget(a fyne.App, onStop func()) *miniWidget {
+	m := &miniWidget{}
+	if drv, ok := a.Driver().(desktop.Driver); ok {
+		m.win = drv.CreateSplashWindow()
+	} else {
+		m.win = a.NewWindow("FidruaWatch")
+	}
+	m.status = widget.NewLabel(miniStatusText(0, 0))
+	m.stop = widget.NewButton("⏹", onStop)
+	m.stop.Importance = widget.DangerImportance
+	m.stop.Disable()
+	closeBtn := widget.NewButton("✕", func() {
+		config.MiniWidget = false
+		m.win.Hide()
+		if m.OnClose != nil {
+			m.OnClose()
+		}
+	})
+	closeBtn.Importance = widget.LowImportance
+	m.win.SetContent(container.NewBorder(nil, nil, nil, container.NewHBox(m.stop, closeBtn), m.status))
+	m.win.Resize(fyne.NewSize(260, 40))
+	return m
+}
+
+// SetVisible shows or hides the mini window
+func (m *miniWidget) SetVisible(show bool) {
+	if show {
+		m.win.Show()
+	} else {
+		m.win.Hide()
+	}
+}
+
+// Update refreshes the counters; the stop button is enabled while monitoring
+func (m *miniWidget) Update(uploading int, bps float64, monitoring bool) {
+	m.status.SetText(miniStatusText(uploading, bps))
+	if monitoring {
+		m.stop.Enable()
+	} else {
+		m.stop.Disable()
+	}
+}
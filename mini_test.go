@@ -0,0 +1,13 @@
+package main
+
+import "testing"
+
+func TestMiniStatusText(t *testing.T) {
+	useLocale(t, "zh-CN")
+	if got := miniStatusText(0, 0); got != "⏸ 无上传" {
+		t.Errorf("idle text = %q", got)
+	}
+	if got := miniStatusText(2, 1536); got != "⏫ 2 个批次 · 1.5 KB/s" {
+		t.Errorf("active text = %q", got)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// muteState silences sounds and desktop popups for a while without
+// changing the settings. Notifications still go to the log and plugins.
+type muteState struct {
+	mu    sync.Mutex
+	on    bool
+	until time.Time // zero while muted until turned off
+	timer *time.Timer
+}
+
+var mute muteState
+
+// Set mutes for d, or until Clear when d is 0, and calls expired when d
+// has passed
+func (m *muteState) Set(d time.Duration, now time.Time, expired func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.on, m.until = true, time.Time{}
+	if d > 0 {
+		until := now.Add(d)
+		m.until = until
+		m.timer = time.AfterFunc(d, func() {
+			// A later Set may have replaced this mute meanwhile
+			m.mu.Lock()
+			current := m.on && m.until.Equal(until)
+			if current {
+				m.on, m.until, m.timer = false, time.Time{}, nil
+			}
+			m.mu.Unlock()
+			if current {
+				expired()
+			}
+		})
+	}
+}
+
+// Clear ends muting
+func (m *muteState) Clear() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	m.on, m.until = false, time.Time{}
+}
+
+// Active reports whether sounds and popups are muted at now, and until when
+// (zero when until turned off)
+func (m *muteState) Active(now time.Time) (bool, time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.on || !m.until.IsZero() && !now.Before(m.until) {
+		return false, time.Time{}
+	}
+	return true, m.until
+}
+
+// muteButtonText is the label of the mute toggle for the current state
+func muteButtonText(on bool, until time.Time) string {
+	if !on {
+		return "🔇 静音"
+	}
+	if until.IsZero() {
+		return "🔔 取消静音"
+	}
+	return "🔔 取消静音 (" + formatClock(until, false) + " 自动恢复)"
+}
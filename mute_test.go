@@ -0,0 +1,57 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMute(t *testing.T) {
+	var m muteState
+	now := time.Now()
+	if on, _ := m.Active(now); on {
+		t.Fatal("Muted from the start")
+	}
+
+	expired := make(chan struct{})
+	m.Set(20*time.Millisecond, now, func() { close(expired) })
+	if on, until := m.Active(now); !on || !until.Equal(now.Add(20*time.Millisecond)) {
+		t.Errorf("Active = %v, %v", on, until)
+	}
+	if on, _ := m.Active(now.Add(time.Second)); on {
+		t.Error("Still muted after the duration")
+	}
+	select {
+	case <-expired:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expiry not reported")
+	}
+	if on, _ := m.Active(now); on {
+		t.Error("Muted after expiring")
+	}
+
+	// Without a duration it lasts until cleared, and clearing stops the timer
+	m.Set(0, now, func() { t.Error("Unlimited mute expired") })
+	if on, until := m.Active(now.Add(24 * time.Hour)); !on || !until.IsZero() {
+		t.Errorf("Unlimited mute: %v, %v", on, until)
+	}
+	m.Set(10*time.Millisecond, now, func() { t.Error("Cleared mute expired") })
+	m.Clear()
+	time.Sleep(30 * time.Millisecond)
+	if on, _ := m.Active(now); on {
+		t.Error("Muted after Clear")
+	}
+}
+
+func TestMuteButtonText(t *testing.T) {
+	if got := muteButtonText(false, time.Time{}); got != "🔇 静音" {
+		t.Errorf("unmuted: %q", got)
+	}
+	if got := muteButtonText(true, time.Time{}); strings.Contains(got, "自动恢复") {
+		t.Errorf("unlimited mute shows an end: %q", got)
+	}
+	until := time.Date(2024, 3, 1, 15, 30, 0, 0, time.Local)
+	if got := muteButtonText(true, until); !strings.Contains(got, formatClock(until, false)) {
+		t.Errorf("timed mute lacks its end: %q", got)
+	}
+}
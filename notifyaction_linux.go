@@ -0,0 +1,84 @@
+//go:build linux
+
+package main
+
+import (
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// freedesktop notification service, which reports clicks as ActionInvoked
+const (
+	notifyService   = "org.freedesktop.Notifications"
+	notifyPath      = "/org/freedesktop/Notifications"
+	notifyInterface = "org.freedesktop.Notifications"
+)
+
+// notifyActions tracks the batch of each notification still on screen
+var notifyActions struct {
+	once    sync.Once
+	conn    *dbus.Conn
+	mu      sync.Mutex
+	pending map[uint32]string // notification ID → batch ID
+}
+
+// notifyWithAction shows a notification whose click shows the batch. It
+// returns false when the notification service can't be reached.
+func notifyWithAction(title, content, batchID string) bool {
+	n := &notifyActions
+	n.once.Do(func() {
+		conn, err := dbus.ConnectSessionBus()
+		if err != nil {
+			return
+		}
+		for _, member := range []string{"ActionInvoked", "NotificationClosed"} {
+			if err := conn.AddMatchSignal(dbus.WithMatchInterface(notifyInterface), dbus.WithMatchMember(member)); err != nil {
+				conn.Close()
+				return
+			}
+		}
+		signals := make(chan *dbus.Signal, 16)
+		conn.Signal(signals)
+		n.conn = conn
+		n.pending = make(map[uint32]string)
+		go listenNotifyActions(signals)
+	})
+	if n.conn == nil {
+		return false
+	}
+	var id uint32
+	err := n.conn.Object(notifyService, notifyPath).Call(notifyInterface+".Notify", 0,
+		"FidruaWatch", uint32(0), "", title, content,
+		[]string{"default", "查看批次"}, map[string]dbus.Variant{}, int32(-1)).Store(&id)
+	if err != nil {
+		logEvent("发送通知失败: %v", err)
+		return false
+	}
+	n.mu.Lock()
+	n.pending[id] = batchID
+	n.mu.Unlock()
+	return true
+}
+
+// listenNotifyActions shows the batch of a clicked notification and
+// forgets closed ones
+func listenNotifyActions(signals <-chan *dbus.Signal) {
+	n := &notifyActions
+	for sig := range signals {
+		if len(sig.Body) < 2 {
+			continue
+		}
+		id, ok := sig.Body[0].(uint32)
+		if !ok {
+			continue
+		}
+		n.mu.Lock()
+		batchID, known := n.pending[id]
+		delete(n.pending, id)
+		n.mu.Unlock()
+		if known && sig.Name == notifyInterface+".ActionInvoked" && notificationClicked != nil {
+			notificationClicked(batchID)
+		}
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+// notifyWithAction reports that clicks on notifications can't be told
+// apart here; the foreground heuristic in notifyfocus.go stands in
+func notifyWithAction(title, content, batchID string) bool {
+	return false
+}
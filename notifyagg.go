@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// startDigestWindow is how long batch starts are collected into one
+// notification, so a folder with many subfolders doesn't raise a toast each
+const startDigestWindow = 3 * time.Second
+
+// startDigest collects new batch starts until the window passes
+type startDigest struct {
+	mu    sync.Mutex
+	count int
+	first string // file that started the first batch
+}
+
+var batchStarts startDigest
+
+// Add notes a new batch started by path, scheduling the notification on
+// the first start of a window
+func (d *startDigest) Add(app fyne.App, path string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.count++
+	if d.count > 1 {
+		return
+	}
+	d.first = path
+	time.AfterFunc(startDigestWindow, func() { d.flush(app) })
+}
+
+// flush sends the notification for the starts collected so far
+func (d *startDigest) flush(app fyne.App) {
+	d.mu.Lock()
+	count, first := d.count, d.first
+	d.count, d.first = 0, ""
+	d.mu.Unlock()
+	if count == 0 {
+		return
+	}
+	sendNotification(app, "FidruaWatch - 新上传", startDigestText(count, first))
+	playSound(SoundTypeStart)
+}
+
+// startDigestText is the notification text for count batch starts
+func startDigestText(count int, first string) string {
+	if count == 1 {
+		return fmt.Sprintf("检测到新文件: %s", filepath.Base(first))
+	}
+	return fmt.Sprintf("%d 个新上传批次已开始", count)
+}
+
+// notifyLimiter caps desktop notifications at config.NotifyPerMinute.
+// Notifications over the cap are still logged and passed to notifier
+// plugins; the next one shown says how many were held back.
+type notifyLimiter struct {
+	mu         sync.Mutex
+	sent       []time.Time // shown in the last minute, oldest first
+	suppressed int
+}
+
+var toastLimit notifyLimiter
+
+// Allow reports whether a notification may be shown now and how many were
+// suppressed since the last one shown
+func (l *notifyLimiter) Allow(now time.Time, perMinute int) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if perMinute <= 0 {
+		n := l.suppressed
+		l.sent, l.suppressed = nil, 0
+		return true, n
+	}
+	keep := 0
+	for keep < len(l.sent) && now.Sub(l.sent[keep]) >= time.Minute {
+		keep++
+	}
+	l.sent = l.sent[keep:]
+	if len(l.sent) >= perMinute {
+		l.suppressed++
+		return false, 0
+	}
+	l.sent = append(l.sent, now)
+	n := l.suppressed
+	l.suppressed = 0
+	return true, n
+}
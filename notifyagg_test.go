@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartDigestText(t *testing.T) {
+	if got := startDigestText(1, "/in/cam1/a.mp4"); got != "检测到新文件: a.mp4" {
+		t.Errorf("single start = %q", got)
+	}
+	if got := startDigestText(30, "/in/cam1/a.mp4"); got != "30 个新上传批次已开始" {
+		t.Errorf("30 starts = %q", got)
+	}
+}
+
+func TestNotifyLimiter(t *testing.T) {
+	var l notifyLimiter
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		if ok, _ := l.Allow(now.Add(time.Duration(i)*time.Second), 3); !ok {
+			t.Fatalf("notification %d held back under the cap", i)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.Allow(now.Add(10*time.Second), 3); ok {
+			t.Fatal("notification over the cap shown")
+		}
+	}
+	// The first one has left the minute
+	ok, held := l.Allow(now.Add(time.Minute), 3)
+	if !ok || held != 2 {
+		t.Errorf("after a minute: shown %v, held %d, want true, 2", ok, held)
+	}
+	if ok, held := l.Allow(now.Add(time.Minute), 0); !ok || held != 0 {
+		t.Errorf("no limit: shown %v, held %d", ok, held)
+	}
+}
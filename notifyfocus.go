@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// notifyFocusWindow is how soon after a completion notification bringing
+// the app to the foreground is taken for clicking that notification. Kept
+// short, as switching windows for any other reason looks the same.
+const notifyFocusWindow = 15 * time.Second
+
+// notifyFocus remembers the batch of the latest completion notification
+// where notifications report no clicks (see notifyWithAction). Clicking one
+// activates the app on Windows and macOS, so the next foreground event
+// soon after is treated as the click.
+type notifyFocus struct {
+	mu      sync.Mutex
+	batchID string
+	at      time.Time
+}
+
+var lastNotified notifyFocus
+
+// highlightBatchID is the batch whose card is highlighted after a
+// notification click, empty when none. Guarded by batchesMu.
+var highlightBatchID string
+
+// notificationClicked shows the batch of a clicked notification, set by
+// the window
+var notificationClicked func(batchID string)
+
+// Note records that a notification about a batch was just sent
+func (n *notifyFocus) Note(batchID string, now time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.batchID, n.at = batchID, now
+}
+
+// Take returns the batch to show when the app comes to the foreground, at
+// most once per notification
+func (n *notifyFocus) Take(now time.Time) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	id := n.batchID
+	n.batchID = ""
+	if id == "" || now.Sub(n.at) > notifyFocusWindow {
+		return "", false
+	}
+	return id, true
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNotifyFocusTake(t *testing.T) {
+	var n notifyFocus
+	now := time.Now()
+
+	if _, ok := n.Take(now); ok {
+		t.Error("Take without a notification")
+	}
+
+	n.Note("b1", now)
+	if id, ok := n.Take(now.Add(10 * time.Second)); !ok || id != "b1" {
+		t.Errorf("Take = %q, %v; want b1", id, ok)
+	}
+	if _, ok := n.Take(now.Add(11 * time.Second)); ok {
+		t.Error("notification taken twice")
+	}
+
+	n.Note("b2", now)
+	if _, ok := n.Take(now.Add(notifyFocusWindow + time.Second)); ok {
+		t.Error("stale notification treated as a click")
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// folderUnder reports whether folder is root or below it
+func folderUnder(folder, root string) bool {
+	rel, err := filepath.Rel(filepath.Clean(root), folder)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// noteRootOutage records a watched root lost or re-attached in the
+// timelines of the batches uploading below it, so the outage is kept in the
+// history. Their completion timers run again from the re-attach: nothing
+// could arrive while the root was down.
+func noteRootOutage(app fyne.App, o rootOutage) {
+	now := time.Now()
+	batchesMu.Lock()
+	affected := 0
+	for _, b := range batches {
+		if b.Status != "uploading" || !folderUnder(b.Folder, o.Root) {
+			continue
+		}
+		affected++
+		if o.Back {
+			shiftBatchTimers(b, o.Down)
+			noteTimeline(b, timelineWatchBack, "中断了 "+formatDuration(o.Down.Seconds()), now)
+		} else {
+			noteTimeline(b, timelineOutage, o.Err.Error(), now)
+		}
+	}
+	batchesMu.Unlock()
+
+	name := displayWindowsPath(o.Root)
+	if o.Back {
+		logEvent("监控文件夹已恢复 %s, 中断了 %s, %d 个上传中批次受影响", o.Root, o.Down.Round(time.Second), affected)
+		sendNotification(app, "FidruaWatch - 监控已恢复", fmt.Sprintf("%s 已重新监控, 中断了 %s", name, formatDuration(o.Down.Seconds())))
+		return
+	}
+	logEvent("监控文件夹不可用 %s: %v, %d 个上传中批次受影响", o.Root, o.Err, affected)
+	msg := fmt.Sprintf("%s: %v", name, o.Err)
+	if config.ReattachRoots {
+		msg += "\n恢复后将自动重新监控"
+	}
+	sendNotification(app, "FidruaWatch - 监控中断", msg)
+}
+
+// holdForLostRoot keeps a batch uploading while the root it is in is lost
+// and waits to be re-attached, since its files can't arrive meanwhile.
+// Caller must hold batchesMu.
+func holdForLostRoot(b *Batch) bool {
+	if !config.ReattachRoots {
+		return false
+	}
+	for _, s := range watchers.Statuses() {
+		if s.State == rootFailed && !s.Since.IsZero() && folderUnder(b.Folder, s.Root) {
+			return true
+		}
+	}
+	return false
+}
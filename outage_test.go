@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNoteRootOutage(t *testing.T) {
+	savedBatches, savedConfig := batches, config
+	defer func() { batches, config = savedBatches, savedConfig }()
+	config = Config{}
+
+	root := filepath.Join("data", "share")
+	start := time.Now().Add(-time.Minute)
+	inside := &Batch{ID: "a", Folder: filepath.Join(root, "job1"), Status: "uploading", LastTime: start}
+	outside := &Batch{ID: "b", Folder: filepath.Join("data", "shared"), Status: "uploading", LastTime: start}
+	batches = map[string]*Batch{"a": inside, "b": outside}
+
+	noteRootOutage(nil, rootOutage{Root: root, Err: errors.New("gone")})
+	noteRootOutage(nil, rootOutage{Root: root, Down: 10 * time.Minute, Back: true})
+
+	if len(inside.Timeline) != 2 || inside.Timeline[0].Kind != timelineOutage || inside.Timeline[1].Detail != "中断了 00:10:00" {
+		t.Errorf("timeline = %+v", inside.Timeline)
+	}
+	if !inside.LastTime.Equal(start.Add(10 * time.Minute)) {
+		t.Errorf("completion timer not moved past the outage: %v", inside.LastTime.Sub(start))
+	}
+	if len(outside.Timeline) != 0 || !outside.LastTime.Equal(start) {
+		t.Error("batch outside the root was changed")
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// ownOutputGrace is how long files written by FidruaWatch keep being
+// ignored after the write finished, covering late watcher events and the
+// poller
+const ownOutputGrace = 2 * time.Minute
+
+// Files FidruaWatch itself writes into the watched tree (zips, organized or
+// moved files) would otherwise come back as new batches. Keyed by pathKey;
+// a zero time means the write is still going on. Guarded by ownOutputMu.
+var (
+	ownOutputMu sync.Mutex
+	ownOutputs  = make(map[string]time.Time)
+)
+
+// claimOwnOutput marks path as written by us until the returned release is
+// called and for ownOutputGrace after that. Call it before the first write.
+func claimOwnOutput(path string) (release func()) {
+	key := pathKey(path)
+	ownOutputMu.Lock()
+	ownOutputs[key] = time.Time{}
+	ownOutputMu.Unlock()
+	return func() {
+		ownOutputMu.Lock()
+		ownOutputs[key] = time.Now().Add(ownOutputGrace)
+		ownOutputMu.Unlock()
+	}
+}
+
+// isOwnOutput reports whether path was written by FidruaWatch recently.
+// Expired entries are dropped along the way.
+func isOwnOutput(path string) bool {
+	ownOutputMu.Lock()
+	defer ownOutputMu.Unlock()
+	if len(ownOutputs) == 0 {
+		return false
+	}
+	now := time.Now()
+	for k, until := range ownOutputs {
+		if !until.IsZero() && now.After(until) {
+			delete(ownOutputs, k)
+		}
+	}
+	_, ok := ownOutputs[pathKey(path)]
+	return ok
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOwnOutputIgnored(t *testing.T) {
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{ArchiveEnabled: true}
+
+	zipPath := filepath.Join(t.TempDir(), "day1_20240301-153000.zip")
+	if !isMonitoredFile(zipPath) {
+		t.Fatal("Expected zip files to be monitored")
+	}
+	release := claimOwnOutput(zipPath)
+	if isMonitoredFile(zipPath) {
+		t.Error("Expected our own zip to be ignored while it is written")
+	}
+	release()
+	if !isOwnOutput(zipPath) {
+		t.Error("Expected our own zip to be ignored during the grace period")
+	}
+
+	ownOutputMu.Lock()
+	ownOutputs[pathKey(zipPath)] = time.Now().Add(-time.Second)
+	ownOutputMu.Unlock()
+	if isOwnOutput(zipPath) {
+		t.Error("Expected the claim to expire after the grace period")
+	}
+	if _, ok := ownOutputs[pathKey(zipPath)]; ok {
+		t.Error("Expected the expired claim to be dropped")
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// packName returns the zip name for a batch, e.g. "day1_20240301-153000.zip"
+func packName(folder string, t time.Time) string {
+	return fmt.Sprintf("%s_%s.zip", filepath.Base(folder), t.Format("20060102-150405"))
+}
+
+// progressWriter reports bytes written through it
+type progressWriter struct {
+	w        io.Writer
+	done     int64
+	onUpdate func(done int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.done += int64(n)
+	p.onUpdate(p.done)
+	return n, err
+}
+
+// zipFiles writes files (relative to folder) into a new zip at dst, skipping
+// temp files. progress receives the bytes of source data packed so far.
+func zipFiles(dst, folder string, files []string, progress func(done int64)) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(out)
+	var base int64
+	pw := &progressWriter{onUpdate: func(done int64) { progress(base + done) }}
+
+	for _, f := range files {
+		if isTempFile(f) {
+			continue
+		}
+		src := filepath.Join(folder, f)
+		info, err := os.Stat(src)
+		if err != nil {
+			zw.Close()
+			out.Close()
+			return err
+		}
+		hdr, err := zip.FileInfoHeader(info)
+		if err != nil {
+			zw.Close()
+			out.Close()
+			return err
+		}
+		hdr.Name = filepath.ToSlash(f)
+		hdr.Method = zip.Deflate
+		w, err := zw.CreateHeader(hdr)
+		if err == nil {
+			err = copyFileTo(w, src, pw)
+		}
+		if err != nil {
+			zw.Close()
+			out.Close()
+			return err
+		}
+		base += pw.done
+		pw.done = 0
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// copyFileTo copies src into w at the transfer rate, counting the bytes
+// read through pw
+func copyFileTo(w io.Writer, src string, pw *progressWriter) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	pw.w = w
+	_, err = io.Copy(pw, limitReader(context.Background(), in))
+	return err
+}
+
+// packBatch compresses a completed batch into a timestamped zip in
+// config.PackDir and verifies it, reporting whether that succeeded. The
+// batch's Packing and PackPct track progress for the card.
+func packBatch(b *Batch, updateUI func(), app fyne.App) bool {
+	batchesMu.Lock()
+	folder := b.Folder
+	files := append([]string(nil), b.Files...)
+	total := b.TotalSize
+	b.Packing, b.PackPct = true, 0
+	batchesMu.Unlock()
+	updateUI()
+
+	dest := config.PackDir
+	if dest == "" {
+		dest = filepath.Dir(folder)
+	}
+	zipPath := filepath.Join(dest, packName(folder, time.Now()))
+
+	lastPct := 0
+	setPct := func(done int64) {
+		if total <= 0 {
+			return
+		}
+		pct := int(done * 100 / total)
+		if pct >= 100 {
+			pct = 99
+		}
+		if pct < lastPct+5 {
+			return
+		}
+		lastPct = pct
+		batchesMu.Lock()
+		b.PackPct = pct
+		batchesMu.Unlock()
+		updateUI()
+	}
+
+	// The zip may land inside the watched tree
+	release := claimOwnOutput(zipPath)
+	defer release()
+	err := os.MkdirAll(dest, 0755)
+	if err == nil {
+		// The zip is usually written to another disk or a NAS
+		var done func()
+		if done, err = transferJobs.Acquire(context.Background()); err == nil {
+			err = zipFiles(zipPath, folder, files, setPct)
+			done()
+		}
+	}
+	if err == nil {
+		err = testZip(zipPath)
+	}
+
+	batchesMu.Lock()
+	b.Packing = false
+	if err != nil {
+		os.Remove(zipPath)
+		batchesMu.Unlock()
+		logEvent("打包批次 %s 失败: %v", b.ID, err)
+		sendNotification(app, "FidruaWatch - 打包失败", fmt.Sprintf("%s: %v", displayFolder(folder), err))
+		updateUI()
+		return false
+	}
+	b.PackPath = zipPath
+	batchesMu.Unlock()
+	logEvent("批次 %s 已打包: %s", b.ID, zipPath)
+	updateUI()
+	return true
+}
+
+// packBatchNow packs a batch from its card menu and deletes the originals
+// when enabled. It refuses while the batch's completion actions, which may
+// be copying or deleting the same files, are still running.
+func packBatchNow(b *Batch, updateUI func(), app fyne.App) {
+	batchesMu.RLock()
+	busy := b.Packing || b.Ingest || b.PostProc
+	folder, files := b.Folder, append([]string(nil), b.Files...)
+	batchesMu.RUnlock()
+	if busy {
+		logEvent("批次 %s 正在处理中, 稍后再打包", b.ID)
+		return
+	}
+	if packBatch(b, updateUI, app) && config.PackDeleteOrig {
+		removeOriginals(folder, files)
+		updateUI()
+	}
+}
+
+// removeOriginals deletes the files of a batch once they were copied and
+// verified elsewhere. Temp files were not copied and stay.
+func removeOriginals(folder string, files []string) {
+	for _, f := range files {
+		if isTempFile(f) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(folder, f)); err != nil && !os.IsNotExist(err) {
+			logEvent("删除原文件 %s 失败: %v", f, err)
+		}
+	}
+}
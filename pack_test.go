@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPackName(t *testing.T) {
+	got := packName("/in/day1", time.Date(2024, 3, 1, 15, 30, 0, 0, time.UTC))
+	if got != "day1_20240301-153000.zip" {
+		t.Errorf("packName = %q", got)
+	}
+}
+
+func TestPackBatch(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "day1")
+	os.MkdirAll(filepath.Join(folder, "sub"), 0755)
+	files := map[string]string{
+		"a.mp4":      strings.Repeat("a", 5000),
+		"sub/b.jpg":  strings.Repeat("b", 5000),
+		"c.mp4.part": "partial",
+	}
+	b := &Batch{ID: "1", Folder: folder, Status: "completed", FileSizes: map[string]int64{}}
+	for name, data := range files {
+		os.WriteFile(filepath.Join(folder, name), []byte(data), 0644)
+		b.Files = append(b.Files, name)
+		b.FileSizes[name] = int64(len(data))
+		b.TotalSize += int64(len(data))
+	}
+
+	config.PackDir = filepath.Join(dir, "out")
+	config.PackDeleteOrig = true
+	packBatchNow(b, func() {}, nil)
+
+	if b.Packing || b.PackPath == "" {
+		t.Fatalf("Packing = %v, PackPath = %q", b.Packing, b.PackPath)
+	}
+	r, err := zip.OpenReader(b.PackPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	if len(names) != 2 {
+		t.Errorf("zip entries = %v, want a.mp4 and sub/b.jpg without the temp file", names)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "a.mp4")); !os.IsNotExist(err) {
+		t.Error("original not deleted after verified packing")
+	}
+	if _, err := os.Stat(filepath.Join(folder, "c.mp4.part")); err != nil {
+		t.Error("temp file left out of the zip was deleted")
+	}
+}
+
+func TestPostProcessingPacksAfterChecks(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+
+	dir := t.TempDir()
+	folder := filepath.Join(dir, "day1")
+	os.MkdirAll(folder, 0755)
+	data := strings.Repeat("x", 1<<20)
+	b := &Batch{ID: "1", Folder: folder, Status: "completed", FileSizes: map[string]int64{}}
+	var sums strings.Builder
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("clip%02d.mp4", i)
+		os.WriteFile(filepath.Join(folder, name), []byte(data), 0644)
+		fmt.Fprintf(&sums, "%x  %s\n", sha256.Sum256([]byte(data)), name)
+		b.Files = append(b.Files, name)
+		b.FileSizes[name] = int64(len(data))
+		b.TotalSize += int64(len(data))
+	}
+	os.WriteFile(filepath.Join(folder, "SHA256SUMS"), []byte(sums.String()), 0644)
+	b.Files = append(b.Files, "SHA256SUMS")
+
+	config = Config{VerifyChecksums: true, PackEnabled: true, PackDeleteOrig: true, PackDir: filepath.Join(dir, "out")}
+	batchesMu.Lock()
+	startPostProcessing(context.Background(), b, func() {}, nil)
+	batchesMu.Unlock()
+
+	// The originals are deleted after packing, which must wait for the
+	// checksums
+	for deadline := time.Now().Add(30 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		batchesMu.RLock()
+		running := b.PostProc
+		batchesMu.RUnlock()
+		if !running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("post-processing did not finish")
+		}
+	}
+	batchesMu.RLock()
+	defer batchesMu.RUnlock()
+	if b.PackPath == "" {
+		t.Fatal("batch not packed")
+	}
+	if b.Checksum != checksumOK {
+		t.Errorf("Checksum = %q, want ok: %v", b.Checksum, b.Sums)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "clip00.mp4")); !os.IsNotExist(err) {
+		t.Error("originals not deleted after packing")
+	}
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Plugins add notification and storage backends without touching the rest
+// of the program. A compiled-in plugin is a file that calls registerPlugin
+// from init with a value implementing one or more of Notifier, Uploader and
+// Store; an external plugin is a command speaking the JSON protocol of
+// execPlugin. Either kind only runs when listed and enabled in
+// config.Plugins.
+
+// Notifier receives every notification FidruaWatch sends
+type Notifier interface {
+	Notify(ctx context.Context, title, message string) error
+}
+
+// Uploader receives each completed batch, e.g. to copy it elsewhere.
+// Folder and Files in the record locate the files.
+type Uploader interface {
+	Upload(ctx context.Context, rec HistoryRecord) error
+}
+
+// Store keeps a copy of every history record written
+type Store interface {
+	Store(ctx context.Context, rec HistoryRecord) error
+}
+
+// Plugin kinds, the interface a plugin implements
+const (
+	pluginNotifier = "notifier"
+	pluginUploader = "uploader"
+	pluginStore    = "store"
+)
+
+var pluginKinds = []struct {
+	Kind  string
+	Label string
+}{
+	{pluginNotifier, "通知"},
+	{pluginUploader, "上传完成的批次"},
+	{pluginStore, "保存历史记录"},
+}
+
+// Time limits of one plugin call, and how many calls may wait
+const (
+	pluginTimeout       = 30 * time.Second
+	pluginUploadTimeout = 30 * time.Minute
+	pluginQueueSize     = 256
+)
+
+// PluginConfig enables a plugin. Command is empty for a compiled-in plugin,
+// which is found by Name; for an external one it is the command line and
+// Kind says which calls it gets.
+type PluginConfig struct {
+	Name    string `json:"name" yaml:"name" toml:"name"`
+	Enabled bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Kind    string `json:"kind,omitempty" yaml:"kind,omitempty" toml:"kind,omitempty"`
+	Command string `json:"command,omitempty" yaml:"command,omitempty" toml:"command,omitempty"`
+}
+
+// validate returns why a plugin entry can't be used, or ""
+func (p PluginConfig) validate() string {
+	if strings.TrimSpace(p.Name) == "" {
+		return "缺少名称"
+	}
+	if p.Command == "" {
+		if _, ok := builtinPlugin(p.Name); !ok {
+			return "没有这个内置插件"
+		}
+		return ""
+	}
+	if pluginKindLabel(p.Kind) == "" {
+		return fmt.Sprintf("类型 %q 无效", p.Kind)
+	}
+	return ""
+}
+
+// pluginKindLabel returns the label of a kind, or "" if it is unknown
+func pluginKindLabel(kind string) string {
+	for _, k := range pluginKinds {
+		if k.Kind == kind {
+			return k.Label
+		}
+	}
+	return ""
+}
+
+var (
+	builtinPluginsMu sync.Mutex
+	builtinPlugins   = map[string]interface{}{}
+)
+
+// registerPlugin makes a compiled-in plugin available under name. It
+// panics on a duplicate name or a value that implements none of the plugin
+// interfaces, both mistakes of the plugin's author.
+func registerPlugin(name string, p interface{}) {
+	if len(pluginKindsOf(p)) == 0 {
+		panic("plugin " + name + " implements no plugin interface")
+	}
+	builtinPluginsMu.Lock()
+	defer builtinPluginsMu.Unlock()
+	if _, dup := builtinPlugins[name]; dup {
+		panic("plugin " + name + " registered twice")
+	}
+	builtinPlugins[name] = p
+}
+
+// builtinPlugin returns the compiled-in plugin registered under name
+func builtinPlugin(name string) (interface{}, bool) {
+	builtinPluginsMu.Lock()
+	defer builtinPluginsMu.Unlock()
+	p, ok := builtinPlugins[name]
+	return p, ok
+}
+
+// builtinPluginNames returns the names of the compiled-in plugins, sorted
+func builtinPluginNames() []string {
+	builtinPluginsMu.Lock()
+	defer builtinPluginsMu.Unlock()
+	names := make([]string, 0, len(builtinPlugins))
+	for name := range builtinPlugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// pluginKindsOf returns the kinds a compiled-in plugin implements
+func pluginKindsOf(p interface{}) []string {
+	var kinds []string
+	if _, ok := p.(Notifier); ok {
+		kinds = append(kinds, pluginNotifier)
+	}
+	if _, ok := p.(Uploader); ok {
+		kinds = append(kinds, pluginUploader)
+	}
+	if _, ok := p.(Store); ok {
+		kinds = append(kinds, pluginStore)
+	}
+	return kinds
+}
+
+// activePlugin is an enabled plugin of one kind
+type activePlugin struct {
+	Name   string
+	Plugin interface{}
+}
+
+// enabledPlugins returns the enabled plugins of a kind in config order
+func enabledPlugins(kind string) []activePlugin {
+	var out []activePlugin
+	for _, pc := range config.Plugins {
+		if !pc.Enabled {
+			continue
+		}
+		var p interface{}
+		if pc.Command != "" {
+			if pc.Kind != kind {
+				continue
+			}
+			p = execPlugin{Name: pc.Name, Command: pc.Command}
+		} else if p, _ = builtinPlugin(pc.Name); p == nil {
+			continue
+		}
+		for _, k := range pluginKindsOf(p) {
+			if k == kind {
+				out = append(out, activePlugin{pc.Name, p})
+			}
+		}
+	}
+	return out
+}
+
+// pluginQueue runs plugin calls one at a time in the order they were made,
+// so a store plugin sees a batch's records in order and a slow backend
+// never holds up watching
+type pluginQueue struct {
+	once sync.Once
+	jobs chan func()
+}
+
+var pluginCalls = &pluginQueue{}
+
+// enqueue adds a call, dropping it with a log entry if the queue is full
+func (q *pluginQueue) enqueue(name string, fn func()) {
+	q.once.Do(func() {
+		q.jobs = make(chan func(), pluginQueueSize)
+		go func() {
+			for job := range q.jobs {
+				job()
+			}
+		}()
+	})
+	select {
+	case q.jobs <- fn:
+	default:
+		logEvent("插件 %s: 队列已满, 丢弃一次调用", name)
+	}
+}
+
+// callPlugin runs one plugin call with a time limit, logging failures
+func callPlugin(name string, timeout time.Duration, call func(ctx context.Context) error) {
+	pluginCalls.enqueue(name, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		if err := call(ctx); err != nil {
+			logEvent("插件 %s 失败: %v", name, err)
+		}
+	})
+}
+
+// notifyPlugins passes a notification to the notifier plugins
+func notifyPlugins(title, message string) {
+	for _, p := range enabledPlugins(pluginNotifier) {
+		n := p.Plugin.(Notifier)
+		callPlugin(p.Name, pluginTimeout, func(ctx context.Context) error {
+			return n.Notify(ctx, title, message)
+		})
+	}
+}
+
+// uploadPlugins passes a completed batch to the uploader plugins, waiting
+// while power saving pauses uploads. Remote and simulated batches have no
+// local files and are skipped.
+func uploadPlugins(ctx context.Context, b *Batch) {
+	plugins := enabledPlugins(pluginUploader)
+	if len(plugins) == 0 {
+		return
+	}
+	batchesMu.RLock()
+	rec := historyRecordFor(b)
+	batchesMu.RUnlock()
+	if isRemoteWatchPath(rec.Folder) {
+		return
+	}
+	if !waitForPower(ctx, "上传批次 "+rec.ID) {
+		return
+	}
+	for _, p := range plugins {
+		u := p.Plugin.(Uploader)
+		callPlugin(p.Name, pluginUploadTimeout, func(ctx context.Context) error {
+			return u.Upload(ctx, rec)
+		})
+	}
+}
+
+// storePlugins passes a history record to the store plugins
+func storePlugins(rec HistoryRecord) {
+	for _, p := range enabledPlugins(pluginStore) {
+		s := p.Plugin.(Store)
+		callPlugin(p.Name, pluginTimeout, func(ctx context.Context) error {
+			return s.Store(ctx, rec)
+		})
+	}
+}
+
+// pluginProtocol is the version of the external plugin protocol
+const pluginProtocol = 1
+
+// pluginRequest is written as one JSON document to an external plugin's
+// stdin. Event is "notify" (Title, Message), "upload" or "store" (Batch).
+type pluginRequest struct {
+	Protocol int            `json:"protocol"`
+	Event    string         `json:"event"`
+	Title    string         `json:"title,omitempty"`
+	Message  string         `json:"message,omitempty"`
+	Batch    *HistoryRecord `json:"batch,omitempty"`
+	// RateLimit asks uploaders to stay under this many bytes per second,
+	// see transferLimit; 0 is no limit
+	RateLimit int64 `json:"rate_limit,omitempty"`
+}
+
+// pluginReply is what an external plugin may print on stdout. No output
+// and a zero exit status also mean success.
+type pluginReply struct {
+	Error string `json:"error"`
+}
+
+// execPlugin is an external plugin: its command runs once per call
+// through the shell
+type execPlugin struct {
+	Name    string
+	Command string
+}
+
+func (p execPlugin) Notify(ctx context.Context, title, message string) error {
+	return p.call(ctx, pluginRequest{Event: "notify", Title: title, Message: message})
+}
+
+func (p execPlugin) Upload(ctx context.Context, rec HistoryRecord) error {
+	return p.call(ctx, pluginRequest{Event: "upload", Batch: &rec, RateLimit: transferLimit()})
+}
+
+func (p execPlugin) Store(ctx context.Context, rec HistoryRecord) error {
+	return p.call(ctx, pluginRequest{Event: "store", Batch: &rec})
+}
+
+// call runs the command with req on stdin and reads its reply
+func (p execPlugin) call(ctx context.Context, req pluginRequest) error {
+	req.Protocol = pluginProtocol
+	in, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	cmd := shellCommand(ctx, p.Command)
+	cmd.Stdin = bytes.NewReader(in)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		text := strings.TrimSpace(stderr.String())
+		if len(text) > 200 {
+			text = text[:200] + "..."
+		}
+		return fmt.Errorf("%v %s", err, text)
+	}
+	out := bytes.TrimSpace(stdout.Bytes())
+	if len(out) == 0 {
+		return nil
+	}
+	var reply pluginReply
+	if err := json.Unmarshal(out, &reply); err != nil {
+		return fmt.Errorf("无法解析插件输出: %v", err)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("%s", reply.Error)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+// recordingPlugin is a compiled-in notifier and store that remembers calls
+type recordingPlugin struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (p *recordingPlugin) Notify(ctx context.Context, title, message string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, "notify "+title)
+	return nil
+}
+
+func (p *recordingPlugin) Store(ctx context.Context, rec HistoryRecord) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls = append(p.calls, "store "+rec.ID)
+	return nil
+}
+
+// waitPlugins returns once the calls queued so far have run
+func waitPlugins() {
+	done := make(chan struct{})
+	pluginCalls.enqueue("test", func() { close(done) })
+	<-done
+}
+
+func TestPlugins(t *testing.T) {
+	origConfig := config
+	defer func() { config = origConfig }()
+	p := &recordingPlugin{}
+	registerPlugin("recorder", p)
+	defer func() {
+		builtinPluginsMu.Lock()
+		delete(builtinPlugins, "recorder")
+		builtinPluginsMu.Unlock()
+	}()
+	if got, want := pluginKindsOf(p), []string{pluginNotifier, pluginStore}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pluginKindsOf = %v, want %v", got, want)
+	}
+
+	config = defaultConfig()
+	notifyPlugins("off", "")
+	config.Plugins = []PluginConfig{{Name: "recorder", Enabled: true}, {Name: "missing", Enabled: true}}
+	notifyPlugins("on", "")
+	storePlugins(HistoryRecord{ID: "b1"})
+	uploadPlugins(context.Background(), &Batch{ID: "b2", Folder: t.TempDir()})
+	waitPlugins()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if want := []string{"notify on", "store b1"}; !reflect.DeepEqual(p.calls, want) {
+		t.Errorf("calls = %v, want %v", p.calls, want)
+	}
+}
+
+func TestPluginConfigValidate(t *testing.T) {
+	registerPlugin("validate-test", &recordingPlugin{})
+	defer func() {
+		builtinPluginsMu.Lock()
+		delete(builtinPlugins, "validate-test")
+		builtinPluginsMu.Unlock()
+	}()
+	tests := []struct {
+		p  PluginConfig
+		ok bool
+	}{
+		{PluginConfig{Name: "validate-test"}, true},
+		{PluginConfig{Name: "nope"}, false},
+		{PluginConfig{Name: "ext", Kind: pluginUploader, Command: "upload.sh"}, true},
+		{PluginConfig{Name: "ext", Kind: "mail", Command: "mail.sh"}, false},
+		{PluginConfig{Kind: pluginStore, Command: "store.sh"}, false},
+	}
+	for _, tt := range tests {
+		if problem := tt.p.validate(); (problem == "") != tt.ok {
+			t.Errorf("validate(%+v) = %q, want ok %v", tt.p, problem, tt.ok)
+		}
+	}
+}
+
+func TestExecPlugin(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	out := filepath.Join(t.TempDir(), "request.json")
+	p := execPlugin{Name: "ext", Command: "cat > '" + out + "'"}
+	if err := p.Upload(context.Background(), HistoryRecord{ID: "b1", Files: []string{"a.mp4"}}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var req pluginRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		t.Fatal(err)
+	}
+	if req.Protocol != pluginProtocol || req.Event != "upload" || req.Batch == nil || req.Batch.ID != "b1" {
+		t.Errorf("request = %s", data)
+	}
+
+	p.Command = `echo '{"error": "disk full"}'`
+	if err := p.Notify(context.Background(), "t", "m"); err == nil || err.Error() != "disk full" {
+		t.Errorf("Notify error = %v, want disk full", err)
+	}
+	p.Command = "exit 3"
+	if err := p.Store(context.Background(), HistoryRecord{}); err == nil {
+		t.Error("Store succeeded with exit status 3")
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showPluginsDialog lists the compiled-in plugins and the external ones
+// added by the user for enabling, editing and deleting. Every change is
+// saved right away.
+func showPluginsDialog(save func() bool, w fyne.Window) {
+	plugins := append([]PluginConfig(nil), config.Plugins...)
+	listed := make(map[string]bool)
+	for _, p := range plugins {
+		if p.Command == "" {
+			listed[p.Name] = true
+		}
+	}
+	for _, name := range builtinPluginNames() {
+		if !listed[name] {
+			plugins = append(plugins, PluginConfig{Name: name})
+		}
+	}
+
+	empty := widget.NewLabel("还没有插件。外部插件是一个命令, 每次调用时从标准输入读取一个 JSON 请求。")
+	empty.Wrapping = fyne.TextWrapWord
+	var list *widget.List
+	apply := func() {
+		config.Plugins = append([]PluginConfig(nil), plugins...)
+		save()
+		empty.Hidden = len(plugins) > 0
+		empty.Refresh()
+		list.Refresh()
+	}
+	list = widget.NewList(
+		func() int { return len(plugins) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, widget.NewCheck("", nil),
+				container.NewHBox(widget.NewButton("✏️", nil), widget.NewButton("🗑️", nil)), l)
+		},
+		nil,
+	)
+	list.UpdateItem = func(i widget.ListItemID, o fyne.CanvasObject) {
+		row := o.(*fyne.Container)
+		buttons := row.Objects[2].(*fyne.Container).Objects
+		row.Objects[0].(*widget.Label).SetText(pluginSummary(plugins[i]))
+		check := row.Objects[1].(*widget.Check)
+		check.OnChanged = nil
+		check.SetChecked(plugins[i].Enabled)
+		check.OnChanged = func(on bool) {
+			plugins[i].Enabled = on
+			apply()
+		}
+		edit, del := buttons[0].(*widget.Button), buttons[1].(*widget.Button)
+		edit.OnTapped = func() {
+			showPluginForm(plugins[i], func(p PluginConfig) {
+				plugins[i] = p
+				apply()
+			}, w)
+		}
+		del.OnTapped = func() {
+			plugins = append(plugins[:i:i], plugins[i+1:]...)
+			apply()
+		}
+		// Compiled-in plugins can only be switched on and off
+		if plugins[i].Command == "" {
+			edit.Disable()
+			del.Disable()
+		} else {
+			edit.Enable()
+			del.Enable()
+		}
+	}
+	empty.Hidden = len(plugins) > 0
+
+	addBtn := widget.NewButton("➕ 添加外部插件", func() {
+		p := PluginConfig{Name: fmt.Sprintf("插件 %d", len(plugins)+1), Enabled: true, Kind: pluginNotifier}
+		showPluginForm(p, func(p PluginConfig) {
+			plugins = append(plugins, p)
+			apply()
+		}, w)
+	})
+	hint := widget.NewLabel("通知插件收到每条通知, 上传插件收到每个完成的批次, 存储插件收到每条历史记录。插件在后台按顺序调用。")
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewBorder(container.NewVBox(hint, empty), addBtn, nil, nil, list)
+	d := dialog.NewCustom("🧱 插件", "关闭", content, w)
+	d.Resize(fyne.NewSize(600, 420))
+	d.Show()
+}
+
+// showPluginForm edits an external plugin, calling done once it is valid
+func showPluginForm(p PluginConfig, done func(PluginConfig), w fyne.Window) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(p.Name)
+	enabledCheck := widget.NewCheck("启用", nil)
+	enabledCheck.SetChecked(p.Enabled)
+	var kindLabels []string
+	for _, k := range pluginKinds {
+		kindLabels = append(kindLabels, k.Label)
+	}
+	kindSelect := widget.NewSelect(kindLabels, nil)
+	for i, k := range pluginKinds {
+		if k.Kind == p.Kind {
+			kindSelect.SetSelectedIndex(i)
+		}
+	}
+	commandEntry := widget.NewEntry()
+	commandEntry.SetPlaceHolder("命令行, 如 python3 /path/to/plugin.py")
+	commandEntry.SetText(p.Command)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("名称", container.NewBorder(nil, nil, nil, enabledCheck, nameEntry)),
+		widget.NewFormItem("类型", kindSelect),
+		widget.NewFormItem("命令", commandEntry),
+	}
+	dialog.ShowForm("编辑插件", "保存", "取消", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		edited := PluginConfig{
+			Name:    strings.TrimSpace(nameEntry.Text),
+			Enabled: enabledCheck.Checked,
+			Command: strings.TrimSpace(commandEntry.Text),
+		}
+		if i := kindSelect.SelectedIndex(); i >= 0 {
+			edited.Kind = pluginKinds[i].Kind
+		}
+		problem := edited.validate()
+		if edited.Command == "" {
+			problem = "缺少命令"
+		}
+		if problem != "" {
+			dialog.ShowError(fmt.Errorf("%s", problem), w)
+			showPluginForm(edited, done, w)
+			return
+		}
+		done(edited)
+	}, w)
+}
+
+// pluginSummary describes a plugin in one line for the plugins list
+func pluginSummary(p PluginConfig) string {
+	if p.Command != "" {
+		return fmt.Sprintf("%s: %s, %s", p.Name, pluginKindLabel(p.Kind), p.Command)
+	}
+	var labels []string
+	if v, ok := builtinPlugin(p.Name); ok {
+		for _, k := range pluginKindsOf(v) {
+			labels = append(labels, pluginKindLabel(k))
+		}
+	} else {
+		labels = append(labels, "未编译进此版本")
+	}
+	return fmt.Sprintf("%s (内置): %s", p.Name, strings.Join(labels, ", "))
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+const inotifyLimitPath = "/proc/sys/fs/inotify/max_user_watches"
+
+// pollInterval is how often polled directories are listed
+var pollInterval = 5 * time.Second
+
+// poller covers the directories fsnotify refused to watch once the user
+// opts into polling. Guarded by watchers.mu.
+var poller *dirPoller
+
+// dirPoller watches directories by periodically listing them. It covers the
+// part of a tree that could not be added to fsnotify, e.g. once the Linux
+// inotify watch limit is exhausted.
+type dirPoller struct {
+	mu   sync.Mutex
+	dirs map[string]bool
+	seen map[string]int64 // file path -> last observed size
+}
+
+func newDirPoller() *dirPoller {
+	return &dirPoller{
+		dirs: make(map[string]bool),
+		seen: make(map[string]int64),
+	}
+}
+
+// AddDir starts polling dir. With baseline set, files already present are
+// recorded without being reported, matching fsnotify which only sees changes.
+func (p *dirPoller) AddDir(dir string, baseline bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.dirs[dir] {
+		return
+	}
+	p.dirs[dir] = true
+	if !baseline {
+		return
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if info, err := e.Info(); err == nil {
+			p.seen[filepath.Join(dir, e.Name())] = info.Size()
+		}
+	}
+}
+
+// Len returns the number of polled directories
+func (p *dirPoller) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.dirs)
+}
+
+// Poll lists every polled directory once and returns the monitored files that
+// appeared or grew since the last poll. New subdirectories are polled from now
+// on when subfolder monitoring is enabled.
+func (p *dirPoller) Poll() []string {
+	p.mu.Lock()
+	dirs := make([]string, 0, len(p.dirs))
+	for d := range p.dirs {
+		dirs = append(dirs, d)
+	}
+	p.mu.Unlock()
+
+	var changed []string
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			if e.IsDir() {
+				if config.MonitorSubdirs {
+					p.AddDir(path, false)
+				}
+				continue
+			}
+			info, err := e.Info()
+			if err != nil {
+				continue
+			}
+			p.mu.Lock()
+			size, known := p.seen[path]
+			p.seen[path] = info.Size()
+			p.mu.Unlock()
+			if known && info.Size() <= size {
+				continue
+			}
+			if isMonitoredFile(path) {
+				changed = append(changed, path)
+			}
+		}
+	}
+	return changed
+}
+
+// Run polls until ctx is cancelled, passing changed files to ingest
+func (p *dirPoller) Run(ctx context.Context, ingest func(path string)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ecoInterval(pollInterval)):
+			for _, path := range p.Poll() {
+				ingest(path)
+			}
+		}
+	}
+}
+
+// parseInotifyLimit parses the contents of max_user_watches, 0 if invalid
+func parseInotifyLimit(data string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(data))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// readInotifyLimit returns the current inotify watch limit, 0 if unknown
+func readInotifyLimit() int {
+	if runtime.GOOS != "linux" {
+		return 0
+	}
+	data, err := os.ReadFile(inotifyLimitPath)
+	if err != nil {
+		return 0
+	}
+	return parseInotifyLimit(string(data))
+}
+
+// failedWatchCount returns how many directories could not be watched
+func failedWatchCount() int {
+	return len(watchers.FailedDirs())
+}
+
+// startPolling falls back to polling for every directory fsnotify rejected
+func startPolling(ctx context.Context, updateUI func(), app fyne.App) {
+	p := newDirPoller()
+	for _, dir := range watchers.FailedDirs() {
+		p.AddDir(dir, true)
+	}
+	watchers.mu.Lock()
+	poller = p
+	watchers.mu.Unlock()
+
+	go p.Run(ctx, func(path string) {
+		ingestFile(path, updateUI, app)
+	})
+}
+
+// showWatchLimitDialog explains why part of the tree is not watched and
+// offers to poll the remaining directories instead
+func showWatchLimitDialog(failed int, w fyne.Window, onPoll func()) {
+	msg := fmt.Sprintf("有 %d 个文件夹无法加入实时监控。\n", failed)
+	if limit := readInotifyLimit(); limit > 0 {
+		msg += fmt.Sprintf("\nLinux inotify 监控上限 fs.inotify.max_user_watches 当前为 %d，\n", limit) +
+			"文件夹数量超出后新增的监控都会失败。可执行以下命令提高上限：\n\n" +
+			"sudo sysctl fs.inotify.max_user_watches=524288\n"
+	}
+	msg += "\n是否对剩余文件夹改用轮询模式（每 " + pollInterval.String() + " 扫描一次）？"
+
+	dialog.ShowConfirm("部分文件夹未被监控", msg, func(ok bool) {
+		if ok {
+			onPoll()
+		}
+	}, w)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseInotifyLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"8192\n", 8192},
+		{"524288", 524288},
+		{"", 0},
+		{"garbage", 0},
+	}
+	for _, tt := range tests {
+		if result := parseInotifyLimit(tt.input); result != tt.expected {
+			t.Errorf("parseInotifyLimit(%q) = %d, want %d", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestDirPoller(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.WriteFile(filepath.Join(tmpDir, "existing.mp4"), []byte("old"), 0644)
+
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{VideoEnabled: true, MonitorSubdirs: true}
+
+	p := newDirPoller()
+	p.AddDir(tmpDir, true)
+
+	if changed := p.Poll(); len(changed) != 0 {
+		t.Errorf("Baselined files reported as changed: %v", changed)
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "new.mp4"), []byte("new"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "existing.mp4"), []byte("older"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "notes.txt"), []byte("x"), 0644)
+	sub := filepath.Join(tmpDir, "sub")
+	os.MkdirAll(sub, 0755)
+	os.WriteFile(filepath.Join(sub, "inner.mp4"), []byte("inner"), 0644)
+
+	changed := p.Poll()
+	if len(changed) != 2 {
+		t.Errorf("Expected 2 changed files, got %v", changed)
+	}
+	if p.Len() != 2 {
+		t.Errorf("Expected new subfolder to be polled, Len() = %d", p.Len())
+	}
+
+	// Files in the newly discovered subfolder are reported on the next poll
+	changed = p.Poll()
+	if len(changed) != 1 || filepath.Base(changed[0]) != "inner.mp4" {
+		t.Errorf("Expected inner.mp4, got %v", changed)
+	}
+}
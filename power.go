@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Power saving on laptops: while on battery or a metered connection, when
+// the matching settings are on, FidruaWatch polls less often, skips
+// hashing files and holds uploads until the power or network is back.
+
+const (
+	powerCheckInterval = time.Minute
+	ecoPollFactor      = 4 // polling intervals are this many times longer
+)
+
+// powerState is what the platform power APIs report; unknown is false
+type powerState struct {
+	OnBattery bool
+	Metered   bool
+}
+
+var (
+	powerMu      sync.Mutex
+	currentPower powerState
+	// powerChanged is closed and replaced whenever currentPower changes,
+	// waking waitForPower
+	powerChanged = make(chan struct{})
+)
+
+// setPowerState records a new reading, logging changes
+func setPowerState(p powerState) {
+	powerMu.Lock()
+	old := currentPower
+	currentPower = p
+	if p != old {
+		close(powerChanged)
+		powerChanged = make(chan struct{})
+	}
+	powerMu.Unlock()
+	if p.OnBattery != old.OnBattery {
+		if p.OnBattery {
+			logEvent("切换到电池供电")
+		} else {
+			logEvent("已接通电源")
+		}
+	}
+	if p.Metered != old.Metered {
+		if p.Metered {
+			logEvent("当前网络为计费网络")
+		} else {
+			logEvent("当前网络不再计费")
+		}
+	}
+}
+
+// runPowerMonitor reads the power state every powerCheckInterval until ctx
+// is cancelled, while power saving is configured
+func runPowerMonitor(ctx context.Context) {
+	for {
+		if config.EcoOnBattery || config.EcoOnMetered {
+			setPowerState(readPowerState(ctx))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(powerCheckInterval):
+		}
+	}
+}
+
+// ecoActive reports whether power saving applies right now
+func ecoActive() bool {
+	powerMu.Lock()
+	p := currentPower
+	powerMu.Unlock()
+	return (config.EcoOnBattery && p.OnBattery) || (config.EcoOnMetered && p.Metered)
+}
+
+// ecoInterval stretches a polling interval while saving power
+func ecoInterval(d time.Duration) time.Duration {
+	if config.EcoSlowPolling && ecoActive() {
+		return d * ecoPollFactor
+	}
+	return d
+}
+
+// ecoSkipHashing reports whether files should not be hashed right now
+func ecoSkipHashing() bool {
+	return config.EcoSkipHashing && ecoActive()
+}
+
+// waitForPower holds an upload while power saving pauses uploads. It
+// returns false if ctx was cancelled first.
+func waitForPower(ctx context.Context, what string) bool {
+	logged := false
+	for {
+		powerMu.Lock()
+		changed := powerChanged
+		powerMu.Unlock()
+		if !config.EcoPauseUploads || !ecoActive() {
+			if logged {
+				logEvent("节能模式结束, 继续%s", what)
+			}
+			return true
+		}
+		if !logged {
+			logEvent("节能模式: %s暂停, 等待接通电源或非计费网络", what)
+			logged = true
+		}
+		// Settings can end power saving too, so look again now and then
+		select {
+		case <-ctx.Done():
+			return false
+		case <-changed:
+		case <-time.After(powerCheckInterval):
+		}
+	}
+}
+
+// sysfsOnBattery reads the Linux power supply class: on battery when a
+// battery is present and no mains or USB supply is online
+func sysfsOnBattery(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	battery, mains := false, false
+	for _, e := range entries {
+		read := func(name string) string {
+			data, _ := os.ReadFile(filepath.Join(dir, e.Name(), name))
+			return strings.TrimSpace(string(data))
+		}
+		switch read("type") {
+		case "Battery":
+			if read("scope") != "Device" { // not a mouse or keyboard battery
+				battery = true
+			}
+		case "Mains", "USB", "USB_C", "USB_PD":
+			if read("online") == "1" {
+				mains = true
+			}
+		}
+	}
+	return battery && !mains
+}
+
+// nmMetered parses NetworkManager's Metered property as printed by busctl,
+// e.g. "u 1": 1 is yes and 3 a guessed yes
+func nmMetered(out string) bool {
+	f := strings.Fields(out)
+	return len(f) == 2 && (f[1] == "1" || f[1] == "3")
+}
+
+// pmsetOnBattery parses the output of "pmset -g batt" on macOS
+func pmsetOnBattery(out string) bool {
+	return strings.Contains(out, "'Battery Power'")
+}
+
+// windowsMetered interprets the NetworkCostType of the internet connection
+// profile; only Unrestricted is free
+func windowsMetered(cost string) bool {
+	cost = strings.TrimSpace(cost)
+	return cost != "" && cost != "Unrestricted" && cost != "Unknown"
+}
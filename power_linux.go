@@ -0,0 +1,24 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// readPowerState reads the power supply from sysfs and asks
+// NetworkManager over D-Bus whether the connection is metered
+func readPowerState(ctx context.Context) powerState {
+	p := powerState{OnBattery: sysfsOnBattery("/sys/class/power_supply")}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "busctl", "get-property",
+		"org.freedesktop.NetworkManager", "/org/freedesktop/NetworkManager",
+		"org.freedesktop.NetworkManager", "Metered").Output()
+	if err == nil {
+		p.Metered = nmMetered(string(out))
+	}
+	return p
+}
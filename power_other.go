@@ -0,0 +1,21 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// readPowerState asks pmset for the power source. macOS has no way to ask
+// about metered networks from the command line, so Metered stays false.
+func readPowerState(ctx context.Context) powerState {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, "pmset", "-g", "batt").Output()
+	if err != nil {
+		return powerState{}
+	}
+	return powerState{OnBattery: pmsetOnBattery(string(out))}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSysfsOnBattery(t *testing.T) {
+	dir := t.TempDir()
+	supply := func(name string, files map[string]string) {
+		os.MkdirAll(filepath.Join(dir, name), 0755)
+		for f, v := range files {
+			os.WriteFile(filepath.Join(dir, name, f), []byte(v+"\n"), 0644)
+		}
+	}
+	if sysfsOnBattery(dir) {
+		t.Error("a desktop without supplies is on battery")
+	}
+	supply("BAT0", map[string]string{"type": "Battery", "scope": "System"})
+	supply("AC", map[string]string{"type": "Mains", "online": "1"})
+	supply("hid-mouse", map[string]string{"type": "Battery", "scope": "Device"})
+	if sysfsOnBattery(dir) {
+		t.Error("plugged-in laptop reported on battery")
+	}
+	supply("AC", map[string]string{"online": "0"})
+	if !sysfsOnBattery(dir) {
+		t.Error("unplugged laptop not reported on battery")
+	}
+	os.RemoveAll(filepath.Join(dir, "BAT0"))
+	if sysfsOnBattery(dir) {
+		t.Error("a mouse battery counted as the system's")
+	}
+}
+
+func TestPowerOutputParsers(t *testing.T) {
+	if !nmMetered("u 1\n") || !nmMetered("u 3") || nmMetered("u 2") || nmMetered("") {
+		t.Error("nmMetered")
+	}
+	if !pmsetOnBattery("Now drawing from 'Battery Power'\n -InternalBattery-0 80%") || pmsetOnBattery("Now drawing from 'AC Power'") {
+		t.Error("pmsetOnBattery")
+	}
+	if !windowsMetered("Fixed\r\n") || windowsMetered("Unrestricted\r\n") || windowsMetered("") {
+		t.Error("windowsMetered")
+	}
+}
+
+func TestEcoMode(t *testing.T) {
+	saved := config
+	defer func() {
+		config = saved
+		setPowerState(powerState{})
+	}()
+	config.EcoOnBattery = true
+	config.EcoSlowPolling, config.EcoPauseUploads = true, true
+
+	setPowerState(powerState{Metered: true})
+	if ecoActive() || ecoInterval(time.Second) != time.Second {
+		t.Error("metered connection saved power without EcoOnMetered")
+	}
+	setPowerState(powerState{OnBattery: true})
+	if ecoInterval(time.Second) != ecoPollFactor*time.Second || ecoSkipHashing() {
+		t.Errorf("on battery: interval %v, skip hashing %v", ecoInterval(time.Second), ecoSkipHashing())
+	}
+
+	// An upload waits until the power is back
+	done := make(chan bool)
+	go func() { done <- waitForPower(context.Background(), "test") }()
+	select {
+	case <-done:
+		t.Fatal("upload ran on battery")
+	case <-time.After(50 * time.Millisecond):
+	}
+	setPowerState(powerState{})
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("waitForPower = false")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("upload still waiting on mains power")
+	}
+
+	setPowerState(powerState{OnBattery: true})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if waitForPower(ctx, "test") {
+		t.Error("waitForPower ignored the cancelled context")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// PresetSettings are the settings a preset sets: file types, completion
+// timeout, grouping and the actions run on completed batches
+type PresetSettings struct {
+	VideoEnabled      bool   `json:"video_enabled" yaml:"video_enabled" toml:"video_enabled"`
+	ImageEnabled      bool   `json:"image_enabled" yaml:"image_enabled" toml:"image_enabled"`
+	AudioEnabled      bool   `json:"audio_enabled" yaml:"audio_enabled" toml:"audio_enabled"`
+	DocEnabled        bool   `json:"doc_enabled" yaml:"doc_enabled" toml:"doc_enabled"`
+	ArchiveEnabled    bool   `json:"archive_enabled" yaml:"archive_enabled" toml:"archive_enabled"`
+	CustomExts        string `json:"custom_exts" yaml:"custom_exts" toml:"custom_exts"`
+	CompletionTimeout int    `json:"completion_timeout" yaml:"completion_timeout" toml:"completion_timeout"`
+	MonitorSubdirs    bool   `json:"monitor_subdirs" yaml:"monitor_subdirs" toml:"monitor_subdirs"`
+	GroupDepth        int    `json:"group_depth" yaml:"group_depth" toml:"group_depth"`
+	PairSidecars      bool   `json:"pair_sidecars" yaml:"pair_sidecars" toml:"pair_sidecars"`
+	ProbeVideo        bool   `json:"probe_video" yaml:"probe_video" toml:"probe_video"`
+	VerifyArchives    bool   `json:"verify_archives" yaml:"verify_archives" toml:"verify_archives"`
+	VerifyChecksums   bool   `json:"verify_checksums" yaml:"verify_checksums" toml:"verify_checksums"`
+	PackEnabled       bool   `json:"pack_enabled" yaml:"pack_enabled" toml:"pack_enabled"`
+}
+
+// Preset is a named set of watch folder settings applied in one click
+type Preset struct {
+	Name        string         `json:"name" yaml:"name" toml:"name"`
+	Icon        string         `json:"icon,omitempty" yaml:"icon,omitempty" toml:"icon,omitempty"`
+	Description string         `json:"description,omitempty" yaml:"description,omitempty" toml:"description,omitempty"`
+	Settings    PresetSettings `json:"settings" yaml:"settings" toml:"settings"`
+}
+
+// builtinPresets ship with the app; user presets are kept in config.Presets
+var builtinPresets = []Preset{
+	{"视频交付", "🎬", "成片与素材交付: 按项目分组, 读取视频信息, 按附带的校验文件核对", PresetSettings{
+		VideoEnabled: true, AudioEnabled: true, CustomExts: ".mxf,.r3d,.braw,.prproj",
+		CompletionTimeout: 120, MonitorSubdirs: true, GroupDepth: 1,
+		PairSidecars: true, ProbeVideo: true, VerifyChecksums: true,
+	}},
+	{"摄影素材", "📷", "照片与 RAW: 按拍摄文件夹分组, XMP 与照片配对", PresetSettings{
+		ImageEnabled: true, VideoEnabled: true, CustomExts: strings.Join(cameraRawExts, ","),
+		CompletionTimeout: 60, MonitorSubdirs: true, GroupDepth: 1, PairSidecars: true,
+	}},
+	{"文档收集", "📄", "收集文档与压缩包: 每个提交者一个文件夹, 校验压缩包并打包存档", PresetSettings{
+		DocEnabled: true, ArchiveEnabled: true,
+		CompletionTimeout: 30, MonitorSubdirs: true, GroupDepth: 1,
+		VerifyArchives: true, PackEnabled: true,
+	}},
+	{"印刷文件", "🖨️", "印前文件: PDF 与设计源文件, 校验附带的压缩包", PresetSettings{
+		DocEnabled: true, ImageEnabled: true, ArchiveEnabled: true, CustomExts: ".ai,.eps,.indd,.idml,.tif,.cdr",
+		CompletionTimeout: 90, MonitorSubdirs: true, GroupDepth: 1, VerifyArchives: true, VerifyChecksums: true,
+	}},
+}
+
+// allPresets returns the built-in presets followed by the user's own
+func allPresets() []Preset {
+	return append(append([]Preset(nil), builtinPresets...), config.Presets...)
+}
+
+// isBuiltinPreset reports whether name is taken by a built-in preset
+func isBuiltinPreset(name string) bool {
+	for _, p := range builtinPresets {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// presetFromConfig captures the preset settings of c under name
+func presetFromConfig(name string, c Config) Preset {
+	return Preset{Name: name, Icon: "⭐", Settings: PresetSettings{
+		VideoEnabled:      c.VideoEnabled,
+		ImageEnabled:      c.ImageEnabled,
+		AudioEnabled:      c.AudioEnabled,
+		DocEnabled:        c.DocEnabled,
+		ArchiveEnabled:    c.ArchiveEnabled,
+		CustomExts:        c.CustomExts,
+		CompletionTimeout: c.CompletionTimeout,
+		MonitorSubdirs:    c.MonitorSubdirs,
+		GroupDepth:        c.GroupDepth,
+		PairSidecars:      c.PairSidecars,
+		ProbeVideo:        c.ProbeVideo,
+		VerifyArchives:    c.VerifyArchives,
+		VerifyChecksums:   c.VerifyChecksums,
+		PackEnabled:       c.PackEnabled,
+	}}
+}
+
+// applyPreset sets the settings of p. Other settings, like the watch path
+// and notifications, are left alone.
+func applyPreset(p Preset) {
+	applyPresetSettings(&config, p.Settings)
+	logEvent("已应用预设: %s", p.Name)
+}
+
+// applyPresetSettings copies the preset settings s into c
+func applyPresetSettings(c *Config, s PresetSettings) {
+	c.VideoEnabled = s.VideoEnabled
+	c.ImageEnabled = s.ImageEnabled
+	c.AudioEnabled = s.AudioEnabled
+	c.DocEnabled = s.DocEnabled
+	c.ArchiveEnabled = s.ArchiveEnabled
+	c.CustomExts = s.CustomExts
+	if s.CompletionTimeout > 0 {
+		c.CompletionTimeout = s.CompletionTimeout
+	}
+	c.MonitorSubdirs = s.MonitorSubdirs
+	c.GroupDepth = s.GroupDepth
+	c.PairSidecars = s.PairSidecars
+	c.ProbeVideo = s.ProbeVideo
+	c.VerifyArchives = s.VerifyArchives
+	c.VerifyChecksums = s.VerifyChecksums
+	c.PackEnabled = s.PackEnabled
+}
+
+// saveUserPreset adds p to the user presets, replacing one of the same name
+func saveUserPreset(p Preset) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return fmt.Errorf("请输入预设名称")
+	}
+	if isBuiltinPreset(p.Name) {
+		return fmt.Errorf("%q 是内置预设, 请换一个名称", p.Name)
+	}
+	for i := range config.Presets {
+		if config.Presets[i].Name == p.Name {
+			config.Presets[i] = p
+			return nil
+		}
+	}
+	config.Presets = append(config.Presets, p)
+	return nil
+}
+
+// deleteUserPreset removes the user preset named name
+func deleteUserPreset(name string) {
+	for i, p := range config.Presets {
+		if p.Name == name {
+			config.Presets = append(config.Presets[:i:i], config.Presets[i+1:]...)
+			return
+		}
+	}
+}
+
+// presetSummary lists what a preset sets, for the gallery
+func presetSummary(p Preset) string {
+	s := p.Settings
+	var types []string
+	for _, t := range []struct {
+		on   bool
+		name string
+	}{{s.VideoEnabled, "视频"}, {s.ImageEnabled, "图片"}, {s.AudioEnabled, "音频"}, {s.DocEnabled, "文档"}, {s.ArchiveEnabled, "压缩包"}} {
+		if t.on {
+			types = append(types, t.name)
+		}
+	}
+	if s.CustomExts != "" {
+		types = append(types, s.CustomExts)
+	}
+	parts := []string{strings.Join(types, " ")}
+	if s.CompletionTimeout > 0 {
+		parts = append(parts, fmt.Sprintf("超时 %d 秒", s.CompletionTimeout))
+	}
+	if s.MonitorSubdirs && s.GroupDepth > 0 {
+		parts = append(parts, fmt.Sprintf("按第 %d 层文件夹分组", s.GroupDepth))
+	} else if s.MonitorSubdirs {
+		parts = append(parts, "含子文件夹")
+	}
+	var actions []string
+	for _, a := range []struct {
+		on   bool
+		name string
+	}{{s.PairSidecars, "附属文件配对"}, {s.ProbeVideo, "视频信息"}, {s.VerifyArchives, "校验压缩包"}, {s.VerifyChecksums, "校验 MD5/SHA256"}, {s.PackEnabled, "打包 zip"}} {
+		if a.on {
+			actions = append(actions, a.name)
+		}
+	}
+	if len(actions) > 0 {
+		parts = append(parts, strings.Join(actions, "、"))
+	}
+	return strings.Join(parts, " · ")
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestApplyPreset(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config = defaultConfig()
+	config.WatchPath = "/in"
+
+	for _, p := range builtinPresets {
+		if p.Name == "摄影素材" {
+			applyPreset(p)
+		}
+	}
+	if !config.ImageEnabled || config.DocEnabled || config.GroupDepth != 1 || config.CompletionTimeout != 60 || !config.PairSidecars {
+		t.Errorf("preset not applied: %+v", presetFromConfig("", config).Settings)
+	}
+	if config.WatchPath != "/in" {
+		t.Error("preset changed the watch path")
+	}
+	if got := presetFromConfig("x", config).Settings; got != builtinPresets[1].Settings {
+		t.Errorf("captured %+v, want the applied preset", got)
+	}
+}
+
+func TestUserPresets(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config = defaultConfig()
+
+	if err := saveUserPreset(Preset{Name: "视频交付"}); err == nil {
+		t.Error("user preset may take a built-in name")
+	}
+	if err := saveUserPreset(Preset{Name: "  "}); err == nil {
+		t.Error("user preset without a name")
+	}
+	saveUserPreset(Preset{Name: "客户A", Settings: PresetSettings{CompletionTimeout: 45}})
+	saveUserPreset(Preset{Name: "客户B"})
+	saveUserPreset(Preset{Name: " 客户A ", Settings: PresetSettings{CompletionTimeout: 90}})
+	if len(config.Presets) != 2 || config.Presets[0].Settings.CompletionTimeout != 90 {
+		t.Fatalf("presets = %+v, want 客户A replaced", config.Presets)
+	}
+	if all := allPresets(); len(all) != len(builtinPresets)+2 || all[len(all)-1].Name != "客户B" {
+		t.Errorf("allPresets = %d presets", len(all))
+	}
+
+	data, err := encodeConfig(config, formatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	back, err := decodeConfig(data, formatYAML, defaultConfig())
+	if err != nil || len(back.Presets) != 2 || back.Presets[0].Settings.CompletionTimeout != 90 {
+		t.Errorf("YAML round trip: %v, %+v", err, back.Presets)
+	}
+
+	deleteUserPreset("客户A")
+	if len(config.Presets) != 1 || config.Presets[0].Name != "客户B" {
+		t.Errorf("after delete: %+v", config.Presets)
+	}
+}
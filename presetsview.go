@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showPresetsDialog lists the built-in and user presets. Applying one
+// saves the settings and calls applied so the settings page can show the
+// new values; the current settings can be saved as a user preset.
+func showPresetsDialog(save func() bool, applied func(), w fyne.Window) {
+	presets := allPresets()
+	var d *dialog.CustomDialog
+	var list *widget.List
+	list = widget.NewList(
+		func() int { return len(presets) },
+		func() fyne.CanvasObject {
+			title := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+			detail := widget.NewLabel("")
+			detail.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, nil,
+				container.NewHBox(widget.NewButton("应用", nil), widget.NewButton("🗑️", nil)),
+				container.NewVBox(title, detail))
+		},
+		nil,
+	)
+	list.UpdateItem = func(i widget.ListItemID, o fyne.CanvasObject) {
+		p := presets[i]
+		row := o.(*fyne.Container)
+		labels := row.Objects[0].(*fyne.Container).Objects
+		buttons := row.Objects[1].(*fyne.Container).Objects
+		title := p.Icon + " " + p.Name
+		if p.Description != "" {
+			title += " - " + p.Description
+		}
+		labels[0].(*widget.Label).SetText(title)
+		labels[1].(*widget.Label).SetText(presetSummary(p))
+		use, del := buttons[0].(*widget.Button), buttons[1].(*widget.Button)
+		use.OnTapped = func() {
+			dialog.ShowConfirm("应用预设", "用「"+p.Name+"」替换文件类型、完成判定、分组和完成后操作的设置?", func(ok bool) {
+				if !ok {
+					return
+				}
+				applyPreset(p)
+				save()
+				applied()
+				d.Hide()
+			}, w)
+		}
+		del.OnTapped = func() {
+			deleteUserPreset(p.Name)
+			save()
+			presets = allPresets()
+			list.Refresh()
+		}
+		if isBuiltinPreset(p.Name) {
+			del.Disable()
+		} else {
+			del.Enable()
+		}
+	}
+
+	nameEntry := widget.NewEntry()
+	nameEntry.SetPlaceHolder("预设名称")
+	saveBtn := widget.NewButton("⭐ 将当前设置保存为预设", func() {
+		if err := saveUserPreset(presetFromConfig(nameEntry.Text, config)); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		save()
+		nameEntry.SetText("")
+		presets = allPresets()
+		list.Refresh()
+	})
+	footer := container.NewBorder(nil, nil, nil, saveBtn, nameEntry)
+	hint := widget.NewLabel("预设一次设置文件类型、完成判定超时、分组方式和完成后的操作。监控文件夹和通知设置不变。保存为预设的是已保存的设置。")
+	hint.Wrapping = fyne.TextWrapWord
+	d = dialog.NewCustom("📚 预设", "关闭", container.NewBorder(hint, footer, nil, nil, list), w)
+	d.Resize(fyne.NewSize(680, 460))
+	d.Show()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/canvas"
+	"fyne.io/fyne/v2/theme"
+	"fyne.io/fyne/v2/widget"
+)
+
+// rateChart draws transfer rates over time as a line, scaled to the peak
+type rateChart struct {
+	widget.BaseWidget
+	points []ratePoint
+}
+
+// newRateChart returns a chart of points, oldest first
+func newRateChart(points []ratePoint) *rateChart {
+	c := &rateChart{points: points}
+	c.ExtendBaseWidget(c)
+	return c
+}
+
+// SetPoints replaces the plotted rates
+func (c *rateChart) SetPoints(points []ratePoint) {
+	c.points = points
+	c.Refresh()
+}
+
+func (c *rateChart) MinSize() fyne.Size {
+	return fyne.NewSize(200, 100)
+}
+
+func (c *rateChart) CreateRenderer() fyne.WidgetRenderer {
+	r := &rateChartRenderer{
+		chart: c,
+		frame: canvas.NewRectangle(color.Transparent),
+		peak:  canvas.NewText("", theme.Color(theme.ColorNamePlaceHolder)),
+		span:  canvas.NewText("", theme.Color(theme.ColorNamePlaceHolder)),
+	}
+	r.frame.StrokeColor = theme.Color(theme.ColorNameSeparator)
+	r.frame.StrokeWidth = 1
+	r.peak.TextSize = theme.CaptionTextSize()
+	r.span.TextSize = theme.CaptionTextSize()
+	r.update()
+	return r
+}
+
+type rateChartRenderer struct {
+	chart *rateChart
+	frame *canvas.Rectangle
+	peak  *canvas.Text
+	span  *canvas.Text
+	lines []*canvas.Line
+	max   float64 // peak rate, the top of the chart
+	size  fyne.Size
+}
+
+// update rebuilds the line segments and labels for the current points
+func (r *rateChartRenderer) update() {
+	points := r.chart.points
+	r.lines = nil
+	r.max = peakRate(points)
+	r.peak.Text = "峰值 " + formatRate(r.max)
+	r.span.Text = ""
+	if len(points) == 0 {
+		r.peak.Text = "暂无数据"
+		return
+	}
+	r.span.Text = formatClock(points[0].Time, true) + " ~ " + formatClock(points[len(points)-1].Time, true)
+	for i := 1; i < len(points); i++ {
+		l := canvas.NewLine(theme.Color(theme.ColorNamePrimary))
+		l.StrokeWidth = 1.5
+		r.lines = append(r.lines, l)
+	}
+}
+
+// layoutLines positions the segments within the current size
+func (r *rateChartRenderer) layoutLines() {
+	points := r.chart.points
+	if len(points) < 2 || r.size.Width <= 0 {
+		return
+	}
+	start, end := points[0].Time, points[len(points)-1].Time
+	span := end.Sub(start).Seconds()
+	if span <= 0 {
+		span = 1
+	}
+	peak := r.max
+	if peak <= 0 {
+		peak = 1
+	}
+	top := r.peak.MinSize().Height
+	height := r.size.Height - top - r.span.MinSize().Height
+	pos := func(p ratePoint) fyne.Position {
+		x := float32(p.Time.Sub(start).Seconds()/span) * r.size.Width
+		y := top + height - float32(p.Rate/peak)*height
+		return fyne.NewPos(x, y)
+	}
+	for i, l := range r.lines {
+		l.Position1 = pos(points[i])
+		l.Position2 = pos(points[i+1])
+	}
+}
+
+func (r *rateChartRenderer) Layout(size fyne.Size) {
+	r.size = size
+	r.frame.Resize(size)
+	r.peak.Move(fyne.NewPos(4, 0))
+	r.span.Move(fyne.NewPos(4, size.Height-r.span.MinSize().Height))
+	r.layoutLines()
+}
+
+func (r *rateChartRenderer) MinSize() fyne.Size {
+	return r.chart.MinSize()
+}
+
+func (r *rateChartRenderer) Refresh() {
+	r.update()
+	r.Layout(r.size)
+	canvas.Refresh(r.chart)
+}
+
+func (r *rateChartRenderer) Objects() []fyne.CanvasObject {
+	objects := []fyne.CanvasObject{r.frame, r.peak, r.span}
+	for _, l := range r.lines {
+		objects = append(objects, l)
+	}
+	return objects
+}
+
+func (r *rateChartRenderer) Destroy() {}
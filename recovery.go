@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// configBackupCount is how many previous configs are kept
+const configBackupCount = 3
+
+// configBackupPath is where the last known-good config is kept
+func configBackupPath() string {
+	return configPath + ".bak"
+}
+
+// configBackupPaths returns the backups from newest to oldest: config.json.bak,
+// config.json.bak.1, ...
+func configBackupPaths() []string {
+	paths := []string{configBackupPath()}
+	for i := 1; i < configBackupCount; i++ {
+		paths = append(paths, fmt.Sprintf("%s.%d", configBackupPath(), i))
+	}
+	return paths
+}
+
+// backupConfig copies the current config file to the newest backup, moving
+// older ones down the line. Only a file that still parses is backed up, so a
+// corrupted file never pushes out a good backup.
+func backupConfig() error {
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil
+	}
+	c, err := decodeConfig(data, configFormat(configPath), Config{})
+	if err != nil {
+		return nil
+	}
+	// A file from before secrets were stored keeps no plain copy behind
+	if sealed := referenceSecrets(c); !reflect.DeepEqual(sealed, c) {
+		if encoded, err := encodeConfig(sealed, configFormat(configPath)); err == nil {
+			data = encoded
+		}
+	}
+	paths := configBackupPaths()
+	for i := len(paths) - 1; i > 0; i-- {
+		os.Rename(paths[i-1], paths[i])
+	}
+	return writeFileAtomic(paths[0], data, 0644)
+}
+
+// latestConfigBackup returns the newest backup that still parses
+func latestConfigBackup() (Config, error) {
+	err := errors.New("没有可用的备份")
+	for _, p := range configBackupPaths() {
+		data, readErr := os.ReadFile(p)
+		if readErr != nil {
+			continue
+		}
+		c, decodeErr := decodeConfig(data, configFormat(configPath), defaultConfig())
+		if decodeErr == nil {
+			return c, nil
+		}
+		err = fmt.Errorf("备份文件也已损坏: %v", decodeErr)
+	}
+	return Config{}, err
+}
+
+// hasConfigBackup reports whether a readable backup exists
+func hasConfigBackup() bool {
+	_, err := latestConfigBackup()
+	return err == nil
+}
+
+// setAsideCorruptedConfig renames the broken config file so it is kept for
+// inspection instead of being overwritten
+func setAsideCorruptedConfig() {
+	if _, err := os.Stat(configPath); err != nil {
+		return
+	}
+	os.Rename(configPath, corruptCopyPath(configPath))
+}
+
+// corruptCopyPath names the copy a damaged file is set aside as
+func corruptCopyPath(path string) string {
+	return fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102-150405"))
+}
+
+// restoreConfigBackup replaces the corrupted config with the last backup
+func restoreConfigBackup() error {
+	restored, err := latestConfigBackup()
+	if err != nil {
+		return err
+	}
+	setAsideCorruptedConfig()
+	for _, problem := range openSecrets(&restored) {
+		logEvent("恢复配置: %s", problem)
+	}
+	config = restored
+	return saveConfig()
+}
+
+// resetConfigToDefaults replaces the corrupted config with default settings
+func resetConfigToDefaults() error {
+	setAsideCorruptedConfig()
+	config = defaultConfig()
+	return saveConfig()
+}
+
+// openFileLocation reveals path in the platform file manager
+func openFileLocation(path string) error {
+	switch runtime.GOOS {
+	case "windows":
+		return exec.Command("explorer", "/select,", path).Start()
+	case "darwin":
+		return exec.Command("open", "-R", path).Start()
+	default:
+		return exec.Command("xdg-open", filepath.Dir(path)).Start()
+	}
+}
+
+// showConfigRecoveryDialog explains that config.json could not be read and
+// lets the user restore the backup, reset to defaults or inspect the file,
+// instead of silently running on defaults
+func showConfigRecoveryDialog(loadErr error, w fyne.Window) {
+	msg := widget.NewLabel(fmt.Sprintf(
+		"配置文件无法读取，当前使用默认设置运行：\n%s\n\n错误: %v", configPath, loadErr))
+	msg.Wrapping = fyne.TextWrapWord
+
+	var d dialog.Dialog
+	done := func(text string) {
+		d.Hide()
+		dialog.ShowInformation("配置已恢复", text+"\n设置页面将在重启应用后刷新。", w)
+	}
+
+	restoreBtn := widget.NewButton("♻️ 从上次备份恢复", func() {
+		if err := restoreConfigBackup(); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		done("已从备份恢复设置。")
+	})
+	if !hasConfigBackup() {
+		restoreBtn.Disable()
+	}
+
+	resetBtn := widget.NewButton("🔄 恢复默认设置", func() {
+		if err := resetConfigToDefaults(); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		done("已恢复默认设置，损坏的文件已另存。")
+	})
+
+	openBtn := widget.NewButton("📂 打开文件位置", func() {
+		if err := openFileLocation(configPath); err != nil {
+			dialog.ShowError(err, w)
+		}
+	})
+
+	content := container.NewVBox(msg, widget.NewSeparator(), restoreBtn, resetBtn, openBtn)
+	d = dialog.NewCustom("配置文件已损坏", "稍后处理", content, w)
+	d.Resize(fyne.NewSize(400, 300))
+	d.Show()
+}
+
+var (
+	historyLoadErr     error  // set when the history could not be read in full at startup
+	historyCorruptPath string // where the damaged history was set aside, if it was
+)
+
+// recoverHistory checks the history before anything rewrites it. A history
+// file with unreadable lines, or a database that does not open, is set
+// aside as .corrupt-<time> and history starts over with the records that
+// could still be read.
+func recoverHistory() error {
+	if useDatabase() {
+		return recoverDatabase()
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	records, badLines, err := readHistory()
+	if err == nil && badLines == 0 {
+		return nil
+	}
+	if err == nil {
+		err = fmt.Errorf("%d 行无法解析", badLines)
+	}
+	corruptPath := corruptCopyPath(historyPath)
+	if renameErr := os.Rename(historyPath, corruptPath); renameErr != nil {
+		return fmt.Errorf("%v; 无法另存损坏的文件: %v", err, renameErr)
+	}
+	historyCorruptPath = corruptPath
+	var buf bytes.Buffer
+	for i := len(records) - 1; i >= 0; i-- { // oldest first, as appended
+		data, marshalErr := json.Marshal(records[i])
+		if marshalErr != nil {
+			continue
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	if writeErr := writeFileAtomic(historyPath, buf.Bytes(), 0644); writeErr != nil {
+		return fmt.Errorf("%v; 无法写入新的历史记录: %v", err, writeErr)
+	}
+	return fmt.Errorf("%v，已保留 %d 条可读的记录", err, len(records))
+}
+
+// recoverDatabase opens and checks the history database, replacing a
+// damaged one with a fresh database
+func recoverDatabase() error {
+	db, err := openDatabase()
+	if err == nil {
+		var result string
+		if err = db.QueryRow(`PRAGMA quick_check`).Scan(&result); err == nil && result != "ok" {
+			err = errors.New(result)
+		}
+	}
+	if err == nil {
+		return databaseImportErr
+	}
+	if _, statErr := os.Stat(databasePath); statErr != nil {
+		return err // nothing to set aside, e.g. the folder is not writable
+	}
+	closeDatabase()
+	corruptPath := corruptCopyPath(databasePath)
+	if renameErr := os.Rename(databasePath, corruptPath); renameErr != nil {
+		return fmt.Errorf("%v; 无法另存损坏的数据库: %v", err, renameErr)
+	}
+	// The journal belongs to the damaged file
+	for _, suffix := range []string{"-wal", "-shm"} {
+		os.Rename(databasePath+suffix, corruptPath+suffix)
+	}
+	historyCorruptPath = corruptPath
+	if _, openErr := openDatabase(); openErr != nil {
+		return fmt.Errorf("%v; 无法新建数据库: %v", err, openErr)
+	}
+	return fmt.Errorf("数据库已损坏: %v", err)
+}
+
+// showHistoryRecoveryDialog tells the user the history could not be read
+// in full and where the damaged copy was kept
+func showHistoryRecoveryDialog(loadErr error, w fyne.Window) {
+	text := fmt.Sprintf("历史记录无法完整读取。\n\n错误: %v", loadErr)
+	path := historyPath
+	if useDatabase() {
+		path = databasePath
+	}
+	if historyCorruptPath != "" {
+		text = fmt.Sprintf("历史记录无法完整读取，已用可读的记录重新开始。\n损坏的文件已另存为：\n%s\n\n错误: %v", historyCorruptPath, loadErr)
+		path = historyCorruptPath
+	}
+	msg := widget.NewLabel(text)
+	msg.Wrapping = fyne.TextWrapWord
+
+	openBtn := widget.NewButton("📂 打开文件位置", func() {
+		if err := openFileLocation(path); err != nil {
+			dialog.ShowError(err, w)
+		}
+	})
+
+	d := dialog.NewCustom("历史记录已损坏", "知道了", container.NewVBox(msg, widget.NewSeparator(), openBtn), w)
+	d.Resize(fyne.NewSize(400, 260))
+	d.Show()
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigCorrupted(t *testing.T) {
+	tmpDir := t.TempDir()
+	origConfig := config
+	origConfigPath := configPath
+	defer func() {
+		config = origConfig
+		configPath = origConfigPath
+	}()
+	configPath = filepath.Join(tmpDir, "config.json")
+
+	config = defaultConfig()
+	config.CompletionTimeout = 99
+	saveConfig()
+	// The second save backs up the first one
+	config.CompletionTimeout = 120
+	saveConfig()
+
+	os.WriteFile(configPath, []byte("{not json"), 0644)
+	config = defaultConfig()
+	if err := loadConfig(); err == nil {
+		t.Fatal("Expected error loading corrupted config")
+	}
+	if config.CompletionTimeout != defaultConfig().CompletionTimeout {
+		t.Errorf("Corrupted load changed CompletionTimeout to %d", config.CompletionTimeout)
+	}
+
+	if !hasConfigBackup() {
+		t.Fatal("Expected a usable backup")
+	}
+	if err := restoreConfigBackup(); err != nil {
+		t.Fatalf("restoreConfigBackup() error: %v", err)
+	}
+	if config.CompletionTimeout != 99 {
+		t.Errorf("Restored CompletionTimeout = %d, want 99", config.CompletionTimeout)
+	}
+	if err := loadConfig(); err != nil {
+		t.Errorf("Restored config does not load: %v", err)
+	}
+
+	matches, _ := filepath.Glob(configPath + ".corrupt-*")
+	if len(matches) != 1 {
+		t.Errorf("Expected corrupted file to be set aside, found %v", matches)
+	}
+}
+
+func TestResetConfigToDefaults(t *testing.T) {
+	tmpDir := t.TempDir()
+	origConfig := config
+	origConfigPath := configPath
+	defer func() {
+		config = origConfig
+		configPath = origConfigPath
+	}()
+	configPath = filepath.Join(tmpDir, "config.json")
+
+	os.WriteFile(configPath, []byte("garbage"), 0644)
+	config.CompletionTimeout = 5
+	resetConfigToDefaults()
+
+	if config.CompletionTimeout != defaultConfig().CompletionTimeout {
+		t.Errorf("CompletionTimeout = %d, want default", config.CompletionTimeout)
+	}
+	if err := loadConfig(); err != nil {
+		t.Errorf("Reset config does not load: %v", err)
+	}
+}
+
+func TestConfigBackupRotation(t *testing.T) {
+	tmpDir := t.TempDir()
+	origConfig, origConfigPath := config, configPath
+	defer func() { config, configPath = origConfig, origConfigPath }()
+	configPath = filepath.Join(tmpDir, "config.json")
+
+	config = defaultConfig()
+	for _, timeout := range []int{40, 50, 60, 70, 80} {
+		config.CompletionTimeout = timeout
+		if err := saveConfig(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Saving unchanged settings does not push out older backups
+	saveConfig()
+
+	for i, want := range []int{70, 60, 50} {
+		data, err := os.ReadFile(configBackupPaths()[i])
+		if err != nil {
+			t.Fatalf("Backup %d missing: %v", i, err)
+		}
+		c, _ := decodeConfig(data, formatJSON, Config{})
+		if c.CompletionTimeout != want {
+			t.Errorf("Backup %d has timeout %d, want %d", i, c.CompletionTimeout, want)
+		}
+	}
+	if _, err := os.Stat(configBackupPath() + "." + "3"); err == nil {
+		t.Error("Kept more backups than configBackupCount")
+	}
+
+	// A corrupted newest backup falls back to the next one
+	os.WriteFile(configBackupPath(), []byte("{"), 0644)
+	os.WriteFile(configPath, []byte("{"), 0644)
+	if err := restoreConfigBackup(); err != nil || config.CompletionTimeout != 60 {
+		t.Errorf("Restored timeout %d, err %v; want 60", config.CompletionTimeout, err)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(tmpDir, ".config.json.tmp-*"))
+	if len(matches) > 0 {
+		t.Errorf("Temp files left behind: %v", matches)
+	}
+}
+
+func TestSaveConfigReportsErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	origConfig, origConfigPath := config, configPath
+	defer func() { config, configPath = origConfig, origConfigPath }()
+
+	// The config folder cannot be created where a file is in the way
+	blocker := filepath.Join(tmpDir, "blocker")
+	os.WriteFile(blocker, []byte("x"), 0644)
+	configPath = filepath.Join(blocker, "config.json")
+	config = defaultConfig()
+	if err := saveConfig(); err == nil {
+		t.Error("Expected an error saving below a file")
+	}
+
+	configPath = filepath.Join(tmpDir, "config.json")
+	config.CompletionTimeout = 45
+	saveConfig()
+	if err := writeFileAtomic(filepath.Join(tmpDir, "missing", "config.json"), []byte("{}"), 0644); err == nil {
+		t.Error("Expected an error writing into a missing folder")
+	}
+	config = defaultConfig()
+	if err := loadConfig(); err != nil || config.CompletionTimeout != 45 {
+		t.Errorf("Config after failed writes: timeout %d, err %v", config.CompletionTimeout, err)
+	}
+}
+
+func TestRecoverHistory(t *testing.T) {
+	origConfig, origHistory, origCorrupt := config, historyPath, historyCorruptPath
+	defer func() { config, historyPath, historyCorruptPath = origConfig, origHistory, origCorrupt }()
+	historyPath = filepath.Join(t.TempDir(), "history.jsonl")
+	config = Config{SaveHistory: true}
+
+	if err := recoverHistory(); err != nil {
+		t.Fatalf("missing history: %v", err)
+	}
+	recordHistory(&Batch{ID: "1", Folder: "/up/a", Status: "completed"})
+	if err := recoverHistory(); err != nil {
+		t.Fatalf("healthy history: %v", err)
+	}
+
+	f, _ := os.OpenFile(historyPath, os.O_APPEND|os.O_WRONLY, 0644)
+	f.WriteString("garbage\n")
+	f.Close()
+	recordHistory(&Batch{ID: "2", Folder: "/up/b", Status: "completed"})
+	if err := recoverHistory(); err == nil {
+		t.Fatal("damaged history passed")
+	}
+	if _, err := os.Stat(historyCorruptPath); err != nil {
+		t.Errorf("damaged file not set aside: %v", err)
+	}
+	records, bad, err := loadHistory()
+	if err != nil || bad != 0 || len(records) != 2 {
+		t.Errorf("after recovery: %d records, %d bad lines, %v", len(records), bad, err)
+	}
+}
+
+func TestRecoverDatabase(t *testing.T) {
+	origConfig, origHistory, origDB, origCorrupt := config, historyPath, databasePath, historyCorruptPath
+	defer func() {
+		closeDatabase()
+		config, historyPath, databasePath, historyCorruptPath = origConfig, origHistory, origDB, origCorrupt
+	}()
+	dir := t.TempDir()
+	historyPath = filepath.Join(dir, "history.jsonl")
+	databasePath = filepath.Join(dir, "fidruawatch.db")
+	config = defaultConfig()
+	config.StorageEngine = storageSQLite
+
+	os.WriteFile(databasePath, []byte("this is not a database, just some text long enough"), 0644)
+	if err := recoverHistory(); err == nil {
+		t.Fatal("damaged database passed")
+	}
+	if _, err := os.Stat(historyCorruptPath); err != nil {
+		t.Errorf("damaged database not set aside: %v", err)
+	}
+	if err := dbAppendHistory(HistoryRecord{ID: "1", Folder: "/up/a", Status: "completed"}); err != nil {
+		t.Fatalf("fresh database: %v", err)
+	}
+	if err := recoverHistory(); err != nil {
+		t.Errorf("fresh database: %v", err)
+	}
+}
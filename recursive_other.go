@@ -0,0 +1,9 @@
+//go:build !windows && (!darwin || !cgo)
+
+package main
+
+// newRecursiveWatcher returns nil: directories are added to fsnotify one by
+// one on this platform
+func newRecursiveWatcher() fsWatcher {
+	return nil
+}
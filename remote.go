@@ -0,0 +1,382 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Prefixes of watch paths that name a remote directory
+const (
+	sftpScheme   = "sftp:"
+	webdavScheme = "webdav:"
+	cloudScheme  = "cloud:"
+)
+
+// maxRemoteDepth bounds how deep a remote listing descends
+const maxRemoteDepth = 32
+
+// isRemoteWatchPath reports whether p names a remote directory, or the
+// simulator, rather than a local folder. Batches of a remote watch have such
+// paths as their folder.
+func isRemoteWatchPath(p string) bool {
+	for _, scheme := range []string{sftpScheme, webdavScheme, cloudScheme, simScheme} {
+		if strings.HasPrefix(p, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// sftpWatchPath names an SFTP directory as a watch path in scp style with a
+// scheme, e.g. "sftp:ingest@nas:/uploads". The form survives filepath.Clean,
+// so batch folders and files below it are ordinary paths to the batch logic.
+func sftpWatchPath(host, dir string) string {
+	if dir == "" {
+		dir = "."
+	}
+	return sftpScheme + host + ":" + path.Clean(dir)
+}
+
+// remoteInterval returns how often remote directories are listed
+func remoteInterval() time.Duration {
+	if config.RemoteInterval < 5 {
+		return 30 * time.Second
+	}
+	return time.Duration(config.RemoteInterval) * time.Second
+}
+
+// remoteEntry is a file in a remote listing. ETag is empty when the source
+// has none.
+type remoteEntry struct {
+	Size int64
+	ETag string
+}
+
+// sftpLister lists a remote directory tree over SFTP, so the server only
+// has to allow SFTP, not run commands
+type sftpLister struct {
+	Host string // user@host, the local user when no user is given
+	Port int    // 0 = 22
+	Key  string // private key file, empty = ~/.ssh default keys and agent
+	Dir  string
+}
+
+// sftpDialTimeout bounds connecting and the SSH handshake
+const sftpDialTimeout = 15 * time.Second
+
+// sshUserHost splits user@host, defaulting to the local user name
+func sshUserHost(s string) (login, host string) {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return s[:i], strings.Trim(s[i+1:], "[]")
+	}
+	if u, err := user.Current(); err == nil {
+		// Windows names are DOMAIN\user
+		login = u.Username[strings.LastIndex(u.Username, `\`)+1:]
+	}
+	return login, strings.Trim(s, "[]")
+}
+
+// sshSigners loads the configured key, or whichever default keys exist.
+// Keys needing a passphrase are left to the agent.
+func sshSigners(keyFile string) ([]ssh.Signer, error) {
+	homeDir, _ := os.UserHomeDir()
+	files := []string{keyFile}
+	if rest, ok := strings.CutPrefix(keyFile, "~/"); ok && homeDir != "" {
+		// As ssh does for -i
+		files[0] = filepath.Join(homeDir, filepath.FromSlash(rest))
+	}
+	if keyFile == "" {
+		files = nil
+		if homeDir != "" {
+			for _, name := range []string{"id_ed25519", "id_ecdsa", "id_rsa"} {
+				files = append(files, filepath.Join(homeDir, ".ssh", name))
+			}
+		}
+	}
+	var signers []ssh.Signer
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			if keyFile != "" {
+				return nil, err
+			}
+			continue
+		}
+		signer, err := ssh.ParsePrivateKey(data)
+		var missing *ssh.PassphraseMissingError
+		switch {
+		case errors.As(err, &missing):
+			continue
+		case err != nil && keyFile != "":
+			return nil, fmt.Errorf("无法读取私钥 %s: %w", f, err)
+		case err == nil:
+			signers = append(signers, signer)
+		}
+	}
+	return signers, nil
+}
+
+// anyHostKey stands in for the server's key to ask known_hosts which keys
+// it holds for a host
+type anyHostKey struct{}
+
+func (anyHostKey) Type() string                                 { return "" }
+func (anyHostKey) Marshal() []byte                              { return nil }
+func (anyHostKey) Verify(data []byte, sig *ssh.Signature) error { return errors.New("not a key") }
+
+// knownHostKeyAlgorithms returns the algorithms of the keys known_hosts
+// holds for addr, so the server is asked for one of those rather than one
+// that would not match
+func knownHostKeyAlgorithms(hostKeys ssh.HostKeyCallback, addr string) []string {
+	var keyErr *knownhosts.KeyError
+	if err := hostKeys(addr, &net.TCPAddr{}, anyHostKey{}); !errors.As(err, &keyErr) {
+		return nil
+	}
+	var algos []string
+	for _, k := range keyErr.Want {
+		if k.Key.Type() == ssh.KeyAlgoRSA {
+			algos = append(algos, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256)
+		}
+		algos = append(algos, k.Key.Type())
+	}
+	return algos
+}
+
+// dial connects and authenticates with the key and the agent. Like ssh in
+// batch mode, the host key must already be in ~/.ssh/known_hosts.
+func (l sftpLister) dial(ctx context.Context) (*ssh.Client, error) {
+	login, host := sshUserHost(l.Host)
+	port := l.Port
+	if port == 0 {
+		port = 22
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	hostKeys, err := knownhosts.New(filepath.Join(homeDir, ".ssh", "known_hosts"))
+	if err != nil {
+		return nil, fmt.Errorf("无法读取 known_hosts: %w", err)
+	}
+	signers, err := sshSigners(l.Key)
+	if err != nil {
+		return nil, err
+	}
+	var auth []ssh.AuthMethod
+	if len(signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(signers...))
+	}
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if agentConn, err := net.Dial("unix", sock); err == nil {
+			defer agentConn.Close()
+			auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(agentConn).Signers))
+		}
+	}
+	cfg := &ssh.ClientConfig{
+		User:              login,
+		Auth:              auth,
+		HostKeyCallback:   hostKeys,
+		HostKeyAlgorithms: knownHostKeyAlgorithms(hostKeys, addr),
+		Timeout:           sftpDialTimeout,
+	}
+
+	d := net.Dialer{Timeout: sftpDialTimeout}
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(sftpDialTimeout))
+	c, chans, reqs, err := ssh.NewClientConn(conn, addr, cfg)
+	if err != nil {
+		conn.Close()
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("%s 不在 known_hosts 中, 请先用 ssh 连接一次确认主机密钥", host)
+		}
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// List returns the files below Dir, keyed by slash-separated path relative
+// to Dir. Each listing uses its own connection.
+func (l sftpLister) List(ctx context.Context) (map[string]remoteEntry, error) {
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// Cancelling ends a listing in progress
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+	return listSFTP(ctx, client, l.Dir, config.MonitorSubdirs)
+}
+
+// listSFTP returns the regular files below dir, keyed by slash-separated
+// path relative to dir, and those of its subfolders when subdirs is set
+func listSFTP(ctx context.Context, c *sftp.Client, dir string, subdirs bool) (map[string]remoteEntry, error) {
+	if dir == "" {
+		dir = "."
+	}
+	files := make(map[string]remoteEntry)
+	level := []string{""}
+	for depth := 0; len(level) > 0 && depth < maxRemoteDepth; depth++ {
+		var next []string
+		for _, rel := range level {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			entries, err := c.ReadDir(path.Join(dir, rel))
+			if err != nil {
+				// A subfolder may vanish between listings
+				if rel != "" && errors.Is(err, os.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+			for _, fi := range entries {
+				name := path.Join(rel, fi.Name())
+				switch {
+				case fi.Mode().IsRegular():
+					files[name] = remoteEntry{Size: fi.Size()}
+				case fi.IsDir():
+					next = append(next, name)
+				}
+			}
+		}
+		if !subdirs {
+			break
+		}
+		level = next
+	}
+	return files, nil
+}
+
+// remoteChange is a remote file that appeared or grew
+type remoteChange struct {
+	Path string // below the watch path, in local path form
+	Size int64
+}
+
+// remoteLister lists the files below a remote directory
+type remoteLister func(ctx context.Context) (map[string]remoteEntry, error)
+
+// remoteWatch diffs successive listings of a remote directory the way
+// dirPoller diffs local ones
+type remoteWatch struct {
+	root   string
+	list   remoteLister
+	seen   map[string]remoteEntry
+	primed bool
+}
+
+func newRemoteWatch(root string, list remoteLister) *remoteWatch {
+	return &remoteWatch{root: root, list: list, seen: make(map[string]remoteEntry)}
+}
+
+// Poll lists the remote directory once and returns the files that appeared,
+// grew or got a new ETag since the last listing. The first listing only
+// records what is already there, matching local watches which only see
+// changes.
+func (r *remoteWatch) Poll(ctx context.Context) ([]remoteChange, error) {
+	files, err := r.list(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var changed []remoteChange
+	for name, e := range files {
+		old, known := r.seen[name]
+		r.seen[name] = e
+		if !r.primed || (known && e.Size <= old.Size && e.ETag == old.ETag) {
+			continue
+		}
+		changed = append(changed, remoteChange{
+			Path: filepath.Join(r.root, filepath.FromSlash(name)),
+			Size: e.Size,
+		})
+	}
+	for name := range r.seen {
+		if _, ok := files[name]; !ok {
+			delete(r.seen, name)
+		}
+	}
+	r.primed = true
+	sort.Slice(changed, func(i, j int) bool { return changed[i].Path < changed[j].Path })
+	return changed, nil
+}
+
+// Run polls every interval until ctx is cancelled, passing changes to
+// ingest. Failures are logged when they start and when they clear.
+func (r *remoteWatch) Run(ctx context.Context, interval time.Duration, ingest func(remoteChange)) {
+	failing := false
+	for {
+		changes, err := r.Poll(ctx)
+		switch {
+		case err != nil && ctx.Err() == nil && !failing:
+			logEvent("远程列表失败 %s: %v", r.root, err)
+			failing = true
+		case err == nil && failing:
+			logEvent("远程列表恢复: %s", r.root)
+			failing = false
+		}
+		for _, c := range changes {
+			ingest(c)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(ecoInterval(interval)):
+		}
+	}
+}
+
+// startRemoteWatch watches the configured remote directory, feeding new and
+// changing files into the same batch and completion logic as local folders
+func startRemoteWatch(ctx context.Context, updateUI func(), app fyne.App) error {
+	ingest := func(c remoteChange) {
+		if tool := detectUploader(c.Path); tool != "" {
+			noteUploader(c.Path, tool)
+		}
+		if isMonitoredFile(c.Path) {
+			announceIngest(addObservedFile(c.Path, c.Size), c.Path, updateUI, app)
+		}
+	}
+	var list remoteLister
+	switch {
+	case strings.HasPrefix(monitorPath, cloudScheme):
+		return startCloudWatch(ctx, ingest)
+	case strings.HasPrefix(monitorPath, simScheme):
+		go runSimulation(ctx, monitorPath, simOptionsFromConfig(), ingest)
+		return nil
+	case strings.HasPrefix(monitorPath, webdavScheme):
+		list = webdavLister{URL: config.WebDAVURL, User: config.WebDAVUser, Password: config.WebDAVPass}.List
+	default:
+		list = sftpLister{Host: config.SFTPHost, Port: config.SFTPPort, Key: config.SFTPKey, Dir: config.SFTPDir}.List
+	}
+	go newRemoteWatch(monitorPath, list).Run(ctx, remoteInterval(), ingest)
+	return nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestSFTPWatchPath(t *testing.T) {
+	tests := []struct {
+		host, dir, expected string
+	}{
+		{"ingest@nas", "/uploads/", "sftp:ingest@nas:/uploads"},
+		{"nas", "", "sftp:nas:."},
+		{"nas", "in/../drop", "sftp:nas:drop"},
+	}
+	for _, tt := range tests {
+		p := sftpWatchPath(tt.host, tt.dir)
+		if p != tt.expected {
+			t.Errorf("sftpWatchPath(%q, %q) = %q, want %q", tt.host, tt.dir, p, tt.expected)
+		}
+		if !isRemoteWatchPath(p) {
+			t.Errorf("isRemoteWatchPath(%q) = false", p)
+		}
+		if filepath.Clean(p) != p && filepath.Separator == '/' {
+			t.Errorf("Watch path %q changes under filepath.Clean", p)
+		}
+	}
+	if isRemoteWatchPath("/home/me/uploads") {
+		t.Error("Local path reported as remote")
+	}
+}
+
+func TestSSHUserHost(t *testing.T) {
+	if login, host := sshUserHost("ingest@[fd00::5]"); login != "ingest" || host != "fd00::5" {
+		t.Errorf("sshUserHost = %q, %q", login, host)
+	}
+	if login, host := sshUserHost("nas"); login == "" || host != "nas" {
+		t.Errorf("sshUserHost without user = %q, %q, want the local user", login, host)
+	}
+}
+
+// startTestSFTPServer serves an in-memory SFTP tree over SSH on localhost to
+// clients holding key, and returns its address and host key
+func startTestSFTPServer(t *testing.T, key ssh.PublicKey) (string, ssh.PublicKey) {
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(meta ssh.ConnMetadata, k ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(k.Marshal(), key.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+	handlers := sftp.InMemHandler()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			nc, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nc, cfg)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for nch := range chans {
+					ch, chReqs, err := nch.Accept()
+					if err != nil {
+						continue
+					}
+					go func() {
+						for req := range chReqs {
+							ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+							req.Reply(ok, nil)
+							if ok {
+								go func() {
+									sftp.NewRequestServer(ch, handlers).Serve()
+									ch.Close()
+								}()
+							}
+						}
+					}()
+				}
+			}()
+		}
+	}()
+	return l.Addr().String(), hostSigner.PublicKey()
+}
+
+func TestSFTPListerList(t *testing.T) {
+	origConfig := config
+	defer func() { config = origConfig }()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	clientKey, _ := ssh.NewPublicKey(pub)
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(home, "upload_key")
+	os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600)
+	addr, hostKey := startTestSFTPServer(t, clientKey)
+	host, portText, _ := net.SplitHostPort(addr)
+	port, _ := strconv.Atoi(portText)
+	l := sftpLister{Host: "ingest@" + host, Port: port, Key: "~/upload_key", Dir: "/uploads"}
+	ctx := context.Background()
+
+	// Unknown hosts are refused, like ssh in batch mode
+	os.MkdirAll(filepath.Join(home, ".ssh"), 0700)
+	knownHosts := filepath.Join(home, ".ssh", "known_hosts")
+	os.WriteFile(knownHosts, nil, 0600)
+	if _, err := l.List(ctx); err == nil || !strings.Contains(err.Error(), "known_hosts") {
+		t.Fatalf("List of an unknown host: %v", err)
+	}
+	os.WriteFile(knownHosts, []byte(knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)+"\n"), 0600)
+
+	conn, err := l.dial(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := sftp.NewClient(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"/uploads", "/uploads/Day 1"} {
+		if err := c.Mkdir(dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, data := range map[string]string{"/uploads/clip.mp4": "0123456789", "/uploads/Day 1/my notes.txt": "notes"} {
+		f, err := c.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(data))
+		f.Close()
+	}
+	c.Close()
+	conn.Close()
+
+	config = Config{}
+	files, err := l.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]remoteEntry{"clip.mp4": {Size: 10}}; !reflect.DeepEqual(files, want) {
+		t.Errorf("List = %v, want %v", files, want)
+	}
+	config.MonitorSubdirs = true
+	files, err = l.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]remoteEntry{"clip.mp4": {Size: 10}, "Day 1/my notes.txt": {Size: 5}}; !reflect.DeepEqual(files, want) {
+		t.Errorf("List with subfolders = %v, want %v", files, want)
+	}
+}
+
+func TestRemoteWatchPoll(t *testing.T) {
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{VideoEnabled: true}
+
+	listing := map[string]remoteEntry{"old.mp4": {Size: 10}}
+	list := func(ctx context.Context) (map[string]remoteEntry, error) {
+		out := make(map[string]remoteEntry, len(listing))
+		for k, v := range listing {
+			out[k] = v
+		}
+		return out, nil
+	}
+	root := sftpWatchPath("nas", "/uploads")
+	rw := newRemoteWatch(root, list)
+
+	if changed, err := rw.Poll(context.Background()); err != nil || len(changed) != 0 {
+		t.Fatalf("First poll should only record a baseline, got %v, %v", changed, err)
+	}
+
+	listing["old.mp4"] = remoteEntry{Size: 20}
+	listing["cam/new.mp4"] = remoteEntry{Size: 5}
+	changed, err := rw.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changed) != 2 {
+		t.Fatalf("Expected 2 changes, got %v", changed)
+	}
+	if want := filepath.Join(root, "cam", "new.mp4"); changed[0].Path != want || changed[0].Size != 5 {
+		t.Errorf("changed[0] = %+v, want %s", changed[0], want)
+	}
+
+	if changed, _ := rw.Poll(context.Background()); len(changed) != 0 {
+		t.Errorf("Unchanged listing reported %v", changed)
+	}
+
+	// A rewritten file of the same size is caught by its ETag
+	listing["old.mp4"] = remoteEntry{Size: 20, ETag: "v2"}
+	if changed, _ := rw.Poll(context.Background()); len(changed) != 1 {
+		t.Errorf("Expected ETag change to be reported, got %v", changed)
+	}
+}
+
+func TestRemoteFilesFormBatches(t *testing.T) {
+	origConfig, origBatches, origMonitorPath := config, batches, monitorPath
+	defer func() {
+		config, batches, monitorPath = origConfig, origBatches, origMonitorPath
+	}()
+	config = Config{VideoEnabled: true, MonitorSubdirs: true, ZeroByteMode: zeroByteHold}
+	batches = make(map[string]*Batch)
+	monitorPath = sftpWatchPath("ingest@nas", "/uploads")
+
+	path := filepath.Join(monitorPath, "cam", "a.mp4")
+	if !addObservedFile(path, 0) {
+		t.Fatal("Expected a new batch")
+	}
+	addObservedFile(filepath.Join(monitorPath, "cam", "b.mp4"), 100)
+
+	if len(batches) != 1 {
+		t.Fatalf("Expected 1 batch, got %d", len(batches))
+	}
+	for _, b := range batches {
+		if b.Folder != filepath.Join(monitorPath, "cam") || len(b.Files) != 2 {
+			t.Errorf("Unexpected batch %s %v", b.Folder, b.Files)
+		}
+		if displayFolder(b.Folder) != "cam" {
+			t.Errorf("displayFolder = %q", displayFolder(b.Folder))
+		}
+		// Empty remote files hold completion without being dropped by a local stat
+		if !holdForZeroByte(b) || len(b.Files) != 2 {
+			t.Errorf("Expected remote zero-byte file to hold completion, files %v", b.Files)
+		}
+	}
+}
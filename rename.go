@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+// renameFields are the values available to rename templates
+type renameFields struct {
+	Date     string // batch start date, 2006-01-02
+	Time     string // batch start time, 150405
+	Folder   string // batch folder name
+	Category string // 视频, 图片, 音频, 文档, 压缩包 or 其他
+	Index    int    // 1-based position of the file in the batch
+	Name     string // original file name without extension
+	Ext      string // original extension with dot
+	Dir      string // original subfolder, "." for the batch root
+}
+
+// renameOp moves one file, both paths relative to the batch folder
+type renameOp struct {
+	From string
+	To   string
+}
+
+// validRenameTemplate reports whether t parses as a rename template
+func validRenameTemplate(t string) bool {
+	_, err := template.New("rename").Parse(t)
+	return err == nil
+}
+
+// planRename renders the template for every file of a batch, without
+// touching the disk. It fails when a result leaves the batch folder or two
+// files would end up with the same name. Caller must hold batchesMu.
+func planRename(b *Batch, tmplText string) ([]renameOp, error) {
+	tmpl, err := template.New("rename").Option("missingkey=error").Parse(tmplText)
+	if err != nil {
+		return nil, fmt.Errorf("模板错误: %v", err)
+	}
+
+	files := append([]string(nil), b.Files...)
+	sort.Strings(files)
+	ops := make([]renameOp, 0, len(files))
+	targets := make(map[string]string, len(files))
+	for i, f := range files {
+		ext := filepath.Ext(f)
+		fields := renameFields{
+			Date:     b.StartTime.Format("2006-01-02"),
+			Time:     b.StartTime.Format("150405"),
+			Folder:   filepath.Base(b.Folder),
+			Category: fileCategory(f),
+			Index:    i + 1,
+			Name:     strings.TrimSuffix(filepath.Base(f), ext),
+			Ext:      ext,
+			Dir:      filepath.Dir(f),
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, fields); err != nil {
+			return nil, fmt.Errorf("模板错误: %v", err)
+		}
+		to := filepath.Clean(filepath.FromSlash(strings.TrimSpace(buf.String())))
+		if to == "." || filepath.IsAbs(to) || to == ".." || strings.HasPrefix(to, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("%s: 目标路径无效: %q", f, to)
+		}
+		key := pathKey(to)
+		if other, ok := targets[key]; ok {
+			return nil, fmt.Errorf("%s 和 %s 会重名为 %s", other, f, to)
+		}
+		targets[key] = f
+		ops = append(ops, renameOp{From: f, To: to})
+	}
+	return ops, nil
+}
+
+// applyRename moves the files of a batch as planned and updates its file
+// list. Files already at their target are skipped; it stops at the first
+// failure, keeping the moves done so far.
+func applyRename(b *Batch, ops []renameOp) error {
+	batchesMu.RLock()
+	folder := b.Folder
+	batchesMu.RUnlock()
+
+	done := make(map[string]string, len(ops))
+	var firstErr error
+	for _, op := range ops {
+		if op.From == op.To {
+			continue
+		}
+		src, dst := filepath.Join(folder, op.From), filepath.Join(folder, op.To)
+		if _, err := os.Stat(dst); err == nil {
+			firstErr = fmt.Errorf("目标文件已存在: %s", op.To)
+			break
+		}
+		release := claimOwnOutput(dst)
+		err := os.MkdirAll(filepath.Dir(dst), 0755)
+		if err == nil {
+			err = moveFile(src, dst)
+		}
+		release()
+		if err != nil {
+			firstErr = fmt.Errorf("移动 %s 失败: %v", op.From, err)
+			break
+		}
+		done[op.From] = op.To
+	}
+
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	for i, f := range b.Files {
+		if to, ok := done[f]; ok {
+			b.Files[i] = to
+			renameBatchFile(b, f, to)
+		}
+	}
+	logEvent("批次 %s 已整理 %d 个文件", b.ID, len(done))
+	return firstErr
+}
+
+// renameBatchFile moves what a batch keeps per file from one name to
+// another; b.Files itself is left to the caller. Caller must hold batchesMu.
+func renameBatchFile(b *Batch, from, to string) {
+	if size, ok := b.FileSizes[from]; ok {
+		b.FileSizes[to] = size
+		delete(b.FileSizes, from)
+	}
+	if ring, ok := b.Growth[from]; ok {
+		b.Growth[to] = ring
+		delete(b.Growth, from)
+	}
+	if info, ok := b.Media[from]; ok {
+		b.Media[to] = info
+		delete(b.Media, from)
+	}
+	if sum, ok := b.Sums[from]; ok {
+		b.Sums[to] = sum
+		delete(b.Sums, from)
+	}
+	for _, list := range [][]string{b.DupFiles, b.BadFiles, b.Locked} {
+		for i, f := range list {
+			if f == from {
+				list[i] = to
+			}
+		}
+	}
+}
+
+// renamePreview formats planned moves for the dry-run dialog
+func renamePreview(ops []renameOp) string {
+	lines := make([]string, 0, len(ops))
+	for _, op := range ops {
+		if op.From == op.To {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s → %s", filepath.ToSlash(op.From), filepath.ToSlash(op.To)))
+	}
+	if len(lines) == 0 {
+		return "没有需要移动的文件"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// defaultRenameTemplate sorts files into date and category folders
+const defaultRenameTemplate = "{{.Date}}/{{.Category}}/{{.Name}}{{.Ext}}"
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlanRename(t *testing.T) {
+	b := &Batch{
+		Folder:    "/in/day1",
+		Files:     []string{"b.jpg", "a.mp4"},
+		FileSizes: map[string]int64{"a.mp4": 1, "b.jpg": 1},
+		StartTime: time.Date(2024, 3, 1, 9, 30, 0, 0, time.UTC),
+	}
+
+	ops, err := planRename(b, defaultRenameTemplate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []renameOp{
+		{"a.mp4", filepath.FromSlash("2024-03-01/视频/a.mp4")},
+		{"b.jpg", filepath.FromSlash("2024-03-01/图片/b.jpg")},
+	}
+	if len(ops) != len(want) || ops[0] != want[0] || ops[1] != want[1] {
+		t.Errorf("ops = %v, want %v", ops, want)
+	}
+
+	ops, err = planRename(b, `{{.Folder}}_{{printf "%03d" .Index}}{{.Ext}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ops[0].To != "day1_001.mp4" || ops[1].To != "day1_002.jpg" {
+		t.Errorf("indexed ops = %v", ops)
+	}
+
+	// Collisions, escapes and bad templates are rejected before touching disk
+	for _, tmpl := range []string{"{{.Folder}}", "../{{.Name}}{{.Ext}}", "{{.Nope}}", "{{"} {
+		if _, err := planRename(b, tmpl); err == nil {
+			t.Errorf("template %q accepted", tmpl)
+		}
+	}
+}
+
+func TestApplyRename(t *testing.T) {
+	folder := t.TempDir()
+	os.WriteFile(filepath.Join(folder, "a.mp4"), []byte("video"), 0644)
+	b := &Batch{
+		Folder:    folder,
+		Files:     []string{"a.mp4"},
+		FileSizes: map[string]int64{"a.mp4": 5},
+	}
+	ops := []renameOp{{"a.mp4", filepath.Join("视频", "clip.mp4")}}
+	if err := applyRename(b, ops); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(folder, "视频", "clip.mp4")); err != nil {
+		t.Errorf("file not moved: %v", err)
+	}
+	if b.Files[0] != ops[0].To || b.FileSizes[ops[0].To] != 5 {
+		t.Errorf("batch not updated: %v %v", b.Files, b.FileSizes)
+	}
+}
+
+func TestApplyRenameKeepsPerFileState(t *testing.T) {
+	folder := t.TempDir()
+	os.WriteFile(filepath.Join(folder, "a.mp4"), []byte("video"), 0644)
+	b := &Batch{
+		Folder:    folder,
+		Files:     []string{"a.mp4"},
+		FileSizes: map[string]int64{"a.mp4": 5},
+		Media:     map[string]VideoInfo{"a.mp4": {Codec: "h264"}},
+		Sums:      map[string]string{"a.mp4": sumOK},
+		DupFiles:  []string{"a.mp4"},
+	}
+	to := filepath.Join("视频", "clip.mp4")
+	if err := applyRename(b, []renameOp{{"a.mp4", to}}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Media[to].Codec != "h264" || b.Sums[to] != sumOK || b.DupFiles[0] != to {
+		t.Errorf("per-file state not moved: media %v, sums %v, dups %v", b.Media, b.Sums, b.DupFiles)
+	}
+	if _, ok := b.Media["a.mp4"]; ok {
+		t.Error("old media entry left behind")
+	}
+	if _, ok := b.Sums["a.mp4"]; ok {
+		t.Error("old checksum entry left behind")
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import "time"
+
+// reopenableBatch returns the completed batch of the folder with key
+// folderKey that is not signed yet and finished less than
+// config.ReopenMinutes before now, or nil. Caller must hold batchesMu.
+func reopenableBatch(folderKey string, now time.Time) *Batch {
+	window := time.Duration(config.ReopenMinutes) * time.Minute
+	if window <= 0 {
+		return nil
+	}
+	var found *Batch
+	for _, b := range batches {
+		if b.Status != "completed" && b.Status != statusReview {
+			continue
+		}
+		if b.Packing || b.Ingest || b.PostProc || pathKey(b.Folder) != folderKey || now.Sub(b.DoneTime) > window {
+			continue
+		}
+		// The most recent one if the folder completed several times
+		if found == nil || b.DoneTime.After(found.DoneTime) {
+			found = b
+		}
+	}
+	return found
+}
+
+// resumeUploading puts a batch back into uploading and clears everything
+// derived from its completed files. Caller must hold batchesMu.
+func resumeUploading(b *Batch, now time.Time) {
+	b.Status = "uploading"
+	b.LastTime = now
+	b.DoneTime = time.Time{}
+	b.CheckCode = ""
+	b.DupFiles = nil
+	b.Media = nil
+	b.Exif = nil
+	b.Archive = archiveUnchecked
+	b.BadFiles = nil
+	b.Checksum = checksumUnchecked
+	b.Sums = nil
+	b.SignOffs = nil
+	noteTimeline(b, timelineReopened, "", now)
+	noteGrowth(b, now)
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReopenCompletedBatch(t *testing.T) {
+	origConfig, origBatches, origMonitorPath := config, batches, monitorPath
+	defer func() {
+		config, batches, monitorPath = origConfig, origBatches, origMonitorPath
+	}()
+	config = Config{VideoEnabled: true, MonitorSubdirs: true}
+	batches = make(map[string]*Batch)
+	monitorPath = t.TempDir()
+	a := filepath.Join(monitorPath, "a.mp4")
+
+	complete := func() *Batch {
+		for _, b := range batches {
+			b.Status = "completed"
+			b.DoneTime = time.Now()
+			b.CheckCode = "ABC"
+			return b
+		}
+		t.Fatal("No batch")
+		return nil
+	}
+
+	// Disabled: a change after completion starts a new batch
+	addObservedFile(a, 100)
+	complete()
+	if !addObservedFile(a, 200) || len(batches) != 2 {
+		t.Fatalf("Expected a new batch without a reopen window, have %d", len(batches))
+	}
+
+	batches = make(map[string]*Batch)
+	config.ReopenMinutes = 5
+	addObservedFile(a, 100)
+	b := complete()
+	if addObservedFile(filepath.Join(monitorPath, "b.mp4"), 50) {
+		t.Error("Expected the completed batch to be reopened")
+	}
+	if len(batches) != 1 || b.Status != "uploading" || b.CheckCode != "" {
+		t.Fatalf("Batch not reopened: %d batches, status %s", len(batches), b.Status)
+	}
+	if len(b.Files) != 2 || b.TotalSize != 150 {
+		t.Errorf("Reopened batch has %v, %d bytes", b.Files, b.TotalSize)
+	}
+
+	// Signed batches and ones past the window stay closed
+	complete()
+	b.Status = "signed"
+	addObservedFile(a, 300)
+	if len(batches) != 2 {
+		t.Errorf("Expected a signed batch to stay closed, have %d batches", len(batches))
+	}
+	for _, other := range batches {
+		if other != b {
+			other.Status = "completed"
+			other.DoneTime = time.Now().Add(-6 * time.Minute)
+		}
+	}
+	addObservedFile(a, 400)
+	if len(batches) != 3 {
+		t.Errorf("Expected a batch past the window to stay closed, have %d batches", len(batches))
+	}
+}
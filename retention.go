@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// evictBatches drops finished batches from memory once there are more than
+// config.MaxBatches of them or they are older than config.KeepBatchHours,
+// oldest first. Their final state is written to the history (when enabled)
+// before they go. Signed batches go before ones still waiting for a
+// signature, which are only dropped by age when reminders are off, so
+// pending work stays visible. Caller must hold batchesMu.
+func evictBatches(now time.Time) int {
+	var finished []*Batch
+	for _, b := range batches {
+		if b.Status != "uploading" && !b.Packing && !b.Ingest && !b.PostProc {
+			finished = append(finished, b)
+		}
+	}
+	unsigned := func(b *Batch) bool { return b.Status != "signed" }
+	sort.Slice(finished, func(i, j int) bool {
+		if unsigned(finished[i]) != unsigned(finished[j]) {
+			return !unsigned(finished[i])
+		}
+		return finished[i].LastTime.Before(finished[j].LastTime)
+	})
+
+	excess := 0
+	if config.MaxBatches > 0 && len(finished) > config.MaxBatches {
+		excess = len(finished) - config.MaxBatches
+	}
+	maxAge := time.Duration(config.KeepBatchHours) * time.Hour
+	evicted := 0
+	for i, b := range finished {
+		tooOld := maxAge > 0 && now.Sub(b.LastTime) > maxAge && (!unsigned(b) || !config.RemindUnsigned)
+		if i >= excess && !tooOld {
+			continue
+		}
+		recordHistory(b)
+		delete(batches, b.ID)
+		evicted++
+	}
+	if evicted > 0 {
+		logEvent("已从内存移除 %d 个已完成的批次 (保留 %d 个)", evicted, len(batches))
+	}
+	return evicted
+}
+
+// pruneHistory rewrites the history file keeping only the latest record of
+// each batch within config.HistoryKeepDays, config.HistoryMaxBatches and
+// config.HistoryMaxMB, newest first. It returns the number of batches
+// removed.
+func pruneHistory(now time.Time) (int, error) {
+	if useDatabase() {
+		return dbPruneHistory(now)
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+
+	records, badLines, err := readHistory()
+	if err == nil && badLines > 0 {
+		// Rewriting would drop the unreadable lines for good
+		err = fmt.Errorf("%d 行无法解析", badLines)
+	}
+	if err != nil || len(records) == 0 {
+		return 0, err
+	}
+	maxAge := time.Duration(config.HistoryKeepDays) * 24 * time.Hour
+	maxBytes := int64(config.HistoryMaxMB) << 20
+	var lines [][]byte
+	var size int64
+	for _, rec := range records {
+		if maxAge > 0 && now.Sub(rec.EndTime) > maxAge {
+			break // sorted by end time, the rest is older
+		}
+		if config.HistoryMaxBatches > 0 && len(lines) >= config.HistoryMaxBatches {
+			break
+		}
+		data, err := json.Marshal(rec)
+		if err != nil {
+			return 0, err
+		}
+		if maxBytes > 0 && size+int64(len(data))+1 > maxBytes {
+			break
+		}
+		lines = append(lines, data)
+		size += int64(len(data)) + 1
+	}
+
+	// Superseded records and torn lines go too, so rewrite whenever the file
+	// is larger than what is kept
+	info, err := os.Stat(historyPath)
+	if err != nil || info.Size() == size {
+		return 0, err
+	}
+	var buf bytes.Buffer
+	for i := len(lines) - 1; i >= 0; i-- { // oldest first, as appended
+		buf.Write(lines[i])
+		buf.WriteByte('\n')
+	}
+	if err := writeFileAtomic(historyPath, buf.Bytes(), 0644); err != nil {
+		return 0, err
+	}
+	return len(records) - len(lines), nil
+}
+
+// pruneHistoryOnStartup sets a damaged history aside, applies the history
+// retention settings, loads the duplicate index and empties the trash of
+// batches past restoring, logging the outcome
+func pruneHistoryOnStartup() {
+	if err := recoverHistory(); err != nil {
+		historyLoadErr = err
+		logEvent("历史记录读取失败: %v", err)
+	}
+	removed, err := pruneHistory(time.Now())
+	switch {
+	case err != nil:
+		logEvent("整理历史记录失败: %v", err)
+	case removed > 0:
+		logEvent("已清理 %d 条过期的历史记录", removed)
+	}
+	loadSeenFiles()
+	if err := pruneTrash(time.Now()); err != nil {
+		logEvent("整理回收站失败: %v", err)
+	}
+}
+
+// clearHistory deletes the whole history, from both engines
+func clearHistory() error {
+	if useDatabase() {
+		if err := dbClearHistory(); err != nil {
+			return err
+		}
+	}
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	if err := os.Remove(historyPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	forgetSeenFiles()
+	return nil
+}
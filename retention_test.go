@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestEvictBatches(t *testing.T) {
+	origBatches, origConfig, origHistory := batches, config, historyPath
+	defer func() { batches, config, historyPath = origBatches, origConfig, origHistory }()
+	historyPath = filepath.Join(t.TempDir(), "history.jsonl")
+	config = defaultConfig()
+	config.SaveHistory = true
+	config.MaxBatches = 3
+
+	now := time.Now()
+	batches = make(map[string]*Batch)
+	add := func(id, status string, age time.Duration) {
+		batches[id] = &Batch{ID: id, Folder: "/in/" + id, Status: status, LastTime: now.Add(-age), FileSizes: map[string]int64{}}
+	}
+	for i := 0; i < 4; i++ {
+		add(fmt.Sprintf("s%d", i), "signed", time.Duration(10-i)*time.Hour)
+	}
+	add("c0", "completed", 20*time.Hour)
+	add("c1", statusReview, 30*time.Hour)
+	add("up", "uploading", 40*time.Hour)
+	batches["pk"] = &Batch{ID: "pk", Status: "completed", Packing: true, LastTime: now.Add(-50 * time.Hour)}
+
+	// 6 finished, cap 3: the three oldest signed ones go first
+	if n := evictBatches(now); n != 3 {
+		t.Fatalf("Evicted %d batches, want 3", n)
+	}
+	for _, id := range []string{"s3", "c0", "c1", "up", "pk"} {
+		if batches[id] == nil {
+			t.Errorf("Batch %s should be kept", id)
+		}
+	}
+	records, _, _ := loadHistory()
+	if len(records) != 3 {
+		t.Errorf("Evicted batches not in history: %d records", len(records))
+	}
+
+	// By age: unsigned batches stay while reminders are on
+	config.MaxBatches = 0
+	config.KeepBatchHours = 5
+	evictBatches(now)
+	if batches["c0"] == nil || batches["s3"] != nil {
+		t.Error("Age limit should drop signed batches only")
+	}
+	config.RemindUnsigned = false
+	evictBatches(now)
+	if batches["c0"] != nil || batches["up"] == nil || batches["pk"] == nil {
+		t.Error("Age limit should drop unsigned batches without reminders, never uploading or packing ones")
+	}
+}
+
+func TestPruneHistory(t *testing.T) {
+	origConfig, origHistory := config, historyPath
+	defer func() { config, historyPath = origConfig, origHistory }()
+	historyPath = filepath.Join(t.TempDir(), "history.jsonl")
+	config = defaultConfig()
+	config.SaveHistory = true
+
+	now := time.Now()
+	for i := 0; i < 5; i++ {
+		b := &Batch{ID: fmt.Sprintf("b%d", i), Status: "completed", LastTime: now.Add(-time.Duration(i) * 24 * time.Hour)}
+		recordHistory(b)
+		b.Status = "signed"
+		recordHistory(b)
+	}
+
+	config.HistoryKeepDays = 0
+	config.HistoryMaxBatches = 0
+	config.HistoryMaxMB = 0
+	if n, err := pruneHistory(now); err != nil || n != 0 {
+		t.Fatalf("pruneHistory = %d, %v without limits", n, err)
+	}
+	if records, _, _ := loadHistory(); len(records) != 5 || records[4].Status != "signed" {
+		t.Errorf("Compaction lost records: %+v", records)
+	}
+
+	config.HistoryKeepDays = 3
+	if n, _ := pruneHistory(now.Add(time.Hour)); n != 2 {
+		t.Errorf("Pruned %d batches by age, want 2", n)
+	}
+	config.HistoryMaxBatches = 2
+	if n, _ := pruneHistory(now); n != 1 {
+		t.Errorf("Pruned %d batches by count, want 1", n)
+	}
+	records, _, _ := loadHistory()
+	if len(records) != 2 || records[0].ID != "b0" || records[1].ID != "b1" {
+		t.Errorf("Kept %+v, want the two newest", records)
+	}
+
+	if err := clearHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(historyPath); !os.IsNotExist(err) {
+		t.Error("History file still there after clearHistory")
+	}
+	if err := clearHistory(); err != nil {
+		t.Errorf("Clearing an empty history failed: %v", err)
+	}
+}
@@ -0,0 +1,516 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	pathpkg "path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// Rule scopes: file rules look at every file of a batch, batch rules at the
+// batch as a whole
+const (
+	ruleScopeFile  = "file"
+	ruleScopeBatch = "batch"
+)
+
+var ruleScopes = []struct {
+	Scope string
+	Label string
+}{
+	{ruleScopeFile, "每个文件"},
+	{ruleScopeBatch, "完成的批次"},
+}
+
+// Rule actions. Ignoring a file keeps it out of its batch; ignoring a batch
+// completes it without the completion notification and sound.
+const (
+	ruleNotify  = "notify"  // Target: channel, see ruleChannels
+	ruleCommand = "command" // Target: command line, run through the shell
+	ruleMove    = "move"    // Target: destination folder
+	ruleTag     = "tag"     // Target: tag added to the batch
+	ruleIgnore  = "ignore"
+)
+
+var ruleActions = []struct {
+	Action string
+	Label  string
+}{
+	{ruleNotify, "通知"},
+	{ruleCommand, "运行命令"},
+	{ruleMove, "移动到"},
+	{ruleTag, "添加标签"},
+	{ruleIgnore, "忽略"},
+}
+
+// Channels of notify rules
+const (
+	ruleChannelDesktop = "desktop"
+	ruleChannelSysLog  = "syslog" // the backend chosen in config.SysLog
+)
+
+var ruleChannels = []struct {
+	Channel string
+	Label   string
+}{
+	{ruleChannelDesktop, "桌面通知"},
+	{ruleChannelSysLog, "系统日志"},
+}
+
+// ruleCommandTimeout is how long a rule command may run before it is killed
+const ruleCommandTimeout = 10 * time.Minute
+
+// Rule is one entry of the rules list. A rule applies when all its
+// conditions match; empty or zero conditions match anything. All matching
+// rules apply, in list order.
+type Rule struct {
+	Name     string `json:"name" yaml:"name" toml:"name"`
+	Enabled  bool   `json:"enabled" yaml:"enabled" toml:"enabled"`
+	Scope    string `json:"scope" yaml:"scope" toml:"scope"`             // file or batch
+	Exts     string `json:"exts" yaml:"exts" toml:"exts"`                // comma-separated; a batch matches if any file has one
+	Glob     string `json:"glob" yaml:"glob" toml:"glob"`                // like exclude patterns; batch rules match the folder
+	MinMB    int    `json:"min_mb" yaml:"min_mb" toml:"min_mb"`          // size of the file or of the whole batch
+	MaxMB    int    `json:"max_mb" yaml:"max_mb" toml:"max_mb"`          //
+	MinFiles int    `json:"min_files" yaml:"min_files" toml:"min_files"` // batch rules: number of files
+	MaxFiles int    `json:"max_files" yaml:"max_files" toml:"max_files"` //
+	From     string `json:"from" yaml:"from" toml:"from"`                // "HH:MM" time of day, an end before the start spans midnight
+	To       string `json:"to" yaml:"to" toml:"to"`                      //
+	Action   string `json:"action" yaml:"action" toml:"action"`
+	Target   string `json:"target" yaml:"target" toml:"target"` // channel, command, folder or tag, by action
+}
+
+// validate returns what is wrong with a rule, or ""
+func (r Rule) validate() string {
+	if strings.TrimSpace(r.Name) == "" {
+		return "缺少名称"
+	}
+	scopeOK := false
+	for _, s := range ruleScopes {
+		scopeOK = scopeOK || s.Scope == r.Scope
+	}
+	if !scopeOK {
+		return fmt.Sprintf("范围 %q 无效", r.Scope)
+	}
+	switch r.Action {
+	case ruleNotify:
+		if r.Target != "" && ruleChannelLabel(r.Target) == "" {
+			return fmt.Sprintf("通知渠道 %q 无效", r.Target)
+		}
+	case ruleCommand, ruleMove, ruleTag:
+		if strings.TrimSpace(r.Target) == "" {
+			return "缺少动作参数"
+		}
+	case ruleIgnore:
+	default:
+		return fmt.Sprintf("动作 %q 无效", r.Action)
+	}
+	if r.MinMB < 0 || r.MaxMB < 0 || r.MinFiles < 0 || r.MaxFiles < 0 {
+		return "大小和文件数不能为负数"
+	}
+	if r.MaxMB > 0 && r.MaxMB < r.MinMB || r.MaxFiles > 0 && r.MaxFiles < r.MinFiles {
+		return "上限小于下限"
+	}
+	if r.From != "" || r.To != "" {
+		_, _, ok1 := parseClock(r.From)
+		_, _, ok2 := parseClock(r.To)
+		if !ok1 || !ok2 || r.From == r.To {
+			return "时间段应为两个不同的 HH:MM"
+		}
+	}
+	if _, err := pathpkg.Match(r.Glob, ""); err != nil {
+		return fmt.Sprintf("路径规则 %q 无效", r.Glob)
+	}
+	return ""
+}
+
+// ruleChannelLabel names a notification channel, "" if unknown
+func ruleChannelLabel(channel string) string {
+	for _, c := range ruleChannels {
+		if c.Channel == channel {
+			return c.Label
+		}
+	}
+	return ""
+}
+
+// ruleSubject is what a rule is matched against: a file, or a batch with
+// its folder as path
+type ruleSubject struct {
+	Path  string
+	Exts  []string // lower-case, with dot
+	Size  int64    // -1 when unknown, which fails size conditions
+	Files int
+}
+
+// matches reports whether all conditions of r hold for s at now
+func (r Rule) matches(s ruleSubject, now time.Time) bool {
+	if r.Exts != "" {
+		want := make(map[string]bool)
+		for _, ext := range strings.Split(r.Exts, ",") {
+			if ext = strings.ToLower(strings.TrimSpace(ext)); ext != "" {
+				want["."+strings.TrimPrefix(ext, ".")] = true
+			}
+		}
+		found := false
+		for _, ext := range s.Exts {
+			found = found || want[ext]
+		}
+		if !found {
+			return false
+		}
+	}
+	if r.Glob != "" && !matchPathGlob(r.Glob, s.Path) {
+		return false
+	}
+	if r.MinMB > 0 || r.MaxMB > 0 {
+		if s.Size < 0 || s.Size < int64(r.MinMB)<<20 || r.MaxMB > 0 && s.Size > int64(r.MaxMB)<<20 {
+			return false
+		}
+	}
+	if r.Scope == ruleScopeBatch {
+		if s.Files < r.MinFiles || r.MaxFiles > 0 && s.Files > r.MaxFiles {
+			return false
+		}
+	}
+	if r.From != "" && r.To != "" {
+		everyDay := []int{0, 1, 2, 3, 4, 5, 6}
+		if w, err := newScheduleWindow(everyDay, r.From, r.To); err != nil || !w.Contains(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchPathGlob matches a pattern the way exclude patterns do: without a
+// slash against the name, otherwise against the path below the watch root
+func matchPathGlob(pattern, path string) bool {
+	pattern = pathKey(filepath.ToSlash(strings.TrimSpace(pattern)))
+	target := pathKey(filepath.Base(path))
+	if strings.Contains(pattern, "/") {
+		target = ""
+		if r, err := filepath.Rel(filepath.Clean(monitorPath), path); err == nil && monitorPath != "" {
+			target = pathKey(filepath.ToSlash(r))
+		}
+	}
+	ok, _ := pathpkg.Match(pattern, target)
+	return ok
+}
+
+// fileSubject describes one file of the given size
+func fileSubject(path string, size int64) ruleSubject {
+	return ruleSubject{Path: path, Exts: []string{strings.ToLower(filepath.Ext(path))}, Size: size, Files: 1}
+}
+
+// batchSubject describes a batch. Caller must hold batchesMu.
+func batchSubject(b *Batch) ruleSubject {
+	s := ruleSubject{Path: b.Folder, Size: b.TotalSize, Files: len(b.Files)}
+	seen := make(map[string]bool)
+	for _, f := range b.Files {
+		if ext := strings.ToLower(filepath.Ext(f)); !seen[ext] {
+			seen[ext] = true
+			s.Exts = append(s.Exts, ext)
+		}
+	}
+	return s
+}
+
+// ignoringRule names the first enabled file rule that ignores path at size,
+// or returns ""
+func ignoringRule(path string, size int64, now time.Time) string {
+	for _, r := range config.Rules {
+		if r.Enabled && r.Scope == ruleScopeFile && r.Action == ruleIgnore && r.matches(fileSubject(path, size), now) {
+			return r.Name
+		}
+	}
+	return ""
+}
+
+// dropIgnoredFile takes a file an ignore rule matched out of its uploading
+// batch, e.g. once it grew past a size limit. A batch left empty is removed.
+func dropIgnoredFile(filePath, rule string) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	for _, b := range batches {
+		if b.Status != "uploading" {
+			continue
+		}
+		rel, err := filepath.Rel(b.Folder, filePath)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		key := pathKey(rel)
+		for i, f := range b.Files {
+			if pathKey(f) != key {
+				continue
+			}
+			b.Files = append(b.Files[:i:i], b.Files[i+1:]...)
+			b.TotalSize -= b.FileSizes[f]
+			delete(b.FileSizes, f)
+			delete(b.Growth, f)
+			logEvent("批次 %s: %s 按规则 %q 忽略", b.ID, f, rule)
+			if len(b.Files) == 0 {
+				delete(batches, b.ID)
+				logEvent("批次 %s 的文件均被规则忽略, 已移除", b.ID)
+				publishBatchEvent(eventDeleted, b)
+			}
+			return
+		}
+	}
+}
+
+// plannedMove moves files of a batch, relative to its folder, into Dest
+type plannedMove struct {
+	Rule  string
+	Dest  string
+	Files []string
+	Whole bool // the batch rule matched: the batch follows its files
+}
+
+// plannedRun is a command to run for a batch, or for one of its files
+type plannedRun struct {
+	Rule string
+	Line string
+	File string // relative to the batch folder, "" for batch rules
+}
+
+// ruleOutcome is what the rules decided for a completed batch
+type ruleOutcome struct {
+	Silent   bool // complete without notification and sound
+	Moves    []plannedMove
+	Commands []plannedRun
+}
+
+// Pending reports whether there are moves or commands left to run
+func (o ruleOutcome) Pending() bool {
+	return len(o.Moves)+len(o.Commands) > 0
+}
+
+// applyBatchRules evaluates the rules for a batch that just completed. Tags
+// and notifications happen right away; moves and commands are returned for
+// runRuleActions. Caller must hold batchesMu.
+func applyBatchRules(b *Batch, app fyne.App, now time.Time) ruleOutcome {
+	var out ruleOutcome
+	for _, r := range config.Rules {
+		if !r.Enabled {
+			continue
+		}
+		var files []string
+		if r.Scope == ruleScopeBatch {
+			if !r.matches(batchSubject(b), now) {
+				continue
+			}
+			files = append(files, b.Files...)
+		} else {
+			for _, f := range b.Files {
+				if r.matches(fileSubject(filepath.Join(b.Folder, f), b.FileSizes[f]), now) {
+					files = append(files, f)
+				}
+			}
+			if len(files) == 0 {
+				continue
+			}
+		}
+		logEvent("批次 %s 匹配规则 %q (%d 个文件)", b.ID, r.Name, len(files))
+
+		switch r.Action {
+		case ruleTag:
+			addBatchTag(b, r.Target)
+		case ruleNotify:
+			msg := fmt.Sprintf("%s: %s", displayFolder(b.Folder), fileCountText(b))
+			if r.Scope == ruleScopeFile {
+				msg = fmt.Sprintf("%s: %d 个文件, 如 %s", displayFolder(b.Folder), len(files), filepath.Base(files[0]))
+			}
+			if r.Target == ruleChannelSysLog {
+				sysLogBatch(sysLogInfo, b, "规则 %s: %s", r.Name, msg)
+			} else {
+				sendNotification(app, "FidruaWatch - "+r.Name, msg)
+			}
+		case ruleIgnore:
+			// Ignored files never joined the batch
+			out.Silent = out.Silent || r.Scope == ruleScopeBatch
+		case ruleMove:
+			out.Moves = append(out.Moves, plannedMove{Rule: r.Name, Dest: r.Target, Files: files, Whole: r.Scope == ruleScopeBatch})
+		case ruleCommand:
+			if r.Scope == ruleScopeBatch {
+				out.Commands = append(out.Commands, plannedRun{Rule: r.Name, Line: r.Target})
+				break
+			}
+			for _, f := range files {
+				out.Commands = append(out.Commands, plannedRun{Rule: r.Name, Line: r.Target, File: f})
+			}
+		}
+	}
+	return out
+}
+
+// addBatchTag tags a batch once. Caller must hold batchesMu.
+func addBatchTag(b *Batch, tag string) {
+	tag = strings.TrimSpace(tag)
+	for _, t := range b.Tags {
+		if t == tag {
+			return
+		}
+	}
+	b.Tags = append(b.Tags, tag)
+}
+
+// runRuleActions runs the moves of a rule outcome, then starts its
+// commands. Moves keep the batch's subfolder structure below a folder of
+// the batch's name in the destination; moved files leave the batch unless
+// the whole batch moved.
+func runRuleActions(ctx context.Context, b *Batch, out ruleOutcome) {
+	batchesMu.RLock()
+	folder := b.Folder
+	batchesMu.RUnlock()
+	remote := isRemoteWatchPath(folder)
+
+	for _, m := range out.Moves {
+		if remote {
+			logEvent("规则 %q: 远程批次不能移动", m.Rule)
+			continue
+		}
+		dest := filepath.Join(m.Dest, filepath.Base(folder))
+		moved := make(map[string]bool, len(m.Files))
+		for _, f := range m.Files {
+			src, dst := filepath.Join(folder, f), filepath.Join(dest, f)
+			if pathKey(src) == pathKey(dst) {
+				continue
+			}
+			if _, err := os.Stat(dst); err == nil {
+				logEvent("规则 %q: 目标文件已存在, 跳过 %s", m.Rule, dst)
+				continue
+			}
+			release := claimOwnOutput(dst)
+			err := os.MkdirAll(filepath.Dir(dst), 0755)
+			if err == nil {
+				err = moveFile(src, dst)
+			}
+			release()
+			if err != nil {
+				logEvent("规则 %q: 移动 %s 失败: %v", m.Rule, f, err)
+				continue
+			}
+			moved[f] = true
+		}
+		logEvent("规则 %q: 已移动 %d 个文件到 %s", m.Rule, len(moved), dest)
+
+		batchesMu.Lock()
+		if m.Whole && len(moved) == len(m.Files) {
+			b.Folder = dest
+			folder = dest
+		} else if len(moved) > 0 {
+			kept := b.Files[:0]
+			for _, f := range b.Files {
+				if moved[f] {
+					b.TotalSize -= b.FileSizes[f]
+					delete(b.FileSizes, f)
+					continue
+				}
+				kept = append(kept, f)
+			}
+			b.Files = kept
+		}
+		recordHistory(b)
+		batchesMu.Unlock()
+	}
+
+	for _, run := range out.Commands {
+		go runRuleCommand(ctx, b, run)
+	}
+}
+
+// runRuleCommand runs a rule's command line through the shell, in the batch
+// folder for local batches, with the batch described in FIDRUAWATCH_*
+// variables
+func runRuleCommand(ctx context.Context, b *Batch, run plannedRun) {
+	ctx, cancel := context.WithTimeout(ctx, ruleCommandTimeout)
+	defer cancel()
+	cmd := shellCommand(ctx, run.Line)
+
+	batchesMu.RLock()
+	cmd.Env = append(os.Environ(),
+		"FIDRUAWATCH_RULE="+run.Rule,
+		"FIDRUAWATCH_BATCH_ID="+b.ID,
+		"FIDRUAWATCH_BATCH_FOLDER="+b.Folder,
+		"FIDRUAWATCH_FILE_COUNT="+fmt.Sprint(len(b.Files)),
+		"FIDRUAWATCH_TOTAL_SIZE="+fmt.Sprint(b.TotalSize),
+		"FIDRUAWATCH_TAGS="+strings.Join(b.Tags, ","),
+	)
+	if run.File != "" {
+		cmd.Env = append(cmd.Env, "FIDRUAWATCH_FILE="+filepath.Join(b.Folder, run.File))
+	}
+	if !isRemoteWatchPath(b.Folder) {
+		cmd.Dir = b.Folder
+	}
+	id := b.ID
+	batchesMu.RUnlock()
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		text := strings.TrimSpace(string(out))
+		if len(text) > 200 {
+			text = text[:200] + "..."
+		}
+		logEvent("规则 %q 的命令失败 (批次 %s): %v %s", run.Rule, id, err, text)
+		return
+	}
+	logEvent("规则 %q 的命令已完成 (批次 %s)", run.Rule, id)
+}
+
+// ruleSummary describes a rule in one line for the rules list
+func ruleSummary(r Rule) string {
+	var conds []string
+	if r.Exts != "" {
+		conds = append(conds, r.Exts)
+	}
+	if r.Glob != "" {
+		conds = append(conds, r.Glob)
+	}
+	switch {
+	case r.MinMB > 0 && r.MaxMB > 0:
+		conds = append(conds, fmt.Sprintf("%d-%d MB", r.MinMB, r.MaxMB))
+	case r.MinMB > 0:
+		conds = append(conds, fmt.Sprintf("≥%d MB", r.MinMB))
+	case r.MaxMB > 0:
+		conds = append(conds, fmt.Sprintf("≤%d MB", r.MaxMB))
+	}
+	if r.Scope == ruleScopeBatch && (r.MinFiles > 0 || r.MaxFiles > 0) {
+		if r.MaxFiles > 0 {
+			conds = append(conds, fmt.Sprintf("%d-%d 个文件", r.MinFiles, r.MaxFiles))
+		} else {
+			conds = append(conds, fmt.Sprintf("≥%d 个文件", r.MinFiles))
+		}
+	}
+	if r.From != "" {
+		conds = append(conds, r.From+"-"+r.To)
+	}
+	scope := "文件"
+	if r.Scope == ruleScopeBatch {
+		scope = "批次"
+	}
+	when := "所有" + scope
+	if len(conds) > 0 {
+		when = scope + " " + strings.Join(conds, ", ")
+	}
+	action := r.Action
+	for _, a := range ruleActions {
+		if a.Action == r.Action {
+			action = a.Label
+		}
+	}
+	switch r.Action {
+	case ruleNotify:
+		if label := ruleChannelLabel(r.Target); label != "" {
+			action += " (" + label + ")"
+		}
+	case ruleIgnore:
+	default:
+		action += " " + r.Target
+	}
+	return when + " → " + action
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRuleMatches(t *testing.T) {
+	origConfig, origPath := config, monitorPath
+	defer func() { config, monitorPath = origConfig, origPath }()
+	config = Config{}
+	monitorPath = filepath.FromSlash("/in")
+	noon := time.Date(2026, 3, 2, 12, 0, 0, 0, time.Local)
+	file := fileSubject(filepath.FromSlash("/in/客户A/clip_raw.MOV"), 300<<20)
+	batch := ruleSubject{Path: filepath.FromSlash("/in/客户A"), Exts: []string{".jpg", ".mov"}, Size: 2 << 30, Files: 40}
+
+	tests := []struct {
+		name    string
+		rule    Rule
+		subject ruleSubject
+		want    bool
+	}{
+		{"any", Rule{Scope: ruleScopeFile}, file, true},
+		{"ext", Rule{Scope: ruleScopeFile, Exts: "mp4, .mov"}, file, true},
+		{"other ext", Rule{Scope: ruleScopeFile, Exts: ".mp4"}, file, false},
+		{"name glob", Rule{Scope: ruleScopeFile, Glob: "*_raw.*"}, file, true},
+		{"path glob", Rule{Scope: ruleScopeFile, Glob: "客户*/*"}, file, true},
+		{"other path", Rule{Scope: ruleScopeFile, Glob: "内部/*"}, file, false},
+		{"min size", Rule{Scope: ruleScopeFile, MinMB: 100}, file, true},
+		{"max size", Rule{Scope: ruleScopeFile, MaxMB: 100}, file, false},
+		{"unknown size", Rule{Scope: ruleScopeFile, MaxMB: 100}, fileSubject("/in/a.mov", -1), false},
+		{"time", Rule{Scope: ruleScopeFile, From: "09:00", To: "18:00"}, file, true},
+		{"night", Rule{Scope: ruleScopeFile, From: "22:00", To: "06:00"}, file, false},
+		{"batch ext", Rule{Scope: ruleScopeBatch, Exts: ".jpg"}, batch, true},
+		{"batch folder", Rule{Scope: ruleScopeBatch, Glob: "客户*"}, batch, true},
+		{"batch files", Rule{Scope: ruleScopeBatch, MinFiles: 10, MaxFiles: 50}, batch, true},
+		{"too few files", Rule{Scope: ruleScopeBatch, MinFiles: 100}, batch, false},
+		{"batch size", Rule{Scope: ruleScopeBatch, MinMB: 1024}, batch, true},
+	}
+	for _, tt := range tests {
+		if got := tt.rule.matches(tt.subject, noon); got != tt.want {
+			t.Errorf("%s: matches = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRuleValidate(t *testing.T) {
+	ok := Rule{Name: "n", Scope: ruleScopeFile, Action: ruleTag, Target: "t"}
+	if p := ok.validate(); p != "" {
+		t.Errorf("Valid rule rejected: %s", p)
+	}
+	for _, mutate := range []func(*Rule){
+		func(r *Rule) { r.Name = " " },
+		func(r *Rule) { r.Scope = "folder" },
+		func(r *Rule) { r.Action = "delete" },
+		func(r *Rule) { r.Target = "" },
+		func(r *Rule) { r.MinMB, r.MaxMB = 10, 5 },
+		func(r *Rule) { r.From = "9:00" },
+		func(r *Rule) { r.From, r.To = "25:00", "26:00" },
+		func(r *Rule) { r.Glob = "[" },
+		func(r *Rule) { r.Action, r.Target = ruleNotify, "sms" },
+	} {
+		r := ok
+		mutate(&r)
+		if r.validate() == "" {
+			t.Errorf("Invalid rule accepted: %+v", r)
+		}
+	}
+}
+
+func TestApplyBatchRules(t *testing.T) {
+	origConfig, origPath := config, monitorPath
+	defer func() { config, monitorPath = origConfig, origPath }()
+	monitorPath = filepath.FromSlash("/in")
+	config = Config{Rules: []Rule{
+		{Name: "原始素材", Enabled: true, Scope: ruleScopeFile, Exts: ".mov", Action: ruleTag, Target: "raw"},
+		{Name: "停用", Enabled: false, Scope: ruleScopeFile, Action: ruleTag, Target: "off"},
+		{Name: "安静", Enabled: true, Scope: ruleScopeBatch, MaxFiles: 5, Action: ruleIgnore},
+		{Name: "归档", Enabled: true, Scope: ruleScopeBatch, Action: ruleMove, Target: "/archive"},
+		{Name: "转码", Enabled: true, Scope: ruleScopeFile, Exts: ".mov", Action: ruleCommand, Target: "transcode"},
+	}}
+	b := &Batch{ID: "1", Folder: filepath.FromSlash("/in/day1"), Files: []string{"a.mov", "b.jpg", "c.mov"},
+		FileSizes: map[string]int64{"a.mov": 1, "b.jpg": 1, "c.mov": 1}}
+
+	out := applyBatchRules(b, nil, time.Now())
+	if !reflect.DeepEqual(b.Tags, []string{"raw"}) {
+		t.Errorf("Tags = %q, want [raw]", b.Tags)
+	}
+	if !out.Silent {
+		t.Error("Batch ignore rule should complete the batch silently")
+	}
+	if len(out.Moves) != 1 || !out.Moves[0].Whole || len(out.Moves[0].Files) != 3 {
+		t.Errorf("Moves = %+v", out.Moves)
+	}
+	if len(out.Commands) != 2 || out.Commands[0].File != "a.mov" || out.Commands[1].File != "c.mov" {
+		t.Errorf("Commands = %+v, want one per .mov file", out.Commands)
+	}
+
+	// Applying again doesn't duplicate tags
+	applyBatchRules(b, nil, time.Now())
+	if len(b.Tags) != 1 {
+		t.Errorf("Tags = %q after second run", b.Tags)
+	}
+}
+
+func TestRunRuleMoves(t *testing.T) {
+	origConfig, origPath := config, monitorPath
+	defer func() { config, monitorPath = origConfig, origPath }()
+	config = Config{}
+	monitorPath = t.TempDir()
+	archive := t.TempDir()
+	folder := filepath.Join(monitorPath, "day1")
+	os.MkdirAll(filepath.Join(folder, "cam"), 0755)
+	for _, f := range []string{"a.mov", filepath.Join("cam", "b.mov"), "c.jpg"} {
+		os.WriteFile(filepath.Join(folder, f), []byte("x"), 0644)
+	}
+	b := &Batch{ID: "1", Folder: folder, Files: []string{"a.mov", filepath.Join("cam", "b.mov"), "c.jpg"},
+		FileSizes: map[string]int64{"a.mov": 1, filepath.Join("cam", "b.mov"): 1, "c.jpg": 1}, TotalSize: 3}
+
+	// File rule: moved files leave the batch
+	runRuleActions(context.Background(), b, ruleOutcome{Moves: []plannedMove{
+		{Rule: "mov", Dest: archive, Files: []string{"a.mov", filepath.Join("cam", "b.mov")}},
+	}})
+	if _, err := os.Stat(filepath.Join(archive, "day1", "cam", "b.mov")); err != nil {
+		t.Errorf("Subfolder structure not kept: %v", err)
+	}
+	if !reflect.DeepEqual(b.Files, []string{"c.jpg"}) || b.TotalSize != 1 || b.Folder != folder {
+		t.Errorf("After file moves: %+v", b)
+	}
+
+	// Batch rule: the batch follows its files
+	runRuleActions(context.Background(), b, ruleOutcome{Moves: []plannedMove{
+		{Rule: "all", Dest: archive, Files: []string{"c.jpg"}, Whole: true},
+	}})
+	if b.Folder != filepath.Join(archive, "day1") {
+		t.Errorf("Batch folder = %s after moving the batch", b.Folder)
+	}
+	if _, err := os.Stat(filepath.Join(b.Folder, "c.jpg")); err != nil {
+		t.Errorf("Batch file not moved: %v", err)
+	}
+}
+
+func TestDropIgnoredFile(t *testing.T) {
+	origConfig, origBatches, origPath := config, batches, monitorPath
+	defer func() { config, batches, monitorPath = origConfig, origBatches, origPath }()
+	config = Config{VideoEnabled: true, Rules: []Rule{
+		{Name: "超大", Enabled: true, Scope: ruleScopeFile, MinMB: 1, Action: ruleIgnore},
+	}}
+	batches = make(map[string]*Batch)
+	monitorPath = t.TempDir()
+	a, b := filepath.Join(monitorPath, "a.mp4"), filepath.Join(monitorPath, "b.mp4")
+
+	addObservedFile(a, 100)
+	addObservedFile(b, 100)
+	// a grows past the limit and leaves its batch
+	addObservedFile(a, 2<<20)
+	if len(batches) != 1 {
+		t.Fatalf("Have %d batches", len(batches))
+	}
+	for _, batch := range batches {
+		if !reflect.DeepEqual(batch.Files, []string{"b.mp4"}) || batch.TotalSize != 100 {
+			t.Errorf("Batch after ignoring a.mp4: %+v", batch)
+		}
+	}
+	// A batch left empty is removed
+	addObservedFile(b, 2<<20)
+	if len(batches) != 0 {
+		t.Errorf("Empty batch kept: %d batches", len(batches))
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// showRulesDialog lists the rules for enabling, editing, reordering and
+// deleting. Every change is saved right away.
+func showRulesDialog(save func() bool, w fyne.Window) {
+	rules := append([]Rule(nil), config.Rules...)
+	empty := widget.NewLabel("还没有规则。规则按条件对文件或完成的批次执行通知、命令、移动、标签或忽略。")
+	empty.Wrapping = fyne.TextWrapWord
+	var list *widget.List
+	apply := func() {
+		config.Rules = append([]Rule(nil), rules...)
+		save()
+		empty.Hidden = len(rules) > 0
+		empty.Refresh()
+		list.Refresh()
+	}
+	list = widget.NewList(
+		func() int { return len(rules) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, widget.NewCheck("", nil),
+				container.NewHBox(widget.NewButton("✏️", nil), widget.NewButton("⬆️", nil), widget.NewButton("🗑️", nil)), l)
+		},
+		nil,
+	)
+	list.UpdateItem = func(i widget.ListItemID, o fyne.CanvasObject) {
+		row := o.(*fyne.Container)
+		buttons := row.Objects[2].(*fyne.Container).Objects
+		row.Objects[0].(*widget.Label).SetText(rules[i].Name + ": " + ruleSummary(rules[i]))
+		check := row.Objects[1].(*widget.Check)
+		check.OnChanged = nil
+		check.SetChecked(rules[i].Enabled)
+		check.OnChanged = func(on bool) {
+			rules[i].Enabled = on
+			apply()
+		}
+		buttons[0].(*widget.Button).OnTapped = func() {
+			showRuleForm(rules[i], func(r Rule) {
+				rules[i] = r
+				apply()
+			}, w)
+		}
+		up := buttons[1].(*widget.Button)
+		up.OnTapped = func() {
+			rules[i-1], rules[i] = rules[i], rules[i-1]
+			apply()
+		}
+		if i == 0 {
+			up.Disable()
+		} else {
+			up.Enable()
+		}
+		buttons[2].(*widget.Button).OnTapped = func() {
+			rules = append(rules[:i:i], rules[i+1:]...)
+			apply()
+		}
+	}
+	empty.Hidden = len(rules) > 0
+
+	addBtn := widget.NewButton("➕ 添加规则", func() {
+		r := Rule{Name: fmt.Sprintf("规则 %d", len(rules)+1), Enabled: true, Scope: ruleScopeFile, Action: ruleNotify, Target: ruleChannelDesktop}
+		showRuleForm(r, func(r Rule) {
+			rules = append(rules, r)
+			apply()
+		}, w)
+	})
+	hint := widget.NewLabel("所有匹配的规则按顺序执行。文件的忽略规则在检测到文件时生效, 其他规则在批次完成时执行。")
+	hint.Wrapping = fyne.TextWrapWord
+	content := container.NewBorder(container.NewVBox(hint, empty), addBtn, nil, nil, list)
+	d := dialog.NewCustom("🧩 规则", "关闭", content, w)
+	d.Resize(fyne.NewSize(640, 460))
+	d.Show()
+}
+
+// showRuleForm edits one rule, calling done with the result once it is valid
+func showRuleForm(r Rule, done func(Rule), w fyne.Window) {
+	nameEntry := widget.NewEntry()
+	nameEntry.SetText(r.Name)
+	enabledCheck := widget.NewCheck("启用", nil)
+	enabledCheck.SetChecked(r.Enabled)
+
+	var scopeLabels []string
+	for _, s := range ruleScopes {
+		scopeLabels = append(scopeLabels, s.Label)
+	}
+	scopeSelect := widget.NewSelect(scopeLabels, nil)
+	for i, s := range ruleScopes {
+		if s.Scope == r.Scope {
+			scopeSelect.SetSelectedIndex(i)
+		}
+	}
+
+	extsEntry := widget.NewEntry()
+	extsEntry.SetPlaceHolder(".mp4,.mov (留空 = 任意)")
+	extsEntry.SetText(r.Exts)
+	globEntry := widget.NewEntry()
+	globEntry.SetPlaceHolder("如 *_raw.* 或 客户*/* (留空 = 任意)")
+	globEntry.SetText(r.Glob)
+	intEntry := func(n int) *widget.Entry {
+		e := widget.NewEntry()
+		if n > 0 {
+			e.SetText(fmt.Sprintf("%d", n))
+		}
+		return e
+	}
+	minMBEntry, maxMBEntry := intEntry(r.MinMB), intEntry(r.MaxMB)
+	minFilesEntry, maxFilesEntry := intEntry(r.MinFiles), intEntry(r.MaxFiles)
+	fromEntry := widget.NewEntry()
+	fromEntry.SetPlaceHolder("HH:MM")
+	fromEntry.SetText(r.From)
+	toEntry := widget.NewEntry()
+	toEntry.SetPlaceHolder("HH:MM")
+	toEntry.SetText(r.To)
+	filesRow := container.NewGridWithColumns(2, minFilesEntry, maxFilesEntry)
+
+	var channelLabels []string
+	for _, c := range ruleChannels {
+		channelLabels = append(channelLabels, c.Label)
+	}
+	channelSelect := widget.NewSelect(channelLabels, nil)
+	channelSelect.SetSelectedIndex(0)
+	for i, c := range ruleChannels {
+		if c.Channel == r.Target {
+			channelSelect.SetSelectedIndex(i)
+		}
+	}
+	targetEntry := widget.NewEntry()
+	if r.Action != ruleNotify {
+		targetEntry.SetText(r.Target)
+	}
+
+	var actionLabels []string
+	for _, a := range ruleActions {
+		actionLabels = append(actionLabels, a.Label)
+	}
+	actionSelect := widget.NewSelect(actionLabels, nil)
+	action := func() string {
+		if i := actionSelect.SelectedIndex(); i >= 0 {
+			return ruleActions[i].Action
+		}
+		return ""
+	}
+	actionSelect.OnChanged = func(string) {
+		channelSelect.Hidden = action() != ruleNotify
+		targetEntry.Hidden = action() == ruleNotify || action() == ruleIgnore
+		switch action() {
+		case ruleCommand:
+			targetEntry.SetPlaceHolder("命令行, 批次信息在 FIDRUAWATCH_* 环境变量中")
+		case ruleMove:
+			targetEntry.SetPlaceHolder("目标文件夹")
+		case ruleTag:
+			targetEntry.SetPlaceHolder("标签")
+		}
+		channelSelect.Refresh()
+		targetEntry.Refresh()
+	}
+	for i, a := range ruleActions {
+		if a.Action == r.Action {
+			actionSelect.SetSelectedIndex(i)
+		}
+	}
+	scopeSelect.OnChanged = func(string) {
+		filesRow.Hidden = scopeSelect.SelectedIndex() >= 0 && ruleScopes[scopeSelect.SelectedIndex()].Scope != ruleScopeBatch
+		filesRow.Refresh()
+	}
+	scopeSelect.OnChanged(scopeSelect.Selected)
+
+	items := []*widget.FormItem{
+		widget.NewFormItem("名称", container.NewBorder(nil, nil, nil, enabledCheck, nameEntry)),
+		widget.NewFormItem("检查", scopeSelect),
+		widget.NewFormItem("扩展名", extsEntry),
+		widget.NewFormItem("路径", globEntry),
+		widget.NewFormItem("大小 (MB)", container.NewGridWithColumns(2, minMBEntry, maxMBEntry)),
+		widget.NewFormItem("文件数", filesRow),
+		widget.NewFormItem("时间段", container.NewGridWithColumns(2, fromEntry, toEntry)),
+		widget.NewFormItem("动作", actionSelect),
+		widget.NewFormItem("", container.NewStack(channelSelect, targetEntry)),
+	}
+	for _, e := range []*widget.Entry{minMBEntry, minFilesEntry} {
+		e.SetPlaceHolder("最小")
+	}
+	for _, e := range []*widget.Entry{maxMBEntry, maxFilesEntry} {
+		e.SetPlaceHolder("最大 (0 = 不限)")
+	}
+
+	dialog.ShowForm("编辑规则", "保存", "取消", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		edited := Rule{
+			Name:    strings.TrimSpace(nameEntry.Text),
+			Enabled: enabledCheck.Checked,
+			Exts:    strings.TrimSpace(extsEntry.Text),
+			Glob:    strings.TrimSpace(globEntry.Text),
+			From:    strings.TrimSpace(fromEntry.Text),
+			To:      strings.TrimSpace(toEntry.Text),
+			Action:  action(),
+			Target:  strings.TrimSpace(targetEntry.Text),
+		}
+		if i := scopeSelect.SelectedIndex(); i >= 0 {
+			edited.Scope = ruleScopes[i].Scope
+		}
+		switch edited.Action {
+		case ruleNotify:
+			edited.Target = ruleChannels[channelSelect.SelectedIndex()].Channel
+		case ruleIgnore:
+			edited.Target = ""
+		}
+		for _, f := range []struct {
+			entry *widget.Entry
+			dst   *int
+		}{
+			{minMBEntry, &edited.MinMB},
+			{maxMBEntry, &edited.MaxMB},
+			{minFilesEntry, &edited.MinFiles},
+			{maxFilesEntry, &edited.MaxFiles},
+		} {
+			fmt.Sscanf(f.entry.Text, "%d", f.dst)
+		}
+		if edited.Scope != ruleScopeBatch {
+			edited.MinFiles, edited.MaxFiles = 0, 0
+		}
+		if problem := edited.validate(); problem != "" {
+			dialog.ShowError(fmt.Errorf("%s", problem), w)
+			showRuleForm(edited, done, w)
+			return
+		}
+		done(edited)
+	}, w)
+}
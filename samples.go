@@ -0,0 +1,120 @@
+package main
+
+import "time"
+
+// Sample is one observation of a batch's total size
+type Sample struct {
+	Time time.Time
+	Size int64
+}
+
+// SampleRing stores the most recent samples of a batch in a fixed-size ring
+// buffer so long monitoring sessions stay memory-stable. Samples arriving
+// within resolution of the previous one replace it instead of taking a new
+// slot, which downsamples event storms to one sample per resolution window.
+// Not safe for concurrent use; batch samples are guarded by batchesMu.
+type SampleRing struct {
+	buf        []Sample
+	start      int // index of the oldest sample
+	count      int
+	resolution time.Duration
+}
+
+// NewSampleRing creates a ring holding at most size samples
+func NewSampleRing(size int, resolution time.Duration) *SampleRing {
+	if size < 2 {
+		size = 2
+	}
+	return &SampleRing{buf: make([]Sample, size), resolution: resolution}
+}
+
+// Add records a sample, coalescing it with the latest one if they fall in
+// the same resolution window and overwriting the oldest one when full
+func (r *SampleRing) Add(s Sample) {
+	if r == nil {
+		return
+	}
+	if r.count > 0 {
+		last := (r.start + r.count - 1) % len(r.buf)
+		if s.Time.Sub(r.buf[last].Time) < r.resolution {
+			r.buf[last].Size = s.Size
+			return
+		}
+	}
+	if r.count < len(r.buf) {
+		r.buf[(r.start+r.count)%len(r.buf)] = s
+		r.count++
+		return
+	}
+	r.buf[r.start] = s
+	r.start = (r.start + 1) % len(r.buf)
+}
+
+// Len returns the number of stored samples
+func (r *SampleRing) Len() int {
+	if r == nil {
+		return 0
+	}
+	return r.count
+}
+
+// Cap returns the maximum number of samples the ring holds
+func (r *SampleRing) Cap() int {
+	if r == nil {
+		return 0
+	}
+	return len(r.buf)
+}
+
+// Samples returns a copy of the stored samples, oldest first
+func (r *SampleRing) Samples() []Sample {
+	if r == nil {
+		return nil
+	}
+	out := make([]Sample, r.count)
+	for i := 0; i < r.count; i++ {
+		out[i] = r.buf[(r.start+i)%len(r.buf)]
+	}
+	return out
+}
+
+// Latest returns the newest sample, if any
+func (r *SampleRing) Latest() (Sample, bool) {
+	if r == nil || r.count == 0 {
+		return Sample{}, false
+	}
+	return r.buf[(r.start+r.count-1)%len(r.buf)], true
+}
+
+// Rate returns the growth in bytes per second over the samples within
+// window before now, 0 when there is no recent growth to measure
+func (r *SampleRing) Rate(window time.Duration, now time.Time) float64 {
+	if r == nil || r.count == 0 {
+		return 0
+	}
+	latest, _ := r.Latest()
+	if now.Sub(latest.Time) > window {
+		return 0 // idle
+	}
+	for i := 0; i < r.count; i++ {
+		s := r.buf[(r.start+i)%len(r.buf)]
+		if now.Sub(s.Time) > window {
+			continue
+		}
+		elapsed := now.Sub(s.Time).Seconds()
+		if elapsed <= 0 || latest.Size <= s.Size {
+			return 0
+		}
+		return float64(latest.Size-s.Size) / elapsed
+	}
+	return 0
+}
+
+// newBatchSampleRing creates a sample ring sized from the advanced settings
+func newBatchSampleRing() *SampleRing {
+	resolution := time.Duration(config.SampleResolution) * time.Second
+	if resolution <= 0 {
+		resolution = time.Second
+	}
+	return NewSampleRing(config.SampleBufferSize, resolution)
+}
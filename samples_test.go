@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSampleRingWrap(t *testing.T) {
+	r := NewSampleRing(3, time.Second)
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		r.Add(Sample{Time: base.Add(time.Duration(i) * time.Second), Size: int64(i)})
+	}
+	if r.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", r.Len())
+	}
+	samples := r.Samples()
+	for i, want := range []int64{2, 3, 4} {
+		if samples[i].Size != want {
+			t.Errorf("samples[%d].Size = %d, want %d", i, samples[i].Size, want)
+		}
+	}
+	if last, ok := r.Latest(); !ok || last.Size != 4 {
+		t.Errorf("Latest() = %+v, want size 4", last)
+	}
+}
+
+func TestSampleRingDownsample(t *testing.T) {
+	r := NewSampleRing(10, time.Second)
+	base := time.Now()
+	// A burst within one resolution window collapses into a single sample
+	for i := 0; i < 100; i++ {
+		r.Add(Sample{Time: base.Add(time.Duration(i) * time.Millisecond), Size: int64(i)})
+	}
+	if r.Len() != 1 {
+		t.Fatalf("Len() = %d, want 1", r.Len())
+	}
+	if last, _ := r.Latest(); last.Size != 99 {
+		t.Errorf("Latest().Size = %d, want 99", last.Size)
+	}
+}
+
+func TestSampleRingNil(t *testing.T) {
+	var r *SampleRing
+	r.Add(Sample{Size: 1})
+	if r.Len() != 0 || r.Samples() != nil {
+		t.Error("nil ring should be empty")
+	}
+}
+
+func TestSampleRingRate(t *testing.T) {
+	now := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	r := NewSampleRing(10, time.Second)
+	if r.Rate(10*time.Second, now) != 0 {
+		t.Error("empty ring has a rate")
+	}
+	r.Add(Sample{Time: now.Add(-20 * time.Second), Size: 0})
+	r.Add(Sample{Time: now.Add(-4 * time.Second), Size: 1000})
+	r.Add(Sample{Time: now, Size: 5000})
+
+	// Only samples within the window count: (5000-1000) bytes over 4s
+	if got := r.Rate(10*time.Second, now); got != 1000 {
+		t.Errorf("Rate = %v, want 1000", got)
+	}
+	// No growth within the window means idle
+	if got := r.Rate(10*time.Second, now.Add(30*time.Second)); got != 0 {
+		t.Errorf("idle Rate = %v, want 0", got)
+	}
+}
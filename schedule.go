@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// weekdayLabels are indexed by time.Weekday
+var weekdayLabels = []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+
+// scheduleWindow is the daily time window monitoring runs in on the
+// selected weekdays. An end before the start spans midnight and belongs to
+// the day it starts on.
+type scheduleWindow struct {
+	Days       map[time.Weekday]bool
+	Start, End int // minutes after midnight
+}
+
+// newScheduleWindow builds a window from the config values
+func newScheduleWindow(days []int, start, end string) (scheduleWindow, error) {
+	sh, sm, ok1 := parseClock(start)
+	eh, em, ok2 := parseClock(end)
+	if !ok1 || !ok2 {
+		return scheduleWindow{}, fmt.Errorf("时间格式应为 HH:MM")
+	}
+	w := scheduleWindow{Days: make(map[time.Weekday]bool), Start: sh*60 + sm, End: eh*60 + em}
+	for _, d := range days {
+		if d >= 0 && d <= 6 {
+			w.Days[time.Weekday(d)] = true
+		}
+	}
+	return w, nil
+}
+
+// Contains reports whether monitoring should run at t
+func (w scheduleWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return w.Days[t.Weekday()] && minute >= w.Start && minute < w.End
+	}
+	// Overnight: the evening part belongs to today, the morning part to yesterday
+	if minute >= w.Start {
+		return w.Days[t.Weekday()]
+	}
+	return minute < w.End && w.Days[t.AddDate(0, 0, -1).Weekday()]
+}
+
+// NextStart returns the next time a window opens after t, zero if no day is selected
+func (w scheduleWindow) NextStart(t time.Time) time.Time {
+	for i := 0; i <= 7; i++ {
+		day := t.AddDate(0, 0, i)
+		start := time.Date(day.Year(), day.Month(), day.Day(), w.Start/60, w.Start%60, 0, 0, t.Location())
+		if start.After(t) && w.Days[start.Weekday()] {
+			return start
+		}
+	}
+	return time.Time{}
+}
+
+// scheduleText describes the next scheduled start for the idle status line
+func scheduleText(next time.Time) string {
+	if next.IsZero() {
+		return "⏰ 计划监控: 未选择日期"
+	}
+	return fmt.Sprintf("⏰ 已计划: %s %s 开始", weekdayLabels[next.Weekday()], formatClock(next, false))
+}
+
+// scheduleState turns the schedule into start/stop actions. It only acts
+// when the window opens or closes, so a manual start or stop in between
+// stays in effect until the next boundary.
+type scheduleState struct {
+	known bool
+	last  bool
+}
+
+// Transition returns the new state and true when it changed
+func (s *scheduleState) Transition(want bool) (bool, bool) {
+	if s.known && want == s.last {
+		return want, false
+	}
+	s.known, s.last = true, want
+	return want, true
+}
+
+// runSchedule checks the schedule every 30 seconds and calls apply with
+// true to start and false to stop monitoring. Settings are re-read each time.
+func runSchedule(ctx context.Context, apply func(start bool)) {
+	var state scheduleState
+	check := func(now time.Time) {
+		if !config.ScheduleEnabled {
+			state = scheduleState{}
+			return
+		}
+		w, err := newScheduleWindow(config.ScheduleDays, config.ScheduleStart, config.ScheduleEnd)
+		if err != nil {
+			return
+		}
+		if want, changed := state.Transition(w.Contains(now)); changed {
+			apply(want)
+		}
+	}
+
+	check(time.Now())
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			check(now)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleWindowContains(t *testing.T) {
+	weekdays, err := newScheduleWindow([]int{1, 2, 3, 4, 5}, "09:00", "19:00")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-03-01 is a Friday
+	at := func(day, hour, min int) time.Time { return time.Date(2024, 3, day, hour, min, 0, 0, time.Local) }
+	cases := []struct {
+		t    time.Time
+		want bool
+	}{
+		{at(1, 9, 0), true},
+		{at(1, 18, 59), true},
+		{at(1, 19, 0), false},
+		{at(1, 8, 59), false},
+		{at(2, 12, 0), false}, // Saturday
+	}
+	for _, c := range cases {
+		if got := weekdays.Contains(c.t); got != c.want {
+			t.Errorf("Contains(%v) = %v, want %v", c.t, got, c.want)
+		}
+	}
+
+	// Friday night shift runs into Saturday morning
+	night, _ := newScheduleWindow([]int{5}, "22:00", "06:00")
+	if !night.Contains(at(1, 23, 0)) || !night.Contains(at(2, 5, 0)) {
+		t.Error("overnight window not matched")
+	}
+	if night.Contains(at(2, 23, 0)) || night.Contains(at(1, 5, 0)) {
+		t.Error("overnight window matched on the wrong day")
+	}
+
+	if _, err := newScheduleWindow(nil, "9", "19:00"); err == nil {
+		t.Error("invalid time accepted")
+	}
+}
+
+func TestScheduleNextStart(t *testing.T) {
+	useLocale(t, "zh-CN")
+	w, _ := newScheduleWindow([]int{1}, "09:00", "19:00")
+	// From Friday 2024-03-01 the next Monday is 03-04
+	next := w.NextStart(time.Date(2024, 3, 1, 12, 0, 0, 0, time.Local))
+	if want := time.Date(2024, 3, 4, 9, 0, 0, 0, time.Local); !next.Equal(want) {
+		t.Errorf("NextStart = %v, want %v", next, want)
+	}
+	if got := scheduleText(next); got != "⏰ 已计划: 周一 09:00 开始" {
+		t.Errorf("scheduleText = %q", got)
+	}
+	empty, _ := newScheduleWindow(nil, "09:00", "19:00")
+	if !empty.NextStart(time.Now()).IsZero() {
+		t.Error("NextStart without days should be zero")
+	}
+}
+
+func TestScheduleStateTransitions(t *testing.T) {
+	var s scheduleState
+	if want, changed := s.Transition(false); want || !changed {
+		t.Error("first check should apply")
+	}
+	// Staying inside or outside the window leaves manual overrides alone
+	if _, changed := s.Transition(false); changed {
+		t.Error("unchanged window reported a transition")
+	}
+	if want, changed := s.Transition(true); !want || !changed {
+		t.Error("window opening not reported")
+	}
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"github.com/d5/tengo/v2"
+	"github.com/d5/tengo/v2/stdlib"
+)
+
+// Script hooks cover what rules can't express. Every .tengo file in
+// scriptsDir may define
+//
+//	onFileDetected := func(file) { ... }    // return false to ignore the file
+//	onBatchCompleted := func(batch) { ... }
+//
+// Scripts are sandboxed: no file, process or import access beyond the safe
+// standard library modules and the fidruawatch and http modules below.
+const (
+	scriptExt            = ".tengo"
+	hookFileDetected     = "onFileDetected"
+	hookBatchCompleted   = "onBatchCompleted"
+	scriptFileTimeout    = time.Second // file hooks hold up ingesting the file
+	scriptBatchTimeout   = time.Minute
+	scriptMaxAllocs      = 5000000
+	scriptHTTPTimeout    = 10 * time.Second
+	scriptReloadInterval = 2 * time.Second
+	scriptDecisionsMax   = 10000
+)
+
+// scriptsDir holds the user's scripts
+var scriptsDir string
+
+// scriptStdlib are the standard library modules scripts may import; os is
+// left out
+var scriptStdlib = []string{"math", "text", "times", "rand", "fmt", "json", "base64", "hex", "enum"}
+
+// scriptHook is one script's compiled call of a hook
+type scriptHook struct {
+	script   string // file name, for logs
+	compiled *tengo.Compiled
+}
+
+// scriptHost keeps the hooks compiled, recompiling them when the scripts
+// change, and remembers onFileDetected decisions so each path is asked once
+type scriptHost struct {
+	mu        sync.Mutex
+	app       fyne.App
+	stamp     string // names, sizes and times of the loaded scripts
+	checked   time.Time
+	hooks     map[string][]scriptHook
+	decisions map[string]bool // by pathKey
+}
+
+var scripts = &scriptHost{}
+
+// SetApp sets the app scripts send notifications through; nil only logs them
+func (h *scriptHost) SetApp(app fyne.App) {
+	h.mu.Lock()
+	h.app = app
+	h.mu.Unlock()
+}
+
+// current returns the hooks of name, reloading changed scripts first
+func (h *scriptHost) current(name string) []scriptHook {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if time.Since(h.checked) >= scriptReloadInterval {
+		h.checked = time.Now()
+		if stamp := scriptsStamp(scriptsDir); stamp != h.stamp || h.hooks == nil {
+			h.stamp = stamp
+			h.hooks = h.compileAll(scriptsDir)
+			h.decisions = make(map[string]bool)
+		}
+	}
+	return h.hooks[name]
+}
+
+// scriptExample is written to a new scripts folder. Its extension keeps it
+// from being loaded until it is renamed.
+const scriptExample = `// 复制为 .tengo 文件并在设置中开启脚本钩子后生效
+fw := import("fidruawatch")
+text := import("text")
+
+// 返回 false 忽略文件
+onFileDetected := func(file) {
+	return !text.has_prefix(file.name, "~")
+}
+
+onBatchCompleted := func(batch) {
+	if batch.file_count >= 100 {
+		fw.notify("大批次完成", batch.folder)
+	}
+}
+`
+
+// ensureScriptsDir creates the scripts folder with an example script and
+// returns the example's path
+func ensureScriptsDir() (string, error) {
+	if err := os.MkdirAll(scriptsDir, 0755); err != nil {
+		return "", err
+	}
+	example := filepath.Join(scriptsDir, "example.tengo.txt")
+	if _, err := os.Stat(example); os.IsNotExist(err) {
+		if err := os.WriteFile(example, []byte(scriptExample), 0644); err != nil {
+			return "", err
+		}
+	}
+	return example, nil
+}
+
+// scriptsStamp summarizes the scripts in dir, so edits can be noticed
+func scriptsStamp(dir string) string {
+	entries, _ := os.ReadDir(dir)
+	var sb strings.Builder
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && strings.EqualFold(filepath.Ext(e.Name()), scriptExt) {
+			fmt.Fprintf(&sb, "%s:%d:%d;", e.Name(), info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	return sb.String()
+}
+
+// compileAll compiles the hooks of every script in dir, logging scripts
+// that don't compile. Caller must hold h.mu.
+func (h *scriptHost) compileAll(dir string) map[string][]scriptHook {
+	hooks := make(map[string][]scriptHook)
+	entries, _ := os.ReadDir(dir)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, e := range entries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), scriptExt) {
+			continue
+		}
+		src, err := os.ReadFile(filepath.Join(dir, e.Name()))
+		if err != nil {
+			logEvent("读取脚本 %s 失败: %v", e.Name(), err)
+			continue
+		}
+		for _, name := range []string{hookFileDetected, hookBatchCompleted} {
+			if !definesHook(src, name) {
+				continue
+			}
+			compiled, err := h.compileHook(src, name)
+			if err != nil {
+				logEvent("脚本 %s 编译失败: %v", e.Name(), err)
+				break
+			}
+			hooks[name] = append(hooks[name], scriptHook{script: e.Name(), compiled: compiled})
+		}
+	}
+	if len(hooks) > 0 {
+		logEvent("已加载脚本: %d 个文件钩子, %d 个批次钩子", len(hooks[hookFileDetected]), len(hooks[hookBatchCompleted]))
+	}
+	return hooks
+}
+
+// definesHook reports whether a script assigns the hook at the top level
+func definesHook(src []byte, name string) bool {
+	return regexp.MustCompile(`(?m)^` + name + `\s*:?=`).Match(src)
+}
+
+// compileHook compiles a script followed by a call of the hook, which
+// takes its argument from __arg and leaves its result in __result
+func (h *scriptHost) compileHook(src []byte, name string) (*tengo.Compiled, error) {
+	code := append(append([]byte(nil), src...), []byte("\n__result := "+name+"(__arg)\n")...)
+	s := tengo.NewScript(code)
+	modules := stdlib.GetModuleMap(scriptStdlib...)
+	modules.AddBuiltinModule("fidruawatch", h.module())
+	modules.AddBuiltinModule("http", scriptHTTPModule())
+	s.SetImports(modules)
+	s.SetMaxAllocs(scriptMaxAllocs)
+	if err := s.Add("__arg", nil); err != nil {
+		return nil, err
+	}
+	return s.Compile()
+}
+
+// call runs every script's hook with arg, returning false if any returned
+// false. Errors and timeouts are logged and count as no answer.
+func (h *scriptHost) call(ctx context.Context, name string, arg map[string]interface{}, timeout time.Duration) bool {
+	allow := true
+	for _, hook := range h.current(name) {
+		c := hook.compiled.Clone()
+		if err := c.Set("__arg", arg); err != nil {
+			logEvent("脚本 %s: %v", hook.script, err)
+			continue
+		}
+		runCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := c.RunContext(runCtx)
+		cancel()
+		if err != nil {
+			logEvent("脚本 %s 的 %s 出错: %v", hook.script, name, err)
+			continue
+		}
+		if result := c.Get("__result"); result.ValueType() == "bool" && !result.Bool() {
+			allow = false
+		}
+	}
+	return allow
+}
+
+// scriptAllowsFile asks the onFileDetected hooks about a file the first
+// time its path is seen
+func scriptAllowsFile(path string, size int64) bool {
+	if !config.ScriptsEnabled || len(scripts.current(hookFileDetected)) == 0 {
+		return true
+	}
+	key := pathKey(path)
+	scripts.mu.Lock()
+	allow, known := scripts.decisions[key]
+	scripts.mu.Unlock()
+	if known {
+		return allow
+	}
+	allow = scripts.call(context.Background(), hookFileDetected, map[string]interface{}{
+		"path":   path,
+		"name":   filepath.Base(path),
+		"ext":    strings.ToLower(filepath.Ext(path)),
+		"size":   size,
+		"folder": groupFolder(monitorPath, filepath.Dir(path), config.GroupDepth),
+	}, scriptFileTimeout)
+	scripts.mu.Lock()
+	if len(scripts.decisions) >= scriptDecisionsMax {
+		scripts.decisions = make(map[string]bool)
+	}
+	scripts.decisions[key] = allow
+	scripts.mu.Unlock()
+	if !allow {
+		logEvent("脚本忽略文件: %s", path)
+	}
+	return allow
+}
+
+// scriptDecision returns what the file hooks decided for path, if asked
+func scriptDecision(path string) (allow, known bool) {
+	scripts.mu.Lock()
+	defer scripts.mu.Unlock()
+	allow, known = scripts.decisions[pathKey(path)]
+	return
+}
+
+// runBatchScripts calls the onBatchCompleted hooks for a completed batch
+func runBatchScripts(ctx context.Context, b *Batch) {
+	if !config.ScriptsEnabled || len(scripts.current(hookBatchCompleted)) == 0 {
+		return
+	}
+	scripts.call(ctx, hookBatchCompleted, batchScriptValue(b), scriptBatchTimeout)
+}
+
+// batchScriptValue is the batch as scripts see it
+func batchScriptValue(b *Batch) map[string]interface{} {
+	batchesMu.RLock()
+	defer batchesMu.RUnlock()
+	files := make([]interface{}, len(b.Files))
+	for i, f := range b.Files {
+		files[i] = map[string]interface{}{"name": filepath.ToSlash(f), "size": b.FileSizes[f]}
+	}
+	tags := make([]interface{}, len(b.Tags))
+	for i, t := range b.Tags {
+		tags[i] = t
+	}
+	return map[string]interface{}{
+		"id":         b.ID,
+		"folder":     b.Folder,
+		"status":     b.Status,
+		"files":      files,
+		"file_count": len(b.Files),
+		"total_size": b.TotalSize,
+		"start_time": b.StartTime,
+		"end_time":   b.LastTime,
+		"check_code": b.CheckCode,
+		"uploader":   b.Uploader,
+		"tags":       tags,
+	}
+}
+
+// scriptError returns a message as a Tengo error value
+func scriptError(format string, args ...interface{}) tengo.Object {
+	return &tengo.Error{Value: &tengo.String{Value: fmt.Sprintf(format, args...)}}
+}
+
+// scriptStrings reads n string arguments
+func scriptStrings(args []tengo.Object, n int) ([]string, error) {
+	if len(args) != n {
+		return nil, tengo.ErrWrongNumArguments
+	}
+	out := make([]string, n)
+	for i, a := range args {
+		s, ok := tengo.ToString(a)
+		if !ok {
+			return nil, tengo.ErrInvalidArgumentType{Name: fmt.Sprintf("#%d", i+1), Expected: "string", Found: a.TypeName()}
+		}
+		out[i] = s
+	}
+	return out, nil
+}
+
+// module is the fidruawatch module: notify(title, message), log(message),
+// tag(batch_id, tag) and move(path, dir). move only takes files below the
+// watch folder and returns the new path.
+func (h *scriptHost) module() map[string]tengo.Object {
+	return map[string]tengo.Object{
+		"notify": &tengo.UserFunction{Name: "notify", Value: func(args ...tengo.Object) (tengo.Object, error) {
+			s, err := scriptStrings(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			h.mu.Lock()
+			app := h.app
+			h.mu.Unlock()
+			sendNotification(app, s[0], s[1])
+			return tengo.UndefinedValue, nil
+		}},
+		"log": &tengo.UserFunction{Name: "log", Value: func(args ...tengo.Object) (tengo.Object, error) {
+			s, err := scriptStrings(args, 1)
+			if err != nil {
+				return nil, err
+			}
+			logEvent("脚本: %s", s[0])
+			return tengo.UndefinedValue, nil
+		}},
+		"tag": &tengo.UserFunction{Name: "tag", Value: func(args ...tengo.Object) (tengo.Object, error) {
+			s, err := scriptStrings(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			batchesMu.Lock()
+			defer batchesMu.Unlock()
+			b, ok := batches[s[0]]
+			if !ok {
+				return scriptError("批次 %s 不存在", s[0]), nil
+			}
+			addBatchTag(b, s[1])
+			recordHistory(b)
+			return tengo.TrueValue, nil
+		}},
+		"move": &tengo.UserFunction{Name: "move", Value: func(args ...tengo.Object) (tengo.Object, error) {
+			s, err := scriptStrings(args, 2)
+			if err != nil {
+				return nil, err
+			}
+			dst, err := scriptMove(s[0], s[1])
+			if err != nil {
+				return scriptError("%v", err), nil
+			}
+			return &tengo.String{Value: dst}, nil
+		}},
+	}
+}
+
+// scriptMove moves a file below the watch folder into dir
+func scriptMove(src, dir string) (string, error) {
+	src = filepath.Clean(src)
+	rel, err := filepath.Rel(filepath.Clean(monitorPath), src)
+	if monitorPath == "" || isRemoteWatchPath(monitorPath) || err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("只能移动监控文件夹中的文件: %s", src)
+	}
+	dst := filepath.Join(dir, filepath.Base(src))
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("目标文件已存在: %s", dst)
+	}
+	release := claimOwnOutput(dst)
+	defer release()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	if err := moveFile(src, dst); err != nil {
+		return "", err
+	}
+	logEvent("脚本已移动 %s 到 %s", src, dir)
+	return dst, nil
+}
+
+// scriptHTTPModule is the http module: post(url, body[, content_type])
+// returns {status, body}. Only http and https URLs are allowed; the content
+// type defaults to application/json.
+func scriptHTTPModule() map[string]tengo.Object {
+	client := &http.Client{Timeout: scriptHTTPTimeout}
+	return map[string]tengo.Object{
+		"post": &tengo.UserFunction{Name: "post", Value: func(args ...tengo.Object) (tengo.Object, error) {
+			if len(args) == 2 {
+				args = append(args, &tengo.String{Value: "application/json"})
+			}
+			s, err := scriptStrings(args, 3)
+			if err != nil {
+				return nil, err
+			}
+			if !strings.HasPrefix(s[0], "http://") && !strings.HasPrefix(s[0], "https://") {
+				return scriptError("只支持 http/https 地址: %s", s[0]), nil
+			}
+			resp, err := client.Post(s[0], s[2], bytes.NewBufferString(s[1]))
+			if err != nil {
+				return scriptError("%v", err), nil
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+			return &tengo.ImmutableMap{Value: map[string]tengo.Object{
+				"status": &tengo.Int{Value: int64(resp.StatusCode)},
+				"body":   &tengo.String{Value: string(body)},
+			}}, nil
+		}},
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestScriptHooks(t *testing.T) {
+	origConfig, origPath, origDir, origHost := config, monitorPath, scriptsDir, scripts
+	defer func() { config, monitorPath, scriptsDir, scripts = origConfig, origPath, origDir, origHost }()
+	config = defaultConfig()
+	config.SaveHistory = false
+	config.ScriptsEnabled = true
+	monitorPath = t.TempDir()
+	scriptsDir = t.TempDir()
+	scripts = &scriptHost{}
+
+	write := func(name, src string) {
+		if err := os.WriteFile(filepath.Join(scriptsDir, name), []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.tengo", `
+text := import("text")
+onFileDetected := func(file) {
+	return !text.has_suffix(file.name, ".tmp") && file.size != 13
+}
+`)
+	write("b.tengo", `
+fw := import("fidruawatch")
+onBatchCompleted := func(batch) {
+	if batch.file_count == 2 && batch.files[0].name == "a.mp4" {
+		fw.tag(batch.id, "pair")
+	}
+}
+`)
+	write("broken.tengo", "onFileDetected := func(file) { return false ")
+	write("notes.txt", "onFileDetected := func(file) { return false }")
+
+	tests := []struct {
+		name string
+		size int64
+		want bool
+	}{
+		{"clip.mp4", 100, true},
+		{"clip.tmp", 100, false},
+		{"odd.mp4", 13, false},
+	}
+	for _, tt := range tests {
+		if got := scriptAllowsFile(filepath.Join(monitorPath, tt.name), tt.size); got != tt.want {
+			t.Errorf("scriptAllowsFile(%s) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	if allow, known := scriptDecision(filepath.Join(monitorPath, "clip.tmp")); !known || allow {
+		t.Errorf("scriptDecision(clip.tmp) = %v, %v, want false, true", allow, known)
+	}
+
+	b := &Batch{ID: "s1", Folder: monitorPath, Files: []string{"a.mp4", "b.mp4"}, FileSizes: map[string]int64{"a.mp4": 1, "b.mp4": 2}}
+	batchesMu.Lock()
+	batches[b.ID] = b
+	batchesMu.Unlock()
+	defer func() {
+		batchesMu.Lock()
+		delete(batches, b.ID)
+		batchesMu.Unlock()
+	}()
+	runBatchScripts(context.Background(), b)
+	if !reflect.DeepEqual(b.Tags, []string{"pair"}) {
+		t.Errorf("tags = %v, want [pair]", b.Tags)
+	}
+
+	config.ScriptsEnabled = false
+	if !scriptAllowsFile(filepath.Join(monitorPath, "other.tmp"), 1) {
+		t.Error("disabled scripts still ignored a file")
+	}
+}
+
+func TestScriptMove(t *testing.T) {
+	origPath := monitorPath
+	defer func() { monitorPath = origPath }()
+	monitorPath = t.TempDir()
+	src := filepath.Join(monitorPath, "clip.mp4")
+	if err := os.WriteFile(src, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dest := t.TempDir()
+
+	outside := filepath.Join(dest, "other.mp4")
+	os.WriteFile(outside, []byte("x"), 0644)
+	if _, err := scriptMove(outside, monitorPath); err == nil {
+		t.Error("moved a file outside the watch folder")
+	}
+	got, err := scriptMove(src, filepath.Join(dest, "done"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(dest, "done", "clip.mp4"); got != want {
+		t.Errorf("moved to %s, want %s", got, want)
+	}
+	if _, err := os.Stat(got); err != nil {
+		t.Error(err)
+	}
+}
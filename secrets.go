@@ -0,0 +1,369 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/zalando/go-keyring"
+)
+
+// Where secret settings are kept. The config file then only holds a
+// reference like "secret:api_token".
+const (
+	secretStoreKeyring = "keyring" // OS credential store, the encrypted file where there is none
+	secretStoreFile    = "file"    // encrypted file in the settings folder
+	secretStorePlain   = "plain"   // in the config file as plain text
+)
+
+var secretStoreOptions = []struct {
+	Store string
+	Label string
+}{
+	{secretStoreKeyring, "系统钥匙串 (不可用时用加密文件)"},
+	{secretStoreFile, "加密文件"},
+	{secretStorePlain, "明文写在配置文件中"},
+}
+
+// secretRef prefixes a config value naming a stored secret
+const secretRef = "secret:"
+
+// keyringService groups FidruaWatch's entries in the OS credential store
+const keyringService = "FidruaWatch"
+
+// secretsPath is the encrypted secrets file
+var secretsPath string
+
+// secretsKeyPath holds the key of the secrets file. It is kept out of the
+// settings folder, in a per-user folder that is not roamed or synced, so a
+// copy of the settings folder does not carry the key with it.
+var secretsKeyPath string
+
+// localStateDir returns the per-user folder for state that stays on this
+// machine: %LocalAppData% on Windows, $XDG_STATE_HOME or ~/.local/state
+// elsewhere
+func localStateDir() (string, error) {
+	if runtime.GOOS == "windows" {
+		return os.UserCacheDir()
+	}
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".local", "state"), nil
+}
+
+// secretField is a setting holding a secret, by config key
+type secretField struct {
+	key   string
+	field *string
+}
+
+// secretFields returns the secret settings of c
+func secretFields(c *Config) []secretField {
+	return []secretField{
+		{"api_token", &c.APIToken},
+		{"sign_token", &c.SignToken},
+		{"webdav_pass", &c.WebDAVPass},
+		{"cloud_secret", &c.CloudSecret},
+		{"cloud_refresh", &c.CloudRefresh},
+		{"team_token", &c.TeamToken},
+	}
+}
+
+// secretBackend stores secrets by name
+type secretBackend interface {
+	Get(name string) (string, error)
+	Set(name, value string) error
+	Delete(name string) error
+}
+
+// keyringBackend is the OS credential store: Windows Credential Manager,
+// the macOS Keychain or the Secret Service (libsecret) on Linux
+type keyringBackend struct{}
+
+func (keyringBackend) Get(name string) (string, error) { return keyring.Get(keyringService, name) }
+func (keyringBackend) Set(name, value string) error    { return keyring.Set(keyringService, name, value) }
+func (keyringBackend) Delete(name string) error        { return keyring.Delete(keyringService, name) }
+
+// fileBackend keeps secrets as an AES-GCM encrypted JSON object. The key is
+// in secretsKeyPath, readable only by the user, so the file protects secrets
+// in a shared or synced config folder rather than from the user's account.
+type fileBackend struct {
+	mu sync.Mutex
+}
+
+var errSecretNotFound = errors.New("未找到")
+
+// fileKey reads the key of the secrets file, moving one left next to the
+// file by older versions. A missing key is created when create is set.
+func fileKey(create bool) ([]byte, error) {
+	if secretsKeyPath == "" {
+		return nil, errors.New("没有存放密钥的位置")
+	}
+	key, err := os.ReadFile(secretsKeyPath)
+	if os.IsNotExist(err) {
+		legacyPath := secretsPath + ".key"
+		switch key, err = os.ReadFile(legacyPath); {
+		case err == nil && len(key) == 32:
+			if err := writeSecretsKey(key); err != nil {
+				return nil, err
+			}
+			os.Remove(legacyPath)
+		case err == nil:
+			return nil, fmt.Errorf("密钥文件 %s 已损坏", legacyPath)
+		case !os.IsNotExist(err):
+			return nil, err
+		case !create:
+			return nil, fmt.Errorf("找不到密钥文件 %s", secretsKeyPath)
+		default:
+			key = make([]byte, 32)
+			if _, err := rand.Read(key); err != nil {
+				return nil, err
+			}
+			if err := writeSecretsKey(key); err != nil {
+				return nil, err
+			}
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("密钥文件 %s 已损坏", secretsKeyPath)
+	}
+	return key, nil
+}
+
+// writeSecretsKey saves the key in secretsKeyPath
+func writeSecretsKey(key []byte) error {
+	if err := os.MkdirAll(filepath.Dir(secretsKeyPath), 0700); err != nil {
+		return err
+	}
+	return writeFileAtomic(secretsKeyPath, key, 0600)
+}
+
+// aead returns the cipher. With create, a missing key is created, which is
+// only right when there is no file yet to decrypt.
+func (f *fileBackend) aead(create bool) (cipher.AEAD, error) {
+	key, err := fileKey(create)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// load decrypts the whole file; a missing file is empty
+func (f *fileBackend) load() (map[string]string, error) {
+	secrets := make(map[string]string)
+	data, err := os.ReadFile(secretsPath)
+	if os.IsNotExist(err) {
+		return secrets, nil
+	} else if err != nil {
+		return nil, err
+	}
+	gcm, err := f.aead(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("%s 已损坏", secretsPath)
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, fmt.Errorf("无法解密 %s: %v", secretsPath, err)
+	}
+	return secrets, json.Unmarshal(plain, &secrets)
+}
+
+// save encrypts secrets with a fresh nonce and replaces the file
+func (f *fileBackend) save(secrets map[string]string) error {
+	gcm, err := f.aead(true)
+	if err != nil {
+		return err
+	}
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	return writeFileAtomic(secretsPath, gcm.Seal(nonce, nonce, plain, nil), 0600)
+}
+
+func (f *fileBackend) Get(name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secrets, err := f.load()
+	if err != nil {
+		return "", err
+	}
+	v, ok := secrets[name]
+	if !ok {
+		return "", errSecretNotFound
+	}
+	return v, nil
+}
+
+func (f *fileBackend) Set(name, value string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return f.save(secrets)
+}
+
+func (f *fileBackend) Delete(name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	secrets, err := f.load()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return nil
+	}
+	delete(secrets, name)
+	return f.save(secrets)
+}
+
+// Backends, variables so tests can keep off the real credential store
+var (
+	osKeyring  secretBackend = keyringBackend{}
+	secretFile secretBackend = &fileBackend{}
+)
+
+var (
+	secretsMu sync.Mutex
+	// sealedSecrets are the values last stored in or read from sealedStore,
+	// by config key, so saving unchanged settings doesn't rewrite the store
+	sealedSecrets = make(map[string]string)
+	sealedStore   string
+)
+
+// lookupSecret reads a stored secret, from the credential store first
+// unless store is the file
+func lookupSecret(name, store string) (string, error) {
+	if store != secretStoreFile {
+		if v, err := osKeyring.Get(name); err == nil {
+			return v, nil
+		}
+	}
+	return secretFile.Get(name)
+}
+
+// storeSecret stores a secret in store, falling back to the encrypted file
+// when the credential store is unavailable
+func storeSecret(name, value, store string) error {
+	if store == secretStoreKeyring {
+		err := osKeyring.Set(name, value)
+		if err == nil {
+			secretFile.Delete(name)
+			return nil
+		}
+		logEvent("系统钥匙串不可用, 密钥 %s 改存加密文件: %v", name, err)
+	}
+	return secretFile.Set(name, value)
+}
+
+// deleteSecret removes a secret from both places
+func deleteSecret(name string) {
+	osKeyring.Delete(name)
+	secretFile.Delete(name)
+}
+
+// sealSecrets stores the plain secrets of c and returns c with references
+// in their place, for writing to the config file. A secret that can't be
+// stored stays in plain text rather than being lost.
+func sealSecrets(c Config) Config {
+	if c.SecretStore == secretStorePlain {
+		return c
+	}
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	if c.SecretStore != sealedStore {
+		sealedSecrets = make(map[string]string)
+		sealedStore = c.SecretStore
+	}
+	for _, f := range secretFields(&c) {
+		v := *f.field
+		switch {
+		case strings.HasPrefix(v, secretRef):
+		case v == "":
+			if sealedSecrets[f.key] != "" {
+				deleteSecret(f.key)
+				delete(sealedSecrets, f.key)
+			}
+		default:
+			if sealedSecrets[f.key] != v {
+				if err := storeSecret(f.key, v, c.SecretStore); err != nil {
+					logEvent("保存密钥 %s 失败, 仍以明文写入配置文件: %v", f.key, err)
+					continue
+				}
+				sealedSecrets[f.key] = v
+			}
+			*f.field = secretRef + f.key
+		}
+	}
+	return c
+}
+
+// referenceSecrets replaces the plain secrets of an older copy of the
+// settings, e.g. a backup, with references to the stored ones, so no copy
+// keeps them in plain text
+func referenceSecrets(c Config) Config {
+	if config.SecretStore == secretStorePlain {
+		return c
+	}
+	for _, f := range secretFields(&c) {
+		if *f.field != "" && !strings.HasPrefix(*f.field, secretRef) {
+			*f.field = secretRef + f.key
+		}
+	}
+	return c
+}
+
+// openSecrets replaces the references in c with the stored secrets and
+// returns a description of each one that can't be read; those are left
+// empty
+func openSecrets(c *Config) []string {
+	secretsMu.Lock()
+	defer secretsMu.Unlock()
+	var problems []string
+	for _, f := range secretFields(c) {
+		name, ok := strings.CutPrefix(*f.field, secretRef)
+		if !ok {
+			continue
+		}
+		v, err := lookupSecret(name, c.SecretStore)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: 无法读取密钥 %q: %v", f.key, name, err))
+			*f.field = ""
+			continue
+		}
+		*f.field = v
+		if name == f.key && c.SecretStore == sealedStore {
+			sealedSecrets[f.key] = v
+		}
+	}
+	return problems
+}
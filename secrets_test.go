@@ -0,0 +1,216 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memSecrets stands in for the OS credential store in tests
+type memSecrets struct {
+	mu     sync.Mutex
+	values map[string]string
+	broken bool // every call fails, like a desktop without a keyring
+}
+
+func (m *memSecrets) Get(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	v, ok := m.values[name]
+	if m.broken || !ok {
+		return "", errors.New("not found")
+	}
+	return v, nil
+}
+
+func (m *memSecrets) Set(name, value string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.broken {
+		return errors.New("no keyring")
+	}
+	m.values[name] = value
+	return nil
+}
+
+func (m *memSecrets) Delete(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.values, name)
+	return nil
+}
+
+func init() {
+	// Tests never touch the real credential store or secrets file
+	osKeyring = &memSecrets{values: make(map[string]string)}
+	dir, err := os.MkdirTemp("", "fidruawatch-secrets")
+	if err != nil {
+		panic(err)
+	}
+	secretsPath = filepath.Join(dir, "config", "secrets.enc")
+	secretsKeyPath = filepath.Join(dir, "state", "secrets.key")
+}
+
+// withSecretStores gives a test an empty keyring and secrets file
+func withSecretStores(t *testing.T) *memSecrets {
+	origKeyring, origPath, origKeyPath, origConfig := osKeyring, secretsPath, secretsKeyPath, config
+	t.Cleanup(func() {
+		osKeyring, secretsPath, secretsKeyPath, config = origKeyring, origPath, origKeyPath, origConfig
+		sealedSecrets, sealedStore = make(map[string]string), ""
+	})
+	mem := &memSecrets{values: make(map[string]string)}
+	osKeyring = mem
+	secretsPath = filepath.Join(t.TempDir(), "secrets.enc")
+	secretsKeyPath = filepath.Join(t.TempDir(), "state", "secrets.key")
+	sealedSecrets, sealedStore = make(map[string]string), ""
+	config = defaultConfig()
+	return mem
+}
+
+func TestSealAndOpenSecrets(t *testing.T) {
+	mem := withSecretStores(t)
+	c := defaultConfig()
+	c.APIToken = "tok"
+	c.WebDAVPass = "pw"
+
+	sealed := sealSecrets(c)
+	if sealed.APIToken != "secret:api_token" || sealed.WebDAVPass != "secret:webdav_pass" || sealed.SignToken != "" {
+		t.Errorf("sealed = %q, %q, %q", sealed.APIToken, sealed.WebDAVPass, sealed.SignToken)
+	}
+	if c.APIToken != "tok" {
+		t.Error("sealSecrets changed its argument")
+	}
+	if mem.values["api_token"] != "tok" || mem.values["webdav_pass"] != "pw" {
+		t.Errorf("keyring = %v", mem.values)
+	}
+	if problems := openSecrets(&sealed); len(problems) > 0 || sealed.APIToken != "tok" || sealed.WebDAVPass != "pw" {
+		t.Errorf("openSecrets = %v, %q, %q", problems, sealed.APIToken, sealed.WebDAVPass)
+	}
+
+	// Clearing a secret removes it from the store
+	c.APIToken = ""
+	sealSecrets(c)
+	if _, ok := mem.values["api_token"]; ok {
+		t.Error("cleared secret still stored")
+	}
+
+	missing := Config{SecretStore: secretStoreKeyring, TeamToken: "secret:nope"}
+	if problems := openSecrets(&missing); len(problems) != 1 || missing.TeamToken != "" {
+		t.Errorf("missing secret: %v, %q", problems, missing.TeamToken)
+	}
+}
+
+func TestSecretsFileFallback(t *testing.T) {
+	mem := withSecretStores(t)
+	mem.broken = true
+	c := defaultConfig()
+	c.CloudRefresh = "refresh-token-123"
+
+	sealed := sealSecrets(c)
+	if sealed.CloudRefresh != "secret:cloud_refresh" {
+		t.Fatalf("sealed = %q", sealed.CloudRefresh)
+	}
+	data, err := os.ReadFile(secretsPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "refresh-token-123") {
+		t.Error("secrets file holds the secret in plain text")
+	}
+	if problems := openSecrets(&sealed); len(problems) > 0 || sealed.CloudRefresh != "refresh-token-123" {
+		t.Errorf("openSecrets = %v, %q", problems, sealed.CloudRefresh)
+	}
+	// The key stays out of the settings folder
+	if _, err := os.Stat(secretsKeyPath); err != nil {
+		t.Errorf("key not in %s: %v", secretsKeyPath, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(secretsPath)); len(entries) != 1 {
+		t.Errorf("settings folder holds %d files, want only secrets.enc", len(entries))
+	}
+
+	// A damaged key makes the file unreadable instead of returning garbage
+	os.WriteFile(secretsKeyPath, make([]byte, 32), 0600)
+	again := Config{CloudRefresh: "secret:cloud_refresh"}
+	if problems := openSecrets(&again); len(problems) != 1 {
+		t.Errorf("wrong key: problems %v", problems)
+	}
+}
+
+func TestSecretStorePlain(t *testing.T) {
+	mem := withSecretStores(t)
+	c := defaultConfig()
+	c.SecretStore = secretStorePlain
+	c.APIToken = "tok"
+	if sealed := sealSecrets(c); sealed.APIToken != "tok" || len(mem.values) > 0 {
+		t.Errorf("plain store sealed %q, keyring %v", sealed.APIToken, mem.values)
+	}
+}
+
+func TestConfigFileKeepsNoSecrets(t *testing.T) {
+	withSecretStores(t)
+	origPath := configPath
+	defer func() { configPath = origPath }()
+	configPath = filepath.Join(t.TempDir(), "config.json")
+
+	config.APIToken = "old-token"
+	config.SecretStore = secretStorePlain
+	if err := writeConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	config.SecretStore = secretStoreKeyring
+	config.APIToken = "new-token"
+	if err := writeConfigFile(); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range append([]string{configPath}, configBackupPaths()...) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			continue
+		}
+		if strings.Contains(string(data), "-token") {
+			t.Errorf("%s holds a token in plain text:\n%s", filepath.Base(p), data)
+		}
+	}
+
+	config = defaultConfig()
+	if err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if config.APIToken != "new-token" {
+		t.Errorf("loaded api token %q, want new-token", config.APIToken)
+	}
+}
+
+func TestSecretsKeyMovesOutOfSettingsFolder(t *testing.T) {
+	mem := withSecretStores(t)
+	mem.broken = true
+	c := defaultConfig()
+	c.WebDAVPass = "pw"
+	sealed := sealSecrets(c)
+
+	// Older versions kept the key next to the file
+	legacyPath := secretsPath + ".key"
+	if err := os.Rename(secretsKeyPath, legacyPath); err != nil {
+		t.Fatal(err)
+	}
+	if problems := openSecrets(&sealed); len(problems) > 0 || sealed.WebDAVPass != "pw" {
+		t.Fatalf("openSecrets with the old key = %v, %q", problems, sealed.WebDAVPass)
+	}
+	if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+		t.Errorf("old key left in the settings folder: %v", err)
+	}
+
+	// Without its key, e.g. a settings folder synced to another machine,
+	// the file is reported unreadable rather than given a new key
+	os.Remove(secretsKeyPath)
+	again := Config{WebDAVPass: "secret:webdav_pass"}
+	if problems := openSecrets(&again); len(problems) != 1 {
+		t.Errorf("missing key: problems %v", problems)
+	}
+	if _, err := os.Stat(secretsKeyPath); !os.IsNotExist(err) {
+		t.Errorf("a new key was created for an existing file: %v", err)
+	}
+}
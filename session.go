@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// sessionID identifies the current monitoring session in logs, batches and
+// notifications, so events can be correlated across log files and reports.
+// It is set before the monitoring goroutines start and empty when idle.
+var sessionID string
+
+// newSessionID returns an ID like "20240301-a1b2c3d4": sortable by start
+// date and unique enough to tell sessions of the same day apart
+func newSessionID() string {
+	buf := make([]byte, 4)
+	if _, err := rand.Read(buf); err != nil {
+		return time.Now().Format("20060102-150405")
+	}
+	return time.Now().Format("20060102") + "-" + hex.EncodeToString(buf)
+}
+
+// logEvent writes a log line tagged with the current session ID
+func logEvent(format string, args ...interface{}) {
+	id := sessionID
+	if id == "" {
+		id = "idle"
+	}
+	log.Printf("[%s] %s", id, fmt.Sprintf(format, args...))
+}
+
+// sendNotification shows a desktop notification tagged with the session ID
+func sendNotification(app fyne.App, title, content string) {
+	sendBatchNotification(app, "", title, content)
+}
+
+// sendBatchNotification is sendNotification about one batch, which
+// clicking the notification shows
+func sendBatchNotification(app fyne.App, batchID, title, content string) {
+	if sessionID != "" {
+		content += "\n会话 " + sessionID
+	}
+	logEvent("通知: %s - %s", title, content)
+	notifyPlugins(title, content)
+	if app == nil {
+		return
+	}
+	if muted, _ := mute.Active(time.Now()); muted {
+		logEvent("静音中, 不弹出通知: %s", title)
+		return
+	}
+	ok, held := toastLimit.Allow(time.Now(), config.NotifyPerMinute)
+	if !ok {
+		logEvent("通知过于频繁, 暂不弹出: %s", title)
+		return
+	}
+	if held > 0 {
+		content += fmt.Sprintf("\n(另有 %d 条通知因过于频繁未弹出)", held)
+	}
+	if batchID != "" {
+		if notifyWithAction(title, content, batchID) {
+			return
+		}
+		lastNotified.Note(batchID, time.Now())
+	}
+	app.SendNotification(&fyne.Notification{Title: title, Content: content})
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestNewSessionID(t *testing.T) {
+	pattern := regexp.MustCompile(`^\d{8}-[0-9a-f]{8}$`)
+	a, b := newSessionID(), newSessionID()
+	if !pattern.MatchString(a) {
+		t.Errorf("newSessionID() = %q, want YYYYMMDD-xxxxxxxx", a)
+	}
+	if a == b {
+		t.Errorf("Expected unique session IDs, got %q twice", a)
+	}
+}
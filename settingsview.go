@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/widget"
+)
+
+// settingItem is one row of the settings tab. Keywords is what the settings
+// search box matches against: the row's label plus any alternative terms.
+type settingItem struct {
+	Keywords string
+	Object   fyne.CanvasObject
+}
+
+// settingsSection is a collapsible group of settings
+type settingsSection struct {
+	Title string
+	Items []settingItem
+}
+
+// settingMatches reports whether every word of query occurs in keywords,
+// ignoring case. An empty query matches everything.
+func settingMatches(keywords, query string) bool {
+	keywords = strings.ToLower(keywords)
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		if !strings.Contains(keywords, word) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterSettings shows the rows matching query, hides the rest, and returns
+// for each section whether any of its rows (or its title) matched
+func filterSettings(sections []settingsSection, query string) []bool {
+	visible := make([]bool, len(sections))
+	for i, s := range sections {
+		titleMatch := strings.TrimSpace(query) != "" && settingMatches(s.Title, query)
+		for _, item := range s.Items {
+			if titleMatch || settingMatches(item.Keywords, query) {
+				item.Object.Show()
+				visible[i] = true
+			} else {
+				item.Object.Hide()
+			}
+		}
+	}
+	return visible
+}
+
+// newSettingsView lays the sections out as an accordion under a search box.
+// While searching, sections without matches are removed and the remaining
+// ones are expanded; clearing the search restores the collapsed layout.
+func newSettingsView(sections []settingsSection, footer fyne.CanvasObject) fyne.CanvasObject {
+	items := make([]*widget.AccordionItem, len(sections))
+	for i, s := range sections {
+		objects := make([]fyne.CanvasObject, len(s.Items))
+		for j, item := range s.Items {
+			objects[j] = item.Object
+		}
+		items[i] = widget.NewAccordionItem(s.Title, container.NewVBox(objects...))
+	}
+	accordion := widget.NewAccordion(items...)
+	accordion.MultiOpen = true
+	if len(items) > 0 {
+		accordion.Open(0)
+	}
+
+	noMatch := widget.NewLabel("没有匹配的设置项")
+	noMatch.Hide()
+
+	search := widget.NewEntry()
+	search.SetPlaceHolder("🔍 搜索设置...")
+	search.OnChanged = func(query string) {
+		visible := filterSettings(sections, query)
+		searching := strings.TrimSpace(query) != ""
+		shown := make([]*widget.AccordionItem, 0, len(items))
+		for i, item := range items {
+			if !visible[i] {
+				continue
+			}
+			item.Open = searching
+			shown = append(shown, item)
+		}
+		if !searching && len(shown) > 0 {
+			shown[0].Open = true
+		}
+		accordion.Items = shown
+		accordion.Refresh()
+		if len(shown) == 0 {
+			noMatch.Show()
+		} else {
+			noMatch.Hide()
+		}
+	}
+
+	body := container.NewVScroll(container.NewVBox(noMatch, accordion))
+	return container.NewBorder(search, footer, nil, nil, body)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2/widget"
+)
+
+func TestSettingMatches(t *testing.T) {
+	cases := []struct {
+		keywords, query string
+		want            bool
+	}{
+		{"完成判定 超时 timeout", "", true},
+		{"完成判定 超时 timeout", "超时", true},
+		{"完成判定 超时 timeout", "TimeOut", true},
+		{"完成判定 超时 timeout", "超时 timeout", true},
+		{"完成判定 超时 timeout", "超时 sound", false},
+	}
+	for _, c := range cases {
+		if got := settingMatches(c.keywords, c.query); got != c.want {
+			t.Errorf("settingMatches(%q, %q) = %v, want %v", c.keywords, c.query, got, c.want)
+		}
+	}
+}
+
+func TestFilterSettings(t *testing.T) {
+	timeout := widget.NewLabel("timeout")
+	sound := widget.NewLabel("sound")
+	token := widget.NewLabel("token")
+	sections := []settingsSection{
+		{"📁 文件监控", []settingItem{{"完成判定 超时", timeout}}},
+		{"🔔 通知设置", []settingItem{{"声音提醒", sound}}},
+		{"🔌 API", []settingItem{{"访问令牌", token}}},
+	}
+
+	visible := filterSettings(sections, "声音")
+	if visible[0] || !visible[1] || visible[2] {
+		t.Errorf("visible = %v, want only the notification section", visible)
+	}
+	if timeout.Visible() || !sound.Visible() || token.Visible() {
+		t.Error("only the matching row should be shown")
+	}
+
+	// A matching section title shows all of its rows
+	visible = filterSettings(sections, "api")
+	if !visible[2] || !token.Visible() || sound.Visible() {
+		t.Errorf("title match: visible = %v", visible)
+	}
+
+	visible = filterSettings(sections, "")
+	for i, v := range visible {
+		if !v {
+			t.Errorf("section %d hidden with empty query", i)
+		}
+	}
+	if !timeout.Visible() || !sound.Visible() || !token.Visible() {
+		t.Error("empty query should show every row")
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/dialog"
+)
+
+// appCtx is cancelled when the app quits, ending the background work that
+// outlives a monitoring session
+var appCtx, appCancel = context.WithCancel(context.Background())
+
+var shutdownOnce sync.Once
+
+// finishShutdown stops the API servers and writes what must survive the
+// restart: pending history lines and the in-flight batches
+func finishShutdown() {
+	shutdownOnce.Do(func() {
+		appCancel()
+		if apiServer != nil {
+			apiServer.Close()
+			apiServer = nil
+		}
+		if grpcServer != nil {
+			grpcServer.Stop()
+			grpcServer = nil
+		}
+		// History is appended synchronously; taking the lock waits for a
+		// write in progress
+		historyMu.Lock()
+		historyMu.Unlock()
+		saveCheckpoint()
+		logEvent("已退出")
+	})
+}
+
+// confirmQuit asks before quitting while batches are still uploading, when
+// enabled; otherwise, or once confirmed, it calls quit
+func confirmQuit(w fyne.Window, quit func()) {
+	batchesMu.Lock()
+	uploading := uploadingCount()
+	batchesMu.Unlock()
+	if uploading == 0 || !config.ConfirmQuit {
+		quit()
+		return
+	}
+	dialog.ShowConfirm("退出 FidruaWatch",
+		fmt.Sprintf("还有 %d 个批次正在上传。\n退出后进度会保存，下次启动时继续跟踪。\n确定退出吗？", uploading),
+		func(ok bool) {
+			if ok {
+				quit()
+			}
+		}, w)
+}
@@ -0,0 +1,23 @@
+package main
+
+import "testing"
+
+func TestConfirmQuitSkipsDialog(t *testing.T) {
+	origBatches, origConfig := batches, config
+	defer func() { batches, config = origBatches, origConfig }()
+	config = defaultConfig()
+	batches = map[string]*Batch{"1": {ID: "1", Status: "completed"}}
+
+	quits := 0
+	confirmQuit(nil, func() { quits++ })
+	if quits != 1 {
+		t.Fatal("Should quit at once without uploads")
+	}
+
+	batches["2"] = &Batch{ID: "2", Status: "uploading"}
+	config.ConfirmQuit = false
+	confirmQuit(nil, func() { quits++ })
+	if quits != 2 {
+		t.Error("Should quit at once with confirmation disabled")
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sidecarExts are metadata files that travel with a photo, video or audio
+// file, mapped to the primary types they belong to (nil = any). They are
+// named after their primary, either replacing its extension (IMG_0001.xmp)
+// or appended to it (IMG_0001.CR2.xmp).
+var sidecarExts = map[string][]string{
+	".xmp": nil,       // Lightroom, darktable, Capture One
+	".thm": videoExts, // camera thumbnails
+	".srt": videoExts, // subtitles, drone telemetry
+	".lrc": audioExts, // lyrics
+}
+
+// isSidecar reports whether name is a sidecar file
+func isSidecar(name string) bool {
+	_, ok := sidecarExts[strings.ToLower(filepath.Ext(name))]
+	return ok
+}
+
+// sidecarPairing is how the files of a batch pair up
+type sidecarPairing struct {
+	Paired  map[string]string // sidecar -> its primary file
+	Orphans []string          // sidecars whose primary hasn't arrived
+	Waiting []string          // primaries missing a sidecar their siblings have
+}
+
+// pairSidecars matches the sidecars of a batch with their primaries.
+// Caller must hold batchesMu.
+func pairSidecars(b *Batch) sidecarPairing {
+	p := sidecarPairing{Paired: make(map[string]string)}
+	// Cameras mix cases (clip.MP4 + CLIP.THM), so names match ignoring case
+	key := func(p string) string { return strings.ToLower(pathKey(p)) }
+	byName := make(map[string]string) // key of a primary -> file
+	byStem := make(map[string][]string)
+	var sidecars []string
+	for _, f := range b.Files {
+		if isSidecar(f) {
+			sidecars = append(sidecars, f)
+			continue
+		}
+		byName[key(f)] = f
+		stem := key(strings.TrimSuffix(f, filepath.Ext(f)))
+		byStem[stem] = append(byStem[stem], f)
+	}
+
+	// sidecar extension -> categories of primaries having one
+	withSidecar := make(map[string]map[string]bool)
+	has := make(map[string]bool) // primary + sidecar ext
+	for _, s := range sidecars {
+		ext := strings.ToLower(filepath.Ext(s))
+		stem := strings.TrimSuffix(s, filepath.Ext(s))
+		primary, ok := byName[key(stem)]
+		if !ok {
+			for _, f := range byStem[key(stem)] {
+				if kinds := sidecarExts[ext]; kinds == nil || hasExt(f, kinds) {
+					primary, ok = f, true
+					break
+				}
+			}
+		}
+		if !ok {
+			p.Orphans = append(p.Orphans, s)
+			continue
+		}
+		p.Paired[s] = primary
+		has[primary+ext] = true
+		if withSidecar[ext] == nil {
+			withSidecar[ext] = make(map[string]bool)
+		}
+		withSidecar[ext][fileCategory(primary)] = true
+	}
+
+	// Once some files of a kind brought a sidecar, the others are expected
+	// to bring one too
+	for _, f := range byName {
+		for ext, cats := range withSidecar {
+			if cats[fileCategory(f)] && !has[f+ext] {
+				p.Waiting = append(p.Waiting, f)
+				break
+			}
+		}
+	}
+	sort.Strings(p.Waiting)
+	return p
+}
+
+// assetCount is the number of files of a batch not counting paired
+// sidecars. Caller must hold batchesMu.
+func assetCount(b *Batch) int {
+	if !config.PairSidecars {
+		return len(b.Files)
+	}
+	return len(b.Files) - len(pairSidecars(b).Paired)
+}
+
+// fileCountText describes the size of a batch in assets, e.g. "12个文件"
+// or "10个素材 +2个附属文件". Caller must hold batchesMu.
+func fileCountText(b *Batch) string {
+	assets := assetCount(b)
+	if sidecars := len(b.Files) - assets; sidecars > 0 {
+		return fmt.Sprintf("%d个素材 +%d个附属文件", assets, sidecars)
+	}
+	return fmt.Sprintf("%d个文件", len(b.Files))
+}
+
+// holdForSidecars holds completion while sidecars and their primaries are
+// still pairing up, for at most one more completion timeout.
+// Caller must hold batchesMu.
+func holdForSidecars(b *Batch) bool {
+	if !config.PairSidecars {
+		return false
+	}
+	p := pairSidecars(b)
+	if len(p.Orphans) == 0 && len(p.Waiting) == 0 {
+		return false
+	}
+	return time.Since(b.LastTime) < 2*batchTimeout(b)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPairSidecars(t *testing.T) {
+	b := &Batch{Files: []string{
+		"IMG_0001.CR2", "IMG_0001.xmp",
+		"IMG_0002.CR2", "IMG_0002.CR2.xmp",
+		"IMG_0003.CR2",
+		"clip.MP4", "CLIP.THM",
+		"song.lrc",
+		"notes.pdf",
+	}}
+	p := pairSidecars(b)
+	want := map[string]string{
+		"IMG_0001.xmp":     "IMG_0001.CR2",
+		"IMG_0002.CR2.xmp": "IMG_0002.CR2",
+		"CLIP.THM":         "clip.MP4",
+	}
+	if !reflect.DeepEqual(p.Paired, want) {
+		t.Errorf("Paired = %v, want %v", p.Paired, want)
+	}
+	if !reflect.DeepEqual(p.Orphans, []string{"song.lrc"}) {
+		t.Errorf("Orphans = %v, want [song.lrc]", p.Orphans)
+	}
+	// The other raw files are expected to get an .xmp as well; the pdf is not
+	if !reflect.DeepEqual(p.Waiting, []string{"IMG_0003.CR2"}) {
+		t.Errorf("Waiting = %v, want [IMG_0003.CR2]", p.Waiting)
+	}
+}
+
+func TestSidecarAccounting(t *testing.T) {
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{ImageEnabled: true, PairSidecars: true, CompletionTimeout: 30}
+
+	if !isMonitoredFile("/up/IMG_0001.xmp") {
+		t.Error("Expected sidecars to be monitored when pairing is on")
+	}
+
+	b := &Batch{Files: []string{"a.jpg", "a.xmp", "b.jpg"}, LastTime: time.Now()}
+	if got := fileCountText(b); got != "2个素材 +1个附属文件" {
+		t.Errorf("fileCountText = %q", got)
+	}
+	if !holdForSidecars(b) {
+		t.Error("Expected completion to wait for b.xmp")
+	}
+	b.LastTime = time.Now().Add(-61 * time.Second)
+	if holdForSidecars(b) {
+		t.Error("Expected the wait for sidecars to be bounded")
+	}
+
+	b.Files = append(b.Files, "b.xmp")
+	b.LastTime = time.Now()
+	if holdForSidecars(b) {
+		t.Error("Expected no hold once every sidecar arrived")
+	}
+
+	config.PairSidecars = false
+	if got := fileCountText(b); got != "4个文件" {
+		t.Errorf("fileCountText without pairing = %q", got)
+	}
+}
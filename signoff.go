@@ -0,0 +1,135 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/user"
+	"strings"
+	"time"
+)
+
+// statusReview is the state between completed and signed when two-person
+// sign-off is required: one operator has checked the delivery and another
+// has to sign it
+const statusReview = "review"
+
+// Sign-off actions
+const (
+	signActionReview = "review"
+	signActionSign   = "sign"
+)
+
+// SignOff is one step of a batch's sign-off trail
+type SignOff struct {
+	Action    string    `json:"action"`
+	By        string    `json:"by"`
+	At        time.Time `json:"at"`
+	Comment   string    `json:"comment,omitempty"`
+	Principal string    `json:"principal,omitempty"` // who was authenticated, see localPrincipal and apiPrincipal
+}
+
+var errSameReviewer = errors.New("复核和签收需要由不同的操作员完成")
+
+// localPrincipal identifies who signs in the window: the OS user running
+// the app, as anyone can type any operator name
+func localPrincipal() string {
+	if u, err := user.Current(); err == nil {
+		return "user:" + u.Username
+	}
+	return "user:"
+}
+
+// signBatch signs a batch as the configured operator without a comment.
+// Caller must hold batchesMu.
+func signBatch(b *Batch) error {
+	err := signBatchAs(b, config.OperatorName, localPrincipal(), "", time.Now())
+	if err != nil {
+		logEvent("签收批次 %s 失败: %v", b.ID, err)
+	}
+	return err
+}
+
+// signBatchAs advances a batch one step in the sign flow and records who did
+// it: completed batches go to review when two-person sign-off is required,
+// otherwise straight to signed; reviewed batches are signed by a different
+// operator. The operator is told apart by principal, who was authenticated,
+// as well as by the name given. Caller must hold batchesMu.
+func signBatchAs(b *Batch, by, principal, comment string, now time.Time) error {
+	by = strings.TrimSpace(by)
+	action := signActionSign
+	switch b.Status {
+	case "completed":
+		if config.RequireReview {
+			action = signActionReview
+		}
+	case statusReview:
+		if by == "" {
+			return errors.New("请填写签收人")
+		}
+		if reviewer := lastSignOff(b, signActionReview); reviewer != nil &&
+			(strings.EqualFold(reviewer.By, by) || reviewer.Principal != "" && reviewer.Principal == principal) {
+			return errSameReviewer
+		}
+	default:
+		return fmt.Errorf("批次状态为 %s, 不能签收", b.Status)
+	}
+
+	so := SignOff{Action: action, By: by, At: now, Comment: strings.TrimSpace(comment), Principal: principal}
+	b.SignOffs = append(b.SignOffs, so)
+	noteTimeline(b, timelineSigned, signOffSummary(so), now)
+	if action == signActionReview {
+		b.Status = statusReview
+	} else {
+		b.Status = "signed"
+	}
+	logEvent("批次 %s %s: %s", b.ID, signActionLabel(action), by)
+	recordHistory(b)
+	if action == signActionReview {
+		publishBatchEvent(eventReviewed, b)
+	} else {
+		publishBatchEvent(eventSigned, b)
+	}
+	return nil
+}
+
+// lastSignOff returns the latest trail entry with the given action
+func lastSignOff(b *Batch, action string) *SignOff {
+	for i := len(b.SignOffs) - 1; i >= 0; i-- {
+		if b.SignOffs[i].Action == action {
+			return &b.SignOffs[i]
+		}
+	}
+	return nil
+}
+
+func signActionLabel(action string) string {
+	if action == signActionReview {
+		return "已复核"
+	}
+	return "已签收"
+}
+
+// signOffText formats a trail entry for the detail dialog
+func signOffText(s SignOff) string {
+	by := s.By
+	if by == "" {
+		by = "(未署名)"
+	}
+	text := fmt.Sprintf("%s %s · %s", signActionLabel(s.Action), by, formatBatchTime(s.At, time.Now()))
+	if s.Comment != "" {
+		text += " · " + s.Comment
+	}
+	return text
+}
+
+// signOffSummary is signOffText without the time, for the batch timeline
+func signOffSummary(s SignOff) string {
+	text := signActionLabel(s.Action) + " " + s.By
+	if s.By == "" {
+		text += "(未署名)"
+	}
+	if s.Comment != "" {
+		text += " · " + s.Comment
+	}
+	return text
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTwoPersonSignOff(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.SaveHistory = false
+	config.RequireReview = true
+
+	now := time.Now()
+	b := &Batch{ID: "1", Status: "completed"}
+	if err := signBatchAs(b, "Alice", "user:alice", "画面已检查", now); err != nil {
+		t.Fatal(err)
+	}
+	if b.Status != statusReview {
+		t.Fatalf("status after review = %q, want %q", b.Status, statusReview)
+	}
+
+	// The reviewer can't also sign, under another name either
+	if err := signBatchAs(b, "Bob", "user:alice", "", now); err != errSameReviewer {
+		t.Errorf("same principal: err = %v, want errSameReviewer", err)
+	}
+	if err := signBatchAs(b, "alice", "user:bob", "", now); err != errSameReviewer {
+		t.Errorf("same operator: err = %v, want errSameReviewer", err)
+	}
+	if err := signBatchAs(b, "", "user:bob", "", now); err == nil {
+		t.Error("anonymous sign accepted after review")
+	}
+	if err := signBatchAs(b, "Bob", "user:bob", "", now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if b.Status != "signed" {
+		t.Errorf("status = %q, want signed", b.Status)
+	}
+
+	if len(b.SignOffs) != 2 || b.SignOffs[0].By != "Alice" || b.SignOffs[0].Comment != "画面已检查" || b.SignOffs[1].By != "Bob" {
+		t.Errorf("trail = %+v", b.SignOffs)
+	}
+	if err := signBatchAs(b, "Carol", "user:carol", "", now); err == nil {
+		t.Error("signed batch signed again")
+	}
+}
+
+func TestSingleSignOff(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.SaveHistory = false
+	config.RequireReview = false
+	config.OperatorName = "Alice"
+
+	b := &Batch{ID: "1", Status: "completed"}
+	signBatch(b)
+	if b.Status != "signed" || len(b.SignOffs) != 1 || b.SignOffs[0].By != "Alice" {
+		t.Errorf("status = %q, trail = %+v", b.Status, b.SignOffs)
+	}
+}
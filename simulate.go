@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// simScheme prefixes the watch path of the simulator, which makes up file
+// events instead of watching a folder, for trying out filters, grouping and
+// notifications or reproducing event storms. Nothing is read or written on
+// disk: simulated batches are handled like remote ones.
+const simScheme = "sim:"
+
+// simWatchPath is the watch path that runs the simulator
+const simWatchPath = simScheme + "/demo"
+
+// simTick is how often the simulator emits its share of events
+const simTick = 50 * time.Millisecond
+
+// simOptions shapes the simulated uploads
+type simOptions struct {
+	Rate          int      // events per second
+	MinSize       int64    // file sizes are uniform in [MinSize, MaxSize]
+	MaxSize       int64    //
+	Exts          []string // with dot, picked at random per file
+	Folders       int      // batches uploading at the same time
+	FilesPerBatch int
+}
+
+// simOptionsFromConfig reads the simulation settings, filling in usable
+// values for unset ones
+func simOptionsFromConfig() simOptions {
+	o := simOptions{
+		Rate:          config.SimRate,
+		MinSize:       int64(config.SimMinMB) << 20,
+		MaxSize:       int64(config.SimMaxMB) << 20,
+		Folders:       config.SimFolders,
+		FilesPerBatch: config.SimFilesPerBatch,
+	}
+	for _, ext := range strings.Split(config.SimExts, ",") {
+		if ext = strings.ToLower(strings.TrimSpace(ext)); ext != "" {
+			if !strings.HasPrefix(ext, ".") {
+				ext = "." + ext
+			}
+			o.Exts = append(o.Exts, ext)
+		}
+	}
+	if o.Rate < 1 {
+		o.Rate = 1
+	}
+	if o.MaxSize < o.MinSize {
+		o.MaxSize = o.MinSize
+	}
+	if len(o.Exts) == 0 {
+		o.Exts = []string{".mp4"}
+	}
+	if o.Folders < 1 {
+		o.Folders = 1
+	}
+	if o.FilesPerBatch < 1 {
+		o.FilesPerBatch = 1
+	}
+	return o
+}
+
+type simFile struct {
+	path         string
+	size, target int64
+}
+
+type simFolder struct {
+	dir   string
+	files []*simFile
+}
+
+// simulator generates the events of Folders concurrent uploads. Each file
+// arrives in one to four growing writes; a folder that has all its files
+// complete is replaced by a new one, so batches keep completing.
+type simulator struct {
+	opts    simOptions
+	root    string
+	rng     *rand.Rand
+	folders []*simFolder
+	started int // folders started so far, for names
+}
+
+func newSimulator(root string, opts simOptions, seed int64) *simulator {
+	s := &simulator{opts: opts, root: root, rng: rand.New(rand.NewSource(seed))}
+	for i := 0; i < opts.Folders; i++ {
+		s.folders = append(s.folders, s.newFolder())
+	}
+	return s
+}
+
+func (s *simulator) newFolder() *simFolder {
+	s.started++
+	return &simFolder{dir: filepath.Join(s.root, fmt.Sprintf("batch-%03d", s.started))}
+}
+
+// Next returns the next file event
+func (s *simulator) Next() remoteChange {
+	i := s.rng.Intn(len(s.folders))
+	f := s.folders[i]
+	var growing []*simFile
+	for _, file := range f.files {
+		if file.size < file.target {
+			growing = append(growing, file)
+		}
+	}
+	switch {
+	case len(growing) > 0 && (len(f.files) == s.opts.FilesPerBatch || s.rng.Intn(2) == 0):
+		file := growing[s.rng.Intn(len(growing))]
+		file.size += file.target / int64(1+s.rng.Intn(4))
+		if file.size > file.target {
+			file.size = file.target
+		}
+		return remoteChange{Path: file.path, Size: file.size}
+	case len(f.files) < s.opts.FilesPerBatch:
+		dir := f.dir
+		if s.rng.Intn(4) == 0 {
+			dir = filepath.Join(dir, fmt.Sprintf("cam%d", 1+s.rng.Intn(2)))
+		}
+		name := fmt.Sprintf("sim_%04d%s", len(f.files)+1, s.opts.Exts[s.rng.Intn(len(s.opts.Exts))])
+		target := s.opts.MinSize
+		if span := s.opts.MaxSize - s.opts.MinSize; span > 0 {
+			target += s.rng.Int63n(span + 1)
+		}
+		file := &simFile{path: filepath.Join(dir, name), target: target, size: target / 4}
+		f.files = append(f.files, file)
+		return remoteChange{Path: file.path, Size: file.size}
+	}
+	s.folders[i] = s.newFolder()
+	return s.Next()
+}
+
+// runSimulation feeds simulated events to ingest at opts.Rate until ctx is
+// cancelled
+func runSimulation(ctx context.Context, root string, opts simOptions, ingest func(remoteChange)) {
+	logEvent("模拟数据源: 每秒 %d 个事件, %d 个并行批次, 每批次 %d 个文件 (%s)",
+		opts.Rate, opts.Folders, opts.FilesPerBatch, strings.Join(opts.Exts, ", "))
+	sim := newSimulator(root, opts, time.Now().UnixNano())
+	ticker := time.NewTicker(simTick)
+	defer ticker.Stop()
+	var due float64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			due += float64(opts.Rate) * simTick.Seconds()
+			for ; due >= 1; due-- {
+				ingest(sim.Next())
+			}
+		}
+	}
+}
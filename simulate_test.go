@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSimOptionsFromConfig(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+
+	config = Config{SimRate: 0, SimMinMB: 8, SimMaxMB: 2, SimExts: " MP4, jpg ,,", SimFolders: 3}
+	o := simOptionsFromConfig()
+	if o.Rate != 1 || o.MinSize != 8<<20 || o.MaxSize != 8<<20 || o.Folders != 3 || o.FilesPerBatch != 1 {
+		t.Errorf("simOptionsFromConfig() = %+v", o)
+	}
+	if !reflect.DeepEqual(o.Exts, []string{".mp4", ".jpg"}) {
+		t.Errorf("Exts = %q", o.Exts)
+	}
+	config = Config{}
+	if o := simOptionsFromConfig(); !reflect.DeepEqual(o.Exts, []string{".mp4"}) {
+		t.Errorf("Exts without setting = %q", o.Exts)
+	}
+}
+
+func TestSimulator(t *testing.T) {
+	opts := simOptions{Rate: 1, MinSize: 10, MaxSize: 1000, Exts: []string{".mp4", ".jpg"}, Folders: 2, FilesPerBatch: 5}
+	sim := newSimulator(simWatchPath, opts, 1)
+	sizes := make(map[string]int64)
+	for i := 0; i < 500; i++ {
+		c := sim.Next()
+		if !strings.HasPrefix(c.Path, simWatchPath+"/batch-") {
+			t.Fatalf("Event outside the simulated root: %q", c.Path)
+		}
+		if c.Size < sizes[c.Path] {
+			t.Fatalf("%s shrank from %d to %d", c.Path, sizes[c.Path], c.Size)
+		}
+		sizes[c.Path] = c.Size
+	}
+	if sim.started <= opts.Folders {
+		t.Fatalf("No folder completed after 500 events (%d started)", sim.started)
+	}
+	// Every replaced folder got all its files, grown to a size in range
+	perFolder := make(map[string]int)
+	for path, size := range sizes {
+		folder := strings.Split(strings.TrimPrefix(path, simWatchPath+"/"), "/")[0]
+		perFolder[folder]++
+		if size < opts.MinSize/4 || size > opts.MaxSize {
+			t.Errorf("%s has size %d", path, size)
+		}
+	}
+	active := make(map[string]bool)
+	for _, f := range sim.folders {
+		active[strings.TrimPrefix(f.dir, simWatchPath+"/")] = true
+	}
+	for folder, n := range perFolder {
+		if !active[folder] && n != opts.FilesPerBatch {
+			t.Errorf("Completed folder %s has %d files, want %d", folder, n, opts.FilesPerBatch)
+		}
+	}
+	if !isRemoteWatchPath(simWatchPath) {
+		t.Error("Simulated watch path should be handled as remote")
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenPrivateSocket listens on a Unix socket only this user may connect
+// to. The umask keeps the socket private from the moment it exists, rather
+// than only after a chmod.
+func listenPrivateSocket(path string) (net.Listener, error) {
+	old := syscall.Umask(0077)
+	l, err := net.Listen("unix", path)
+	syscall.Umask(old)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
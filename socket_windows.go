@@ -0,0 +1,12 @@
+//go:build windows
+
+package main
+
+import "net"
+
+// listenPrivateSocket listens on a Unix socket. Windows has no socket
+// permission bits; the socket takes the access rights of its folder, which
+// for the config folder is the user's profile.
+func listenPrivateSocket(path string) (net.Listener, error) {
+	return net.Listen("unix", path)
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// Storage engines for history and checkpoints
+const (
+	storageJSON   = "json"   // history.jsonl and batches.json
+	storageSQLite = "sqlite" // one fidruawatch.db, indexed and safe for concurrent writers
+)
+
+var storageEngineOptions = []struct {
+	Engine string
+	Label  string
+}{
+	{storageJSON, "JSON 文件"},
+	{storageSQLite, "SQLite 数据库"},
+}
+
+// useDatabase reports whether history and checkpoints go to SQLite
+func useDatabase() bool {
+	return config.StorageEngine == storageSQLite
+}
+
+var (
+	databasePath      string
+	database          *sql.DB
+	databaseMu        sync.Mutex
+	databaseImportErr error // why the JSON history was not imported in full
+)
+
+const databaseSchema = `
+CREATE TABLE IF NOT EXISTS history (
+	id         TEXT PRIMARY KEY,
+	folder     TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	end_time   INTEGER NOT NULL, -- unix milliseconds
+	record     TEXT NOT NULL     -- HistoryRecord as JSON
+);
+CREATE INDEX IF NOT EXISTS history_end_time ON history(end_time);
+CREATE INDEX IF NOT EXISTS history_folder ON history(folder);
+-- Trigram tokens match any substring of 3+ characters, Chinese included
+CREATE VIRTUAL TABLE IF NOT EXISTS history_fts USING fts5(id UNINDEXED, text, tokenize = 'trigram');
+CREATE TABLE IF NOT EXISTS checkpoint (
+	id   INTEGER PRIMARY KEY CHECK (id = 1),
+	data BLOB NOT NULL
+);`
+
+// openDatabase returns the database, creating it on first use. A history
+// written by the JSON engine is imported into an empty database.
+func openDatabase() (*sql.DB, error) {
+	databaseMu.Lock()
+	defer databaseMu.Unlock()
+	if database != nil {
+		return database, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(databasePath), 0755); err != nil {
+		return nil, err
+	}
+	// WAL lets the GUI and a headless run write at the same time; the busy
+	// timeout makes the loser of a write race wait instead of failing
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(databasePath)+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(databaseSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	databaseImportErr = nil
+	if err := importHistoryFile(db); err != nil {
+		databaseImportErr = fmt.Errorf("导入历史记录失败: %v", err)
+		logEvent("%v", databaseImportErr)
+	}
+	if err := indexHistory(db); err != nil {
+		logEvent("建立历史记录索引失败: %v", err)
+	}
+	database = db
+	return db, nil
+}
+
+// closeDatabase closes the database, if open
+func closeDatabase() {
+	databaseMu.Lock()
+	defer databaseMu.Unlock()
+	if database != nil {
+		database.Close()
+		database = nil
+	}
+}
+
+// importHistoryFile copies history.jsonl into an empty database. The file
+// is left in place so switching back loses nothing.
+func importHistoryFile(db *sql.DB) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM history`).Scan(&n); err != nil || n > 0 {
+		return err
+	}
+	historyMu.Lock()
+	records, badLines, readErr := readHistory()
+	historyMu.Unlock()
+	if readErr == nil && badLines > 0 {
+		readErr = fmt.Errorf("%s 中有 %d 行无法解析", historyPath, badLines)
+	}
+	if len(records) == 0 {
+		return readErr
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if err := putHistory(tx, rec); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	logEvent("已将 %d 条历史记录导入数据库", len(records))
+	return readErr
+}
+
+// indexHistory fills the full-text index of a database created before
+// it existed
+func indexHistory(db *sql.DB) error {
+	var indexed, total int
+	if err := db.QueryRow(`SELECT (SELECT COUNT(*) FROM history_fts), (SELECT COUNT(*) FROM history)`).Scan(&indexed, &total); err != nil || indexed == total {
+		return err
+	}
+	records, _, err := dbQueryHistoryIn(db, `1 = 1`)
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM history_fts`); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, rec := range records {
+		if _, err := tx.Exec(`INSERT INTO history_fts (id, text) VALUES (?, ?)`, rec.ID, historySearchText(rec)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// execer is a *sql.DB or *sql.Tx
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// putHistory inserts or replaces the record of a batch and its full-text
+// index entry
+func putHistory(tx execer, rec HistoryRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO history (id, folder, status, end_time, record) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET folder = excluded.folder, status = excluded.status,
+			end_time = excluded.end_time, record = excluded.record`,
+		rec.ID, rec.Folder, rec.Status, rec.EndTime.UnixMilli(), string(data))
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM history_fts WHERE id = ?`, rec.ID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`INSERT INTO history_fts (id, text) VALUES (?, ?)`, rec.ID, historySearchText(rec))
+	return err
+}
+
+// dbAppendHistory stores the latest record of a batch
+func dbAppendHistory(rec HistoryRecord) error {
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := putHistory(tx, rec); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// dbQueryHistory returns the records ending in [from, to), newest first. A
+// zero from or to leaves that end open.
+func dbQueryHistory(from, to time.Time) ([]HistoryRecord, int, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return nil, 0, err
+	}
+	return dbSearchHistoryIn(db, historyQuery{From: from, To: to})
+}
+
+// dbSearchHistory runs a search screen query, see searchHistory
+func dbSearchHistory(q historyQuery) ([]HistoryRecord, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	records, _, err := dbSearchHistoryIn(db, q)
+	return records, err
+}
+
+// dbSearchHistoryIn turns a query into a WHERE clause: dates and sizes are
+// compared directly, text through the full-text index
+func dbSearchHistoryIn(db *sql.DB, q historyQuery) ([]HistoryRecord, int, error) {
+	where := `1 = 1`
+	var args []any
+	if !q.From.IsZero() {
+		where += ` AND end_time >= ?`
+		args = append(args, q.From.UnixMilli())
+	}
+	if !q.To.IsZero() {
+		where += ` AND end_time < ?`
+		args = append(args, q.To.UnixMilli())
+	}
+	if q.MinSize > 0 {
+		where += ` AND json_extract(record, '$.total_size') >= ?`
+		args = append(args, q.MinSize)
+	}
+	if q.MaxSize > 0 {
+		where += ` AND json_extract(record, '$.total_size') <= ?`
+		args = append(args, q.MaxSize)
+	}
+	if text := strings.TrimSpace(q.Text); text != "" {
+		where += ` AND id IN (SELECT id FROM history_fts WHERE text LIKE ? ESCAPE '\')`
+		args = append(args, "%"+likeEscaper.Replace(text)+"%")
+	}
+	return dbQueryHistoryIn(db, where, args...)
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// dbQueryHistoryIn returns the records matching a WHERE clause, newest first
+func dbQueryHistoryIn(db *sql.DB, where string, args ...any) ([]HistoryRecord, int, error) {
+	rows, err := db.Query(`SELECT record FROM history WHERE `+where+` ORDER BY end_time DESC`, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	var records []HistoryRecord
+	bad := 0
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return records, bad, err
+		}
+		var rec HistoryRecord
+		if json.Unmarshal([]byte(data), &rec) != nil {
+			bad++
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, bad, rows.Err()
+}
+
+// dbPruneHistory applies the history retention settings, see pruneHistory
+func dbPruneHistory(now time.Time) (int, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return 0, err
+	}
+	rows, err := db.Query(`SELECT id, end_time, length(record) FROM history ORDER BY end_time DESC`)
+	if err != nil {
+		return 0, err
+	}
+	maxAge := time.Duration(config.HistoryKeepDays) * 24 * time.Hour
+	maxBytes := int64(config.HistoryMaxMB) << 20
+	var drop []string
+	kept := 0
+	var size int64
+	for rows.Next() {
+		var id string
+		var end, n int64
+		if err := rows.Scan(&id, &end, &n); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		tooOld := maxAge > 0 && now.Sub(time.UnixMilli(end)) > maxAge
+		tooMany := config.HistoryMaxBatches > 0 && kept >= config.HistoryMaxBatches
+		tooBig := maxBytes > 0 && size+n+1 > maxBytes
+		if len(drop) > 0 || tooOld || tooMany || tooBig {
+			drop = append(drop, id)
+			continue
+		}
+		kept++
+		size += n + 1
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(drop) == 0 {
+		return 0, err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	for _, id := range drop {
+		if _, err := tx.Exec(`DELETE FROM history WHERE id = ?`, id); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+		if _, err := tx.Exec(`DELETE FROM history_fts WHERE id = ?`, id); err != nil {
+			tx.Rollback()
+			return 0, err
+		}
+	}
+	return len(drop), tx.Commit()
+}
+
+// dbClearHistory deletes every history record
+func dbClearHistory() error {
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`DELETE FROM history; DELETE FROM history_fts`)
+	return err
+}
+
+// dbWriteCheckpoint replaces the checkpoint; nil data removes it
+func dbWriteCheckpoint(data []byte) error {
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		_, err = db.Exec(`DELETE FROM checkpoint`)
+		return err
+	}
+	_, err = db.Exec(`INSERT INTO checkpoint (id, data) VALUES (1, ?)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data`, data)
+	return err
+}
+
+// dbReadCheckpoint returns the checkpoint, or os.ErrNotExist without one
+func dbReadCheckpoint() ([]byte, error) {
+	db, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	err = db.QueryRow(`SELECT data FROM checkpoint WHERE id = 1`).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, os.ErrNotExist
+	}
+	return data, err
+}
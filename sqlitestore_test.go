@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteHistory(t *testing.T) {
+	origConfig, origHistory, origDB := config, historyPath, databasePath
+	defer func() {
+		closeDatabase()
+		config, historyPath, databasePath = origConfig, origHistory, origDB
+	}()
+	dir := t.TempDir()
+	historyPath = filepath.Join(dir, "history.jsonl")
+	databasePath = filepath.Join(dir, "fidruawatch.db")
+	config = defaultConfig()
+	config.SaveHistory = true
+
+	// Written by the JSON engine, imported when the database is created
+	now := time.Now()
+	recordHistory(&Batch{ID: "old", Folder: "/in/old", Status: "signed", LastTime: now.Add(-48 * time.Hour)})
+
+	config.StorageEngine = storageSQLite
+	for i := 0; i < 3; i++ {
+		b := &Batch{ID: fmt.Sprintf("b%d", i), Folder: "/in", Status: "completed", LastTime: now.Add(-time.Duration(i) * time.Hour)}
+		recordHistory(b)
+		b.Status = "signed"
+		recordHistory(b)
+	}
+	records, _, err := loadHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 4 || records[0].ID != "b0" || records[0].Status != "signed" || records[3].ID != "old" {
+		t.Fatalf("records = %+v", records)
+	}
+
+	records, err = queryHistory(now.Add(-90*time.Minute), now.Add(-30*time.Minute))
+	if err != nil || len(records) != 1 || records[0].ID != "b1" {
+		t.Errorf("queryHistory = %+v, %v, want b1", records, err)
+	}
+
+	recordHistory(&Batch{ID: "cn", Folder: "/in/客户交付", Status: "signed", Files: []string{"100%_final.mov"}, LastTime: now})
+	for text, want := range map[string]int{"客户交": 1, "户": 1, "/in": 5, "100%_": 1, "0%x": 0} {
+		records, err := searchHistory(historyQuery{Text: text})
+		if err != nil || len(records) != want {
+			t.Errorf("searchHistory(%q) = %d records, %v, want %d", text, len(records), err, want)
+		}
+	}
+
+	config.HistoryKeepDays = 1
+	if n, err := pruneHistory(now); err != nil || n != 1 {
+		t.Errorf("pruneHistory = %d, %v, want the old batch removed", n, err)
+	}
+	if err := clearHistory(); err != nil {
+		t.Fatal(err)
+	}
+	if records, _, _ := loadHistory(); len(records) != 0 {
+		t.Errorf("%d records left after clearHistory", len(records))
+	}
+}
+
+func TestSQLiteCheckpoint(t *testing.T) {
+	origConfig, origDB, origBatches, origLast := config, databasePath, batches, lastCheckpoint
+	defer func() {
+		closeDatabase()
+		config, databasePath, batches, lastCheckpoint = origConfig, origDB, origBatches, origLast
+	}()
+	databasePath = filepath.Join(t.TempDir(), "fidruawatch.db")
+	config = defaultConfig()
+	config.StorageEngine = storageSQLite
+	lastCheckpoint = nil
+
+	batches = map[string]*Batch{
+		"up": {ID: "up", Folder: "/remote/up", Status: "uploading", Files: []string{"a.mp4"}, FileSizes: map[string]int64{"a.mp4": 5}, TotalSize: 5},
+	}
+	if err := writeCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := dbReadCheckpoint()
+	if err != nil || len(data) == 0 {
+		t.Fatalf("dbReadCheckpoint = %q, %v", data, err)
+	}
+
+	batches = map[string]*Batch{}
+	if err := writeCheckpoint(); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := restoreCheckpoint(); err != nil || n != 0 {
+		t.Errorf("restoreCheckpoint = %d, %v after the checkpoint was cleared", n, err)
+	}
+}
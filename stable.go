@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// fileSettleTime is how long the size of a file must stay the same before
+// the file counts as stable
+const fileSettleTime = 10 * time.Second
+
+// fileStable reports whether the size of one file of b has stopped
+// changing. Files of finished batches are always stable. Caller must hold
+// batchesMu.
+func fileStable(b *Batch, name string, now time.Time) bool {
+	if b.Status != "uploading" {
+		return true
+	}
+	// Files without a size history, e.g. restored after a restart, last
+	// changed no later than the batch did
+	changed := b.GrowTime
+	if last, ok := b.Growth[name].Latest(); ok {
+		changed = last.Time
+	}
+	return now.Sub(changed) >= fileSettleTime
+}
+
+// stableFiles counts the stable files of b. Caller must hold batchesMu.
+func stableFiles(b *Batch, now time.Time) int {
+	n := 0
+	for _, f := range b.Files {
+		if fileStable(b, f, now) {
+			n++
+		}
+	}
+	return n
+}
+
+// stableFilesText is the card line of an uploading batch, e.g.
+// "12/18 文件已稳定", "" for other batches. Caller must hold batchesMu.
+func stableFilesText(b *Batch, now time.Time) string {
+	if b.Status != "uploading" || len(b.Files) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("🧊 %d/%d 文件已稳定", stableFiles(b, now), len(b.Files))
+}
+
+// holdForUnstableFiles checks the sizes of an idle local batch on disk
+// before it completes, in case a change was never reported. Changed sizes
+// are recorded and hold completion until the files are stable again; the
+// completion timeout is never shorter than fileSettleTime, so every
+// reported change has settled by then. Caller must hold batchesMu.
+func holdForUnstableFiles(b *Batch) bool {
+	if isRemoteWatchPath(b.Folder) {
+		return false
+	}
+	now := time.Now()
+	changed := false
+	for _, f := range b.Files {
+		info, err := os.Stat(filepath.Join(b.Folder, f))
+		if err != nil || info.IsDir() || info.Size() == b.FileSizes[f] {
+			continue
+		}
+		old := b.FileSizes[f]
+		logEvent("批次 %s: %s 大小变化未被检测到 (%s → %s), 暂缓完成", b.ID, f, formatSize(old), formatSize(info.Size()))
+		b.FileSizes[f] = info.Size()
+		b.TotalSize += info.Size() - old
+		b.LastTime = now
+		noteGrowth(b, now)
+		noteFileSize(b, f, info.Size(), now)
+		b.Samples.Add(Sample{Time: now, Size: b.TotalSize})
+		changed = true
+	}
+	return changed
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStable(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b := &Batch{Status: "uploading", Files: []string{"a.mp4", "b.mp4", "c.mp4"}}
+	noteGrowth(b, start)
+	noteFileSize(b, "a.mp4", 100, start)
+	noteFileSize(b, "b.mp4", 100, start)
+	noteFileSize(b, "b.mp4", 200, start.Add(8*time.Second))
+
+	now := start.Add(12 * time.Second)
+	if !fileStable(b, "a.mp4", now) || fileStable(b, "b.mp4", now) {
+		t.Error("a.mp4 should be stable and b.mp4 still changing")
+	}
+	// c.mp4 has no size history and goes by the batch
+	if !fileStable(b, "c.mp4", now) {
+		t.Error("c.mp4 should be stable")
+	}
+	if got := stableFilesText(b, now); got != "🧊 2/3 文件已稳定" {
+		t.Errorf("stableFilesText = %q", got)
+	}
+	b.Status = "completed"
+	if got := stableFilesText(b, now); got != "" || !fileStable(b, "b.mp4", now) {
+		t.Errorf("completed batch: %q, b.mp4 stable %v", got, fileStable(b, "b.mp4", now))
+	}
+}
+
+func TestHoldForUnstableFiles(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.mp4"), make([]byte, 10), 0644)
+	os.WriteFile(filepath.Join(dir, "b.mp4"), make([]byte, 30), 0644)
+	b := &Batch{
+		Status:    "uploading",
+		Folder:    dir,
+		Files:     []string{"a.mp4", "b.mp4", "gone.mp4"},
+		FileSizes: map[string]int64{"a.mp4": 10, "b.mp4": 20, "gone.mp4": 5},
+		TotalSize: 35,
+	}
+	if !holdForUnstableFiles(b) {
+		t.Fatal("an unreported size change did not hold completion")
+	}
+	if b.FileSizes["b.mp4"] != 30 || b.TotalSize != 45 || fileStable(b, "b.mp4", time.Now()) {
+		t.Errorf("b.mp4 recorded as %d (total %d), want 30 (45) and unstable", b.FileSizes["b.mp4"], b.TotalSize)
+	}
+	if holdForUnstableFiles(b) {
+		t.Error("completion held again without a change")
+	}
+}
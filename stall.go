@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// stallThreshold returns how long an uploading batch may go without any file
+// growing before it is flagged as stalled, 0 when detection is off
+func stallThreshold() time.Duration {
+	if config.StallMinutes <= 0 {
+		return 0
+	}
+	return time.Duration(config.StallMinutes) * time.Minute
+}
+
+// checkStalled flags an uploading batch whose files haven't grown within the
+// stall threshold. It returns true only when the batch has just become
+// stalled, so the alert is sent once. Caller must hold batchesMu.
+func checkStalled(b *Batch, now time.Time) bool {
+	threshold := stallThreshold()
+	if threshold == 0 || b.Status != "uploading" || b.Stalled {
+		return false
+	}
+	if now.Sub(b.GrowTime) <= threshold {
+		return false
+	}
+	b.Stalled = true
+	noteTimeline(b, timelineStalled, fmt.Sprintf("%d 分钟无新数据", config.StallMinutes), now)
+	return true
+}
+
+// noteGrowth records that a batch received data and clears a stall.
+// Caller must hold batchesMu.
+func noteGrowth(b *Batch, now time.Time) {
+	if b.Stalled {
+		noteTimeline(b, timelineResumed, "停滞了 "+formatDuration(now.Sub(b.GrowTime).Seconds()), now)
+	}
+	noteArrivalGap(b, now)
+	b.GrowTime = now
+	b.Stalled = false
+}
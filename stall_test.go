@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckStalled(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.StallMinutes = 10
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)
+	b := &Batch{Status: "uploading"}
+	noteGrowth(b, start)
+
+	if checkStalled(b, start.Add(5*time.Minute)) || b.Stalled {
+		t.Fatal("batch stalled before the threshold")
+	}
+	if !checkStalled(b, start.Add(11*time.Minute)) || !b.Stalled {
+		t.Fatal("batch not stalled after the threshold")
+	}
+	// The transition is reported once
+	if checkStalled(b, start.Add(12*time.Minute)) {
+		t.Error("stall reported twice")
+	}
+
+	noteGrowth(b, start.Add(13*time.Minute))
+	if b.Stalled {
+		t.Error("growth did not clear the stall")
+	}
+
+	b.Status = "completed"
+	if checkStalled(b, start.Add(time.Hour)) {
+		t.Error("completed batch reported as stalled")
+	}
+
+	config.StallMinutes = 0
+	b.Status = "uploading"
+	if checkStalled(b, start.Add(time.Hour)) {
+		t.Error("stall detection should be off with StallMinutes = 0")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/layout"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newStatsView builds the stats tab. The returned refresh reloads it from
+// the history and is called whenever the tab is shown.
+func newStatsView(w fyne.Window) (fyne.CanvasObject, func()) {
+	summary := widget.NewLabel("")
+	summary.Wrapping = fyne.TextWrapWord
+	dayLabel := widget.NewLabel("点击某一天查看当天的批次")
+	calendar := container.New(layout.NewGridLayoutWithRows(7))
+
+	legend := container.NewHBox(layout.NewSpacer(), widget.NewLabel("少"))
+	for level := 0; level <= 4; level++ {
+		legend.Add(container.NewGridWrap(fyne.NewSize(10, 10), newHeatCell(heatColor(level), nil)))
+	}
+	legend.Add(widget.NewLabel("多"))
+
+	throughput := newRateChart(nil)
+
+	refresh := func() {
+		throughput.SetPoints(sessionRates.Rates())
+		now := time.Now()
+		days := heatMapDays(now)
+		go func() {
+			records, err := queryHistory(days[0], days[len(days)-1].AddDate(0, 0, 1))
+			fyne.Do(func() {
+				if err != nil {
+					summary.SetText("读取历史记录失败: " + err.Error())
+					return
+				}
+				volumes := dailyVolumes(records)
+				var max, total int64
+				active := 0
+				for _, v := range volumes {
+					total += v.Bytes
+					active++
+					if v.Bytes > max {
+						max = v.Bytes
+					}
+				}
+				summary.SetText(fmt.Sprintf("📅 过去一年: %d 个批次, %s, %d 天有上传", len(records), formatSize(total), active))
+
+				calendar.Objects = nil
+				for _, day := range days {
+					day, v := day, volumes[dayKey(day)]
+					calendar.Add(newHeatCell(heatColor(heatLevel(v.Bytes, max)), func() {
+						dayLabel.SetText(fmt.Sprintf("%s: %d 个批次, %s", formatDate(day), v.Batches, formatSize(v.Bytes)))
+						if v.Batches > 0 {
+							showDayBatches(day, w)
+						}
+					}))
+				}
+				calendar.Refresh()
+			})
+		}()
+	}
+
+	content := container.NewVBox(
+		widget.NewLabelWithStyle("上传活跃度", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		summary,
+		container.NewGridWrap(fyne.NewSize(float32(heatMapWeeks*7), 7*7), calendar),
+		legend,
+		dayLabel,
+		widget.NewSeparator(),
+		widget.NewLabelWithStyle("本次监控吞吐量", fyne.TextAlignLeading, fyne.TextStyle{Bold: true}),
+		container.NewGridWrap(fyne.NewSize(float32(heatMapWeeks*7), 160), throughput),
+		widget.NewButton("🔄 刷新", func() { throughput.SetPoints(sessionRates.Rates()) }),
+	)
+	return container.NewVScroll(content), refresh
+}
+
+// showDayBatches lists the batches that completed on a day
+func showDayBatches(day time.Time, w fyne.Window) {
+	records, err := queryHistory(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	list := widget.NewList(
+		func() int { return len(records) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return l
+		},
+		func(i widget.ListItemID, o fyne.CanvasObject) {
+			o.(*widget.Label).SetText(historyRecordText(records[i]))
+		},
+	)
+	list.OnSelected = func(i widget.ListItemID) {
+		list.Unselect(i)
+		showHistoryRecordDialog(records[i], w)
+	}
+	scroll := container.NewStack(list)
+	d := dialog.NewCustom(formatDate(day)+" 的批次", "关闭", scroll, w)
+	d.Resize(fyne.NewSize(400, 360))
+	d.Show()
+}
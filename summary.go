@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+)
+
+// Summary periods
+const (
+	summaryDaily  = "daily"
+	summaryWeekly = "weekly" // sent on Mondays, covering the previous 7 days
+)
+
+// parseClock parses "HH:MM" into hour and minute
+func parseClock(s string) (hour, minute int, ok bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, false
+	}
+	return t.Hour(), t.Minute(), true
+}
+
+// nextSummaryTime returns the first summary time strictly after now
+func nextSummaryTime(now time.Time, clock, period string) time.Time {
+	hour, minute, ok := parseClock(clock)
+	if !ok {
+		hour, minute = 18, 0
+	}
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	if period == summaryWeekly {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+// summaryWindow returns the period a summary sent at t covers
+func summaryWindow(t time.Time, period string) (from, to time.Time) {
+	if period == summaryWeekly {
+		return t.AddDate(0, 0, -7), t
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()), t
+}
+
+// summarizeHistory counts the batches completed in [from, to) and their size
+func summarizeHistory(records []HistoryRecord, from, to time.Time) (count int, size int64) {
+	for _, r := range records {
+		if r.EndTime.Before(from) || !r.EndTime.Before(to) {
+			continue
+		}
+		count++
+		size += r.TotalSize
+	}
+	return count, size
+}
+
+// summaryText formats the summary notification body
+func summaryText(period string, count int, size int64) string {
+	prefix := "今日"
+	if period == summaryWeekly {
+		prefix = "本周"
+	}
+	return fmt.Sprintf("%s共完成 %d 个批次, 共 %s", prefix, count, formatSize(size))
+}
+
+// runSummaryScheduler sends the daily/weekly summary at the configured time,
+// built from the history store. Settings are re-read every minute.
+func runSummaryScheduler(ctx context.Context, app fyne.App) {
+	next := nextSummaryTime(time.Now(), config.SummaryTime, config.SummaryPeriod)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			// Pick up changed settings without waiting for the old time
+			if want := nextSummaryTime(now, config.SummaryTime, config.SummaryPeriod); want.Before(next) {
+				next = want
+			}
+			if now.Before(next) {
+				continue
+			}
+			if config.SummaryEnabled {
+				sendSummary(app, next, config.SummaryPeriod)
+			}
+			next = nextSummaryTime(now, config.SummaryTime, config.SummaryPeriod)
+		}
+	}
+}
+
+// sendSummary notifies about the batches completed in the period ending at t
+func sendSummary(app fyne.App, t time.Time, period string) {
+	from, to := summaryWindow(t, period)
+	records, err := queryHistory(from, to)
+	if err != nil {
+		logEvent("读取历史记录失败: %v", err)
+		return
+	}
+	count, size := summarizeHistory(records, from, to)
+	sendNotification(app, "FidruaWatch - 上传汇总", summaryText(period, count, size))
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNextSummaryTime(t *testing.T) {
+	loc := time.Local
+	// Wednesday
+	now := time.Date(2024, 3, 6, 10, 0, 0, 0, loc)
+
+	if got := nextSummaryTime(now, "18:00", summaryDaily); !got.Equal(time.Date(2024, 3, 6, 18, 0, 0, 0, loc)) {
+		t.Errorf("daily before time = %v", got)
+	}
+	if got := nextSummaryTime(now, "09:30", summaryDaily); !got.Equal(time.Date(2024, 3, 7, 9, 30, 0, 0, loc)) {
+		t.Errorf("daily after time = %v", got)
+	}
+	if got := nextSummaryTime(now, "18:00", summaryWeekly); !got.Equal(time.Date(2024, 3, 11, 18, 0, 0, 0, loc)) {
+		t.Errorf("weekly = %v, want next Monday", got)
+	}
+	if got := nextSummaryTime(now, "bogus", summaryDaily); got.Hour() != 18 {
+		t.Errorf("invalid clock should fall back to 18:00, got %v", got)
+	}
+}
+
+func TestSummarizeHistory(t *testing.T) {
+	useLocale(t, "zh-CN")
+	at := time.Date(2024, 3, 6, 18, 0, 0, 0, time.Local)
+	records := []HistoryRecord{
+		{ID: "today", TotalSize: 1 << 30, EndTime: at.Add(-time.Hour)},
+		{ID: "today2", TotalSize: 1 << 30, EndTime: at.Add(-2 * time.Hour)},
+		{ID: "yesterday", TotalSize: 5, EndTime: at.Add(-24 * time.Hour)},
+	}
+	from, to := summaryWindow(at, summaryDaily)
+	count, size := summarizeHistory(records, from, to)
+	if count != 2 || size != 2<<30 {
+		t.Errorf("daily summary = %d batches, %d bytes", count, size)
+	}
+	if text := summaryText(summaryDaily, count, size); text != "今日共完成 2 个批次, 共 2.0 GB" {
+		t.Errorf("summaryText = %q", text)
+	}
+
+	from, to = summaryWindow(at, summaryWeekly)
+	if count, _ := summarizeHistory(records, from, to); count != 3 {
+		t.Errorf("weekly summary = %d batches, want 3", count)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// systemdUnitName is the user unit running FidruaWatch headless
+const systemdUnitName = "fidruawatch.service"
+
+// runSystemctl runs systemctl --user with args and returns its trimmed
+// output; a variable so tests can stand in for systemd
+var runSystemctl = func(args ...string) (string, error) {
+	out, err := exec.Command("systemctl", append([]string{"--user"}, args...)...).CombinedOutput()
+	return strings.TrimSpace(string(out)), err
+}
+
+// systemdUnitPath returns ~/.config/systemd/user/fidruawatch.service
+func systemdUnitPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "systemd", "user", systemdUnitName), nil
+}
+
+// systemdQuote quotes an ExecStart argument when it needs it
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\;$%") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+	return `"` + r.Replace(arg) + `"`
+}
+
+// systemdUnit returns the unit file running exePath headless with the
+// config at cfgPath, restarted when it fails
+func systemdUnit(exePath, cfgPath string) string {
+	args := []string{exePath, "--headless", "--config", cfgPath}
+	for i, a := range args {
+		args[i] = systemdQuote(a)
+	}
+	return fmt.Sprintf(`[Unit]
+Description=FidruaWatch upload monitor (headless)
+After=network-online.target
+
+[Service]
+ExecStart=%s
+Restart=on-failure
+RestartSec=10
+
+[Install]
+WantedBy=default.target
+`, strings.Join(args, " "))
+}
+
+// installSystemdService writes the unit and enables and starts it
+func installSystemdService() error {
+	exePath := getExecutablePath()
+	if exePath == "" {
+		return fmt.Errorf("无法获取程序路径")
+	}
+	unitPath, err := systemdUnitPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(unitPath), 0755); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(unitPath, []byte(systemdUnit(exePath, configPath)), 0644); err != nil {
+		return fmt.Errorf("无法写入 %s: %v", unitPath, err)
+	}
+	if out, err := runSystemctl("daemon-reload"); err != nil {
+		return fmt.Errorf("systemctl daemon-reload 失败: %v %s", err, out)
+	}
+	if out, err := runSystemctl("enable", "--now", systemdUnitName); err != nil {
+		return fmt.Errorf("启用 %s 失败: %v %s", systemdUnitName, err, out)
+	}
+	return nil
+}
+
+// removeSystemdService stops and disables the unit and deletes its file
+func removeSystemdService() error {
+	unitPath, err := systemdUnitPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(unitPath); os.IsNotExist(err) {
+		return nil
+	}
+	if out, err := runSystemctl("disable", "--now", systemdUnitName); err != nil {
+		return fmt.Errorf("停用 %s 失败: %v %s", systemdUnitName, err, out)
+	}
+	if err := os.Remove(unitPath); err != nil {
+		return err
+	}
+	runSystemctl("daemon-reload")
+	return nil
+}
+
+// systemdStatus describes whether the unit is installed, enabled and running
+func systemdStatus() string {
+	unitPath, err := systemdUnitPath()
+	if err != nil {
+		return "未知"
+	}
+	if _, err := os.Stat(unitPath); err != nil {
+		return "未安装"
+	}
+	// is-enabled/is-active exit non-zero for "disabled"/"inactive", which
+	// still come with a usable answer
+	enabled, _ := runSystemctl("is-enabled", systemdUnitName)
+	active, _ := runSystemctl("is-active", systemdUnitName)
+	if enabled == "" && active == "" {
+		return "已安装 (无法连接 systemd)"
+	}
+	return fmt.Sprintf("已安装, %s, %s", enabled, active)
+}
+
+// newSystemdRow is the settings row installing, removing and showing the
+// state of the headless service
+func newSystemdRow(w fyne.Window) fyne.CanvasObject {
+	status := widget.NewLabel("")
+	refresh := func() { status.SetText("状态: " + systemdStatus()) }
+	run := func(action func() error, done string) {
+		if err := action(); err != nil {
+			dialog.ShowError(err, w)
+		} else {
+			logEvent("%s", done)
+		}
+		refresh()
+	}
+	install := widget.NewButton("安装并启用", func() {
+		dialog.ShowConfirm("systemd 服务",
+			"将安装 "+systemdUnitName+" 并在登录后以无界面模式运行，失败时自动重启。\n服务与本窗口共用配置文件，请勿同时监控同一文件夹。",
+			func(ok bool) {
+				if ok {
+					run(installSystemdService, "已安装 systemd 服务")
+				}
+			}, w)
+	})
+	remove := widget.NewButton("停用并移除", func() {
+		run(removeSystemdService, "已移除 systemd 服务")
+	})
+	refreshBtn := widget.NewButton("刷新", refresh)
+	refresh()
+	return container.NewVBox(
+		widget.NewLabel("🐧 systemd 用户服务 (无界面)"),
+		container.NewHBox(install, remove, refreshBtn),
+		status,
+	)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	unit := systemdUnit("/opt/Fidrua Watch/fidruawatch", "/home/ana/.config/FidruaWatch/config.json")
+	for _, want := range []string{
+		`ExecStart="/opt/Fidrua Watch/fidruawatch" --headless --config /home/ana/.config/FidruaWatch/config.json`,
+		"Restart=on-failure",
+		"WantedBy=default.target",
+	} {
+		if !strings.Contains(unit, want) {
+			t.Errorf("Unit lacks %q:\n%s", want, unit)
+		}
+	}
+	if got := systemdQuote(`50%$"x"`); got != `"50%%$$\"x\""` {
+		t.Errorf("systemdQuote = %s", got)
+	}
+}
+
+func TestInstallSystemdService(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("systemd units are Linux only")
+	}
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	origRun := runSystemctl
+	defer func() { runSystemctl = origRun }()
+	var calls []string
+	state := "disabled"
+	runSystemctl = func(args ...string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		switch args[0] {
+		case "enable":
+			state = "enabled"
+		case "disable":
+			state = "disabled"
+		case "is-enabled":
+			return state, nil
+		case "is-active":
+			return "active", nil
+		}
+		return "", nil
+	}
+
+	if got := systemdStatus(); got != "未安装" {
+		t.Errorf("Status before install = %s", got)
+	}
+	if err := installSystemdService(); err != nil {
+		t.Fatal(err)
+	}
+	unitPath, _ := systemdUnitPath()
+	if data, err := os.ReadFile(unitPath); err != nil || !strings.Contains(string(data), "--headless") {
+		t.Fatalf("Unit not written: %v", err)
+	}
+	if got := systemdStatus(); got != "已安装, enabled, active" {
+		t.Errorf("Status after install = %s", got)
+	}
+
+	if err := removeSystemdService(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(unitPath); !os.IsNotExist(err) {
+		t.Error("Unit file not removed")
+	}
+	want := "daemon-reload|enable --now fidruawatch.service"
+	if got := strings.Join(calls[:2], "|"); got != want {
+		t.Errorf("systemctl calls %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+)
+
+// System log backends
+const (
+	sysLogOff      = "off"
+	sysLogSyslog   = "syslog"   // Linux and macOS syslog
+	sysLogJournald = "journald" // systemd journal, with the batch as structured fields
+	sysLogEventLog = "eventlog" // Windows Event Log, source FidruaWatch
+)
+
+var sysLogOptions = []struct {
+	Backend string
+	Label   string
+}{
+	{sysLogOff, "关闭"},
+	{sysLogSyslog, "syslog"},
+	{sysLogJournald, "journald"},
+	{sysLogEventLog, "Windows 事件日志"},
+}
+
+// sysLogPriority is the severity of a system log message
+type sysLogPriority int
+
+const (
+	sysLogInfo sysLogPriority = iota
+	sysLogWarning
+	sysLogError
+)
+
+// sysLogWriter is a system log backend. Fields are extra key/value data
+// for backends that keep structured records.
+type sysLogWriter interface {
+	Send(prio sysLogPriority, msg string, fields map[string]string) error
+	Close() error
+}
+
+// systemLog sends batch completions and errors to the backend selected in
+// config.SysLog, reopening it when the setting changes
+type systemLog struct {
+	mu      sync.Mutex
+	backend string
+	w       sysLogWriter
+	failed  bool // the last send failed and was logged
+}
+
+var sysLog = &systemLog{}
+
+// send writes one message, logging failures once until a send succeeds
+func (l *systemLog) send(prio sysLogPriority, msg string, fields map[string]string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if config.SysLog != l.backend {
+		if l.w != nil {
+			l.w.Close()
+			l.w = nil
+		}
+		l.backend, l.failed = config.SysLog, false
+	}
+	if l.backend == sysLogOff || l.backend == "" {
+		return
+	}
+	var err error
+	if l.w == nil {
+		l.w, err = openSysLog(l.backend)
+	}
+	if err == nil {
+		err = l.w.Send(prio, msg, fields)
+	}
+	if err != nil && !l.failed {
+		logEvent("写入系统日志 (%s) 失败: %v", l.backend, err)
+	}
+	l.failed = err != nil
+}
+
+// sysLogBatch sends a message about a batch to the system log. Caller must
+// hold batchesMu.
+func sysLogBatch(prio sysLogPriority, b *Batch, format string, args ...interface{}) {
+	if config.SysLog == sysLogOff {
+		return
+	}
+	msg := fmt.Sprintf(format, args...)
+	sysLog.send(prio, fmt.Sprintf("%s (批次 %s: %s)", msg, b.ID, b.Folder), map[string]string{
+		"BATCH_ID":     b.ID,
+		"BATCH_FOLDER": b.Folder,
+		"BATCH_STATUS": b.Status,
+		"SESSION_ID":   b.SessionID,
+	})
+}
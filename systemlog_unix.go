@@ -0,0 +1,51 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"log/syslog"
+	"runtime"
+)
+
+// sysLogBackends are the backends offered on this platform
+var sysLogBackends = func() []string {
+	if runtime.GOOS == "linux" {
+		return []string{sysLogOff, sysLogSyslog, sysLogJournald}
+	}
+	return []string{sysLogOff, sysLogSyslog}
+}()
+
+// openSysLog connects to a backend
+func openSysLog(backend string) (sysLogWriter, error) {
+	switch backend {
+	case sysLogSyslog:
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "fidruawatch")
+		if err != nil {
+			return nil, err
+		}
+		return syslogWriter{w}, nil
+	case sysLogJournald:
+		return openJournald()
+	}
+	return nil, errors.New("此平台不支持 " + backend)
+}
+
+// syslogWriter adapts log/syslog; fields are already in the message
+type syslogWriter struct {
+	w *syslog.Writer
+}
+
+func (s syslogWriter) Send(prio sysLogPriority, msg string, _ map[string]string) error {
+	switch prio {
+	case sysLogError:
+		return s.w.Err(msg)
+	case sysLogWarning:
+		return s.w.Warning(msg)
+	}
+	return s.w.Info(msg)
+}
+
+func (s syslogWriter) Close() error {
+	return s.w.Close()
+}
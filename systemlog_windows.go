@@ -0,0 +1,51 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+// sysLogBackends are the backends offered on this platform
+var sysLogBackends = []string{sysLogOff, sysLogEventLog}
+
+// eventLogSource is the source name shown in the Event Viewer
+const eventLogSource = "FidruaWatch"
+
+// openSysLog connects to a backend. Registering the event source needs
+// administrator rights the first time; without it Windows still records
+// the events, just with a note that the source is unknown.
+func openSysLog(backend string) (sysLogWriter, error) {
+	if backend != sysLogEventLog {
+		return nil, errors.New("此平台不支持 " + backend)
+	}
+	eventlog.InstallAsEventCreate(eventLogSource, eventlog.Error|eventlog.Warning|eventlog.Info)
+	l, err := eventlog.Open(eventLogSource)
+	if err != nil {
+		return nil, err
+	}
+	return winEventLog{l}, nil
+}
+
+// winEventLog adapts the Windows Event Log; fields are already in the
+// message. Event IDs are the priority plus one, as EventCreate accepts 1-1000.
+type winEventLog struct {
+	l *eventlog.Log
+}
+
+func (e winEventLog) Send(prio sysLogPriority, msg string, _ map[string]string) error {
+	id := uint32(prio) + 1
+	switch prio {
+	case sysLogError:
+		return e.l.Error(id, msg)
+	case sysLogWarning:
+		return e.l.Warning(id, msg)
+	}
+	return e.l.Info(id, msg)
+}
+
+func (e winEventLog) Close() error {
+	return e.l.Close()
+}
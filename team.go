@@ -0,0 +1,335 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Multi-operator mode: the instance receiving uploads serves its batches
+// through the local API, and teammates' instances connect to it as team
+// clients. A client mirrors the server's batch list from /api/batches and
+// the /api/watch event stream, and signs batches through the server so
+// everyone sees the same sign-off trail.
+
+// Team client timing
+const (
+	teamRefresh      = 10 * time.Second // full list refresh, for progress the event stream doesn't carry
+	teamRetry        = 5 * time.Second
+	teamRequestLimit = 15 * time.Second
+)
+
+// teamRequestClient is used for list and sign requests; the event stream
+// runs without a timeout until it is cancelled
+var (
+	teamRequestClient = &http.Client{Timeout: teamRequestLimit}
+	teamStreamClient  = &http.Client{}
+)
+
+// teamClient mirrors the batch list of a FidruaWatch server
+type teamClient struct {
+	mu       sync.Mutex
+	base     string // server URL without trailing slash
+	token    string
+	batches  map[string]apiBatch
+	status   string // connection state for the team page
+	role     string // apiRoleViewer or apiRoleSigner, "" if the server didn't say
+	cancel   context.CancelFunc
+	onChange func()
+}
+
+var team = &teamClient{}
+
+// teamServerURL normalizes a server address: http:// is assumed, and a
+// trailing slash is dropped
+func teamServerURL(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("请填写服务器地址")
+	}
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("服务器地址无效: %s", s)
+	}
+	return strings.TrimRight(u.String(), "/"), nil
+}
+
+// Connect starts mirroring server, replacing any previous connection.
+// onChange runs after every change of the list or the connection state.
+func (c *teamClient) Connect(server, token string, onChange func()) error {
+	base, err := teamServerURL(server)
+	if err != nil {
+		return err
+	}
+	c.Disconnect()
+	ctx, cancel := context.WithCancel(context.Background())
+	c.mu.Lock()
+	c.base, c.token = base, token
+	c.batches = make(map[string]apiBatch)
+	c.role = ""
+	c.cancel = cancel
+	c.onChange = onChange
+	c.mu.Unlock()
+	c.setStatus("正在连接 " + base + "...")
+	go c.run(ctx)
+	return nil
+}
+
+// Disconnect stops mirroring and clears the list
+func (c *teamClient) Disconnect() {
+	c.mu.Lock()
+	if c.cancel == nil {
+		c.mu.Unlock()
+		return
+	}
+	c.cancel()
+	c.cancel = nil
+	c.batches = nil
+	c.status = ""
+	onChange := c.onChange
+	c.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// Connected reports whether the client is mirroring a server
+func (c *teamClient) Connected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cancel != nil
+}
+
+// CanSign reports whether the server lets this client sign. Servers that
+// don't report a role decide when asked.
+func (c *teamClient) CanSign() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.role != apiRoleViewer
+}
+
+// Status describes the connection
+func (c *teamClient) Status() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.status
+}
+
+// Batches returns the mirrored batches, newest first
+func (c *teamClient) Batches() []apiBatch {
+	c.mu.Lock()
+	list := make([]apiBatch, 0, len(c.batches))
+	for _, b := range c.batches {
+		list = append(list, b)
+	}
+	c.mu.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].StartTime.After(list[j].StartTime) })
+	return list
+}
+
+func (c *teamClient) setStatus(s string) {
+	c.mu.Lock()
+	c.status = s
+	onChange := c.onChange
+	c.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// run keeps the mirror up to date until ctx is cancelled, reconnecting
+// after errors
+func (c *teamClient) run(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(teamRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.refresh(ctx)
+			}
+		}
+	}()
+	for {
+		err := c.stream(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		logEvent("团队服务器连接中断: %v", err)
+		c.setStatus(fmt.Sprintf("连接中断: %v, %d 秒后重试", err, int(teamRetry/time.Second)))
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(teamRetry):
+		}
+	}
+}
+
+// request calls the server API and decodes the data of its reply into out
+func (c *teamClient) request(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	c.mu.Lock()
+	base, token := c.base, c.token
+	c.mu.Unlock()
+	req, err := http.NewRequestWithContext(ctx, method, base+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := teamRequestClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var reply struct {
+		Error string          `json:"error"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("%s: %v", resp.Status, err)
+	}
+	if reply.Error != "" {
+		return fmt.Errorf("%s", reply.Error)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	if out != nil && len(reply.Data) > 0 {
+		return json.Unmarshal(reply.Data, out)
+	}
+	return nil
+}
+
+// refresh replaces the mirror with the server's current list
+func (c *teamClient) refresh(ctx context.Context) error {
+	var list []apiBatch
+	if err := c.request(ctx, http.MethodGet, "/api/batches", nil, &list); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	if ctx.Err() != nil {
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+	c.batches = make(map[string]apiBatch, len(list))
+	for _, b := range list {
+		c.batches[b.ID] = b
+	}
+	onChange := c.onChange
+	c.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+	return nil
+}
+
+// stream loads the list once the event stream is open, so no event falls
+// between the two, then applies the server's batch events until the stream
+// ends
+func (c *teamClient) stream(ctx context.Context) error {
+	c.mu.Lock()
+	base, token := c.base, c.token
+	c.mu.Unlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/api/watch", nil)
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := teamStreamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", resp.Status)
+	}
+	if err := c.refresh(ctx); err != nil {
+		return err
+	}
+	var who struct {
+		Role string `json:"role"`
+	}
+	if err := c.request(ctx, http.MethodGet, "/api/whoami", nil, &who); err != nil {
+		who.Role = ""
+	}
+	c.mu.Lock()
+	c.role = who.Role
+	c.mu.Unlock()
+	if who.Role == apiRoleViewer {
+		c.setStatus("已连接 " + base + " · 👁 只读, 签收需要签收令牌")
+	} else {
+		c.setStatus("已连接 " + base)
+	}
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var ev batchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			continue
+		}
+		c.apply(ev)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("服务器关闭了事件流")
+}
+
+// apply updates the mirror with one event
+func (c *teamClient) apply(ev batchEvent) {
+	c.mu.Lock()
+	if c.batches == nil {
+		c.mu.Unlock()
+		return
+	}
+	if ev.Type == eventDeleted {
+		delete(c.batches, ev.Batch.ID)
+	} else {
+		c.batches[ev.Batch.ID] = ev.Batch
+	}
+	onChange := c.onChange
+	c.mu.Unlock()
+	if onChange != nil {
+		onChange()
+	}
+}
+
+// Sign signs a batch on the server as the configured operator and updates
+// the mirror with the result
+func (c *teamClient) Sign(ctx context.Context, id, comment string) error {
+	var b apiBatch
+	req := apiSignRequest{By: config.OperatorName, Comment: comment}
+	if err := c.request(ctx, http.MethodPost, "/api/batches/"+url.PathEscape(id)+"/sign", req, &b); err != nil {
+		return err
+	}
+	c.apply(batchEvent{Type: eventSigned, Time: time.Now(), Batch: b})
+	return nil
+}
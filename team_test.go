@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTeamServerURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+		ok       bool
+	}{
+		{"192.168.1.20:8765", "http://192.168.1.20:8765", true},
+		{"https://watch.example.com/", "https://watch.example.com", true},
+		{" ", "", false},
+		{"ftp://host", "", false},
+	}
+	for _, tt := range tests {
+		got, err := teamServerURL(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("teamServerURL(%q) = %q, %v; want %q, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestTeamClientMirrorsAndSigns(t *testing.T) {
+	origConfig, origBatches := config, batches
+	defer func() { config, batches = origConfig, origBatches }()
+	config = Config{APIToken: "secret", OperatorName: "Lin"}
+	now := time.Now()
+	batches = map[string]*Batch{
+		"b1": {ID: "b1", Folder: "/in/客户A", Status: "completed", Files: []string{"a.mp4"}, StartTime: now.Add(-time.Hour)},
+		"b2": {ID: "b2", Folder: "/in/客户B", Status: "uploading", StartTime: now},
+	}
+	srv := httptest.NewServer(newAPIHandler(func() {}, nil))
+	defer srv.Close()
+
+	c := &teamClient{}
+	changed := make(chan struct{}, 100)
+	if err := c.Connect(srv.URL, "secret", func() { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	waitFor := func(what string, ok func() bool) {
+		t.Helper()
+		deadline := time.After(5 * time.Second)
+		for !ok() {
+			select {
+			case <-changed:
+			case <-deadline:
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+	waitFor("the batch list", func() bool { return len(c.Batches()) == 2 })
+	if list := c.Batches(); list[0].ID != "b2" || list[1].Files != 1 {
+		t.Errorf("Batches() = %+v, want b2 first and b1 with 1 file", list)
+	}
+	waitFor("the event stream", func() bool { return c.Status() == "已连接 "+srv.URL })
+
+	// A sign-off made on the server reaches the client through the stream
+	batchesMu.Lock()
+	batches["b2"].Status = "completed"
+	signBatchAs(batches["b2"], "Wang", "user:wang", "", now)
+	batchesMu.Unlock()
+	waitFor("the server's sign-off", func() bool { return c.Batches()[0].Status == "signed" })
+
+	if err := c.Sign(context.Background(), "b1", "ok"); err != nil {
+		t.Fatal(err)
+	}
+	batchesMu.RLock()
+	got := batches["b1"].SignOffs
+	batchesMu.RUnlock()
+	if len(got) != 1 || got[0].By != "Lin" || got[0].Comment != "ok" {
+		t.Errorf("server sign-offs = %+v, want one by Lin", got)
+	}
+	if b := c.Batches()[1]; b.Status != "signed" {
+		t.Errorf("mirrored status = %s, want signed", b.Status)
+	}
+	if err := c.Sign(context.Background(), "b1", ""); err == nil {
+		t.Error("signing a signed batch succeeded")
+	}
+}
+
+func TestTeamClientViewer(t *testing.T) {
+	origConfig, origBatches := config, batches
+	defer func() { config, batches = origConfig, origBatches }()
+	config = Config{APIToken: "view", SignToken: "sign"}
+	batches = map[string]*Batch{"b1": {ID: "b1", Status: "completed"}}
+	srv := httptest.NewServer(newAPIHandler(func() {}, nil))
+	defer srv.Close()
+
+	c := &teamClient{}
+	changed := make(chan struct{}, 100)
+	if err := c.Connect(srv.URL, "view", func() { changed <- struct{}{} }); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Disconnect()
+	deadline := time.After(5 * time.Second)
+	for c.CanSign() {
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatal("timed out waiting for the viewer role")
+		}
+	}
+	if err := c.Sign(context.Background(), "b1", ""); err == nil {
+		t.Error("a viewer signed a batch")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// newTeamView builds the team page: the connection to a FidruaWatch server
+// and the server's batches with sign actions. It connects right away when
+// a server was saved.
+func newTeamView(save func() bool, w fyne.Window) fyne.CanvasObject {
+	serverEntry := widget.NewEntry()
+	serverEntry.SetPlaceHolder("服务器地址, 如 192.168.1.20:8765")
+	serverEntry.SetText(config.TeamServer)
+	tokenEntry := widget.NewPasswordEntry()
+	tokenEntry.SetPlaceHolder("服务器的访问令牌, 签收需要签收令牌")
+	tokenEntry.SetText(config.TeamToken)
+	connLabel := widget.NewLabel("未连接")
+	connLabel.Wrapping = fyne.TextWrapWord
+
+	var list *widget.List
+	var shown []apiBatch
+	var connectBtn *widget.Button
+	refresh := func() {
+		fyne.Do(func() {
+			shown = team.Batches()
+			if team.Connected() {
+				connLabel.SetText(team.Status())
+				connectBtn.SetText("断开")
+			} else {
+				connLabel.SetText("未连接")
+				connectBtn.SetText("🔗 连接")
+			}
+			list.Refresh()
+		})
+	}
+
+	list = widget.NewList(
+		func() int { return len(shown) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, nil, widget.NewButton("✍️ 签收", nil), l)
+		},
+		nil,
+	)
+	list.UpdateItem = func(i widget.ListItemID, o fyne.CanvasObject) {
+		b := shown[i]
+		row := o.(*fyne.Container)
+		text := fmt.Sprintf("%s · %s · %d 个文件 · %s", b.Folder, statusLabel(b.Status), b.Files, formatSize(b.TotalSize))
+		if n := len(b.SignOffs); n > 0 {
+			last := b.SignOffs[n-1]
+			text += fmt.Sprintf(" · %s: %s", signActionLabel(last.Action), last.By)
+		}
+		row.Objects[0].(*widget.Label).SetText(text)
+		btn := row.Objects[1].(*widget.Button)
+		if team.CanSign() && (b.Status == "completed" || b.Status == statusReview) {
+			btn.Enable()
+		} else {
+			btn.Disable()
+		}
+		btn.OnTapped = func() { showTeamSignDialog(b, w) }
+	}
+
+	connectBtn = widget.NewButton("🔗 连接", func() {
+		if team.Connected() {
+			team.Disconnect()
+			return
+		}
+		if err := team.Connect(serverEntry.Text, tokenEntry.Text, refresh); err != nil {
+			dialog.ShowError(err, w)
+			return
+		}
+		config.TeamServer = serverEntry.Text
+		config.TeamToken = tokenEntry.Text
+		save()
+	})
+	if config.TeamServer != "" {
+		if err := team.Connect(config.TeamServer, config.TeamToken, refresh); err != nil {
+			logEvent("连接团队服务器失败: %v", err)
+		}
+	}
+
+	hint := widget.NewLabel("在队友的电脑上连接接收上传的 FidruaWatch, 即可看到同样的批次并签收。接收端需要在 设置 → 🔌 API 中启用本地 API, 监听地址设为 0.0.0.0:8765 并设置访问令牌; 设置了签收令牌时, 只有使用签收令牌的队友可以签收, 其他人只能查看。签收人为本机设置的操作员。")
+	hint.Wrapping = fyne.TextWrapWord
+	form := container.NewBorder(nil, nil, nil, connectBtn,
+		container.NewGridWithColumns(2, serverEntry, tokenEntry))
+	return container.NewBorder(container.NewVBox(hint, form, connLabel, widget.NewSeparator()), nil, nil, nil, list)
+}
+
+// showTeamSignDialog signs a server batch with an optional comment
+func showTeamSignDialog(b apiBatch, w fyne.Window) {
+	commentEntry := widget.NewEntry()
+	commentEntry.SetPlaceHolder("备注 (可选)")
+	items := []*widget.FormItem{
+		widget.NewFormItem("批次", widget.NewLabel(b.Folder)),
+		widget.NewFormItem("签收人", widget.NewLabel(config.OperatorName)),
+		widget.NewFormItem("备注", commentEntry),
+	}
+	dialog.ShowForm("✍️ 签收", "签收", "取消", items, func(ok bool) {
+		if !ok {
+			return
+		}
+		go func() {
+			if err := team.Sign(context.Background(), b.ID, commentEntry.Text); err != nil {
+				fyne.Do(func() { dialog.ShowError(err, w) })
+			}
+		}()
+	}, w)
+}
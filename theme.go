@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"image/color"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/theme"
+)
+
+// Theme modes
+const (
+	themeDark   = "dark"
+	themeLight  = "light"
+	themeSystem = "system" // follow the OS light/dark setting
+)
+
+var themeOptions = []struct {
+	Mode  string
+	Label string
+}{
+	{themeDark, "深色"},
+	{themeLight, "浅色"},
+	{themeSystem, "跟随系统"},
+}
+
+// defaultAccent is the purple used before the accent was configurable
+const defaultAccent = "#8a2be2"
+
+// colorNameCard is the background of batch cards
+const colorNameCard fyne.ThemeColorName = "fidruaCard"
+
+// themePalette holds the colors customTheme overrides for one variant
+type themePalette map[fyne.ThemeColorName]color.NRGBA
+
+var darkPalette = themePalette{
+	theme.ColorNameBackground:        {R: 20, G: 22, B: 35, A: 255}, // Dark blue background
+	theme.ColorNameButton:            {R: 45, G: 50, B: 80, A: 255},
+	theme.ColorNameDisabledButton:    {R: 35, G: 40, B: 60, A: 255},
+	theme.ColorNameInputBackground:   {R: 30, G: 35, B: 55, A: 255},
+	theme.ColorNameOverlayBackground: {R: 25, G: 28, B: 45, A: 255},
+	theme.ColorNameMenuBackground:    {R: 30, G: 35, B: 55, A: 255},
+	theme.ColorNameSeparator:         {R: 60, G: 65, B: 90, A: 255},
+	theme.ColorNameForeground:        {R: 220, G: 220, B: 230, A: 255},
+	colorNameCard:                    {R: 35, G: 40, B: 60, A: 255},
+}
+
+var lightPalette = themePalette{
+	theme.ColorNameBackground:        {R: 245, G: 246, B: 250, A: 255},
+	theme.ColorNameButton:            {R: 225, G: 228, B: 240, A: 255},
+	theme.ColorNameDisabledButton:    {R: 235, G: 236, B: 242, A: 255},
+	theme.ColorNameInputBackground:   {R: 255, G: 255, B: 255, A: 255},
+	theme.ColorNameOverlayBackground: {R: 250, G: 250, B: 253, A: 255},
+	theme.ColorNameMenuBackground:    {R: 255, G: 255, B: 255, A: 255},
+	theme.ColorNameSeparator:         {R: 210, G: 212, B: 225, A: 255},
+	theme.ColorNameForeground:        {R: 30, G: 32, B: 45, A: 255},
+	colorNameCard:                    {R: 255, G: 255, B: 255, A: 255},
+}
+
+// newCustomTheme builds the app theme from the current settings
+func newCustomTheme() *customTheme {
+	accent, err := parseHexColor(config.AccentColor)
+	if err != nil {
+		accent, _ = parseHexColor(defaultAccent)
+	}
+	return &customTheme{mode: config.Theme, accent: accent, scale: uiScale(config.UIScale)}
+}
+
+// UI scale limits in percent
+const (
+	minUIScale = 80
+	maxUIScale = 160
+)
+
+// uiScale converts the configured percentage to a size factor, clamped so a
+// bad config can't make the window unusable
+func uiScale(percent int) float32 {
+	switch {
+	case percent == 0:
+		percent = 100
+	case percent < minUIScale:
+		percent = minUIScale
+	case percent > maxUIScale:
+		percent = maxUIScale
+	}
+	return float32(percent) / 100
+}
+
+// variant resolves the mode to the light or dark variant; requested is the
+// variant fyne asks for, which follows the OS setting
+func (t *customTheme) variant(requested fyne.ThemeVariant) fyne.ThemeVariant {
+	switch t.mode {
+	case themeLight:
+		return theme.VariantLight
+	case themeSystem:
+		return requested
+	}
+	return theme.VariantDark
+}
+
+// parseHexColor parses "#rrggbb"
+func parseHexColor(s string) (color.NRGBA, error) {
+	c := color.NRGBA{A: 255}
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &c.R, &c.G, &c.B); err != nil || len(s) != 7 {
+		return color.NRGBA{}, fmt.Errorf("invalid color %q", s)
+	}
+	return c, nil
+}
+
+// hexColor formats a color as "#rrggbb"
+func hexColor(c color.Color) string {
+	n := color.NRGBAModel.Convert(c).(color.NRGBA)
+	return fmt.Sprintf("#%02x%02x%02x", n.R, n.G, n.B)
+}
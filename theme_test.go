@@ -0,0 +1,69 @@
+package main
+
+import (
+	"image/color"
+	"testing"
+
+	"fyne.io/fyne/v2/theme"
+)
+
+func TestParseHexColor(t *testing.T) {
+	c, err := parseHexColor("#8a2be2")
+	if err != nil || c != (color.NRGBA{R: 138, G: 43, B: 226, A: 255}) {
+		t.Errorf("parseHexColor = %v, %v", c, err)
+	}
+	if hexColor(c) != "#8a2be2" {
+		t.Errorf("hexColor = %q", hexColor(c))
+	}
+	for _, bad := range []string{"", "8a2be2", "#8a2be", "#zzzzzz"} {
+		if _, err := parseHexColor(bad); err == nil {
+			t.Errorf("parseHexColor(%q) accepted", bad)
+		}
+	}
+}
+
+func TestCustomThemeModes(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+
+	config.Theme, config.AccentColor = themeLight, "#ff0000"
+	th := newCustomTheme()
+	if got := th.Color(theme.ColorNameBackground, theme.VariantDark); got != lightPalette[theme.ColorNameBackground] {
+		t.Errorf("light theme background = %v", got)
+	}
+	if got := th.Color(theme.ColorNamePrimary, theme.VariantDark); got != (color.NRGBA{R: 255, A: 255}) {
+		t.Errorf("accent = %v", got)
+	}
+
+	config.Theme = themeSystem
+	th = newCustomTheme()
+	if got := th.Color(theme.ColorNameBackground, theme.VariantDark); got != darkPalette[theme.ColorNameBackground] {
+		t.Errorf("system theme ignores the OS variant: %v", got)
+	}
+	if got := th.Color(theme.ColorNameBackground, theme.VariantLight); got != lightPalette[theme.ColorNameBackground] {
+		t.Errorf("system theme ignores the OS variant: %v", got)
+	}
+
+	// An invalid accent falls back to the default purple
+	config.Theme, config.AccentColor = themeDark, "purple"
+	if got := newCustomTheme().accent; hexColor(got) != defaultAccent {
+		t.Errorf("fallback accent = %v", got)
+	}
+}
+
+func TestUIScale(t *testing.T) {
+	cases := map[int]float32{0: 1, 100: 1, 120: 1.2, 10: 0.8, 500: 1.6}
+	for percent, want := range cases {
+		if got := uiScale(percent); got != want {
+			t.Errorf("uiScale(%d) = %v, want %v", percent, got, want)
+		}
+	}
+
+	saved := config
+	defer func() { config = saved }()
+	config.UIScale = 150
+	th := newCustomTheme()
+	if got, want := th.Size(theme.SizeNameText), theme.DefaultTheme().Size(theme.SizeNameText)*1.5; got != want {
+		t.Errorf("scaled text size = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import "time"
+
+// refreshThrottle spaces out batch list rebuilds during heavy ingest. While
+// rebuilds are cheap, or the UI has been idle, refreshes happen immediately;
+// once a rebuild takes longer than heavyRebuild, refreshes are limited to one
+// per minInterval so the window stays responsive during large drops.
+type refreshThrottle struct {
+	minInterval  time.Duration
+	heavyRebuild time.Duration
+	lastEnd      time.Time
+	lastDuration time.Duration
+}
+
+func newRefreshThrottle() *refreshThrottle {
+	return &refreshThrottle{
+		minInterval:  500 * time.Millisecond, // at most 2 refreshes/sec under load
+		heavyRebuild: 30 * time.Millisecond,
+	}
+}
+
+// Delay returns how long to wait before the next rebuild
+func (t *refreshThrottle) Delay(now time.Time) time.Duration {
+	if t.lastDuration < t.heavyRebuild {
+		return 0
+	}
+	since := now.Sub(t.lastEnd)
+	if since >= t.minInterval {
+		return 0
+	}
+	return t.minInterval - since
+}
+
+// Done records a rebuild that ran from start to end
+func (t *refreshThrottle) Done(start, end time.Time) {
+	t.lastEnd = end
+	t.lastDuration = end.Sub(start)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRefreshThrottle(t *testing.T) {
+	th := newRefreshThrottle()
+	now := time.Now()
+
+	if d := th.Delay(now); d != 0 {
+		t.Errorf("First refresh delayed by %v", d)
+	}
+
+	// Cheap rebuilds are never delayed
+	th.Done(now, now.Add(5*time.Millisecond))
+	if d := th.Delay(now.Add(10 * time.Millisecond)); d != 0 {
+		t.Errorf("Cheap rebuild delayed by %v", d)
+	}
+
+	// A heavy rebuild limits the refresh rate
+	start := now.Add(time.Second)
+	end := start.Add(100 * time.Millisecond)
+	th.Done(start, end)
+	if d := th.Delay(end.Add(100 * time.Millisecond)); d != 400*time.Millisecond {
+		t.Errorf("Delay after heavy rebuild = %v, want 400ms", d)
+	}
+
+	// After an idle period the next refresh is immediate again
+	if d := th.Delay(end.Add(time.Second)); d != 0 {
+		t.Errorf("Delay after idle = %v, want 0", d)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Session throughput history: one sample per checkCompletions tick for an
+// hour or so
+const (
+	sessionRateSamples    = 1200
+	sessionRateResolution = 3 * time.Second
+)
+
+// ratePoint is the observed transfer rate over the interval ending at Time
+type ratePoint struct {
+	Time time.Time
+	Rate float64 // bytes per second
+}
+
+// rateSeries turns size samples into the rate between consecutive ones.
+// Shrinking sizes (files rewritten) count as no transfer.
+func rateSeries(samples []Sample) []ratePoint {
+	var points []ratePoint
+	for i := 1; i < len(samples); i++ {
+		elapsed := samples[i].Time.Sub(samples[i-1].Time).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		rate := 0.0
+		if grown := samples[i].Size - samples[i-1].Size; grown > 0 {
+			rate = float64(grown) / elapsed
+		}
+		points = append(points, ratePoint{Time: samples[i].Time, Rate: rate})
+	}
+	return points
+}
+
+// peakRate returns the highest rate of points
+func peakRate(points []ratePoint) float64 {
+	var peak float64
+	for _, p := range points {
+		if p.Rate > peak {
+			peak = p.Rate
+		}
+	}
+	return peak
+}
+
+// sessionThroughput records the bytes received by all batches of the
+// monitoring session over time. Batches only add the growth seen between
+// ticks, so completed or evicted batches don't make the total drop.
+type sessionThroughput struct {
+	mu       sync.Mutex
+	session  string
+	received int64
+	seen     map[string]int64 // last TotalSize per batch
+	samples  *SampleRing
+}
+
+var sessionRates = &sessionThroughput{}
+
+// Note samples the batches of the current session. Caller must hold
+// batchesMu.
+func (s *sessionThroughput) Note(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.session != sessionID || s.samples == nil {
+		s.session = sessionID
+		s.received = 0
+		s.seen = make(map[string]int64)
+		s.samples = NewSampleRing(sessionRateSamples, sessionRateResolution)
+	}
+	if sessionID == "" {
+		return
+	}
+	for _, b := range batches {
+		if b.SessionID != sessionID {
+			continue
+		}
+		// Batches of this session arrived in it, their first sight counts in full
+		if last := s.seen[b.ID]; b.TotalSize > last {
+			s.received += b.TotalSize - last
+		}
+		s.seen[b.ID] = b.TotalSize
+	}
+	s.samples.Add(Sample{Time: now, Size: s.received})
+}
+
+// Rates returns the session's transfer rate over time, oldest first
+func (s *sessionThroughput) Rates() []ratePoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return rateSeries(s.samples.Samples())
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateSeries(t *testing.T) {
+	start := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	points := rateSeries([]Sample{
+		{Time: start, Size: 0},
+		{Time: start.Add(2 * time.Second), Size: 2000},
+		{Time: start.Add(4 * time.Second), Size: 1000}, // rewritten smaller
+		{Time: start.Add(4 * time.Second), Size: 1500}, // no time elapsed
+		{Time: start.Add(8 * time.Second), Size: 5000},
+	})
+	want := []float64{1000, 0, 875}
+	if len(points) != len(want) {
+		t.Fatalf("Got %d points, want %d", len(points), len(want))
+	}
+	for i, p := range points {
+		if p.Rate != want[i] {
+			t.Errorf("Point %d rate = %v, want %v", i, p.Rate, want[i])
+		}
+	}
+	if peakRate(points) != 1000 {
+		t.Errorf("peakRate = %v, want 1000", peakRate(points))
+	}
+}
+
+func TestSessionThroughput(t *testing.T) {
+	oldBatches, oldSession := batches, sessionID
+	defer func() { batches, sessionID = oldBatches, oldSession }()
+
+	s := &sessionThroughput{}
+	sessionID = "s1"
+	a := &Batch{ID: "a", SessionID: "s1", TotalSize: 1000}
+	other := &Batch{ID: "o", SessionID: "s0", TotalSize: 9999}
+	batches = map[string]*Batch{"a": a, "o": other}
+
+	start := time.Date(2026, 5, 1, 10, 0, 0, 0, time.UTC)
+	s.Note(start)
+	a.TotalSize = 4000
+	s.Note(start.Add(3 * time.Second))
+	// The batch leaving the list doesn't count as negative transfer
+	delete(batches, "a")
+	s.Note(start.Add(6 * time.Second))
+
+	points := s.Rates()
+	if len(points) != 2 || points[0].Rate != 1000 || points[1].Rate != 0 {
+		t.Errorf("Rates = %+v, want 1000 then 0", points)
+	}
+
+	sessionID = "s2"
+	s.Note(start.Add(9 * time.Second))
+	if points := s.Rates(); len(points) != 0 {
+		t.Errorf("New session kept %d points", len(points))
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for thumbnails
+	"image/jpeg"
+	_ "image/png"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	_ "golang.org/x/image/bmp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// thumbSize is the longest edge of a generated thumbnail in pixels
+const thumbSize = 160
+
+// videoThumbTimeout bounds ffmpeg, which can hang on a truncated or still
+// growing file
+const videoThumbTimeout = 30 * time.Second
+
+// thumbDir holds cached thumbnails, set next to the config file
+var thumbDir string
+
+// thumbExts are the image formats thumbnails can be decoded from
+var thumbExts = []string{".jpg", ".jpeg", ".png", ".gif", ".bmp", ".webp"}
+
+// thumbCachePath returns the cache file for a source file; size and
+// modification time are part of the key so replaced files get new thumbnails
+func thumbCachePath(src string, info os.FileInfo) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d|%d", src, info.Size(), info.ModTime().UnixNano())))
+	return filepath.Join(thumbDir, hex.EncodeToString(sum[:])+".jpg")
+}
+
+// thumbnail returns the path of a cached thumbnail for src, generating it if
+// needed. Videos need ffmpeg on PATH for a first-frame still.
+func thumbnail(src string) (string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+	dst := thumbCachePath(src, info)
+	if _, err := os.Stat(dst); err == nil {
+		return dst, nil
+	}
+	if err := os.MkdirAll(thumbDir, 0755); err != nil {
+		return "", err
+	}
+
+	switch {
+	case hasExt(src, thumbExts):
+		err = imageThumbnail(src, dst)
+	case hasExt(src, videoExts):
+		err = videoThumbnail(src, dst)
+	default:
+		return "", fmt.Errorf("不支持的文件类型: %s", filepath.Ext(src))
+	}
+	if err != nil {
+		os.Remove(dst)
+		return "", err
+	}
+	pruneThumbCache(int64(config.ThumbCacheMB) * 1024 * 1024)
+	return dst, nil
+}
+
+// scaleToFit returns w×h scaled down so the longest edge is at most max
+func scaleToFit(w, h, max int) (int, int) {
+	if w <= max && h <= max {
+		return w, h
+	}
+	if w >= h {
+		return max, h * max / w
+	}
+	return w * max / h, max
+}
+
+// imageThumbnail decodes an image and writes a scaled-down JPEG
+func imageThumbnail(src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return err
+	}
+	b := img.Bounds()
+	w, h := scaleToFit(b.Dx(), b.Dy(), thumbSize)
+	if w < 1 || h < 1 {
+		return fmt.Errorf("图片尺寸无效")
+	}
+	thumb := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.ApproxBiLinear.Scale(thumb, thumb.Bounds(), img, b, draw.Src, nil)
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(out, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// videoThumbnail grabs a still from the first second of a video with ffmpeg
+func videoThumbnail(src, dst string) error {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		return fmt.Errorf("未找到 ffmpeg")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), videoThumbTimeout)
+	defer cancel()
+	scale := "scale='min(" + strconv.Itoa(thumbSize) + ",iw)':-2"
+	err = exec.CommandContext(ctx, ffmpeg, "-v", "error", "-y", "-ss", "1", "-i", src,
+		"-frames:v", "1", "-vf", scale, dst).Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("ffmpeg 超时")
+	}
+	return err
+}
+
+// pruneThumbCache deletes the least recently written thumbnails until the
+// cache is within limit bytes
+func pruneThumbCache(limit int64) {
+	if limit <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(thumbDir)
+	if err != nil {
+		return
+	}
+	var files []os.FileInfo
+	var total int64
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && !e.IsDir() {
+			files = append(files, info)
+			total += info.Size()
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, f := range files {
+		if total <= limit {
+			break
+		}
+		if os.Remove(filepath.Join(thumbDir, f.Name())) == nil {
+			total -= f.Size()
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestScaleToFit(t *testing.T) {
+	cases := []struct{ w, h, wantW, wantH int }{
+		{100, 50, 100, 50},
+		{1600, 900, 160, 90},
+		{900, 1600, 90, 160},
+	}
+	for _, c := range cases {
+		if w, h := scaleToFit(c.w, c.h, 160); w != c.wantW || h != c.wantH {
+			t.Errorf("scaleToFit(%d, %d) = %d×%d, want %d×%d", c.w, c.h, w, h, c.wantW, c.wantH)
+		}
+	}
+}
+
+func TestImageThumbnailCached(t *testing.T) {
+	saved := thumbDir
+	defer func() { thumbDir = saved }()
+	dir := t.TempDir()
+	thumbDir = filepath.Join(dir, "thumbs")
+
+	src := filepath.Join(dir, "photo.png")
+	img := image.NewRGBA(image.Rect(0, 0, 640, 480))
+	for x := 0; x < 640; x++ {
+		img.Set(x, 10, color.RGBA{255, 0, 0, 255})
+	}
+	f, err := os.Create(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	png.Encode(f, img)
+	f.Close()
+
+	thumb, err := thumbnail(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tf, err := os.Open(thumb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tf.Close()
+	cfg, err := jpeg.DecodeConfig(tf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 160 || cfg.Height != 120 {
+		t.Errorf("thumbnail is %d×%d, want 160×120", cfg.Width, cfg.Height)
+	}
+
+	again, err := thumbnail(src)
+	if err != nil || again != thumb {
+		t.Errorf("second call = %q, %v; want cached %q", again, err, thumb)
+	}
+}
+
+func TestPruneThumbCache(t *testing.T) {
+	saved := thumbDir
+	defer func() { thumbDir = saved }()
+	thumbDir = t.TempDir()
+
+	old := time.Now().Add(-time.Hour)
+	for i, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		p := filepath.Join(thumbDir, name)
+		os.WriteFile(p, make([]byte, 100), 0644)
+		mt := old.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(p, mt, mt)
+	}
+	pruneThumbCache(250)
+
+	if _, err := os.Stat(filepath.Join(thumbDir, "a.jpg")); !os.IsNotExist(err) {
+		t.Error("oldest thumbnail was not removed")
+	}
+	for _, name := range []string{"b.jpg", "c.jpg"} {
+		if _, err := os.Stat(filepath.Join(thumbDir, name)); err != nil {
+			t.Errorf("%s removed: %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Timeline entry kinds
+const (
+	timelineCreated   = "created"   // first file detected
+	timelineFile      = "file"      // a file was added, Detail is its name
+	timelineMoreFiles = "more"      // further files beyond maxTimelineFiles, Count of them
+	timelineStalled   = "stalled"   // nothing grew for config.StallMinutes
+	timelineResumed   = "resumed"   // data arrived again after a stall
+	timelineCompleted = "completed" // the completion timeout passed
+	timelineReopened  = "reopened"  // back to uploading after completing
+	timelineSigned    = "signed"    // reviewed or signed, Detail is the sign-off
+	timelineRestored  = "restored"  // brought back after a restart
+	timelineOutage    = "outage"    // the watched folder was lost, Detail is why
+	timelineWatchBack = "watchback" // the watched folder was re-attached, Detail is how long it was lost
+	timelineIngested  = "ingested"  // copied off the card and verified, Detail is where to
+)
+
+// maxTimelineFiles caps the per-file entries of a timeline; later files
+// are counted in one entry so huge batches don't bloat the history
+const maxTimelineFiles = 200
+
+// TimelineEntry is one step in how a batch arrived
+type TimelineEntry struct {
+	Time   time.Time `json:"time"`
+	Kind   string    `json:"kind"`
+	Detail string    `json:"detail,omitempty"`
+	Count  int       `json:"count,omitempty"`
+}
+
+// noteTimeline appends an entry to the timeline of b. Caller must hold
+// batchesMu.
+func noteTimeline(b *Batch, kind, detail string, now time.Time) {
+	if kind == timelineFile {
+		files := 0
+		for i := len(b.Timeline) - 1; i >= 0; i-- {
+			switch b.Timeline[i].Kind {
+			case timelineMoreFiles:
+				b.Timeline[i].Count++
+				b.Timeline[i].Time = now
+				return
+			case timelineFile:
+				files++
+			}
+		}
+		if files >= maxTimelineFiles {
+			kind, detail = timelineMoreFiles, ""
+		}
+	}
+	e := TimelineEntry{Time: now, Kind: kind, Detail: detail}
+	if kind == timelineMoreFiles {
+		e.Count = 1
+	}
+	b.Timeline = append(b.Timeline, e)
+}
+
+// timelineText describes an entry for the detail views
+func timelineText(e TimelineEntry) string {
+	switch e.Kind {
+	case timelineCreated:
+		return "🆕 检测到第一个文件: " + e.Detail
+	case timelineFile:
+		return "📄 新增 " + e.Detail
+	case timelineMoreFiles:
+		return fmt.Sprintf("📄 … 另有 %d 个文件, 最后一个在此时", e.Count)
+	case timelineStalled:
+		return "⏸️ 停滞: " + e.Detail
+	case timelineResumed:
+		return "▶️ 恢复传输, " + e.Detail
+	case timelineCompleted:
+		return "✅ 上传完成 " + e.Detail
+	case timelineReopened:
+		return "🔄 重新打开"
+	case timelineSigned:
+		return "✍️ " + e.Detail
+	case timelineRestored:
+		return "♻️ 重启后恢复"
+	case timelineOutage:
+		return "🚫 监控中断: " + e.Detail
+	case timelineWatchBack:
+		return "🔗 监控恢复, " + e.Detail
+	case timelineIngested:
+		return "📥 已导入并校验: " + e.Detail
+	}
+	return e.Kind + " " + e.Detail
+}
+
+// timelineLines formats a timeline for the detail views. Times are to the
+// second; the date is only written when it changes.
+func timelineLines(entries []TimelineEntry) []string {
+	lines := make([]string, 0, len(entries))
+	var day string
+	for _, e := range entries {
+		t := e.Time.Local()
+		stamp := formatClock(t, true)
+		if dayKey(t) != day {
+			day = dayKey(t)
+			stamp = formatDateTime(t, true)
+		}
+		lines = append(lines, stamp+"  "+timelineText(e))
+	}
+	return lines
+}
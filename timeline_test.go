@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBatchTimeline(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.StallMinutes = 10
+
+	start := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	b := &Batch{Status: "uploading"}
+	noteTimeline(b, timelineCreated, "a.mp4", start)
+	noteGrowth(b, start)
+	checkStalled(b, start.Add(11*time.Minute))
+	noteGrowth(b, start.Add(15*time.Minute))
+	noteTimeline(b, timelineFile, "b.mp4", start.Add(15*time.Minute))
+	resumeUploading(b, start.Add(time.Hour))
+
+	var kinds []string
+	for _, e := range b.Timeline {
+		kinds = append(kinds, e.Kind)
+	}
+	want := []string{timelineCreated, timelineStalled, timelineResumed, timelineFile, timelineReopened}
+	if strings.Join(kinds, ",") != strings.Join(want, ",") {
+		t.Fatalf("timeline kinds = %v, want %v", kinds, want)
+	}
+	if d := b.Timeline[2].Detail; d != "停滞了 00:15:00" {
+		t.Errorf("resume detail = %q", d)
+	}
+
+	lines := timelineLines(b.Timeline)
+	if !strings.HasPrefix(lines[0], formatDateTime(start, true)) || !strings.HasPrefix(lines[1], formatClock(start.Add(11*time.Minute), true)+"  ") {
+		t.Errorf("timeline lines = %q", lines)
+	}
+	if next := timelineLines([]TimelineEntry{{Time: start}, {Time: start.AddDate(0, 0, 1)}}); !strings.HasPrefix(next[1], formatDateTime(start.AddDate(0, 0, 1), true)) {
+		t.Errorf("a new day should show its date: %q", next[1])
+	}
+}
+
+func TestTimelineFileCap(t *testing.T) {
+	b := &Batch{}
+	now := time.Now()
+	for i := 0; i < maxTimelineFiles+5; i++ {
+		noteTimeline(b, timelineFile, "f", now.Add(time.Duration(i)*time.Second))
+	}
+	noteTimeline(b, timelineCompleted, "", now.Add(time.Hour))
+	noteTimeline(b, timelineFile, "late", now.Add(2*time.Hour))
+
+	if len(b.Timeline) != maxTimelineFiles+2 {
+		t.Fatalf("timeline has %d entries, want %d", len(b.Timeline), maxTimelineFiles+2)
+	}
+	more := b.Timeline[maxTimelineFiles]
+	if more.Kind != timelineMoreFiles || more.Count != 6 || !more.Time.Equal(now.Add(2*time.Hour)) {
+		t.Errorf("overflow entry = %+v, want 6 more files at the last one's time", more)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Copies made by completion actions, e.g. a rule moving a batch to a NAS,
+// share the link the uploads arrive on. All of them together are held to
+// config.TransferLimitKB per second, and at most config.TransferJobs run at
+// once.
+
+// transferChunk is how much is read between waits of the rate limiter
+const transferChunk = 64 * 1024
+
+// transferLimit returns the rate cap in bytes per second, 0 for none
+func transferLimit() int64 {
+	return int64(config.TransferLimitKB) * 1024
+}
+
+// rateLimiter spaces out bytes to stay under transferLimit. The rate is
+// read on every call, so a new setting applies to running copies.
+type rateLimiter struct {
+	mu   sync.Mutex
+	next time.Time // when the bytes handed out so far are paid for
+}
+
+var transferRate = &rateLimiter{}
+
+// Wait blocks until n more bytes fit under the cap
+func (l *rateLimiter) Wait(ctx context.Context, n int) error {
+	limit := transferLimit()
+	if limit <= 0 {
+		return nil
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / limit))
+	wait := l.next.Sub(now)
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// limitedReader reads through transferRate
+type limitedReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// limitReader caps reads from r at the transfer rate
+func limitReader(ctx context.Context, r io.Reader) io.Reader {
+	return &limitedReader{ctx, r}
+}
+
+func (lr *limitedReader) Read(p []byte) (int, error) {
+	if transferLimit() > 0 && len(p) > transferChunk {
+		p = p[:transferChunk]
+	}
+	n, err := lr.r.Read(p)
+	if n > 0 {
+		if werr := transferRate.Wait(lr.ctx, n); werr != nil {
+			return n, werr
+		}
+	}
+	return n, err
+}
+
+// transferGate admits at most config.TransferJobs copies at once
+type transferGate struct {
+	mu     sync.Mutex
+	active int
+	freed  chan struct{} // closed when a copy finishes
+}
+
+var transferJobs = &transferGate{}
+
+// Acquire waits for a free slot and returns the function releasing it
+func (g *transferGate) Acquire(ctx context.Context) (func(), error) {
+	for {
+		g.mu.Lock()
+		if limit := config.TransferJobs; limit <= 0 || g.active < limit {
+			g.active++
+			g.mu.Unlock()
+			var once sync.Once
+			return func() { once.Do(g.release) }, nil
+		}
+		if g.freed == nil {
+			g.freed = make(chan struct{})
+		}
+		freed := g.freed
+		g.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-freed:
+		}
+	}
+}
+
+func (g *transferGate) release() {
+	g.mu.Lock()
+	g.active--
+	if g.freed != nil {
+		close(g.freed)
+		g.freed = nil
+	}
+	g.mu.Unlock()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLimitReader(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.TransferLimitKB = 1024 // 1 MB/s
+
+	data := make([]byte, 256*1024)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, limitReader(context.Background(), bytes.NewReader(data)))
+	if err != nil || n != int64(len(data)) {
+		t.Fatalf("copied %d, %v", n, err)
+	}
+	if d := time.Since(start); d < 200*time.Millisecond || d > 2*time.Second {
+		t.Errorf("256 KB at 1 MB/s took %v, want about 250ms", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := io.Copy(io.Discard, limitReader(ctx, bytes.NewReader(data))); err != context.Canceled {
+		t.Errorf("cancelled copy: %v", err)
+	}
+
+	config.TransferLimitKB = 0
+	start = time.Now()
+	io.Copy(io.Discard, limitReader(context.Background(), bytes.NewReader(make([]byte, 64<<20))))
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("unlimited copy took %v", d)
+	}
+}
+
+func TestTransferGate(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.TransferJobs = 1
+	g := &transferGate{}
+
+	release, err := g.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(chan func())
+	go func() {
+		r, _ := g.Acquire(context.Background())
+		got <- r
+	}()
+	select {
+	case <-got:
+		t.Fatal("second copy started while the only slot was taken")
+	case <-time.After(50 * time.Millisecond):
+	}
+	release()
+	release() // releasing twice frees one slot
+	select {
+	case r := <-got:
+		r()
+	case <-time.After(5 * time.Second):
+		t.Fatal("second copy never started")
+	}
+
+	release, _ = g.Acquire(context.Background())
+	defer release()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := g.Acquire(ctx); err == nil {
+		t.Error("Acquire ignored its context")
+	}
+}
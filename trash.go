@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// undoWindow is how long the undo toast stays after a delete
+	undoWindow = 10 * time.Second
+	// trashKeepDays is how long deleted batches can be restored
+	trashKeepDays = 30
+)
+
+var (
+	// trashPath holds the deleted batches. It is a small JSON file
+	// whatever the storage engine.
+	trashPath string
+	trashMu   sync.Mutex
+)
+
+// trashRecord is a deleted batch in the history record format
+type trashRecord struct {
+	HistoryRecord
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// expired reports whether a deleted batch is past restoring
+func (r trashRecord) expired(now time.Time) bool {
+	return now.Sub(r.DeletedAt) > trashKeepDays*24*time.Hour
+}
+
+// readTrash returns the deleted batches, newest first. Caller must hold
+// trashMu.
+func readTrash() ([]trashRecord, error) {
+	data, err := os.ReadFile(trashPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var recs []trashRecord
+	if err := json.Unmarshal(data, &recs); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(recs, func(i, j int) bool { return recs[i].DeletedAt.After(recs[j].DeletedAt) })
+	return recs, nil
+}
+
+// writeTrash replaces the trash with recs, dropping expired ones, or
+// removes the file when none are left. Caller must hold trashMu.
+func writeTrash(recs []trashRecord, now time.Time) error {
+	kept := recs[:0:0]
+	for _, r := range recs {
+		if !r.expired(now) {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == 0 {
+		if err := os.Remove(trashPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		return err
+	}
+	return writeFileAtomic(trashPath, data, 0644)
+}
+
+// trashBatches removes batches from the list, keeping them in the trash so
+// they can be restored. Files on disk are untouched. Caller must hold
+// batchesMu.
+func trashBatches(bs []*Batch, now time.Time) {
+	if len(bs) == 0 {
+		return
+	}
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	recs, err := readTrash()
+	if err != nil {
+		logEvent("读取回收站失败: %v", err)
+	}
+	for _, b := range bs {
+		recs = append(recs, trashRecord{HistoryRecord: historyRecordFor(b), DeletedAt: now})
+		logEvent("删除批次 %s: %s", b.ID, b.Folder)
+		delete(batches, b.ID)
+		publishBatchEvent(eventDeleted, b)
+	}
+	if err := writeTrash(recs, now); err != nil {
+		logEvent("写入回收站失败: %v", err)
+	}
+}
+
+// undoDelete puts just-deleted batches back as they were and takes them
+// out of the trash
+func undoDelete(bs []*Batch) {
+	batchesMu.Lock()
+	defer batchesMu.Unlock()
+	ids := make(map[string]bool)
+	for _, b := range bs {
+		if _, exists := batches[b.ID]; exists {
+			continue
+		}
+		batches[b.ID] = b
+		ids[b.ID] = true
+		logEvent("撤销删除批次 %s: %s", b.ID, b.Folder)
+		publishBatchEvent(eventRestored, b)
+	}
+	if err := removeFromTrash(ids); err != nil {
+		logEvent("写入回收站失败: %v", err)
+	}
+}
+
+// removeFromTrash drops the given batches from the trash
+func removeFromTrash(ids map[string]bool) error {
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	recs, err := readTrash()
+	if err != nil {
+		return err
+	}
+	kept := recs[:0]
+	for _, r := range recs {
+		if !ids[r.ID] {
+			kept = append(kept, r)
+		}
+	}
+	return writeTrash(kept, time.Now())
+}
+
+// loadTrash returns the batches that can still be restored, newest first
+func loadTrash(now time.Time) ([]trashRecord, error) {
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	recs, err := readTrash()
+	var restorable []trashRecord
+	for _, r := range recs {
+		if !r.expired(now) {
+			restorable = append(restorable, r)
+		}
+	}
+	return restorable, err
+}
+
+// pruneTrash removes deleted batches past restoring
+func pruneTrash(now time.Time) error {
+	trashMu.Lock()
+	defer trashMu.Unlock()
+	recs, err := readTrash()
+	if err != nil || len(recs) == 0 {
+		return err
+	}
+	return writeTrash(recs, now)
+}
+
+// batchFromRecord rebuilds a batch from its history record. A batch that
+// was still uploading gets a fresh completion timeout.
+func batchFromRecord(rec HistoryRecord, now time.Time) *Batch {
+	b := &Batch{
+		ID:        rec.ID,
+		Folder:    rec.Folder,
+		Files:     append([]string{}, rec.Files...),
+		FileSizes: make(map[string]int64, len(rec.FileSizes)),
+		TotalSize: rec.TotalSize,
+		Status:    rec.Status,
+		StartTime: rec.StartTime,
+		LastTime:  rec.EndTime,
+		Uploader:  rec.Uploader,
+		CheckCode: rec.CheckCode,
+		Media:     rec.Media,
+		Exif:      rec.Exif,
+		Archive:   rec.Archive,
+		SignOffs:  append([]SignOff(nil), rec.SignOffs...),
+		Manifest:  rec.Manifest,
+		Checksum:  rec.Checksum,
+		Tags:      append([]string(nil), rec.Tags...),
+		Timeline:  append([]TimelineEntry(nil), rec.Timeline...),
+		DupFiles:  append([]string(nil), rec.DupFiles...),
+		Samples:   newBatchSampleRing(),
+		SessionID: rec.SessionID,
+	}
+	for f, size := range rec.FileSizes {
+		b.FileSizes[f] = size
+	}
+	if b.Status == "uploading" {
+		b.LastTime = now
+	}
+	b.Samples.Add(Sample{Time: b.LastTime, Size: b.TotalSize})
+	return b
+}
+
+// restoreFromTrash brings a deleted batch back into the list
+func restoreFromTrash(id string) error {
+	now := time.Now()
+	recs, err := loadTrash(now)
+	if err != nil {
+		return err
+	}
+	var rec *trashRecord
+	for i := range recs {
+		if recs[i].ID == id {
+			rec = &recs[i]
+			break
+		}
+	}
+	if rec == nil {
+		return fmt.Errorf("回收站中没有批次 %s", id)
+	}
+
+	batchesMu.Lock()
+	if _, exists := batches[id]; exists {
+		batchesMu.Unlock()
+		return fmt.Errorf("批次 %s 已在列表中", id)
+	}
+	b := batchFromRecord(rec.HistoryRecord, now)
+	batches[id] = b
+	logEvent("从回收站恢复批次 %s: %s", id, b.Folder)
+	publishBatchEvent(eventRestored, b)
+	batchesMu.Unlock()
+
+	return removeFromTrash(map[string]bool{id: true})
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrash(t *testing.T) {
+	origBatches, origTrash := batches, trashPath
+	defer func() { batches, trashPath = origBatches, origTrash }()
+	trashPath = filepath.Join(t.TempDir(), "trash.json")
+
+	now := time.Now()
+	a := &Batch{ID: "a", Folder: "/in/a", Status: "signed", Files: []string{"x.mp4"},
+		FileSizes: map[string]int64{"x.mp4": 10}, TotalSize: 10, LastTime: now.Add(-time.Hour),
+		SignOffs: []SignOff{{By: "lee"}}}
+	b := &Batch{ID: "b", Folder: "/in/b", Status: "signed", FileSizes: map[string]int64{}}
+	batches = map[string]*Batch{"a": a, "b": b}
+
+	batchesMu.Lock()
+	trashBatches([]*Batch{a, b}, now)
+	batchesMu.Unlock()
+	if len(batches) != 0 {
+		t.Fatalf("%d batches left after delete, want 0", len(batches))
+	}
+	recs, err := loadTrash(now)
+	if err != nil || len(recs) != 2 {
+		t.Fatalf("Trash has %d records (%v), want 2", len(recs), err)
+	}
+
+	// Undo puts the very same batch back
+	undoDelete([]*Batch{b})
+	if batches["b"] != b {
+		t.Error("Undo should restore batch b")
+	}
+	if recs, _ := loadTrash(now); len(recs) != 1 || recs[0].ID != "a" {
+		t.Errorf("Trash after undo = %+v, want only a", recs)
+	}
+
+	if err := restoreFromTrash("a"); err != nil {
+		t.Fatal(err)
+	}
+	got := batches["a"]
+	if got == nil || got.Status != "signed" || got.FileSizes["x.mp4"] != 10 || len(got.SignOffs) != 1 || !got.LastTime.Equal(a.LastTime) {
+		t.Errorf("Restored batch = %+v", got)
+	}
+	if err := restoreFromTrash("a"); err == nil {
+		t.Error("Restoring twice should fail")
+	}
+	if _, err := os.Stat(trashPath); !os.IsNotExist(err) {
+		t.Error("Empty trash should remove the file")
+	}
+
+	// Deleted batches can only be restored for trashKeepDays
+	batchesMu.Lock()
+	trashBatches([]*Batch{got}, now.AddDate(0, 0, -trashKeepDays-1))
+	batchesMu.Unlock()
+	if recs, _ := loadTrash(now); len(recs) != 0 {
+		t.Errorf("Expired batch still restorable: %+v", recs)
+	}
+	if err := pruneTrash(now); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(trashPath); !os.IsNotExist(err) {
+		t.Error("Pruning should drop expired batches")
+	}
+}
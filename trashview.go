@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// undoToast is the toast currently shown, replaced by the next delete
+var undoToast *widget.PopUp
+
+// showUndoToast announces a delete at the bottom of the window with an
+// "撤销" button, for undoWindow
+func showUndoToast(bs []*Batch, updateUI func(), w fyne.Window) {
+	if len(bs) == 0 {
+		return
+	}
+	if undoToast != nil {
+		undoToast.Hide()
+	}
+	text := "已删除批次 " + displayFolder(bs[0].Folder)
+	if len(bs) > 1 {
+		text = fmt.Sprintf("已删除 %d 个批次", len(bs))
+	}
+	var pop *widget.PopUp
+	undoBtn := widget.NewButton("撤销", func() {
+		pop.Hide()
+		undoDelete(bs)
+		updateUI()
+	})
+	undoBtn.Importance = widget.HighImportance
+	pop = widget.NewPopUp(container.NewHBox(widget.NewLabel(text), undoBtn), w.Canvas())
+	undoToast = pop
+
+	size := w.Canvas().Size()
+	min := pop.MinSize()
+	pop.ShowAtPosition(fyne.NewPos((size.Width-min.Width)/2, size.Height-min.Height-16))
+	time.AfterFunc(undoWindow, func() {
+		fyne.Do(pop.Hide)
+	})
+}
+
+// showTrashDialog lists the deleted batches of the last trashKeepDays days
+// for restoring
+func showTrashDialog(updateUI func(), w fyne.Window) {
+	recs, err := loadTrash(time.Now())
+	if err != nil {
+		dialog.ShowError(err, w)
+		return
+	}
+	empty := widget.NewLabel(fmt.Sprintf("回收站是空的（删除的批次保留 %d 天）", trashKeepDays))
+	list := widget.NewList(
+		func() int { return len(recs) },
+		func() fyne.CanvasObject {
+			l := widget.NewLabel("")
+			l.Truncation = fyne.TextTruncateEllipsis
+			return container.NewBorder(nil, nil, nil, widget.NewButton("♻️ 恢复", nil), l)
+		},
+		nil,
+	)
+	list.UpdateItem = func(i widget.ListItemID, o fyne.CanvasObject) {
+		row := o.(*fyne.Container)
+		rec := recs[i]
+		row.Objects[0].(*widget.Label).SetText(fmt.Sprintf("%s 删除 · %s",
+			formatBatchTime(rec.DeletedAt, time.Now()), historyRecordText(rec.HistoryRecord)))
+		row.Objects[1].(*widget.Button).OnTapped = func() {
+			if err := restoreFromTrash(rec.ID); err != nil {
+				dialog.ShowError(err, w)
+				return
+			}
+			updateUI()
+			recs = append(recs[:i:i], recs[i+1:]...)
+			list.Refresh()
+			empty.Hidden = len(recs) > 0
+			empty.Refresh()
+		}
+	}
+	list.OnSelected = func(i widget.ListItemID) {
+		list.Unselect(i)
+		showHistoryRecordDialog(recs[i].HistoryRecord, w)
+	}
+	empty.Hidden = len(recs) > 0
+
+	content := container.NewBorder(empty, nil, nil, nil, list)
+	d := dialog.NewCustom("♻️ 回收站", "关闭", content, w)
+	d.Resize(fyne.NewSize(560, 420))
+	d.Show()
+}
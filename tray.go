@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/driver/desktop"
+)
+
+// trayStatus mirrors upload activity outside the window: a status line in
+// the system tray menu and the window title, which is what the taskbar and
+// dock show. Fyne has no API for tray tooltips, taskbar progress bars or
+// dock badges, so the count is shown as text.
+type trayStatus struct {
+	desk   desktop.App // nil when the driver has no system tray
+	menu   *fyne.Menu
+	status *fyne.MenuItem
+	mute   *fyne.MenuItem
+	window fyne.Window
+	last   int
+}
+
+// uploadingCount counts batches still receiving files. Caller must hold batchesMu.
+func uploadingCount() int {
+	n := 0
+	for _, b := range batches {
+		if b.Status == "uploading" {
+			n++
+		}
+	}
+	return n
+}
+
+// trayStatusText is the tray menu status line
+func trayStatusText(uploading int) string {
+	if uploading == 0 {
+		return "无上传中的批次"
+	}
+	return fmt.Sprintf("⏫ %d 个批次上传中", uploading)
+}
+
+// windowTitle is the window title for the number of uploading batches
+func windowTitle(uploading int) string {
+	if uploading == 0 {
+		return "FidruaWatch"
+	}
+	return fmt.Sprintf("(%d) FidruaWatch - 上传中", uploading)
+}
+
+// setupTray installs the tray icon and menu when the platform supports it
+func setupTray(a fyne.App, w fyne.Window) *trayStatus {
+	t := &trayStatus{window: w}
+	desk, ok := a.(desktop.App)
+	if !ok {
+		return t
+	}
+	t.desk = desk
+	t.status = fyne.NewMenuItem(trayStatusText(0), nil)
+	t.status.Disabled = true
+	t.mute = fyne.NewMenuItem(muteButtonText(false, time.Time{}), nil)
+	t.menu = fyne.NewMenu("FidruaWatch",
+		t.status,
+		fyne.NewMenuItemSeparator(),
+		t.mute,
+		fyne.NewMenuItem("显示窗口", func() {
+			w.Show()
+			w.RequestFocus()
+		}),
+	)
+	if resourceLogoPng != nil {
+		desk.SetSystemTrayIcon(resourceLogoPng)
+	}
+	desk.SetSystemTrayMenu(t.menu)
+	return t
+}
+
+// Update shows the current number of uploading batches, clearing the
+// indicators when all have completed
+func (t *trayStatus) Update(uploading int) {
+	if uploading == t.last {
+		return
+	}
+	t.last = uploading
+	t.window.SetTitle(windowTitle(uploading))
+	if t.desk != nil {
+		t.status.Label = trayStatusText(uploading)
+		t.desk.SetSystemTrayMenu(t.menu)
+	}
+}
+
+// SetMute shows the mute state in the tray menu; toggle runs when the
+// item is clicked
+func (t *trayStatus) SetMute(label string, toggle func()) {
+	if t.desk == nil {
+		return
+	}
+	t.mute.Label = label
+	t.mute.Action = toggle
+	t.desk.SetSystemTrayMenu(t.menu)
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"fyne.io/fyne/v2/test"
+)
+
+func TestTrayStatusUpdate(t *testing.T) {
+	a := test.NewApp()
+	defer a.Quit()
+	w := a.NewWindow("FidruaWatch")
+	tray := setupTray(a, w)
+
+	tray.Update(2)
+	if got := w.Title(); got != "(2) FidruaWatch - 上传中" {
+		t.Errorf("title = %q", got)
+	}
+	if tray.status != nil && tray.status.Label != "⏫ 2 个批次上传中" {
+		t.Errorf("tray status = %q", tray.status.Label)
+	}
+
+	tray.Update(0)
+	if got := w.Title(); got != "FidruaWatch" {
+		t.Errorf("title not cleared: %q", got)
+	}
+}
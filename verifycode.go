@@ -0,0 +1,46 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// verifyAlphabet is Crockford's base32 alphabet: no I, L, O or U, so the code
+// can be read out over the phone without ambiguity
+const verifyAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// verifyCodeLen is the number of characters in a verification code
+const verifyCodeLen = 6
+
+// batchManifest lists a batch's files as "path<TAB>size" lines, sorted, with
+// paths in NFC and forward slashes so both sides of a delivery agree on it.
+// Caller must hold batchesMu.
+func batchManifest(b *Batch) string {
+	lines := make([]string, 0, len(b.Files))
+	for _, f := range b.Files {
+		name := norm.NFC.String(filepath.ToSlash(f))
+		lines = append(lines, fmt.Sprintf("%s\t%d", name, b.FileSizes[f]))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// verificationCode derives a short code from a batch manifest, e.g. "K7M2QX".
+// The uploader and the receiver can compare it verbally to confirm they are
+// talking about the same, unaltered delivery.
+func verificationCode(manifest string) string {
+	sum := sha256.Sum256([]byte(manifest))
+	code := make([]byte, verifyCodeLen)
+	// 6 characters × 5 bits come from the first 30 bits of the digest
+	bits := uint64(sum[0])<<24 | uint64(sum[1])<<16 | uint64(sum[2])<<8 | uint64(sum[3])
+	for i := range code {
+		shift := uint(32 - 5*(i+1))
+		code[i] = verifyAlphabet[(bits>>shift)&31]
+	}
+	return string(code)
+}
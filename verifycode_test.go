@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBatchManifestOrderIndependent(t *testing.T) {
+	a := &Batch{
+		Files:     []string{"b.mp4", "sub/a.jpg"},
+		FileSizes: map[string]int64{"b.mp4": 200, "sub/a.jpg": 100},
+	}
+	b := &Batch{
+		Files:     []string{"sub/a.jpg", "b.mp4"},
+		FileSizes: map[string]int64{"b.mp4": 200, "sub/a.jpg": 100},
+	}
+	if batchManifest(a) != batchManifest(b) {
+		t.Errorf("manifest depends on file order:\n%q\n%q", batchManifest(a), batchManifest(b))
+	}
+	if want := "b.mp4\t200\nsub/a.jpg\t100"; batchManifest(a) != want {
+		t.Errorf("batchManifest = %q, want %q", batchManifest(a), want)
+	}
+}
+
+func TestVerificationCode(t *testing.T) {
+	code := verificationCode("b.mp4\t200\nsub/a.jpg\t100")
+	if len(code) != verifyCodeLen {
+		t.Fatalf("code %q has length %d, want %d", code, len(code), verifyCodeLen)
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(verifyAlphabet, c) {
+			t.Errorf("code %q contains %q outside the alphabet", code, c)
+		}
+	}
+	if again := verificationCode("b.mp4\t200\nsub/a.jpg\t100"); again != code {
+		t.Errorf("code not stable: %q vs %q", code, again)
+	}
+	// A changed size must change the code
+	if altered := verificationCode("b.mp4\t201\nsub/a.jpg\t100"); altered == code {
+		t.Errorf("altered manifest produced the same code %q", code)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// volumeInterval is how often mounted volumes are listed to notice a card
+// or drive being plugged in or ejected
+const volumeInterval = 5 * time.Second
+
+// volumeInfo is a mounted volume
+type volumeInfo struct {
+	Mount string // mount point or drive root, e.g. /media/me/CARD or E:\
+	Label string
+	UUID  string // filesystem UUID; the volume serial number on Windows, e.g. 1A2B-3C4D
+}
+
+// findVolume returns the volume whose label or UUID is want, ignoring case
+func findVolume(vols []volumeInfo, want string) (volumeInfo, bool) {
+	want = strings.TrimSpace(want)
+	if want == "" {
+		return volumeInfo{}, false
+	}
+	for _, v := range vols {
+		if strings.EqualFold(v.Label, want) || v.UUID != "" && strings.EqualFold(v.UUID, want) {
+			return v, true
+		}
+	}
+	return volumeInfo{}, false
+}
+
+// volumeWatchPath is the folder watched on a volume: config.VolumeSubdir
+// below its root, e.g. the DCIM folder of a camera card, or the root when
+// the volume has no such folder
+func volumeWatchPath(v volumeInfo) string {
+	if sub := strings.Trim(config.VolumeSubdir, `/\`); sub != "" {
+		dir := filepath.Join(v.Mount, filepath.FromSlash(sub))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return v.Mount
+}
+
+// currentVolume is the watched volume while it is plugged in, nil otherwise
+var currentVolume atomic.Pointer[volumeInfo]
+
+// volumeEvent reports the watched volume being plugged in or ejected
+type volumeEvent struct {
+	Volume  volumeInfo
+	Path    string // folder to watch, set when Present
+	Present bool
+}
+
+// runVolumeWatch lists the mounted volumes every volumeInterval while
+// config.VolumeWatch is on and calls apply when the volume named by
+// config.VolumeID appears or goes away. Like the schedule, it only acts on
+// changes, so monitoring started or stopped by hand stays that way until
+// the card is plugged in or ejected again.
+func runVolumeWatch(ctx context.Context, apply func(volumeEvent)) {
+	var present bool
+	var last volumeInfo
+	check := func() {
+		if !config.VolumeWatch {
+			present = false
+			currentVolume.Store(nil)
+			return
+		}
+		v, ok := findVolume(listVolumes(ctx), config.VolumeID)
+		switch {
+		case ok && (!present || v.Mount != last.Mount):
+			present, last = true, v
+			currentVolume.Store(&v)
+			apply(volumeEvent{Volume: v, Path: volumeWatchPath(v), Present: true})
+		case !ok && present:
+			present = false
+			currentVolume.Store(nil)
+			apply(volumeEvent{Volume: last})
+		}
+	}
+
+	check()
+	ticker := time.NewTicker(volumeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
+
+// volumeName is how a volume is named in the UI
+func volumeName(v volumeInfo) string {
+	if v.Label != "" {
+		return v.Label
+	}
+	return displayWindowsPath(v.Mount)
+}
+
+// mountEntry is a line of /proc/self/mounts
+type mountEntry struct {
+	Device string
+	Dir    string
+}
+
+// parseMounts reads /proc/self/mounts, where spaces and other special
+// characters in paths are written as octal escapes like \040
+func parseMounts(data string) []mountEntry {
+	var out []mountEntry
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "/dev/") {
+			continue
+		}
+		out = append(out, mountEntry{Device: unescapeOctal(fields[0]), Dir: unescapeOctal(fields[1])})
+	}
+	return out
+}
+
+// unescapeOctal decodes the \ooo escapes of /proc/self/mounts
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// unescapeUdev decodes the \xHH escapes udev uses in /dev/disk/by-label
+// names, e.g. "EOS\x20DIGITAL"
+func unescapeUdev(s string) string {
+	if !strings.Contains(s, `\x`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) && s[i+1] == 'x' {
+			if n, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// diskutilUUID extracts the volume UUID from `diskutil info` output
+func diskutilUUID(out string) string {
+	for _, line := range strings.Split(out, "\n") {
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), ":"); ok && strings.TrimSpace(k) == "Volume UUID" {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
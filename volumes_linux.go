@@ -0,0 +1,66 @@
+//go:build linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// listVolumes returns the mounted block devices with the labels and UUIDs
+// udev links in /dev/disk
+func listVolumes(ctx context.Context) []volumeInfo {
+	data, err := os.ReadFile("/proc/self/mounts")
+	if err != nil {
+		return nil
+	}
+	labels := diskLinks("/dev/disk/by-label")
+	uuids := diskLinks("/dev/disk/by-uuid")
+	var vols []volumeInfo
+	seen := make(map[string]bool)
+	for _, m := range parseMounts(string(data)) {
+		dev, err := filepath.EvalSymlinks(m.Device)
+		if err != nil || seen[dev] {
+			continue
+		}
+		seen[dev] = true
+		vols = append(vols, volumeInfo{Mount: m.Dir, Label: labels[dev], UUID: uuids[dev]})
+	}
+	return vols
+}
+
+// diskLinks maps the devices linked from a /dev/disk directory to the
+// decoded link names
+func diskLinks(dir string) map[string]string {
+	links := make(map[string]string)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return links
+	}
+	for _, e := range entries {
+		dev, err := filepath.EvalSymlinks(filepath.Join(dir, e.Name()))
+		if err == nil {
+			links[dev] = unescapeUdev(e.Name())
+		}
+	}
+	return links
+}
+
+// ejectVolume unmounts a volume through GIO like the file manager does,
+// falling back to umount
+func ejectVolume(ctx context.Context, mount string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if err := exec.CommandContext(ctx, "gio", "mount", "-e", mount).Run(); err == nil {
+		return nil
+	}
+	if out, err := exec.CommandContext(ctx, "umount", mount).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
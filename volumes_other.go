@@ -0,0 +1,63 @@
+//go:build !windows && !linux
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// volumeUUIDs caches the UUIDs from diskutil by mount point, so listing
+// the volumes every few seconds doesn't run it for each
+var volumeUUIDs sync.Map
+
+// listVolumes returns the volumes mounted in /Volumes, named after their
+// labels; the UUID comes from diskutil
+func listVolumes(ctx context.Context) []volumeInfo {
+	entries, err := os.ReadDir("/Volumes")
+	if err != nil {
+		return nil
+	}
+	var vols []volumeInfo
+	for _, e := range entries {
+		mount := filepath.Join("/Volumes", e.Name())
+		v := volumeInfo{Mount: mount, Label: e.Name()}
+		if id, ok := volumeUUIDs.Load(mount); ok {
+			v.UUID = id.(string)
+		} else {
+			tctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			out, err := exec.CommandContext(tctx, "diskutil", "info", mount).Output()
+			cancel()
+			if err == nil {
+				v.UUID = diskutilUUID(string(out))
+				volumeUUIDs.Store(mount, v.UUID)
+			}
+		}
+		vols = append(vols, v)
+	}
+	// Forget volumes that are gone; a card mounted at the same path later
+	// may be another one
+	volumeUUIDs.Range(func(k, _ interface{}) bool {
+		if _, err := os.Stat(k.(string)); err != nil {
+			volumeUUIDs.Delete(k)
+		}
+		return true
+	})
+	return vols
+}
+
+// ejectVolume ejects a volume with diskutil
+func ejectVolume(ctx context.Context, mount string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	if out, err := exec.CommandContext(ctx, "diskutil", "eject", mount).CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
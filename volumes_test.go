@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFindVolume(t *testing.T) {
+	vols := []volumeInfo{
+		{Mount: "/", UUID: "0a1b"},
+		{Mount: "/media/me/EOS_DIGITAL", Label: "EOS_DIGITAL", UUID: "3C4D-5E6F"},
+	}
+	for _, want := range []string{"eos_digital", "3c4d-5e6f", " EOS_DIGITAL "} {
+		if v, ok := findVolume(vols, want); !ok || v.Mount != "/media/me/EOS_DIGITAL" {
+			t.Errorf("findVolume(%q) = %+v, %v", want, v, ok)
+		}
+	}
+	if _, ok := findVolume(vols, ""); ok {
+		t.Error("empty ID matched a volume without a label")
+	}
+}
+
+func TestVolumeWatchPath(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.VolumeSubdir = "DCIM"
+
+	card := t.TempDir()
+	if got := volumeWatchPath(volumeInfo{Mount: card}); got != card {
+		t.Errorf("without DCIM: %q, want the root", got)
+	}
+	os.Mkdir(filepath.Join(card, "DCIM"), 0755)
+	if got := volumeWatchPath(volumeInfo{Mount: card}); got != filepath.Join(card, "DCIM") {
+		t.Errorf("with DCIM: %q", got)
+	}
+}
+
+func TestParseVolumeListings(t *testing.T) {
+	mounts := parseMounts("proc /proc proc rw 0 0\n/dev/sdb1 /media/me/EOS\\040DIGITAL vfat rw 0 0\n")
+	if len(mounts) != 1 || mounts[0].Device != "/dev/sdb1" || mounts[0].Dir != "/media/me/EOS DIGITAL" {
+		t.Errorf("parseMounts = %+v", mounts)
+	}
+	if got := unescapeUdev(`EOS\x20DIGITAL`); got != "EOS DIGITAL" {
+		t.Errorf("unescapeUdev = %q", got)
+	}
+	out := "   Volume Name:              CARD\n   Volume UUID:              1D2E3F40-1111-2222-3333-444455556666\n"
+	if got := diskutilUUID(out); got != "1D2E3F40-1111-2222-3333-444455556666" {
+		t.Errorf("diskutilUUID = %q", got)
+	}
+}
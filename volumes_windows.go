@@ -0,0 +1,62 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+// listVolumes returns the drives with a volume in them, named by their
+// labels and volume serial numbers
+func listVolumes(ctx context.Context) []volumeInfo {
+	// An empty card reader slot must not raise a "no disk" dialog
+	old := windows.SetErrorMode(windows.SEM_FAILCRITICALERRORS)
+	defer windows.SetErrorMode(old)
+
+	mask, err := windows.GetLogicalDrives()
+	if err != nil {
+		return nil
+	}
+	var vols []volumeInfo
+	for i := 2; i < 26; i++ { // skip A: and B:
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		root := string(rune('A'+i)) + `:\`
+		rootPtr, _ := windows.UTF16PtrFromString(root)
+		label := make([]uint16, windows.MAX_PATH+1)
+		var serial uint32
+		if err := windows.GetVolumeInformation(rootPtr, &label[0], uint32(len(label)), &serial, nil, nil, nil, 0); err != nil {
+			continue
+		}
+		vols = append(vols, volumeInfo{
+			Mount: root,
+			Label: windows.UTF16ToString(label),
+			UUID:  fmt.Sprintf("%04X-%04X", serial>>16, serial&0xFFFF),
+		})
+	}
+	return vols
+}
+
+// ejectScript ejects a drive through the shell, like "Eject" in Explorer
+const ejectScript = `(New-Object -ComObject Shell.Application).Namespace(17).ParseName('%s').InvokeVerb('Eject')`
+
+// ejectVolume ejects a drive given by its root, e.g. E:\
+func ejectVolume(ctx context.Context, mount string) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	drive := strings.TrimRight(mount, `\`)
+	cmd := exec.CommandContext(ctx, "powershell.exe", "-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(ejectScript, drive))
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
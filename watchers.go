@@ -0,0 +1,552 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// State of a watch root as shown in the UI
+const (
+	rootWatching = "watching" // every directory below the root is watched
+	rootDegraded = "degraded" // some directories could not be added
+	rootFailed   = "failed"   // the root itself can't be watched, retried periodically
+)
+
+// rootRetryInterval is how often failed roots are watched again
+var rootRetryInterval = 15 * time.Second
+
+// errRootRemoved marks a root that disappeared while being watched, e.g. an
+// unmounted share
+var errRootRemoved = errors.New("监控文件夹已不存在")
+
+// errRootReplaced marks a root whose path now leads to another folder, e.g.
+// the empty mount point left by an unmounted NAS share
+var errRootReplaced = errors.New("监控文件夹已被卸载或替换")
+
+// RootStatus describes one watch root for the UI
+type RootStatus struct {
+	Root    string
+	State   string
+	Watched int       // directories added to the watcher
+	Failed  int       // directories the watcher refused
+	Err     error     // why the root failed, nil unless State is rootFailed
+	Since   time.Time // when the root was lost while watched, zero otherwise
+}
+
+// rootOutage reports a root lost while watched, or re-attached after that
+type rootOutage struct {
+	Root string
+	Err  error         // why it was lost
+	Down time.Duration // how long it was lost, set when re-attached
+	Back bool
+}
+
+// fsWatcher is the event source of one root: fsnotify, or a platform
+// backend that watches a whole tree with a single Add
+type fsWatcher interface {
+	Add(dir string) error
+	Close() error
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+}
+
+// fsnotifyWatcher adapts fsnotify, which needs an Add per directory
+type fsnotifyWatcher struct{ *fsnotify.Watcher }
+
+func (w fsnotifyWatcher) Events() <-chan fsnotify.Event { return w.Watcher.Events }
+func (w fsnotifyWatcher) Errors() <-chan error          { return w.Watcher.Errors }
+
+// newFSWatcher returns a watcher for one root. With recursive set, the
+// platform's recursive backend is used where there is one; the bool reports
+// whether it was, i.e. whether adding the root covers its subdirectories.
+func newFSWatcher(recursive bool) (fsWatcher, bool, error) {
+	if recursive {
+		if w := newRecursiveWatcher(); w != nil {
+			return w, true, nil
+		}
+	}
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, false, err
+	}
+	return fsnotifyWatcher{w}, false, nil
+}
+
+// rootWatch is the watcher of one root. A root failing or being
+// removed only affects its own watcher; the supervisor retries it while the
+// other roots keep running.
+type rootWatch struct {
+	root      string
+	watcher   fsWatcher       // nil while failed
+	recursive bool            // watcher covers the whole tree, see newFSWatcher
+	added     map[string]bool // directories successfully added
+	failed    []string        // directories the watcher refused
+	err       error
+	info      os.FileInfo // the root folder when last opened, to notice a remount
+	down      time.Time   // when the root was lost while watched, zero otherwise
+}
+
+// watchManager owns the watchers of all roots of a monitoring session.
+// Its mutex also guards the poller.
+type watchManager struct {
+	mu     sync.Mutex
+	roots  []*rootWatch
+	events chan fsnotify.Event
+	errs   chan error
+}
+
+var watchers = &watchManager{}
+
+// Start replaces the current watchers with one per root. Only the roots
+// themselves are added; Scan adds their subdirectories. It fails only if no
+// root could be watched at all; the others are retried by Run.
+func (m *watchManager) Start(roots ...string) error {
+	m.Close()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.events = make(chan fsnotify.Event, 64)
+	m.errs = make(chan error, 8)
+	poller = nil
+	var firstErr error
+	opened := 0
+	for _, root := range roots {
+		rw := &rootWatch{root: root}
+		if err := rw.open(); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else {
+			opened++
+		}
+		m.roots = append(m.roots, rw)
+	}
+	if opened == 0 && firstErr != nil {
+		m.roots = nil
+		return firstErr
+	}
+	return nil
+}
+
+// open creates the watcher of a root and adds the root directory
+func (rw *rootWatch) open() error {
+	w, recursive, err := newFSWatcher(config.MonitorSubdirs)
+	if err != nil {
+		rw.err = err
+		return err
+	}
+	if err := w.Add(rw.root); err != nil {
+		w.Close()
+		rw.added, rw.failed, rw.err = nil, nil, err
+		return err
+	}
+	if info, err := os.Stat(rw.root); err == nil {
+		rw.info = info
+	}
+	rw.watcher = w
+	rw.recursive = recursive
+	rw.added = map[string]bool{rw.root: true}
+	rw.failed = nil
+	rw.err = nil
+	return nil
+}
+
+// scanProgressEvery is how many added directories pass between progress
+// reports of Scan
+const scanProgressEvery = 100
+
+// scanDialogDelay is how long the startup scan runs before its progress is
+// shown
+const scanDialogDelay = time.Second
+
+// Scan adds the subdirectories of every root that needs them added one by
+// one, reporting the number of watched directories to progress along the
+// way. Directories already added are watched while the scan continues.
+// It stops early with ctx.Err() when ctx is cancelled.
+func (m *watchManager) Scan(ctx context.Context, progress func(dirs int)) error {
+	if !config.MonitorSubdirs {
+		return nil
+	}
+	m.mu.Lock()
+	roots := append([]*rootWatch(nil), m.roots...)
+	m.mu.Unlock()
+	total := 0
+	for _, rw := range roots {
+		n, err := m.scanRoot(ctx, rw, func(n int) {
+			if progress != nil {
+				progress(total + n)
+			}
+		})
+		total += n
+		if err != nil {
+			return err
+		}
+	}
+	if progress != nil {
+		progress(total)
+	}
+	return nil
+}
+
+// scanRoot walks one root, adding each directory to its watcher. It stops
+// when ctx is cancelled or the root's watcher was replaced, and returns the
+// number of directories watched.
+func (m *watchManager) scanRoot(ctx context.Context, rw *rootWatch, progress func(dirs int)) (int, error) {
+	m.mu.Lock()
+	w := rw.watcher
+	skip := w == nil || rw.recursive
+	count := len(rw.added)
+	m.mu.Unlock()
+	if skip {
+		return count, nil
+	}
+	err := filepath.WalkDir(rw.root, func(p string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		m.mu.Lock()
+		if rw.watcher != w {
+			m.mu.Unlock()
+			return filepath.SkipAll
+		}
+		added := !rw.added[p] && rw.addDir(w, p)
+		count = len(rw.added)
+		m.mu.Unlock()
+		if added && count%scanProgressEvery == 0 && progress != nil {
+			progress(count)
+		}
+		return nil
+	})
+	return count, err
+}
+
+// addDir adds dir to w, remembering whether it worked
+func (rw *rootWatch) addDir(w fsWatcher, dir string) bool {
+	// Typically ENOSPC once fs.inotify.max_user_watches is exhausted
+	if err := w.Add(dir); err != nil {
+		rw.failed = append(rw.failed, dir)
+		return false
+	}
+	rw.added[dir] = true
+	return true
+}
+
+// close stops the watcher of a root
+func (rw *rootWatch) close() {
+	if rw.watcher != nil {
+		rw.watcher.Close()
+		rw.watcher = nil
+	}
+	rw.added, rw.failed = nil, nil
+}
+
+func (rw *rootWatch) state() string {
+	switch {
+	case rw.watcher == nil:
+		return rootFailed
+	case len(rw.failed) > 0:
+		return rootDegraded
+	}
+	return rootWatching
+}
+
+// rootFor returns the root dir belongs to. Caller must hold m.mu.
+func (m *watchManager) rootFor(dir string) *rootWatch {
+	var best *rootWatch
+	for _, rw := range m.roots {
+		rel, err := filepath.Rel(rw.root, dir)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best == nil || len(rw.root) > len(best.root) {
+			best = rw
+		}
+	}
+	return best
+}
+
+// check reports why a watched root can't be used any more, nil if it can.
+// Unmounting a share or renaming the folder raises no event on the root
+// itself on every platform, so the supervisor checks periodically.
+func (rw *rootWatch) check() error {
+	info, err := os.Stat(rw.root)
+	switch {
+	case os.IsNotExist(err):
+		return errRootRemoved
+	case err != nil:
+		return err
+	case !info.IsDir():
+		return errRootRemoved
+	case rw.info != nil && !os.SameFile(rw.info, info):
+		return errRootReplaced
+	}
+	return nil
+}
+
+// reattachable reports whether the supervisor may re-open a failed root.
+// Roots that failed at start are always retried. A root lost while watched
+// is only re-attached with config.ReattachRoots, and not to an empty folder
+// in its place, which is taken for a bare mount point.
+func (rw *rootWatch) reattachable() bool {
+	if rw.down.IsZero() {
+		return true
+	}
+	if !config.ReattachRoots {
+		return false
+	}
+	info, err := os.Stat(rw.root)
+	if err != nil || !info.IsDir() {
+		return false
+	}
+	if rw.info == nil || os.SameFile(rw.info, info) {
+		return true
+	}
+	entries, err := os.ReadDir(rw.root)
+	return err == nil && len(entries) > 0
+}
+
+// AddDir watches a directory created below a root. Directories the watcher
+// refuses are polled instead if polling was started.
+func (m *watchManager) AddDir(dir string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rw := m.rootFor(dir)
+	if rw == nil || rw.watcher == nil || rw.recursive || rw.added[dir] {
+		return
+	}
+	if !rw.addDir(rw.watcher, dir) && poller != nil {
+		poller.AddDir(dir, false)
+	}
+}
+
+// Run forwards the events and errors of every root until ctx is cancelled,
+// and re-opens failed roots every rootRetryInterval. onChange is called when
+// a root fails or recovers, onOutage, if not nil, when a watched root is
+// lost or re-attached. Both channels are nil if Start was not called.
+func (m *watchManager) Run(ctx context.Context, onChange func(), onOutage func(rootOutage)) (<-chan fsnotify.Event, <-chan error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.events == nil {
+		return nil, nil
+	}
+	events, errs := m.events, m.errs
+	for _, rw := range m.roots {
+		if rw.watcher != nil {
+			go m.forward(ctx, rw, rw.watcher, events, errs, onChange, onOutage)
+		}
+	}
+	go m.supervise(ctx, events, errs, onChange, onOutage)
+	return events, errs
+}
+
+// forward passes on the events of one watcher until it is closed. Removal
+// of the root itself fails the root.
+func (m *watchManager) forward(ctx context.Context, rw *rootWatch, w fsWatcher, events chan<- fsnotify.Event, errs chan<- error, onChange func(), onOutage func(rootOutage)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-w.Events():
+			if !ok {
+				return
+			}
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 && filepath.Clean(event.Name) == filepath.Clean(rw.root) {
+				if m.fail(rw, w, errRootRemoved) && onOutage != nil {
+					onOutage(rootOutage{Root: rw.root, Err: errRootRemoved})
+				}
+				onChange()
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+		case err, ok := <-w.Errors():
+			if !ok {
+				return
+			}
+			select {
+			case errs <- err:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// fail closes the watcher w of a root unless it was replaced meanwhile,
+// reporting whether it did
+func (m *watchManager) fail(rw *rootWatch, w fsWatcher, err error) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if rw.watcher != w {
+		return false
+	}
+	rw.lose(err, time.Now())
+	return true
+}
+
+// lose closes the watcher of a root lost while watched. Caller must hold
+// m.mu.
+func (rw *rootWatch) lose(err error, now time.Time) {
+	rw.close()
+	rw.err = err
+	rw.down = now
+	if config.ReattachRoots {
+		logEvent("监控失败 %s: %v, 稍后重试", rw.root, err)
+	} else {
+		logEvent("监控失败 %s: %v", rw.root, err)
+	}
+}
+
+// supervise checks the watched roots and re-opens failed ones until ctx is
+// cancelled. Files that arrived while a root was down are picked up by a
+// rescan.
+func (m *watchManager) supervise(ctx context.Context, events chan<- fsnotify.Event, errs chan<- error, onChange func(), onOutage func(rootOutage)) {
+	ticker := time.NewTicker(rootRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var outages []rootOutage
+		changed, recovered := false, false
+		now := time.Now()
+		m.mu.Lock()
+		for _, rw := range m.roots {
+			if rw.watcher != nil {
+				if err := rw.check(); err != nil {
+					rw.lose(err, now)
+					outages = append(outages, rootOutage{Root: rw.root, Err: err})
+					changed = true
+				}
+				continue
+			}
+			if !rw.reattachable() || rw.open() != nil {
+				continue
+			}
+			logEvent("已恢复监控: %s", rw.root)
+			if !rw.down.IsZero() {
+				outages = append(outages, rootOutage{Root: rw.root, Down: now.Sub(rw.down), Back: true})
+				rw.down = time.Time{}
+			}
+			go m.forward(ctx, rw, rw.watcher, events, errs, onChange, onOutage)
+			go m.scanRoot(ctx, rw, nil)
+			changed, recovered = true, true
+		}
+		m.mu.Unlock()
+		if onOutage != nil {
+			for _, o := range outages {
+				onOutage(o)
+			}
+		}
+		if recovered {
+			requestRescan()
+		}
+		if changed {
+			onChange()
+		}
+	}
+}
+
+// Close stops every watcher
+func (m *watchManager) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, rw := range m.roots {
+		rw.close()
+	}
+	m.roots = nil
+}
+
+// QueueDepth returns the events waiting for the event loop
+func (m *watchManager) QueueDepth() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.events)
+}
+
+// Statuses returns the state of every root
+func (m *watchManager) Statuses() []RootStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]RootStatus, len(m.roots))
+	for i, rw := range m.roots {
+		out[i] = RootStatus{
+			Root:    rw.root,
+			State:   rw.state(),
+			Watched: len(rw.added),
+			Failed:  len(rw.failed),
+		}
+		if out[i].State == rootFailed {
+			out[i].Err = rw.err
+			out[i].Since = rw.down
+		}
+	}
+	return out
+}
+
+// watchedDirCount returns how many directories are watched over all roots
+func watchedDirCount() int {
+	n := 0
+	for _, s := range watchers.Statuses() {
+		n += s.Watched
+	}
+	return n
+}
+
+// FailedDirs returns the directories the watchers refused, in order
+func (m *watchManager) FailedDirs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var dirs []string
+	for _, rw := range m.roots {
+		dirs = append(dirs, rw.failed...)
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// watchStatusText summarizes the roots that are not fully watched for the
+// status line, empty when everything is watched
+func watchStatusText(statuses []RootStatus) string {
+	var parts []string
+	for _, s := range statuses {
+		name := filepath.Base(s.Root)
+		switch s.State {
+		case rootDegraded:
+			parts = append(parts, fmt.Sprintf("⚠️ %s: %d 个文件夹未监控", name, s.Failed))
+		case rootFailed:
+			parts = append(parts, rootFailedText(name, s))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// rootFailedText describes a failed root for the status line. A root lost
+// while watched shows why and since when, so an unmounted share stands out.
+func rootFailedText(name string, s RootStatus) string {
+	if s.Since.IsZero() {
+		return "❌ " + name + ": 监控失败, 重试中"
+	}
+	text := fmt.Sprintf("❌ %s: %v (自 %s)", name, s.Err, formatClock(s.Since, false))
+	if config.ReattachRoots {
+		return text + ", 恢复后自动重新监控"
+	}
+	return text + ", 请检查后重新开始监控"
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchManagerIsolatesRoots(t *testing.T) {
+	good := t.TempDir()
+	missing := filepath.Join(t.TempDir(), "not-yet")
+
+	origConfig := config
+	origRetry := rootRetryInterval
+	defer func() {
+		config = origConfig
+		rootRetryInterval = origRetry
+	}()
+	config = Config{}
+	rootRetryInterval = 50 * time.Millisecond
+
+	m := &watchManager{}
+	defer m.Close()
+	if err := m.Start(good, missing); err != nil {
+		t.Fatalf("Start failed although one root is watchable: %v", err)
+	}
+	statuses := m.Statuses()
+	if len(statuses) != 2 || statuses[0].State != rootWatching || statuses[1].State != rootFailed {
+		t.Fatalf("Statuses = %+v, want watching and failed", statuses)
+	}
+	if statuses[1].Err == nil {
+		t.Error("Expected the failed root to carry its error")
+	}
+	if text := watchStatusText(statuses); !strings.Contains(text, "not-yet") || strings.Contains(text, filepath.Base(good)) {
+		t.Errorf("watchStatusText = %q, want only the failed root", text)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changed := make(chan struct{}, 4)
+	events, _ := m.Run(ctx, func() { changed <- struct{}{} }, nil)
+
+	os.WriteFile(filepath.Join(good, "a.mp4"), []byte("x"), 0644)
+	select {
+	case ev := <-events:
+		if filepath.Base(ev.Name) != "a.mp4" {
+			t.Errorf("Unexpected event %v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("No event from the healthy root")
+	}
+
+	os.Mkdir(missing, 0755)
+	select {
+	case <-changed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Failed root was not recovered")
+	}
+	for _, s := range m.Statuses() {
+		if s.State != rootWatching {
+			t.Errorf("Root %s is %s after recovery", s.Root, s.State)
+		}
+	}
+}
+
+func TestWatchManagerAllRootsFailed(t *testing.T) {
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{MonitorSubdirs: true}
+
+	m := &watchManager{}
+	defer m.Close()
+	if err := m.Start(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expected an error when no root can be watched")
+	}
+	if len(m.Statuses()) != 0 {
+		t.Errorf("Statuses = %+v after a failed start", m.Statuses())
+	}
+}
+
+func TestWatchManagerScan(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 3; i++ {
+		os.MkdirAll(filepath.Join(root, fmt.Sprintf("job%d", i), "raw"), 0755)
+	}
+
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{MonitorSubdirs: true}
+
+	m := &watchManager{}
+	defer m.Close()
+	if err := m.Start(root); err != nil {
+		t.Fatal(err)
+	}
+	if s := m.Statuses(); s[0].Watched != 1 && !m.roots[0].recursive {
+		t.Errorf("Start watched %d folders, want only the root", s[0].Watched)
+	}
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.Scan(cancelled, nil); err == nil && !m.roots[0].recursive {
+		t.Error("Expected a cancelled scan to report it")
+	}
+
+	last := 0
+	if err := m.Scan(context.Background(), func(dirs int) { last = dirs }); err != nil {
+		t.Fatal(err)
+	}
+	if !m.roots[0].recursive && last != 7 {
+		t.Errorf("Scan reported %d folders, want 7", last)
+	}
+}
+
+func TestWatchManagerReattachesLostRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "share")
+	os.Mkdir(root, 0755)
+
+	origConfig := config
+	origRetry := rootRetryInterval
+	defer func() {
+		config = origConfig
+		rootRetryInterval = origRetry
+	}()
+	config = Config{ReattachRoots: true}
+	rootRetryInterval = 50 * time.Millisecond
+
+	m := &watchManager{}
+	defer m.Close()
+	if err := m.Start(root); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	outages := make(chan rootOutage, 4)
+	m.Run(ctx, func() {}, func(o rootOutage) { outages <- o })
+
+	// Like an unmounted share: the path now leads to an empty folder
+	if err := os.Rename(root, filepath.Join(parent, "share.old")); err != nil {
+		t.Fatal(err)
+	}
+	os.Mkdir(root, 0755)
+	select {
+	case o := <-outages:
+		if o.Back || o.Root != root {
+			t.Fatalf("outage = %+v, want the root lost", o)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("lost root not noticed")
+	}
+	s := m.Statuses()[0]
+	if s.State != rootFailed || s.Since.IsZero() || !strings.Contains(watchStatusText(m.Statuses()), "恢复后自动重新监控") {
+		t.Errorf("status = %+v, %q", s, watchStatusText(m.Statuses()))
+	}
+
+	// The empty folder in its place is not taken for the share
+	time.Sleep(4 * rootRetryInterval)
+	if m.Statuses()[0].State != rootFailed {
+		t.Fatal("re-attached to an empty mount point")
+	}
+	os.Remove(root)
+	os.Rename(filepath.Join(parent, "share.old"), root)
+	select {
+	case o := <-outages:
+		if !o.Back || o.Down <= 0 {
+			t.Errorf("outage = %+v, want the root back", o)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("root not re-attached")
+	}
+	if s := m.Statuses()[0]; s.State != rootWatching {
+		t.Errorf("root is %s after re-attaching", s.State)
+	}
+}
+
+func TestWatchManagerKeepsLostRootWithoutReattach(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "share")
+	os.Mkdir(root, 0755)
+	rw := &rootWatch{root: root}
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{}
+	if err := rw.open(); err != nil {
+		t.Fatal(err)
+	}
+	rw.lose(errRootRemoved, time.Now())
+	if rw.reattachable() {
+		t.Error("lost root re-attached with the option off")
+	}
+	config.ReattachRoots = true
+	if !rw.reattachable() {
+		t.Error("same folder not re-attached")
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// webdavPropfind asks only for what the remote watch compares
+const webdavPropfind = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getetag/></d:prop></d:propfind>`
+
+// webdavClient is used for all PROPFIND requests
+var webdavClient = &http.Client{Timeout: 30 * time.Second}
+
+// webdavWatchPath names a WebDAV folder as a watch path, e.g.
+// "webdav:cloud.example.com/remote.php/dav/files/me/uploads". The URL itself
+// is kept in the config; the watch path only has to identify it and survive
+// filepath.Clean, which a "https://" prefix would not.
+func webdavWatchPath(rawURL string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return "", err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("不是有效的 WebDAV 地址: %s", rawURL)
+	}
+	return webdavScheme + u.Host + path.Clean("/"+u.Path), nil
+}
+
+// webdavLister lists a WebDAV collection with Depth: 1 PROPFIND requests,
+// one per folder, since many servers refuse Depth: infinity
+type webdavLister struct {
+	URL      string
+	User     string
+	Password string
+}
+
+// davMultistatus is the part of a 207 Multi-Status response we read
+type davMultistatus struct {
+	Responses []struct {
+		Href      string `xml:"DAV: href"`
+		Propstats []struct {
+			Status string `xml:"DAV: status"`
+			Prop   struct {
+				Collection *struct{} `xml:"DAV: resourcetype>collection"`
+				Length     string    `xml:"DAV: getcontentlength"`
+				ETag       string    `xml:"DAV: getetag"`
+			} `xml:"DAV: prop"`
+		} `xml:"DAV: propstat"`
+	} `xml:"DAV: response"`
+}
+
+// List returns the files below URL, keyed by slash-separated path relative
+// to it. Subfolders are listed when subfolder monitoring is enabled.
+func (l webdavLister) List(ctx context.Context) (map[string]remoteEntry, error) {
+	base, err := url.Parse(strings.TrimSpace(l.URL))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]remoteEntry)
+	level := []string{""}
+	for depth := 0; len(level) > 0 && depth < maxRemoteDepth; depth++ {
+		var next []string
+		for _, dir := range level {
+			found, dirs, err := l.propfind(ctx, base, dir)
+			if err != nil {
+				if dir == "" {
+					return nil, err
+				}
+				continue // vanished between listings
+			}
+			for name, e := range found {
+				files[name] = e
+			}
+			next = append(next, dirs...)
+		}
+		if !config.MonitorSubdirs {
+			break
+		}
+		level = next
+	}
+	return files, nil
+}
+
+// propfind lists one folder, dir being relative to base
+func (l webdavLister) propfind(ctx context.Context, base *url.URL, dir string) (map[string]remoteEntry, []string, error) {
+	u := *base
+	u.Path = strings.TrimSuffix(path.Join("/", base.Path, dir), "/") + "/"
+	u.RawPath = ""
+	req, err := http.NewRequestWithContext(ctx, "PROPFIND", u.String(), strings.NewReader(webdavPropfind))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+	if l.User != "" {
+		req.SetBasicAuth(l.User, l.Password)
+	}
+	resp, err := webdavClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, nil, fmt.Errorf("PROPFIND %s: %s", u.Path, resp.Status)
+	}
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, nil, fmt.Errorf("PROPFIND %s: %w", u.Path, err)
+	}
+	files, dirs := parseDAVResponses(ms, base.Path)
+	return files, dirs, nil
+}
+
+// parseDAVResponses turns a multistatus response into files and subfolders
+// relative to basePath. The listed folder itself is skipped, as are
+// properties the server reported with a non-200 status.
+func parseDAVResponses(ms davMultistatus, basePath string) (map[string]remoteEntry, []string) {
+	root := strings.TrimSuffix(path.Clean("/"+basePath), "/") + "/"
+	files := make(map[string]remoteEntry)
+	var dirs []string
+	for _, r := range ms.Responses {
+		href, err := url.Parse(strings.TrimSpace(r.Href))
+		if err != nil {
+			continue
+		}
+		p := path.Clean("/" + href.Path)
+		if !strings.HasPrefix(p+"/", root) {
+			continue
+		}
+		rel := strings.TrimPrefix(p, root)
+		if rel == "" || p+"/" == root {
+			continue
+		}
+		for _, ps := range r.Propstats {
+			if !strings.Contains(ps.Status, " 200 ") {
+				continue
+			}
+			if ps.Prop.Collection != nil {
+				dirs = append(dirs, rel)
+				break
+			}
+			size, _ := strconv.ParseInt(strings.TrimSpace(ps.Prop.Length), 10, 64)
+			files[rel] = remoteEntry{Size: size, ETag: strings.TrimSpace(ps.Prop.ETag)}
+			break
+		}
+	}
+	return files, dirs
+}
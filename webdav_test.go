@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebDAVWatchPath(t *testing.T) {
+	p, err := webdavWatchPath("https://cloud.example.com/remote.php/dav/files/me/uploads/")
+	if err != nil || p != "webdav:cloud.example.com/remote.php/dav/files/me/uploads" {
+		t.Errorf("webdavWatchPath = %q, %v", p, err)
+	}
+	if !isRemoteWatchPath(p) {
+		t.Errorf("isRemoteWatchPath(%q) = false", p)
+	}
+	for _, bad := range []string{"", "ftp://host/x", "/local/dir"} {
+		if _, err := webdavWatchPath(bad); err == nil {
+			t.Errorf("webdavWatchPath(%q) accepted", bad)
+		}
+	}
+}
+
+func davEntry(href string, collection bool, size int64, etag string) string {
+	prop := "<d:resourcetype/>"
+	if collection {
+		prop = "<d:resourcetype><d:collection/></d:resourcetype>"
+	} else {
+		prop += fmt.Sprintf("<d:getcontentlength>%d</d:getcontentlength><d:getetag>%s</d:getetag>", size, etag)
+	}
+	return "<d:response><d:href>" + href + "</d:href><d:propstat><d:prop>" + prop +
+		"</d:prop><d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>"
+}
+
+func TestWebDAVLister(t *testing.T) {
+	origConfig := config
+	defer func() { config = origConfig }()
+	config = Config{MonitorSubdirs: true}
+
+	listings := map[string]string{
+		"/dav/uploads/": davEntry("/dav/uploads/", true, 0, "") +
+			davEntry("/dav/uploads/clip.mp4", false, 100, `"a1"`) +
+			davEntry("/dav/uploads/Day%201/", true, 0, ""),
+		"/dav/uploads/Day 1/": davEntry("/dav/uploads/Day%201/", true, 0, "") +
+			davEntry("http://other/dav/uploads/Day%201/b%20c.jpg", false, 7, `"b2"`),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PROPFIND" || r.Header.Get("Depth") != "1" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user != "me" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		body, ok := listings[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusMultiStatus)
+		fmt.Fprint(w, `<?xml version="1.0"?><d:multistatus xmlns:d="DAV:">`+body+`</d:multistatus>`)
+	}))
+	defer srv.Close()
+
+	l := webdavLister{URL: srv.URL + "/dav/uploads", User: "me", Password: "secret"}
+	files, err := l.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %v", files)
+	}
+	if e := files["clip.mp4"]; e.Size != 100 || e.ETag != `"a1"` {
+		t.Errorf("clip.mp4 = %+v", e)
+	}
+	if e := files["Day 1/b c.jpg"]; e.Size != 7 {
+		t.Errorf("Day 1/b c.jpg = %+v", e)
+	}
+
+	l.Password = "wrong"
+	if _, err := l.List(context.Background()); err == nil {
+		t.Error("Expected an error when the folder can't be listed")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"runtime"
+	"strings"
+)
+
+// maxDirPathLen is the Win32 limit for directory paths: MAX_PATH (260)
+// minus room for an 8.3 file name
+const maxDirPathLen = 248
+
+// normalizeWatchPath converts a folder picked in the UI into the form used
+// for watching. On Windows this is a native path, extended to \\?\ form when
+// it is too long for the Win32 APIs behind the watcher.
+func normalizeWatchPath(p string) string {
+	if runtime.GOOS != "windows" {
+		return p
+	}
+	return extendedLengthPath(cleanWindowsPath(p))
+}
+
+// cleanWindowsPath turns a picker path into a native Windows path:
+// "/C:/Media/in" becomes `C:\Media\in` and "//nas/share/in" becomes
+// `\\nas\share\in`. Extended-length paths are returned as-is.
+func cleanWindowsPath(p string) string {
+	p = strings.ReplaceAll(p, "/", `\`)
+	if strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	// UNC root: \\server\share\...
+	if strings.HasPrefix(p, `\\`) {
+		return `\\` + cleanPathSegments(p[2:])
+	}
+	// Leading slash from file URIs, e.g. \C:\path
+	if len(p) > 2 && p[0] == '\\' && p[2] == ':' {
+		p = p[1:]
+	}
+	if len(p) >= 2 && p[1] == ':' {
+		return strings.ToUpper(p[:1]) + `:\` + cleanPathSegments(p[2:])
+	}
+	return p
+}
+
+// cleanPathSegments drops empty and "." segments and resolves ".."
+func cleanPathSegments(p string) string {
+	var out []string
+	for _, seg := range strings.Split(p, `\`) {
+		switch seg {
+		case "", ".":
+		case "..":
+			if len(out) > 0 {
+				out = out[:len(out)-1]
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	return strings.Join(out, `\`)
+}
+
+// extendedLengthPath adds the \\?\ prefix (\\?\UNC\ for shares) to paths
+// over the Win32 limit; shorter paths are returned unchanged
+func extendedLengthPath(p string) string {
+	if len(p) < maxDirPathLen || strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	if strings.HasPrefix(p, `\\`) {
+		return `\\?\UNC\` + p[2:]
+	}
+	return `\\?\` + p
+}
+
+// displayWindowsPath strips extended-length prefixes for display
+func displayWindowsPath(p string) string {
+	if strings.HasPrefix(p, `\\?\UNC\`) {
+		return `\\` + p[len(`\\?\UNC\`):]
+	}
+	return strings.TrimPrefix(p, `\\?\`)
+}
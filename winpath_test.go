@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCleanWindowsPath(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"/C:/Media/in", `C:\Media\in`},
+		{"c:/Media/./in/", `C:\Media\in`},
+		{`C:\Media\old\..\in`, `C:\Media\in`},
+		{"//nas/share/uploads", `\\nas\share\uploads`},
+		{`\\nas\share\uploads\`, `\\nas\share\uploads`},
+		{`\\?\C:\very\long`, `\\?\C:\very\long`},
+	}
+	for _, tt := range tests {
+		if result := cleanWindowsPath(tt.input); result != tt.expected {
+			t.Errorf("cleanWindowsPath(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+	}
+}
+
+func TestExtendedLengthPath(t *testing.T) {
+	long := strings.Repeat(`\segment`, 40)
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`C:\short`, `C:\short`},
+		{`\\nas\share`, `\\nas\share`},
+		{`C:` + long, `\\?\C:` + long},
+		{`\\nas\share` + long, `\\?\UNC\nas\share` + long},
+		{`\\?\C:` + long, `\\?\C:` + long},
+	}
+	for _, tt := range tests {
+		result := extendedLengthPath(tt.input)
+		if result != tt.expected {
+			t.Errorf("extendedLengthPath(%q) = %q, want %q", tt.input, result, tt.expected)
+		}
+		if displayWindowsPath(result) != tt.input && !strings.HasPrefix(tt.input, `\\?\`) {
+			t.Errorf("displayWindowsPath(%q) = %q, want %q", result, displayWindowsPath(result), tt.input)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"fyne.io/fyne/v2"
+	"fyne.io/fyne/v2/container"
+	"fyne.io/fyne/v2/dialog"
+	"fyne.io/fyne/v2/widget"
+)
+
+// firstRun is set when no config file was found at startup, so the setup
+// wizard is shown instead of leaving the user in the settings tab
+var firstRun bool
+
+// wizardSummary describes the settings chosen in the wizard for its last
+// page
+func wizardSummary(c Config) []string {
+	folder := "未选择, 可稍后在监控页选择"
+	if c.WatchPath != "" {
+		folder = displayWindowsPath(c.WatchPath)
+	}
+	var types []string
+	for _, t := range []struct {
+		on   bool
+		name string
+	}{{c.VideoEnabled, "视频"}, {c.ImageEnabled, "图片"}, {c.AudioEnabled, "音频"}, {c.DocEnabled, "文档"}, {c.ArchiveEnabled, "压缩包"}} {
+		if t.on {
+			types = append(types, t.name)
+		}
+	}
+	if c.CustomExts != "" {
+		types = append(types, c.CustomExts)
+	}
+	if len(types) == 0 {
+		types = append(types, "无 (开始监控前请至少选择一种)")
+	}
+	var notify []string
+	if c.NotifyOnStart {
+		notify = append(notify, "上传开始")
+	}
+	if c.NotifyOnComplete {
+		notify = append(notify, "上传完成")
+	}
+	if c.SoundEnabled {
+		notify = append(notify, "声音")
+	}
+	if c.SysLog != sysLogOff && c.SysLog != "" {
+		notify = append(notify, "系统日志 "+c.SysLog)
+	}
+	if len(notify) == 0 {
+		notify = append(notify, "关闭")
+	}
+	format := "跟随系统"
+	for _, opt := range localeOptions {
+		if opt.Tag == c.Locale {
+			format = opt.Label
+		}
+	}
+	return []string{
+		"🌐 数字与时间格式: " + format,
+		"📁 监控文件夹: " + folder,
+		"🎞️ 文件类型: " + strings.Join(types, " "),
+		"🔔 提醒: " + strings.Join(notify, "、"),
+	}
+}
+
+// showFirstRunWizard walks a new user through the format, watch folder,
+// file types and notifications, working on a copy of the settings. done
+// gets the result when the user finishes; skipping keeps the defaults.
+func showFirstRunWizard(w fyne.Window, done func(Config)) {
+	c := config
+
+	// Format
+	var localeLabels []string
+	for _, opt := range localeOptions {
+		localeLabels = append(localeLabels, opt.Label)
+	}
+	localeSelect := widget.NewSelect(localeLabels, func(selected string) {
+		for _, opt := range localeOptions {
+			if opt.Label == selected {
+				c.Locale = opt.Tag
+			}
+		}
+	})
+	for i, opt := range localeOptions {
+		if opt.Tag == c.Locale {
+			localeSelect.SetSelectedIndex(i)
+		}
+	}
+	welcome := widget.NewLabel("欢迎使用 FidruaWatch! 它监控一个文件夹, 把一起到达的文件归为批次, 并在上传完成时提醒你。接下来几步完成基本设置, 其余设置随时可在「设置」页修改。")
+	welcome.Wrapping = fyne.TextWrapWord
+
+	// Folder
+	folderLabel := widget.NewLabel("尚未选择")
+	folderLabel.Wrapping = fyne.TextWrapBreak
+	if c.WatchPath != "" {
+		folderLabel.SetText(displayWindowsPath(c.WatchPath))
+	}
+	folderBtn := widget.NewButton("📁 选择文件夹", func() {
+		d := dialog.NewFolderOpen(func(uri fyne.ListableURI, err error) {
+			if err != nil || uri == nil {
+				return
+			}
+			c.WatchPath = normalizeWatchPath(uri.Path())
+			folderLabel.SetText(displayWindowsPath(c.WatchPath))
+		}, w)
+		d.Resize(fyne.NewSize(600, 450))
+		d.Show()
+	})
+	subdirCheck := widget.NewCheck("同时监控子文件夹", func(on bool) { c.MonitorSubdirs = on })
+	subdirCheck.SetChecked(c.MonitorSubdirs)
+
+	// File types, optionally from a preset
+	typeChecks := []struct {
+		label string
+		field *bool
+	}{
+		{"🎬 视频", &c.VideoEnabled},
+		{"🖼️ 图片", &c.ImageEnabled},
+		{"🎵 音频", &c.AudioEnabled},
+		{"📄 文档", &c.DocEnabled},
+		{"📦 压缩包", &c.ArchiveEnabled},
+	}
+	typeBox := container.NewVBox()
+	var checks []*widget.Check
+	for _, t := range typeChecks {
+		field := t.field
+		check := widget.NewCheck(t.label, func(on bool) { *field = on })
+		check.SetChecked(*field)
+		checks = append(checks, check)
+		typeBox.Add(check)
+	}
+	customEntry := widget.NewEntry()
+	customEntry.SetPlaceHolder("其他扩展名, 逗号分隔, 如 .cr3,.mxf")
+	customEntry.SetText(c.CustomExts)
+	customEntry.OnChanged = func(s string) { c.CustomExts = strings.TrimSpace(s) }
+	presetNames := []string{"自定义"}
+	for _, p := range builtinPresets {
+		presetNames = append(presetNames, p.Icon+" "+p.Name)
+	}
+	presetSelect := widget.NewSelect(presetNames, func(selected string) {
+		for _, p := range builtinPresets {
+			if p.Icon+" "+p.Name != selected {
+				continue
+			}
+			applyPresetSettings(&c, p.Settings)
+			for i, t := range typeChecks {
+				checks[i].SetChecked(*t.field)
+			}
+			customEntry.SetText(c.CustomExts)
+			subdirCheck.SetChecked(c.MonitorSubdirs)
+		}
+	})
+	presetSelect.SetSelectedIndex(0)
+
+	// Notifications
+	startCheck := widget.NewCheck("📤 上传开始时提醒", func(on bool) { c.NotifyOnStart = on })
+	startCheck.SetChecked(c.NotifyOnStart)
+	completeCheck := widget.NewCheck("✅ 上传完成时提醒", func(on bool) { c.NotifyOnComplete = on })
+	completeCheck.SetChecked(c.NotifyOnComplete)
+	soundCheck := widget.NewCheck("🔊 播放提示音", func(on bool) { c.SoundEnabled = on })
+	soundCheck.SetChecked(c.SoundEnabled)
+	var sysLogLabels []string
+	for _, opt := range sysLogOptions {
+		for _, backend := range sysLogBackends {
+			if opt.Backend == backend {
+				sysLogLabels = append(sysLogLabels, opt.Label)
+			}
+		}
+	}
+	sysLogSelect := widget.NewSelect(sysLogLabels, func(selected string) {
+		for _, opt := range sysLogOptions {
+			if opt.Label == selected {
+				c.SysLog = opt.Backend
+			}
+		}
+	})
+	for _, opt := range sysLogOptions {
+		if opt.Backend == c.SysLog {
+			sysLogSelect.SetSelected(opt.Label)
+		}
+	}
+	pluginHint := widget.NewLabel("还可以在「设置 → 规则 → 插件」中添加通知插件, 把提醒转发到聊天工具或邮件。")
+	pluginHint.Wrapping = fyne.TextWrapWord
+
+	summary := widget.NewLabel("")
+	summary.Wrapping = fyne.TextWrapWord
+
+	steps := []struct {
+		title   string
+		content fyne.CanvasObject
+	}{
+		{"👋 欢迎", container.NewVBox(welcome, widget.NewLabel("数字与时间格式:"), localeSelect)},
+		{"📁 监控文件夹", container.NewVBox(widget.NewLabel("选择上传文件到达的文件夹, 如共享文件夹或 NAS 上的目录:"), folderBtn, folderLabel, subdirCheck)},
+		{"🎞️ 文件类型", container.NewVBox(container.NewBorder(nil, nil, widget.NewLabel("从预设开始:"), nil, presetSelect), typeBox, customEntry)},
+		{"🔔 提醒方式", container.NewVBox(startCheck, completeCheck, soundCheck, container.NewBorder(nil, nil, widget.NewLabel("🖥️ 系统日志:"), nil, sysLogSelect), pluginHint)},
+		{"🎉 完成", container.NewVBox(widget.NewLabel("将保存以下设置:"), summary)},
+	}
+
+	step := 0
+	title := widget.NewLabelWithStyle("", fyne.TextAlignLeading, fyne.TextStyle{Bold: true})
+	page := container.NewStack()
+	var d dialog.Dialog
+	backBtn := widget.NewButton("上一步", nil)
+	nextBtn := widget.NewButton("下一步", nil)
+	nextBtn.Importance = widget.HighImportance
+	skipBtn := widget.NewButton("跳过向导", func() {
+		logEvent("首次运行向导已跳过")
+		d.Hide()
+	})
+	show := func() {
+		title.SetText(fmt.Sprintf("%s  (%d/%d)", steps[step].title, step+1, len(steps)))
+		if step == len(steps)-1 {
+			summary.SetText(strings.Join(wizardSummary(c), "\n"))
+			nextBtn.SetText("完成")
+		} else {
+			nextBtn.SetText("下一步")
+		}
+		if step == 0 {
+			backBtn.Disable()
+		} else {
+			backBtn.Enable()
+		}
+		page.Objects = []fyne.CanvasObject{steps[step].content}
+		page.Refresh()
+	}
+	backBtn.OnTapped = func() {
+		step--
+		show()
+	}
+	nextBtn.OnTapped = func() {
+		if step < len(steps)-1 {
+			step++
+			show()
+			return
+		}
+		d.Hide()
+		logEvent("首次运行向导完成")
+		done(c)
+	}
+	show()
+
+	buttons := container.NewBorder(nil, nil, skipBtn, container.NewHBox(backBtn, nextBtn))
+	d = dialog.NewCustomWithoutButtons("FidruaWatch 初始设置", container.NewBorder(title, buttons, nil, nil, page), w)
+	d.Resize(fyne.NewSize(560, 440))
+	d.Show()
+}
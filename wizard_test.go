@@ -0,0 +1,45 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFirstRun(t *testing.T) {
+	origConfig, origConfigPath, origFirstRun := config, configPath, firstRun
+	defer func() { config, configPath, firstRun = origConfig, origConfigPath, origFirstRun }()
+	configPath = filepath.Join(t.TempDir(), "config.json")
+
+	config = defaultConfig()
+	if err := loadConfig(); err != nil || !firstRun {
+		t.Errorf("missing config file: firstRun %v, err %v", firstRun, err)
+	}
+	os.WriteFile(configPath, []byte(`{"version": 2}`), 0644)
+	if err := loadConfig(); err != nil || firstRun {
+		t.Errorf("existing config file: firstRun %v, err %v", firstRun, err)
+	}
+}
+
+func TestWizardSummary(t *testing.T) {
+	c := defaultConfig()
+	c.VideoEnabled, c.ImageEnabled, c.AudioEnabled, c.DocEnabled, c.ArchiveEnabled = true, false, false, true, false
+	c.CustomExts = ".cr3"
+	c.NotifyOnStart, c.NotifyOnComplete, c.SoundEnabled, c.SysLog = false, true, true, sysLogOff
+	c.Locale = "de-DE"
+
+	got := strings.Join(wizardSummary(c), "\n")
+	for _, want := range []string{"Deutsch", "未选择", "视频 文档 .cr3", "上传完成、声音"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("summary lacks %q:\n%s", want, got)
+		}
+	}
+
+	c.VideoEnabled, c.DocEnabled, c.CustomExts = false, false, ""
+	c.NotifyOnComplete, c.SoundEnabled = false, false
+	got = strings.Join(wizardSummary(c), "\n")
+	if !strings.Contains(got, "开始监控前请至少选择一种") || !strings.Contains(got, "提醒: 关闭") {
+		t.Errorf("empty choices not flagged:\n%s", got)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Worker pool sizing for file system calls made on behalf of watch events
+const (
+	statWorkers   = 4
+	statQueueSize = 1024
+)
+
+// workPool runs jobs on a fixed number of goroutines so slow file systems
+// (network shares, sleeping disks) don't stall the caller. Jobs have a key;
+// submitting a key that is still queued is a no-op, so a burst of write
+// events for one file costs one stat.
+type workPool struct {
+	jobs    chan workJob
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+type workJob struct {
+	key string
+	fn  func()
+}
+
+// newWorkPool starts workers goroutines serving a queue of queueSize jobs
+// until ctx is cancelled
+func newWorkPool(ctx context.Context, workers, queueSize int) *workPool {
+	p := &workPool{
+		jobs:    make(chan workJob, queueSize),
+		pending: make(map[string]bool),
+	}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-p.jobs:
+					// Cleared before running so events arriving meanwhile
+					// queue another pass that sees them
+					p.mu.Lock()
+					delete(p.pending, job.key)
+					p.mu.Unlock()
+					job.fn()
+				}
+			}
+		}()
+	}
+	return p
+}
+
+// Len returns the number of queued jobs
+func (p *workPool) Len() int {
+	return len(p.jobs)
+}
+
+// Submit queues fn under key without blocking. It returns false when the
+// queue is full and the job was dropped.
+func (p *workPool) Submit(key string, fn func()) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.pending[key] {
+		return true
+	}
+	select {
+	case p.jobs <- workJob{key: key, fn: fn}:
+		p.pending[key] = true
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkPoolCoalescesAndBounds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	release := make(chan struct{})
+	p := newWorkPool(ctx, 1, 2)
+
+	// Occupy the only worker
+	var started sync.WaitGroup
+	started.Add(1)
+	p.Submit("busy", func() { started.Done(); <-release })
+	started.Wait()
+
+	var runs int32
+	job := func() { atomic.AddInt32(&runs, 1) }
+	for i := 0; i < 10; i++ {
+		if !p.Submit("a.mp4", job) {
+			t.Fatal("Repeated key should coalesce, not fill the queue")
+		}
+	}
+	p.Submit("b.mp4", job)
+	if p.Submit("c.mp4", job) {
+		t.Error("Submit should fail without blocking when the queue is full")
+	}
+
+	close(release)
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&runs) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if n := atomic.LoadInt32(&runs); n != 2 {
+		t.Errorf("Ran %d jobs, want 2", n)
+	}
+	// Once run, the key can be queued again
+	if !p.Submit("a.mp4", job) {
+		t.Error("Key not released after running")
+	}
+}
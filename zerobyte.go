@@ -0,0 +1,126 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// How zero-byte files are treated. Some upload portals touch an empty
+// placeholder before the real data arrives, which can start a batch that then
+// completes before anything was written.
+const (
+	zeroByteInclude = "include" // count them like any other file
+	zeroByteIgnore  = "ignore"  // skip them until they grow, drop them if still empty at completion
+	zeroByteHold    = "hold"    // track them but hold completion until they grow
+	zeroByteFlag    = "flag"    // track them and flag the batch
+)
+
+var zeroByteModes = []struct {
+	Mode  string
+	Label string
+}{
+	{zeroByteInclude, "正常计入"},
+	{zeroByteIgnore, "忽略空文件"},
+	{zeroByteHold, "暂缓完成直到写入"},
+	{zeroByteFlag, "计入并标记提醒"},
+}
+
+// zeroByteCount returns how many files of a batch are still empty.
+// Caller must hold batchesMu.
+func zeroByteCount(b *Batch) int {
+	n := 0
+	for _, f := range b.Files {
+		if b.FileSizes[f] == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// refreshZeroByteFiles re-checks the empty files of a batch on disk: grown
+// files get their size recorded, vanished placeholders are dropped. It
+// returns how many are still empty. Caller must hold batchesMu.
+func refreshZeroByteFiles(b *Batch) int {
+	remaining := 0
+	kept := b.Files[:0]
+	for _, f := range b.Files {
+		if b.FileSizes[f] != 0 {
+			kept = append(kept, f)
+			continue
+		}
+		info, err := os.Stat(filepath.Join(b.Folder, f))
+		if err != nil {
+			delete(b.FileSizes, f)
+			continue
+		}
+		if size := info.Size(); size > 0 {
+			b.FileSizes[f] = size
+			b.TotalSize += size
+		} else {
+			remaining++
+		}
+		kept = append(kept, f)
+	}
+	b.Files = kept
+	return remaining
+}
+
+// holdForZeroByte holds completion while placeholders are still empty. In
+// ignore mode files still empty at completion are dropped instead.
+// Caller must hold batchesMu.
+func holdForZeroByte(b *Batch) bool {
+	switch {
+	case config.ZeroByteMode == zeroByteHold && zeroByteCount(b) > 0:
+		// Remote sizes can't be stat'ed; the next listing updates them
+		if isRemoteWatchPath(b.Folder) {
+			return true
+		}
+		return refreshZeroByteFiles(b) > 0
+	case config.ZeroByteMode == zeroByteIgnore && zeroByteCount(b) > 0:
+		dropZeroByteFiles(b)
+		return len(b.Files) == 0
+	}
+	return false
+}
+
+// dropZeroByteFiles removes the files of a batch that are still empty, and
+// the batch itself when nothing is left. Caller must hold batchesMu.
+func dropZeroByteFiles(b *Batch) {
+	if !isRemoteWatchPath(b.Folder) && refreshZeroByteFiles(b) == 0 {
+		return
+	}
+	kept := b.Files[:0]
+	for _, f := range b.Files {
+		if b.FileSizes[f] != 0 {
+			kept = append(kept, f)
+			continue
+		}
+		delete(b.FileSizes, f)
+		delete(b.Growth, f)
+		logEvent("批次 %s: 忽略空文件 %s", b.ID, f)
+	}
+	b.Files = kept
+	if len(b.Files) == 0 {
+		delete(batches, b.ID)
+		logEvent("批次 %s 只有空文件, 已移除", b.ID)
+		publishBatchEvent(eventDeleted, b)
+	}
+}
+
+// trackedFile reports whether a batch of the folder already has the file
+func trackedFile(folderNorm, fileName string) bool {
+	batchesMu.RLock()
+	defer batchesMu.RUnlock()
+	key := pathKey(fileName)
+	for _, b := range batches {
+		if pathKey(b.Folder) != folderNorm {
+			continue
+		}
+		for _, f := range b.Files {
+			if pathKey(f) == key {
+				return true
+			}
+		}
+	}
+	return false
+}
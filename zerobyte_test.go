@@ -0,0 +1,97 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestZeroByteModes(t *testing.T) {
+	tmpDir := t.TempDir()
+	placeholder := filepath.Join(tmpDir, "placeholder.mp4")
+	os.WriteFile(placeholder, nil, 0644)
+
+	origConfig := config
+	origBatches := batches
+	defer func() {
+		config = origConfig
+		batches = origBatches
+	}()
+
+	config = Config{VideoEnabled: true, ZeroByteMode: zeroByteIgnore}
+	batches = make(map[string]*Batch)
+	if addFileToBatch(placeholder); len(batches) != 0 {
+		t.Errorf("Ignore mode created %d batches for an empty file", len(batches))
+	}
+
+	config.ZeroByteMode = zeroByteHold
+	addFileToBatch(placeholder)
+	if len(batches) != 1 {
+		t.Fatalf("Hold mode should track the empty file, got %d batches", len(batches))
+	}
+	var b *Batch
+	for _, batch := range batches {
+		b = batch
+	}
+	if !holdForZeroByte(b) {
+		t.Error("Expected completion to be held while the file is empty")
+	}
+
+	os.WriteFile(placeholder, []byte("real data"), 0644)
+	if holdForZeroByte(b) {
+		t.Error("Expected completion to be released once the file grew")
+	}
+	if b.TotalSize != int64(len("real data")) {
+		t.Errorf("TotalSize = %d, want %d", b.TotalSize, len("real data"))
+	}
+	if zeroByteCount(b) != 0 {
+		t.Errorf("zeroByteCount = %d, want 0", zeroByteCount(b))
+	}
+}
+
+func TestZeroByteIgnoreKeepsUnknownAndTruncated(t *testing.T) {
+	origConfig, origBatches, origMonitorPath := config, batches, monitorPath
+	defer func() {
+		config, batches, monitorPath = origConfig, origBatches, origMonitorPath
+	}()
+	config = Config{VideoEnabled: true, MonitorSubdirs: true, ZeroByteMode: zeroByteIgnore}
+	batches = make(map[string]*Batch)
+	monitorPath = t.TempDir()
+	os.MkdirAll(filepath.Join(monitorPath, "cam"), 0755)
+	a := filepath.Join(monitorPath, "cam", "a.mp4")
+	b := filepath.Join(monitorPath, "cam", "b.mp4")
+
+	// A size that could not be read is tracked, not taken for empty
+	addObservedFile(a, -1)
+	if len(batches) != 1 {
+		t.Fatalf("unknown size: %d batches, want 1", len(batches))
+	}
+	// A tracked file truncated mid-upload stays in its batch
+	addObservedFile(b, 500)
+	addObservedFile(b, 0)
+	var batch *Batch
+	for _, x := range batches {
+		batch = x
+	}
+	if len(batch.Files) != 2 {
+		t.Fatalf("files = %v, want a.mp4 and b.mp4", batch.Files)
+	}
+
+	// At completion the file that got data is kept, the empty one dropped
+	os.WriteFile(b, []byte("data"), 0644)
+	os.WriteFile(a, nil, 0644)
+	if holdForZeroByte(batch) {
+		t.Error("completion held in ignore mode")
+	}
+	if len(batch.Files) != 1 || batch.Files[0] != "b.mp4" || batch.TotalSize != 4 {
+		t.Errorf("files = %v, total %d; want only b.mp4 of 4 bytes", batch.Files, batch.TotalSize)
+	}
+
+	// A batch left with only empty files goes away
+	os.WriteFile(b, nil, 0644)
+	batch.FileSizes["b.mp4"] = 0
+	batch.TotalSize = 0
+	if !holdForZeroByte(batch) || len(batches) != 0 {
+		t.Errorf("empty batch kept: %d batches", len(batches))
+	}
+}